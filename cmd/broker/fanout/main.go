@@ -21,9 +21,14 @@ import (
 	"time"
 
 	"cloud.google.com/go/pubsub"
+	vkit "cloud.google.com/go/pubsub/apiv1"
+	"cloud.google.com/go/storage"
 
+	"github.com/google/knative-gcp/pkg/broker/archive"
 	"github.com/google/knative-gcp/pkg/broker/config/volume"
 	"github.com/google/knative-gcp/pkg/broker/handler"
+	"github.com/google/knative-gcp/pkg/gclient/bigquery"
+	"github.com/google/knative-gcp/pkg/logging"
 	"github.com/google/knative-gcp/pkg/metrics"
 	"github.com/google/knative-gcp/pkg/utils"
 	"github.com/google/knative-gcp/pkg/utils/appcredentials"
@@ -38,6 +43,9 @@ const (
 	component        = "broker-fanout"
 	metricNamespace  = "trigger"
 	poolResyncPeriod = 15 * time.Second
+	// drainCushion is added to the drain timeout on shutdown for the aborted
+	// events to be nacked.
+	drainCushion = 5 * time.Second
 )
 
 type envConfig struct {
@@ -46,6 +54,13 @@ type envConfig struct {
 	HandlerConcurrency     int    `envconfig:"HANDLER_CONCURRENCY"`
 	MaxConcurrencyPerEvent int    `envconfig:"MAX_CONCURRENCY_PER_EVENT"`
 
+	// MaxConcurrencyPerTarget bounds the adaptive limit of concurrent deliveries to each target.
+	// If not set, deliveries are not limited.
+	MaxConcurrencyPerTarget int `envconfig:"MAX_CONCURRENCY_PER_TARGET"`
+
+	// DeliveryHeadersPath is the directory the delivery headers Secret is mounted in.
+	DeliveryHeadersPath string `envconfig:"DELIVERY_HEADERS_PATH"`
+
 	// Environment variable containing the authType, which represents the authentication configuration mode the Pod is using.
 	AuthType authcheck.AuthType `envconfig:"K_GCP_AUTH_TYPE" default:""`
 
@@ -63,6 +78,31 @@ type envConfig struct {
 
 	// Max to 10m.
 	TimeoutPerEvent time.Duration `envconfig:"TIMEOUT_PER_EVENT"`
+
+	// PullWorkers is the number of workers pulling the decouple subscriptions of all brokers,
+	// with MaxOutstandingMessages bounding the messages processed across all of them. If not set,
+	// each broker has its own streaming pull.
+	PullWorkers int `envconfig:"PULL_WORKERS"`
+
+	// DrainTimeout is how long the in-flight events of a stopped handler are given to be
+	// delivered, on shutdown or when the handler is renewed, before they are aborted.
+	DrainTimeout time.Duration `envconfig:"DRAIN_TIMEOUT" default:"30s"`
+
+	// BigQueryBatchSize is the max number of rows inserted at once into the Trigger subscribers
+	// that are BigQuery tables.
+	BigQueryBatchSize int `envconfig:"BIGQUERY_BATCH_SIZE" default:"500"`
+
+	// BigQueryBatchDelay is how long the rows wait for more rows to be inserted into the same
+	// BigQuery table with.
+	BigQueryBatchDelay time.Duration `envconfig:"BIGQUERY_BATCH_DELAY" default:"50ms"`
+
+	// ArchiveMaxEvents is the max number of events written at once to an archive file of the
+	// Brokers whose events are archived.
+	ArchiveMaxEvents int `envconfig:"ARCHIVE_MAX_EVENTS" default:"1000"`
+
+	// ArchiveFlushDelay is how long the events wait for more events to be written to the same
+	// archive file with.
+	ArchiveFlushDelay time.Duration `envconfig:"ARCHIVE_FLUSH_DELAY" default:"10s"`
 }
 
 func main() {
@@ -88,6 +128,35 @@ func main() {
 		logger.Fatalf("failed to get default ProjectID: %v", err)
 	}
 
+	bigqueryClient, err := bigquery.NewClient(ctx)
+	if err != nil {
+		logger.Fatal("Failed to create BigQuery client", zap.Error(err))
+	}
+	storageClient, err := storage.NewClient(ctx)
+	if err != nil {
+		logger.Fatal("Failed to create Cloud Storage client", zap.Error(err))
+	}
+	defer storageClient.Close()
+
+	opts := buildHandlerOptions(env)
+	opts = append(opts, handler.WithEventRecorder(mainhelper.NewEventRecorder(ctx, res.KubeClient, component)))
+	opts = append(opts, handler.WithBigQueryClient(bigqueryClient))
+	opts = append(opts, handler.WithArchiveStore(archive.NewGCSStore(storageClient)))
+	// The scheduler keeps leasing and acking the in-flight events while they
+	// are drained on shutdown.
+	schedulerCtx, stopScheduler := context.WithCancel(logging.WithLogger(context.Background(), logger.Desugar()))
+	defer stopScheduler()
+	if env.PullWorkers > 0 {
+		subscriberClient, err := vkit.NewSubscriberClient(ctx)
+		if err != nil {
+			logger.Fatal("Failed to create pubsub subscriber client", zap.Error(err))
+		}
+		defer subscriberClient.Close()
+		scheduler := handler.NewPullScheduler(subscriberClient, env.PullWorkers, env.MaxOutstandingMessages)
+		scheduler.Start(schedulerCtx)
+		opts = append(opts, handler.WithPullScheduler(scheduler))
+	}
+
 	syncSignal := poolSyncSignal(ctx, targetsUpdateCh)
 	syncPool, err := InitializeSyncPool(
 		ctx,
@@ -98,7 +167,7 @@ func main() {
 			volume.WithPath(env.TargetsConfigPath),
 			volume.WithNotifyChan(targetsUpdateCh),
 		},
-		buildHandlerOptions(env)...,
+		opts...,
 	)
 	if err != nil {
 		logger.Fatal("Failed to create fanout sync pool", zap.Error(err))
@@ -109,9 +178,13 @@ func main() {
 
 	// Context will be done if a TERM signal is issued.
 	<-ctx.Done()
-	// Wait a grace period for the handlers to shutdown.
-	time.Sleep(30 * time.Second)
-	logger.Info("Done waiting, exit.")
+	// Stop pulling and wait for the in-flight events to be drained.
+	drainCtx, cancel := context.WithTimeout(context.Background(), env.DrainTimeout+drainCushion)
+	defer cancel()
+	if err := syncPool.Drain(drainCtx); err != nil {
+		logger.Warn("Timed out draining the fanout handlers", zap.Error(err))
+	}
+	logger.Info("Done draining, exit.")
 }
 
 func poolSyncSignal(ctx context.Context, targetsUpdateCh chan struct{}) chan struct{} {
@@ -148,12 +221,23 @@ func buildHandlerOptions(env envConfig) []handler.Option {
 	if env.TimeoutPerEvent > 0 {
 		opts = append(opts, handler.WithTimeoutPerEvent(env.TimeoutPerEvent))
 	}
+	if env.MaxConcurrencyPerTarget > 0 {
+		opts = append(opts, handler.WithMaxConcurrencyPerTarget(env.MaxConcurrencyPerTarget))
+	}
+	if env.DeliveryHeadersPath != "" {
+		opts = append(opts, handler.WithDeliveryHeadersPath(env.DeliveryHeadersPath))
+	}
 	if env.MaxOutstandingBytes > 0 {
 		rs.MaxOutstandingBytes = env.MaxOutstandingBytes
 	}
 	if env.MaxOutstandingMessages > 0 {
 		rs.MaxOutstandingMessages = env.MaxOutstandingMessages
 	}
+	opts = append(opts, handler.WithDrainTimeout(env.DrainTimeout))
+	opts = append(opts, handler.WithBigQueryBatchSize(env.BigQueryBatchSize))
+	opts = append(opts, handler.WithBigQueryBatchDelay(env.BigQueryBatchDelay))
+	opts = append(opts, handler.WithArchiveMaxEvents(env.ArchiveMaxEvents))
+	opts = append(opts, handler.WithArchiveFlushDelay(env.ArchiveFlushDelay))
 	opts = append(opts, handler.WithPubsubReceiveSettings(rs))
 	// The default CeClient is good?
 	return opts
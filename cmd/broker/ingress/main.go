@@ -17,6 +17,8 @@ limitations under the License.
 package main
 
 import (
+	"github.com/google/knative-gcp/pkg/broker/config/volume"
+	"github.com/google/knative-gcp/pkg/broker/ingress"
 	"github.com/google/knative-gcp/pkg/metrics"
 	"github.com/google/knative-gcp/pkg/utils"
 	"github.com/google/knative-gcp/pkg/utils/appcredentials"
@@ -31,6 +33,13 @@ import (
 type envConfig struct {
 	PodName string `envconfig:"POD_NAME" required:"true"`
 	Port    int    `envconfig:"PORT" default:"8080"`
+	// Port of the gRPC ingress, which accepts events in the CloudEvents protobuf format.
+	GRPCPort int `envconfig:"GRPC_PORT" default:"8081"`
+	// Port of the HTTPS ingress, which serves the external ingress of the BrokerCell. It is
+	// disabled if 0.
+	TLSPort     int    `envconfig:"TLS_PORT" default:"0"`
+	TLSCertFile string `envconfig:"TLS_CERT_FILE" default:""`
+	TLSKeyFile  string `envconfig:"TLS_KEY_FILE" default:""`
 
 	// Environment variable containing the authType, which represents the authentication configuration mode the Pod is using.
 	AuthType authcheck.AuthType `envconfig:"K_GCP_AUTH_TYPE" default:""`
@@ -45,7 +54,10 @@ const (
 )
 
 // main creates and starts an ingress handler using default options.
-// 1. It listens on port specified by "PORT" env var, or default 8080 if env var is not set
+// 1. It listens on port specified by "PORT" env var, or default 8080 if env var is not set. gRPC
+//    requests are served on "GRPC_PORT", or default 8081, without TLS, as the port is only exposed
+//    inside the cluster. If "TLS_PORT" is set, HTTPS requests are served on it with the certificate
+//    in "TLS_CERT_FILE" and "TLS_KEY_FILE".
 // 2. It reads "PROJECT_ID" env var for pubsub project. If the env var is empty, it retrieves project ID from
 //    GCE metadata.
 // 3. It expects broker configmap mounted at "/var/run/cloud-run-events/broker/targets"
@@ -63,18 +75,25 @@ func main() {
 	}
 	logger.Desugar().Info("Starting ingress handler", zap.Any("envConfig", env), zap.Any("Project ID", projectID))
 
+	// Drop the quota state of the Brokers removed from the targets whenever they are updated.
+	targetsUpdateCh := make(chan struct{})
 	ingress, err := InitializeHandler(
 		ctx,
 		clients.Port(env.Port),
+		clients.GRPCPort(env.GRPCPort),
+		ingress.TLSConfig{Port: env.TLSPort, CertFile: env.TLSCertFile, KeyFile: env.TLSKeyFile},
 		clients.ProjectID(projectID),
 		metrics.PodName(env.PodName),
 		metrics.ContainerName(component),
 		publishSetting(logger.Desugar(), env),
 		env.AuthType,
+		res.KubeClient,
+		[]volume.Option{volume.WithNotifyChan(targetsUpdateCh)},
 	)
 	if err != nil {
 		logger.Desugar().Fatal("Unable to create ingress handler: ", zap.Error(err))
 	}
+	ingress.WatchTargets(targetsUpdateCh)
 
 	logger.Desugar().Info("Starting ingress.", zap.Any("ingress", ingress))
 	if err := ingress.Start(ctx); err != nil {
@@ -28,20 +28,24 @@ import (
 	"github.com/google/knative-gcp/pkg/utils/authcheck"
 	"github.com/google/knative-gcp/pkg/utils/clients"
 	"github.com/google/wire"
+	"k8s.io/client-go/kubernetes"
 )
 
 func InitializeHandler(
 	ctx context.Context,
 	port clients.Port,
+	grpcPort clients.GRPCPort,
+	tlsConfig ingress.TLSConfig,
 	projectID clients.ProjectID,
 	podName metrics.PodName,
 	containerName metrics.ContainerName,
 	publishSettings pubsub.PublishSettings,
 	authType authcheck.AuthType,
+	kubeClient kubernetes.Interface,
+	targetsOptions []volume.Option,
 ) (*ingress.Handler, error) {
 	panic(wire.Build(
 		ingress.HandlerSet,
-		wire.Value([]volume.Option(nil)),
 		volume.NewTargetsFromFile,
 	))
 }
@@ -13,14 +13,15 @@ import (
 	"github.com/google/knative-gcp/pkg/metrics"
 	"github.com/google/knative-gcp/pkg/utils/authcheck"
 	"github.com/google/knative-gcp/pkg/utils/clients"
+	"k8s.io/client-go/kubernetes"
 )
 
 // Injectors from wire.go:
 
-func InitializeHandler(ctx context.Context, port clients.Port, projectID clients.ProjectID, podName metrics.PodName, containerName metrics.ContainerName, publishSettings pubsub.PublishSettings, authType authcheck.AuthType) (*ingress.Handler, error) {
-	httpMessageReceiver := clients.NewHTTPMessageReceiverWithChecker(port, authType)
-	v := _wireValue
-	readonlyTargets, err := volume.NewTargetsFromFile(v...)
+func InitializeHandler(ctx context.Context, port clients.Port, grpcPort clients.GRPCPort, tlsConfig ingress.TLSConfig, projectID clients.ProjectID, podName metrics.PodName, containerName metrics.ContainerName, publishSettings pubsub.PublishSettings, authType authcheck.AuthType, kubeClient kubernetes.Interface, targetsOptions []volume.Option) (*ingress.Handler, error) {
+	httpMessageReceiver := ingress.NewHTTPReceiver(port, authType, tlsConfig)
+	grpcReceiver := ingress.NewGRPCReceiver(grpcPort, authType)
+	readonlyTargets, err := volume.NewTargetsFromFile(targetsOptions...)
 	if err != nil {
 		return nil, err
 	}
@@ -28,15 +29,12 @@ func InitializeHandler(ctx context.Context, port clients.Port, projectID clients
 	if err != nil {
 		return nil, err
 	}
-	multiTopicDecoupleSink := ingress.NewMultiTopicDecoupleSink(ctx, readonlyTargets, client, publishSettings)
 	ingressReporter, err := metrics.NewIngressReporter(podName, containerName)
 	if err != nil {
 		return nil, err
 	}
-	handler := ingress.NewHandler(ctx, httpMessageReceiver, multiTopicDecoupleSink, ingressReporter, authType)
+	multiTopicDecoupleSink := ingress.NewMultiTopicDecoupleSink(ctx, readonlyTargets, client, publishSettings, ingressReporter)
+	eventTypeReporter := ingress.NewEventTypeReporter(kubeClient, podName)
+	handler := ingress.NewHandler(ctx, httpMessageReceiver, grpcReceiver, multiTopicDecoupleSink, readonlyTargets, eventTypeReporter, ingressReporter, authType)
 	return handler, nil
 }
-
-var (
-	_wireValue = []volume.Option(nil)
-)
@@ -0,0 +1,122 @@
+/*
+Copyright 2021 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Command replay sends the events archived for a Broker in a time range back to the Broker. It
+// publishes them straight to the decouple topic of the Broker, so that they keep their arrival time,
+// and it is meant to run as a Job with the identity of the broker data plane, which writes the
+// archive and publishes to the decouple topics.
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"cloud.google.com/go/storage"
+	cepubsub "github.com/cloudevents/sdk-go/protocol/pubsub/v2"
+	"github.com/cloudevents/sdk-go/v2/binding"
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/kelseyhightower/envconfig"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	brokerv1 "github.com/google/knative-gcp/pkg/apis/broker/v1"
+	"github.com/google/knative-gcp/pkg/broker/archive"
+	brokerresources "github.com/google/knative-gcp/pkg/reconciler/broker/resources"
+	"github.com/google/knative-gcp/pkg/utils"
+	"github.com/google/knative-gcp/pkg/utils/appcredentials"
+)
+
+type envConfig struct {
+	// ArchiveBucket and ArchivePrefix are where the events of the Broker are archived, as set by
+	// the archive annotation of the Broker.
+	ArchiveBucket string `envconfig:"ARCHIVE_BUCKET" required:"true"`
+	ArchivePrefix string `envconfig:"ARCHIVE_PREFIX"`
+
+	BrokerNamespace string `envconfig:"BROKER_NAMESPACE" required:"true"`
+	BrokerName      string `envconfig:"BROKER_NAME" required:"true"`
+	// BrokerUID is the UID of the Broker, which names its decouple topic.
+	BrokerUID string `envconfig:"BROKER_UID" required:"true"`
+
+	// ProjectID is the project of the decouple topic of the Broker. It defaults to the project of
+	// the cluster.
+	ProjectID string `envconfig:"PROJECT_ID"`
+
+	// StartTime and EndTime bound the arrival time of the replayed events, in RFC 3339 format.
+	// The start time is inclusive and the end time exclusive.
+	StartTime time.Time `envconfig:"START_TIME" required:"true"`
+	EndTime   time.Time `envconfig:"END_TIME" required:"true"`
+}
+
+func main() {
+	appcredentials.MustExistOrUnsetEnv()
+
+	ctx := context.Background()
+	logCfg := zap.NewProductionConfig()
+	logCfg.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+	logger, err := logCfg.Build()
+	if err != nil {
+		log.Fatalf("Unable to create logger: %v", err)
+	}
+
+	var env envConfig
+	if err := envconfig.Process("", &env); err != nil {
+		logger.Fatal("Failed to process env var", zap.Error(err))
+	}
+	if !env.StartTime.Before(env.EndTime) {
+		logger.Fatal("START_TIME must be before END_TIME", zap.Time("start", env.StartTime), zap.Time("end", env.EndTime))
+	}
+
+	storageClient, err := storage.NewClient(ctx)
+	if err != nil {
+		logger.Fatal("Failed to create Cloud Storage client", zap.Error(err))
+	}
+	defer storageClient.Close()
+	projectID, err := utils.ProjectIDOrDefault(env.ProjectID)
+	if err != nil {
+		logger.Fatal("Failed to get default ProjectID", zap.Error(err))
+	}
+	pubsubClient, err := pubsub.NewClient(ctx, projectID)
+	if err != nil {
+		logger.Fatal("Failed to create Pub/Sub client", zap.Error(err))
+	}
+	defer pubsubClient.Close()
+	topicID := brokerresources.GenerateDecouplingTopicName(&brokerv1.Broker{
+		ObjectMeta: metav1.ObjectMeta{Namespace: env.BrokerNamespace, Name: env.BrokerName, UID: types.UID(env.BrokerUID)},
+	})
+	topic := pubsubClient.Topic(topicID)
+	defer topic.Stop()
+
+	base := archive.BrokerPrefix(env.ArchivePrefix, env.BrokerNamespace, env.BrokerName)
+	logger.Info("Replaying archived events", zap.String("bucket", env.ArchiveBucket), zap.String("prefix", base),
+		zap.Time("start", env.StartTime), zap.Time("end", env.EndTime), zap.String("topic", topicID))
+	sent, err := archive.Replay(ctx, archive.NewGCSStore(storageClient), env.ArchiveBucket, base, env.StartTime, env.EndTime,
+		func(ctx context.Context, e *event.Event) error {
+			msg := new(pubsub.Message)
+			if err := cepubsub.WritePubSubMessage(ctx, binding.ToMessage(e), msg); err != nil {
+				return err
+			}
+			_, err := topic.Publish(ctx, msg).Get(ctx)
+			return err
+		})
+	if err != nil {
+		logger.Fatal("Failed to replay archived events", zap.Int("sent", sent), zap.Error(err))
+	}
+	logger.Info("Replayed archived events", zap.Int("sent", sent))
+}
@@ -22,10 +22,13 @@ import (
 	"time"
 
 	"cloud.google.com/go/pubsub"
+	vkit "cloud.google.com/go/pubsub/apiv1"
 	"go.uber.org/zap"
 
 	"github.com/google/knative-gcp/pkg/broker/config/volume"
 	"github.com/google/knative-gcp/pkg/broker/handler"
+	"github.com/google/knative-gcp/pkg/gclient/bigquery"
+	"github.com/google/knative-gcp/pkg/logging"
 	"github.com/google/knative-gcp/pkg/metrics"
 	"github.com/google/knative-gcp/pkg/utils"
 	"github.com/google/knative-gcp/pkg/utils/appcredentials"
@@ -38,6 +41,9 @@ const (
 	component        = "broker-retry"
 	metricNamespace  = "trigger"
 	poolResyncPeriod = 15 * time.Second
+	// drainCushion is added to the drain timeout on shutdown for the aborted
+	// events to be nacked.
+	drainCushion = 5 * time.Second
 )
 
 type envConfig struct {
@@ -61,6 +67,32 @@ type envConfig struct {
 
 	// Max to 10m.
 	TimeoutPerEvent time.Duration `envconfig:"TIMEOUT_PER_EVENT"`
+
+	// PullWorkers is the number of workers pulling the retry subscriptions of all targets, with
+	// MaxOutstandingMessages bounding the messages processed across all of them. The retries for
+	// which the targets requested a delay wait for it in their ack deadline.
+	PullWorkers int `envconfig:"PULL_WORKERS" default:"8"`
+	// MaxOutstandingMessages is the maximum number of retries processed at once.
+	MaxOutstandingMessages int `envconfig:"MAX_OUTSTANDING_MESSAGES" default:"4000"`
+
+	// MaxConcurrencyPerTarget bounds the adaptive limit of concurrent deliveries to each target.
+	// If not set, deliveries are not limited.
+	MaxConcurrencyPerTarget int `envconfig:"MAX_CONCURRENCY_PER_TARGET"`
+
+	// DeliveryHeadersPath is the directory the delivery headers Secret is mounted in.
+	DeliveryHeadersPath string `envconfig:"DELIVERY_HEADERS_PATH"`
+
+	// DrainTimeout is how long the in-flight events of a stopped handler are given to be
+	// delivered, on shutdown or when the handler is renewed, before they are aborted.
+	DrainTimeout time.Duration `envconfig:"DRAIN_TIMEOUT" default:"30s"`
+
+	// BigQueryBatchSize is the max number of rows inserted at once into the Trigger subscribers
+	// that are BigQuery tables.
+	BigQueryBatchSize int `envconfig:"BIGQUERY_BATCH_SIZE" default:"500"`
+
+	// BigQueryBatchDelay is how long the rows wait for more rows to be inserted into the same
+	// BigQuery table with.
+	BigQueryBatchDelay time.Duration `envconfig:"BIGQUERY_BATCH_DELAY" default:"50ms"`
 }
 
 func main() {
@@ -75,6 +107,9 @@ func main() {
 	if env.MaxStaleDuration > 0 && env.MaxStaleDuration < poolResyncPeriod {
 		logger.Fatalf("MAX_STALE_DURATION must be greater than pool resync period %v", poolResyncPeriod)
 	}
+	if env.PullWorkers <= 0 {
+		logger.Fatal("PULL_WORKERS must be positive")
+	}
 
 	// Give the signal channel some buffer so that reconciling handlers won't
 	// block the targets config update?
@@ -86,6 +121,23 @@ func main() {
 		logger.Fatalf("failed to get default ProjectID: %v", err)
 	}
 
+	bigqueryClient, err := bigquery.NewClient(ctx)
+	if err != nil {
+		logger.Fatal("Failed to create BigQuery client", zap.Error(err))
+	}
+
+	// The scheduler keeps leasing and acking the in-flight retries while they
+	// are drained on shutdown.
+	schedulerCtx, stopScheduler := context.WithCancel(logging.WithLogger(context.Background(), logger.Desugar()))
+	defer stopScheduler()
+	subscriberClient, err := vkit.NewSubscriberClient(ctx)
+	if err != nil {
+		logger.Fatal("Failed to create pubsub subscriber client", zap.Error(err))
+	}
+	defer subscriberClient.Close()
+	scheduler := handler.NewPullScheduler(subscriberClient, env.PullWorkers, env.MaxOutstandingMessages)
+	scheduler.Start(schedulerCtx)
+
 	syncSignal := poolSyncSignal(ctx, targetsUpdateCh)
 	syncPool, err := InitializeSyncPool(
 		ctx,
@@ -96,7 +148,11 @@ func main() {
 			volume.WithPath(env.TargetsConfigPath),
 			volume.WithNotifyChan(targetsUpdateCh),
 		},
-		buildHandlerOptions(env)...,
+		append(buildHandlerOptions(env),
+			handler.WithEventRecorder(mainhelper.NewEventRecorder(ctx, res.KubeClient, component)),
+			handler.WithBigQueryClient(bigqueryClient),
+			handler.WithPullScheduler(scheduler),
+		)...,
 	)
 	if err != nil {
 		logger.Fatal("Failed to get retry sync pool", zap.Error(err))
@@ -107,6 +163,12 @@ func main() {
 
 	// Context will be done if a TERM signal is issued.
 	<-ctx.Done()
+	// Stop pulling and wait for the in-flight events to be drained.
+	drainCtx, cancel := context.WithTimeout(context.Background(), env.DrainTimeout+drainCushion)
+	defer cancel()
+	if err := syncPool.Drain(drainCtx); err != nil {
+		logger.Warn("Timed out draining the retry handlers", zap.Error(err))
+	}
 	logger.Info("Exiting...")
 }
 
@@ -147,6 +209,15 @@ func buildHandlerOptions(env envConfig) []handler.Option {
 	if env.TimeoutPerEvent > 0 {
 		opts = append(opts, handler.WithTimeoutPerEvent(env.TimeoutPerEvent))
 	}
+	if env.MaxConcurrencyPerTarget > 0 {
+		opts = append(opts, handler.WithMaxConcurrencyPerTarget(env.MaxConcurrencyPerTarget))
+	}
+	if env.DeliveryHeadersPath != "" {
+		opts = append(opts, handler.WithDeliveryHeadersPath(env.DeliveryHeadersPath))
+	}
+	opts = append(opts, handler.WithDrainTimeout(env.DrainTimeout))
+	opts = append(opts, handler.WithBigQueryBatchSize(env.BigQueryBatchSize))
+	opts = append(opts, handler.WithBigQueryBatchDelay(env.BigQueryBatchDelay))
 	opts = append(opts, handler.WithPubsubReceiveSettings(rs))
 	// The default CeClient is good?
 	return opts
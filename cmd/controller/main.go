@@ -38,6 +38,7 @@ import (
 	"github.com/google/knative-gcp/pkg/reconciler/messaging/channel"
 	"github.com/google/knative-gcp/pkg/reconciler/trigger"
 	"github.com/google/knative-gcp/pkg/utils/appcredentials"
+	filteredinformerfactory "knative.dev/pkg/client/injection/kube/informers/factory/filtered"
 	"knative.dev/pkg/injection"
 	"knative.dev/pkg/injection/sharedmain"
 	"knative.dev/pkg/signals"
@@ -46,6 +47,8 @@ import (
 func main() {
 	appcredentials.MustExistOrUnsetEnv()
 	ctx := signals.NewContext()
+	// The BrokerCell controller only watches the Secrets opted into delivery headers.
+	ctx = filteredinformerfactory.WithSelectors(ctx, brokercell.DeliveryHeaderSecretsSelector)
 	controllers, err := InitializeControllers(ctx)
 	if err != nil {
 		log.Fatal(err)
@@ -20,6 +20,7 @@ import (
 
 	"github.com/google/knative-gcp/pkg/apis/configs/brokerdelivery"
 	"github.com/google/knative-gcp/pkg/apis/configs/dataresidency"
+	"github.com/google/knative-gcp/pkg/apis/configs/encryption"
 	"github.com/google/knative-gcp/pkg/apis/configs/gcpauth"
 	"github.com/google/knative-gcp/pkg/reconciler/broker"
 	"github.com/google/knative-gcp/pkg/reconciler/brokercell"
@@ -47,6 +48,7 @@ func InitializeControllers(ctx context.Context) ([]injection.ControllerConstruct
 		wire.Struct(new(brokerdelivery.StoreSingleton)),
 		wire.Struct(new(gcpauth.StoreSingleton)),
 		wire.Struct(new(dataresidency.StoreSingleton)),
+		wire.Struct(new(encryption.StoreSingleton)),
 		auditlogs.NewConstructor,
 		storage.NewConstructor,
 		scheduler.NewConstructor,
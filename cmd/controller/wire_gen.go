@@ -10,6 +10,7 @@ import (
 	"context"
 	"github.com/google/knative-gcp/pkg/apis/configs/brokerdelivery"
 	"github.com/google/knative-gcp/pkg/apis/configs/dataresidency"
+	"github.com/google/knative-gcp/pkg/apis/configs/encryption"
 	"github.com/google/knative-gcp/pkg/apis/configs/gcpauth"
 	"github.com/google/knative-gcp/pkg/reconciler/broker"
 	"github.com/google/knative-gcp/pkg/reconciler/brokercell"
@@ -53,11 +54,12 @@ func InitializeControllers(ctx context.Context) ([]injection.ControllerConstruct
 	staticConstructor := static.NewConstructor(iamPolicyManager, storeSingleton)
 	kedaConstructor := keda.NewConstructor(iamPolicyManager, storeSingleton)
 	dataresidencyStoreSingleton := &dataresidency.StoreSingleton{}
-	topicConstructor := topic.NewConstructor(iamPolicyManager, storeSingleton, dataresidencyStoreSingleton)
-	channelConstructor := channel.NewConstructor(dataresidencyStoreSingleton)
-	triggerConstructor := trigger.NewConstructor(dataresidencyStoreSingleton)
+	encryptionStoreSingleton := &encryption.StoreSingleton{}
+	topicConstructor := topic.NewConstructor(iamPolicyManager, storeSingleton, dataresidencyStoreSingleton, encryptionStoreSingleton)
+	channelConstructor := channel.NewConstructor(dataresidencyStoreSingleton, encryptionStoreSingleton)
+	triggerConstructor := trigger.NewConstructor(dataresidencyStoreSingleton, encryptionStoreSingleton)
 	brokerdeliveryStoreSingleton := &brokerdelivery.StoreSingleton{}
-	brokerConstructor := broker.NewConstructor(brokerdeliveryStoreSingleton, dataresidencyStoreSingleton)
+	brokerConstructor := broker.NewConstructor(brokerdeliveryStoreSingleton, dataresidencyStoreSingleton, encryptionStoreSingleton)
 	deploymentConstructor := deployment.NewConstructor()
 	brokercellConstructor := brokercell.NewConstructor()
 	v2 := Controllers(constructor, storageConstructor, schedulerConstructor, pubsubConstructor, buildConstructor, staticConstructor, kedaConstructor, topicConstructor, channelConstructor, triggerConstructor, brokerConstructor, deploymentConstructor, brokercellConstructor)
@@ -23,6 +23,7 @@ import (
 	brokerv1 "github.com/google/knative-gcp/pkg/apis/broker/v1"
 	"github.com/google/knative-gcp/pkg/apis/configs/brokerdelivery"
 	"github.com/google/knative-gcp/pkg/apis/configs/dataresidency"
+	"github.com/google/knative-gcp/pkg/apis/configs/encryption"
 	"github.com/google/knative-gcp/pkg/apis/configs/gcpauth"
 	"github.com/google/knative-gcp/pkg/apis/events"
 	eventsv1 "github.com/google/knative-gcp/pkg/apis/events/v1"
@@ -163,6 +164,7 @@ func NewConfigValidationController(ctx context.Context, _ configmap.Watcher) *co
 			gcpauth.ConfigMapName():        gcpauth.NewDefaultsConfigFromConfigMap,
 			brokerdelivery.ConfigMapName(): brokerdelivery.NewDefaultsConfigFromConfigMap,
 			dataresidency.ConfigMapName():  dataresidency.NewDefaultsConfigFromConfigMap,
+			encryption.ConfigMapName():     encryption.NewDefaultsConfigFromConfigMap,
 		},
 	)
 }
@@ -68,8 +68,23 @@ EXTERNAL_INFORMER_PKG="k8s.io/client-go/informers" \
 "${KNATIVE_CODEGEN_PKG}"/hack/generate-knative.sh "injection" \
   k8s.io/client-go \
   k8s.io/api \
-  "autoscaling:v2beta2" \
+  "autoscaling:v2beta2 policy:v1beta1" \
   --go-header-file "${REPO_ROOT_DIR}"/hack/boilerplate/boilerplate.go.txt
+# Only the PodDisruptionBudget informer of policy/v1beta1 is used.
+rm -rf "${REPO_ROOT_DIR}"/pkg/client/injection/kube/informers/policy/v1beta1/podsecuritypolicy
+
+# Knative Eventing's EventTypes are discovered by the Broker controller.
+OUTPUT_PKG="github.com/google/knative-gcp/pkg/client/injection/eventing" \
+VERSIONED_CLIENTSET_PKG="knative.dev/eventing/pkg/client/clientset/versioned" \
+EXTERNAL_INFORMER_PKG="knative.dev/eventing/pkg/client/informers/externalversions" \
+LISTERS_PKG="knative.dev/eventing/pkg/client/listers" \
+"${KNATIVE_CODEGEN_PKG}"/hack/generate-knative.sh "injection" \
+  knative.dev/eventing/pkg/client \
+  knative.dev/eventing/pkg/apis \
+  "eventing:v1beta1" \
+  --go-header-file "${REPO_ROOT_DIR}"/hack/boilerplate/boilerplate.go.txt
+# Only the client and informers are used.
+rm -rf "${REPO_ROOT_DIR}"/pkg/client/injection/eventing/reconciler
 
 go install github.com/google/wire/cmd/wire
 go generate "${REPO_ROOT_DIR}"/...
@@ -0,0 +1,332 @@
+/*
+Copyright 2021 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rickb777/date/period"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"knative.dev/pkg/apis"
+
+	"github.com/google/knative-gcp/pkg/apis/duck"
+)
+
+const (
+	// IngressEventsPerSecondAnnotationKey is the annotation key for the maximum number of events
+	// per second that the ingress accepts for the Broker. The value is a positive integer.
+	IngressEventsPerSecondAnnotationKey = "events.cloud.google.com/ingressEventsPerSecond"
+	// IngressBytesInFlightAnnotationKey is the annotation key for the maximum number of event bytes
+	// that the ingress publishes concurrently for the Broker. The value is a positive quantity,
+	// e.g. "64Mi".
+	IngressBytesInFlightAnnotationKey = "events.cloud.google.com/ingressBytesInFlight"
+	// IngressFilteringEnabledAnnotationKey is the annotation key for dropping events at the ingress
+	// when no Trigger of the Broker matches them. The value is "true" or "false".
+	IngressFilteringEnabledAnnotationKey = "events.cloud.google.com/ingressFilteringEnabled"
+	// IngressAsyncPublishAnnotationKey is the annotation key for accepting events at the ingress
+	// once they are buffered for publishing, without waiting for Pub/Sub to persist them. Events
+	// may be lost if publishing fails. The value is "true" or "false".
+	IngressAsyncPublishAnnotationKey = "events.cloud.google.com/ingressAsyncPublish"
+	// ReplyLoopDetectionAnnotationKey is the annotation key for dead lettering the events that come
+	// back to a Trigger whose subscriber replied with them, or with the events they descend from.
+	// The lineage of the events is only kept by the ingress of the Broker when it is enabled, so a
+	// producer of the Broker can mark its events as replies of a Trigger. The value is "true" or
+	// "false".
+	ReplyLoopDetectionAnnotationKey = "events.cloud.google.com/replyLoopDetection"
+	// MaxEventAgeAnnotationKey is the annotation key for the maximum age of the events delivered to
+	// the Broker's Triggers. The age of an event counts from its arrival at the Broker, or from
+	// its scheduled delivery time if later. Expired events are sent to the dead letter sink, if
+	// any, or dropped. The value is a positive ISO 8601 duration, e.g. "PT1H". It can also be set
+	// on a Trigger, which then overrides the Broker's.
+	MaxEventAgeAnnotationKey = "events.cloud.google.com/maxEventAge"
+	// RetryTopicModeAnnotationKey is the annotation key for how the retries of the Broker's
+	// Triggers are queued. The value is "perTrigger", the default, for a retry topic per Trigger,
+	// or "consolidated" for a single retry topic per Broker, from which each Trigger's retry
+	// subscription filters the retries of the Trigger. The retries queued before the mode changes
+	// are still delivered.
+	RetryTopicModeAnnotationKey = "events.cloud.google.com/retryTopicMode"
+	// ArchiveAnnotationKey is the annotation key for archiving every event accepted by the Broker
+	// to a Cloud Storage bucket. The value is the name of the bucket, optionally followed by the
+	// prefix of the archive files, e.g. "my-bucket/audit". The events are archived from a
+	// dedicated subscription to the decouple topic of the Broker. The bucket must be listed in the
+	// ArchiveBucketsAnnotationKey annotation of the namespace of the Broker.
+	ArchiveAnnotationKey = "events.cloud.google.com/archive"
+	// ArchiveBucketsAnnotationKey is the Namespace annotation key for the Cloud Storage buckets
+	// that the Brokers in the namespace may archive their events to. The value is a comma
+	// separated list of bucket names.
+	ArchiveBucketsAnnotationKey = "events.cloud.google.com/archiveBuckets"
+	// FederateFromAnnotationKey is the annotation key for delivering the events of remote Brokers,
+	// e.g. in other clusters, to the Broker's Triggers. The value is a comma separated list of the
+	// decouple topics of the remote Brokers, each "projects/<project>/topics/<topic>". The decouple
+	// topic of a Broker is "cre-bkr_<namespace>_<name>_<uid>" in the project of its cluster. The
+	// events are pulled from a subscription to each topic in the project of the Broker. A remote
+	// Broker consents by listing the control plane of the Broker's cluster in its
+	// FederateToAnnotationKey annotation. In the project of the Broker's cluster, only the decouple
+	// topics of the Brokers in the namespace of the Broker are federated.
+	FederateFromAnnotationKey = "events.cloud.google.com/federateFrom"
+	// FederateToAnnotationKey is the annotation key for the IAM members allowed to federate the
+	// events of the Broker, e.g. the service accounts of the control planes of other clusters. The
+	// value is a comma separated list of "serviceAccount:<email>" members, which are granted
+	// roles/pubsub.subscriber on the decouple topic of the Broker to attach subscriptions to it.
+	FederateToAnnotationKey = "events.cloud.google.com/federateTo"
+
+	// RetryTopicModePerTrigger queues the retries of each Trigger in its own retry topic.
+	RetryTopicModePerTrigger = "perTrigger"
+	// RetryTopicModeConsolidated queues the retries of all Triggers in the retry topic of the
+	// Broker.
+	RetryTopicModeConsolidated = "consolidated"
+)
+
+// bucketNameRegexp matches Cloud Storage bucket names.
+var bucketNameRegexp = regexp.MustCompile(`^[a-z0-9][a-z0-9-_.]{1,220}[a-z0-9]$`)
+
+// FederatedTopic is the decouple topic of a remote Broker whose events are delivered to the
+// Triggers of a Broker.
+type FederatedTopic struct {
+	Project string
+	Topic   string
+}
+
+// String returns the full name of the topic.
+func (t FederatedTopic) String() string {
+	return "projects/" + t.Project + "/topics/" + t.Topic
+}
+
+// IngressQuota returns the ingress admission quota set by the Broker's annotations. A zero value
+// means that the corresponding quota is not enforced.
+func (b *Broker) IngressQuota() (eventsPerSecond int64, bytesInFlight int64, err error) {
+	if v, ok := b.GetAnnotations()[IngressEventsPerSecondAnnotationKey]; ok {
+		if eventsPerSecond, err = strconv.ParseInt(v, 10, 64); err != nil || eventsPerSecond <= 0 {
+			return 0, 0, fmt.Errorf("%s must be a positive integer, got %q", IngressEventsPerSecondAnnotationKey, v)
+		}
+	}
+	if v, ok := b.GetAnnotations()[IngressBytesInFlightAnnotationKey]; ok {
+		q, err := resource.ParseQuantity(v)
+		if err != nil || q.Sign() <= 0 {
+			return 0, 0, fmt.Errorf("%s must be a positive quantity, got %q", IngressBytesInFlightAnnotationKey, v)
+		}
+		bytesInFlight = q.Value()
+	}
+	return eventsPerSecond, bytesInFlight, nil
+}
+
+// IngressFilteringEnabled returns whether the ingress drops the events that match no Trigger of
+// the Broker.
+func (b *Broker) IngressFilteringEnabled() (bool, error) {
+	v, ok := b.GetAnnotations()[IngressFilteringEnabledAnnotationKey]
+	if !ok {
+		return false, nil
+	}
+	enabled, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("%s must be a boolean, got %q", IngressFilteringEnabledAnnotationKey, v)
+	}
+	return enabled, nil
+}
+
+// IngressAsyncPublish returns whether the ingress accepts the events sent to the Broker once they
+// are buffered for publishing.
+func (b *Broker) IngressAsyncPublish() (bool, error) {
+	v, ok := b.GetAnnotations()[IngressAsyncPublishAnnotationKey]
+	if !ok {
+		return false, nil
+	}
+	async, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("%s must be a boolean, got %q", IngressAsyncPublishAnnotationKey, v)
+	}
+	return async, nil
+}
+
+// ReplyLoopDetection returns whether the events that come back to a Trigger whose subscriber
+// replied with them are dead lettered.
+func (b *Broker) ReplyLoopDetection() (bool, error) {
+	v, ok := b.GetAnnotations()[ReplyLoopDetectionAnnotationKey]
+	if !ok {
+		return false, nil
+	}
+	enabled, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("%s must be a boolean, got %q", ReplyLoopDetectionAnnotationKey, v)
+	}
+	return enabled, nil
+}
+
+// MaxEventAge returns the maximum age of the events delivered to the Broker's Triggers. A zero
+// value means that the events never expire.
+func (b *Broker) MaxEventAge() (time.Duration, error) {
+	return parseMaxEventAge(b.GetAnnotations())
+}
+
+// RetryTopicMode returns how the retries of the Broker's Triggers are queued. It returns an empty
+// string if the mode is not set, which is equivalent to RetryTopicModePerTrigger.
+func (b *Broker) RetryTopicMode() (string, error) {
+	v, ok := b.GetAnnotations()[RetryTopicModeAnnotationKey]
+	if !ok {
+		return "", nil
+	}
+	if v != RetryTopicModePerTrigger && v != RetryTopicModeConsolidated {
+		return "", fmt.Errorf("%s must be %q or %q, got %q", RetryTopicModeAnnotationKey, RetryTopicModePerTrigger, RetryTopicModeConsolidated, v)
+	}
+	return v, nil
+}
+
+// ConsolidatedRetryTopic returns whether the retries of the Broker's Triggers are queued in the
+// retry topic of the Broker. Invalid annotations are rejected by the webhook and are treated as
+// the default mode.
+func (b *Broker) ConsolidatedRetryTopic() bool {
+	mode, _ := b.RetryTopicMode()
+	return mode == RetryTopicModeConsolidated
+}
+
+// ArchiveBucketAllowed returns whether the annotations of a Namespace allow its Brokers to archive
+// their events to the bucket.
+func ArchiveBucketAllowed(namespaceAnnotations map[string]string, bucket string) bool {
+	for _, b := range strings.Split(namespaceAnnotations[ArchiveBucketsAnnotationKey], ",") {
+		if b = strings.TrimSpace(b); b != "" && b == bucket {
+			return true
+		}
+	}
+	return false
+}
+
+// Archive returns the Cloud Storage bucket and the prefix of the files the events of the Broker
+// are archived to. It returns an empty bucket if the events are not archived.
+func (b *Broker) Archive() (bucket, prefix string, err error) {
+	v, ok := b.GetAnnotations()[ArchiveAnnotationKey]
+	if !ok {
+		return "", "", nil
+	}
+	parts := strings.SplitN(v, "/", 2)
+	if !bucketNameRegexp.MatchString(parts[0]) {
+		return "", "", fmt.Errorf("%s must be a Cloud Storage bucket name optionally followed by a prefix, got %q", ArchiveAnnotationKey, v)
+	}
+	if len(parts) == 2 {
+		prefix = strings.Trim(parts[1], "/")
+	}
+	return parts[0], prefix, nil
+}
+
+// FederatedTopics returns the decouple topics of the remote Brokers whose events are delivered to
+// the Broker's Triggers, without duplicates.
+func (b *Broker) FederatedTopics() ([]FederatedTopic, error) {
+	v, ok := b.GetAnnotations()[FederateFromAnnotationKey]
+	if !ok {
+		return nil, nil
+	}
+	var topics []FederatedTopic
+	seen := make(map[FederatedTopic]bool)
+	for _, name := range strings.Split(v, ",") {
+		t, ok := parseFederatedTopic(strings.TrimSpace(name))
+		if !ok {
+			return nil, fmt.Errorf("%s must be a comma separated list of Pub/Sub topics \"projects/<project>/topics/<topic>\", got %q", FederateFromAnnotationKey, v)
+		}
+		if !seen[t] {
+			seen[t] = true
+			topics = append(topics, t)
+		}
+	}
+	return topics, nil
+}
+
+// FederationMembers returns the IAM members allowed to federate the events of the Broker, without
+// duplicates.
+func (b *Broker) FederationMembers() ([]string, error) {
+	v, ok := b.GetAnnotations()[FederateToAnnotationKey]
+	if !ok {
+		return nil, nil
+	}
+	var members []string
+	seen := make(map[string]bool)
+	for _, m := range strings.Split(v, ",") {
+		m = strings.TrimSpace(m)
+		email := strings.TrimPrefix(m, "serviceAccount:")
+		if email == m || !strings.Contains(email, "@") {
+			return nil, fmt.Errorf("%s must be a comma separated list of IAM members \"serviceAccount:<email>\", got %q", FederateToAnnotationKey, v)
+		}
+		if !seen[m] {
+			seen[m] = true
+			members = append(members, m)
+		}
+	}
+	return members, nil
+}
+
+// parseFederatedTopic parses the full name of a topic, "projects/<project>/topics/<topic>".
+func parseFederatedTopic(name string) (FederatedTopic, bool) {
+	parts := strings.Split(name, "/")
+	if len(parts) != 4 || parts[0] != "projects" || parts[2] != "topics" ||
+		!projectIDRegexp.MatchString(parts[1]) || !topicIDRegexp.MatchString(parts[3]) {
+		return FederatedTopic{}, false
+	}
+	return FederatedTopic{Project: parts[1], Topic: parts[3]}, true
+}
+
+// parseMaxEventAge parses the max event age annotation, if present.
+func parseMaxEventAge(annotations map[string]string) (time.Duration, error) {
+	v, ok := annotations[MaxEventAgeAnnotationKey]
+	if !ok {
+		return 0, nil
+	}
+	p, err := period.Parse(v)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be a positive ISO 8601 duration, got %q", MaxEventAgeAnnotationKey, v)
+	}
+	d, _ := p.Duration()
+	if d <= 0 {
+		return 0, fmt.Errorf("%s must be a positive ISO 8601 duration, got %q", MaxEventAgeAnnotationKey, v)
+	}
+	return d, nil
+}
+
+// validateAnnotations validates the GCP Broker specific annotations.
+func (b *Broker) validateAnnotations() *apis.FieldError {
+	var errs *apis.FieldError
+	if _, _, err := b.IngressQuota(); err != nil {
+		errs = errs.Also(apis.ErrGeneric(err.Error(), "annotations").ViaField("metadata"))
+	}
+	if _, err := b.IngressFilteringEnabled(); err != nil {
+		errs = errs.Also(apis.ErrGeneric(err.Error(), "annotations").ViaField("metadata"))
+	}
+	if _, err := b.IngressAsyncPublish(); err != nil {
+		errs = errs.Also(apis.ErrGeneric(err.Error(), "annotations").ViaField("metadata"))
+	}
+	if _, err := b.ReplyLoopDetection(); err != nil {
+		errs = errs.Also(apis.ErrGeneric(err.Error(), "annotations").ViaField("metadata"))
+	}
+	if _, err := b.MaxEventAge(); err != nil {
+		errs = errs.Also(apis.ErrGeneric(err.Error(), "annotations").ViaField("metadata"))
+	}
+	if _, err := b.RetryTopicMode(); err != nil {
+		errs = errs.Also(apis.ErrGeneric(err.Error(), "annotations").ViaField("metadata"))
+	}
+	if _, _, err := b.Archive(); err != nil {
+		errs = errs.Also(apis.ErrGeneric(err.Error(), "annotations").ViaField("metadata"))
+	}
+	if _, err := b.FederatedTopics(); err != nil {
+		errs = errs.Also(apis.ErrGeneric(err.Error(), "annotations").ViaField("metadata"))
+	}
+	if _, err := b.FederationMembers(); err != nil {
+		errs = errs.Also(apis.ErrGeneric(err.Error(), "annotations").ViaField("metadata"))
+	}
+	errs = duck.ValidateAllowedPersistenceRegionsAnnotation(b.Annotations, errs)
+	return duck.ValidateKMSKeyNameAnnotation(b.Annotations, errs)
+}
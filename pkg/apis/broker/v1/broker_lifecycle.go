@@ -17,6 +17,8 @@ limitations under the License.
 package v1
 
 import (
+	"strings"
+
 	eventingv1 "knative.dev/eventing/pkg/apis/eventing/v1"
 	"knative.dev/pkg/apis"
 )
@@ -37,6 +39,28 @@ const (
 	// BrokerConditionSubscription reports the status of the Broker's PubSub
 	// subscription. This condition is specific to the Google Cloud Broker.
 	BrokerConditionSubscription apis.ConditionType = "SubscriptionReady"
+
+	// TopEventTypesStatusAnnotationKey is the status annotation key listing the most frequently
+	// observed event types of the Broker, comma separated.
+	TopEventTypesStatusAnnotationKey = "events.cloud.google.com/topEventTypes"
+	// FederationSubscriptionsStatusAnnotationKey is the status annotation key listing the
+	// subscriptions to the decouple topics of remote Brokers created for the Broker, comma
+	// separated, so that they are deleted once the Broker no longer federates their topics.
+	FederationSubscriptionsStatusAnnotationKey = "events.cloud.google.com/federationSubscriptions"
+	// FederatedTopicsStatusAnnotationKey is the status annotation key listing the decouple topics
+	// of remote Brokers that the Broker is allowed to federate, comma separated.
+	FederatedTopicsStatusAnnotationKey = "events.cloud.google.com/federatedTopics"
+	// FederationMembersStatusAnnotationKey is the status annotation key listing the IAM members
+	// granted the permission to subscribe to the decouple topic of the Broker, comma separated, so
+	// that it is revoked once they are no longer allowed to federate the events of the Broker.
+	FederationMembersStatusAnnotationKey = "events.cloud.google.com/federationMembers"
+	// ExternalAddressStatusAnnotationKey is the status annotation key of the address of the Broker
+	// at the external ingress of its BrokerCell. The Broker's address stays in-cluster.
+	ExternalAddressStatusAnnotationKey = "events.cloud.google.com/externalAddress"
+	// ArchiveStatusAnnotationKey is the status annotation key of the Cloud Storage bucket and the
+	// prefix of the files that the events of the Broker are archived to, "<bucket>/<prefix>". It is
+	// only set once the namespace of the Broker allows the bucket.
+	ArchiveStatusAnnotationKey = "events.cloud.google.com/archive"
 )
 
 // GetCondition returns the condition currently associated with the given type, or nil.
@@ -105,3 +129,136 @@ func (bs *BrokerStatus) MarkSubscriptionUnknown(reason, format string, args ...i
 func (bs *BrokerStatus) MarkSubscriptionReady(_ string) {
 	brokerCondSet.Manage(bs).MarkTrue(BrokerConditionSubscription)
 }
+
+// SetTopEventTypes lists the most frequently observed event types in the status annotations. The
+// annotation is removed if there are none.
+func (bs *BrokerStatus) SetTopEventTypes(types []string) {
+	if len(types) == 0 {
+		delete(bs.Annotations, TopEventTypesStatusAnnotationKey)
+		return
+	}
+	if bs.Annotations == nil {
+		bs.Annotations = make(map[string]string, 1)
+	}
+	bs.Annotations[TopEventTypesStatusAnnotationKey] = strings.Join(types, ",")
+}
+
+// SetFederationSubscriptions lists the subscriptions to the decouple topics of remote Brokers in
+// the status annotations. The annotation is removed if there are none.
+func (bs *BrokerStatus) SetFederationSubscriptions(subscriptions []string) {
+	if len(subscriptions) == 0 {
+		delete(bs.Annotations, FederationSubscriptionsStatusAnnotationKey)
+		return
+	}
+	if bs.Annotations == nil {
+		bs.Annotations = make(map[string]string, 1)
+	}
+	bs.Annotations[FederationSubscriptionsStatusAnnotationKey] = strings.Join(subscriptions, ",")
+}
+
+// SetFederatedTopics lists the decouple topics of remote Brokers that the Broker is allowed to
+// federate in the status annotations. The annotation is removed if there are none.
+func (bs *BrokerStatus) SetFederatedTopics(topics []FederatedTopic) {
+	if len(topics) == 0 {
+		delete(bs.Annotations, FederatedTopicsStatusAnnotationKey)
+		return
+	}
+	if bs.Annotations == nil {
+		bs.Annotations = make(map[string]string, 1)
+	}
+	names := make([]string, 0, len(topics))
+	for _, t := range topics {
+		names = append(names, t.String())
+	}
+	bs.Annotations[FederatedTopicsStatusAnnotationKey] = strings.Join(names, ",")
+}
+
+// FederatedTopics returns the decouple topics of remote Brokers that the Broker is allowed to
+// federate, as listed in the status annotations.
+func (bs *BrokerStatus) FederatedTopics() []FederatedTopic {
+	v := bs.Annotations[FederatedTopicsStatusAnnotationKey]
+	if v == "" {
+		return nil
+	}
+	var topics []FederatedTopic
+	for _, name := range strings.Split(v, ",") {
+		if t, ok := parseFederatedTopic(name); ok {
+			topics = append(topics, t)
+		}
+	}
+	return topics
+}
+
+// SetFederationMembers lists the IAM members granted the permission to subscribe to the decouple
+// topic of the Broker in the status annotations. The annotation is removed if there are none.
+func (bs *BrokerStatus) SetFederationMembers(members []string) {
+	if len(members) == 0 {
+		delete(bs.Annotations, FederationMembersStatusAnnotationKey)
+		return
+	}
+	if bs.Annotations == nil {
+		bs.Annotations = make(map[string]string, 1)
+	}
+	bs.Annotations[FederationMembersStatusAnnotationKey] = strings.Join(members, ",")
+}
+
+// FederationMembers returns the IAM members granted the permission to subscribe to the decouple
+// topic of the Broker, as listed in the status annotations.
+func (bs *BrokerStatus) FederationMembers() []string {
+	v := bs.Annotations[FederationMembersStatusAnnotationKey]
+	if v == "" {
+		return nil
+	}
+	return strings.Split(v, ",")
+}
+
+// SetExternalAddress sets the address of the Broker at the external ingress in the status
+// annotations. The annotation is removed if the url is nil.
+func (bs *BrokerStatus) SetExternalAddress(url *apis.URL) {
+	if url == nil {
+		delete(bs.Annotations, ExternalAddressStatusAnnotationKey)
+		return
+	}
+	if bs.Annotations == nil {
+		bs.Annotations = make(map[string]string, 1)
+	}
+	bs.Annotations[ExternalAddressStatusAnnotationKey] = url.String()
+}
+
+// SetArchive sets the bucket and the prefix of the files that the events of the Broker are
+// archived to in the status annotations. The annotation is removed if the bucket is empty.
+func (bs *BrokerStatus) SetArchive(bucket, prefix string) {
+	if bucket == "" {
+		delete(bs.Annotations, ArchiveStatusAnnotationKey)
+		return
+	}
+	if bs.Annotations == nil {
+		bs.Annotations = make(map[string]string, 1)
+	}
+	bs.Annotations[ArchiveStatusAnnotationKey] = bucket + "/" + prefix
+}
+
+// Archive returns the bucket and the prefix of the files that the events of the Broker are
+// archived to, as set in the status annotations. It returns an empty bucket if the events are not
+// archived, or if the annotation is malformed.
+func (bs *BrokerStatus) Archive() (bucket, prefix string) {
+	v := bs.Annotations[ArchiveStatusAnnotationKey]
+	if v == "" {
+		return "", ""
+	}
+	parts := strings.SplitN(v, "/", 2)
+	if len(parts) != 2 {
+		return "", ""
+	}
+	return parts[0], parts[1]
+}
+
+// FederationSubscriptions returns the subscriptions to the decouple topics of remote Brokers
+// listed in the status annotations.
+func (bs *BrokerStatus) FederationSubscriptions() []string {
+	v := bs.Annotations[FederationSubscriptionsStatusAnnotationKey]
+	if v == "" {
+		return nil
+	}
+	return strings.Split(v, ",")
+}
@@ -385,3 +385,93 @@ func TestBrokerConditionStatus(t *testing.T) {
 		})
 	}
 }
+
+func TestBrokerSetTopEventTypes(t *testing.T) {
+	bs := &BrokerStatus{}
+	bs.SetTopEventTypes([]string{"type1", "type2"})
+	if got, want := bs.Annotations[TopEventTypesStatusAnnotationKey], "type1,type2"; got != want {
+		t.Errorf("unexpected top event types: want %q, got %q", want, got)
+	}
+	bs.SetTopEventTypes(nil)
+	if _, ok := bs.Annotations[TopEventTypesStatusAnnotationKey]; ok {
+		t.Error("expected top event types annotation to be removed")
+	}
+}
+
+func TestBrokerFederationSubscriptions(t *testing.T) {
+	bs := &BrokerStatus{}
+	if got := bs.FederationSubscriptions(); got != nil {
+		t.Errorf("unexpected federation subscriptions: want none, got %v", got)
+	}
+	bs.SetFederationSubscriptions([]string{"sub1", "sub2"})
+	if diff := cmp.Diff([]string{"sub1", "sub2"}, bs.FederationSubscriptions()); diff != "" {
+		t.Errorf("unexpected federation subscriptions (-want, +got) = %v", diff)
+	}
+	bs.SetFederationSubscriptions(nil)
+	if _, ok := bs.Annotations[FederationSubscriptionsStatusAnnotationKey]; ok {
+		t.Error("expected federation subscriptions annotation to be removed")
+	}
+}
+
+func TestBrokerSetExternalAddress(t *testing.T) {
+	bs := &BrokerStatus{}
+	bs.SetExternalAddress(apis.HTTPS("broker.example.com"))
+	if got, want := bs.Annotations[ExternalAddressStatusAnnotationKey], "https://broker.example.com"; got != want {
+		t.Errorf("unexpected external address: want %q, got %q", want, got)
+	}
+	bs.SetExternalAddress(nil)
+	if _, ok := bs.Annotations[ExternalAddressStatusAnnotationKey]; ok {
+		t.Error("expected external address annotation to be removed")
+	}
+}
+
+func TestBrokerArchive(t *testing.T) {
+	bs := &BrokerStatus{}
+	if bucket, _ := bs.Archive(); bucket != "" {
+		t.Errorf("unexpected archive bucket: want none, got %q", bucket)
+	}
+	bs.SetArchive("audit-bucket", "events")
+	if bucket, prefix := bs.Archive(); bucket != "audit-bucket" || prefix != "events" {
+		t.Errorf("unexpected archive: want %q and %q, got %q and %q", "audit-bucket", "events", bucket, prefix)
+	}
+	bs.SetArchive("", "")
+	if _, ok := bs.Annotations[ArchiveStatusAnnotationKey]; ok {
+		t.Error("expected archive annotation to be removed")
+	}
+	bs.Annotations[ArchiveStatusAnnotationKey] = "audit-bucket"
+	if bucket, prefix := bs.Archive(); bucket != "" || prefix != "" {
+		t.Errorf("unexpected archive of a malformed annotation: want none, got %q and %q", bucket, prefix)
+	}
+}
+
+func TestBrokerFederatedTopics(t *testing.T) {
+	bs := &BrokerStatus{}
+	if got := bs.FederatedTopics(); got != nil {
+		t.Errorf("unexpected federated topics: want none, got %v", got)
+	}
+	topics := []FederatedTopic{{Project: "other-project", Topic: "cre-bkr_ns_broker_abc"}, {Project: "third-project", Topic: "events"}}
+	bs.SetFederatedTopics(topics)
+	if diff := cmp.Diff(topics, bs.FederatedTopics()); diff != "" {
+		t.Errorf("unexpected federated topics (-want, +got) = %v", diff)
+	}
+	bs.SetFederatedTopics(nil)
+	if _, ok := bs.Annotations[FederatedTopicsStatusAnnotationKey]; ok {
+		t.Error("expected federated topics annotation to be removed")
+	}
+}
+
+func TestBrokerFederationMembers(t *testing.T) {
+	bs := &BrokerStatus{}
+	if got := bs.FederationMembers(); got != nil {
+		t.Errorf("unexpected federation members: want none, got %v", got)
+	}
+	members := []string{"serviceAccount:a@p.iam.gserviceaccount.com", "serviceAccount:b@p.iam.gserviceaccount.com"}
+	bs.SetFederationMembers(members)
+	if diff := cmp.Diff(members, bs.FederationMembers()); diff != "" {
+		t.Errorf("unexpected federation members (-want, +got) = %v", diff)
+	}
+	bs.SetFederationMembers(nil)
+	if _, ok := bs.Annotations[FederationMembersStatusAnnotationKey]; ok {
+		t.Error("expected federation members annotation to be removed")
+	}
+}
@@ -22,10 +22,13 @@ import (
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	eventingduckv1 "knative.dev/eventing/pkg/apis/duck/v1"
 	eventingv1 "knative.dev/eventing/pkg/apis/eventing/v1"
 	"knative.dev/pkg/apis"
 	duckv1 "knative.dev/pkg/apis/duck/v1"
+
+	"github.com/google/knative-gcp/pkg/apis/duck"
 )
 
 func TestBroker_Validate(t *testing.T) {
@@ -136,6 +139,236 @@ func TestBroker_Validate(t *testing.T) {
 			},
 		},
 		want: apis.ErrInvalidValue("Dead letter topic maximum length is 255 characters", "spec.delivery.deadLetterSink.uri"),
+	}, {
+		name: "valid ingress quota",
+		broker: Broker{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					IngressEventsPerSecondAnnotationKey: "100",
+					IngressBytesInFlightAnnotationKey:   "64Mi",
+				},
+			},
+		},
+	}, {
+		name: "invalid ingress events per second",
+		broker: Broker{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					IngressEventsPerSecondAnnotationKey: "0",
+				},
+			},
+		},
+		want: apis.ErrGeneric(`events.cloud.google.com/ingressEventsPerSecond must be a positive integer, got "0"`, "metadata.annotations"),
+	}, {
+		name: "invalid ingress bytes in flight",
+		broker: Broker{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					IngressBytesInFlightAnnotationKey: "lots",
+				},
+			},
+		},
+		want: apis.ErrGeneric(`events.cloud.google.com/ingressBytesInFlight must be a positive quantity, got "lots"`, "metadata.annotations"),
+	}, {
+		name: "valid ingress filtering",
+		broker: Broker{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					IngressFilteringEnabledAnnotationKey: "true",
+				},
+			},
+		},
+	}, {
+		name: "invalid ingress filtering",
+		broker: Broker{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					IngressFilteringEnabledAnnotationKey: "sometimes",
+				},
+			},
+		},
+		want: apis.ErrGeneric(`events.cloud.google.com/ingressFilteringEnabled must be a boolean, got "sometimes"`, "metadata.annotations"),
+	}, {
+		name: "valid ingress async publish",
+		broker: Broker{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					IngressAsyncPublishAnnotationKey: "true",
+				},
+			},
+		},
+	}, {
+		name: "invalid ingress async publish",
+		broker: Broker{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					IngressAsyncPublishAnnotationKey: "yes please",
+				},
+			},
+		},
+		want: apis.ErrGeneric(`events.cloud.google.com/ingressAsyncPublish must be a boolean, got "yes please"`, "metadata.annotations"),
+	}, {
+		name: "valid reply loop detection",
+		broker: Broker{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					ReplyLoopDetectionAnnotationKey: "true",
+				},
+			},
+		},
+	}, {
+		name: "invalid reply loop detection",
+		broker: Broker{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					ReplyLoopDetectionAnnotationKey: "on",
+				},
+			},
+		},
+		want: apis.ErrGeneric(`events.cloud.google.com/replyLoopDetection must be a boolean, got "on"`, "metadata.annotations"),
+	}, {
+		name: "valid max event age",
+		broker: Broker{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					MaxEventAgeAnnotationKey: "PT1H",
+				},
+			},
+		},
+	}, {
+		name: "invalid max event age",
+		broker: Broker{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					MaxEventAgeAnnotationKey: "1h",
+				},
+			},
+		},
+		want: apis.ErrGeneric(`events.cloud.google.com/maxEventAge must be a positive ISO 8601 duration, got "1h"`, "metadata.annotations"),
+	}, {
+		name: "zero max event age",
+		broker: Broker{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					MaxEventAgeAnnotationKey: "PT0S",
+				},
+			},
+		},
+		want: apis.ErrGeneric(`events.cloud.google.com/maxEventAge must be a positive ISO 8601 duration, got "PT0S"`, "metadata.annotations"),
+	}, {
+		name: "valid retry topic mode",
+		broker: Broker{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					RetryTopicModeAnnotationKey: "consolidated",
+				},
+			},
+		},
+	}, {
+		name: "invalid retry topic mode",
+		broker: Broker{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					RetryTopicModeAnnotationKey: "shared",
+				},
+			},
+		},
+		want: apis.ErrGeneric(`events.cloud.google.com/retryTopicMode must be "perTrigger" or "consolidated", got "shared"`, "metadata.annotations"),
+	}, {
+		name: "valid archive",
+		broker: Broker{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					ArchiveAnnotationKey: "audit-bucket/events/prod",
+				},
+			},
+		},
+	}, {
+		name: "invalid archive bucket",
+		broker: Broker{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					ArchiveAnnotationKey: "gs://audit-bucket",
+				},
+			},
+		},
+		want: apis.ErrGeneric(`events.cloud.google.com/archive must be a Cloud Storage bucket name optionally followed by a prefix, got "gs://audit-bucket"`, "metadata.annotations"),
+	}, {
+		name: "valid federated topics",
+		broker: Broker{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					FederateFromAnnotationKey: "projects/other-project/topics/cre-bkr_ns_broker_abc, projects/third-project/topics/events",
+				},
+			},
+		},
+	}, {
+		name: "invalid federated topic",
+		broker: Broker{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					FederateFromAnnotationKey: "projects/other-project/topics/events,cre-bkr_ns_broker_abc",
+				},
+			},
+		},
+		want: apis.ErrGeneric(`events.cloud.google.com/federateFrom must be a comma separated list of Pub/Sub topics "projects/<project>/topics/<topic>", got "projects/other-project/topics/events,cre-bkr_ns_broker_abc"`, "metadata.annotations"),
+	}, {
+		name: "valid federation members",
+		broker: Broker{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					FederateToAnnotationKey: "serviceAccount:controller@other-project.iam.gserviceaccount.com, serviceAccount:controller@third-project.iam.gserviceaccount.com",
+				},
+			},
+		},
+	}, {
+		name: "invalid federation member",
+		broker: Broker{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					FederateToAnnotationKey: "user:someone@example.com",
+				},
+			},
+		},
+		want: apis.ErrGeneric(`events.cloud.google.com/federateTo must be a comma separated list of IAM members "serviceAccount:<email>", got "user:someone@example.com"`, "metadata.annotations"),
+	}, {
+		name: "valid allowed persistence regions",
+		broker: Broker{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					duck.AllowedPersistenceRegionsAnnotation: "europe-west1,europe-west4",
+				},
+			},
+		},
+	}, {
+		name: "invalid allowed persistence regions",
+		broker: Broker{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					duck.AllowedPersistenceRegionsAnnotation: "europe-west1-b",
+				},
+			},
+		},
+		want: apis.ErrInvalidValue("europe-west1-b", "metadata.annotations[events.cloud.google.com/allowedPersistenceRegions]"),
+	}, {
+		name: "valid kms key name",
+		broker: Broker{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					duck.KMSKeyNameAnnotation: "projects/my-project/locations/europe-west1/keyRings/my-ring/cryptoKeys/my-key",
+				},
+			},
+		},
+	}, {
+		name: "invalid kms key name",
+		broker: Broker{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					duck.KMSKeyNameAnnotation: "my-key",
+				},
+			},
+		},
+		want: apis.ErrInvalidValue("my-key", "metadata.annotations[events.cloud.google.com/kmsKeyName]"),
 	}, {
 		name: "valid dead letter topic",
 		broker: Broker{
@@ -163,3 +396,16 @@ func TestBroker_Validate(t *testing.T) {
 		})
 	}
 }
+
+func TestArchiveBucketAllowed(t *testing.T) {
+	annotations := map[string]string{ArchiveBucketsAnnotationKey: "audit-bucket, other-bucket"}
+	if !ArchiveBucketAllowed(annotations, "other-bucket") {
+		t.Error("listed bucket is not allowed")
+	}
+	if ArchiveBucketAllowed(annotations, "unlisted-bucket") {
+		t.Error("unlisted bucket is allowed")
+	}
+	if ArchiveBucketAllowed(nil, "") {
+		t.Error("empty bucket is allowed without annotation")
+	}
+}
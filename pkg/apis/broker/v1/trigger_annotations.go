@@ -0,0 +1,274 @@
+/*
+Copyright 2021 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"knative.dev/pkg/apis"
+)
+
+const (
+	// SuccessResponseCodesAnnotationKey is the annotation key for the non-2xx HTTP status codes of
+	// the subscriber's responses that are treated as successful deliveries. The value is a
+	// comma-separated list of status codes, e.g. "409,410".
+	SuccessResponseCodesAnnotationKey = "events.cloud.google.com/successResponseCodes"
+	// NonRetryableResponseCodesAnnotationKey is the annotation key for the HTTP status codes of the
+	// subscriber's responses that are not retried. Their events are sent to the dead letter sink,
+	// if any, or dropped. The value is a comma-separated list of status codes, e.g. "400,413,415".
+	NonRetryableResponseCodesAnnotationKey = "events.cloud.google.com/nonRetryableResponseCodes"
+	// DeliveryFormatAnnotationKey is the annotation key for the encoding of the events delivered to
+	// the subscriber. The value is "binary" or "structured".
+	DeliveryFormatAnnotationKey = "events.cloud.google.com/deliveryFormat"
+	// DeliveryHeadersAnnotationKey is the annotation key for static HTTP headers added to the
+	// requests to the subscriber. The value is a JSON object of header names to values, e.g.
+	// '{"X-Tenant-Id": "tenant-1"}'.
+	DeliveryHeadersAnnotationKey = "events.cloud.google.com/deliveryHeaders"
+	// DeliveryHeaderSecretsAnnotationKey is the annotation key for HTTP headers added to the
+	// requests to the subscriber whose values are read from Secrets in the Trigger's namespace. The
+	// value is a JSON object of header names to "<secret name>/<key>", e.g.
+	// '{"X-Api-Key": "subscriber-credentials/api-key"}'. Only Secrets labeled with
+	// DeliveryHeaderSecretLabelKey are read.
+	DeliveryHeaderSecretsAnnotationKey = "events.cloud.google.com/deliveryHeaderSecrets"
+	// DeliveryHeaderSecretLabelKey is the label key that opts a Secret into being read for the
+	// delivery headers of the Triggers in its namespace. The value must be "true".
+	DeliveryHeaderSecretLabelKey = "events.cloud.google.com/delivery-header-secret"
+	// PubsubOrderingKeyAnnotationKey is the annotation key for the CloudEvent attribute whose value
+	// is the ordering key of the messages published to a Pub/Sub topic subscriber, e.g. "subject".
+	PubsubOrderingKeyAnnotationKey = "events.cloud.google.com/pubsubOrderingKey"
+	// PubsubAttributesAnnotationKey is the annotation key for the message attributes set from
+	// CloudEvent attributes on the messages published to a Pub/Sub topic subscriber. The value is a
+	// JSON object of CloudEvent attribute names to message attribute names, e.g.
+	// '{"type": "eventType"}'.
+	PubsubAttributesAnnotationKey = "events.cloud.google.com/pubsubAttributes"
+	// BigQueryIgnoreUnknownValuesAnnotationKey is the annotation key for ignoring the values of the
+	// rows streamed into a BigQuery table subscriber that don't match a column of the table, such as
+	// the CloudEvent extensions the table has no column for. The value is a boolean. By default the
+	// rows with such values are not inserted, and their events are retried.
+	BigQueryIgnoreUnknownValuesAnnotationKey = "events.cloud.google.com/bigqueryIgnoreUnknownValues"
+	// PubsubSubscribersLabelKey is the label key that opts a Namespace into Triggers with Pub/Sub
+	// topic subscribers. The value must be "true". The owner of the topic grants
+	// roles/pubsub.publisher to the Google service account of the data plane.
+	PubsubSubscribersLabelKey = "events.cloud.google.com/pubsub-subscribers"
+	// BigQuerySubscribersLabelKey is the label key that opts a Namespace into Triggers with
+	// BigQuery table subscribers. The value must be "true". The owner of the table grants
+	// roles/bigquery.dataEditor to the Google service account of the data plane.
+	BigQuerySubscribersLabelKey = "events.cloud.google.com/bigquery-subscribers"
+
+	// DeliveryFormatBinary delivers events in the binary content mode.
+	DeliveryFormatBinary = "binary"
+	// DeliveryFormatStructured delivers events in the structured content mode, as JSON.
+	DeliveryFormatStructured = "structured"
+)
+
+// headerNameRegexp matches the names of the headers that can be added to the requests to the
+// subscriber. It is stricter than RFC 7230, so that header names can be used in Secret keys.
+var headerNameRegexp = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9-]*$`)
+
+// ceAttributeNameRegexp matches the names of CloudEvent attributes.
+var ceAttributeNameRegexp = regexp.MustCompile(`^[a-z0-9]{1,20}$`)
+
+// ResponseClassification returns the status codes of the subscriber's responses that the
+// Trigger's annotations classify as successful and as non-retryable. Responses with other status
+// codes succeed if they are 2xx, and are retried otherwise.
+func (t *Trigger) ResponseClassification() (success []int32, nonRetryable []int32, err error) {
+	if success, err = parseStatusCodes(t.GetAnnotations(), SuccessResponseCodesAnnotationKey); err != nil {
+		return nil, nil, err
+	}
+	if nonRetryable, err = parseStatusCodes(t.GetAnnotations(), NonRetryableResponseCodesAnnotationKey); err != nil {
+		return nil, nil, err
+	}
+	for _, s := range success {
+		for _, n := range nonRetryable {
+			if s == n {
+				return nil, nil, fmt.Errorf("%s and %s must not both contain status code %d", SuccessResponseCodesAnnotationKey, NonRetryableResponseCodesAnnotationKey, s)
+			}
+		}
+	}
+	return success, nonRetryable, nil
+}
+
+// parseStatusCodes parses the comma-separated HTTP status codes of the annotation, if present.
+func parseStatusCodes(annotations map[string]string, key string) ([]int32, error) {
+	v, ok := annotations[key]
+	if !ok {
+		return nil, nil
+	}
+	var codes []int32
+	for _, s := range strings.Split(v, ",") {
+		code, err := strconv.ParseInt(strings.TrimSpace(s), 10, 32)
+		if err != nil || code < 100 || code > 599 {
+			return nil, fmt.Errorf("%s must be a comma-separated list of HTTP status codes, got %q", key, v)
+		}
+		codes = append(codes, int32(code))
+	}
+	return codes, nil
+}
+
+// DeliveryFormat returns the encoding of the events delivered to the subscriber, if set.
+func (t *Trigger) DeliveryFormat() (string, error) {
+	v, ok := t.GetAnnotations()[DeliveryFormatAnnotationKey]
+	if !ok {
+		return "", nil
+	}
+	if v != DeliveryFormatBinary && v != DeliveryFormatStructured {
+		return "", fmt.Errorf("%s must be %q or %q, got %q", DeliveryFormatAnnotationKey, DeliveryFormatBinary, DeliveryFormatStructured, v)
+	}
+	return v, nil
+}
+
+// DeliveryHeaders returns the static headers added to the requests to the subscriber, and the
+// headers whose values are read from Secrets in the Trigger's namespace.
+func (t *Trigger) DeliveryHeaders() (static map[string]string, secrets map[string]corev1.SecretKeySelector, err error) {
+	if static, err = parseHeaders(t.GetAnnotations(), DeliveryHeadersAnnotationKey); err != nil {
+		return nil, nil, err
+	}
+	refs, err := parseHeaders(t.GetAnnotations(), DeliveryHeaderSecretsAnnotationKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	for name, ref := range refs {
+		if _, ok := static[name]; ok {
+			return nil, nil, fmt.Errorf("%s and %s must not both set header %q", DeliveryHeadersAnnotationKey, DeliveryHeaderSecretsAnnotationKey, name)
+		}
+		parts := strings.Split(ref, "/")
+		if len(parts) != 2 || len(validation.IsDNS1123Subdomain(parts[0])) != 0 || len(validation.IsConfigMapKey(parts[1])) != 0 {
+			return nil, nil, fmt.Errorf("%s must reference Secret keys as \"<secret name>/<key>\", got %q for header %q", DeliveryHeaderSecretsAnnotationKey, ref, name)
+		}
+		if secrets == nil {
+			secrets = make(map[string]corev1.SecretKeySelector, len(refs))
+		}
+		secrets[name] = corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: parts[0]},
+			Key:                  parts[1],
+		}
+	}
+	return static, secrets, nil
+}
+
+// parseHeaders parses the JSON object of header names to values of the annotation, if present.
+func parseHeaders(annotations map[string]string, key string) (map[string]string, error) {
+	v, ok := annotations[key]
+	if !ok {
+		return nil, nil
+	}
+	var headers map[string]string
+	if err := json.Unmarshal([]byte(v), &headers); err != nil {
+		return nil, fmt.Errorf("%s must be a JSON object of header names to strings: %v", key, err)
+	}
+	for name := range headers {
+		if !headerNameRegexp.MatchString(name) {
+			return nil, fmt.Errorf("%s has invalid header name %q", key, name)
+		}
+		if canonical := http.CanonicalHeaderKey(name); canonical == "Content-Type" || canonical == "Content-Length" || canonical == "Host" || strings.HasPrefix(canonical, "Ce-") {
+			return nil, fmt.Errorf("%s must not set reserved header %q", key, name)
+		}
+	}
+	return headers, nil
+}
+
+// PubsubDelivery returns the CloudEvent attribute whose value is the ordering key of the messages
+// published to a Pub/Sub topic subscriber, and the message attributes set from CloudEvent
+// attributes, keyed by the CloudEvent attribute names.
+func (t *Trigger) PubsubDelivery() (orderingKey string, attributes map[string]string, err error) {
+	annotations := t.GetAnnotations()
+	if v, ok := annotations[PubsubOrderingKeyAnnotationKey]; ok {
+		if !ceAttributeNameRegexp.MatchString(v) {
+			return "", nil, fmt.Errorf("%s must be a CloudEvent attribute name, got %q", PubsubOrderingKeyAnnotationKey, v)
+		}
+		orderingKey = v
+	}
+	v, ok := annotations[PubsubAttributesAnnotationKey]
+	if !ok {
+		return orderingKey, nil, nil
+	}
+	if err := json.Unmarshal([]byte(v), &attributes); err != nil {
+		return "", nil, fmt.Errorf("%s must be a JSON object of CloudEvent attribute names to message attribute names: %v", PubsubAttributesAnnotationKey, err)
+	}
+	for ce, name := range attributes {
+		if !ceAttributeNameRegexp.MatchString(ce) {
+			return "", nil, fmt.Errorf("%s has invalid CloudEvent attribute name %q", PubsubAttributesAnnotationKey, ce)
+		}
+		// The CloudEvents attributes of the messages are prefixed with "ce-", and Pub/Sub reserves
+		// the "goog" prefix.
+		lower := strings.ToLower(name)
+		if name == "" || len(name) > 256 || strings.HasPrefix(lower, "ce-") || strings.HasPrefix(lower, "goog") || lower == "content-type" {
+			return "", nil, fmt.Errorf("%s has invalid or reserved message attribute name %q", PubsubAttributesAnnotationKey, name)
+		}
+	}
+	return orderingKey, attributes, nil
+}
+
+// BigQueryIgnoreUnknownValues returns whether the values of the rows streamed into a BigQuery
+// table subscriber that don't match a column of the table are ignored.
+func (t *Trigger) BigQueryIgnoreUnknownValues() (bool, error) {
+	v, ok := t.GetAnnotations()[BigQueryIgnoreUnknownValuesAnnotationKey]
+	if !ok {
+		return false, nil
+	}
+	ignore, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("%s must be a boolean, got %q", BigQueryIgnoreUnknownValuesAnnotationKey, v)
+	}
+	return ignore, nil
+}
+
+// MaxEventAge returns the maximum age of the events delivered to the Trigger's subscriber, set by
+// the MaxEventAgeAnnotationKey annotation. A zero value means that the Broker's applies.
+func (t *Trigger) MaxEventAge() (time.Duration, error) {
+	return parseMaxEventAge(t.GetAnnotations())
+}
+
+// validateAnnotations validates the GCP Trigger specific annotations.
+func (t *Trigger) validateAnnotations() *apis.FieldError {
+	var errs *apis.FieldError
+	if _, _, err := t.ResponseClassification(); err != nil {
+		errs = errs.Also(apis.ErrGeneric(err.Error(), "annotations").ViaField("metadata"))
+	}
+	if _, err := t.MaxEventAge(); err != nil {
+		errs = errs.Also(apis.ErrGeneric(err.Error(), "annotations").ViaField("metadata"))
+	}
+	if _, err := t.DeliveryFormat(); err != nil {
+		errs = errs.Also(apis.ErrGeneric(err.Error(), "annotations").ViaField("metadata"))
+	}
+	if _, _, err := t.DeliveryHeaders(); err != nil {
+		errs = errs.Also(apis.ErrGeneric(err.Error(), "annotations").ViaField("metadata"))
+	}
+	if orderingKey, attributes, err := t.PubsubDelivery(); err != nil {
+		errs = errs.Also(apis.ErrGeneric(err.Error(), "annotations").ViaField("metadata"))
+	} else if orderingKey != "" || len(attributes) > 0 {
+		if _, _, ok := PubsubSubscriberTopic(t.Spec.Subscriber.URI); !ok || t.Spec.Subscriber.Ref != nil {
+			errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("%s and %s require a Pub/Sub topic subscriber", PubsubOrderingKeyAnnotationKey, PubsubAttributesAnnotationKey), "annotations").ViaField("metadata"))
+		}
+	}
+	if _, ok := t.GetAnnotations()[BigQueryIgnoreUnknownValuesAnnotationKey]; ok {
+		if _, err := t.BigQueryIgnoreUnknownValues(); err != nil {
+			errs = errs.Also(apis.ErrGeneric(err.Error(), "annotations").ViaField("metadata"))
+		} else if _, _, _, ok := BigQuerySubscriberTable(t.Spec.Subscriber.URI); !ok || t.Spec.Subscriber.Ref != nil {
+			errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("%s requires a BigQuery table subscriber", BigQueryIgnoreUnknownValuesAnnotationKey), "annotations").ViaField("metadata"))
+		}
+	}
+	return errs
+}
@@ -17,6 +17,8 @@ limitations under the License.
 package v1
 
 import (
+	"time"
+
 	corev1 "k8s.io/api/core/v1"
 	eventingv1 "knative.dev/eventing/pkg/apis/eventing/v1"
 	"knative.dev/pkg/apis"
@@ -36,6 +38,16 @@ const (
 	TriggerConditionSubscription apis.ConditionType = "SubscriptionReady"
 )
 
+const (
+	// DrainingRetrySubscriptionStatusAnnotationKey is the status annotation with the retry
+	// subscription the Trigger no longer queues retries in, whose remaining retries are still
+	// delivered.
+	DrainingRetrySubscriptionStatusAnnotationKey = "events.cloud.google.com/drainingRetrySubscription"
+	// DrainingRetrySubscriptionDeadlineStatusAnnotationKey is the status annotation with the time,
+	// as an RFC 3339 timestamp, after which the draining retry subscription is deleted.
+	DrainingRetrySubscriptionDeadlineStatusAnnotationKey = "events.cloud.google.com/drainingRetrySubscriptionDeadline"
+)
+
 // GetCondition returns the condition currently associated with the given type, or nil.
 func (ts *TriggerStatus) GetCondition(t apis.ConditionType) *apis.Condition {
 	return triggerCondSet.Manage(ts).GetCondition(t)
@@ -159,3 +171,32 @@ func (ts *TriggerStatus) PropagateDependencyStatus(src *duckv1.Source) {
 		ts.MarkDependencyUnknown("DependencyUnknown", "The status of Dependency is invalid: %v", sc.Status)
 	}
 }
+
+// MarkRetrySubscriptionDraining records in the status annotations that the retries remaining in
+// the subscription are delivered until the deadline.
+func (ts *TriggerStatus) MarkRetrySubscriptionDraining(subscription string, deadline time.Time) {
+	if ts.Annotations == nil {
+		ts.Annotations = make(map[string]string, 2)
+	}
+	ts.Annotations[DrainingRetrySubscriptionStatusAnnotationKey] = subscription
+	ts.Annotations[DrainingRetrySubscriptionDeadlineStatusAnnotationKey] = deadline.UTC().Format(time.RFC3339)
+}
+
+// MarkRetrySubscriptionDrained removes the draining retry subscription from the status
+// annotations.
+func (ts *TriggerStatus) MarkRetrySubscriptionDrained() {
+	delete(ts.Annotations, DrainingRetrySubscriptionStatusAnnotationKey)
+	delete(ts.Annotations, DrainingRetrySubscriptionDeadlineStatusAnnotationKey)
+}
+
+// DrainingRetrySubscription returns the retry subscription whose remaining retries are still
+// delivered, and the time after which it is deleted, if there is one.
+func (ts *TriggerStatus) DrainingRetrySubscription() (string, time.Time, bool) {
+	subscription, ok := ts.Annotations[DrainingRetrySubscriptionStatusAnnotationKey]
+	if !ok {
+		return "", time.Time{}, false
+	}
+	// A malformed deadline is treated as passed.
+	deadline, _ := time.Parse(time.RFC3339, ts.Annotations[DrainingRetrySubscriptionDeadlineStatusAnnotationKey])
+	return subscription, deadline, true
+}
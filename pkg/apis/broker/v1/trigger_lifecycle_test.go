@@ -18,6 +18,7 @@ package v1
 
 import (
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
@@ -428,3 +429,20 @@ func TestTriggerConditionStatus(t *testing.T) {
 		})
 	}
 }
+
+func TestTriggerDrainingRetrySubscription(t *testing.T) {
+	ts := &TriggerStatus{}
+	if _, _, ok := ts.DrainingRetrySubscription(); ok {
+		t.Error("unexpected draining retry subscription")
+	}
+	deadline := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	ts.MarkRetrySubscriptionDraining("sub", deadline)
+	sub, gotDeadline, ok := ts.DrainingRetrySubscription()
+	if !ok || sub != "sub" || !gotDeadline.Equal(deadline) {
+		t.Errorf("unexpected draining retry subscription: want (%q, %v), got (%q, %v, %v)", "sub", deadline, sub, gotDeadline, ok)
+	}
+	ts.MarkRetrySubscriptionDrained()
+	if len(ts.Annotations) != 0 {
+		t.Errorf("expected draining retry subscription annotations to be removed, got %v", ts.Annotations)
+	}
+}
@@ -18,13 +18,118 @@ package v1
 
 import (
 	"context"
+	"fmt"
+	"regexp"
+	"strings"
 
 	"knative.dev/pkg/apis"
 )
 
+// PubsubSubscriberScheme is the URI scheme of the Trigger subscribers that are Pub/Sub topics,
+// "pubsub://<project>/<topic>". The events are published to the topic instead of being sent over
+// HTTP.
+const PubsubSubscriberScheme = "pubsub"
+
+// BigQuerySubscriberScheme is the URI scheme of the Trigger subscribers that are BigQuery tables,
+// "bigquery://<project>/<dataset>/<table>". The events are streamed into the table as rows instead
+// of being sent over HTTP.
+const BigQuerySubscriberScheme = "bigquery"
+
+// controllerTopicPrefix is the prefix of the Pub/Sub topics managed by the controller, such as
+// the decouple topics of the Brokers and the retry topics of the Triggers. Publishing to them
+// would bypass the ingress of the Brokers.
+const controllerTopicPrefix = "cre-"
+
+var (
+	// projectIDRegexp matches Google Cloud project IDs, including the domain-scoped ones.
+	projectIDRegexp = regexp.MustCompile(`^([a-z][a-z0-9-.]*[a-z0-9]:)?[a-z][a-z0-9-]{4,28}[a-z0-9]$`)
+	// topicIDRegexp matches Pub/Sub topic IDs.
+	topicIDRegexp = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9-_.~+%]{2,254}$`)
+	// bigQueryIDRegexp matches BigQuery dataset and table IDs, which are at most 1024 characters
+	// long.
+	bigQueryIDRegexp = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+)
+
 // Validate the Trigger.
 func (t *Trigger) Validate(ctx context.Context) *apis.FieldError {
-	// The Google Cloud Broker doesn't have any custom validations. The
-	// eventing webhook will run the usual validations.
-	return nil
+	// The Google Cloud Broker only validates its own annotations and Pub/Sub topic
+	// and BigQuery table subscribers. The eventing webhook will run the usual validations.
+	return t.validateAnnotations().Also(t.validateSubscriber())
+}
+
+// PubsubSubscriberTopic returns the project and the ID of the Pub/Sub topic of the subscriber URI,
+// if it is a Pub/Sub topic subscriber.
+func PubsubSubscriberTopic(u *apis.URL) (project, topic string, ok bool) {
+	if u == nil || u.Scheme != PubsubSubscriberScheme {
+		return "", "", false
+	}
+	return u.Host, strings.TrimPrefix(u.Path, "/"), true
+}
+
+// BigQuerySubscriberTable returns the project, the dataset and the ID of the BigQuery table of
+// the subscriber URI, if it is a BigQuery table subscriber.
+func BigQuerySubscriberTable(u *apis.URL) (project, dataset, table string, ok bool) {
+	if u == nil || u.Scheme != BigQuerySubscriberScheme {
+		return "", "", "", false
+	}
+	parts := strings.SplitN(strings.TrimPrefix(u.Path, "/"), "/", 2)
+	if len(parts) != 2 {
+		return u.Host, parts[0], "", true
+	}
+	return u.Host, parts[0], parts[1], true
+}
+
+// validateSubscriber validates the subscriber URI if it is a Pub/Sub topic or a BigQuery table.
+func (t *Trigger) validateSubscriber() *apis.FieldError {
+	if _, _, _, ok := BigQuerySubscriberTable(t.Spec.Subscriber.URI); ok {
+		return t.validateBigQuerySubscriber()
+	}
+	project, topic, ok := PubsubSubscriberTopic(t.Spec.Subscriber.URI)
+	if !ok {
+		return nil
+	}
+	if t.Spec.Subscriber.Ref != nil {
+		return apis.ErrGeneric("Pub/Sub topic subscriber URI must not be relative to a ref", "ref", "uri").ViaField("spec", "subscriber")
+	}
+	var errs *apis.FieldError
+	if !projectIDRegexp.MatchString(project) {
+		errs = errs.Also(apis.ErrInvalidValue("Pub/Sub topic subscriber URI must be pubsub://<project>/<topic> with a valid project ID", "uri"))
+	}
+	if !topicIDRegexp.MatchString(topic) || strings.HasPrefix(topic, "goog") {
+		errs = errs.Also(apis.ErrInvalidValue("Pub/Sub topic subscriber URI must be pubsub://<project>/<topic> with a valid topic ID", "uri"))
+	} else if strings.HasPrefix(topic, controllerTopicPrefix) {
+		errs = errs.Also(apis.ErrInvalidValue(fmt.Sprintf("Pub/Sub topic subscriber URI must not be a topic managed by the controller, with prefix %q", controllerTopicPrefix), "uri"))
+	}
+	u := t.Spec.Subscriber.URI
+	if u.User != nil || u.RawQuery != "" || u.Fragment != "" {
+		errs = errs.Also(apis.ErrInvalidValue("Pub/Sub topic subscriber URI must not have user info, a query or a fragment", "uri"))
+	}
+	return errs.ViaField("spec", "subscriber")
+}
+
+// validateBigQuerySubscriber validates the subscriber URI of a BigQuery table.
+func (t *Trigger) validateBigQuerySubscriber() *apis.FieldError {
+	if t.Spec.Subscriber.Ref != nil {
+		return apis.ErrGeneric("BigQuery table subscriber URI must not be relative to a ref", "ref", "uri").ViaField("spec", "subscriber")
+	}
+	project, dataset, table, _ := BigQuerySubscriberTable(t.Spec.Subscriber.URI)
+	var errs *apis.FieldError
+	if !projectIDRegexp.MatchString(project) {
+		errs = errs.Also(apis.ErrInvalidValue("BigQuery table subscriber URI must be bigquery://<project>/<dataset>/<table> with a valid project ID", "uri"))
+	}
+	if !validBigQueryID(dataset) {
+		errs = errs.Also(apis.ErrInvalidValue("BigQuery table subscriber URI must be bigquery://<project>/<dataset>/<table> with a valid dataset ID", "uri"))
+	}
+	if !validBigQueryID(table) {
+		errs = errs.Also(apis.ErrInvalidValue("BigQuery table subscriber URI must be bigquery://<project>/<dataset>/<table> with a valid table ID", "uri"))
+	}
+	u := t.Spec.Subscriber.URI
+	if u.User != nil || u.RawQuery != "" || u.Fragment != "" {
+		errs = errs.Also(apis.ErrInvalidValue("BigQuery table subscriber URI must not have user info, a query or a fragment", "uri"))
+	}
+	return errs.ViaField("spec", "subscriber")
+}
+
+func validBigQueryID(id string) bool {
+	return len(id) <= 1024 && bigQueryIDRegexp.MatchString(id)
 }
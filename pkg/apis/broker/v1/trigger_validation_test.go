@@ -19,11 +19,287 @@ package v1
 import (
 	"context"
 	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	eventingv1 "knative.dev/eventing/pkg/apis/eventing/v1"
+	"knative.dev/pkg/apis"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
 )
 
 func TestTrigger_Validate(t *testing.T) {
-	trig := Trigger{}
-	if err := trig.Validate(context.TODO()); err != nil {
-		t.Errorf("expected nil, got %v", err)
+	tests := []struct {
+		name    string
+		trigger Trigger
+		want    *apis.FieldError
+	}{{
+		name:    "empty",
+		trigger: Trigger{},
+	}, {
+		name: "valid response classification",
+		trigger: Trigger{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					SuccessResponseCodesAnnotationKey:      "409",
+					NonRetryableResponseCodesAnnotationKey: "400, 413,415",
+				},
+			},
+		},
+	}, {
+		name: "invalid status code",
+		trigger: Trigger{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					NonRetryableResponseCodesAnnotationKey: "400,4000",
+				},
+			},
+		},
+		want: apis.ErrGeneric(`events.cloud.google.com/nonRetryableResponseCodes must be a comma-separated list of HTTP status codes, got "400,4000"`, "metadata.annotations"),
+	}, {
+		name: "status code classified twice",
+		trigger: Trigger{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					SuccessResponseCodesAnnotationKey:      "404",
+					NonRetryableResponseCodesAnnotationKey: "400,404",
+				},
+			},
+		},
+		want: apis.ErrGeneric("events.cloud.google.com/successResponseCodes and events.cloud.google.com/nonRetryableResponseCodes must not both contain status code 404", "metadata.annotations"),
+	}, {
+		name: "valid max event age",
+		trigger: Trigger{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					MaxEventAgeAnnotationKey: "PT30M",
+				},
+			},
+		},
+	}, {
+		name: "invalid max event age",
+		trigger: Trigger{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					MaxEventAgeAnnotationKey: "-PT30M",
+				},
+			},
+		},
+		want: apis.ErrGeneric(`events.cloud.google.com/maxEventAge must be a positive ISO 8601 duration, got "-PT30M"`, "metadata.annotations"),
+	}, {
+		name: "valid delivery format and headers",
+		trigger: Trigger{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					DeliveryFormatAnnotationKey:        "structured",
+					DeliveryHeadersAnnotationKey:       `{"X-Tenant-Id": "tenant-1"}`,
+					DeliveryHeaderSecretsAnnotationKey: `{"Authorization": "credentials/token"}`,
+				},
+			},
+		},
+	}, {
+		name: "invalid delivery format",
+		trigger: Trigger{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					DeliveryFormatAnnotationKey: "batched",
+				},
+			},
+		},
+		want: apis.ErrGeneric(`events.cloud.google.com/deliveryFormat must be "binary" or "structured", got "batched"`, "metadata.annotations"),
+	}, {
+		name: "reserved delivery header",
+		trigger: Trigger{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					DeliveryHeadersAnnotationKey: `{"ce-type": "spoofed"}`,
+				},
+			},
+		},
+		want: apis.ErrGeneric(`events.cloud.google.com/deliveryHeaders must not set reserved header "ce-type"`, "metadata.annotations"),
+	}, {
+		name: "malformed delivery header secret reference",
+		trigger: Trigger{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					DeliveryHeaderSecretsAnnotationKey: `{"Authorization": "credentials"}`,
+				},
+			},
+		},
+		want: apis.ErrGeneric(`events.cloud.google.com/deliveryHeaderSecrets must reference Secret keys as "<secret name>/<key>", got "credentials" for header "Authorization"`, "metadata.annotations"),
+	}, {
+		name: "header set both statically and from a secret",
+		trigger: Trigger{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					DeliveryHeadersAnnotationKey:       `{"Authorization": "token"}`,
+					DeliveryHeaderSecretsAnnotationKey: `{"Authorization": "credentials/token"}`,
+				},
+			},
+		},
+		want: apis.ErrGeneric(`events.cloud.google.com/deliveryHeaders and events.cloud.google.com/deliveryHeaderSecrets must not both set header "Authorization"`, "metadata.annotations"),
+	}, {
+		name: "valid pubsub topic subscriber",
+		trigger: Trigger{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					PubsubOrderingKeyAnnotationKey: "subject",
+					PubsubAttributesAnnotationKey:  `{"type": "eventType", "tenant": "tenant"}`,
+				},
+			},
+			Spec: eventingv1.TriggerSpec{
+				Subscriber: duckv1.Destination{URI: mustParseURL("pubsub://other-project/events")},
+			},
+		},
+	}, {
+		name: "pubsub topic subscriber without topic",
+		trigger: Trigger{
+			Spec: eventingv1.TriggerSpec{
+				Subscriber: duckv1.Destination{URI: mustParseURL("pubsub://events")},
+			},
+		},
+		want: apis.ErrInvalidValue("Pub/Sub topic subscriber URI must be pubsub://<project>/<topic> with a valid topic ID", "spec.subscriber.uri"),
+	}, {
+		name: "pubsub topic subscriber managed by the controller",
+		trigger: Trigger{
+			Spec: eventingv1.TriggerSpec{
+				Subscriber: duckv1.Destination{URI: mustParseURL("pubsub://other-project/cre-bkr_default_broker_abc123")},
+			},
+		},
+		want: apis.ErrInvalidValue(`Pub/Sub topic subscriber URI must not be a topic managed by the controller, with prefix "cre-"`, "spec.subscriber.uri"),
+	}, {
+		name: "pubsub topic subscriber with invalid project",
+		trigger: Trigger{
+			Spec: eventingv1.TriggerSpec{
+				Subscriber: duckv1.Destination{URI: mustParseURL("pubsub://Project/events")},
+			},
+		},
+		want: apis.ErrInvalidValue("Pub/Sub topic subscriber URI must be pubsub://<project>/<topic> with a valid project ID", "spec.subscriber.uri"),
+	}, {
+		name: "pubsub topic subscriber relative to a ref",
+		trigger: Trigger{
+			Spec: eventingv1.TriggerSpec{
+				Subscriber: duckv1.Destination{
+					Ref: &duckv1.KReference{Kind: "Service", Name: "subscriber"},
+					URI: mustParseURL("pubsub://other-project/events"),
+				},
+			},
+		},
+		want: apis.ErrGeneric("Pub/Sub topic subscriber URI must not be relative to a ref", "spec.subscriber.ref", "spec.subscriber.uri"),
+	}, {
+		name: "pubsub attributes without pubsub topic subscriber",
+		trigger: Trigger{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					PubsubAttributesAnnotationKey: `{"type": "eventType"}`,
+				},
+			},
+		},
+		want: apis.ErrGeneric("events.cloud.google.com/pubsubOrderingKey and events.cloud.google.com/pubsubAttributes require a Pub/Sub topic subscriber", "metadata.annotations"),
+	}, {
+		name: "invalid pubsub ordering key",
+		trigger: Trigger{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					PubsubOrderingKeyAnnotationKey: "Subject",
+				},
+			},
+		},
+		want: apis.ErrGeneric(`events.cloud.google.com/pubsubOrderingKey must be a CloudEvent attribute name, got "Subject"`, "metadata.annotations"),
+	}, {
+		name: "reserved pubsub attribute",
+		trigger: Trigger{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					PubsubAttributesAnnotationKey: `{"type": "googType"}`,
+				},
+			},
+		},
+		want: apis.ErrGeneric(`events.cloud.google.com/pubsubAttributes has invalid or reserved message attribute name "googType"`, "metadata.annotations"),
+	}, {
+		name: "valid bigquery table subscriber",
+		trigger: Trigger{
+			Spec: eventingv1.TriggerSpec{
+				Subscriber: duckv1.Destination{URI: mustParseURL("bigquery://other-project/analytics/events")},
+			},
+		},
+	}, {
+		name: "bigquery table subscriber ignoring unknown values",
+		trigger: Trigger{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					BigQueryIgnoreUnknownValuesAnnotationKey: "true",
+				},
+			},
+			Spec: eventingv1.TriggerSpec{
+				Subscriber: duckv1.Destination{URI: mustParseURL("bigquery://other-project/analytics/events")},
+			},
+		},
+	}, {
+		name: "invalid bigquery ignore unknown values",
+		trigger: Trigger{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					BigQueryIgnoreUnknownValuesAnnotationKey: "sometimes",
+				},
+			},
+			Spec: eventingv1.TriggerSpec{
+				Subscriber: duckv1.Destination{URI: mustParseURL("bigquery://other-project/analytics/events")},
+			},
+		},
+		want: apis.ErrGeneric(`events.cloud.google.com/bigqueryIgnoreUnknownValues must be a boolean, got "sometimes"`, "metadata.annotations"),
+	}, {
+		name: "bigquery ignore unknown values without bigquery table subscriber",
+		trigger: Trigger{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					BigQueryIgnoreUnknownValuesAnnotationKey: "true",
+				},
+			},
+		},
+		want: apis.ErrGeneric("events.cloud.google.com/bigqueryIgnoreUnknownValues requires a BigQuery table subscriber", "metadata.annotations"),
+	}, {
+		name: "bigquery table subscriber without table",
+		trigger: Trigger{
+			Spec: eventingv1.TriggerSpec{
+				Subscriber: duckv1.Destination{URI: mustParseURL("bigquery://other-project/analytics")},
+			},
+		},
+		want: apis.ErrInvalidValue("BigQuery table subscriber URI must be bigquery://<project>/<dataset>/<table> with a valid table ID", "spec.subscriber.uri"),
+	}, {
+		name: "bigquery table subscriber with invalid dataset",
+		trigger: Trigger{
+			Spec: eventingv1.TriggerSpec{
+				Subscriber: duckv1.Destination{URI: mustParseURL("bigquery://other-project/analytics-dataset/events")},
+			},
+		},
+		want: apis.ErrInvalidValue("BigQuery table subscriber URI must be bigquery://<project>/<dataset>/<table> with a valid dataset ID", "spec.subscriber.uri"),
+	}, {
+		name: "bigquery table subscriber relative to a ref",
+		trigger: Trigger{
+			Spec: eventingv1.TriggerSpec{
+				Subscriber: duckv1.Destination{
+					Ref: &duckv1.KReference{Kind: "Service", Name: "subscriber"},
+					URI: mustParseURL("bigquery://other-project/analytics/events"),
+				},
+			},
+		},
+		want: apis.ErrGeneric("BigQuery table subscriber URI must not be relative to a ref", "spec.subscriber.ref", "spec.subscriber.uri"),
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := test.trigger.Validate(context.TODO())
+			if diff := cmp.Diff(test.want.Error(), got.Error()); diff != "" {
+				t.Error("Trigger.Validate (-want, +got) =", diff)
+			}
+		})
+	}
+}
+
+func mustParseURL(s string) *apis.URL {
+	u, err := apis.ParseURL(s)
+	if err != nil {
+		panic(err)
 	}
+	return u
 }
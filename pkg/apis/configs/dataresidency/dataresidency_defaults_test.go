@@ -27,6 +27,8 @@ import (
 
 	. "knative.dev/pkg/configmap/testing"
 	_ "knative.dev/pkg/system/testing"
+
+	"github.com/google/knative-gcp/pkg/apis/duck"
 )
 
 func TestDefaultsConfigurationFromFile(t *testing.T) {
@@ -43,35 +45,45 @@ func TestNewDefaultsConfigFromConfigMap(t *testing.T) {
 		t.Fatalf("NewDefaultsConfigFromConfigMap(example) = %v", err)
 	}
 
-	// Only cluster wide configuration is supported now, but we use the namespace
-	// as the test name and for future extension.
 	testCases := []struct {
 		ns      string
 		regions []string
+		global  bool
 	}{
 		{
 			ns:      "cluster-wide",
 			regions: []string{"us-east1", "us-west1"},
 		},
+		{
+			ns:      "eu-ns",
+			regions: []string{"europe-west1"},
+		},
+		{
+			ns:     "global-ns",
+			global: true,
+		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.ns, func(t *testing.T) {
-			if diff := cmp.Diff(tc.regions, defaults.AllowedPersistenceRegions()); diff != "" {
+			if diff := cmp.Diff(tc.regions, defaults.AllowedPersistenceRegions(tc.ns)); diff != "" {
 				t.Errorf("Unexpected value (-want +got): %s", diff)
 			}
+			if got := defaults.Global(tc.ns); got != tc.global {
+				t.Errorf("Unexpected global value, expected: %v, got %v", tc.global, got)
+			}
 		})
 	}
 }
 
 func TestComputeAllowedPersistenceRegions(t *testing.T) {
 	const clusterRegion = "us-central1"
-	// Only cluster wide configuration is supported now, but we use the namespace
-	// as the test name and for future extension.
 	testCases := []struct {
 		ns                 string
 		topicConfigRegions []string
 		dsRegions          []string
+		nsRegions          []string
+		annotations        map[string]string
 		expectedRegions    []string
 		global             bool
 		updated            bool
@@ -127,15 +139,53 @@ func TestComputeAllowedPersistenceRegions(t *testing.T) {
 			expectedRegions:    []string{"us-east1"},
 			updated:            true,
 		},
+		{
+			ns:                 "namespace-defaults",
+			topicConfigRegions: nil,
+			dsRegions:          []string{"us-east1"},
+			nsRegions:          []string{"europe-west1"},
+			expectedRegions:    []string{"europe-west1"},
+			updated:            true,
+		},
+		{
+			ns:                 "override",
+			topicConfigRegions: nil,
+			dsRegions:          []string{"us-east1"},
+			nsRegions:          []string{"europe-west1"},
+			annotations:        map[string]string{duck.AllowedPersistenceRegionsAnnotation: "europe-west4,europe-west6"},
+			expectedRegions:    []string{"europe-west4", "europe-west6"},
+			updated:            true,
+		},
+		{
+			ns:                 "override-global",
+			global:             true,
+			topicConfigRegions: nil,
+			annotations:        map[string]string{duck.AllowedPersistenceRegionsAnnotation: "europe-west4"},
+			expectedRegions:    []string{"europe-west4"},
+			updated:            true,
+		},
+		{
+			ns:                 "override-existing",
+			topicConfigRegions: []string{"us-east1"},
+			annotations:        map[string]string{duck.AllowedPersistenceRegionsAnnotation: "europe-west4"},
+			expectedRegions:    []string{"us-east1"},
+			updated:            false,
+		},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.ns, func(t *testing.T) {
 			defaults := &Defaults{}
 			defaults.ClusterDefaults.AllowedPersistenceRegions = tc.dsRegions
 			defaults.ClusterDefaults.Global = tc.global
+			if tc.nsRegions != nil {
+				defaults.NamespaceDefaults = map[string]ScopedDefaults{
+					tc.ns: {AllowedPersistenceRegions: tc.nsRegions},
+				}
+			}
 			topicConfig := &pubsub.TopicConfig{}
 			topicConfig.MessageStoragePolicy.AllowedPersistenceRegions = tc.topicConfigRegions
-			updated := defaults.ComputeAllowedPersistenceRegions(topicConfig, clusterRegion)
+			obj := &metav1.ObjectMeta{Namespace: tc.ns, Annotations: tc.annotations}
+			updated := defaults.ComputeAllowedPersistenceRegions(topicConfig, clusterRegion, obj)
 			if updated != tc.updated {
 				t.Errorf("Unexpected updated value, expected: %v, got %v", tc.updated, updated)
 			}
@@ -146,6 +196,88 @@ func TestComputeAllowedPersistenceRegions(t *testing.T) {
 	}
 }
 
+func TestRequiredPersistenceRegions(t *testing.T) {
+	defaults := &Defaults{
+		ClusterDefaults: ScopedDefaults{Global: true},
+		NamespaceDefaults: map[string]ScopedDefaults{
+			"eu-ns": {AllowedPersistenceRegions: []string{"europe-west1"}},
+		},
+	}
+	testCases := []struct {
+		name string
+		obj  metav1.Object
+		want []string
+	}{
+		{
+			name: "cluster defaults",
+			obj:  &metav1.ObjectMeta{Namespace: "ns"},
+		},
+		{
+			name: "namespace defaults",
+			obj:  &metav1.ObjectMeta{Namespace: "eu-ns"},
+			want: []string{"europe-west1"},
+		},
+		{
+			name: "override",
+			obj: &metav1.ObjectMeta{
+				Namespace:   "eu-ns",
+				Annotations: map[string]string{duck.AllowedPersistenceRegionsAnnotation: "europe-west4"},
+			},
+			want: []string{"europe-west4"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, defaults.RequiredPersistenceRegions(tc.obj)); diff != "" {
+				t.Errorf("Unexpected value (-want +got): %s", diff)
+			}
+		})
+	}
+}
+
+func TestConflictingPersistenceRegions(t *testing.T) {
+	testCases := []struct {
+		name          string
+		allowed       []string
+		existing      []string
+		wantConflicts []string
+		wantConflict  bool
+	}{
+		{
+			name:     "no constraint",
+			existing: []string{"us-east1"},
+		},
+		{
+			name:     "subset",
+			allowed:  []string{"us-east1", "us-west1"},
+			existing: []string{"us-west1"},
+		},
+		{
+			name:          "conflict",
+			allowed:       []string{"europe-west1"},
+			existing:      []string{"europe-west1", "us-east1"},
+			wantConflicts: []string{"us-east1"},
+			wantConflict:  true,
+		},
+		{
+			name:         "existing unconstrained",
+			allowed:      []string{"europe-west1"},
+			wantConflict: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			conflicts, conflict := ConflictingPersistenceRegions(tc.allowed, tc.existing)
+			if conflict != tc.wantConflict {
+				t.Errorf("Unexpected conflict, expected: %v, got %v", tc.wantConflict, conflict)
+			}
+			if diff := cmp.Diff(tc.wantConflicts, conflicts); diff != "" {
+				t.Errorf("Unexpected conflicting regions (-want +got): %s", diff)
+			}
+		})
+	}
+}
+
 func TestNewDefaultsConfigFromConfigMapWithKeyError(t *testing.T) {
 	testCases := map[string]struct {
 		name   string
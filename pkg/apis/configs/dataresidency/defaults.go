@@ -18,11 +18,18 @@ package dataresidency
 
 import (
 	"cloud.google.com/go/pubsub"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/google/knative-gcp/pkg/apis/duck"
 )
 
 // Defaults includes the default values to be populated by the Webhook.
 type Defaults struct {
-	// ClusterDefaults are the data residency defaults to use for all namepaces
+	// NamespaceDefaults are the data residency defaults to use in specific namespaces. The
+	// namespace is the key, the value is the defaults.
+	NamespaceDefaults map[string]ScopedDefaults `json:"namespaceDefaults,omitempty"`
+	// ClusterDefaults are the data residency defaults to use for all namepaces that are not in
+	// NamespaceDefaults.
 	ClusterDefaults ScopedDefaults `json:"clusterDefaults,omitempty"`
 }
 
@@ -36,40 +43,47 @@ type ScopedDefaults struct {
 	Global bool `json:"messagestoragepolicy.global,omitempty"`
 }
 
-// scoped gets the scoped data residency defaults, for now we only have
-// cluster scope.
-func (d *Defaults) scoped() *ScopedDefaults {
+// scoped gets the scoped data residency defaults for the given namespace.
+func (d *Defaults) scoped(ns string) *ScopedDefaults {
 	scopedDefaults := &d.ClusterDefaults
-	// currently we don't support namespace, but if we do, we should check
-	// namespace default here.
+	if sd, present := d.NamespaceDefaults[ns]; present {
+		scopedDefaults = &sd
+	}
 	return scopedDefaults
 }
 
-// AllowedPersistenceRegions gets the AllowedPersistenceRegions setting in the default.
-func (d *Defaults) AllowedPersistenceRegions() []string {
-	return d.scoped().AllowedPersistenceRegions
+// AllowedPersistenceRegions gets the AllowedPersistenceRegions setting in the default of the
+// namespace.
+func (d *Defaults) AllowedPersistenceRegions(ns string) []string {
+	return d.scoped(ns).AllowedPersistenceRegions
 }
 
-// Global gets the Global setting in the default.
-func (d *Defaults) Global() bool {
-	return d.scoped().Global
+// Global gets the Global setting in the default of the namespace.
+func (d *Defaults) Global(ns string) bool {
+	return d.scoped(ns).Global
 }
 
 // ComputeAllowedPersistenceRegions computes the final message storage policy in
-// topicConfig. Return true if the topicConfig is updated.
-func (d *Defaults) ComputeAllowedPersistenceRegions(topicConfig *pubsub.TopicConfig, clusterRegion string) bool {
+// topicConfig for a topic created for obj. The regions of the
+// duck.AllowedPersistenceRegionsAnnotation of obj take precedence over the
+// defaults of its namespace. Return true if the topicConfig is updated.
+func (d *Defaults) ComputeAllowedPersistenceRegions(topicConfig *pubsub.TopicConfig, clusterRegion string, obj metav1.Object) bool {
 	if topicConfig.MessageStoragePolicy.AllowedPersistenceRegions != nil {
 		// Don't try to change anything if it is not empty
 		return false
 	}
+	if regions := duck.AllowedPersistenceRegions(obj.GetAnnotations()); len(regions) > 0 {
+		topicConfig.MessageStoragePolicy.AllowedPersistenceRegions = regions
+		return true
+	}
 	// We can do subset of both in the future, but for now, we just overwrite the
 	// configuration as the relationship between region and zones are not clear to handle,
 	// eg. us-east1 vs us-east1-a. Important note: setting the AllowedPersistenceRegions
 	// to empty string slice is an error, should set it to nil for all regions.
-	allowedRegions := d.AllowedPersistenceRegions()
+	allowedRegions := d.AllowedPersistenceRegions(obj.GetNamespace())
 	// overwrite empty allowedRegions to nil
 	if len(allowedRegions) == 0 {
-		if d.Global() {
+		if d.Global(obj.GetNamespace()) {
 			// Not setting means same as Org Policy
 			return false
 		}
@@ -81,3 +95,37 @@ func (d *Defaults) ComputeAllowedPersistenceRegions(topicConfig *pubsub.TopicCon
 	topicConfig.MessageStoragePolicy.AllowedPersistenceRegions = allowedRegions
 	return (allowedRegions != nil)
 }
+
+// RequiredPersistenceRegions returns the regions the topics of obj are required to persist their
+// messages in, from its duck.AllowedPersistenceRegionsAnnotation or the defaults of its namespace.
+// Unlike ComputeAllowedPersistenceRegions, it doesn't fall back to the cluster region, which is
+// only a default for the topics created by the controller.
+func (d *Defaults) RequiredPersistenceRegions(obj metav1.Object) []string {
+	if regions := duck.AllowedPersistenceRegions(obj.GetAnnotations()); len(regions) > 0 {
+		return regions
+	}
+	return d.AllowedPersistenceRegions(obj.GetNamespace())
+}
+
+// ConflictingPersistenceRegions returns the regions an existing topic persists its messages in
+// that are not allowed, if any. An existing topic without allowed persistence regions persists
+// its messages in any region, which conflicts with any allowed regions.
+func ConflictingPersistenceRegions(allowed, existing []string) ([]string, bool) {
+	if len(allowed) == 0 {
+		return nil, false
+	}
+	if len(existing) == 0 {
+		return nil, true
+	}
+	isAllowed := make(map[string]bool, len(allowed))
+	for _, r := range allowed {
+		isAllowed[r] = true
+	}
+	var conflicts []string
+	for _, r := range existing {
+		if !isAllowed[r] {
+			conflicts = append(conflicts, r)
+		}
+	}
+	return conflicts, len(conflicts) > 0
+}
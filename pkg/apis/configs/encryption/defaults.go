@@ -0,0 +1,72 @@
+/*
+Copyright 2021 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package encryption
+
+import (
+	"cloud.google.com/go/pubsub"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/google/knative-gcp/pkg/apis/duck"
+)
+
+// Defaults includes the default values to be populated by the Webhook.
+type Defaults struct {
+	// NamespaceDefaults are the encryption defaults to use in specific namespaces. The namespace
+	// is the key, the value is the defaults.
+	NamespaceDefaults map[string]ScopedDefaults `json:"namespaceDefaults,omitempty"`
+	// ClusterDefaults are the encryption defaults to use for all namepaces that are not in
+	// NamespaceDefaults.
+	ClusterDefaults ScopedDefaults `json:"clusterDefaults,omitempty"`
+}
+
+// ScopedDefaults are the encryption setting defaults.
+type ScopedDefaults struct {
+	// KMSKeyName is the name of the Cloud KMS key the topics are encrypted with, eg
+	// "projects/my-project/locations/us-east1/keyRings/my-ring/cryptoKeys/my-key". An empty
+	// configuration means the topics are encrypted with Google-managed keys.
+	KMSKeyName string `json:"kmsKeyName,omitempty"`
+}
+
+// scoped gets the scoped encryption defaults for the given namespace.
+func (d *Defaults) scoped(ns string) *ScopedDefaults {
+	scopedDefaults := &d.ClusterDefaults
+	if sd, present := d.NamespaceDefaults[ns]; present {
+		scopedDefaults = &sd
+	}
+	return scopedDefaults
+}
+
+// KMSKeyName gets the Cloud KMS key the topics created for obj are required to be encrypted with.
+// The duck.KMSKeyNameAnnotation of obj takes precedence over the defaults of its namespace.
+// Return an empty string if no customer-managed key is required.
+func (d *Defaults) KMSKeyName(obj metav1.Object) string {
+	if key, ok := obj.GetAnnotations()[duck.KMSKeyNameAnnotation]; ok {
+		return key
+	}
+	return d.scoped(obj.GetNamespace()).KMSKeyName
+}
+
+// ComputeKMSKeyName computes the final Cloud KMS key in topicConfig for a topic
+// created for obj. Return true if the topicConfig is updated.
+func (d *Defaults) ComputeKMSKeyName(topicConfig *pubsub.TopicConfig, obj metav1.Object) bool {
+	if topicConfig.KMSKeyName != "" {
+		// Don't try to change anything if it is not empty
+		return false
+	}
+	topicConfig.KMSKeyName = d.KMSKeyName(obj)
+	return topicConfig.KMSKeyName != ""
+}
@@ -0,0 +1,197 @@
+/*
+Copyright 2021 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package encryption
+
+import (
+	"testing"
+
+	"cloud.google.com/go/pubsub"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	. "knative.dev/pkg/configmap/testing"
+	_ "knative.dev/pkg/system/testing"
+
+	"github.com/google/knative-gcp/pkg/apis/duck"
+)
+
+const (
+	clusterKey = "projects/my-project/locations/us-east1/keyRings/my-ring/cryptoKeys/my-key"
+	euKey      = "projects/my-project/locations/europe-west1/keyRings/my-ring/cryptoKeys/my-key"
+	otherKey   = "projects/my-project/locations/europe-west1/keyRings/my-ring/cryptoKeys/other-key"
+)
+
+func TestDefaultsConfigurationFromFile(t *testing.T) {
+	_, example := ConfigMapsFromTestFile(t, configName, defaulterKey)
+	if _, err := NewDefaultsConfigFromConfigMap(example); err != nil {
+		t.Errorf("NewDefaultsConfigFromConfigMap(example) = %v", err)
+	}
+}
+
+func TestNewDefaultsConfigFromConfigMap(t *testing.T) {
+	_, example := ConfigMapsFromTestFile(t, configName, defaulterKey)
+	defaults, err := NewDefaultsConfigFromConfigMap(example)
+	if err != nil {
+		t.Fatalf("NewDefaultsConfigFromConfigMap(example) = %v", err)
+	}
+
+	testCases := []struct {
+		ns  string
+		key string
+	}{
+		{
+			ns:  "cluster-wide",
+			key: clusterKey,
+		},
+		{
+			ns:  "eu-ns",
+			key: euKey,
+		},
+		{
+			ns: "unencrypted-ns",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.ns, func(t *testing.T) {
+			if got := defaults.KMSKeyName(&metav1.ObjectMeta{Namespace: tc.ns}); got != tc.key {
+				t.Errorf("Unexpected KMS key name, expected: %q, got %q", tc.key, got)
+			}
+		})
+	}
+}
+
+func TestComputeKMSKeyName(t *testing.T) {
+	defaults := &Defaults{
+		ClusterDefaults: ScopedDefaults{KMSKeyName: clusterKey},
+		NamespaceDefaults: map[string]ScopedDefaults{
+			"eu-ns":          {KMSKeyName: euKey},
+			"unencrypted-ns": {},
+		},
+	}
+	testCases := []struct {
+		name           string
+		ns             string
+		annotations    map[string]string
+		topicConfigKey string
+		expectedKey    string
+		updated        bool
+	}{
+		{
+			name:        "cluster defaults",
+			ns:          "ns",
+			expectedKey: clusterKey,
+			updated:     true,
+		},
+		{
+			name:        "namespace defaults",
+			ns:          "eu-ns",
+			expectedKey: euKey,
+			updated:     true,
+		},
+		{
+			name:    "namespace without key",
+			ns:      "unencrypted-ns",
+			updated: false,
+		},
+		{
+			name:        "override",
+			ns:          "eu-ns",
+			annotations: map[string]string{duck.KMSKeyNameAnnotation: otherKey},
+			expectedKey: otherKey,
+			updated:     true,
+		},
+		{
+			name:        "override without namespace key",
+			ns:          "unencrypted-ns",
+			annotations: map[string]string{duck.KMSKeyNameAnnotation: otherKey},
+			expectedKey: otherKey,
+			updated:     true,
+		},
+		{
+			name:           "topic config key",
+			ns:             "eu-ns",
+			topicConfigKey: otherKey,
+			expectedKey:    otherKey,
+			updated:        false,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			topicConfig := &pubsub.TopicConfig{KMSKeyName: tc.topicConfigKey}
+			obj := &metav1.ObjectMeta{Namespace: tc.ns, Annotations: tc.annotations}
+			updated := defaults.ComputeKMSKeyName(topicConfig, obj)
+			if updated != tc.updated {
+				t.Errorf("Unexpected updated value, expected: %v, got %v", tc.updated, updated)
+			}
+			if topicConfig.KMSKeyName != tc.expectedKey {
+				t.Errorf("Unexpected KMS key name, expected: %q, got %q", tc.expectedKey, topicConfig.KMSKeyName)
+			}
+		})
+	}
+}
+
+func TestNewDefaultsConfigFromConfigMapWithKeyError(t *testing.T) {
+	testCases := map[string]struct {
+		name   string
+		config *corev1.ConfigMap
+	}{
+		"empty data": {
+			config: &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "cloud-run-events",
+					Name:      configName,
+				},
+				Data: map[string]string{},
+			},
+		},
+		"missing key": {
+			config: &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "cloud-run-events",
+					Name:      configName,
+				},
+				Data: map[string]string{
+					"other-keys": "are-present",
+				},
+			},
+		},
+		"wrong format": {
+			config: &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "cloud-run-events",
+					Name:      configName,
+				},
+				Data: map[string]string{
+					defaulterKey: `
+  clusterDefaults:
+    kmsKey: projects/my-project/locations/us-east1/keyRings/my-ring/cryptoKeys/my-key`,
+				},
+			},
+		},
+	}
+
+	for n, tc := range testCases {
+		t.Run(n, func(t *testing.T) {
+			_, err := NewDefaultsConfigFromConfigMap(tc.config)
+			if err == nil {
+				t.Fatalf("Expected an error, actually nil")
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+/*
+Copyright 2021 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package encryption
+
+import (
+	"context"
+	"sync"
+
+	"knative.dev/pkg/logging"
+
+	"knative.dev/pkg/configmap"
+)
+
+// +k8s:deepcopy-gen=false
+type StoreSingleton struct {
+	setup sync.Once
+	store *Store
+}
+
+func (s *StoreSingleton) Store(ctx context.Context, cmw configmap.Watcher) *Store {
+	s.setup.Do(func() {
+		s.store = NewStore(logging.FromContext(ctx).Named("config-encryption-store"))
+		s.store.WatchConfigs(cmw)
+	})
+	return s.store
+}
@@ -0,0 +1,44 @@
+/*
+Copyright 2021 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package encryption
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	. "knative.dev/pkg/configmap"
+	. "knative.dev/pkg/configmap/testing"
+)
+
+func TestStoreSingletonLoadWithContext(t *testing.T) {
+	ctx := context.Background()
+
+	storeSingleton := &StoreSingleton{}
+
+	_, defaultsConfig := ConfigMapsFromTestFile(t, configName, defaulterKey)
+	cmw := NewStaticWatcher(defaultsConfig)
+
+	store := storeSingleton.Store(ctx, cmw)
+
+	t.Run("defaults", func(t *testing.T) {
+		expected, _ := NewDefaultsConfigFromConfigMap(defaultsConfig)
+		if diff := cmp.Diff(expected, store.Load().EncryptionDefaults); diff != "" {
+			t.Fatalf("Unexpected defaults config (-want, +got): %v", diff)
+		}
+	})
+}
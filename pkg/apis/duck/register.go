@@ -28,6 +28,15 @@ const (
 	AutoscalingClassAnnotation = Autoscaling + "/class"
 	// ClusterNameAnnotation is the annotation for the cluster Name.
 	ClusterNameAnnotation = "cluster-name"
+	// AllowedPersistenceRegionsAnnotation is the annotation to override the data residency
+	// defaults of the Pub/Sub topics created for a resource. The value is a comma separated list
+	// of regions, eg "us-east1,us-west1".
+	AllowedPersistenceRegionsAnnotation = "events.cloud.google.com/allowedPersistenceRegions"
+
+	// KMSKeyNameAnnotation is the annotation to override the encryption defaults of the Pub/Sub
+	// topics created for a resource. The value is the name of the Cloud KMS key, eg
+	// "projects/my-project/locations/us-east1/keyRings/my-ring/cryptoKeys/my-key".
+	KMSKeyNameAnnotation = "events.cloud.google.com/kmsKeyName"
 
 	// AutoscalingMinScaleAnnotation is the annotation to specify the minimum number of pods to scale to.
 	AutoscalingMinScaleAnnotation = Autoscaling + "/minScale"
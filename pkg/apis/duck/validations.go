@@ -23,6 +23,7 @@ import (
 	"math"
 	"regexp"
 	"strconv"
+	"strings"
 
 	"github.com/google/go-cmp/cmp"
 
@@ -35,6 +36,12 @@ var (
 	// The name of a k8s ServiceAccount object must be a valid DNS subdomain name.
 	// https://kubernetes.io/docs/concepts/overview/working-with-objects/names/#dns-subdomain-names
 	ksaValidationRegex = regexp.MustCompile(`^[A-Za-z0-9](?:[A-Za-z0-9\-]{0,61}[A-Za-z0-9])?$`)
+
+	// The GCP regions, eg "us-east1" or "northamerica-northeast1".
+	regionValidationRegex = regexp.MustCompile(`^[a-z]+(-[a-z]+)+[0-9]+$`)
+
+	// The Cloud KMS keys, eg "projects/my-project/locations/us-east1/keyRings/my-ring/cryptoKeys/my-key".
+	kmsKeyNameValidationRegex = regexp.MustCompile(`^projects/[^/]+/locations/[^/]+/keyRings/[^/]+/cryptoKeys/[^/]+$`)
 )
 
 // ValidateAutoscalingAnnotations validates the autoscaling annotations.
@@ -68,6 +75,50 @@ func ValidateAutoscalingAnnotations(ctx context.Context, annotations map[string]
 	return errs
 }
 
+// AllowedPersistenceRegions returns the regions of the AllowedPersistenceRegionsAnnotation, or nil
+// if there is none.
+func AllowedPersistenceRegions(annotations map[string]string) []string {
+	value, ok := annotations[AllowedPersistenceRegionsAnnotation]
+	if !ok {
+		return nil
+	}
+	var regions []string
+	for _, r := range strings.Split(value, ",") {
+		if r = strings.TrimSpace(r); r != "" {
+			regions = append(regions, r)
+		}
+	}
+	return regions
+}
+
+// ValidateAllowedPersistenceRegionsAnnotation validates the AllowedPersistenceRegionsAnnotation is
+// a non empty list of regions, if present.
+func ValidateAllowedPersistenceRegionsAnnotation(annotations map[string]string, errs *apis.FieldError) *apis.FieldError {
+	value, ok := annotations[AllowedPersistenceRegionsAnnotation]
+	if !ok {
+		return errs
+	}
+	regions := AllowedPersistenceRegions(annotations)
+	if len(regions) == 0 {
+		return errs.Also(apis.ErrInvalidValue(value, fmt.Sprintf("metadata.annotations[%s]", AllowedPersistenceRegionsAnnotation)))
+	}
+	for _, r := range regions {
+		if !regionValidationRegex.MatchString(r) {
+			errs = errs.Also(apis.ErrInvalidValue(r, fmt.Sprintf("metadata.annotations[%s]", AllowedPersistenceRegionsAnnotation)))
+		}
+	}
+	return errs
+}
+
+// ValidateKMSKeyNameAnnotation validates the KMSKeyNameAnnotation is the name of a Cloud KMS key, if
+// present.
+func ValidateKMSKeyNameAnnotation(annotations map[string]string, errs *apis.FieldError) *apis.FieldError {
+	if value, ok := annotations[KMSKeyNameAnnotation]; ok && !kmsKeyNameValidationRegex.MatchString(value) {
+		errs = errs.Also(apis.ErrInvalidValue(value, fmt.Sprintf("metadata.annotations[%s]", KMSKeyNameAnnotation)))
+	}
+	return errs
+}
+
 func validateAnnotation(annotations map[string]string, annotation string, minimumValue int, errs *apis.FieldError) (int, *apis.FieldError) {
 	var value int
 	if val, ok := annotations[annotation]; !ok {
@@ -120,6 +120,80 @@ func TestValidateAutoscalingAnnotations(t *testing.T) {
 	}
 }
 
+func TestValidateAllowedPersistenceRegionsAnnotation(t *testing.T) {
+	testCases := map[string]struct {
+		annotations map[string]string
+		wantRegions []string
+		error       bool
+	}{
+		"no annotation": {
+			annotations: map[string]string{},
+		},
+		"single region": {
+			annotations: map[string]string{AllowedPersistenceRegionsAnnotation: "us-east1"},
+			wantRegions: []string{"us-east1"},
+		},
+		"multiple regions": {
+			annotations: map[string]string{AllowedPersistenceRegionsAnnotation: "europe-west1, northamerica-northeast1"},
+			wantRegions: []string{"europe-west1", "northamerica-northeast1"},
+		},
+		"empty": {
+			annotations: map[string]string{AllowedPersistenceRegionsAnnotation: " , "},
+			error:       true,
+		},
+		"invalid region": {
+			annotations: map[string]string{AllowedPersistenceRegionsAnnotation: "us-east1,us-east1-a"},
+			wantRegions: []string{"us-east1", "us-east1-a"},
+			error:       true,
+		},
+	}
+	for n, tc := range testCases {
+		t.Run(n, func(t *testing.T) {
+			if diff := cmp.Diff(tc.wantRegions, AllowedPersistenceRegions(tc.annotations)); diff != "" {
+				t.Errorf("Unexpected regions (-want +got): %s", diff)
+			}
+			err := ValidateAllowedPersistenceRegionsAnnotation(tc.annotations, nil)
+			if tc.error != (err != nil) {
+				t.Fatalf("Unexpected validation failure. Got %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateKMSKeyNameAnnotation(t *testing.T) {
+	testCases := map[string]struct {
+		annotations map[string]string
+		error       bool
+	}{
+		"no annotation": {
+			annotations: map[string]string{},
+		},
+		"valid key": {
+			annotations: map[string]string{KMSKeyNameAnnotation: "projects/my-project/locations/us-east1/keyRings/my-ring/cryptoKeys/my-key"},
+		},
+		"empty": {
+			annotations: map[string]string{KMSKeyNameAnnotation: ""},
+			error:       true,
+		},
+		"key version": {
+			annotations: map[string]string{KMSKeyNameAnnotation: "projects/my-project/locations/us-east1/keyRings/my-ring/cryptoKeys/my-key/cryptoKeyVersions/1"},
+			error:       true,
+		},
+		"missing key ring": {
+			annotations: map[string]string{KMSKeyNameAnnotation: "projects/my-project/locations/us-east1/cryptoKeys/my-key"},
+			error:       true,
+		},
+	}
+	for n, tc := range testCases {
+		t.Run(n, func(t *testing.T) {
+			err := ValidateKMSKeyNameAnnotation(tc.annotations, nil)
+			if tc.error != (err != nil) {
+				t.Fatalf("Unexpected validation failure. Got %v", err)
+			}
+		})
+	}
+}
+
 func TestCheckImmutableClusterNameAnnotation(t *testing.T) {
 	testCases := map[string]struct {
 		original *v1.ObjectMeta
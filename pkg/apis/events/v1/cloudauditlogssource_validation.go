@@ -35,7 +35,8 @@ func (current *CloudAuditLogsSource) Validate(ctx context.Context) *apis.FieldEr
 		original := apis.GetBaseline(ctx).(*CloudAuditLogsSource)
 		err = err.Also(current.CheckImmutableFields(ctx, original))
 	}
-	return err
+	err = duck.ValidateAllowedPersistenceRegionsAnnotation(current.Annotations, err)
+	return duck.ValidateKMSKeyNameAnnotation(current.Annotations, err)
 }
 
 func (current *CloudAuditLogsSourceSpec) Validate(ctx context.Context) *apis.FieldError {
@@ -37,6 +37,8 @@ func (current *CloudPubSubSource) Validate(ctx context.Context) *apis.FieldError
 		original := apis.GetBaseline(ctx).(*CloudPubSubSource)
 		errs = errs.Also(current.CheckImmutableFields(ctx, original))
 	}
+	errs = duck.ValidateAllowedPersistenceRegionsAnnotation(current.Annotations, errs)
+	errs = duck.ValidateKMSKeyNameAnnotation(current.Annotations, errs)
 	return duck.ValidateAutoscalingAnnotations(ctx, current.Annotations, errs)
 }
 
@@ -39,7 +39,8 @@ func (t *Topic) Validate(ctx context.Context) *apis.FieldError {
 		original := apis.GetBaseline(ctx).(*Topic)
 		err = err.Also(t.CheckImmutableFields(ctx, original))
 	}
-	return err
+	err = duck.ValidateAllowedPersistenceRegionsAnnotation(t.Annotations, err)
+	return duck.ValidateKMSKeyNameAnnotation(t.Annotations, err)
 }
 
 func (ts *TopicSpec) Validate(ctx context.Context) *apis.FieldError {
@@ -89,6 +89,66 @@ func TestTopicValidation(t *testing.T) {
 		want: []string{
 			"invalid value: invalid-propagation-policy: spec.propagationPolicy",
 		},
+	}, {
+		name: "allowed persistence regions",
+		cr: &Topic{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					duck.AllowedPersistenceRegionsAnnotation: "us-east1,",
+				},
+			},
+			Spec: TopicSpec{
+				Topic:             "topic",
+				PropagationPolicy: TopicPolicyCreateNoDelete,
+			},
+		},
+		want: nil,
+	}, {
+		name: "allowed persistence regions with invalid region",
+		cr: &Topic{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					duck.AllowedPersistenceRegionsAnnotation: "us",
+				},
+			},
+			Spec: TopicSpec{
+				Topic:             "topic",
+				PropagationPolicy: TopicPolicyCreateNoDelete,
+			},
+		},
+		want: []string{
+			"invalid value: us: metadata.annotations[events.cloud.google.com/allowedPersistenceRegions]",
+		},
+	}, {
+		name: "kms key name",
+		cr: &Topic{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					duck.KMSKeyNameAnnotation: "projects/my-project/locations/us-east1/keyRings/my-ring/cryptoKeys/my-key",
+				},
+			},
+			Spec: TopicSpec{
+				Topic:             "topic",
+				PropagationPolicy: TopicPolicyCreateNoDelete,
+			},
+		},
+		want: nil,
+	}, {
+		name: "invalid kms key name",
+		cr: &Topic{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					duck.KMSKeyNameAnnotation: "projects/my-project/cryptoKeys/my-key",
+				},
+			},
+			Spec: TopicSpec{
+				Topic:             "topic",
+				PropagationPolicy: TopicPolicyCreateNoDelete,
+			},
+		},
+		want: []string{
+			"invalid value: projects/my-project/cryptoKeys/my-key: metadata.annotations[events.cloud.google.com/kmsKeyName]",
+		},
 	}}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
@@ -46,6 +46,7 @@ const (
 	memoryLimitFanout     string = "2500Mi"
 	memoryLimitIngress    string = "2000Mi"
 	memoryLimitRetry      string = "1500Mi"
+	targetBacklog         int64  = 1000
 	minReplicas           int32  = 1
 	maxReplicas           int32  = 10
 )
@@ -73,6 +74,10 @@ func (bcs *BrokerCellSpec) SetDefaults(ctx context.Context) {
 		bcs.Components.Retry = makeComponent(cpuRequestRetry, cpuLimitRetry, memoryRequestRetry, memoryLimitRetry, avgCPUUtilizationRetry, avgMemoryUsageRetry)
 	}
 	bcs.Components.Retry.setAutoScalingDefaults()
+	// External ingress defaults
+	if bcs.ExternalIngress != nil && bcs.ExternalIngress.Type == "" {
+		bcs.ExternalIngress.Type = LoadBalancerExternalIngress
+	}
 }
 
 func makeComponent(cpuRequest, cpuLimit, memoryRequest, memoryLimit string, avgCPUUtilization int32, targetMemoryUsage string) *ComponentParameters {
@@ -93,4 +98,7 @@ func (componentParams *ComponentParameters) setAutoScalingDefaults() {
 	if componentParams.MaxReplicas == nil {
 		componentParams.MaxReplicas = ptr.Int32(maxReplicas)
 	}
+	if componentParams.AutoscalingMode == BacklogAutoscaling && componentParams.TargetBacklog == nil {
+		componentParams.TargetBacklog = ptr.Int64(targetBacklog)
+	}
 }
@@ -67,7 +67,7 @@ func TestBrokerCell_SetDefaults(t *testing.T) {
 				},
 			},
 			Spec: BrokerCellSpec{
-				ComponentsParametersSpec{
+				Components: ComponentsParametersSpec{
 					Fanout: &ComponentParameters{
 						CPURequest:        fanoutSpecPrefix + customCPURequest,
 						CPULimit:          fanoutSpecPrefix + customCPULimit,
@@ -108,7 +108,7 @@ func TestBrokerCell_SetDefaults(t *testing.T) {
 				},
 			},
 			Spec: BrokerCellSpec{
-				ComponentsParametersSpec{
+				Components: ComponentsParametersSpec{
 					Fanout: &ComponentParameters{
 						CPURequest:        fanoutSpecPrefix + customCPURequest,
 						CPULimit:          fanoutSpecPrefix + customCPULimit,
@@ -146,7 +146,7 @@ func TestBrokerCell_SetDefaults(t *testing.T) {
 		name: "Defaulting for resource specification is not applied when some of the parameters are specified",
 		start: &BrokerCell{
 			Spec: BrokerCellSpec{
-				ComponentsParametersSpec{
+				Components: ComponentsParametersSpec{
 					Fanout: &ComponentParameters{
 						CPURequest: "10000",
 					},
@@ -155,7 +155,7 @@ func TestBrokerCell_SetDefaults(t *testing.T) {
 		},
 		want: &BrokerCell{
 			Spec: BrokerCellSpec{
-				ComponentsParametersSpec{
+				Components: ComponentsParametersSpec{
 					Fanout: (&ComponentParameters{
 						CPURequest:        "10000",
 						CPULimit:          "",
@@ -173,7 +173,7 @@ func TestBrokerCell_SetDefaults(t *testing.T) {
 		name: "Defaulting for resource specification is not applied when a target CPU or memory parameter is specified",
 		start: &BrokerCell{
 			Spec: BrokerCellSpec{
-				ComponentsParametersSpec{
+				Components: ComponentsParametersSpec{
 					Fanout: &ComponentParameters{
 						AvgCPUUtilization: ptr.Int32(95),
 					},
@@ -182,7 +182,7 @@ func TestBrokerCell_SetDefaults(t *testing.T) {
 		},
 		want: &BrokerCell{
 			Spec: BrokerCellSpec{
-				ComponentsParametersSpec{
+				Components: ComponentsParametersSpec{
 					Fanout: (&ComponentParameters{
 						AvgCPUUtilization: ptr.Int32(95),
 						AvgMemoryUsage:    nil,
@@ -196,6 +196,50 @@ func TestBrokerCell_SetDefaults(t *testing.T) {
 				},
 			},
 		},
+	}, {
+		name: "Target backlog is defaulted in backlog autoscaling mode",
+		start: &BrokerCell{
+			Spec: BrokerCellSpec{
+				Components: ComponentsParametersSpec{
+					Fanout: &ComponentParameters{
+						AutoscalingMode: BacklogAutoscaling,
+					},
+					Retry: &ComponentParameters{
+						AutoscalingMode: BacklogAutoscaling,
+						TargetBacklog:   ptr.Int64(100),
+					},
+				},
+			},
+		},
+		want: &BrokerCell{
+			Spec: BrokerCellSpec{
+				Components: ComponentsParametersSpec{
+					Fanout: (&ComponentParameters{
+						AutoscalingMode: BacklogAutoscaling,
+						TargetBacklog:   ptr.Int64(targetBacklog),
+					}).WithDefaultReplicas(),
+					Ingress: makeComponent(cpuRequestIngress, cpuLimitIngress, memoryRequestIngress, memoryLimitIngress, avgCPUUtilizationIngress, avgMemoryUsageIngress).WithDefaultReplicas(),
+					Retry: (&ComponentParameters{
+						AutoscalingMode: BacklogAutoscaling,
+						TargetBacklog:   ptr.Int64(100),
+					}).WithDefaultReplicas(),
+				},
+			},
+		},
+	}, {
+		name: "External ingress type is defaulted to LoadBalancer",
+		start: &BrokerCell{
+			Spec: BrokerCellSpec{
+				ExternalIngress: &ExternalIngressSpec{},
+			},
+		},
+		want: &BrokerCell{
+			Spec: func() BrokerCellSpec {
+				spec := MakeDefaultBrokerCellSpec()
+				spec.ExternalIngress = &ExternalIngressSpec{Type: LoadBalancerExternalIngress}
+				return spec
+			}(),
+		},
 	}}
 
 	for _, test := range tests {
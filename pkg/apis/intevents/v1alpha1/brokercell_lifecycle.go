@@ -18,6 +18,7 @@ package v1alpha1
 
 import (
 	appsv1 "k8s.io/api/apps/v1"
+	hpav2beta2 "k8s.io/api/autoscaling/v2beta2"
 	corev1 "k8s.io/api/core/v1"
 	"knative.dev/eventing/pkg/apis/duck"
 	"knative.dev/pkg/apis"
@@ -194,3 +195,41 @@ func (bs *BrokerCellStatus) MarkTargetsConfigFailed(reason, format string, args
 func (bs *BrokerCellStatus) SetIngressTemplate(address string) {
 	bs.IngressTemplate = address
 }
+
+// SetExternalIngressTemplate sets the IngressTemplate of the external ingress.
+func (bs *BrokerCellStatus) SetExternalIngressTemplate(address string) {
+	bs.ExternalIngressTemplate = address
+}
+
+// SetTenants sets the number of tenants served by the BrokerCell.
+func (bs *BrokerCellStatus) SetTenants(tenants TenantsStatus) {
+	bs.Tenants = tenants
+}
+
+// SetTargetsConfigSize sets the size in bytes of the targets ConfigMap.
+func (bs *BrokerCellStatus) SetTargetsConfigSize(size int64) {
+	bs.TargetsConfigSize = size
+}
+
+// PropagateIngressReplicas sets the replicas of the ingress from its Deployment and HPA.
+func (bs *BrokerCellStatus) PropagateIngressReplicas(d *appsv1.Deployment, hpa *hpav2beta2.HorizontalPodAutoscaler) {
+	bs.Components.Ingress = componentStatus(d, hpa)
+}
+
+// PropagateFanoutReplicas sets the replicas of the fanout from its Deployment and HPA.
+func (bs *BrokerCellStatus) PropagateFanoutReplicas(d *appsv1.Deployment, hpa *hpav2beta2.HorizontalPodAutoscaler) {
+	bs.Components.Fanout = componentStatus(d, hpa)
+}
+
+// PropagateRetryReplicas sets the replicas of the retry from its Deployment and HPA.
+func (bs *BrokerCellStatus) PropagateRetryReplicas(d *appsv1.Deployment, hpa *hpav2beta2.HorizontalPodAutoscaler) {
+	bs.Components.Retry = componentStatus(d, hpa)
+}
+
+func componentStatus(d *appsv1.Deployment, hpa *hpav2beta2.HorizontalPodAutoscaler) ComponentStatus {
+	return ComponentStatus{
+		Replicas:          d.Status.Replicas,
+		AvailableReplicas: d.Status.AvailableReplicas,
+		AtMaxReplicas:     hpa != nil && hpa.Spec.MaxReplicas > 0 && hpa.Status.DesiredReplicas >= hpa.Spec.MaxReplicas,
+	}
+}
@@ -22,6 +22,7 @@ import (
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	appsv1 "k8s.io/api/apps/v1"
+	hpav2beta2 "k8s.io/api/autoscaling/v2beta2"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"knative.dev/pkg/apis"
@@ -462,3 +463,47 @@ func TestPropagateDeploymentAvailability(t *testing.T) {
 		}
 	})
 }
+
+func TestPropagateReplicas(t *testing.T) {
+	d := &appsv1.Deployment{
+		Status: appsv1.DeploymentStatus{
+			Replicas:          3,
+			AvailableReplicas: 2,
+		},
+	}
+	hpa := func(desired int32) *hpav2beta2.HorizontalPodAutoscaler {
+		return &hpav2beta2.HorizontalPodAutoscaler{
+			Spec:   hpav2beta2.HorizontalPodAutoscalerSpec{MaxReplicas: 3},
+			Status: hpav2beta2.HorizontalPodAutoscalerStatus{DesiredReplicas: desired},
+		}
+	}
+
+	tests := []struct {
+		name string
+		hpa  *hpav2beta2.HorizontalPodAutoscaler
+		want ComponentStatus
+	}{{
+		name: "no hpa",
+		want: ComponentStatus{Replicas: 3, AvailableReplicas: 2},
+	}, {
+		name: "below max replicas",
+		hpa:  hpa(2),
+		want: ComponentStatus{Replicas: 3, AvailableReplicas: 2},
+	}, {
+		name: "at max replicas",
+		hpa:  hpa(3),
+		want: ComponentStatus{Replicas: 3, AvailableReplicas: 2, AtMaxReplicas: true},
+	}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := &BrokerCellStatus{}
+			s.PropagateIngressReplicas(d, test.hpa)
+			s.PropagateFanoutReplicas(d, test.hpa)
+			s.PropagateRetryReplicas(d, test.hpa)
+			want := ComponentsStatus{Ingress: test.want, Fanout: test.want, Retry: test.want}
+			if diff := cmp.Diff(want, s.Components); diff != "" {
+				t.Error("unexpected components status (-want, +got) =", diff)
+			}
+		})
+	}
+}
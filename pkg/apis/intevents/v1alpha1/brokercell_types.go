@@ -17,9 +17,11 @@ limitations under the License.
 package v1alpha1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"knative.dev/pkg/apis"
 	duckv1 "knative.dev/pkg/apis/duck/v1"
 	"knative.dev/pkg/kmeta"
@@ -83,6 +85,20 @@ type ResourceSpecification struct {
 	Limits SystemResource `json:"limits,omitempty"`
 }
 
+// AutoscalingMode selects the metrics driving the Horizontal Pod Autoscaler
+// of a BrokerCell component.
+type AutoscalingMode string
+
+const (
+	// ResourceAutoscaling scales the component on its CPU and memory usage.
+	ResourceAutoscaling AutoscalingMode = "resource"
+	// BacklogAutoscaling additionally scales the component on the number of
+	// undelivered messages in the Pub/Sub subscriptions it pulls from. It
+	// requires an external metrics provider serving Cloud Monitoring metrics,
+	// such as the Custom Metrics Stackdriver Adapter.
+	BacklogAutoscaling AutoscalingMode = "backlog"
+)
+
 // ComponentParameters specifies scaling and resource parameters to be used
 // by a single component of a BrokerCell.
 type ComponentParameters struct {
@@ -104,11 +120,59 @@ type ComponentParameters struct {
 	// MemoryLimit specifies the maximal amount of memory to be consumable by the deployment
 	MemoryLimit string `json:"memoryLimit,omitempty"`
 
+	// AutoscalingMode specifies the metrics the component's Horizontal Pod
+	// Autoscaler scales on, defaulting to resource autoscaling. Backlog
+	// autoscaling is only supported by the fanout and retry components.
+	AutoscalingMode AutoscalingMode `json:"autoscalingMode,omitempty"`
+
+	// TargetBacklog specifies the average number of undelivered messages per
+	// replica targeted by the component's Horizontal Pod Autoscaler in backlog
+	// autoscaling mode.
+	TargetBacklog *int64 `json:"targetBacklog,omitempty"`
+
 	// MinReplicas specifies the minimum replica count for the component.
 	MinReplicas *int32 `json:"minReplicas,omitempty"`
 
 	// MaxReplicas specifies the maximum replica count for the component.
 	MaxReplicas *int32 `json:"maxReplicas,omitempty"`
+
+	// MaxConcurrencyPerTarget specifies the bound of the adaptive limit of
+	// concurrent deliveries to each target. Deliveries are not limited if
+	// it is not specified. Only supported by the fanout and retry components.
+	MaxConcurrencyPerTarget *int32 `json:"maxConcurrencyPerTarget,omitempty"`
+
+	// PullWorkers specifies the number of workers pulling the subscriptions
+	// of all the Brokers or Triggers of the component. If it is not
+	// specified, the fanout pulls each decouple subscription with its own
+	// streaming pull. Only supported by the fanout and retry components.
+	PullWorkers *int32 `json:"pullWorkers,omitempty"`
+
+	// NodeSelector specifies the node labels the component's pods must be scheduled on.
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	// Tolerations specifies the node taints tolerated by the component's pods.
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+
+	// Affinity specifies the scheduling constraints of the component's pods.
+	Affinity *corev1.Affinity `json:"affinity,omitempty"`
+
+	// TopologySpreadConstraints specifies how the component's pods are spread
+	// across topology domains, e.g. zones. Constraints without a label
+	// selector select the pods of the component.
+	TopologySpreadConstraints []corev1.TopologySpreadConstraint `json:"topologySpreadConstraints,omitempty"`
+
+	// PriorityClassName specifies the priority class of the component's pods.
+	PriorityClassName string `json:"priorityClassName,omitempty"`
+
+	// MinAvailable specifies the number or percentage of the component's pods
+	// that must remain available during voluntary disruptions. A
+	// PodDisruptionBudget is only created for the component if either
+	// MinAvailable or MaxUnavailable is specified.
+	MinAvailable *intstr.IntOrString `json:"minAvailable,omitempty"`
+
+	// MaxUnavailable specifies the number or percentage of the component's pods
+	// that can be unavailable during voluntary disruptions.
+	MaxUnavailable *intstr.IntOrString `json:"maxUnavailable,omitempty"`
 }
 
 // ComponentsParametersSpec specifies separate parameters for each component
@@ -124,6 +188,61 @@ type BrokerCellSpec struct {
 	// Components specifies parameters of each component (fanout, ingress,
 	// retry) of a BrokerCell.
 	Components ComponentsParametersSpec `json:"components,omitempty"`
+
+	// ExternalIngress exposes the ingress of the BrokerCell outside of the
+	// cluster. When set, the address of the Brokers served by the BrokerCell
+	// is the external one, while the BrokerCell components keep using the
+	// in-cluster ingress.
+	// +optional
+	ExternalIngress *ExternalIngressSpec `json:"externalIngress,omitempty"`
+}
+
+// ExternalIngressType is how the ingress of a BrokerCell is exposed outside
+// of the cluster.
+type ExternalIngressType string
+
+const (
+	// LoadBalancerExternalIngress exposes the ingress with a Service of type
+	// LoadBalancer. TLS, if any, is terminated by the ingress.
+	LoadBalancerExternalIngress ExternalIngressType = "LoadBalancer"
+)
+
+// ExternalIngressSpec specifies how the ingress of a BrokerCell is exposed
+// outside of the cluster.
+type ExternalIngressSpec struct {
+	// Type is how the ingress is exposed. Only "LoadBalancer" is supported.
+	// Defaults to "LoadBalancer".
+	// +optional
+	Type ExternalIngressType `json:"type,omitempty"`
+
+	// HostTemplate is the external host of the Brokers. It may contain the
+	// variables `name` and `namespace` of the Broker.
+	// Example: "{name}.{namespace}.events.example.com"
+	// It defaults to the address of the load balancer.
+	// +optional
+	HostTemplate string `json:"hostTemplate,omitempty"`
+
+	// TLS serves the external ingress over HTTPS.
+	// +optional
+	TLS *ExternalIngressTLS `json:"tls,omitempty"`
+
+	// LoadBalancerSourceRanges are the CIDRs of the clients allowed to reach
+	// the load balancer. The ingress doesn't authenticate the publishers, so
+	// the ranges are required. "0.0.0.0/0" exposes the Brokers to anyone.
+	LoadBalancerSourceRanges []string `json:"loadBalancerSourceRanges"`
+
+	// Annotations are added to the LoadBalancer Service, e.g. to configure
+	// the load balancer of the cloud provider.
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// ExternalIngressTLS specifies the TLS certificate of the external ingress.
+type ExternalIngressTLS struct {
+	// SecretName is the name of a Secret of type kubernetes.io/tls, in the
+	// namespace of the BrokerCell, holding the certificate of the external
+	// host.
+	SecretName string `json:"secretName"`
 }
 
 // BrokerCellStatus represents the current state of a BrokerCell.
@@ -138,6 +257,59 @@ type BrokerCellStatus struct {
 	// `namespace`.
 	// Example: "http://broker-ingress.cloud-run-events.svc.cluster.local/{namespace}/{name}"
 	IngressTemplate string `json:"ingressTemplate,omitempty"`
+
+	// ExternalIngressTemplate is the IngressTemplate of the external ingress,
+	// if the BrokerCell exposes its ingress outside of the cluster and the
+	// external ingress is ready.
+	// Example: "https://{name}.{namespace}.events.example.com/{namespace}/{name}"
+	// +optional
+	ExternalIngressTemplate string `json:"externalIngressTemplate,omitempty"`
+
+	// Tenants counts the tenants served by the BrokerCell.
+	Tenants TenantsStatus `json:"tenants,omitempty"`
+
+	// TargetsConfigSize is the size in bytes of the BrokerCell's targets
+	// ConfigMap, which is limited to 1MiB.
+	TargetsConfigSize int64 `json:"targetsConfigSize,omitempty"`
+
+	// Components reports the replicas of each component of the BrokerCell.
+	Components ComponentsStatus `json:"components,omitempty"`
+}
+
+// TenantsStatus counts the tenants served by a BrokerCell.
+type TenantsStatus struct {
+	// Brokers is the number of Brokers served by the BrokerCell.
+	Brokers int32 `json:"brokers,omitempty"`
+
+	// Triggers is the number of Triggers of the served Brokers.
+	Triggers int32 `json:"triggers,omitempty"`
+
+	// Channels is the number of Channels served by the BrokerCell.
+	Channels int32 `json:"channels,omitempty"`
+
+	// Subscribers is the number of subscribers of the served Channels.
+	Subscribers int32 `json:"subscribers,omitempty"`
+}
+
+// ComponentStatus reports the replicas of a single component of a
+// BrokerCell.
+type ComponentStatus struct {
+	// Replicas is the number of pods of the component.
+	Replicas int32 `json:"replicas,omitempty"`
+
+	// AvailableReplicas is the number of available pods of the component.
+	AvailableReplicas int32 `json:"availableReplicas,omitempty"`
+
+	// AtMaxReplicas is true when the component's Horizontal Pod Autoscaler
+	// wants the maximum number of replicas or more.
+	AtMaxReplicas bool `json:"atMaxReplicas,omitempty"`
+}
+
+// ComponentsStatus reports the replicas of each component of a BrokerCell.
+type ComponentsStatus struct {
+	Fanout  ComponentStatus `json:"fanout,omitempty"`
+	Ingress ComponentStatus `json:"ingress,omitempty"`
+	Retry   ComponentStatus `json:"retry,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
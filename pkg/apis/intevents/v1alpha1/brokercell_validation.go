@@ -19,9 +19,12 @@ package v1alpha1
 import (
 	"context"
 	"fmt"
+	"net"
+	"strings"
 
 	resourceutil "github.com/google/knative-gcp/pkg/utils/resource"
 	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"knative.dev/pkg/apis"
 )
 
@@ -38,10 +41,63 @@ func (bcs *BrokerCellSpec) Validate(ctx context.Context) *apis.FieldError {
 	}
 	if bcs.Components.Ingress != nil {
 		fieldErrors = bcs.Components.Ingress.ValidateResourceRequirementSpecification(fieldErrors, "components.ingress")
+		// The ingress doesn't pull from any subscription.
+		if bcs.Components.Ingress.AutoscalingMode == BacklogAutoscaling {
+			invalidValueError := apis.ErrInvalidValue(bcs.Components.Ingress.AutoscalingMode, "autoscalingMode").ViaField("components.ingress")
+			invalidValueError.Details = "Backlog autoscaling is only supported by the fanout and retry components"
+			fieldErrors = fieldErrors.Also(invalidValueError)
+		}
+		// The ingress doesn't deliver events to targets.
+		if bcs.Components.Ingress.MaxConcurrencyPerTarget != nil {
+			fieldErrors = fieldErrors.Also(apis.ErrDisallowedFields("maxConcurrencyPerTarget").ViaField("components.ingress"))
+		}
+		if bcs.Components.Ingress.PullWorkers != nil {
+			fieldErrors = fieldErrors.Also(apis.ErrDisallowedFields("pullWorkers").ViaField("components.ingress"))
+		}
 	}
 	if bcs.Components.Retry != nil {
 		fieldErrors = bcs.Components.Retry.ValidateResourceRequirementSpecification(fieldErrors, "components.retry")
 	}
+	if bcs.ExternalIngress != nil {
+		fieldErrors = fieldErrors.Also(bcs.ExternalIngress.Validate(ctx).ViaField("externalIngress"))
+	}
+	return fieldErrors
+}
+
+func (ei *ExternalIngressSpec) Validate(_ context.Context) *apis.FieldError {
+	var fieldErrors *apis.FieldError
+	if ei.Type != LoadBalancerExternalIngress {
+		invalidValueError := apis.ErrInvalidValue(ei.Type, "type")
+		invalidValueError.Details = fmt.Sprintf("type should be %q", LoadBalancerExternalIngress)
+		fieldErrors = fieldErrors.Also(invalidValueError)
+	}
+	// The ingress doesn't authenticate the publishers, the load balancer must restrict its clients.
+	if len(ei.LoadBalancerSourceRanges) == 0 {
+		fieldErrors = fieldErrors.Also(apis.ErrMissingField("loadBalancerSourceRanges"))
+	}
+	for i, cidr := range ei.LoadBalancerSourceRanges {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			fieldErrors = fieldErrors.Also(apis.ErrInvalidArrayValue(cidr, "loadBalancerSourceRanges", i))
+		}
+	}
+	if ei.HostTemplate != "" {
+		// Expand the template with valid names to validate the rest of the host.
+		host := strings.NewReplacer("{name}", "name", "{namespace}", "namespace").Replace(ei.HostTemplate)
+		if errs := validation.IsDNS1123Subdomain(host); len(errs) > 0 {
+			invalidValueError := apis.ErrInvalidValue(ei.HostTemplate, "hostTemplate")
+			invalidValueError.Details = strings.Join(errs, ", ")
+			fieldErrors = fieldErrors.Also(invalidValueError)
+		}
+	}
+	if ei.TLS != nil {
+		// The certificate is issued for a host, not for the address of a load balancer.
+		if ei.HostTemplate == "" {
+			fieldErrors = fieldErrors.Also(apis.ErrMissingField("hostTemplate"))
+		}
+		if ei.TLS.SecretName == "" {
+			fieldErrors = fieldErrors.Also(apis.ErrMissingField("tls.secretName"))
+		}
+	}
 	return fieldErrors
 }
 
@@ -49,6 +105,15 @@ func (componentParams *ComponentParameters) ValidateResourceRequirementSpecifica
 	fieldErrors = componentParams.ValidateQuantityFormats(fieldErrors, componentPath)
 	fieldErrors = componentParams.ValidateResourceSpecification(fieldErrors, componentPath)
 	fieldErrors = componentParams.ValidateAutoscalingSpecification(fieldErrors, componentPath)
+	fieldErrors = componentParams.ValidateDisruptionBudgetSpecification(fieldErrors, componentPath)
+	return fieldErrors
+}
+
+func (componentParams *ComponentParameters) ValidateDisruptionBudgetSpecification(fieldErrors *apis.FieldError, componentPath string) *apis.FieldError {
+	// A PodDisruptionBudget can't specify both minAvailable and maxUnavailable
+	if componentParams.MinAvailable != nil && componentParams.MaxUnavailable != nil {
+		fieldErrors = fieldErrors.Also(apis.ErrMultipleOneOf("minAvailable", "maxUnavailable").ViaField(componentPath))
+	}
 	return fieldErrors
 }
 
@@ -89,14 +154,36 @@ func (componentParams *ComponentParameters) ValidateAutoscalingSpecification(fie
 			}
 		}
 	}
+	switch componentParams.AutoscalingMode {
+	case "", ResourceAutoscaling, BacklogAutoscaling:
+	default:
+		invalidValueError := apis.ErrInvalidValue(componentParams.AutoscalingMode, "autoscalingMode").ViaField(componentPath)
+		invalidValueError.Details = fmt.Sprintf("autoscalingMode should be either %q or %q", ResourceAutoscaling, BacklogAutoscaling)
+		fieldErrors = fieldErrors.Also(invalidValueError)
+	}
+	if componentParams.TargetBacklog != nil && *componentParams.TargetBacklog <= 0 {
+		invalidValueError := apis.ErrInvalidValue(*componentParams.TargetBacklog, "targetBacklog").ViaField(componentPath)
+		invalidValueError.Details = "targetBacklog should be positive"
+		fieldErrors = fieldErrors.Also(invalidValueError)
+	}
 	// At least one of the autoscaling metrics should be specified
 	// TODO: consider adjusting this rule (https://github.com/google/knative-gcp/issues/1632)
 	isAvgMemoryUsageSpecified := componentParams.AvgMemoryUsage != nil && *componentParams.AvgMemoryUsage != ""
-	if componentParams.AvgCPUUtilization == nil && !isAvgMemoryUsageSpecified {
+	if componentParams.AvgCPUUtilization == nil && !isAvgMemoryUsageSpecified && componentParams.AutoscalingMode != BacklogAutoscaling {
 		invalidValueError := apis.ErrInvalidValue(nil, componentPath)
 		invalidValueError.Details = "At least one of the autoscaling metrics (avgCPUUtilization, avgMemoryUsage) should be specified"
 		fieldErrors = fieldErrors.Also(invalidValueError)
 	}
+	if componentParams.MaxConcurrencyPerTarget != nil && *componentParams.MaxConcurrencyPerTarget <= 0 {
+		invalidValueError := apis.ErrInvalidValue(*componentParams.MaxConcurrencyPerTarget, "maxConcurrencyPerTarget").ViaField(componentPath)
+		invalidValueError.Details = "maxConcurrencyPerTarget should be positive"
+		fieldErrors = fieldErrors.Also(invalidValueError)
+	}
+	if componentParams.PullWorkers != nil && *componentParams.PullWorkers <= 0 {
+		invalidValueError := apis.ErrInvalidValue(*componentParams.PullWorkers, "pullWorkers").ViaField(componentPath)
+		invalidValueError.Details = "pullWorkers should be positive"
+		fieldErrors = fieldErrors.Also(invalidValueError)
+	}
 	if componentParams.MinReplicas != nil && componentParams.MaxReplicas != nil && *componentParams.MinReplicas > *componentParams.MaxReplicas {
 		invalidValueError := apis.ErrInvalidValue(*componentParams.MinReplicas, "minReplicas").ViaField(componentPath)
 		invalidValueError.Details = "minReplicas value can not exceed the value of maxReplicas"
@@ -18,9 +18,12 @@ package v1alpha1
 
 import (
 	"context"
+	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"knative.dev/pkg/apis"
 	"knative.dev/pkg/ptr"
 )
@@ -168,6 +171,193 @@ func TestBrokerCell_Validate(t *testing.T) {
 
 			}(),
 		},
+		{
+			name: "Backlog autoscaling is supported by fanout and retry",
+			brokerCell: BrokerCell{
+				Spec: (func() BrokerCellSpec {
+					brokerCellWithBacklogAutoscaling := MakeDefaultBrokerCellSpec()
+					brokerCellWithBacklogAutoscaling.Components.Fanout.AutoscalingMode = BacklogAutoscaling
+					brokerCellWithBacklogAutoscaling.Components.Fanout.AvgCPUUtilization = nil
+					brokerCellWithBacklogAutoscaling.Components.Fanout.AvgMemoryUsage = nil
+					brokerCellWithBacklogAutoscaling.Components.Retry.AutoscalingMode = BacklogAutoscaling
+					brokerCellWithBacklogAutoscaling.Components.Retry.TargetBacklog = ptr.Int64(100)
+					return brokerCellWithBacklogAutoscaling
+				}()),
+			},
+			want: nil,
+		},
+		{
+			name: "Backlog autoscaling is not supported by ingress",
+			brokerCell: BrokerCell{
+				Spec: (func() BrokerCellSpec {
+					brokerCellWithBacklogAutoscaling := MakeDefaultBrokerCellSpec()
+					brokerCellWithBacklogAutoscaling.Components.Ingress.AutoscalingMode = BacklogAutoscaling
+					return brokerCellWithBacklogAutoscaling
+				}()),
+			},
+			want: func() *apis.FieldError {
+				var fieldErrors *apis.FieldError
+				fe := apis.ErrInvalidValue(BacklogAutoscaling, "spec.components.ingress.autoscalingMode")
+				fe.Details = "Backlog autoscaling is only supported by the fanout and retry components"
+				fieldErrors = fieldErrors.Also(fe)
+				return fieldErrors
+			}(),
+		},
+		{
+			name: "Invalid autoscaling parameters are catched",
+			brokerCell: BrokerCell{
+				Spec: (func() BrokerCellSpec {
+					brokerCellWithInvalidAutoscaling := MakeDefaultBrokerCellSpec()
+					testComponent := brokerCellWithInvalidAutoscaling.Components.Fanout
+					testComponent.AutoscalingMode = "queue"
+					testComponent.TargetBacklog = ptr.Int64(0)
+					return brokerCellWithInvalidAutoscaling
+				}()),
+			},
+			want: func() *apis.FieldError {
+				var fieldErrors *apis.FieldError
+				modeFE := apis.ErrInvalidValue("queue", "spec.components.fanout.autoscalingMode")
+				modeFE.Details = `autoscalingMode should be either "resource" or "backlog"`
+				fieldErrors = fieldErrors.Also(modeFE)
+				backlogFE := apis.ErrInvalidValue(0, "spec.components.fanout.targetBacklog")
+				backlogFE.Details = "targetBacklog should be positive"
+				fieldErrors = fieldErrors.Also(backlogFE)
+				return fieldErrors
+			}(),
+		},
+		{
+			name: "Max concurrency per target is supported by fanout and retry",
+			brokerCell: BrokerCell{
+				Spec: (func() BrokerCellSpec {
+					brokerCellWithMaxConcurrency := MakeDefaultBrokerCellSpec()
+					brokerCellWithMaxConcurrency.Components.Fanout.MaxConcurrencyPerTarget = ptr.Int32(50)
+					brokerCellWithMaxConcurrency.Components.Retry.MaxConcurrencyPerTarget = ptr.Int32(10)
+					return brokerCellWithMaxConcurrency
+				}()),
+			},
+			want: nil,
+		},
+		{
+			name: "Invalid max concurrency per target is catched",
+			brokerCell: BrokerCell{
+				Spec: (func() BrokerCellSpec {
+					brokerCellWithMaxConcurrency := MakeDefaultBrokerCellSpec()
+					brokerCellWithMaxConcurrency.Components.Fanout.MaxConcurrencyPerTarget = ptr.Int32(0)
+					brokerCellWithMaxConcurrency.Components.Ingress.MaxConcurrencyPerTarget = ptr.Int32(10)
+					return brokerCellWithMaxConcurrency
+				}()),
+			},
+			want: func() *apis.FieldError {
+				var fieldErrors *apis.FieldError
+				fe := apis.ErrInvalidValue(0, "spec.components.fanout.maxConcurrencyPerTarget")
+				fe.Details = "maxConcurrencyPerTarget should be positive"
+				fieldErrors = fieldErrors.Also(fe)
+				fieldErrors = fieldErrors.Also(apis.ErrDisallowedFields("spec.components.ingress.maxConcurrencyPerTarget"))
+				return fieldErrors
+			}(),
+		},
+		{
+			name: "Pull workers are supported by fanout and retry",
+			brokerCell: BrokerCell{
+				Spec: (func() BrokerCellSpec {
+					brokerCellWithPullWorkers := MakeDefaultBrokerCellSpec()
+					brokerCellWithPullWorkers.Components.Fanout.PullWorkers = ptr.Int32(16)
+					brokerCellWithPullWorkers.Components.Retry.PullWorkers = ptr.Int32(4)
+					return brokerCellWithPullWorkers
+				}()),
+			},
+			want: nil,
+		},
+		{
+			name: "Invalid pull workers are catched",
+			brokerCell: BrokerCell{
+				Spec: (func() BrokerCellSpec {
+					brokerCellWithPullWorkers := MakeDefaultBrokerCellSpec()
+					brokerCellWithPullWorkers.Components.Retry.PullWorkers = ptr.Int32(-1)
+					brokerCellWithPullWorkers.Components.Ingress.PullWorkers = ptr.Int32(4)
+					return brokerCellWithPullWorkers
+				}()),
+			},
+			want: func() *apis.FieldError {
+				var fieldErrors *apis.FieldError
+				fieldErrors = fieldErrors.Also(apis.ErrDisallowedFields("spec.components.ingress.pullWorkers"))
+				fe := apis.ErrInvalidValue(-1, "spec.components.retry.pullWorkers")
+				fe.Details = "pullWorkers should be positive"
+				fieldErrors = fieldErrors.Also(fe)
+				return fieldErrors
+			}(),
+		},
+		{
+			name: "Only one of minAvailable and maxUnavailable can be specified",
+			brokerCell: BrokerCell{
+				Spec: (func() BrokerCellSpec {
+					brokerCellWithInvalidDisruptionBudget := MakeDefaultBrokerCellSpec()
+					testComponent := brokerCellWithInvalidDisruptionBudget.Components.Fanout
+					minAvailable := intstr.FromInt(1)
+					maxUnavailable := intstr.FromString("50%")
+					testComponent.MinAvailable = &minAvailable
+					testComponent.MaxUnavailable = &maxUnavailable
+					return brokerCellWithInvalidDisruptionBudget
+				}()),
+			},
+			want: apis.ErrMultipleOneOf("spec.components.fanout.minAvailable", "spec.components.fanout.maxUnavailable"),
+		},
+		{
+			name: "Valid external ingress",
+			brokerCell: BrokerCell{
+				Spec: (func() BrokerCellSpec {
+					brokerCellWithExternalIngress := MakeDefaultBrokerCellSpec()
+					brokerCellWithExternalIngress.ExternalIngress = &ExternalIngressSpec{
+						Type:                     LoadBalancerExternalIngress,
+						HostTemplate:             "{name}.{namespace}.events.example.com",
+						TLS:                      &ExternalIngressTLS{SecretName: "events-tls"},
+						LoadBalancerSourceRanges: []string{"203.0.113.0/24"},
+					}
+					return brokerCellWithExternalIngress
+				}()),
+			},
+			want: nil,
+		},
+		{
+			name: "Invalid external ingress is catched",
+			brokerCell: BrokerCell{
+				Spec: (func() BrokerCellSpec {
+					brokerCellWithExternalIngress := MakeDefaultBrokerCellSpec()
+					brokerCellWithExternalIngress.ExternalIngress = &ExternalIngressSpec{
+						Type:                     "NodePort",
+						HostTemplate:             "{name}_{namespace}.example.com",
+						TLS:                      &ExternalIngressTLS{},
+						LoadBalancerSourceRanges: []string{"203.0.113.0"},
+					}
+					return brokerCellWithExternalIngress
+				}()),
+			},
+			want: func() *apis.FieldError {
+				var fieldErrors *apis.FieldError
+				typeFE := apis.ErrInvalidValue("NodePort", "spec.externalIngress.type")
+				typeFE.Details = `type should be "LoadBalancer"`
+				fieldErrors = fieldErrors.Also(typeFE)
+				fieldErrors = fieldErrors.Also(apis.ErrInvalidArrayValue("203.0.113.0", "spec.externalIngress.loadBalancerSourceRanges", 0))
+				hostFE := apis.ErrInvalidValue("{name}_{namespace}.example.com", "spec.externalIngress.hostTemplate")
+				hostFE.Details = strings.Join(validation.IsDNS1123Subdomain("name_namespace.example.com"), ", ")
+				fieldErrors = fieldErrors.Also(hostFE)
+				fieldErrors = fieldErrors.Also(apis.ErrMissingField("spec.externalIngress.tls.secretName"))
+				return fieldErrors
+			}(),
+		},
+		{
+			name: "External ingress requires load balancer source ranges",
+			brokerCell: BrokerCell{
+				Spec: (func() BrokerCellSpec {
+					brokerCellWithExternalIngress := MakeDefaultBrokerCellSpec()
+					brokerCellWithExternalIngress.ExternalIngress = &ExternalIngressSpec{
+						Type: LoadBalancerExternalIngress,
+					}
+					return brokerCellWithExternalIngress
+				}()),
+			},
+			want: apis.ErrMissingField("spec.externalIngress.loadBalancerSourceRanges"),
+		},
 		{
 			name: "Empty quantities are supported",
 			brokerCell: BrokerCell{
@@ -21,7 +21,9 @@ limitations under the License.
 package v1alpha1
 
 import (
+	v1 "k8s.io/api/core/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
+	intstr "k8s.io/apimachinery/pkg/util/intstr"
 )
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
@@ -89,6 +91,11 @@ func (in *BrokerCellList) DeepCopyObject() runtime.Object {
 func (in *BrokerCellSpec) DeepCopyInto(out *BrokerCellSpec) {
 	*out = *in
 	in.Components.DeepCopyInto(&out.Components)
+	if in.ExternalIngress != nil {
+		in, out := &in.ExternalIngress, &out.ExternalIngress
+		*out = new(ExternalIngressSpec)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -106,6 +113,8 @@ func (in *BrokerCellSpec) DeepCopy() *BrokerCellSpec {
 func (in *BrokerCellStatus) DeepCopyInto(out *BrokerCellStatus) {
 	*out = *in
 	in.Status.DeepCopyInto(&out.Status)
+	out.Tenants = in.Tenants
+	out.Components = in.Components
 	return
 }
 
@@ -132,6 +141,11 @@ func (in *ComponentParameters) DeepCopyInto(out *ComponentParameters) {
 		*out = new(string)
 		**out = **in
 	}
+	if in.TargetBacklog != nil {
+		in, out := &in.TargetBacklog, &out.TargetBacklog
+		*out = new(int64)
+		**out = **in
+	}
 	if in.MinReplicas != nil {
 		in, out := &in.MinReplicas, &out.MinReplicas
 		*out = new(int32)
@@ -142,6 +156,52 @@ func (in *ComponentParameters) DeepCopyInto(out *ComponentParameters) {
 		*out = new(int32)
 		**out = **in
 	}
+	if in.MaxConcurrencyPerTarget != nil {
+		in, out := &in.MaxConcurrencyPerTarget, &out.MaxConcurrencyPerTarget
+		*out = new(int32)
+		**out = **in
+	}
+	if in.PullWorkers != nil {
+		in, out := &in.PullWorkers, &out.PullWorkers
+		*out = new(int32)
+		**out = **in
+	}
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Tolerations != nil {
+		in, out := &in.Tolerations, &out.Tolerations
+		*out = make([]v1.Toleration, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Affinity != nil {
+		in, out := &in.Affinity, &out.Affinity
+		*out = new(v1.Affinity)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.TopologySpreadConstraints != nil {
+		in, out := &in.TopologySpreadConstraints, &out.TopologySpreadConstraints
+		*out = make([]v1.TopologySpreadConstraint, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.MinAvailable != nil {
+		in, out := &in.MinAvailable, &out.MinAvailable
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
+	if in.MaxUnavailable != nil {
+		in, out := &in.MaxUnavailable, &out.MaxUnavailable
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
 	return
 }
 
@@ -155,6 +215,22 @@ func (in *ComponentParameters) DeepCopy() *ComponentParameters {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ComponentStatus) DeepCopyInto(out *ComponentStatus) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ComponentStatus.
+func (in *ComponentStatus) DeepCopy() *ComponentStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ComponentStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ComponentsParametersSpec) DeepCopyInto(out *ComponentsParametersSpec) {
 	*out = *in
@@ -186,6 +262,74 @@ func (in *ComponentsParametersSpec) DeepCopy() *ComponentsParametersSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ComponentsStatus) DeepCopyInto(out *ComponentsStatus) {
+	*out = *in
+	out.Fanout = in.Fanout
+	out.Ingress = in.Ingress
+	out.Retry = in.Retry
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ComponentsStatus.
+func (in *ComponentsStatus) DeepCopy() *ComponentsStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ComponentsStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ExternalIngressSpec) DeepCopyInto(out *ExternalIngressSpec) {
+	*out = *in
+	if in.TLS != nil {
+		in, out := &in.TLS, &out.TLS
+		*out = new(ExternalIngressTLS)
+		**out = **in
+	}
+	if in.LoadBalancerSourceRanges != nil {
+		in, out := &in.LoadBalancerSourceRanges, &out.LoadBalancerSourceRanges
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExternalIngressSpec.
+func (in *ExternalIngressSpec) DeepCopy() *ExternalIngressSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ExternalIngressSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ExternalIngressTLS) DeepCopyInto(out *ExternalIngressTLS) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExternalIngressTLS.
+func (in *ExternalIngressTLS) DeepCopy() *ExternalIngressTLS {
+	if in == nil {
+		return nil
+	}
+	out := new(ExternalIngressTLS)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ResourceSpecification) DeepCopyInto(out *ResourceSpecification) {
 	*out = *in
@@ -219,3 +363,19 @@ func (in *SystemResource) DeepCopy() *SystemResource {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TenantsStatus) DeepCopyInto(out *TenantsStatus) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TenantsStatus.
+func (in *TenantsStatus) DeepCopy() *TenantsStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(TenantsStatus)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -33,7 +33,8 @@ func (c *Channel) Validate(ctx context.Context) *apis.FieldError {
 		original := apis.GetBaseline(ctx).(*Channel)
 		err = err.Also(c.CheckImmutableFields(ctx, original))
 	}
-	return err
+	err = duck.ValidateAllowedPersistenceRegionsAnnotation(c.Annotations, err)
+	return duck.ValidateKMSKeyNameAnnotation(c.Annotations, err)
 }
 
 func (cs *ChannelSpec) Validate(ctx context.Context) *apis.FieldError {
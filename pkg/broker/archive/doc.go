@@ -0,0 +1,23 @@
+/*
+Copyright 2021 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package archive writes the events accepted by the Brokers to time-partitioned files in object
+// storage, and replays them for a time range.
+//
+// The events of a Broker are written under "<prefix>/<namespace>/<name>/", in hourly partitions
+// "dt=<yyyy-mm-dd>/hour=<hh>/" by their arrival time at the Broker. Each file is a gzip compressed
+// list of structured mode CloudEvents in JSON, one per line.
+package archive
@@ -0,0 +1,103 @@
+/*
+Copyright 2021 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+)
+
+const (
+	// partitionLayout is the layout of the hourly partitions of the archive files.
+	partitionLayout = "dt=2006-01-02/hour=15/"
+	// fileTimeLayout is the layout of the arrival time of the first event of a file, which starts
+	// its name so that the files of a partition are listed in order.
+	fileTimeLayout = "20060102T150405.000000000Z"
+	// fileExtension is the extension of the archive files.
+	fileExtension = ".ndjson.gz"
+)
+
+// BrokerPrefix returns the prefix of the names of the archive files of the Broker.
+func BrokerPrefix(prefix, namespace, name string) string {
+	return path.Join(prefix, namespace, name) + "/"
+}
+
+// partitionPrefix returns the prefix of the names of the archive files of the hourly partition of
+// the given time.
+func partitionPrefix(base string, t time.Time) string {
+	return base + t.UTC().Format(partitionLayout)
+}
+
+// fileName returns the name of an archive file whose first event arrived at the given time.
+func fileName(base string, first time.Time, id string) string {
+	return partitionPrefix(base, first) + first.UTC().Format(fileTimeLayout) + "-" + id + fileExtension
+}
+
+// encodeEvents encodes the events as a gzip compressed list of JSON CloudEvents, one per line.
+func encodeEvents(events []*event.Event) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	for _, e := range events {
+		b, err := json.Marshal(e)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode event %q: %w", e.ID(), err)
+		}
+		if _, err := zw.Write(append(b, '\n')); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeEvents decodes the events of an archive file.
+func decodeEvents(data []byte) ([]*event.Event, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	var events []*event.Event
+	r := bufio.NewReader(zr)
+	for {
+		line, err := r.ReadBytes('\n')
+		if len(strings.TrimSpace(string(line))) > 0 {
+			e := event.New()
+			if err := json.Unmarshal(line, &e); err != nil {
+				return nil, fmt.Errorf("failed to decode event %d: %w", len(events), err)
+			}
+			events = append(events, &e)
+		}
+		if err == io.EOF {
+			return events, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
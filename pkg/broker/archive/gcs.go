@@ -0,0 +1,86 @@
+/*
+Copyright 2021 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package archive
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"sort"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/iterator"
+)
+
+// GCSStore stores the archive files in Cloud Storage buckets. Retention policies on the buckets
+// keep the files from being deleted or replaced.
+type GCSStore struct {
+	client *storage.Client
+}
+
+var _ BlobStore = (*GCSStore)(nil)
+
+// NewGCSStore creates a GCSStore with the given Cloud Storage client.
+func NewGCSStore(client *storage.Client) *GCSStore {
+	return &GCSStore{client: client}
+}
+
+// Put writes the object if it doesn't exist.
+func (s *GCSStore) Put(ctx context.Context, bucket, name string, data []byte) error {
+	w := s.client.Bucket(bucket).Object(name).If(storage.Conditions{DoesNotExist: true}).NewWriter(ctx)
+	w.ContentType = "application/gzip"
+	if _, err := w.Write(data); err != nil {
+		w.Close()
+		return err
+	}
+	err := w.Close()
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) && apiErr.Code == http.StatusPreconditionFailed {
+		return ErrExists
+	}
+	return err
+}
+
+// Get reads the object.
+func (s *GCSStore) Get(ctx context.Context, bucket, name string) ([]byte, error) {
+	r, err := s.client.Bucket(bucket).Object(name).NewReader(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+// List lists the objects with the prefix.
+func (s *GCSStore) List(ctx context.Context, bucket, prefix string) ([]string, error) {
+	var names []string
+	it := s.client.Bucket(bucket).Objects(ctx, &storage.Query{Prefix: prefix})
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, attrs.Name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
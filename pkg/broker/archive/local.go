@@ -0,0 +1,104 @@
+/*
+Copyright 2021 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package archive
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// tempPrefix is the prefix of the files being written by LocalStore.
+const tempPrefix = ".tmp-"
+
+// LocalStore stores the archive files in the local file system, with a directory per bucket under
+// Root. It is meant for tests and local development.
+type LocalStore struct {
+	Root string
+}
+
+var _ BlobStore = (*LocalStore)(nil)
+
+// Put writes the file to a temporary file first, which is then linked to its name so that the
+// file is complete once visible and never replaced.
+func (s *LocalStore) Put(_ context.Context, bucket, name string, data []byte) error {
+	path := s.path(bucket, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), tempPrefix)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Link(tmp.Name(), path); err != nil {
+		if os.IsExist(err) {
+			return ErrExists
+		}
+		return err
+	}
+	return nil
+}
+
+// Get reads the file.
+func (s *LocalStore) Get(_ context.Context, bucket, name string) ([]byte, error) {
+	return ioutil.ReadFile(s.path(bucket, name))
+}
+
+// List walks the directory of the bucket for the files with the prefix.
+func (s *LocalStore) List(_ context.Context, bucket, prefix string) ([]string, error) {
+	root := filepath.Join(s.Root, bucket)
+	var names []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) && path == root {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || strings.HasPrefix(info.Name(), tempPrefix) {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if name := filepath.ToSlash(rel); strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (s *LocalStore) path(bucket, name string) string {
+	return filepath.Join(s.Root, bucket, filepath.FromSlash(name))
+}
@@ -0,0 +1,70 @@
+/*
+Copyright 2021 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package archive
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	cetypes "github.com/cloudevents/sdk-go/v2/types"
+
+	"github.com/google/knative-gcp/pkg/broker/eventutil"
+)
+
+// Replay reads the events archived under base in the bucket that arrived in [start, end), and
+// sends them in the order of their files. The events without an arrival time are sent if their
+// partition is in the range. The events keep their arrival time and are stamped with the time the
+// replay started, so that they are not archived again and don't expire right away. It returns the
+// number of events sent. Replay stops at the first error.
+func Replay(ctx context.Context, store BlobStore, bucket, base string, start, end time.Time, send func(context.Context, *event.Event) error) (int, error) {
+	replayedAt := cetypes.Timestamp{Time: time.Now()}
+	sent := 0
+	for hour := start.UTC().Truncate(time.Hour); hour.Before(end); hour = hour.Add(time.Hour) {
+		names, err := store.List(ctx, bucket, partitionPrefix(base, hour))
+		if err != nil {
+			return sent, fmt.Errorf("failed to list the archive files of %v: %w", hour, err)
+		}
+		for _, name := range names {
+			if !strings.HasSuffix(name, fileExtension) {
+				continue
+			}
+			data, err := store.Get(ctx, bucket, name)
+			if err != nil {
+				return sent, fmt.Errorf("failed to read archive file %q: %w", name, err)
+			}
+			events, err := decodeEvents(data)
+			if err != nil {
+				return sent, fmt.Errorf("failed to decode archive file %q: %w", name, err)
+			}
+			for _, e := range events {
+				arrival, ok := eventutil.ArrivalTime(e)
+				if ok && (arrival.Before(start) || !arrival.Before(end)) {
+					continue
+				}
+				e.SetExtension(eventutil.ReplayTimeExtension, replayedAt)
+				if err := send(ctx, e); err != nil {
+					return sent, fmt.Errorf("failed to send event %q of archive file %q: %w", e.ID(), name, err)
+				}
+				sent++
+			}
+		}
+	}
+	return sent, nil
+}
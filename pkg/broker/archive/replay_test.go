@@ -0,0 +1,98 @@
+/*
+Copyright 2021 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package archive
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/google/knative-gcp/pkg/broker/eventutil"
+)
+
+func TestReplay(t *testing.T) {
+	ctx := context.Background()
+	store := &LocalStore{Root: t.TempDir()}
+	base := BrokerPrefix("", "ns", "broker")
+	hour := time.Date(2021, 3, 4, 5, 0, 0, 0, time.UTC)
+
+	// Each event is written in its own file.
+	w := NewWriter(store, "bucket", base, 1, time.Hour)
+	for _, e := range []*event.Event{
+		newArchivedEvent("before", hour.Add(-time.Minute)),
+		newArchivedEvent("first", hour.Add(10*time.Minute)),
+		newArchivedEvent("second", hour.Add(20*time.Minute)),
+		newArchivedEvent("third", hour.Add(time.Hour+10*time.Minute)),
+		newArchivedEvent("after", hour.Add(time.Hour+30*time.Minute)),
+	} {
+		if err := w.Write(ctx, e); err != nil {
+			t.Fatalf("Write(%q) got error %v", e.ID(), err)
+		}
+	}
+	// Files of other Brokers are not replayed.
+	other := NewWriter(store, "bucket", BrokerPrefix("", "ns", "other"), 1, time.Hour)
+	if err := other.Write(ctx, newArchivedEvent("other", hour.Add(10*time.Minute))); err != nil {
+		t.Fatalf("Write() got error %v", err)
+	}
+
+	var got []string
+	replayStart := time.Now()
+	n, err := Replay(ctx, store, "bucket", base, hour.Add(5*time.Minute), hour.Add(time.Hour+20*time.Minute), func(_ context.Context, e *event.Event) error {
+		got = append(got, e.ID())
+		if replayedAt, ok := eventutil.ReplayTime(e); !ok || replayedAt.Before(replayStart.Truncate(time.Second)) {
+			t.Errorf("Replayed event %q got replay time %v, %v, want at least %v", e.ID(), replayedAt, ok, replayStart)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Replay() got error %v", err)
+	}
+	want := []string{"first", "second", "third"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Replayed events (-want, +got) = %s", diff)
+	}
+	if n != len(want) {
+		t.Errorf("Replay() got %d events sent, want %d", n, len(want))
+	}
+}
+
+func TestReplaySendError(t *testing.T) {
+	ctx := context.Background()
+	store := &LocalStore{Root: t.TempDir()}
+	now := time.Now()
+	w := NewWriter(store, "bucket", "", 1, time.Hour)
+	for _, id := range []string{"1", "2"} {
+		if err := w.Write(ctx, newArchivedEvent(id, now)); err != nil {
+			t.Fatalf("Write() got error %v", err)
+		}
+	}
+
+	wantErr := errors.New("send failed")
+	n, err := Replay(ctx, store, "bucket", "", now.Add(-time.Minute), now.Add(time.Minute), func(context.Context, *event.Event) error {
+		return wantErr
+	})
+	if !errors.Is(err, wantErr) {
+		t.Errorf("Replay() got error %v, want %v", err, wantErr)
+	}
+	if n != 0 {
+		t.Errorf("Replay() got %d events sent, want 0", n)
+	}
+}
@@ -0,0 +1,35 @@
+/*
+Copyright 2021 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package archive
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrExists is returned when writing an archive file that already exists.
+var ErrExists = errors.New("archive file already exists")
+
+// BlobStore stores the archive files. The files are immutable: they are only written once.
+type BlobStore interface {
+	// Put writes a file to the bucket. It returns ErrExists if the file already exists.
+	Put(ctx context.Context, bucket, name string, data []byte) error
+	// Get reads a file of the bucket.
+	Get(ctx context.Context, bucket, name string) ([]byte, error)
+	// List returns the names of the files of the bucket that start with prefix, in lexical order.
+	List(ctx context.Context, bucket, prefix string) ([]string, error)
+}
@@ -0,0 +1,157 @@
+/*
+Copyright 2021 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package archive
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/google/uuid"
+
+	"github.com/google/knative-gcp/pkg/broker/eventutil"
+)
+
+// writeTimeout bounds the writing of a batch of events, which is shared by all its events.
+const writeTimeout = time.Minute
+
+// pendingEvent is an event waiting for its batch to be written.
+type pendingEvent struct {
+	event   *event.Event
+	arrival time.Time
+	done    chan error
+}
+
+// Writer writes the events of a Broker to the archive. The events written concurrently are
+// batched in the same files, a file per hourly partition.
+type Writer struct {
+	store      BlobStore
+	bucket     string
+	base       string
+	maxEvents  int
+	flushDelay time.Duration
+
+	// now returns the current time. It is stubbed in tests.
+	now func() time.Time
+
+	mu      sync.Mutex
+	pending []*pendingEvent
+	timer   *time.Timer
+}
+
+// NewWriter creates a Writer of the archive files under base in the bucket. A batch is written
+// once it has maxEvents events, or flushDelay after its first event.
+func NewWriter(store BlobStore, bucket, base string, maxEvents int, flushDelay time.Duration) *Writer {
+	if maxEvents < 1 {
+		maxEvents = 1
+	}
+	return &Writer{
+		store:      store,
+		bucket:     bucket,
+		base:       base,
+		maxEvents:  maxEvents,
+		flushDelay: flushDelay,
+		now:        time.Now,
+	}
+}
+
+// Write adds the event to the next batch, and returns its error once the batch is written. The
+// event is archived in the partition of its arrival time at the Broker, or of the current time if
+// it has none.
+func (w *Writer) Write(ctx context.Context, e *event.Event) error {
+	arrival, ok := eventutil.ArrivalTime(e)
+	if !ok {
+		arrival = w.now()
+	}
+	pe := &pendingEvent{event: e, arrival: arrival, done: make(chan error, 1)}
+	w.mu.Lock()
+	w.pending = append(w.pending, pe)
+	switch {
+	case len(w.pending) >= w.maxEvents:
+		batch := w.takeLocked()
+		go w.flush(batch)
+	case len(w.pending) == 1:
+		w.timer = time.AfterFunc(w.flushDelay, w.flushPending)
+	}
+	w.mu.Unlock()
+
+	select {
+	case err := <-pe.done:
+		return err
+	case <-ctx.Done():
+		// The event may still be archived, it is archived again if it is redelivered.
+		return ctx.Err()
+	}
+}
+
+// takeLocked takes the pending events. w.mu must be held.
+func (w *Writer) takeLocked() []*pendingEvent {
+	if w.timer != nil {
+		w.timer.Stop()
+		w.timer = nil
+	}
+	batch := w.pending
+	w.pending = nil
+	return batch
+}
+
+func (w *Writer) flushPending() {
+	w.mu.Lock()
+	batch := w.takeLocked()
+	w.mu.Unlock()
+	if len(batch) > 0 {
+		w.flush(batch)
+	}
+}
+
+// flush writes the batch in a file per partition and returns each event the error of its file.
+func (w *Writer) flush(batch []*pendingEvent) {
+	ctx, cancel := context.WithTimeout(context.Background(), writeTimeout)
+	defer cancel()
+	partitions := make(map[string][]*pendingEvent)
+	var order []string
+	for _, pe := range batch {
+		p := partitionPrefix(w.base, pe.arrival)
+		if _, ok := partitions[p]; !ok {
+			order = append(order, p)
+		}
+		partitions[p] = append(partitions[p], pe)
+	}
+	for _, p := range order {
+		err := w.writeFile(ctx, partitions[p])
+		for _, pe := range partitions[p] {
+			pe.done <- err
+		}
+	}
+}
+
+func (w *Writer) writeFile(ctx context.Context, pes []*pendingEvent) error {
+	first := pes[0].arrival
+	events := make([]*event.Event, len(pes))
+	for i, pe := range pes {
+		events[i] = pe.event
+		if pe.arrival.Before(first) {
+			first = pe.arrival
+		}
+	}
+	data, err := encodeEvents(events)
+	if err != nil {
+		return err
+	}
+	return w.store.Put(ctx, w.bucket, fileName(w.base, first, uuid.New().String()), data)
+}
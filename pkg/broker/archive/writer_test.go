@@ -0,0 +1,150 @@
+/*
+Copyright 2021 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package archive
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+
+	"github.com/google/knative-gcp/pkg/broker/eventutil"
+)
+
+func newArchivedEvent(id string, arrival time.Time) *event.Event {
+	e := event.New()
+	e.SetID(id)
+	e.SetSource("source")
+	e.SetType("type")
+	e.SetExtension(eventutil.ArrivalTimeExtension, arrival)
+	if err := e.SetData(event.ApplicationJSON, map[string]string{"id": id}); err != nil {
+		panic(err)
+	}
+	return &e
+}
+
+func TestWriterPartitions(t *testing.T) {
+	ctx := context.Background()
+	store := &LocalStore{Root: t.TempDir()}
+	base := BrokerPrefix("events", "ns", "broker")
+	w := NewWriter(store, "bucket", base, 3, time.Hour)
+
+	hour := time.Date(2021, 3, 4, 5, 0, 0, 0, time.UTC)
+	events := []*event.Event{
+		newArchivedEvent("1", hour.Add(time.Minute)),
+		newArchivedEvent("2", hour.Add(2*time.Minute)),
+		newArchivedEvent("3", hour.Add(time.Hour+time.Minute)),
+	}
+	var wg sync.WaitGroup
+	for _, e := range events {
+		wg.Add(1)
+		go func(e *event.Event) {
+			defer wg.Done()
+			if err := w.Write(ctx, e); err != nil {
+				t.Errorf("Write(%q) got error %v", e.ID(), err)
+			}
+		}(e)
+	}
+	wg.Wait()
+
+	for _, p := range []struct {
+		hour time.Time
+		ids  []string
+	}{
+		{hour: hour, ids: []string{"1", "2"}},
+		{hour: hour.Add(time.Hour), ids: []string{"3"}},
+	} {
+		names, err := store.List(ctx, "bucket", partitionPrefix(base, p.hour))
+		if err != nil {
+			t.Fatalf("List() got error %v", err)
+		}
+		if len(names) != 1 {
+			t.Fatalf("Archive files of partition %v got %v, want one", p.hour, names)
+		}
+		data, err := store.Get(ctx, "bucket", names[0])
+		if err != nil {
+			t.Fatalf("Get(%q) got error %v", names[0], err)
+		}
+		got, err := decodeEvents(data)
+		if err != nil {
+			t.Fatalf("decodeEvents() got error %v", err)
+		}
+		var ids []string
+		for _, e := range got {
+			ids = append(ids, e.ID())
+		}
+		// The events written concurrently are in any order in their file.
+		if diff := cmp.Diff(p.ids, ids, cmpopts.SortSlices(func(a, b string) bool { return a < b })); diff != "" {
+			t.Errorf("Archived events of partition %v (-want, +got) = %s", p.hour, diff)
+		}
+	}
+}
+
+func TestWriterFlushDelay(t *testing.T) {
+	store := &LocalStore{Root: t.TempDir()}
+	w := NewWriter(store, "bucket", "", 100, 10*time.Millisecond)
+	if err := w.Write(context.Background(), newArchivedEvent("1", time.Now())); err != nil {
+		t.Fatalf("Write() got error %v", err)
+	}
+	names, err := store.List(context.Background(), "bucket", "")
+	if err != nil {
+		t.Fatalf("List() got error %v", err)
+	}
+	if len(names) != 1 {
+		t.Errorf("Archive files got %v, want one", names)
+	}
+}
+
+func TestWriterError(t *testing.T) {
+	wantErr := errors.New("put failed")
+	w := NewWriter(&failingStore{err: wantErr}, "bucket", "", 1, time.Hour)
+	if err := w.Write(context.Background(), newArchivedEvent("1", time.Now())); !errors.Is(err, wantErr) {
+		t.Errorf("Write() got error %v, want %v", err, wantErr)
+	}
+}
+
+func TestLocalStorePutExisting(t *testing.T) {
+	ctx := context.Background()
+	store := &LocalStore{Root: t.TempDir()}
+	if err := store.Put(ctx, "bucket", "a/b", []byte("first")); err != nil {
+		t.Fatalf("Put() got error %v", err)
+	}
+	if err := store.Put(ctx, "bucket", "a/b", []byte("second")); !errors.Is(err, ErrExists) {
+		t.Errorf("Put() of an existing file got error %v, want %v", err, ErrExists)
+	}
+	data, err := store.Get(ctx, "bucket", "a/b")
+	if err != nil {
+		t.Fatalf("Get() got error %v", err)
+	}
+	if string(data) != "first" {
+		t.Errorf("Get() got %q, want %q", data, "first")
+	}
+}
+
+type failingStore struct {
+	LocalStore
+	err error
+}
+
+func (s *failingStore) Put(context.Context, string, string, []byte) error {
+	return s.err
+}
@@ -26,6 +26,14 @@ import (
 // CachedTargets provides a in-memory cached copy of targets.
 type CachedTargets struct {
 	Value atomic.Value
+	// index holds the *indexedTargets of the last loaded TargetsConfig.
+	index atomic.Value
+}
+
+// indexedTargets is a TargetsConfig along with the index of its Targets.
+type indexedTargets struct {
+	config *TargetsConfig
+	index  targetIndex
 }
 
 var _ ReadonlyTargets = (*CachedTargets)(nil)
@@ -72,6 +80,31 @@ func (ct *CachedTargets) RangeAllTargets(f func(*Target) bool) {
 	}
 }
 
+// RangeCandidateTargets ranges over the targets of the CellTenant that may match an event with the
+// given attributes, without evaluating the filter of every target. The given targets must still
+// be checked against their filter.
+// Do not modify the given Target copy.
+func (ct *CachedTargets) RangeCandidateTargets(key *CellTenantKey, attrs map[string]interface{}, f func(*Target) bool) {
+	val := ct.Load()
+	if val == nil {
+		return
+	}
+	if idx, ok := ct.loadIndex(val)[key.PersistenceString()]; ok {
+		idx.rangeCandidates(attrs, f)
+	}
+}
+
+// loadIndex returns the index of the given TargetsConfig. The index is built the first time a
+// TargetsConfig is loaded, so that mutating the targets doesn't pay for rebuilding it.
+func (ct *CachedTargets) loadIndex(val *TargetsConfig) targetIndex {
+	if it, ok := ct.index.Load().(*indexedTargets); ok && it.config == val {
+		return it.index
+	}
+	idx := newTargetIndex(val)
+	ct.index.Store(&indexedTargets{config: val, index: idx})
+	return idx
+}
+
 // GetTargetByKey returns a target by its trigger key. The format of trigger key is namespace/brokerName/targetName.
 // Do not modify the returned Target copy.
 func (ct *CachedTargets) GetTargetByKey(key *TargetKey) (*Target, bool) {
@@ -21,6 +21,10 @@ type ReadonlyTargets interface {
 	// RangeAllTargets ranges over all targets.
 	// Do not modify the given Target copy.
 	RangeAllTargets(func(*Target) bool)
+	// RangeCandidateTargets ranges over the targets of a CellTenant that may match an event with
+	// the given attributes. The given targets must still be checked against their filter.
+	// Do not modify the given Target copy.
+	RangeCandidateTargets(key *CellTenantKey, attrs map[string]interface{}, f func(*Target) bool)
 	// GetTargetByKey returns a target by its trigger key, if it exists.
 	// Do not modify the returned Target copy.
 	GetTargetByKey(key *TargetKey) (*Target, bool)
@@ -48,6 +52,23 @@ type CellTenantMutation interface {
 	SetDecoupleQueue(q *Queue) CellTenantMutation
 	// SetState sets the CellTenant's state.
 	SetState(s State) CellTenantMutation
+	// SetIngressQuota sets the CellTenant's ingress admission quota.
+	SetIngressQuota(q *IngressQuota) CellTenantMutation
+	// SetIngressFilteringEnabled sets whether the ingress drops events that match no target.
+	SetIngressFilteringEnabled(enabled bool) CellTenantMutation
+	// SetIngressAsyncPublish sets whether the ingress accepts events once they are buffered.
+	SetIngressAsyncPublish(async bool) CellTenantMutation
+	// SetReplyLoopDetection sets whether the events that come back to a target whose subscriber
+	// replied with them are dead lettered.
+	SetReplyLoopDetection(enabled bool) CellTenantMutation
+	// SetArchive sets where the events of the CellTenant are archived, nil if they are not.
+	SetArchive(a *Archive) CellTenantMutation
+	// SetFederationQueues sets the subscriptions to the decouple queues of remote CellTenants whose
+	// events are fanned out to the targets of the CellTenant.
+	SetFederationQueues(queues ...*Queue) CellTenantMutation
+	// SetDelayQueue sets the queue the events scheduled for a later delivery wait in, nil if the
+	// CellTenant has none.
+	SetDelayQueue(q *Queue) CellTenantMutation
 	// UpsertTargets upserts Targets to the CellTenant.
 	// The targets' namespace, CellTenantType, and CellTenantName will be set to the CellTenant's
 	// value.
@@ -0,0 +1,107 @@
+/*
+Copyright 2021 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import "sort"
+
+// preferredIndexAttributes are the filter attributes Targets are preferably indexed by, as they
+// are the most commonly used and the most selective.
+var preferredIndexAttributes = []string{"type", "source"}
+
+// targetIndex indexes the Targets of each CellTenant by their filter attributes. It is keyed by
+// the CellTenant's PersistenceString().
+type targetIndex map[string]*cellTenantIndex
+
+// cellTenantIndex indexes the Targets of a CellTenant by one of their filter attributes. A Target
+// can only match events whose value of that attribute is the value in the Target's filter.
+type cellTenantIndex struct {
+	// byAttribute maps a filter attribute name to the filter value to the Targets indexed by that
+	// attribute.
+	byAttribute map[string]map[string][]*Target
+	// unindexed are the Targets without an exact match filter attribute. They may match any event.
+	unindexed []*Target
+}
+
+func newTargetIndex(tc *TargetsConfig) targetIndex {
+	idx := make(targetIndex, len(tc.GetCellTenants()))
+	for key, ct := range tc.GetCellTenants() {
+		idx[key] = newCellTenantIndex(ct)
+	}
+	return idx
+}
+
+func newCellTenantIndex(ct *CellTenant) *cellTenantIndex {
+	idx := &cellTenantIndex{byAttribute: make(map[string]map[string][]*Target)}
+	for _, t := range ct.Targets {
+		name, ok := indexAttribute(t.FilterAttributes)
+		if !ok {
+			idx.unindexed = append(idx.unindexed, t)
+			continue
+		}
+		values, ok := idx.byAttribute[name]
+		if !ok {
+			values = make(map[string][]*Target)
+			idx.byAttribute[name] = values
+		}
+		value := t.FilterAttributes[name]
+		values[value] = append(values[value], t)
+	}
+	return idx
+}
+
+// indexAttribute picks the filter attribute to index a Target by. An empty filter value matches
+// any value, so only attributes with a non-empty value can be used.
+func indexAttribute(attrs map[string]string) (string, bool) {
+	for _, name := range preferredIndexAttributes {
+		if attrs[name] != "" {
+			return name, true
+		}
+	}
+	names := make([]string, 0, len(attrs))
+	for name, value := range attrs {
+		if value != "" {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return "", false
+	}
+	// Pick deterministically so that Targets with the same filter share an index entry.
+	sort.Strings(names)
+	return names[0], true
+}
+
+// rangeCandidates ranges over the Targets that may match an event with the given attributes.
+func (idx *cellTenantIndex) rangeCandidates(attrs map[string]interface{}, f func(*Target) bool) {
+	for name, values := range idx.byAttribute {
+		// Filter values are strings, so they never match attributes of other types.
+		value, ok := attrs[name].(string)
+		if !ok {
+			continue
+		}
+		for _, t := range values[value] {
+			if !f(t) {
+				return
+			}
+		}
+	}
+	for _, t := range idx.unindexed {
+		if !f(t) {
+			return
+		}
+	}
+}
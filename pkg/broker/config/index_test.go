@@ -0,0 +1,129 @@
+/*
+Copyright 2021 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCachedTargetsRangeCandidateTargets(t *testing.T) {
+	broker := &CellTenant{
+		Type:      CellTenantType_BROKER,
+		Name:      "broker",
+		Namespace: "ns",
+		Targets: map[string]*Target{
+			"no-filter":    {Name: "no-filter"},
+			"any-type":     {Name: "any-type", FilterAttributes: map[string]string{"type": ""}},
+			"type-a":       {Name: "type-a", FilterAttributes: map[string]string{"type": "a"}},
+			"type-b":       {Name: "type-b", FilterAttributes: map[string]string{"type": "b"}},
+			"type-a-src-x": {Name: "type-a-src-x", FilterAttributes: map[string]string{"type": "a", "source": "x"}},
+			"src-x":        {Name: "src-x", FilterAttributes: map[string]string{"source": "x"}},
+			"ext-1":        {Name: "ext-1", FilterAttributes: map[string]string{"zext": "1", "aext": ""}},
+		},
+	}
+	other := &CellTenant{
+		Type:      CellTenantType_BROKER,
+		Name:      "other",
+		Namespace: "ns",
+		Targets: map[string]*Target{
+			"other-no-filter": {Name: "other-no-filter"},
+		},
+	}
+	targets := &CachedTargets{}
+	targets.Store(&TargetsConfig{
+		CellTenants: map[string]*CellTenant{
+			"ns/broker": broker,
+			"ns/other":  other,
+		},
+	})
+
+	tests := []struct {
+		name  string
+		key   *CellTenantKey
+		attrs map[string]interface{}
+		want  []string
+	}{{
+		name:  "type a from source x",
+		key:   broker.Key(),
+		attrs: map[string]interface{}{"type": "a", "source": "x"},
+		want:  []string{"any-type", "no-filter", "src-x", "type-a", "type-a-src-x"},
+	}, {
+		name:  "type b from source y",
+		key:   broker.Key(),
+		attrs: map[string]interface{}{"type": "b", "source": "y"},
+		want:  []string{"any-type", "no-filter", "type-b"},
+	}, {
+		name:  "extension",
+		key:   broker.Key(),
+		attrs: map[string]interface{}{"type": "c", "zext": "1"},
+		want:  []string{"any-type", "ext-1", "no-filter"},
+	}, {
+		name:  "non-string attribute",
+		key:   broker.Key(),
+		attrs: map[string]interface{}{"type": "c", "zext": int32(1)},
+		want:  []string{"any-type", "no-filter"},
+	}, {
+		name:  "other broker",
+		key:   other.Key(),
+		attrs: map[string]interface{}{"type": "a"},
+		want:  []string{"other-no-filter"},
+	}, {
+		name:  "unknown broker",
+		key:   TestOnlyBrokerKey("ns", "unknown"),
+		attrs: map[string]interface{}{"type": "a"},
+	}}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var got []string
+			targets.RangeCandidateTargets(tc.key, tc.attrs, func(t *Target) bool {
+				got = append(got, t.Name)
+				return true
+			})
+			sort.Strings(got)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("RangeCandidateTargets (-want,+got): %v", diff)
+			}
+		})
+	}
+
+	t.Run("index is rebuilt on store", func(t *testing.T) {
+		targets.Store(&TargetsConfig{
+			CellTenants: map[string]*CellTenant{
+				"ns/other": {
+					Type:      CellTenantType_BROKER,
+					Name:      "other",
+					Namespace: "ns",
+					Targets: map[string]*Target{
+						"other-type-a": {Name: "other-type-a", FilterAttributes: map[string]string{"type": "a"}},
+					},
+				},
+			},
+		})
+		var got []string
+		targets.RangeCandidateTargets(other.Key(), map[string]interface{}{"type": "a"}, func(t *Target) bool {
+			got = append(got, t.Name)
+			return true
+		})
+		if diff := cmp.Diff([]string{"other-type-a"}, got); diff != "" {
+			t.Errorf("RangeCandidateTargets (-want,+got): %v", diff)
+		}
+	})
+}
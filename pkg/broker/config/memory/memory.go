@@ -54,6 +54,48 @@ func (m *cellTenantMutation) SetState(s config.State) config.CellTenantMutation
 	return m
 }
 
+func (m *cellTenantMutation) SetIngressQuota(q *config.IngressQuota) config.CellTenantMutation {
+	m.delete = false
+	m.b.IngressQuota = q
+	return m
+}
+
+func (m *cellTenantMutation) SetIngressFilteringEnabled(enabled bool) config.CellTenantMutation {
+	m.delete = false
+	m.b.IngressFilteringEnabled = enabled
+	return m
+}
+
+func (m *cellTenantMutation) SetIngressAsyncPublish(async bool) config.CellTenantMutation {
+	m.delete = false
+	m.b.IngressAsyncPublish = async
+	return m
+}
+
+func (m *cellTenantMutation) SetReplyLoopDetection(enabled bool) config.CellTenantMutation {
+	m.delete = false
+	m.b.ReplyLoopDetection = enabled
+	return m
+}
+
+func (m *cellTenantMutation) SetArchive(a *config.Archive) config.CellTenantMutation {
+	m.delete = false
+	m.b.Archive = a
+	return m
+}
+
+func (m *cellTenantMutation) SetFederationQueues(queues ...*config.Queue) config.CellTenantMutation {
+	m.delete = false
+	m.b.FederationQueues = queues
+	return m
+}
+
+func (m *cellTenantMutation) SetDelayQueue(q *config.Queue) config.CellTenantMutation {
+	m.delete = false
+	m.b.DelayQueue = q
+	return m
+}
+
 func (m *cellTenantMutation) UpsertTargets(targets ...*config.Target) config.CellTenantMutation {
 	m.delete = false
 	if m.b.Targets == nil {
@@ -96,6 +96,90 @@ func TestMutateBroker(t *testing.T) {
 		assertBroker(t, wantBroker, targets)
 	})
 
+	t.Run("set and clear ingress quota", func(t *testing.T) {
+		wantBroker.IngressQuota = &config.IngressQuota{EventsPerSecond: 10, BytesInFlight: 1024}
+		targets.MutateCellTenant(wantBroker.Key(), func(m config.CellTenantMutation) {
+			m.SetIngressQuota(&config.IngressQuota{EventsPerSecond: 10, BytesInFlight: 1024})
+		})
+		assertBroker(t, wantBroker, targets)
+
+		wantBroker.IngressQuota = nil
+		targets.MutateCellTenant(wantBroker.Key(), func(m config.CellTenantMutation) {
+			m.SetIngressQuota(nil)
+		})
+		assertBroker(t, wantBroker, targets)
+	})
+
+	t.Run("set and clear ingress async publish", func(t *testing.T) {
+		wantBroker.IngressAsyncPublish = true
+		targets.MutateCellTenant(wantBroker.Key(), func(m config.CellTenantMutation) {
+			m.SetIngressAsyncPublish(true)
+		})
+		assertBroker(t, wantBroker, targets)
+
+		wantBroker.IngressAsyncPublish = false
+		targets.MutateCellTenant(wantBroker.Key(), func(m config.CellTenantMutation) {
+			m.SetIngressAsyncPublish(false)
+		})
+		assertBroker(t, wantBroker, targets)
+	})
+
+	t.Run("set and clear reply loop detection", func(t *testing.T) {
+		wantBroker.ReplyLoopDetection = true
+		targets.MutateCellTenant(wantBroker.Key(), func(m config.CellTenantMutation) {
+			m.SetReplyLoopDetection(true)
+		})
+		assertBroker(t, wantBroker, targets)
+
+		wantBroker.ReplyLoopDetection = false
+		targets.MutateCellTenant(wantBroker.Key(), func(m config.CellTenantMutation) {
+			m.SetReplyLoopDetection(false)
+		})
+		assertBroker(t, wantBroker, targets)
+	})
+
+	t.Run("set and clear archive", func(t *testing.T) {
+		wantBroker.Archive = &config.Archive{Bucket: "bucket", Prefix: "events", Subscription: "sub"}
+		targets.MutateCellTenant(wantBroker.Key(), func(m config.CellTenantMutation) {
+			m.SetArchive(&config.Archive{Bucket: "bucket", Prefix: "events", Subscription: "sub"})
+		})
+		assertBroker(t, wantBroker, targets)
+
+		wantBroker.Archive = nil
+		targets.MutateCellTenant(wantBroker.Key(), func(m config.CellTenantMutation) {
+			m.SetArchive(nil)
+		})
+		assertBroker(t, wantBroker, targets)
+	})
+
+	t.Run("set and clear federation queues", func(t *testing.T) {
+		wantBroker.FederationQueues = []*config.Queue{{Topic: "projects/other-project/topics/topic", Subscription: "sub"}}
+		targets.MutateCellTenant(wantBroker.Key(), func(m config.CellTenantMutation) {
+			m.SetFederationQueues(&config.Queue{Topic: "projects/other-project/topics/topic", Subscription: "sub"})
+		})
+		assertBroker(t, wantBroker, targets)
+
+		wantBroker.FederationQueues = nil
+		targets.MutateCellTenant(wantBroker.Key(), func(m config.CellTenantMutation) {
+			m.SetFederationQueues()
+		})
+		assertBroker(t, wantBroker, targets)
+	})
+
+	t.Run("set and clear delay queue", func(t *testing.T) {
+		wantBroker.DelayQueue = &config.Queue{Topic: "delay-topic", Subscription: "delay-sub"}
+		targets.MutateCellTenant(wantBroker.Key(), func(m config.CellTenantMutation) {
+			m.SetDelayQueue(&config.Queue{Topic: "delay-topic", Subscription: "delay-sub"})
+		})
+		assertBroker(t, wantBroker, targets)
+
+		wantBroker.DelayQueue = nil
+		targets.MutateCellTenant(wantBroker.Key(), func(m config.CellTenantMutation) {
+			m.SetDelayQueue(nil)
+		})
+		assertBroker(t, wantBroker, targets)
+	})
+
 	t1 := &config.Target{
 		Id:             "uid-1",
 		Address:        "consumer1.example.com",
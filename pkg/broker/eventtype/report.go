@@ -0,0 +1,243 @@
+/*
+Copyright 2021 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package eventtype contains the reports of the event types observed by the broker ingress. The
+// ingress Pods write them to a shared ConfigMap created by the BrokerCell controller, from which
+// the Broker controller discovers EventTypes.
+package eventtype
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// ReportsName is the name of the ConfigMap that holds the event type reports of all ingress
+	// Pods, keyed by Pod name. The data plane may only update this ConfigMap, so it is created by
+	// the controller.
+	ReportsName = "broker-eventtype-reports"
+
+	// TTL is how long an event type is kept after it was last observed. Reports not updated within
+	// the TTL, e.g. those of deleted Pods, are dropped.
+	TTL = 24 * time.Hour
+	// MaxReports bounds the number of reports kept in the ConfigMap. The least recently updated
+	// reports are dropped beyond it.
+	MaxReports = 50
+	// MaxReportsSize bounds the total size in bytes of the reports in the ConfigMap, well under the
+	// 1MiB limit of Kubernetes objects. The least recently updated reports are dropped beyond it.
+	MaxReportsSize = 512 * 1024
+)
+
+// Tuple identifies an event type.
+type Tuple struct {
+	Type   string `json:"type"`
+	Source string `json:"source,omitempty"`
+	Schema string `json:"schema,omitempty"`
+}
+
+// Observation is an event type observed on a Broker.
+type Observation struct {
+	Tuple
+	// Count is the number of events of this type observed.
+	Count int64 `json:"count"`
+	// LastSeen is when an event of this type was last observed.
+	LastSeen metav1.Time `json:"lastSeen"`
+}
+
+// Report holds the event types observed by a single ingress Pod.
+type Report struct {
+	// Brokers maps the PersistenceString of a Broker's CellTenantKey to the event types observed
+	// on that Broker.
+	Brokers map[string][]Observation `json:"brokers"`
+	// ReportedAt is when the report was last written.
+	ReportedAt metav1.Time `json:"reportedAt"`
+}
+
+// SetReport encodes the report of the given Pod into the ConfigMap's data. It drops the reports
+// not updated within the TTL and, if there are still more than MaxReports or they are larger than
+// MaxReportsSize, the least recently updated ones. A report larger than MaxReportsSize on its own
+// is trimmed of its least recently seen event types.
+func SetReport(cm *corev1.ConfigMap, podName string, r *Report) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if len(podName)+len(b) > MaxReportsSize {
+		if b, err = trim(r, MaxReportsSize-len(podName)); err != nil {
+			return err
+		}
+	}
+	if cm.Data == nil {
+		cm.Data = make(map[string]string, 1)
+	}
+	cm.Data[podName] = string(b)
+
+	notBefore := r.ReportedAt.Add(-TTL)
+	reportedAt := make(map[string]time.Time, len(cm.Data))
+	for name, data := range cm.Data {
+		other := &Report{}
+		if err := json.Unmarshal([]byte(data), other); err != nil || other.ReportedAt.Time.Before(notBefore) {
+			delete(cm.Data, name)
+			continue
+		}
+		reportedAt[name] = other.ReportedAt.Time
+	}
+	names := make([]string, 0, len(cm.Data))
+	for name := range cm.Data {
+		names = append(names, name)
+	}
+	// The Pod's own report is kept first, whatever the clocks of the other Pods say.
+	sort.Slice(names, func(i, j int) bool {
+		if (names[i] == podName) != (names[j] == podName) {
+			return names[i] == podName
+		}
+		if !reportedAt[names[i]].Equal(reportedAt[names[j]]) {
+			return reportedAt[names[i]].After(reportedAt[names[j]])
+		}
+		return names[i] < names[j]
+	})
+	size := 0
+	for i, name := range names {
+		size += len(name) + len(cm.Data[name])
+		if i >= MaxReports || size > MaxReportsSize {
+			delete(cm.Data, name)
+		}
+	}
+	return nil
+}
+
+// trim drops the least recently seen observations from the report until it encodes within
+// maxSize bytes, and returns the encoded report.
+func trim(r *Report, maxSize int) ([]byte, error) {
+	type entry struct {
+		broker string
+		o      Observation
+		size   int
+	}
+	var entries []entry
+	for broker, obs := range r.Brokers {
+		for _, o := range obs {
+			b, err := json.Marshal(o)
+			if err != nil {
+				return nil, err
+			}
+			// Account for the separating comma.
+			entries = append(entries, entry{broker: broker, o: o, size: len(b) + 1})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].o.LastSeen.Before(&entries[j].o.LastSeen)
+	})
+	for {
+		b, err := json.Marshal(r)
+		if err != nil || len(b) <= maxSize || len(entries) == 0 {
+			return b, err
+		}
+		excess := len(b) - maxSize
+		dropped := make(map[string]map[Tuple]bool)
+		for excess > 0 && len(entries) > 0 {
+			e := entries[0]
+			entries = entries[1:]
+			if dropped[e.broker] == nil {
+				dropped[e.broker] = make(map[Tuple]bool)
+			}
+			dropped[e.broker][e.o.Tuple] = true
+			excess -= e.size
+		}
+		for broker, tuples := range dropped {
+			kept := r.Brokers[broker][:0:0]
+			for _, o := range r.Brokers[broker] {
+				if !tuples[o.Tuple] {
+					kept = append(kept, o)
+				}
+			}
+			if len(kept) == 0 {
+				delete(r.Brokers, broker)
+			} else {
+				r.Brokers[broker] = kept
+			}
+		}
+	}
+}
+
+// ReportsFromConfigMap decodes the reports from the ConfigMap's data. Malformed reports are
+// skipped and returned in the error.
+func ReportsFromConfigMap(cm *corev1.ConfigMap) ([]*Report, error) {
+	reports := make([]*Report, 0, len(cm.Data))
+	var malformed []string
+	for name, data := range cm.Data {
+		r := &Report{}
+		if err := json.Unmarshal([]byte(data), r); err != nil {
+			malformed = append(malformed, name)
+			continue
+		}
+		reports = append(reports, r)
+	}
+	if len(malformed) > 0 {
+		sort.Strings(malformed)
+		return reports, fmt.Errorf("malformed event type reports %v in %s/%s", malformed, cm.Namespace, cm.Name)
+	}
+	return reports, nil
+}
+
+// Merge merges the observations of a Broker from all reports, dropping those last seen before
+// notBefore. The result is ordered by decreasing count.
+func Merge(reports []*Report, brokerKey string, notBefore time.Time) []Observation {
+	merged := make(map[Tuple]*Observation)
+	for _, r := range reports {
+		for _, o := range r.Brokers[brokerKey] {
+			if o.LastSeen.Time.Before(notBefore) {
+				continue
+			}
+			m, ok := merged[o.Tuple]
+			if !ok {
+				o := o
+				merged[o.Tuple] = &o
+				continue
+			}
+			m.Count += o.Count
+			if m.LastSeen.Before(&o.LastSeen) {
+				m.LastSeen = o.LastSeen
+			}
+		}
+	}
+	obs := make([]Observation, 0, len(merged))
+	for _, o := range merged {
+		obs = append(obs, *o)
+	}
+	sort.Slice(obs, func(i, j int) bool {
+		if obs[i].Count != obs[j].Count {
+			return obs[i].Count > obs[j].Count
+		}
+		return lessTuple(obs[i].Tuple, obs[j].Tuple)
+	})
+	return obs
+}
+
+func lessTuple(a, b Tuple) bool {
+	if a.Type != b.Type {
+		return a.Type < b.Type
+	}
+	if a.Source != b.Source {
+		return a.Source < b.Source
+	}
+	return a.Schema < b.Schema
+}
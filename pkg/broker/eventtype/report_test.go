@@ -0,0 +1,183 @@
+/*
+Copyright 2021 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package eventtype
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestReportConfigMapRoundTrip(t *testing.T) {
+	now := metav1.NewTime(time.Now().Truncate(time.Second))
+	want := &Report{
+		Brokers: map[string][]Observation{
+			"ns/broker": {{Tuple: Tuple{Type: "type", Source: "source"}, Count: 3, LastSeen: now}},
+		},
+		ReportedAt: now,
+	}
+	cm := &corev1.ConfigMap{}
+	if err := SetReport(cm, "pod", want); err != nil {
+		t.Fatalf("SetReport got error: %v", err)
+	}
+	got, err := ReportsFromConfigMap(cm)
+	if err != nil {
+		t.Fatalf("ReportsFromConfigMap got error: %v", err)
+	}
+	if diff := cmp.Diff([]*Report{want}, got); diff != "" {
+		t.Errorf("Report round trip (-want,+got): %v", diff)
+	}
+}
+
+func TestReportsFromConfigMapMalformed(t *testing.T) {
+	cm := &corev1.ConfigMap{Data: map[string]string{"malformed": "{", "pod": "{}"}}
+	reports, err := ReportsFromConfigMap(cm)
+	if err == nil {
+		t.Error("ReportsFromConfigMap got nil error, want error")
+	}
+	if len(reports) != 1 {
+		t.Errorf("ReportsFromConfigMap got %d reports, want the well-formed one", len(reports))
+	}
+}
+
+func TestSetReportDropsStaleReports(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	cm := &corev1.ConfigMap{}
+	if err := SetReport(cm, "expired", &Report{ReportedAt: metav1.NewTime(now.Add(-2 * TTL))}); err != nil {
+		t.Fatalf("SetReport got error: %v", err)
+	}
+	for i := 0; i < MaxReports+5; i++ {
+		r := &Report{ReportedAt: metav1.NewTime(now.Add(time.Duration(i-MaxReports-5) * time.Minute))}
+		if err := SetReport(cm, fmt.Sprintf("pod-%d", i), r); err != nil {
+			t.Fatalf("SetReport got error: %v", err)
+		}
+	}
+	if got := len(cm.Data); got != MaxReports {
+		t.Errorf("Reports got %d, want %d", got, MaxReports)
+	}
+	for _, name := range []string{"expired", "pod-0", "pod-4"} {
+		if _, ok := cm.Data[name]; ok {
+			t.Errorf("Report %q is kept, want it dropped", name)
+		}
+	}
+	if _, ok := cm.Data[fmt.Sprintf("pod-%d", MaxReports+4)]; !ok {
+		t.Error("The latest report is dropped")
+	}
+}
+
+func TestSetReportBoundsSize(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	largeReport := func(reportedAt time.Time, types int) *Report {
+		obs := make([]Observation, 0, types)
+		for i := 0; i < types; i++ {
+			obs = append(obs, Observation{
+				Tuple:    Tuple{Type: fmt.Sprintf("type-%05d", i), Source: "//source.example.com/a/fairly/long/source/path"},
+				Count:    1,
+				LastSeen: metav1.NewTime(reportedAt.Add(time.Duration(i) * time.Millisecond)),
+			})
+		}
+		return &Report{
+			Brokers:    map[string][]Observation{"ns/broker": obs},
+			ReportedAt: metav1.NewTime(reportedAt),
+		}
+	}
+	size := func(cm *corev1.ConfigMap) int {
+		size := 0
+		for name, data := range cm.Data {
+			size += len(name) + len(data)
+		}
+		return size
+	}
+
+	cm := &corev1.ConfigMap{}
+	for i := 0; i < 10; i++ {
+		if err := SetReport(cm, fmt.Sprintf("pod-%d", i), largeReport(now.Add(time.Duration(i)*time.Minute), 1000)); err != nil {
+			t.Fatalf("SetReport got error: %v", err)
+		}
+	}
+	if got := size(cm); got > MaxReportsSize {
+		t.Errorf("Reports size got %d, want at most %d", got, MaxReportsSize)
+	}
+	if _, ok := cm.Data["pod-0"]; ok {
+		t.Error("The earliest report is kept, want it dropped")
+	}
+	if _, ok := cm.Data["pod-9"]; !ok {
+		t.Error("The latest report is dropped")
+	}
+
+	// A report too large on its own keeps its most recently seen event types.
+	if err := SetReport(cm, "pod-9", largeReport(now.Add(time.Hour), 10000)); err != nil {
+		t.Fatalf("SetReport got error: %v", err)
+	}
+	if got := size(cm); got > MaxReportsSize {
+		t.Errorf("Reports size got %d, want at most %d", got, MaxReportsSize)
+	}
+	reports, err := ReportsFromConfigMap(cm)
+	if err != nil {
+		t.Fatalf("ReportsFromConfigMap got error: %v", err)
+	}
+	if len(reports) != 1 {
+		t.Fatalf("Reports got %d, want only the trimmed one", len(reports))
+	}
+	obs := reports[0].Brokers["ns/broker"]
+	if len(obs) == 0 || len(obs) == 10000 {
+		t.Fatalf("Trimmed report got %d event types, want some but not all of them", len(obs))
+	}
+	if got, want := obs[len(obs)-1].Type, "type-09999"; got != want {
+		t.Errorf("Last kept event type got %q, want the most recently seen %q", got, want)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	now := time.Now()
+	recent := metav1.NewTime(now.Add(-time.Minute))
+	latest := metav1.NewTime(now)
+	expired := metav1.NewTime(now.Add(-2 * TTL))
+	reports := []*Report{{
+		Brokers: map[string][]Observation{
+			"ns/broker": {
+				{Tuple: Tuple{Type: "a"}, Count: 1, LastSeen: recent},
+				{Tuple: Tuple{Type: "b"}, Count: 5, LastSeen: recent},
+				{Tuple: Tuple{Type: "expired"}, Count: 10, LastSeen: expired},
+			},
+			"ns/other": {
+				{Tuple: Tuple{Type: "other"}, Count: 10, LastSeen: recent},
+			},
+		},
+	}, {
+		Brokers: map[string][]Observation{
+			"ns/broker": {
+				{Tuple: Tuple{Type: "a"}, Count: 2, LastSeen: latest},
+				{Tuple: Tuple{Type: "a", Source: "source"}, Count: 3, LastSeen: recent},
+			},
+		},
+	}}
+
+	want := []Observation{
+		{Tuple: Tuple{Type: "b"}, Count: 5, LastSeen: recent},
+		{Tuple: Tuple{Type: "a"}, Count: 3, LastSeen: latest},
+		{Tuple: Tuple{Type: "a", Source: "source"}, Count: 3, LastSeen: recent},
+	}
+	got := Merge(reports, "ns/broker", now.Add(-TTL))
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Merge (-want,+got): %v", diff)
+	}
+}
@@ -0,0 +1,78 @@
+/*
+Copyright 2021 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package eventutil
+
+import (
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	cetypes "github.com/cloudevents/sdk-go/v2/types"
+)
+
+// ArrivalTimeExtension is the extension with the time the event arrived at the broker. It is set
+// by the broker ingress.
+const ArrivalTimeExtension = "knativearrivaltime"
+
+// ReplayTimeExtension is the extension with the time the replay of an archived event started. It
+// is set by the archive replay, which keeps the arrival time of the event, and stripped by the
+// broker ingress.
+const ReplayTimeExtension = "knativereplaytime"
+
+// Age returns the age of the event at the given time. It counts from the arrival of the event at
+// the broker, or from its scheduled delivery time or its replay time if later. It returns false if
+// the event has no valid arrival time.
+func Age(e *event.Event, now time.Time) (time.Duration, bool) {
+	since, ok := ArrivalTime(e)
+	if !ok {
+		return 0, false
+	}
+	if deliverAt, ok := DeliverAt(e); ok && deliverAt.After(since) {
+		since = deliverAt
+	}
+	if replayedAt, ok := ReplayTime(e); ok && replayedAt.After(since) {
+		since = replayedAt
+	}
+	return now.Sub(since), true
+}
+
+// ArrivalTime returns the time the event arrived at the broker. It returns false if the event has
+// no valid arrival time.
+func ArrivalTime(e *event.Event) (time.Time, bool) {
+	raw, ok := e.Extensions()[ArrivalTimeExtension]
+	if !ok {
+		return time.Time{}, false
+	}
+	t, err := cetypes.ToTime(raw)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// ReplayTime returns the time the replay of the archived event started. It returns false if the
+// event is not replayed or has no valid replay time.
+func ReplayTime(e *event.Event) (time.Time, bool) {
+	raw, ok := e.Extensions()[ReplayTimeExtension]
+	if !ok {
+		return time.Time{}, false
+	}
+	t, err := cetypes.ToTime(raw)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2021 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package eventutil
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+)
+
+func TestAge(t *testing.T) {
+	now := time.Date(2021, 1, 1, 1, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name       string
+		extensions map[string]interface{}
+		wantOK     bool
+		wantAge    time.Duration
+	}{{
+		name: "no arrival time",
+	}, {
+		name:       "invalid arrival time",
+		extensions: map[string]interface{}{ArrivalTimeExtension: "yesterday"},
+	}, {
+		name:       "arrival time",
+		extensions: map[string]interface{}{ArrivalTimeExtension: "2021-01-01T00:00:00Z"},
+		wantOK:     true,
+		wantAge:    time.Hour,
+	}, {
+		name: "scheduled delivery time",
+		extensions: map[string]interface{}{
+			ArrivalTimeExtension: "2021-01-01T00:00:00Z",
+			DeliverAtExtension:   "2021-01-01T00:50:00Z",
+		},
+		wantOK:  true,
+		wantAge: 10 * time.Minute,
+	}, {
+		name: "replay time",
+		extensions: map[string]interface{}{
+			ArrivalTimeExtension: "2020-12-01T00:00:00Z",
+			ReplayTimeExtension:  "2021-01-01T00:40:00Z",
+		},
+		wantOK:  true,
+		wantAge: 20 * time.Minute,
+	}}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := event.New()
+			for k, v := range tc.extensions {
+				e.SetExtension(k, v)
+			}
+			age, ok := Age(&e, now)
+			if ok != tc.wantOK {
+				t.Fatalf("Age got ok %v, want %v", ok, tc.wantOK)
+			}
+			if age != tc.wantAge {
+				t.Errorf("Age got %v, want %v", age, tc.wantAge)
+			}
+		})
+	}
+}
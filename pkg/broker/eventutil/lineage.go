@@ -0,0 +1,89 @@
+/*
+Copyright 2021 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package eventutil
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strings"
+
+	"github.com/cloudevents/sdk-go/v2/binding"
+	"github.com/cloudevents/sdk-go/v2/event"
+	cetypes "github.com/cloudevents/sdk-go/v2/types"
+)
+
+const (
+	// LineageAttribute is the extension with the targets whose subscribers replied with the event,
+	// or with the events it descends from, so that reply loops are detected as soon as an event
+	// comes back to a target it went through. Intentionally short, like the hops attribute.
+	LineageAttribute = "kgcplineage"
+
+	// maxLineage bounds the targets in the lineage of an event, so that the extension stays small.
+	// Only the most recent targets are kept.
+	maxLineage = 16
+	// lineageSeparator separates the targets in the lineage.
+	lineageSeparator = "."
+)
+
+// lineageID returns the compact ID of the target with the given ID in the lineage of events.
+func lineageID(targetID string) string {
+	h := fnv.New32a()
+	h.Write([]byte(targetID))
+	return fmt.Sprintf("%08x", h.Sum32())
+}
+
+// GetLineage returns the lineage of the event, if any.
+func GetLineage(e *event.Event) []string {
+	raw, ok := e.Extensions()[LineageAttribute]
+	if !ok {
+		return nil
+	}
+	v, err := cetypes.ToString(raw)
+	if err != nil || v == "" {
+		return nil
+	}
+	return strings.Split(v, lineageSeparator)
+}
+
+// InLineage returns whether the target with the given ID is in the lineage.
+func InLineage(lineage []string, targetID string) bool {
+	id := lineageID(targetID)
+	for _, l := range lineage {
+		if l == id {
+			return true
+		}
+	}
+	return false
+}
+
+// AppendLineage returns the lineage of the replies of the target with the given ID to an event
+// with the given lineage.
+func AppendLineage(lineage []string, targetID string) []string {
+	appended := append(append(make([]string, 0, len(lineage)+1), lineage...), lineageID(targetID))
+	if len(appended) > maxLineage {
+		appended = appended[len(appended)-maxLineage:]
+	}
+	return appended
+}
+
+// SetLineageTransformer sets the lineage of the event.
+type SetLineageTransformer []string
+
+func (l SetLineageTransformer) Transform(_ binding.MessageMetadataReader, out binding.MessageMetadataWriter) error {
+	out.SetExtension(LineageAttribute, strings.Join(l, lineageSeparator))
+	return nil
+}
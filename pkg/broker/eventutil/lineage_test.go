@@ -0,0 +1,69 @@
+/*
+Copyright 2021 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package eventutil
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/cloudevents/sdk-go/v2/binding"
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLineage(t *testing.T) {
+	e := event.New()
+	e.SetID("id")
+	e.SetSource("source")
+	e.SetType("type")
+	if got := GetLineage(&e); got != nil {
+		t.Errorf("lineage of an event without lineage got %v, want nil", got)
+	}
+
+	lineage := AppendLineage(GetLineage(&e), "trigger1")
+	lineage = AppendLineage(lineage, "trigger2")
+	reply, err := binding.ToEvent(context.Background(), binding.ToMessage(&e), SetLineageTransformer(lineage))
+	if err != nil {
+		t.Fatalf("failed to set the lineage: %v", err)
+	}
+	got := GetLineage(reply)
+	if diff := cmp.Diff(lineage, got); diff != "" {
+		t.Errorf("unexpected lineage (-want, +got) = %v", diff)
+	}
+	for id, want := range map[string]bool{"trigger1": true, "trigger2": true, "trigger3": false} {
+		if in := InLineage(got, id); in != want {
+			t.Errorf("InLineage(%q) got %v, want %v", id, in, want)
+		}
+	}
+}
+
+func TestLineageBounded(t *testing.T) {
+	var lineage []string
+	for i := 0; i < 2*maxLineage; i++ {
+		lineage = AppendLineage(lineage, fmt.Sprint(i))
+	}
+	if len(lineage) != maxLineage {
+		t.Errorf("lineage has %d targets, want %d", len(lineage), maxLineage)
+	}
+	if InLineage(lineage, "0") {
+		t.Error("lineage kept the oldest target")
+	}
+	if !InLineage(lineage, fmt.Sprint(2*maxLineage-1)) {
+		t.Error("lineage dropped the latest target")
+	}
+}
@@ -0,0 +1,64 @@
+/*
+Copyright 2021 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package eventutil
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	cetypes "github.com/cloudevents/sdk-go/v2/types"
+)
+
+const (
+	// RetryTargetAttribute is the extension with the ID of the target an event is retried for.
+	// It routes the retries published to the retry topic shared by the targets of a CellTenant to
+	// the retry subscription of the target. Intentionally short, like the hops attribute.
+	RetryTargetAttribute = "kgcptarget"
+	// RetryAtAttribute is the extension with the time, as an RFC 3339 timestamp, before which an
+	// event must not be retried, because the target requested a delay with the Retry-After header.
+	RetryAtAttribute = "kgcpretryat"
+
+	// pubsubExtensionPrefix is the prefix of the Pub/Sub attributes holding the CloudEvents
+	// attributes and extensions in binary mode.
+	pubsubExtensionPrefix = "ce-"
+)
+
+// RetryTargetFilter returns the Pub/Sub subscription filter selecting the retries of the target
+// with the given ID.
+func RetryTargetFilter(targetID string) string {
+	return fmt.Sprintf("attributes.%s = %s", strconv.Quote(pubsubExtensionPrefix+RetryTargetAttribute), strconv.Quote(targetID))
+}
+
+// RetryAt returns the time before which the event must not be retried, if there is a valid one.
+func RetryAt(e *event.Event) (time.Time, bool) {
+	raw, ok := e.Extensions()[RetryAtAttribute]
+	if !ok {
+		return time.Time{}, false
+	}
+	t, err := cetypes.ToTime(raw)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// SetRetryAt sets the time before which the event must not be retried.
+func SetRetryAt(e *event.Event, t time.Time) {
+	e.SetExtension(RetryAtAttribute, cetypes.Timestamp{Time: t})
+}
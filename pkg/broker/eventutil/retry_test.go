@@ -0,0 +1,48 @@
+/*
+Copyright 2021 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package eventutil
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+)
+
+func TestRetryTargetFilter(t *testing.T) {
+	want := `attributes."ce-kgcptarget" = "11186600-4003-4ad6-90e7-22780053debf"`
+	if got := RetryTargetFilter("11186600-4003-4ad6-90e7-22780053debf"); got != want {
+		t.Errorf("RetryTargetFilter got %q, want %q", got, want)
+	}
+}
+
+func TestRetryAt(t *testing.T) {
+	e := event.New()
+	if _, ok := RetryAt(&e); ok {
+		t.Error("RetryAt got a time for an event without one")
+	}
+	want := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	SetRetryAt(&e, want)
+	got, ok := RetryAt(&e)
+	if !ok || !got.Equal(want) {
+		t.Errorf("RetryAt got %v, %v, want %v, true", got, ok, want)
+	}
+	e.SetExtension(RetryAtAttribute, "tomorrow")
+	if _, ok := RetryAt(&e); ok {
+		t.Error("RetryAt got a time for an invalid extension")
+	}
+}
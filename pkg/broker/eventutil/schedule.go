@@ -0,0 +1,97 @@
+/*
+Copyright 2021 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package eventutil
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	cetypes "github.com/cloudevents/sdk-go/v2/types"
+	"github.com/rickb777/date/period"
+)
+
+const (
+	// DeliverAtExtension is the extension with the time, as an RFC 3339 timestamp, before which
+	// the event must not be delivered to the triggers.
+	DeliverAtExtension = "deliverat"
+	// DeliverAfterExtension is the extension with the delay, as an ISO 8601 duration, after the
+	// arrival of the event at the broker before which the event must not be delivered.
+	DeliverAfterExtension = "deliverafter"
+
+	// MaxDeliveryDelay bounds how far in the future the delivery of an event can be scheduled. The
+	// scheduled events are kept in the decouple subscription, so they must be delivered before the
+	// message retention duration of the subscription expires.
+	MaxDeliveryDelay = 7 * 24 * time.Hour
+)
+
+// ScheduleDelivery resolves the scheduled delivery time of an event arriving at the broker at the
+// given time. A delivery delay is converted to the absolute delivery time, so that it does not
+// depend on when the event is processed. It returns an error if the scheduled delivery time is
+// malformed or too far in the future.
+func ScheduleDelivery(e *event.Event, arrival time.Time) error {
+	exts := e.Extensions()
+	if raw, ok := exts[DeliverAfterExtension]; ok {
+		if _, ok := exts[DeliverAtExtension]; ok {
+			return fmt.Errorf("only one of the %s and %s extensions can be set", DeliverAtExtension, DeliverAfterExtension)
+		}
+		s, err := cetypes.ToString(raw)
+		if err != nil {
+			return fmt.Errorf("invalid %s extension: %w", DeliverAfterExtension, err)
+		}
+		p, err := period.Parse(s)
+		if err != nil {
+			return fmt.Errorf("invalid %s extension: %w", DeliverAfterExtension, err)
+		}
+		d, _ := p.Duration()
+		if d < 0 {
+			return fmt.Errorf("invalid %s extension: negative duration %q", DeliverAfterExtension, s)
+		}
+		e.SetExtension(DeliverAfterExtension, nil)
+		e.SetExtension(DeliverAtExtension, cetypes.Timestamp{Time: arrival.Add(d)})
+	}
+
+	deliverAt, ok, err := deliverAt(e)
+	if err != nil {
+		return err
+	}
+	if ok && deliverAt.After(arrival.Add(MaxDeliveryDelay)) {
+		return fmt.Errorf("delivery can be scheduled at most %v in the future", MaxDeliveryDelay)
+	}
+	return nil
+}
+
+// DeliverAt returns the scheduled delivery time of the event, if there is a valid one.
+func DeliverAt(e *event.Event) (time.Time, bool) {
+	t, ok, err := deliverAt(e)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, ok
+}
+
+func deliverAt(e *event.Event) (time.Time, bool, error) {
+	raw, ok := e.Extensions()[DeliverAtExtension]
+	if !ok {
+		return time.Time{}, false, nil
+	}
+	t, err := cetypes.ToTime(raw)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("invalid %s extension: %w", DeliverAtExtension, err)
+	}
+	return t, true, nil
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2021 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package eventutil
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+)
+
+func TestScheduleDelivery(t *testing.T) {
+	arrival := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name          string
+		extensions    map[string]interface{}
+		wantErr       bool
+		wantScheduled bool
+		wantDeliverAt time.Time
+	}{{
+		name: "not scheduled",
+	}, {
+		name:          "deliver at",
+		extensions:    map[string]interface{}{DeliverAtExtension: "2021-01-01T01:00:00Z"},
+		wantScheduled: true,
+		wantDeliverAt: arrival.Add(time.Hour),
+	}, {
+		name:          "deliver after",
+		extensions:    map[string]interface{}{DeliverAfterExtension: "PT90S"},
+		wantScheduled: true,
+		wantDeliverAt: arrival.Add(90 * time.Second),
+	}, {
+		name:       "invalid deliver at",
+		extensions: map[string]interface{}{DeliverAtExtension: "tomorrow"},
+		wantErr:    true,
+	}, {
+		name:       "invalid deliver after",
+		extensions: map[string]interface{}{DeliverAfterExtension: "90s"},
+		wantErr:    true,
+	}, {
+		name:       "negative deliver after",
+		extensions: map[string]interface{}{DeliverAfterExtension: "-PT90S"},
+		wantErr:    true,
+	}, {
+		name: "both deliver at and after",
+		extensions: map[string]interface{}{
+			DeliverAtExtension:    "2021-01-01T01:00:00Z",
+			DeliverAfterExtension: "PT90S",
+		},
+		wantErr: true,
+	}, {
+		name:       "too far in the future",
+		extensions: map[string]interface{}{DeliverAfterExtension: "P8D"},
+		wantErr:    true,
+	}}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := event.New()
+			for k, v := range tc.extensions {
+				e.SetExtension(k, v)
+			}
+			err := ScheduleDelivery(&e, arrival)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("ScheduleDelivery got error %v, want error %v", err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+			if _, ok := e.Extensions()[DeliverAfterExtension]; ok {
+				t.Errorf("ScheduleDelivery did not replace the %s extension", DeliverAfterExtension)
+			}
+			deliverAt, ok := DeliverAt(&e)
+			if ok != tc.wantScheduled {
+				t.Fatalf("DeliverAt got scheduled %v, want %v", ok, tc.wantScheduled)
+			}
+			if !deliverAt.Equal(tc.wantDeliverAt) {
+				t.Errorf("DeliverAt got %v, want %v", deliverAt, tc.wantDeliverAt)
+			}
+		})
+	}
+}
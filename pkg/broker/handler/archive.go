@@ -0,0 +1,128 @@
+/*
+Copyright 2021 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"context"
+	"sync"
+
+	"cloud.google.com/go/pubsub"
+	"go.uber.org/zap"
+
+	"github.com/google/knative-gcp/pkg/broker/archive"
+	"github.com/google/knative-gcp/pkg/broker/config"
+	handlerctx "github.com/google/knative-gcp/pkg/broker/handler/context"
+	archiveprocessor "github.com/google/knative-gcp/pkg/broker/handler/processors/archive"
+	"github.com/google/knative-gcp/pkg/logging"
+)
+
+// archivePool runs the archive handlers of the Brokers whose events are archived. Each handler
+// pulls the archive subscription of its Broker, which receives the same events as the decouple
+// subscription.
+type archivePool struct {
+	options      *Options
+	targets      config.ReadonlyTargets
+	pubsubClient *pubsub.Client
+	store        archive.BlobStore
+
+	pool sync.Map
+	// running tracks the handlers until they are stopped and drained.
+	running sync.WaitGroup
+}
+
+type archiveHandlerCache struct {
+	Handler
+	archive *config.Archive
+}
+
+// shouldRenew returns whether the handler must be renewed for the current archive settings.
+func (hc *archiveHandlerCache) shouldRenew(a *config.Archive) bool {
+	return !hc.IsAlive() ||
+		a.Bucket != hc.archive.Bucket ||
+		a.Prefix != hc.archive.Prefix ||
+		a.Subscription != hc.archive.Subscription
+}
+
+func newArchivePool(targets config.ReadonlyTargets, pubsubClient *pubsub.Client, options *Options) *archivePool {
+	return &archivePool{
+		options:      options,
+		targets:      targets,
+		pubsubClient: pubsubClient,
+		store:        options.ArchiveStore,
+	}
+}
+
+// syncOnce starts the archive handlers of the Brokers whose events are archived, and stops the
+// others.
+func (p *archivePool) syncOnce(ctx context.Context) {
+	p.pool.Range(func(k, v interface{}) bool {
+		key := k.(config.CellTenantKey)
+		if b, ok := p.targets.GetCellTenantByKey(&key); !ok || b.Archive == nil {
+			v.(*archiveHandlerCache).Stop()
+			p.pool.Delete(key)
+		}
+		return true
+	})
+
+	p.targets.RangeCellTenants(func(b *config.CellTenant) bool {
+		if b.Archive == nil || b.State != config.State_READY {
+			return true
+		}
+		if v, ok := p.pool.Load(*b.Key()); ok {
+			if !v.(*archiveHandlerCache).shouldRenew(b.Archive) {
+				return true
+			}
+			v.(*archiveHandlerCache).Stop()
+			p.pool.Delete(*b.Key())
+		}
+
+		sub := p.pubsubClient.Subscription(b.Archive.Subscription)
+		sub.ReceiveSettings = p.options.PubsubReceiveSettings
+		writer := archive.NewWriter(p.store, b.Archive.Bucket, archive.BrokerPrefix(b.Archive.Prefix, b.Namespace, b.Name),
+			p.options.ArchiveMaxEvents, p.options.ArchiveFlushDelay)
+		h := NewHandler(sub, &archiveprocessor.Processor{Writer: writer}, p.options.TimeoutPerEvent)
+		h.Scheduler = p.options.PullScheduler
+		h.DrainTimeout = p.options.DrainTimeout
+		hc := &archiveHandlerCache{
+			Handler: *h,
+			archive: b.Archive,
+		}
+
+		bk := b.Key()
+		p.running.Add(1)
+		hc.Start(handlerctx.WithBrokerKey(ctx, bk), func(err error) {
+			defer p.running.Done()
+			if err != nil {
+				logging.FromContext(ctx).Error("archive handler for broker has stopped with error", zap.Stringer("broker", bk), zap.Error(err))
+			} else {
+				logging.FromContext(ctx).Info("archive handler for broker has stopped", zap.Stringer("broker", bk))
+			}
+		})
+		p.pool.Store(*bk, hc)
+		return true
+	})
+}
+
+// drain stops all the archive handlers and waits for their in-flight events to be archived, or
+// the context to be done.
+func (p *archivePool) drain(ctx context.Context) error {
+	p.pool.Range(func(_, v interface{}) bool {
+		v.(*archiveHandlerCache).Stop()
+		return true
+	})
+	return waitDrained(ctx, &p.running)
+}
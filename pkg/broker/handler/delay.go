@@ -0,0 +1,102 @@
+/*
+Copyright 2021 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"context"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"github.com/google/knative-gcp/pkg/broker/config"
+	handlerctx "github.com/google/knative-gcp/pkg/broker/handler/context"
+	"github.com/google/knative-gcp/pkg/logging"
+)
+
+// delayPool runs the handlers of the delay subscriptions of the brokers. The events scheduled for
+// a later delivery wait there, rather than in the decouple subscriptions, until they are due, and
+// are then fanned out to the targets of their broker.
+type delayPool struct {
+	fanout *FanoutPool
+	pool   sync.Map
+}
+
+type delayHandlerCache struct {
+	Handler
+	subscription string
+}
+
+func newDelayPool(fanout *FanoutPool) *delayPool {
+	return &delayPool{fanout: fanout}
+}
+
+// syncOnce starts the handlers of the ready delay queues of the ready brokers, and stops the
+// others.
+func (p *delayPool) syncOnce(ctx context.Context) {
+	wanted := make(map[config.CellTenantKey]*config.Queue)
+	p.fanout.targets.RangeCellTenants(func(b *config.CellTenant) bool {
+		if b.State == config.State_READY && b.DelayQueue != nil && b.DelayQueue.State == config.State_READY {
+			wanted[*b.Key()] = b.DelayQueue
+		}
+		return true
+	})
+
+	p.pool.Range(func(k, v interface{}) bool {
+		hc := v.(*delayHandlerCache)
+		if q, ok := wanted[k.(config.CellTenantKey)]; !ok || !hc.IsAlive() || q.Subscription != hc.subscription {
+			hc.Stop()
+			p.pool.Delete(k)
+		}
+		return true
+	})
+
+	for key, q := range wanted {
+		if _, ok := p.pool.Load(key); ok {
+			continue
+		}
+		sub := p.fanout.pubsubClient.Subscription(q.Subscription)
+		sub.ReceiveSettings = p.fanout.options.PubsubReceiveSettings
+		h := NewHandler(sub, p.fanout.newDelayProcessor(), p.fanout.options.TimeoutPerEvent)
+		h.Scheduler = p.fanout.options.PullScheduler
+		h.DrainTimeout = p.fanout.options.DrainTimeout
+		h.StatsReporter = p.fanout.statsReporter
+		hc := &delayHandlerCache{
+			Handler:      *h,
+			subscription: q.Subscription,
+		}
+
+		bk := key
+		p.fanout.running.Add(1)
+		hc.Start(handlerctx.WithBrokerKey(ctx, &bk), func(err error) {
+			defer p.fanout.running.Done()
+			if err != nil {
+				logging.FromContext(ctx).Error("delay handler for broker has stopped with error", zap.Stringer("broker", &bk), zap.Error(err))
+			} else {
+				logging.FromContext(ctx).Info("delay handler for broker has stopped", zap.Stringer("broker", &bk))
+			}
+		})
+		p.pool.Store(key, hc)
+	}
+}
+
+// stop stops all the delay handlers. They are drained with the fanout handlers.
+func (p *delayPool) stop() {
+	p.pool.Range(func(_, v interface{}) bool {
+		v.(*delayHandlerCache).Stop()
+		return true
+	})
+}
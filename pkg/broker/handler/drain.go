@@ -0,0 +1,99 @@
+/*
+Copyright 2021 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// detachedContext carries the values of its parent but is not canceled with
+// it, so that in-flight events keep being processed once their handler stops
+// pulling.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
+
+func (c detachedContext) Value(key interface{}) interface{} {
+	return c.parent.Value(key)
+}
+
+// detach returns a context with the values of ctx that is never canceled.
+func detach(ctx context.Context) context.Context {
+	return detachedContext{parent: ctx}
+}
+
+// waitDrained waits for the handlers tracked by the wait group to be drained,
+// or the context to be done.
+func waitDrained(ctx context.Context, handlers *sync.WaitGroup) error {
+	drained := make(chan struct{})
+	go func() {
+		handlers.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// inflightEvents tracks the events being processed by a handler.
+type inflightEvents struct {
+	mu sync.Mutex
+	n  int
+	// idle is closed once no event is being processed.
+	idle chan struct{}
+}
+
+func newInflightEvents() *inflightEvents {
+	idle := make(chan struct{})
+	close(idle)
+	return &inflightEvents{idle: idle}
+}
+
+func (e *inflightEvents) add() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.n == 0 {
+		e.idle = make(chan struct{})
+	}
+	e.n++
+}
+
+func (e *inflightEvents) done() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.n--
+	if e.n == 0 {
+		close(e.idle)
+	}
+}
+
+// count returns the number of events being processed, and a channel closed
+// once there is none.
+func (e *inflightEvents) count() (int, <-chan struct{}) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.n, e.idle
+}
@@ -34,6 +34,7 @@ import (
 	"github.com/google/knative-gcp/pkg/broker/handler/processors/deliver"
 	"github.com/google/knative-gcp/pkg/broker/handler/processors/fanout"
 	"github.com/google/knative-gcp/pkg/broker/handler/processors/filter"
+	"github.com/google/knative-gcp/pkg/broker/handler/processors/schedule"
 	"github.com/google/knative-gcp/pkg/metrics"
 )
 
@@ -61,6 +62,29 @@ type FanoutPool struct {
 	// And we can set target address dynamically.
 	deliverClient *http.Client
 	statsReporter *metrics.DeliveryReporter
+	// Adaptive concurrency limiters of the targets, nil if deliveries are not limited.
+	limiters *deliver.ConcurrencyLimiters
+
+	// Values of the delivery headers read from the mounted Secret, nil if it is not mounted.
+	headerSecrets *deliver.HeaderSecrets
+
+	// Publishers of the targets that are Pub/Sub topics.
+	topics *deliver.Topics
+
+	// Batchers of the targets that are BigQuery tables, nil if no BigQuery client is configured.
+	tables *deliver.Tables
+
+	// Handlers archiving the events of the Brokers, nil if no archive store is configured.
+	archive *archivePool
+
+	// Handlers fanning out the events of the remote Brokers federated by the Brokers.
+	federation *federationPool
+
+	// Handlers fanning out the scheduled events of the Brokers once they are due.
+	delay *delayPool
+
+	// running tracks the handlers until they are stopped and drained.
+	running sync.WaitGroup
 }
 
 type fanoutHandlerCache struct {
@@ -120,7 +144,22 @@ func NewFanoutPool(
 		deliverClient:      deliverClient,
 		deliverRetryClient: retryClient,
 		statsReporter:      statsReporter,
+		topics:             deliver.NewTopics(pubsubClient),
 	}
+	if options.MaxConcurrencyPerTarget > 0 {
+		p.limiters = deliver.NewConcurrencyLimiters(options.MaxConcurrencyPerTarget)
+	}
+	if options.DeliveryHeadersPath != "" {
+		p.headerSecrets = deliver.NewHeaderSecrets(options.DeliveryHeadersPath)
+	}
+	if options.BigQueryClient != nil {
+		p.tables = deliver.NewTables(options.BigQueryClient, options.BigQueryBatchSize, options.BigQueryBatchDelay)
+	}
+	if options.ArchiveStore != nil {
+		p.archive = newArchivePool(targets, pubsubClient, options)
+	}
+	p.federation = newFederationPool(p)
+	p.delay = newDelayPool(p)
 	return p, nil
 }
 
@@ -131,6 +170,19 @@ func (p *FanoutPool) SyncOnce(ctx context.Context) error {
 		logging.FromContext(ctx).Error("failed to add tags to context", zap.Error(err))
 	}
 
+	if p.limiters != nil {
+		p.limiters.Prune(p.targets)
+	}
+	p.topics.Prune(p.targets)
+	if p.tables != nil {
+		p.tables.Prune(p.targets)
+	}
+	if p.archive != nil {
+		p.archive.syncOnce(ctx)
+	}
+	p.federation.syncOnce(ctx)
+	p.delay.syncOnce(ctx)
+
 	p.pool.Range(func(key config.CellTenantKey, value *fanoutHandlerCache) bool {
 		if _, ok := p.targets.GetCellTenantByKey(&key); !ok {
 			value.Stop()
@@ -159,29 +211,19 @@ func (p *FanoutPool) SyncOnce(ctx context.Context) error {
 		sub := p.pubsubClient.Subscription(b.DecoupleQueue.Subscription)
 		sub.ReceiveSettings = p.options.PubsubReceiveSettings
 
-		h := NewHandler(
-			sub,
-			processors.ChainProcessors(
-				&fanout.Processor{MaxConcurrency: p.options.MaxConcurrencyPerEvent, Targets: p.targets},
-				&filter.Processor{Targets: p.targets},
-				&deliver.Processor{
-					DeliverClient:      p.deliverClient,
-					Targets:            p.targets,
-					RetryOnFailure:     true,
-					DeliverRetryClient: p.deliverRetryClient,
-					DeliverTimeout:     p.options.DeliveryTimeout,
-					StatsReporter:      p.statsReporter,
-				},
-			),
-			p.options.TimeoutPerEvent,
-		)
+		h := NewHandler(sub, p.newFanoutProcessor(), p.options.TimeoutPerEvent)
+		h.Scheduler = p.options.PullScheduler
+		h.DrainTimeout = p.options.DrainTimeout
+		h.StatsReporter = p.statsReporter
 		hc := &fanoutHandlerCache{
 			Handler: *h,
 			b:       b,
 		}
 
 		// Start the handler with broker key in context.
+		p.running.Add(1)
 		hc.Start(handlerctx.WithBrokerKey(ctx, b.Key()), func(err error) {
+			defer p.running.Done()
 			if err != nil {
 				logging.FromContext(ctx).Error("handler for broker has stopped with error", zap.Stringer("broker", b.Key()), zap.Error(err))
 			} else {
@@ -196,6 +238,62 @@ func (p *FanoutPool) SyncOnce(ctx context.Context) error {
 	return nil
 }
 
+// newFanoutProcessor creates the processors delivering the events of a broker to its targets,
+// preceded by the given processors. The events that are not due yet are sent to the delay topic
+// of the broker.
+func (p *FanoutPool) newFanoutProcessor(pre ...processors.ChainableProcessor) processors.Interface {
+	return p.newProcessor(&schedule.Processor{Targets: p.targets, DelayClient: p.deliverRetryClient}, pre...)
+}
+
+// newDelayProcessor creates the processors delivering the events pulled from the delay
+// subscription of a broker to its targets. The events that are not due yet are held in the
+// subscription.
+func (p *FanoutPool) newDelayProcessor() processors.Interface {
+	return p.newProcessor(&schedule.Processor{})
+}
+
+// newProcessor creates the processors scheduling the events with sched and delivering them to the
+// targets of their broker, preceded by the given processors.
+func (p *FanoutPool) newProcessor(sched *schedule.Processor, pre ...processors.ChainableProcessor) processors.Interface {
+	chain := append(pre,
+		sched,
+		&fanout.Processor{MaxConcurrency: p.options.MaxConcurrencyPerEvent, Targets: p.targets},
+		&filter.Processor{Targets: p.targets},
+		&deliver.Processor{
+			DeliverClient:      p.deliverClient,
+			Targets:            p.targets,
+			RetryOnFailure:     true,
+			DeliverRetryClient: p.deliverRetryClient,
+			DeliverTimeout:     p.options.DeliveryTimeout,
+			StatsReporter:      p.statsReporter,
+			Limiters:           p.limiters,
+			HeaderSecrets:      p.headerSecrets,
+			Recorder:           p.options.EventRecorder,
+			Topics:             p.topics,
+			Tables:             p.tables,
+		},
+	)
+	return processors.ChainProcessors(chain[0], chain[1:]...)
+}
+
+// Drain stops all the handlers, including the archive, federation and delay handlers,
+// and waits for their in-flight events to be drained, including the handlers
+// stopped by previous syncs, or the context to be done.
+func (p *FanoutPool) Drain(ctx context.Context) error {
+	p.pool.Range(func(_ config.CellTenantKey, value *fanoutHandlerCache) bool {
+		value.Stop()
+		return true
+	})
+	p.federation.stop()
+	p.delay.stop()
+	if p.archive != nil {
+		if err := p.archive.drain(ctx); err != nil {
+			return err
+		}
+	}
+	return waitDrained(ctx, &p.running)
+}
+
 // syncMapBrokerKey is a typed version of sync.Map.
 type syncMapBrokerKey struct {
 	m sync.Map
@@ -22,9 +22,13 @@ import (
 	"testing"
 	"time"
 
+	"cloud.google.com/go/pubsub"
+	vkit "cloud.google.com/go/pubsub/apiv1"
 	"github.com/cloudevents/sdk-go/v2/event"
 	"github.com/google/go-cmp/cmp"
 	"golang.org/x/sync/errgroup"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
 
 	"github.com/google/knative-gcp/pkg/broker/config"
 	"github.com/google/knative-gcp/pkg/broker/eventutil"
@@ -283,7 +287,7 @@ func TestFanoutSyncPoolE2E(t *testing.T) {
 			return nil
 		})
 		group.Go(func() error {
-			helper.VerifyNextTargetRetryEvent(ctx, t, t3.Key(), &e)
+			helper.VerifyNextTargetRetryEvent(ctx, t, t3.Key(), retryEvent(e, t3))
 			return nil
 		})
 
@@ -313,7 +317,7 @@ func TestFanoutSyncPoolE2E(t *testing.T) {
 		// Because of the delay, t1 delivery should timeout.
 		// Thus the event should have been sent to the retry queue.
 		group.Go(func() error {
-			helper.VerifyNextTargetRetryEvent(ctx, t, t1.Key(), &e)
+			helper.VerifyNextTargetRetryEvent(ctx, t, t1.Key(), retryEvent(e, t1))
 			return nil
 		})
 		// The same event should be received by t2.
@@ -395,6 +399,262 @@ func TestFanoutSyncPoolE2E(t *testing.T) {
 	})
 }
 
+func TestFanoutFederation(t *testing.T) {
+	reportertest.ResetDeliveryMetrics()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	helper, err := handlertesting.NewHelper(ctx, "test-project")
+	if err != nil {
+		t.Fatalf("failed to create pool testing helper: %v", err)
+	}
+	defer helper.Close()
+
+	// Broker1 federates the decouple topic of broker2.
+	b1 := helper.GenerateBroker(ctx, t, "ns")
+	b2 := helper.GenerateBroker(ctx, t, "ns")
+	t1 := helper.GenerateTarget(ctx, t, b1.Key(), nil)
+	t2 := helper.GenerateTarget(ctx, t, b2.Key(), nil)
+
+	sub := "federation-sub"
+	if _, err := helper.PubsubClient.CreateSubscription(ctx, sub, pubsub.SubscriptionConfig{
+		Topic: helper.PubsubClient.Topic(b2.DecoupleQueue.Topic),
+	}); err != nil {
+		t.Fatalf("failed to create test federation subscription: %v", err)
+	}
+	helper.Targets.MutateCellTenant(b1.Key(), func(bm config.CellTenantMutation) {
+		bm.SetFederationQueues(&config.Queue{
+			Topic:        b2.DecoupleQueue.Topic,
+			Subscription: sub,
+			State:        config.State_READY,
+		})
+	})
+
+	signal := make(chan struct{})
+	syncPool, err := InitializeTestFanoutPool(ctx, fanoutPod, fanoutContainer, helper.Targets, helper.PubsubClient)
+	if err != nil {
+		t.Errorf("unexpected error from getting sync pool: %v", err)
+	}
+	p, err := GetFreePort()
+	if err != nil {
+		t.Fatalf("failed to get random free port: %v", err)
+	}
+	if _, err := StartSyncPool(ctx, syncPool, signal, time.Minute, p, &authcheck.FakeAuthenticationCheck{}); err != nil {
+		t.Errorf("unexpected error from starting sync pool: %v", err)
+	}
+
+	e := event.New()
+	e.SetType("type")
+	e.SetID("id")
+	e.SetSource("source")
+	e.SetExtension(eventutil.HopsAttribute, 10)
+	// The federated event consumes a hop.
+	federated := e.Clone()
+	federated.SetExtension(eventutil.HopsAttribute, 9)
+
+	t.Run("federated broker's targets receive the remote events", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+		defer cancel()
+
+		group, ctx := errgroup.WithContext(ctx)
+		group.Go(func() error {
+			helper.VerifyNextTargetEvent(ctx, t, t1.Key(), &federated)
+			return nil
+		})
+		group.Go(func() error {
+			helper.VerifyNextTargetEvent(ctx, t, t2.Key(), &e)
+			return nil
+		})
+
+		helper.SendEventToDecoupleQueue(ctx, t, b2.Key(), &e)
+
+		if err := group.Wait(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("events without remaining hops are not federated", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+		defer cancel()
+
+		last := e.Clone()
+		last.SetExtension(eventutil.HopsAttribute, 0)
+
+		group, ctx := errgroup.WithContext(ctx)
+		group.Go(func() error {
+			helper.VerifyNextTargetEvent(ctx, t, t1.Key(), nil)
+			return nil
+		})
+		group.Go(func() error {
+			helper.VerifyNextTargetEvent(ctx, t, t2.Key(), &last)
+			return nil
+		})
+
+		helper.SendEventToDecoupleQueue(ctx, t, b2.Key(), &last)
+
+		if err := group.Wait(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("removing the federation queue stops federating", func(t *testing.T) {
+		helper.Targets.MutateCellTenant(b1.Key(), func(bm config.CellTenantMutation) {
+			bm.SetFederationQueues()
+		})
+		signal <- struct{}{}
+		// Wait a short period for the handlers to be updated.
+		<-time.After(time.Second)
+
+		ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+		defer cancel()
+
+		group, ctx := errgroup.WithContext(ctx)
+		group.Go(func() error {
+			helper.VerifyNextTargetEvent(ctx, t, t1.Key(), nil)
+			return nil
+		})
+		group.Go(func() error {
+			helper.VerifyNextTargetEvent(ctx, t, t2.Key(), &e)
+			return nil
+		})
+
+		helper.SendEventToDecoupleQueue(ctx, t, b2.Key(), &e)
+
+		if err := group.Wait(); err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+func TestFanoutDelay(t *testing.T) {
+	reportertest.ResetDeliveryMetrics()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	helper, err := handlertesting.NewHelper(ctx, "test-project")
+	if err != nil {
+		t.Fatalf("failed to create pool testing helper: %v", err)
+	}
+	defer helper.Close()
+
+	b := helper.GenerateBroker(ctx, t, "ns")
+	target := helper.GenerateTarget(ctx, t, b.Key(), nil)
+
+	delayTopic, err := helper.PubsubClient.CreateTopic(ctx, "delay-topic")
+	if err != nil {
+		t.Fatalf("failed to create test delay topic: %v", err)
+	}
+	if _, err := helper.PubsubClient.CreateSubscription(ctx, "delay-sub", pubsub.SubscriptionConfig{Topic: delayTopic}); err != nil {
+		t.Fatalf("failed to create test delay subscription: %v", err)
+	}
+	helper.Targets.MutateCellTenant(b.Key(), func(bm config.CellTenantMutation) {
+		bm.SetDelayQueue(&config.Queue{
+			Topic:        "delay-topic",
+			Subscription: "delay-sub",
+			State:        config.State_READY,
+		})
+	})
+
+	signal := make(chan struct{})
+	syncPool, err := InitializeTestFanoutPool(ctx, fanoutPod, fanoutContainer, helper.Targets, helper.PubsubClient)
+	if err != nil {
+		t.Errorf("unexpected error from getting sync pool: %v", err)
+	}
+	p, err := GetFreePort()
+	if err != nil {
+		t.Fatalf("failed to get random free port: %v", err)
+	}
+	if _, err := StartSyncPool(ctx, syncPool, signal, time.Minute, p, &authcheck.FakeAuthenticationCheck{}); err != nil {
+		t.Errorf("unexpected error from starting sync pool: %v", err)
+	}
+
+	deliverAt := time.Now().Add(time.Second)
+	e := event.New()
+	e.SetType("type")
+	e.SetID("id")
+	e.SetSource("source")
+	e.SetExtension(eventutil.DeliverAtExtension, deliverAt.Format(time.RFC3339Nano))
+
+	vctx, vcancel := context.WithTimeout(ctx, 5*time.Second)
+	defer vcancel()
+	group, vctx := errgroup.WithContext(vctx)
+	group.Go(func() error {
+		helper.VerifyNextTargetEvent(vctx, t, target.Key(), &e)
+		return nil
+	})
+	helper.SendEventToDecoupleQueue(ctx, t, b.Key(), &e)
+	if err := group.Wait(); err != nil {
+		t.Error(err)
+	}
+	if now := time.Now(); now.Before(deliverAt) {
+		t.Errorf("scheduled event delivered %v before it was due", deliverAt.Sub(now))
+	}
+}
+
+func TestFanoutMultiplexedPull(t *testing.T) {
+	reportertest.ResetDeliveryMetrics()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	helper, err := handlertesting.NewHelper(ctx, "test-project")
+	if err != nil {
+		t.Fatalf("failed to create pool testing helper: %v", err)
+	}
+	defer helper.Close()
+
+	conn, err := grpc.Dial(helper.PubsubServer.Addr, grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("failed to dial the test pubsub server: %v", err)
+	}
+	defer conn.Close()
+	subscriber, err := vkit.NewSubscriberClient(ctx, option.WithGRPCConn(conn))
+	if err != nil {
+		t.Fatalf("failed to create the subscriber client: %v", err)
+	}
+	defer subscriber.Close()
+	scheduler := NewPullScheduler(subscriber, 2, 100)
+	scheduler.Start(ctx)
+
+	b1 := helper.GenerateBroker(ctx, t, "ns")
+	b2 := helper.GenerateBroker(ctx, t, "ns")
+	t1 := helper.GenerateTarget(ctx, t, b1.Key(), nil)
+	t2 := helper.GenerateTarget(ctx, t, b2.Key(), nil)
+
+	signal := make(chan struct{})
+	syncPool, err := InitializeTestFanoutPool(ctx, fanoutPod, fanoutContainer, helper.Targets, helper.PubsubClient, WithPullScheduler(scheduler))
+	if err != nil {
+		t.Errorf("unexpected error from getting sync pool: %v", err)
+	}
+	p, err := GetFreePort()
+	if err != nil {
+		t.Fatalf("failed to get random free port: %v", err)
+	}
+	if _, err := StartSyncPool(ctx, syncPool, signal, time.Minute, p, &authcheck.FakeAuthenticationCheck{}); err != nil {
+		t.Errorf("unexpected error from starting sync pool: %v", err)
+	}
+
+	e1 := genTestEvent("foo1", "bar1", "id1", "source1")
+	e2 := genTestEvent("foo2", "bar2", "id2", "source2")
+
+	vctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	group, vctx := errgroup.WithContext(vctx)
+	group.Go(func() error {
+		helper.VerifyNextTargetEvent(vctx, t, t1.Key(), &e1)
+		return nil
+	})
+	group.Go(func() error {
+		helper.VerifyNextTargetEvent(vctx, t, t2.Key(), &e2)
+		return nil
+	})
+
+	helper.SendEventToDecoupleQueue(ctx, t, b1.Key(), &e1)
+	helper.SendEventToDecoupleQueue(ctx, t, b2.Key(), &e2)
+
+	if err := group.Wait(); err != nil {
+		t.Error(err)
+	}
+}
+
 func assertFanoutHandlers(t *testing.T, p *FanoutPool, targets config.Targets) {
 	t.Helper()
 	gotHandlers := make(map[config.CellTenantKey]bool)
@@ -432,3 +692,10 @@ func trigger(target *config.Target) reportertest.Trigger {
 		Broker:    target.CellTenantName,
 	}
 }
+
+// retryEvent returns the event as sent to the retry queue of the target.
+func retryEvent(e event.Event, target *config.Target) *event.Event {
+	retry := e.Clone()
+	retry.SetExtension(eventutil.RetryTargetAttribute, target.Id)
+	return &retry
+}
@@ -0,0 +1,117 @@
+/*
+Copyright 2021 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"context"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"github.com/google/knative-gcp/pkg/broker/config"
+	handlerctx "github.com/google/knative-gcp/pkg/broker/handler/context"
+	"github.com/google/knative-gcp/pkg/broker/handler/processors/federation"
+	"github.com/google/knative-gcp/pkg/logging"
+)
+
+// federationKey identifies the subscription of a broker to the decouple topic of a remote broker.
+type federationKey struct {
+	broker       config.CellTenantKey
+	subscription string
+}
+
+// federationPool runs the handlers of the subscriptions of the brokers to the decouple topics of
+// the remote brokers they federate. The events pulled by each handler are fanned out to the
+// targets of its broker like the events of the broker itself.
+type federationPool struct {
+	fanout *FanoutPool
+	pool   sync.Map
+}
+
+type federationHandlerCache struct {
+	Handler
+	topic string
+}
+
+func newFederationPool(fanout *FanoutPool) *federationPool {
+	return &federationPool{fanout: fanout}
+}
+
+// syncOnce starts the handlers of the ready federation queues of the ready brokers, and stops the
+// others.
+func (p *federationPool) syncOnce(ctx context.Context) {
+	wanted := make(map[federationKey]*config.Queue)
+	p.fanout.targets.RangeCellTenants(func(b *config.CellTenant) bool {
+		if b.State != config.State_READY {
+			return true
+		}
+		for _, q := range b.FederationQueues {
+			if q.State == config.State_READY {
+				wanted[federationKey{broker: *b.Key(), subscription: q.Subscription}] = q
+			}
+		}
+		return true
+	})
+
+	p.pool.Range(func(k, v interface{}) bool {
+		hc := v.(*federationHandlerCache)
+		if q, ok := wanted[k.(federationKey)]; !ok || !hc.IsAlive() || q.Topic != hc.topic {
+			hc.Stop()
+			p.pool.Delete(k)
+		}
+		return true
+	})
+
+	for key, q := range wanted {
+		if _, ok := p.pool.Load(key); ok {
+			continue
+		}
+		sub := p.fanout.pubsubClient.Subscription(q.Subscription)
+		sub.ReceiveSettings = p.fanout.options.PubsubReceiveSettings
+		h := NewHandler(sub, p.fanout.newFanoutProcessor(&federation.Processor{}), p.fanout.options.TimeoutPerEvent)
+		h.Scheduler = p.fanout.options.PullScheduler
+		h.DrainTimeout = p.fanout.options.DrainTimeout
+		h.StatsReporter = p.fanout.statsReporter
+		hc := &federationHandlerCache{
+			Handler: *h,
+			topic:   q.Topic,
+		}
+
+		bk := key.broker
+		topic := q.Topic
+		p.fanout.running.Add(1)
+		hc.Start(handlerctx.WithBrokerKey(ctx, &bk), func(err error) {
+			defer p.fanout.running.Done()
+			if err != nil {
+				logging.FromContext(ctx).Error("federation handler for broker has stopped with error",
+					zap.Stringer("broker", &bk), zap.String("topic", topic), zap.Error(err))
+			} else {
+				logging.FromContext(ctx).Info("federation handler for broker has stopped",
+					zap.Stringer("broker", &bk), zap.String("topic", topic))
+			}
+		})
+		p.pool.Store(key, hc)
+	}
+}
+
+// stop stops all the federation handlers. They are drained with the fanout handlers.
+func (p *federationPool) stop() {
+	p.pool.Range(func(_, v interface{}) bool {
+		v.(*federationHandlerCache).Stop()
+		return true
+	})
+}
@@ -26,6 +26,8 @@ import (
 	cepubsub "github.com/cloudevents/sdk-go/protocol/pubsub/v2"
 	"github.com/cloudevents/sdk-go/v2/binding"
 	"github.com/google/knative-gcp/pkg/broker/handler/processors"
+	"github.com/google/knative-gcp/pkg/broker/handler/processors/deliver"
+	"github.com/google/knative-gcp/pkg/broker/handler/processors/schedule"
 	"github.com/google/knative-gcp/pkg/logging"
 	"github.com/google/knative-gcp/pkg/metrics"
 	"go.uber.org/zap"
@@ -44,9 +46,25 @@ type Handler struct {
 	// Timeout is the timeout for processing each individual event.
 	Timeout time.Duration
 
+	// Scheduler pulls the messages of the subscription along with the
+	// subscriptions of other handlers when present. Otherwise the handler
+	// has its own streaming pull.
+	Scheduler *PullScheduler
+
+	// DrainTimeout is how long the in-flight events are given to be done
+	// once the handler stops pulling. The ones still being processed are
+	// aborted afterwards and redelivered.
+	DrainTimeout time.Duration
+
+	// StatsReporter reports the drain of the handler when it is not nil.
+	StatsReporter *metrics.DeliveryReporter
+
 	// cancel is function to stop pulling messages.
 	cancel context.CancelFunc
 
+	// inflight tracks the events being processed.
+	inflight *inflightEvents
+
 	// alive is a bool indicator that the handler is still alive.
 	alive atomic.Value
 }
@@ -65,43 +83,120 @@ func NewHandler(
 }
 
 // Start starts the handler.
-// done func will be called if the pubsub inbound is closed.
+// done func will be called if the pubsub inbound is closed, once the in-flight
+// events are drained.
 func (h *Handler) Start(ctx context.Context, done func(error)) {
+	// The events are processed with a context that is not canceled when the
+	// handler stops pulling, so that the in-flight ones are drained rather
+	// than abandoned.
+	processCtx, abort := context.WithCancel(detach(ctx))
 	ctx, h.cancel = context.WithCancel(ctx)
+	h.inflight = newInflightEvents()
 	h.alive.Store(true)
 
+	drained := make(chan struct{})
+	go func() {
+		defer close(drained)
+		<-ctx.Done()
+		h.drain(ctx, abort)
+	}()
+
 	go func() {
+		var err error
+		if h.Scheduler != nil {
+			err = h.Scheduler.serve(ctx, h.Subscription.String(), func(_ context.Context, msg *pubsub.Message) (bool, time.Duration) {
+				return h.process(ctx, processCtx, msg)
+			})
+		} else {
+			err = h.Subscription.Receive(ctx, func(_ context.Context, msg *pubsub.Message) {
+				h.receive(ctx, processCtx, msg)
+			})
+		}
 		// For any reason if inbound is closed, mark alive as false.
-		defer h.alive.Store(false)
-		done(h.Subscription.Receive(ctx, h.receive))
+		h.alive.Store(false)
+		h.cancel()
+		<-drained
+		done(err)
 	}()
 }
 
-// Stop stops the handlers.
+// Stop stops pulling messages. The in-flight events keep being processed
+// until they are done or the drain timeout is exceeded.
 func (h *Handler) Stop() {
 	h.cancel()
 }
 
+// drain waits for the in-flight events to be done, and aborts the ones that
+// are still being processed once the drain timeout is exceeded.
+func (h *Handler) drain(ctx context.Context, abort context.CancelFunc) {
+	defer abort()
+	start := time.Now()
+	pending, idle := h.inflight.count()
+	aborted := 0
+	timer := time.NewTimer(h.DrainTimeout)
+	defer timer.Stop()
+	select {
+	case <-idle:
+	case <-timer.C:
+		aborted, idle = h.inflight.count()
+		if aborted > 0 {
+			logging.FromContext(ctx).Warn("aborting in-flight events after the drain timeout", zap.Int("events", aborted), zap.Duration("drainTimeout", h.DrainTimeout))
+		}
+		abort()
+		<-idle
+	}
+	drainedEvents := pending - aborted
+	if drainedEvents < 0 {
+		drainedEvents = 0
+	}
+	if h.StatsReporter != nil {
+		h.StatsReporter.ReportDrain(ctx, time.Since(start), drainedEvents, aborted)
+	}
+}
+
 // IsAlive indicates whether the handler is alive.
 func (h *Handler) IsAlive() bool {
 	return h.alive.Load().(bool)
 }
 
-// receive converts message to events and invoke processor chain.
-func (h *Handler) receive(ctx context.Context, msg *pubsub.Message) {
+// receive processes the message and acks or nacks it.
+func (h *Handler) receive(ctx, processCtx context.Context, msg *pubsub.Message) {
+	// The streaming pull can't delay the redelivery of a nacked message, the
+	// retry policy of the subscription does.
+	if ack, _ := h.process(ctx, processCtx, msg); ack {
+		msg.Ack()
+	} else {
+		msg.Nack()
+	}
+}
+
+// process handles the message with the processing context, unless the handler
+// has stopped pulling meanwhile, and tracks it as in-flight until it is done.
+func (h *Handler) process(ctx, processCtx context.Context, msg *pubsub.Message) (bool, time.Duration) {
+	if ctx.Err() != nil {
+		// The message was not processed yet, let it be redelivered.
+		return false, 0
+	}
+	h.inflight.add()
+	defer h.inflight.done()
+	return h.handle(processCtx, msg)
+}
+
+// handle converts message to events and invoke processor chain. It returns
+// whether the message should be acked, and otherwise how long to wait before
+// it is redelivered.
+func (h *Handler) handle(ctx context.Context, msg *pubsub.Message) (bool, time.Duration) {
 	ctx = metrics.StartEventProcessing(ctx)
 	event, err := binding.ToEvent(ctx, cepubsub.NewMessage(msg))
 	if isNonRetryable(err) {
 		logEventConversionError(ctx, msg, err, "failed to convert received message to an event, check the msg format")
 		// Ack the message so it won't be retried.
 		// TODO Should this go to the DLQ once DLQ is implemented?
-		msg.Ack()
-		return
+		return true, 0
 	}
 	if err != nil {
 		logEventConversionError(ctx, msg, err, "unknown error when converting the received message to an event")
-		msg.Nack()
-		return
+		return false, 0
 	}
 
 	if h.Timeout != 0 {
@@ -110,12 +205,19 @@ func (h *Handler) receive(ctx context.Context, msg *pubsub.Message) {
 		defer cancel()
 	}
 	if err := h.Processor.Process(ctx, event); err != nil {
+		if delay, ok := schedule.NotDue(err); ok {
+			// The message stays in the delay subscription until the event is due, so the
+			// scheduled delivery survives restarts.
+			logging.FromContext(ctx).Debug("holding event scheduled for a later delivery", zap.String("eventID", event.ID()), zap.Duration("delay", delay))
+			return false, delay
+		}
 		logging.FromContext(ctx).Error("failed to process event", zap.String("eventID", event.ID()), zap.Error(err))
-		msg.Nack()
-		return
+		if delay, ok := deliver.RetryAfter(err); ok {
+			return false, delay
+		}
+		return false, 0
 	}
-
-	msg.Ack()
+	return true, 0
 }
 
 func isNonRetryable(err error) bool {
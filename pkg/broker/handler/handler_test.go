@@ -18,8 +18,10 @@ package handler
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"runtime"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -33,7 +35,10 @@ import (
 	"google.golang.org/api/option"
 	"google.golang.org/grpc"
 
+	"github.com/google/knative-gcp/pkg/broker/eventutil"
 	"github.com/google/knative-gcp/pkg/broker/handler/processors"
+	"github.com/google/knative-gcp/pkg/broker/handler/processors/deliver"
+	"github.com/google/knative-gcp/pkg/broker/handler/processors/schedule"
 	kgcptesting "github.com/google/knative-gcp/pkg/testing"
 )
 
@@ -160,6 +165,266 @@ func TestHandler(t *testing.T) {
 	})
 }
 
+// retryAfterProcessor fails the first attempt to process an event with a delivery failure that
+// requests a delay before retrying, and records when each attempt happened.
+type retryAfterProcessor struct {
+	processors.BaseProcessor
+
+	delay    time.Duration
+	attempts chan time.Time
+	count    int32
+}
+
+func (p *retryAfterProcessor) Process(ctx context.Context, e *event.Event) error {
+	p.attempts <- time.Now()
+	if atomic.AddInt32(&p.count, 1) == 1 {
+		return &deliver.RetryAfterError{Delay: p.delay, Err: errors.New("too many requests")}
+	}
+	return nil
+}
+
+func TestHandlerDelaysRetryRequestedByTarget(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// The pull scheduler delays the redelivery with the ack deadline of the message.
+	scheduler, c := newTestPullScheduler(t, 1, 10)
+	scheduler.Start(ctx)
+
+	topic, err := c.CreateTopic(ctx, testTopic)
+	if err != nil {
+		t.Fatalf("failed to create topic: %v", err)
+	}
+	sub, err := c.CreateSubscription(ctx, testSub, pubsub.SubscriptionConfig{
+		Topic: topic,
+	})
+	if err != nil {
+		t.Fatalf("failed to create subscription: %v", err)
+	}
+
+	p, err := cepubsub.New(context.Background(),
+		cepubsub.WithClient(c),
+		cepubsub.WithProjectID(testProjectID),
+		cepubsub.WithTopicID(testTopic),
+	)
+	if err != nil {
+		t.Fatalf("failed to create cloudevents pubsub protocol: %v", err)
+	}
+
+	processor := &retryAfterProcessor{delay: 500 * time.Millisecond, attempts: make(chan time.Time, 2)}
+	h := NewHandler(sub, processor, time.Second)
+	h.Scheduler = scheduler
+	h.Start(ctx, func(err error) {})
+	defer h.Stop()
+
+	testEvent := event.New()
+	testEvent.SetID("id")
+	testEvent.SetSource("source")
+	testEvent.SetType("type")
+	if err := p.Send(ctx, binding.ToMessage(&testEvent)); err != nil {
+		t.Fatalf("failed to seed event to pubsub: %v", err)
+	}
+
+	var attempts []time.Time
+	for i := 0; i < 2; i++ {
+		select {
+		case attempt := <-processor.attempts:
+			attempts = append(attempts, attempt)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("got %d attempts to process the event, want 2", len(attempts))
+		}
+	}
+	if got := attempts[1].Sub(attempts[0]); got < processor.delay {
+		t.Errorf("event retried after %v, want at least %v", got, processor.delay)
+	}
+}
+
+func TestHandlerHoldsScheduledEvent(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// The pull scheduler delays the redelivery with the ack deadline of the message.
+	scheduler, c := newTestPullScheduler(t, 1, 10)
+	scheduler.Start(ctx)
+
+	topic, err := c.CreateTopic(ctx, testTopic)
+	if err != nil {
+		t.Fatalf("failed to create topic: %v", err)
+	}
+	sub, err := c.CreateSubscription(ctx, testSub, pubsub.SubscriptionConfig{
+		Topic: topic,
+	})
+	if err != nil {
+		t.Fatalf("failed to create subscription: %v", err)
+	}
+
+	p, err := cepubsub.New(context.Background(),
+		cepubsub.WithClient(c),
+		cepubsub.WithProjectID(testProjectID),
+		cepubsub.WithTopicID(testTopic),
+	)
+	if err != nil {
+		t.Fatalf("failed to create cloudevents pubsub protocol: %v", err)
+	}
+
+	eventCh := make(chan *event.Event, 1)
+	processor := &processors.FakeProcessor{PrevEventsCh: eventCh}
+	h := NewHandler(sub, processors.ChainProcessors(&schedule.Processor{}, processor), time.Second)
+	h.Scheduler = scheduler
+	h.Start(ctx, func(err error) {})
+	defer h.Stop()
+
+	deliverAt := time.Now().Add(500 * time.Millisecond)
+	testEvent := event.New()
+	testEvent.SetID("id")
+	testEvent.SetSource("source")
+	testEvent.SetType("type")
+	testEvent.SetExtension(eventutil.DeliverAtExtension, deliverAt)
+	if err := p.Send(ctx, binding.ToMessage(&testEvent)); err != nil {
+		t.Fatalf("failed to seed event to pubsub: %v", err)
+	}
+
+	select {
+	case <-eventCh:
+	case <-time.After(5 * time.Second):
+		t.Fatal("scheduled event was not processed")
+	}
+	if now := time.Now(); now.Before(deliverAt) {
+		t.Errorf("scheduled event processed %v before it was due", deliverAt.Sub(now))
+	}
+}
+
+// blockingProcessor blocks processing events until it is released or the
+// context is done, and reports the result of each attempt.
+type blockingProcessor struct {
+	processors.BaseProcessor
+
+	started chan struct{}
+	release chan struct{}
+	results chan error
+}
+
+func newBlockingProcessor() *blockingProcessor {
+	return &blockingProcessor{
+		started: make(chan struct{}, 10),
+		release: make(chan struct{}),
+		results: make(chan error, 10),
+	}
+}
+
+func (p *blockingProcessor) Process(ctx context.Context, e *event.Event) error {
+	p.started <- struct{}{}
+	select {
+	case <-p.release:
+		p.results <- nil
+		return nil
+	case <-ctx.Done():
+		p.results <- ctx.Err()
+		return ctx.Err()
+	}
+}
+
+func TestHandlerDrain(t *testing.T) {
+	for _, pull := range []string{"streaming pull", "pull scheduler"} {
+		pull := pull
+		t.Run(pull, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			var c *pubsub.Client
+			var scheduler *PullScheduler
+			if pull == "pull scheduler" {
+				scheduler, c = newTestPullScheduler(t, 1, 10)
+				scheduler.Start(ctx)
+			} else {
+				var close func()
+				c, close = testPubsubClient(ctx, t, testProjectID)
+				defer close()
+			}
+			topic, sub := newTestSubscription(ctx, t, c, testSub)
+			p, err := cepubsub.New(ctx,
+				cepubsub.WithClient(c),
+				cepubsub.WithProjectID(testProjectID),
+				cepubsub.WithTopicID(topic.ID()),
+			)
+			if err != nil {
+				t.Fatalf("failed to create cloudevents pubsub protocol: %v", err)
+			}
+			testEvent := event.New()
+			testEvent.SetID("id")
+			testEvent.SetSource("source")
+			testEvent.SetType("type")
+
+			start := func(processor processors.Interface, drainTimeout time.Duration) (*Handler, <-chan struct{}) {
+				h := NewHandler(sub, processor, 10*time.Second)
+				h.Scheduler = scheduler
+				h.DrainTimeout = drainTimeout
+				stopped := make(chan struct{})
+				h.Start(ctx, func(error) { close(stopped) })
+				return h, stopped
+			}
+			waitFor := func(ch <-chan struct{}, what string) {
+				t.Helper()
+				select {
+				case <-ch:
+				case <-time.After(10 * time.Second):
+					t.Fatalf("timed out waiting for %s", what)
+				}
+			}
+
+			t.Run("in-flight event is drained", func(t *testing.T) {
+				processor := newBlockingProcessor()
+				h, stopped := start(processor, 10*time.Second)
+				if err := p.Send(ctx, binding.ToMessage(&testEvent)); err != nil {
+					t.Fatalf("failed to seed event to pubsub: %v", err)
+				}
+				waitFor(processor.started, "the event to be processed")
+				h.Stop()
+
+				select {
+				case <-stopped:
+					t.Fatal("handler stopped before its in-flight event was done")
+				case <-time.After(200 * time.Millisecond):
+				}
+				close(processor.release)
+				waitFor(stopped, "the handler to stop")
+				if err := <-processor.results; err != nil {
+					t.Errorf("in-flight event failed while draining: %v", err)
+				}
+
+				// The drained event was acked, so it is not redelivered.
+				eventCh := make(chan *event.Event, 1)
+				next, nextStopped := start(&processors.FakeProcessor{PrevEventsCh: eventCh}, 0)
+				defer waitFor(nextStopped, "the handler to stop")
+				defer next.Stop()
+				if got := nextEventWithTimeout(eventCh); got != nil {
+					t.Errorf("drained event was redelivered: %v", got)
+				}
+			})
+
+			t.Run("in-flight event is aborted after drain timeout", func(t *testing.T) {
+				processor := newBlockingProcessor()
+				h, stopped := start(processor, 100*time.Millisecond)
+				if err := p.Send(ctx, binding.ToMessage(&testEvent)); err != nil {
+					t.Fatalf("failed to seed event to pubsub: %v", err)
+				}
+				waitFor(processor.started, "the event to be processed")
+				h.Stop()
+				waitFor(stopped, "the handler to stop")
+				if err := <-processor.results; !errors.Is(err, context.Canceled) {
+					t.Errorf("in-flight event got %v, want it aborted", err)
+				}
+
+				// The aborted event is redelivered.
+				eventCh := make(chan *event.Event, 1)
+				next, nextStopped := start(&processors.FakeProcessor{PrevEventsCh: eventCh}, 0)
+				defer waitFor(nextStopped, "the handler to stop")
+				defer next.Stop()
+				if got := nextEventWithTimeout(eventCh); got == nil {
+					t.Error("aborted event was not redelivered")
+				}
+			})
+		})
+	}
+}
+
 type BenchProcessor struct {
 	processors.BaseProcessor
 
@@ -21,12 +21,21 @@ import (
 	"time"
 
 	"cloud.google.com/go/pubsub"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/google/knative-gcp/pkg/broker/archive"
+	"github.com/google/knative-gcp/pkg/gclient/bigquery"
 )
 
 var (
 	defaultHandlerConcurrency     = runtime.NumCPU()
 	defaultMaxConcurrencyPerEvent = 1
 	defaultTimeout                = 10 * time.Minute
+	defaultDrainTimeout           = 30 * time.Second
+	defaultBigQueryBatchSize      = 500
+	defaultBigQueryBatchDelay     = 50 * time.Millisecond
+	defaultArchiveMaxEvents       = 1000
+	defaultArchiveFlushDelay      = 10 * time.Second
 
 	// This is the pubsub default MaxExtension.
 	// It would not make sense for handler timeout per event be greater
@@ -48,8 +57,45 @@ type Options struct {
 	TimeoutPerEvent time.Duration
 	// DeliveryTimeout is the timeout for delivering an event to a consumer.
 	DeliveryTimeout time.Duration
+	// MaxConcurrencyPerTarget is the max number of concurrent deliveries
+	// to a target. The actual limit adapts to the target's latency and
+	// errors. If zero, deliveries are not limited.
+	MaxConcurrencyPerTarget int
+	// DeliveryHeadersPath is the directory the delivery headers Secret is
+	// mounted in. If empty, deliveries to targets with headers read from
+	// Secrets fail.
+	DeliveryHeadersPath string
 	// PubsubReceiveSettings is the pubsub receive settings.
 	PubsubReceiveSettings pubsub.ReceiveSettings
+	// PullScheduler pulls the messages of all the handlers with a bounded
+	// set of workers, and delays the redelivery of the nacked messages with
+	// their ack deadline. If nil, each handler has its own streaming pull
+	// with the PubsubReceiveSettings.
+	PullScheduler *PullScheduler
+	// DrainTimeout is how long the in-flight events of a stopped handler
+	// are given to be done before they are aborted.
+	DrainTimeout time.Duration
+	// EventRecorder emits Kubernetes events on the Triggers, e.g. when
+	// their replies loop back to them. If nil, no event is emitted.
+	EventRecorder record.EventRecorder
+	// BigQueryClient streams the events into the targets that are
+	// BigQuery tables. If nil, the deliveries to such targets fail.
+	BigQueryClient bigquery.Client
+	// BigQueryBatchSize is the max number of rows inserted into a
+	// BigQuery table at once.
+	BigQueryBatchSize int
+	// BigQueryBatchDelay is how long the rows wait for more rows to be
+	// inserted into the same BigQuery table with.
+	BigQueryBatchDelay time.Duration
+	// ArchiveStore stores the archive files of the Brokers whose events
+	// are archived. If nil, the events are not archived.
+	ArchiveStore archive.BlobStore
+	// ArchiveMaxEvents is the max number of events written to an archive
+	// file at once.
+	ArchiveMaxEvents int
+	// ArchiveFlushDelay is how long the events wait for more events to be
+	// written to the same archive file with.
+	ArchiveFlushDelay time.Duration
 }
 
 // NewOptions creates a Options.
@@ -59,6 +105,11 @@ func NewOptions(opts ...Option) (*Options, error) {
 		MaxConcurrencyPerEvent: defaultMaxConcurrencyPerEvent,
 		TimeoutPerEvent:        defaultTimeout,
 		PubsubReceiveSettings:  pubsub.DefaultReceiveSettings,
+		DrainTimeout:           defaultDrainTimeout,
+		BigQueryBatchSize:      defaultBigQueryBatchSize,
+		BigQueryBatchDelay:     defaultBigQueryBatchDelay,
+		ArchiveMaxEvents:       defaultArchiveMaxEvents,
+		ArchiveFlushDelay:      defaultArchiveFlushDelay,
 	}
 	for _, o := range opts {
 		o(opt)
@@ -107,3 +158,80 @@ func WithDeliveryTimeout(t time.Duration) Option {
 		o.DeliveryTimeout = t
 	}
 }
+
+// WithMaxConcurrencyPerTarget sets MaxConcurrencyPerTarget.
+func WithMaxConcurrencyPerTarget(c int) Option {
+	return func(o *Options) {
+		o.MaxConcurrencyPerTarget = c
+	}
+}
+
+// WithDeliveryHeadersPath sets DeliveryHeadersPath.
+func WithDeliveryHeadersPath(path string) Option {
+	return func(o *Options) {
+		o.DeliveryHeadersPath = path
+	}
+}
+
+// WithPullScheduler sets PullScheduler.
+func WithPullScheduler(s *PullScheduler) Option {
+	return func(o *Options) {
+		o.PullScheduler = s
+	}
+}
+
+// WithDrainTimeout sets DrainTimeout.
+func WithDrainTimeout(t time.Duration) Option {
+	return func(o *Options) {
+		o.DrainTimeout = t
+	}
+}
+
+// WithEventRecorder sets EventRecorder.
+func WithEventRecorder(r record.EventRecorder) Option {
+	return func(o *Options) {
+		o.EventRecorder = r
+	}
+}
+
+// WithBigQueryClient sets BigQueryClient.
+func WithBigQueryClient(c bigquery.Client) Option {
+	return func(o *Options) {
+		o.BigQueryClient = c
+	}
+}
+
+// WithBigQueryBatchSize sets BigQueryBatchSize.
+func WithBigQueryBatchSize(size int) Option {
+	return func(o *Options) {
+		o.BigQueryBatchSize = size
+	}
+}
+
+// WithBigQueryBatchDelay sets BigQueryBatchDelay.
+func WithBigQueryBatchDelay(d time.Duration) Option {
+	return func(o *Options) {
+		o.BigQueryBatchDelay = d
+	}
+}
+
+// WithArchiveStore sets ArchiveStore.
+func WithArchiveStore(s archive.BlobStore) Option {
+	return func(o *Options) {
+		o.ArchiveStore = s
+	}
+}
+
+// WithArchiveMaxEvents sets ArchiveMaxEvents.
+func WithArchiveMaxEvents(n int) Option {
+	return func(o *Options) {
+		o.ArchiveMaxEvents = n
+	}
+}
+
+// WithArchiveFlushDelay sets ArchiveFlushDelay.
+func WithArchiveFlushDelay(d time.Duration) Option {
+	return func(o *Options) {
+		o.ArchiveFlushDelay = d
+	}
+}
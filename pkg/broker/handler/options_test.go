@@ -96,3 +96,25 @@ func TestWithDeliveryTimeout(t *testing.T) {
 		t.Errorf("options timeout per event got=%v, want=%v", opt.DeliveryTimeout, want)
 	}
 }
+
+func TestWithMaxConcurrencyPerTarget(t *testing.T) {
+	want := 100
+	opt, err := NewOptions(WithMaxConcurrencyPerTarget(want))
+	if err != nil {
+		t.Errorf("NewOptions got unexpected error: %v", err)
+	}
+	if opt.MaxConcurrencyPerTarget != want {
+		t.Errorf("options max concurrency per target got=%d, want=%d", opt.MaxConcurrencyPerTarget, want)
+	}
+}
+
+func TestWithDeliveryHeadersPath(t *testing.T) {
+	want := "/var/run/delivery-headers"
+	opt, err := NewOptions(WithDeliveryHeadersPath(want))
+	if err != nil {
+		t.Errorf("NewOptions got unexpected error: %v", err)
+	}
+	if opt.DeliveryHeadersPath != want {
+		t.Errorf("options delivery headers path got=%q, want=%q", opt.DeliveryHeadersPath, want)
+	}
+}
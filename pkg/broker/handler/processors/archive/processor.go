@@ -0,0 +1,46 @@
+/*
+Copyright 2021 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package archive
+
+import (
+	"context"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+
+	"github.com/google/knative-gcp/pkg/broker/archive"
+	"github.com/google/knative-gcp/pkg/broker/eventutil"
+	"github.com/google/knative-gcp/pkg/broker/handler/processors"
+)
+
+// Processor archives the events of a Broker. It is the last processor of the archive handlers.
+type Processor struct {
+	processors.BaseProcessor
+
+	// Writer writes the events to the archive of the Broker.
+	Writer *archive.Writer
+}
+
+var _ processors.Interface = (*Processor)(nil)
+
+// Process returns once the event is archived, so that it is only acked once it is. Replayed events
+// are already in the archive and are skipped.
+func (p *Processor) Process(ctx context.Context, e *event.Event) error {
+	if _, ok := e.Extensions()[eventutil.ReplayTimeExtension]; ok {
+		return nil
+	}
+	return p.Writer.Write(ctx, e)
+}
@@ -0,0 +1,73 @@
+/*
+Copyright 2021 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package archive
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+
+	"github.com/google/knative-gcp/pkg/broker/archive"
+	"github.com/google/knative-gcp/pkg/broker/eventutil"
+)
+
+func TestProcessArchivesEvent(t *testing.T) {
+	ctx := context.Background()
+	store := &archive.LocalStore{Root: t.TempDir()}
+	p := &Processor{Writer: archive.NewWriter(store, "bucket", "ns/broker/", 1, time.Hour)}
+
+	e := event.New()
+	e.SetID("id")
+	e.SetSource("source")
+	e.SetType("type")
+	if err := p.Process(ctx, &e); err != nil {
+		t.Fatalf("Process() got error %v", err)
+	}
+
+	names, err := store.List(ctx, "bucket", "ns/broker/")
+	if err != nil {
+		t.Fatalf("List() got error %v", err)
+	}
+	if len(names) != 1 {
+		t.Errorf("Archive files got %v, want one", names)
+	}
+}
+
+func TestProcessSkipsReplayedEvent(t *testing.T) {
+	ctx := context.Background()
+	store := &archive.LocalStore{Root: t.TempDir()}
+	p := &Processor{Writer: archive.NewWriter(store, "bucket", "ns/broker/", 1, time.Hour)}
+
+	e := event.New()
+	e.SetID("id")
+	e.SetSource("source")
+	e.SetType("type")
+	e.SetExtension(eventutil.ReplayTimeExtension, time.Now())
+	if err := p.Process(ctx, &e); err != nil {
+		t.Fatalf("Process() got error %v", err)
+	}
+
+	names, err := store.List(ctx, "bucket", "ns/broker/")
+	if err != nil {
+		t.Fatalf("List() got error %v", err)
+	}
+	if len(names) != 0 {
+		t.Errorf("Archive files got %v, want none", names)
+	}
+}
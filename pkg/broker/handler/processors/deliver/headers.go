@@ -0,0 +1,105 @@
+/*
+Copyright 2021 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deliver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/binding"
+
+	"github.com/google/knative-gcp/pkg/broker/config"
+)
+
+// headerSecretTTL is how long the value of a header read from the mounted Secret is cached.
+const headerSecretTTL = 30 * time.Second
+
+// HeaderSecrets reads the values of the delivery headers from the files of the mounted delivery
+// headers Secret. A nil HeaderSecrets fails the deliveries to targets with such headers.
+type HeaderSecrets struct {
+	dir string
+
+	mu     sync.Mutex
+	values map[string]headerSecretValue
+}
+
+type headerSecretValue struct {
+	value   string
+	expires time.Time
+}
+
+// NewHeaderSecrets creates the HeaderSecrets reading the Secret mounted in the given directory.
+func NewHeaderSecrets(dir string) *HeaderSecrets {
+	return &HeaderSecrets{dir: dir, values: make(map[string]headerSecretValue)}
+}
+
+// Get returns the value of the given key of the Secret.
+func (s *HeaderSecrets) Get(key string) (string, error) {
+	if s == nil {
+		return "", errors.New("delivery headers secret is not mounted")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if v, ok := s.values[key]; ok && now.Before(v.expires) {
+		return v.value, nil
+	}
+	// The key is generated by the controller and cannot contain path separators.
+	b, err := ioutil.ReadFile(filepath.Join(s.dir, key))
+	if err != nil {
+		return "", fmt.Errorf("failed to read delivery header secret %q: %w", key, err)
+	}
+	s.values[key] = headerSecretValue{value: string(b), expires: now.Add(headerSecretTTL)}
+	return string(b), nil
+}
+
+// deliveryHeaders returns the headers to add to the requests to the target.
+func (p *Processor) deliveryHeaders(target *config.Target) (http.Header, error) {
+	if len(target.DeliveryHeaders) == 0 && len(target.DeliveryHeaderSecrets) == 0 {
+		return nil, nil
+	}
+	header := make(http.Header, len(target.DeliveryHeaders)+len(target.DeliveryHeaderSecrets))
+	for name, value := range target.DeliveryHeaders {
+		header.Set(name, value)
+	}
+	for name, key := range target.DeliveryHeaderSecrets {
+		value, err := p.HeaderSecrets.Get(key)
+		if err != nil {
+			return nil, err
+		}
+		header.Set(name, value)
+	}
+	return header, nil
+}
+
+// withDeliveryFormat returns the context forcing the encoding of the events delivered to the
+// target, if it has one.
+func withDeliveryFormat(ctx context.Context, target *config.Target) context.Context {
+	switch target.DeliveryFormat {
+	case config.DeliveryFormat_BINARY:
+		return binding.WithForceBinary(ctx)
+	case config.DeliveryFormat_STRUCTURED:
+		return binding.WithForceStructured(ctx)
+	}
+	return ctx
+}
@@ -0,0 +1,183 @@
+/*
+Copyright 2021 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deliver
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/knative-gcp/pkg/broker/config"
+)
+
+const (
+	// initialLimit is the concurrency limit of a target before any delivery to it completes.
+	initialLimit = 10
+	// decreaseFactor is the factor the limit is multiplied by when the target is congested.
+	decreaseFactor = 0.5
+	// latencyTolerance is how many times slower than the baseline latency a delivery can be before
+	// the target is considered congested.
+	latencyTolerance = 2
+	// baselineDrift is how fast the baseline latency follows slower deliveries, so that it adapts
+	// to targets getting permanently slower.
+	baselineDrift = 0.01
+)
+
+// ConcurrencyLimiters holds the adaptive concurrency limiter of each target. A nil
+// ConcurrencyLimiters doesn't limit deliveries.
+type ConcurrencyLimiters struct {
+	max      int
+	limiters sync.Map
+}
+
+// NewConcurrencyLimiters creates the ConcurrencyLimiters bounding the concurrency limit of each
+// target to max.
+func NewConcurrencyLimiters(max int) *ConcurrencyLimiters {
+	return &ConcurrencyLimiters{max: max}
+}
+
+// Get returns the limiter of the target, creating it if needed.
+func (ls *ConcurrencyLimiters) Get(key config.TargetKey) *ConcurrencyLimiter {
+	if l, ok := ls.limiters.Load(key); ok {
+		return l.(*ConcurrencyLimiter)
+	}
+	l, _ := ls.limiters.LoadOrStore(key, newConcurrencyLimiter(ls.max))
+	return l.(*ConcurrencyLimiter)
+}
+
+// Prune deletes the limiters of the targets that no longer exist.
+func (ls *ConcurrencyLimiters) Prune(targets config.ReadonlyTargets) {
+	ls.limiters.Range(func(k, _ interface{}) bool {
+		key := k.(config.TargetKey)
+		if _, ok := targets.GetTargetByKey(&key); !ok {
+			ls.limiters.Delete(key)
+		}
+		return true
+	})
+}
+
+// ConcurrencyLimiter bounds the number of concurrent deliveries to a target. Its limit is adapted
+// with additive-increase/multiplicative-decrease: it grows by one for every limit's worth of
+// deliveries that succeed without congestion, and is halved when a delivery fails or is much
+// slower than the baseline latency of the target.
+type ConcurrencyLimiter struct {
+	max int
+
+	mu       sync.Mutex
+	limit    float64
+	inFlight int
+	waiters  []chan struct{}
+	// baseline is the latency of the target when it is not congested.
+	baseline time.Duration
+	// lastDecrease is when the limit was last decreased. Deliveries started before are not
+	// considered for another decrease, as they may have been slowed by the same congestion.
+	lastDecrease time.Time
+}
+
+func newConcurrencyLimiter(max int) *ConcurrencyLimiter {
+	limit := initialLimit
+	if limit > max {
+		limit = max
+	}
+	return &ConcurrencyLimiter{max: max, limit: float64(limit)}
+}
+
+// Limit returns the current concurrency limit.
+func (l *ConcurrencyLimiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return int(l.limit)
+}
+
+// Acquire waits until a delivery can start. The returned release function must be called with
+// the error of the delivery once it completes. It returns the error of the context if the context
+// is done first.
+func (l *ConcurrencyLimiter) Acquire(ctx context.Context) (func(error), error) {
+	l.mu.Lock()
+	if l.inFlight < int(l.limit) {
+		l.inFlight++
+		l.mu.Unlock()
+		return l.releaseFunc(time.Now()), nil
+	}
+	ch := make(chan struct{})
+	l.waiters = append(l.waiters, ch)
+	l.mu.Unlock()
+
+	select {
+	case <-ch:
+		return l.releaseFunc(time.Now()), nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		for i, w := range l.waiters {
+			if w == ch {
+				l.waiters = append(l.waiters[:i], l.waiters[i+1:]...)
+				return nil, ctx.Err()
+			}
+		}
+		// The slot was granted concurrently, give it back.
+		l.inFlight--
+		l.grant()
+		return nil, ctx.Err()
+	}
+}
+
+// releaseFunc returns the function releasing a delivery started at the given time.
+func (l *ConcurrencyLimiter) releaseFunc(start time.Time) func(error) {
+	return func(err error) {
+		l.release(start, time.Since(start), err)
+	}
+}
+
+// release adapts the limit to the outcome of a delivery and starts waiting deliveries.
+func (l *ConcurrencyLimiter) release(start time.Time, latency time.Duration, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.inFlight--
+
+	congested := err != nil || (l.baseline > 0 && latency > latencyTolerance*l.baseline)
+	if err == nil {
+		if l.baseline == 0 || latency < l.baseline {
+			l.baseline = latency
+		} else {
+			l.baseline += time.Duration(float64(latency-l.baseline) * baselineDrift)
+		}
+	}
+	switch {
+	case !congested:
+		l.limit += 1 / l.limit
+		if l.limit > float64(l.max) {
+			l.limit = float64(l.max)
+		}
+	case start.After(l.lastDecrease):
+		l.limit *= decreaseFactor
+		if l.limit < 1 {
+			l.limit = 1
+		}
+		l.lastDecrease = time.Now()
+	}
+	l.grant()
+}
+
+// grant starts waiting deliveries while the limit allows it. The lock must be held.
+func (l *ConcurrencyLimiter) grant() {
+	for len(l.waiters) > 0 && l.inFlight < int(l.limit) {
+		close(l.waiters[0])
+		l.waiters = l.waiters[1:]
+		l.inFlight++
+	}
+}
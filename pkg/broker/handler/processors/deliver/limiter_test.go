@@ -0,0 +1,144 @@
+/*
+Copyright 2021 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deliver
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/knative-gcp/pkg/broker/config"
+	"github.com/google/knative-gcp/pkg/broker/config/memory"
+)
+
+func TestConcurrencyLimiterBoundsInFlight(t *testing.T) {
+	l := newConcurrencyLimiter(2)
+	ctx := context.Background()
+	release1, err := l.Acquire(ctx)
+	if err != nil {
+		t.Fatalf("Acquire got unexpected error: %v", err)
+	}
+	if _, err := l.Acquire(ctx); err != nil {
+		t.Fatalf("Acquire got unexpected error: %v", err)
+	}
+
+	// The third delivery waits for a slot.
+	timeoutCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if _, err := l.Acquire(timeoutCtx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Acquire over the limit got error %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	acquired := make(chan struct{})
+	go func() {
+		if _, err := l.Acquire(ctx); err == nil {
+			close(acquired)
+		}
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("Acquire over the limit did not wait")
+	case <-time.After(50 * time.Millisecond):
+	}
+	release1(nil)
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("Acquire did not get the released slot")
+	}
+}
+
+func TestConcurrencyLimiterAdaptsLimit(t *testing.T) {
+	l := newConcurrencyLimiter(20)
+	if got := l.Limit(); got != initialLimit {
+		t.Fatalf("initial limit got %d, want %d", got, initialLimit)
+	}
+
+	// Successful deliveries at the baseline latency increase the limit by about one per limit's
+	// worth.
+	start := time.Now()
+	for i := 0; i < initialLimit+1; i++ {
+		l.release(start, time.Millisecond, nil)
+		l.inFlight++
+	}
+	if got := l.Limit(); got != initialLimit+1 {
+		t.Errorf("limit after successes got %d, want %d", got, initialLimit+1)
+	}
+
+	// A failure halves the limit.
+	l.release(time.Now(), time.Millisecond, errors.New("failure"))
+	l.inFlight++
+	if got := l.Limit(); got != (initialLimit+1)/2 {
+		t.Errorf("limit after failure got %d, want %d", got, (initialLimit+1)/2)
+	}
+
+	// Deliveries started before the decrease don't decrease the limit again.
+	l.release(start, time.Millisecond, errors.New("failure"))
+	l.inFlight++
+	if got := l.Limit(); got != (initialLimit+1)/2 {
+		t.Errorf("limit after earlier failure got %d, want %d", got, (initialLimit+1)/2)
+	}
+
+	// A delivery much slower than the baseline halves the limit.
+	l.release(time.Now(), time.Second, nil)
+	l.inFlight++
+	if got := l.Limit(); got != (initialLimit+1)/4 {
+		t.Errorf("limit after slow delivery got %d, want %d", got, (initialLimit+1)/4)
+	}
+
+	// The limit never drops below one.
+	for i := 0; i < 10; i++ {
+		l.release(time.Now().Add(time.Duration(i+1)*time.Second), time.Millisecond, errors.New("failure"))
+		l.inFlight++
+	}
+	if got := l.Limit(); got != 1 {
+		t.Errorf("limit after failures got %d, want 1", got)
+	}
+}
+
+func TestConcurrencyLimiterMax(t *testing.T) {
+	l := newConcurrencyLimiter(3)
+	for i := 0; i < 100; i++ {
+		l.release(time.Now(), time.Millisecond, nil)
+		l.inFlight++
+	}
+	if got := l.Limit(); got != 3 {
+		t.Errorf("limit got %d, want 3", got)
+	}
+}
+
+func TestConcurrencyLimitersPrune(t *testing.T) {
+	targets := memory.NewEmptyTargets()
+	target := &config.Target{Namespace: "ns", Name: "target", CellTenantType: config.CellTenantType_BROKER, CellTenantName: "broker"}
+	removed := &config.Target{Namespace: "ns", Name: "removed", CellTenantType: config.CellTenantType_BROKER, CellTenantName: "broker"}
+	targets.MutateCellTenant(config.TestOnlyBrokerKey("ns", "broker"), func(m config.CellTenantMutation) {
+		m.UpsertTargets(target)
+	})
+
+	ls := NewConcurrencyLimiters(10)
+	l := ls.Get(*target.Key())
+	ls.Get(*removed.Key())
+	ls.Prune(targets)
+
+	if got := ls.Get(*target.Key()); got != l {
+		t.Error("Prune deleted the limiter of an existing target")
+	}
+	if _, ok := ls.limiters.Load(*removed.Key()); ok {
+		t.Error("Prune kept the limiter of a removed target")
+	}
+}
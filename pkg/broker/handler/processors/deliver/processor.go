@@ -22,6 +22,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
@@ -35,6 +36,9 @@ import (
 	"github.com/google/knative-gcp/pkg/logging"
 	"go.opencensus.io/trace"
 	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 
 	"github.com/google/knative-gcp/pkg/broker/config"
 	"github.com/google/knative-gcp/pkg/broker/eventutil"
@@ -43,7 +47,42 @@ import (
 	"github.com/google/knative-gcp/pkg/metrics"
 )
 
-const defaultEventHopsLimit int32 = 255
+const (
+	defaultEventHopsLimit int32 = 255
+
+	// maxRetryAfter bounds the delay that a target can request with the Retry-After header.
+	maxRetryAfter = 10 * time.Minute
+)
+
+// ErrNonRetryable is wrapped by the delivery failures that must not be retried.
+var ErrNonRetryable = errors.New("non-retryable delivery failure")
+
+// RetryAfterError is a retryable delivery failure for which the target requested a delay before
+// the event is delivered again.
+type RetryAfterError struct {
+	// Delay is the requested delay.
+	Delay time.Duration
+	// Err is the delivery failure.
+	Err error
+}
+
+func (e *RetryAfterError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RetryAfterError) Unwrap() error {
+	return e.Err
+}
+
+// RetryAfter returns the delay the target requested before the event is delivered again, if the
+// delivery failure carries one.
+func RetryAfter(err error) (time.Duration, bool) {
+	var retryAfterErr *RetryAfterError
+	if errors.As(err, &retryAfterErr) {
+		return retryAfterErr.Delay, true
+	}
+	return 0, false
+}
 
 // Processor delivers events based on the broker/target in the context.
 type Processor struct {
@@ -60,7 +99,7 @@ type Processor struct {
 	RetryOnFailure bool
 
 	// DeliverRetryClient is the cloudevents client to send events
-	// to the retry topic.
+	// to the retry topic and to the dead letter topic.
 	DeliverRetryClient ceclient.Client
 
 	// DeliverTimeout is the timeout applied to cancel delivery.
@@ -69,6 +108,25 @@ type Processor struct {
 
 	// StatsReporter is used to report delivery metrics.
 	StatsReporter *metrics.DeliveryReporter
+
+	// Limiters bound the concurrent deliveries to each target. If nil, deliveries are not
+	// limited.
+	Limiters *ConcurrencyLimiters
+
+	// HeaderSecrets reads the values of the delivery headers from the mounted Secret.
+	HeaderSecrets *HeaderSecrets
+
+	// Recorder emits Kubernetes events on the Triggers whose replies loop back to them. If nil,
+	// no Kubernetes event is emitted.
+	Recorder record.EventRecorder
+
+	// Topics publishes the events to the targets that are Pub/Sub topics. If nil, the deliveries
+	// to such targets fail.
+	Topics *Topics
+
+	// Tables streams the events into the targets that are BigQuery tables. If nil, the deliveries
+	// to such targets fail.
+	Tables *Tables
 }
 
 var _ processors.Interface = (*Processor)(nil)
@@ -104,6 +162,30 @@ func (p *Processor) Process(ctx context.Context, e *event.Event) error {
 		return nil
 	}
 
+	if expired(target, e, time.Now()) {
+		p.StatsReporter.ReportEventExpired(ctx)
+		return p.sendToDeadLetterTopic(ctx, target, e, "expired", errors.New("event exceeded the max event age"))
+	}
+
+	// The retries for which the target requested a delay are not delivered before it elapses.
+	if retryAt, ok := eventutil.RetryAt(e); ok && !p.RetryOnFailure {
+		if delay := time.Until(retryAt); delay > 0 {
+			return &RetryAfterError{Delay: delay, Err: fmt.Errorf("event retry is not due until %v", retryAt)}
+		}
+	}
+
+	// Replies that come back to a Trigger they descend from would loop until their hops are
+	// exhausted, amplifying the events at every turn. The lineage is only tracked for the Brokers
+	// that opted into reply loop detection, whose ingress keeps it.
+	var lineage []string
+	if broker.ReplyLoopDetection {
+		lineage = eventutil.GetLineage(e)
+	}
+	if broker.ReplyLoopDetection && target.CellTenantType == config.CellTenantType_BROKER && target.Id != "" && eventutil.InLineage(lineage, target.Id) {
+		p.recordLoop(target, e)
+		return p.sendToDeadLetterTopic(ctx, target, e, "reply loop", errors.New("event descends from a reply of the target's subscriber"))
+	}
+
 	// Hops is a broker local counter so remove any hops value before forwarding.
 	// Do not modify the original event as we need to send the original
 	// event to retry queue on failure.
@@ -129,7 +211,10 @@ func (p *Processor) Process(ctx context.Context, e *event.Event) error {
 		defer cancel()
 	}
 
-	if err := p.deliver(dctx, target, broker, eventutil.NewImmutableEventMessage(e), hops); err != nil {
+	if err := p.limitedDeliver(ctx, dctx, target, broker, eventutil.NewImmutableEventMessage(e), hops, lineage); err != nil {
+		if errors.Is(err, ErrNonRetryable) {
+			return p.sendToDeadLetterTopic(ctx, target, e, "non-retryable response", err)
+		}
 		if !p.RetryOnFailure {
 			return err
 		}
@@ -140,14 +225,46 @@ func (p *Processor) Process(ctx context.Context, e *event.Event) error {
 			"enqueueing for retry",
 		)
 
-		return p.sendToRetryTopic(ctx, target, e)
+		return p.sendToRetryTopic(ctx, target, e, err)
 	}
 	// For post-delivery processing.
 	return p.Next().Process(ctx, e)
 }
 
-// deliver delivers msg to target and sends the target's reply to the broker ingress.
-func (p *Processor) deliver(ctx context.Context, target *config.Target, broker *config.CellTenant, msg binding.Message, hops int32) error {
+// limitedDeliver delivers msg to target once the concurrency limit of the target allows it. The
+// wait is bounded by ctx, the delivery itself by dctx.
+func (p *Processor) limitedDeliver(ctx, dctx context.Context, target *config.Target, broker *config.CellTenant, msg binding.Message, hops int32, lineage []string) error {
+	if p.Limiters == nil {
+		return p.deliver(dctx, target, broker, msg, hops, lineage)
+	}
+	l := p.Limiters.Get(*target.Key())
+	release, err := l.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed waiting for the concurrency limit of the target: %w", err)
+	}
+	err = p.deliver(dctx, target, broker, msg, hops, lineage)
+	// Non-retryable responses don't indicate that the target is congested.
+	if errors.Is(err, ErrNonRetryable) {
+		release(nil)
+	} else {
+		release(err)
+	}
+	p.StatsReporter.ReportConcurrencyLimit(ctx, l.Limit())
+	return err
+}
+
+// deliver delivers msg to target and sends the target's reply to the broker ingress. lineage is
+// the lineage of msg, which the reply extends with the target.
+func (p *Processor) deliver(ctx context.Context, target *config.Target, broker *config.CellTenant, msg binding.Message, hops int32, lineage []string) error {
+	if project, topic, ok := pubsubTopic(target.Address); ok {
+		// Pub/Sub topics don't reply.
+		return p.publishToTopic(ctx, target, msg, project, topic)
+	}
+	if table, ok := bigQueryTable(target.Address); ok {
+		// BigQuery tables don't reply either.
+		return p.insertIntoTable(ctx, target, msg, table)
+	}
+
 	// Channels can have a reply address without a subscriber. So default the replyMessage to the
 	// original message. If there is a subscriber, then replyMessage is overwritten.
 	replyMessage := msg
@@ -185,9 +302,13 @@ func (p *Processor) deliver(ctx context.Context, target *config.Target, broker *
 		// Hops only exist for the Broker. Nothing else uses them.
 		// Attach the previous hops for the reply.
 		transformers = append(transformers, eventutil.SetRemainingHopsTransformer(hops))
+		// So does the lineage. Older configs don't have target IDs.
+		if broker.ReplyLoopDetection && target.Id != "" {
+			transformers = append(transformers, eventutil.SetLineageTransformer(eventutil.AppendLineage(lineage, target.Id)))
+		}
 	}
 
-	replyResp, err := p.sendMsg(ctx, replyAddress, replyMessage, transformers...)
+	replyResp, err := p.sendMsg(ctx, replyAddress, replyMessage, nil, transformers...)
 	if err != nil {
 		return fmt.Errorf("failed to send event to reply: %w", err)
 	}
@@ -208,9 +329,18 @@ func (p *Processor) sendToSubscriber(ctx context.Context, target *config.Target,
 	transformers := []binding.Transformer{
 		// Remove hops from forwarded event.
 		transformer.DeleteExtension(eventutil.HopsAttribute),
+		// Remove the retry target and time from retried events.
+		transformer.DeleteExtension(eventutil.RetryTargetAttribute),
+		transformer.DeleteExtension(eventutil.RetryAtAttribute),
+		// Remove the lineage of replies.
+		transformer.DeleteExtension(eventutil.LineageAttribute),
+	}
+	header, err := p.deliveryHeaders(target)
+	if err != nil {
+		return nil, func() {}, err
 	}
 	startTime := time.Now()
-	resp, err := p.sendMsg(ctx, target.Address, msg, transformers...)
+	resp, err := p.sendMsg(withDeliveryFormat(ctx, target), target.Address, msg, header, transformers...)
 	if err != nil {
 		var result *url.Error
 		if errors.As(err, &result) && result.Timeout() {
@@ -234,8 +364,12 @@ func (p *Processor) sendToSubscriber(ctx context.Context, target *config.Target,
 	// Report event dispatch time with resp status code.
 	p.StatsReporter.ReportEventDispatchTime(cctx, time.Since(startTime))
 
+	if err := classifyResponse(target, resp, time.Now()); err != nil {
+		return nil, closeBody, err
+	}
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, closeBody, fmt.Errorf("event delivery failed: HTTP status code %d", resp.StatusCode)
+		// The target classifies the response as successful, but only 2xx responses carry replies.
+		return nil, closeBody, nil
 	}
 
 	// Pre-check the reply response header, if it's not in structured mode/batched mode or binary mode,
@@ -301,21 +435,146 @@ func (p *Processor) sendToSubscriber(ctx context.Context, target *config.Target,
 	return nil, closeBody, nil
 }
 
-func (p *Processor) sendMsg(ctx context.Context, address string, msg binding.Message, transformers ...binding.Transformer) (*http.Response, error) {
+// classifyResponse returns nil if the target's response is a successful delivery. Otherwise, it
+// returns an error wrapping ErrNonRetryable if the delivery must not be retried, or a
+// RetryAfterError if the target requested a delay before retrying with a 429 or 503 response.
+func classifyResponse(target *config.Target, resp *http.Response, now time.Time) error {
+	if c := target.ResponseClassification; c != nil {
+		if containsStatusCode(c.SuccessCodes, resp.StatusCode) {
+			return nil
+		}
+		if containsStatusCode(c.NonRetryableCodes, resp.StatusCode) {
+			return fmt.Errorf("event delivery failed: HTTP status code %d: %w", resp.StatusCode, ErrNonRetryable)
+		}
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	err := fmt.Errorf("event delivery failed: HTTP status code %d", resp.StatusCode)
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		if delay, ok := parseRetryAfter(resp.Header.Get("Retry-After"), now); ok {
+			return &RetryAfterError{Delay: delay, Err: err}
+		}
+	}
+	return err
+}
+
+func containsStatusCode(codes []int32, code int) bool {
+	for _, c := range codes {
+		if int(c) == code {
+			return true
+		}
+	}
+	return false
+}
+
+// parseRetryAfter parses the value of a Retry-After header, either a number of seconds or an HTTP
+// date, into a positive delay bounded by maxRetryAfter.
+func parseRetryAfter(v string, now time.Time) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	var delay time.Duration
+	if seconds, err := strconv.ParseInt(v, 10, 64); err == nil {
+		if seconds > int64(maxRetryAfter/time.Second) {
+			return maxRetryAfter, true
+		}
+		delay = time.Duration(seconds) * time.Second
+	} else if t, err := http.ParseTime(v); err == nil {
+		delay = t.Sub(now)
+	}
+	if delay <= 0 {
+		return 0, false
+	}
+	if delay > maxRetryAfter {
+		delay = maxRetryAfter
+	}
+	return delay, true
+}
+
+func (p *Processor) sendMsg(ctx context.Context, address string, msg binding.Message, header http.Header, transformers ...binding.Transformer) (*http.Response, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, address, nil)
 	if err != nil {
 		return nil, err
 	}
+	// The event is written after the headers, so that its attributes take precedence.
+	for name, values := range header {
+		req.Header[name] = values
+	}
 	if err := cehttp.WriteRequest(ctx, msg, req, transformers...); err != nil {
 		return nil, err
 	}
 	return p.DeliverClient.Do(req)
 }
 
-func (p *Processor) sendToRetryTopic(ctx context.Context, target *config.Target, event *event.Event) error {
+func (p *Processor) sendToRetryTopic(ctx context.Context, target *config.Target, event *event.Event, cause error) error {
+	// The retry topic may be shared by the targets of the CellTenant, so the retry is tagged with
+	// the target for its retry subscription to select it. The event is shared with the other
+	// targets, so it is copied first.
+	retry := event.Clone()
+	retry.SetExtension(eventutil.RetryTargetAttribute, target.Id)
+	// The delay requested by the target travels with the retry, rather than holding the event
+	// until it elapses.
+	if delay, ok := RetryAfter(cause); ok {
+		eventutil.SetRetryAt(&retry, time.Now().Add(delay))
+	} else {
+		retry.SetExtension(eventutil.RetryAtAttribute, nil)
+	}
 	pctx := cecontext.WithTopic(ctx, target.RetryQueue.Topic)
-	if err := p.DeliverRetryClient.Send(pctx, *event); err != nil {
+	if err := p.DeliverRetryClient.Send(pctx, retry); err != nil {
 		return fmt.Errorf("failed to send event to retry topic: %w", err)
 	}
 	return nil
 }
+
+// sendToDeadLetterTopic sends an event that must not be delivered to the target, for the given
+// reason, to the target's dead letter topic. The event is dropped if the target has none.
+func (p *Processor) sendToDeadLetterTopic(ctx context.Context, target *config.Target, event *event.Event, reason string, cause error) error {
+	if target.DeadLetterQueue == nil || target.DeadLetterQueue.Topic == "" {
+		logging.FromContext(ctx).Warn("event not delivered to target, dropping it",
+			zap.Stringer("target", target.Key()), zap.String("event.id", event.ID()), zap.String("reason", reason), zap.Error(cause))
+		trace.FromContext(ctx).Annotate(
+			[]trace.Attribute{trace.StringAttribute("error_message", cause.Error())},
+			"event dropped: "+reason,
+		)
+		return nil
+	}
+
+	logging.FromContext(ctx).Warn("event not delivered to target, dead lettering it",
+		zap.Stringer("target", target.Key()), zap.String("event.id", event.ID()), zap.String("reason", reason), zap.Error(cause))
+	trace.FromContext(ctx).Annotate(
+		[]trace.Attribute{trace.StringAttribute("error_message", cause.Error())},
+		"enqueueing for dead letter: "+reason,
+	)
+	pctx := cecontext.WithTopic(ctx, target.DeadLetterQueue.Topic)
+	if err := p.DeliverRetryClient.Send(pctx, *event); err != nil {
+		return fmt.Errorf("failed to send event to dead letter topic: %w", err)
+	}
+	return nil
+}
+
+// recordLoop emits a Kubernetes event on the Trigger of the target whose replies looped back to it.
+func (p *Processor) recordLoop(target *config.Target, e *event.Event) {
+	if p.Recorder == nil {
+		return
+	}
+	trigger := &corev1.ObjectReference{
+		APIVersion: "eventing.knative.dev/v1",
+		Kind:       "Trigger",
+		Namespace:  target.Namespace,
+		Name:       target.Name,
+		UID:        types.UID(target.Id),
+	}
+	p.Recorder.Eventf(trigger, corev1.EventTypeWarning, "ReplyLoopDetected",
+		"Event %q from source %q descends from a reply of the Trigger's subscriber and was not delivered to it again", e.ID(), e.Source())
+}
+
+// expired returns whether the event exceeded the max event age of the target. Events without an
+// arrival time never expire.
+func expired(target *config.Target, e *event.Event, now time.Time) bool {
+	if target.MaxEventAge == nil {
+		return false
+	}
+	age, ok := eventutil.Age(e, now)
+	return ok && age > target.MaxEventAge.AsDuration()
+}
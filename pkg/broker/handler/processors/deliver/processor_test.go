@@ -24,7 +24,10 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
 	"runtime"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -42,8 +45,12 @@ import (
 	"go.uber.org/zap/zaptest"
 	"google.golang.org/api/option"
 	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"k8s.io/client-go/tools/record"
 	"knative.dev/pkg/logging"
 	logtest "knative.dev/pkg/logging/testing"
+	"knative.dev/pkg/metrics/metricskey"
+	"knative.dev/pkg/metrics/metricstest"
 
 	"github.com/google/knative-gcp/pkg/broker/config"
 	"github.com/google/knative-gcp/pkg/broker/config/memory"
@@ -872,3 +879,768 @@ func newSampleEvent() *event.Event {
 	sampleEvent.SetTime(time.Now())
 	return &sampleEvent
 }
+
+func TestDeliverResponseClassification(t *testing.T) {
+	cases := []struct {
+		name           string
+		withRetry      bool
+		respCode       int
+		classification *config.ResponseClassification
+		deadLetter     bool
+		// topics are the topics to create. Events sent to other topics fail.
+		topics        []string
+		wantErr       bool
+		wantPublished int
+	}{{
+		name:           "classified as success",
+		withRetry:      true,
+		respCode:       http.StatusConflict,
+		classification: &config.ResponseClassification{SuccessCodes: []int32{http.StatusConflict}},
+	}, {
+		name:           "non-retryable sent to dead letter topic",
+		withRetry:      true,
+		respCode:       http.StatusBadRequest,
+		classification: &config.ResponseClassification{NonRetryableCodes: []int32{http.StatusBadRequest}},
+		deadLetter:     true,
+		topics:         []string{"test-dead-letter-topic"},
+		wantPublished:  1,
+	}, {
+		name:           "non-retryable sent to dead letter topic without retry",
+		respCode:       http.StatusBadRequest,
+		classification: &config.ResponseClassification{NonRetryableCodes: []int32{http.StatusBadRequest}},
+		deadLetter:     true,
+		topics:         []string{"test-dead-letter-topic"},
+		wantPublished:  1,
+	}, {
+		name:           "non-retryable dead letter failure",
+		withRetry:      true,
+		respCode:       http.StatusBadRequest,
+		classification: &config.ResponseClassification{NonRetryableCodes: []int32{http.StatusBadRequest}},
+		deadLetter:     true,
+		topics:         []string{"test-retry-topic"},
+		wantErr:        true,
+	}, {
+		name:           "non-retryable dropped without dead letter topic",
+		withRetry:      true,
+		respCode:       http.StatusBadRequest,
+		classification: &config.ResponseClassification{NonRetryableCodes: []int32{http.StatusBadRequest}},
+		topics:         []string{"test-retry-topic"},
+	}, {
+		name:          "unclassified retried",
+		withRetry:     true,
+		respCode:      http.StatusBadRequest,
+		deadLetter:    true,
+		topics:        []string{"test-retry-topic"},
+		wantPublished: 1,
+	}}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			reportertest.ResetDeliveryMetrics()
+			ctx := logtest.TestContextWithLogger(t)
+			targetSvr := httptest.NewServer(&targetWithFailureHandler{t: t, respCode: tc.respCode})
+			defer targetSvr.Close()
+
+			srv, c, close := testPubsubClient(ctx, t, "test-project")
+			defer close()
+			for _, topic := range tc.topics {
+				if _, err := c.CreateTopic(ctx, topic); err != nil {
+					t.Fatalf("failed to create test pubsub topc: %v", err)
+				}
+			}
+			ps, err := cepubsub.New(ctx, cepubsub.WithClient(c), cepubsub.WithProjectID("test-project"))
+			if err != nil {
+				t.Fatalf("failed to create pubsub protocol: %v", err)
+			}
+			deliverRetryClient, err := ceclient.New(ps)
+			if err != nil {
+				t.Fatalf("failed to create cloudevents client: %v", err)
+			}
+
+			broker := &config.CellTenant{
+				Type:      config.CellTenantType_BROKER,
+				Namespace: "ns",
+				Name:      "broker",
+			}
+			target := &config.Target{
+				Namespace:      "ns",
+				Name:           "target",
+				CellTenantType: config.CellTenantType_BROKER,
+				CellTenantName: "broker",
+				Address:        targetSvr.URL,
+				RetryQueue: &config.Queue{
+					Topic: "test-retry-topic",
+				},
+				ResponseClassification: tc.classification,
+			}
+			if tc.deadLetter {
+				target.DeadLetterQueue = &config.Queue{Topic: "test-dead-letter-topic"}
+			}
+			testTargets := memory.NewEmptyTargets()
+			testTargets.MutateCellTenant(broker.Key(), func(bm config.CellTenantMutation) {
+				bm.UpsertTargets(target)
+			})
+			ctx = handlerctx.WithBrokerKey(ctx, broker.Key())
+			ctx = handlerctx.WithTargetKey(ctx, target.Key())
+
+			r, err := metrics.NewDeliveryReporter("pod", "container")
+			if err != nil {
+				t.Fatal(err)
+			}
+			p := &Processor{
+				DeliverClient:      http.DefaultClient,
+				Targets:            testTargets,
+				RetryOnFailure:     tc.withRetry,
+				DeliverRetryClient: deliverRetryClient,
+				StatsReporter:      r,
+			}
+
+			err = p.Process(ctx, newSampleEvent())
+			if (err != nil) != tc.wantErr {
+				t.Errorf("processing got error=%v, want=%v", err, tc.wantErr)
+			}
+			if got := len(srv.Messages()); got != tc.wantPublished {
+				t.Errorf("Unexpected number of published events. Want %d, Got %d", tc.wantPublished, got)
+			}
+		})
+	}
+}
+
+func TestDeliverExpiredEvent(t *testing.T) {
+	now := time.Now()
+	cases := []struct {
+		name        string
+		maxEventAge time.Duration
+		arrival     time.Time
+		deliverAt   time.Time
+		deadLetter  bool
+		wantExpired bool
+		// wantPublished is the number of events sent to the dead letter topic.
+		wantPublished int
+	}{{
+		name:    "no max event age",
+		arrival: now.Add(-time.Hour),
+	}, {
+		name:        "not expired",
+		maxEventAge: time.Hour,
+		arrival:     now.Add(-time.Minute),
+	}, {
+		name:        "expired and dropped",
+		maxEventAge: time.Minute,
+		arrival:     now.Add(-time.Hour),
+		wantExpired: true,
+	}, {
+		name:          "expired and dead lettered",
+		maxEventAge:   time.Minute,
+		arrival:       now.Add(-time.Hour),
+		deadLetter:    true,
+		wantExpired:   true,
+		wantPublished: 1,
+	}, {
+		name:        "age counts from the scheduled delivery time",
+		maxEventAge: time.Minute,
+		arrival:     now.Add(-time.Hour),
+		deliverAt:   now.Add(-time.Second),
+	}}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			reportertest.ResetDeliveryMetrics()
+			ctx := logtest.TestContextWithLogger(t)
+			delivered := make(chan struct{}, 1)
+			targetSvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				delivered <- struct{}{}
+				w.WriteHeader(http.StatusAccepted)
+			}))
+			defer targetSvr.Close()
+
+			srv, c, close := testPubsubClient(ctx, t, "test-project")
+			defer close()
+			if _, err := c.CreateTopic(ctx, "test-dead-letter-topic"); err != nil {
+				t.Fatalf("failed to create test pubsub topc: %v", err)
+			}
+			ps, err := cepubsub.New(ctx, cepubsub.WithClient(c), cepubsub.WithProjectID("test-project"))
+			if err != nil {
+				t.Fatalf("failed to create pubsub protocol: %v", err)
+			}
+			deliverRetryClient, err := ceclient.New(ps)
+			if err != nil {
+				t.Fatalf("failed to create cloudevents client: %v", err)
+			}
+
+			broker := &config.CellTenant{
+				Type:      config.CellTenantType_BROKER,
+				Namespace: "ns",
+				Name:      "broker",
+			}
+			target := &config.Target{
+				Namespace:      "ns",
+				Name:           "target",
+				CellTenantType: config.CellTenantType_BROKER,
+				CellTenantName: "broker",
+				Address:        targetSvr.URL,
+			}
+			if tc.maxEventAge > 0 {
+				target.MaxEventAge = durationpb.New(tc.maxEventAge)
+			}
+			if tc.deadLetter {
+				target.DeadLetterQueue = &config.Queue{Topic: "test-dead-letter-topic"}
+			}
+			testTargets := memory.NewEmptyTargets()
+			testTargets.MutateCellTenant(broker.Key(), func(bm config.CellTenantMutation) {
+				bm.UpsertTargets(target)
+			})
+			ctx = handlerctx.WithBrokerKey(ctx, broker.Key())
+			ctx = handlerctx.WithTargetKey(ctx, target.Key())
+
+			r, err := metrics.NewDeliveryReporter("pod", "container")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ctx, err = r.AddTags(ctx); err != nil {
+				t.Fatal(err)
+			}
+			p := &Processor{
+				DeliverClient:      http.DefaultClient,
+				Targets:            testTargets,
+				DeliverRetryClient: deliverRetryClient,
+				StatsReporter:      r,
+			}
+
+			e := newSampleEvent()
+			e.SetExtension(eventutil.ArrivalTimeExtension, tc.arrival)
+			if !tc.deliverAt.IsZero() {
+				e.SetExtension(eventutil.DeliverAtExtension, tc.deliverAt)
+			}
+			if err := p.Process(ctx, e); err != nil {
+				t.Errorf("unexpected error processing event: %v", err)
+			}
+			if gotDelivered := len(delivered) == 1; gotDelivered == tc.wantExpired {
+				t.Errorf("event delivered got %v, want %v", gotDelivered, !tc.wantExpired)
+			}
+			if got := len(srv.Messages()); got != tc.wantPublished {
+				t.Errorf("Unexpected number of published events. Want %d, Got %d", tc.wantPublished, got)
+			}
+			if tc.wantExpired {
+				metricstest.CheckCountData(t, "expired_event_count", map[string]string{
+					metricskey.PodName:       "pod",
+					metricskey.ContainerName: "container",
+				}, 1)
+			} else {
+				metricstest.CheckStatsNotReported(t, "expired_event_count")
+			}
+		})
+	}
+}
+
+func TestDeliverWithConcurrencyLimit(t *testing.T) {
+	reportertest.ResetDeliveryMetrics()
+	ctx := logtest.TestContextWithLogger(t)
+	targetSvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer targetSvr.Close()
+
+	broker := &config.CellTenant{
+		Type:      config.CellTenantType_BROKER,
+		Namespace: "ns",
+		Name:      "broker",
+	}
+	target := &config.Target{
+		Namespace:      "ns",
+		Name:           "target",
+		CellTenantType: config.CellTenantType_BROKER,
+		CellTenantName: "broker",
+		Address:        targetSvr.URL,
+	}
+	testTargets := memory.NewEmptyTargets()
+	testTargets.MutateCellTenant(broker.Key(), func(bm config.CellTenantMutation) {
+		bm.UpsertTargets(target)
+	})
+	ctx = handlerctx.WithBrokerKey(ctx, broker.Key())
+	ctx = handlerctx.WithTargetKey(ctx, target.Key())
+
+	r, err := metrics.NewDeliveryReporter("pod", "container")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ctx, err = r.AddTags(ctx); err != nil {
+		t.Fatal(err)
+	}
+	p := &Processor{
+		DeliverClient: http.DefaultClient,
+		Targets:       testTargets,
+		StatsReporter: r,
+		Limiters:      NewConcurrencyLimiters(5),
+	}
+
+	if err := p.Process(ctx, newSampleEvent()); err != nil {
+		t.Fatalf("unexpected error processing event: %v", err)
+	}
+	if got := p.Limiters.Get(*target.Key()).inFlight; got != 0 {
+		t.Errorf("in flight deliveries after processing got %d, want 0", got)
+	}
+	metricstest.CheckLastValueData(t, "concurrency_limit", map[string]string{
+		metricskey.PodName:       "pod",
+		metricskey.ContainerName: "container",
+	}, 5)
+}
+
+func TestDeliverReplyLoop(t *testing.T) {
+	reportertest.ResetDeliveryMetrics()
+	ctx := logtest.TestContextWithLogger(t)
+	var deliveries int32
+	targetSvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&deliveries, 1)
+		if v := req.Header.Get("ce-" + eventutil.LineageAttribute); v != "" {
+			t.Errorf("subscriber received the lineage %q", v)
+		}
+		// Reply with an event matching the Trigger's filter again.
+		w.Header().Set("ce-specversion", "1.0")
+		w.Header().Set("ce-id", "reply")
+		w.Header().Set("ce-source", "source")
+		w.Header().Set("ce-type", "type")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer targetSvr.Close()
+	lineages := make(chan string, 1)
+	ingressSvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		lineages <- req.Header.Get("ce-" + eventutil.LineageAttribute)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer ingressSvr.Close()
+
+	broker := &config.CellTenant{
+		Type:      config.CellTenantType_BROKER,
+		Namespace: "ns",
+		Name:      "broker",
+	}
+	target := &config.Target{
+		Id:             "trigger-uid",
+		Namespace:      "ns",
+		Name:           "target",
+		CellTenantType: config.CellTenantType_BROKER,
+		CellTenantName: "broker",
+		Address:        targetSvr.URL,
+		ReplyAddress:   ingressSvr.URL,
+	}
+	testTargets := memory.NewEmptyTargets()
+	testTargets.MutateCellTenant(broker.Key(), func(bm config.CellTenantMutation) {
+		bm.SetReplyLoopDetection(true)
+		bm.UpsertTargets(target)
+	})
+	ctx = handlerctx.WithBrokerKey(ctx, broker.Key())
+	ctx = handlerctx.WithTargetKey(ctx, target.Key())
+
+	r, err := metrics.NewDeliveryReporter("pod", "container")
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder := record.NewFakeRecorder(10)
+	p := &Processor{
+		DeliverClient: http.DefaultClient,
+		Targets:       testTargets,
+		StatsReporter: r,
+		Recorder:      recorder,
+	}
+
+	if err := p.Process(ctx, newSampleEvent()); err != nil {
+		t.Fatalf("unexpected error processing event: %v", err)
+	}
+	var lineage string
+	select {
+	case lineage = <-lineages:
+	case <-time.After(time.Second):
+		t.Fatal("reply was not sent to the broker ingress")
+	}
+	if !eventutil.InLineage(strings.Split(lineage, "."), target.Id) {
+		t.Fatalf("reply lineage %q doesn't contain the target", lineage)
+	}
+
+	// The reply comes back to the Trigger through the Broker.
+	reply := newSampleEvent()
+	reply.SetID("reply")
+	reply.SetExtension(eventutil.LineageAttribute, lineage)
+	if err := p.Process(ctx, reply); err != nil {
+		t.Fatalf("unexpected error processing reply: %v", err)
+	}
+	if got := atomic.LoadInt32(&deliveries); got != 1 {
+		t.Errorf("subscriber received %d events, want 1", got)
+	}
+	select {
+	case e := <-recorder.Events:
+		if !strings.Contains(e, "ReplyLoopDetected") {
+			t.Errorf("recorded event %q, want a ReplyLoopDetected event", e)
+		}
+	default:
+		t.Error("no event recorded on the Trigger")
+	}
+
+	// Without reply loop detection, the lineage is neither checked nor extended.
+	testTargets.MutateCellTenant(broker.Key(), func(bm config.CellTenantMutation) {
+		bm.SetReplyLoopDetection(false)
+	})
+	if err := p.Process(ctx, reply); err != nil {
+		t.Fatalf("unexpected error processing reply: %v", err)
+	}
+	if got := atomic.LoadInt32(&deliveries); got != 2 {
+		t.Errorf("subscriber received %d events, want 2", got)
+	}
+	select {
+	case lineage = <-lineages:
+	case <-time.After(time.Second):
+		t.Fatal("reply was not sent to the broker ingress")
+	}
+	if lineage != "" {
+		t.Errorf("reply lineage got %q, want none", lineage)
+	}
+}
+
+func TestDeliverFormatAndHeaders(t *testing.T) {
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "ns_credentials_api-key"), []byte("secret-value"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name            string
+		format          config.DeliveryFormat
+		headers         map[string]string
+		headerSecrets   map[string]string
+		wantContentType string
+		wantHeaders     map[string]string
+		wantErr         bool
+	}{{
+		name:            "binary by default",
+		wantContentType: "application/json",
+		wantHeaders:     map[string]string{"Ce-Id": "id"},
+	}, {
+		name:            "structured",
+		format:          config.DeliveryFormat_STRUCTURED,
+		wantContentType: "application/cloudevents+json",
+		wantHeaders:     map[string]string{"Ce-Id": ""},
+	}, {
+		name:            "static and secret headers",
+		headers:         map[string]string{"X-Tenant-Id": "tenant-1"},
+		headerSecrets:   map[string]string{"X-Api-Key": "ns_credentials_api-key"},
+		wantContentType: "application/json",
+		wantHeaders:     map[string]string{"X-Tenant-Id": "tenant-1", "X-Api-Key": "secret-value"},
+	}, {
+		name:          "missing secret",
+		headerSecrets: map[string]string{"X-Api-Key": "ns_credentials_missing"},
+		wantErr:       true,
+	}}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			reportertest.ResetDeliveryMetrics()
+			ctx := logtest.TestContextWithLogger(t)
+			requests := make(chan *http.Request, 1)
+			targetSvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				requests <- req
+				w.WriteHeader(http.StatusAccepted)
+			}))
+			defer targetSvr.Close()
+
+			broker := &config.CellTenant{
+				Type:      config.CellTenantType_BROKER,
+				Namespace: "ns",
+				Name:      "broker",
+			}
+			target := &config.Target{
+				Namespace:             "ns",
+				Name:                  "target",
+				CellTenantType:        config.CellTenantType_BROKER,
+				CellTenantName:        "broker",
+				Address:               targetSvr.URL,
+				DeliveryFormat:        tc.format,
+				DeliveryHeaders:       tc.headers,
+				DeliveryHeaderSecrets: tc.headerSecrets,
+			}
+			testTargets := memory.NewEmptyTargets()
+			testTargets.MutateCellTenant(broker.Key(), func(bm config.CellTenantMutation) {
+				bm.UpsertTargets(target)
+			})
+			ctx = handlerctx.WithBrokerKey(ctx, broker.Key())
+			ctx = handlerctx.WithTargetKey(ctx, target.Key())
+
+			r, err := metrics.NewDeliveryReporter("pod", "container")
+			if err != nil {
+				t.Fatal(err)
+			}
+			p := &Processor{
+				DeliverClient: http.DefaultClient,
+				Targets:       testTargets,
+				StatsReporter: r,
+				HeaderSecrets: NewHeaderSecrets(dir),
+			}
+
+			e := newSampleEvent()
+			if err := e.SetData(event.ApplicationJSON, map[string]string{"foo": "bar"}); err != nil {
+				t.Fatal(err)
+			}
+			err = p.Process(ctx, e)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("process error got %v, want error %v", err, tc.wantErr)
+			}
+			if tc.wantErr {
+				if len(requests) != 0 {
+					t.Error("event delivered despite the missing header secret")
+				}
+				return
+			}
+			req := <-requests
+			if got := req.Header.Get("Content-Type"); got != tc.wantContentType {
+				t.Errorf("Content-Type got %q, want %q", got, tc.wantContentType)
+			}
+			for name, want := range tc.wantHeaders {
+				if got := req.Header.Get(name); got != want {
+					t.Errorf("header %s got %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestDeliverRetryAfter(t *testing.T) {
+	reportertest.ResetDeliveryMetrics()
+	ctx := logtest.TestContextWithLogger(t)
+	targetSvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Retry-After", "5")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer targetSvr.Close()
+
+	broker := &config.CellTenant{
+		Type:      config.CellTenantType_BROKER,
+		Namespace: "ns",
+		Name:      "broker",
+	}
+	target := &config.Target{
+		Namespace:      "ns",
+		Name:           "target",
+		CellTenantType: config.CellTenantType_BROKER,
+		CellTenantName: "broker",
+		Address:        targetSvr.URL,
+	}
+	testTargets := memory.NewEmptyTargets()
+	testTargets.MutateCellTenant(broker.Key(), func(bm config.CellTenantMutation) {
+		bm.UpsertTargets(target)
+	})
+	ctx = handlerctx.WithBrokerKey(ctx, broker.Key())
+	ctx = handlerctx.WithTargetKey(ctx, target.Key())
+
+	r, err := metrics.NewDeliveryReporter("pod", "container")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &Processor{
+		DeliverClient: http.DefaultClient,
+		Targets:       testTargets,
+		StatsReporter: r,
+	}
+
+	err = p.Process(ctx, newSampleEvent())
+	if err == nil {
+		t.Fatal("processing got nil error, want error")
+	}
+	if delay, ok := RetryAfter(err); !ok || delay != 5*time.Second {
+		t.Errorf("RetryAfter got (%v, %v), want (%v, true)", delay, ok, 5*time.Second)
+	}
+}
+
+func TestDeliverRetryAfterToRetryTopic(t *testing.T) {
+	reportertest.ResetDeliveryMetrics()
+	ctx := logtest.TestContextWithLogger(t)
+	retryAfter := "60"
+	targetSvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if retryAfter != "" {
+			w.Header().Set("Retry-After", retryAfter)
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer targetSvr.Close()
+
+	srv, c, close := testPubsubClient(ctx, t, "test-project")
+	defer close()
+	if _, err := c.CreateTopic(ctx, "test-retry-topic"); err != nil {
+		t.Fatalf("failed to create test pubsub topc: %v", err)
+	}
+	ps, err := cepubsub.New(ctx, cepubsub.WithClient(c), cepubsub.WithProjectID("test-project"))
+	if err != nil {
+		t.Fatalf("failed to create pubsub protocol: %v", err)
+	}
+	deliverRetryClient, err := ceclient.New(ps)
+	if err != nil {
+		t.Fatalf("failed to create cloudevents client: %v", err)
+	}
+
+	broker := &config.CellTenant{
+		Type:      config.CellTenantType_BROKER,
+		Namespace: "ns",
+		Name:      "broker",
+	}
+	target := &config.Target{
+		Id:             "target-id",
+		Namespace:      "ns",
+		Name:           "target",
+		CellTenantType: config.CellTenantType_BROKER,
+		CellTenantName: "broker",
+		Address:        targetSvr.URL,
+		RetryQueue:     &config.Queue{Topic: "test-retry-topic"},
+	}
+	testTargets := memory.NewEmptyTargets()
+	testTargets.MutateCellTenant(broker.Key(), func(bm config.CellTenantMutation) {
+		bm.UpsertTargets(target)
+	})
+	ctx = handlerctx.WithBrokerKey(ctx, broker.Key())
+	ctx = handlerctx.WithTargetKey(ctx, target.Key())
+
+	r, err := metrics.NewDeliveryReporter("pod", "container")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &Processor{
+		DeliverClient:      http.DefaultClient,
+		Targets:            testTargets,
+		RetryOnFailure:     true,
+		DeliverRetryClient: deliverRetryClient,
+		StatsReporter:      r,
+	}
+
+	start := time.Now()
+	if err := p.Process(ctx, newSampleEvent()); err != nil {
+		t.Fatalf("unexpected error processing event: %v", err)
+	}
+	msgs := srv.Messages()
+	if len(msgs) != 1 {
+		t.Fatalf("Unexpected number of published events. Want 1, Got %d", len(msgs))
+	}
+	retryAt, err := time.Parse(time.RFC3339Nano, msgs[0].Attributes["ce-"+eventutil.RetryAtAttribute])
+	if err != nil {
+		t.Fatalf("failed to parse the retry time of the retry: %v", err)
+	}
+	if retryAt.Before(start.Add(time.Minute)) || retryAt.After(time.Now().Add(time.Minute)) {
+		t.Errorf("retry time got %v, want a minute after the delivery", retryAt)
+	}
+
+	// A retry time the event already carries is not kept when the target asks for no delay.
+	retryAfter = ""
+	srv.ClearMessages()
+	e := newSampleEvent()
+	eventutil.SetRetryAt(e, time.Now().Add(time.Hour))
+	if err := p.Process(ctx, e); err != nil {
+		t.Fatalf("unexpected error processing event: %v", err)
+	}
+	msgs = srv.Messages()
+	if len(msgs) != 1 {
+		t.Fatalf("Unexpected number of published events. Want 1, Got %d", len(msgs))
+	}
+	if got, ok := msgs[0].Attributes["ce-"+eventutil.RetryAtAttribute]; ok {
+		t.Errorf("retry time got %q, want none", got)
+	}
+}
+
+func TestDeliverRetryNotDue(t *testing.T) {
+	cases := []struct {
+		name        string
+		retryAt     time.Time
+		wantDeliver bool
+	}{{
+		name:        "not due",
+		retryAt:     time.Now().Add(time.Minute),
+		wantDeliver: false,
+	}, {
+		name:        "due",
+		retryAt:     time.Now().Add(-time.Second),
+		wantDeliver: true,
+	}}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			reportertest.ResetDeliveryMetrics()
+			ctx := logtest.TestContextWithLogger(t)
+			delivered := make(chan *http.Request, 1)
+			targetSvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				delivered <- req
+				w.WriteHeader(http.StatusAccepted)
+			}))
+			defer targetSvr.Close()
+
+			broker := &config.CellTenant{
+				Type:      config.CellTenantType_BROKER,
+				Namespace: "ns",
+				Name:      "broker",
+			}
+			target := &config.Target{
+				Namespace:      "ns",
+				Name:           "target",
+				CellTenantType: config.CellTenantType_BROKER,
+				CellTenantName: "broker",
+				Address:        targetSvr.URL,
+			}
+			testTargets := memory.NewEmptyTargets()
+			testTargets.MutateCellTenant(broker.Key(), func(bm config.CellTenantMutation) {
+				bm.UpsertTargets(target)
+			})
+			ctx = handlerctx.WithBrokerKey(ctx, broker.Key())
+			ctx = handlerctx.WithTargetKey(ctx, target.Key())
+
+			r, err := metrics.NewDeliveryReporter("pod", "container")
+			if err != nil {
+				t.Fatal(err)
+			}
+			p := &Processor{
+				DeliverClient: http.DefaultClient,
+				Targets:       testTargets,
+				StatsReporter: r,
+			}
+
+			e := newSampleEvent()
+			eventutil.SetRetryAt(e, tc.retryAt)
+			err = p.Process(ctx, e)
+			if !tc.wantDeliver {
+				delay, ok := RetryAfter(err)
+				if !ok || delay <= 0 || delay > time.Minute {
+					t.Errorf("RetryAfter got (%v, %v), want a delay up to a minute", delay, ok)
+				}
+				if len(delivered) != 0 {
+					t.Error("event delivered before its retry time")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error processing event: %v", err)
+			}
+			req := <-delivered
+			if got := req.Header.Get("ce-" + eventutil.RetryAtAttribute); got != "" {
+				t.Errorf("delivered event has retry time %q, want none", got)
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	cases := []struct {
+		value     string
+		wantDelay time.Duration
+		wantOK    bool
+	}{
+		{value: ""},
+		{value: "invalid"},
+		{value: "0"},
+		{value: "-1"},
+		{value: "30", wantDelay: 30 * time.Second, wantOK: true},
+		{value: "3600", wantDelay: maxRetryAfter, wantOK: true},
+		{value: now.Add(time.Minute).Format(http.TimeFormat), wantDelay: time.Minute, wantOK: true},
+		{value: now.Add(-time.Minute).Format(http.TimeFormat)},
+	}
+	for _, tc := range cases {
+		t.Run(tc.value, func(t *testing.T) {
+			delay, ok := parseRetryAfter(tc.value, now)
+			if delay != tc.wantDelay || ok != tc.wantOK {
+				t.Errorf("parseRetryAfter(%q) got (%v, %v), want (%v, %v)", tc.value, delay, ok, tc.wantDelay, tc.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,309 @@
+/*
+Copyright 2021 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deliver
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/cloudevents/sdk-go/v2/binding"
+	"github.com/cloudevents/sdk-go/v2/binding/transformer"
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/cloudevents/sdk-go/v2/types"
+	"google.golang.org/api/googleapi"
+
+	"github.com/google/knative-gcp/pkg/broker/config"
+	"github.com/google/knative-gcp/pkg/broker/eventutil"
+	"github.com/google/knative-gcp/pkg/gclient/bigquery"
+)
+
+const (
+	// bigQueryScheme is the scheme of the addresses of the targets that are BigQuery tables,
+	// "bigquery://<project>/<dataset>/<table>".
+	bigQueryScheme = "bigquery://"
+
+	// dataColumn is the column of the rows holding the data of the events as JSON.
+	dataColumn = "data"
+
+	// insertTimeout bounds the insertion of a batch of rows, which is shared by the deliveries of
+	// all its events.
+	insertTimeout = 30 * time.Second
+)
+
+// Tables streams the events as rows into the targets that are BigQuery tables. The rows inserted
+// into the same table with the same options are batched. A nil Tables fails the deliveries to such
+// targets.
+type Tables struct {
+	client     bigquery.Client
+	batchSize  int
+	batchDelay time.Duration
+	tables     sync.Map
+}
+
+// NewTables creates the Tables inserting with the given client. A batch is inserted once it has
+// batchSize rows, or batchDelay after its first row.
+func NewTables(client bigquery.Client, batchSize int, batchDelay time.Duration) *Tables {
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	return &Tables{client: client, batchSize: batchSize, batchDelay: batchDelay}
+}
+
+// Insert inserts the row into the table with the next batch, and returns its error once the batch
+// is inserted.
+func (ts *Tables) Insert(ctx context.Context, table bigquery.Table, opts bigquery.InsertOptions, row bigquery.Row) error {
+	return ts.get(tableKey{table: table, opts: opts}).insert(ctx, row)
+}
+
+func (ts *Tables) get(key tableKey) *tableBatcher {
+	if b, ok := ts.tables.Load(key); ok {
+		return b.(*tableBatcher)
+	}
+	b, _ := ts.tables.LoadOrStore(key, &tableBatcher{tables: ts, key: key})
+	return b.(*tableBatcher)
+}
+
+// tableKey identifies the batches of the rows inserted into a table with the same options.
+type tableKey struct {
+	table bigquery.Table
+	opts  bigquery.InsertOptions
+}
+
+// Prune forgets the batchers of the tables that the targets no longer insert into. Their pending
+// batches are still inserted.
+func (ts *Tables) Prune(targets config.ReadonlyTargets) {
+	used := make(map[tableKey]bool)
+	targets.RangeAllTargets(func(t *config.Target) bool {
+		if table, ok := bigQueryTable(t.Address); ok {
+			used[tableKey{table: table, opts: insertOptions(t)}] = true
+		}
+		return true
+	})
+	ts.tables.Range(func(k, _ interface{}) bool {
+		if !used[k.(tableKey)] {
+			ts.tables.Delete(k)
+		}
+		return true
+	})
+}
+
+// pendingRow is a row waiting for its batch to be inserted.
+type pendingRow struct {
+	row  bigquery.Row
+	done chan error
+}
+
+// tableBatcher batches the rows inserted into a table.
+type tableBatcher struct {
+	tables *Tables
+	key    tableKey
+
+	mu      sync.Mutex
+	pending []*pendingRow
+	timer   *time.Timer
+}
+
+func (b *tableBatcher) insert(ctx context.Context, row bigquery.Row) error {
+	r := &pendingRow{row: row, done: make(chan error, 1)}
+	b.mu.Lock()
+	b.pending = append(b.pending, r)
+	switch {
+	case len(b.pending) >= b.tables.batchSize:
+		batch := b.takeLocked()
+		go b.flush(batch)
+	case len(b.pending) == 1:
+		b.timer = time.AfterFunc(b.tables.batchDelay, b.flushPending)
+	}
+	b.mu.Unlock()
+
+	select {
+	case err := <-r.done:
+		return err
+	case <-ctx.Done():
+		// The row may still be inserted, it is deduplicated by its insert ID if the event is
+		// delivered again.
+		return ctx.Err()
+	}
+}
+
+// takeLocked takes the pending rows. b.mu must be held.
+func (b *tableBatcher) takeLocked() []*pendingRow {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	batch := b.pending
+	b.pending = nil
+	return batch
+}
+
+func (b *tableBatcher) flushPending() {
+	b.mu.Lock()
+	batch := b.takeLocked()
+	b.mu.Unlock()
+	if len(batch) > 0 {
+		b.flush(batch)
+	}
+}
+
+// flush inserts the batch and returns each row its own error.
+func (b *tableBatcher) flush(batch []*pendingRow) {
+	ctx, cancel := context.WithTimeout(context.Background(), insertTimeout)
+	defer cancel()
+	rows := make([]bigquery.Row, len(batch))
+	for i, r := range batch {
+		rows[i] = r.row
+	}
+	rowErrs, err := b.tables.client.InsertRows(ctx, b.key.table, rows, b.key.opts)
+	for i, r := range batch {
+		switch {
+		case err != nil:
+			r.done <- err
+		case i < len(rowErrs):
+			r.done <- rowErrs[i]
+		default:
+			r.done <- nil
+		}
+	}
+}
+
+// bigQueryTable returns the BigQuery table of the target address, if it is one.
+func bigQueryTable(address string) (bigquery.Table, bool) {
+	if !strings.HasPrefix(address, bigQueryScheme) {
+		return bigquery.Table{}, false
+	}
+	parts := strings.Split(strings.TrimPrefix(address, bigQueryScheme), "/")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return bigquery.Table{}, false
+	}
+	return bigquery.Table{Project: parts[0], Dataset: parts[1], Table: parts[2]}, true
+}
+
+// insertOptions returns the options of the insertion of the rows into the BigQuery table of the
+// target.
+func insertOptions(target *config.Target) bigquery.InsertOptions {
+	return bigquery.InsertOptions{
+		IgnoreUnknownValues: target.GetBigqueryDelivery().GetIgnoreUnknownValues(),
+	}
+}
+
+// insertIntoTable streams msg as a row into the BigQuery table of the target. The table doesn't
+// reply. The errors of the row are retried through the retry topic, while the failures of the
+// request that retrying cannot fix, such as a missing table or permission, wrap ErrNonRetryable.
+func (p *Processor) insertIntoTable(ctx context.Context, target *config.Target, msg binding.Message, table bigquery.Table) error {
+	if p.Tables == nil {
+		return errors.New("inserting into BigQuery tables is not enabled")
+	}
+	e, err := binding.ToEvent(ctx, msg,
+		// Remove hops from forwarded event.
+		transformer.DeleteExtension(eventutil.HopsAttribute),
+		// Remove the retry target and time from retried events.
+		transformer.DeleteExtension(eventutil.RetryTargetAttribute),
+		transformer.DeleteExtension(eventutil.RetryAtAttribute),
+		// Remove the lineage of replies.
+		transformer.DeleteExtension(eventutil.LineageAttribute),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to read the event: %w", err)
+	}
+	row, err := eventRow(target, e)
+	if err != nil {
+		return fmt.Errorf("failed to convert the event to a row: %v: %w", err, ErrNonRetryable)
+	}
+
+	startTime := time.Now()
+	err = p.Tables.Insert(ctx, table, insertOptions(target), row)
+	p.StatsReporter.ReportEventDispatchTime(ctx, time.Since(startTime))
+	if err == nil {
+		return nil
+	}
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		switch apiErr.Code {
+		case http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound:
+			return fmt.Errorf("event insertion failed: %v: %w", err, ErrNonRetryable)
+		}
+	}
+	return fmt.Errorf("event insertion failed: %w", err)
+}
+
+// eventRow converts the event to a row with a column per attribute and extension, and the data
+// as JSON in the data column. The insert ID identifies the event and the target, so that BigQuery
+// deduplicates the redeliveries of the event.
+func eventRow(target *config.Target, e *event.Event) (bigquery.Row, error) {
+	values := make(map[string]interface{}, 8+len(e.Extensions()))
+	for name, v := range e.Extensions() {
+		s, err := types.Format(v)
+		if err != nil {
+			return bigquery.Row{}, fmt.Errorf("extension %q: %w", name, err)
+		}
+		values[name] = s
+	}
+	values["id"] = e.ID()
+	values["source"] = e.Source()
+	values["specversion"] = e.SpecVersion()
+	values["type"] = e.Type()
+	if s := e.Subject(); s != "" {
+		values["subject"] = s
+	}
+	if t := e.Time(); !t.IsZero() {
+		values["time"] = t.UTC().Format(time.RFC3339Nano)
+	}
+	if ct := e.DataContentType(); ct != "" {
+		values["datacontenttype"] = ct
+	}
+	if ds := e.DataSchema(); ds != "" {
+		values["dataschema"] = ds
+	}
+	if data := e.Data(); len(data) > 0 {
+		values[dataColumn] = jsonData(data)
+	}
+
+	h := sha256.New()
+	for _, s := range []string{target.Key().String(), e.Source(), e.ID()} {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	return bigquery.Row{
+		InsertID: hex.EncodeToString(h.Sum(nil)),
+		Values:   values,
+	}, nil
+}
+
+// jsonData returns the data as JSON: as is if it is JSON already, as a JSON string otherwise. The
+// data that is not text is base64 encoded.
+func jsonData(data []byte) string {
+	if json.Valid(data) {
+		return string(data)
+	}
+	var b []byte
+	if utf8.Valid(data) {
+		b, _ = json.Marshal(string(data))
+	} else {
+		b, _ = json.Marshal(data)
+	}
+	return string(b)
+}
@@ -0,0 +1,323 @@
+/*
+Copyright 2021 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deliver
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	cepubsub "github.com/cloudevents/sdk-go/protocol/pubsub/v2"
+	ceclient "github.com/cloudevents/sdk-go/v2/client"
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/api/googleapi"
+	logtest "knative.dev/pkg/logging/testing"
+
+	"github.com/google/knative-gcp/pkg/broker/config"
+	"github.com/google/knative-gcp/pkg/broker/config/memory"
+	"github.com/google/knative-gcp/pkg/broker/eventutil"
+	handlerctx "github.com/google/knative-gcp/pkg/broker/handler/context"
+	"github.com/google/knative-gcp/pkg/gclient/bigquery"
+	bqtesting "github.com/google/knative-gcp/pkg/gclient/bigquery/testing"
+	"github.com/google/knative-gcp/pkg/metrics"
+	reportertest "github.com/google/knative-gcp/pkg/metrics/testing"
+)
+
+func TestDeliverToBigQueryTable(t *testing.T) {
+	table := bigquery.Table{Project: "other-project", Dataset: "analytics", Table: "events"}
+	// knownColumns are the columns of the table, which has none for the tenant extension.
+	knownColumns := []string{"id", "source", "subject", "specversion", "type", "time", "datacontenttype", "data"}
+	cases := []struct {
+		name string
+		// data configures the test BigQuery client with the insert ID of the row of the event.
+		data             func(insertID string) bqtesting.TestClientData
+		bigqueryDelivery *config.BigQueryDelivery
+		eventData        interface{}
+		// wantValues are the values of the row inserted into the table.
+		wantValues     map[string]interface{}
+		wantRetry      bool
+		wantDeadLetter bool
+	}{{
+		name:      "inserted",
+		eventData: map[string]string{"key": "value"},
+		wantValues: map[string]interface{}{
+			"id":              "id",
+			"source":          "source",
+			"subject":         "subject",
+			"specversion":     "1.0",
+			"type":            "type",
+			"tenant":          "tenant-1",
+			"datacontenttype": "application/json",
+			"data":            `{"key":"value"}`,
+		},
+	}, {
+		name:      "text data inserted as a JSON string",
+		eventData: "not json",
+		wantValues: map[string]interface{}{
+			"id":              "id",
+			"source":          "source",
+			"subject":         "subject",
+			"specversion":     "1.0",
+			"type":            "type",
+			"tenant":          "tenant-1",
+			"datacontenttype": "text/plain",
+			"data":            `"not json"`,
+		},
+	}, {
+		name: "row with unknown columns is retried",
+		data: func(string) bqtesting.TestClientData {
+			return bqtesting.TestClientData{Columns: knownColumns}
+		},
+		eventData: map[string]string{"key": "value"},
+		wantRetry: true,
+	}, {
+		name: "unknown columns ignored",
+		data: func(string) bqtesting.TestClientData {
+			return bqtesting.TestClientData{Columns: knownColumns}
+		},
+		bigqueryDelivery: &config.BigQueryDelivery{IgnoreUnknownValues: true},
+		eventData:        map[string]string{"key": "value"},
+		wantValues: map[string]interface{}{
+			"id":              "id",
+			"source":          "source",
+			"subject":         "subject",
+			"specversion":     "1.0",
+			"type":            "type",
+			"datacontenttype": "application/json",
+			"data":            `{"key":"value"}`,
+		},
+	}, {
+		name: "row error is retried",
+		data: func(insertID string) bqtesting.TestClientData {
+			return bqtesting.TestClientData{RowErrs: map[string]error{insertID: &bigquery.RowError{Reason: "invalid", Message: "no such field"}}}
+		},
+		wantRetry: true,
+	}, {
+		name: "missing table is not retried",
+		data: func(string) bqtesting.TestClientData {
+			return bqtesting.TestClientData{InsertErr: &googleapi.Error{Code: http.StatusNotFound, Message: "Not found: Table"}}
+		},
+		wantDeadLetter: true,
+	}}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			reportertest.ResetDeliveryMetrics()
+			ctx := logtest.TestContextWithLogger(t)
+			srv, c, close := testPubsubClient(ctx, t, "test-project")
+			defer close()
+			for _, topic := range []string{"test-retry-topic", "test-dead-letter-topic"} {
+				if _, err := c.CreateTopic(ctx, topic); err != nil {
+					t.Fatalf("failed to create test pubsub topic: %v", err)
+				}
+			}
+			ps, err := cepubsub.New(ctx, cepubsub.WithClient(c), cepubsub.WithProjectID("test-project"))
+			if err != nil {
+				t.Fatalf("failed to create pubsub protocol: %v", err)
+			}
+			deliverRetryClient, err := ceclient.New(ps)
+			if err != nil {
+				t.Fatalf("failed to create cloudevents client: %v", err)
+			}
+
+			broker := &config.CellTenant{
+				Type:      config.CellTenantType_BROKER,
+				Namespace: "ns",
+				Name:      "broker",
+				Address:   "http://broker.example.com",
+			}
+			target := &config.Target{
+				Id:               "target-uid",
+				Namespace:        "ns",
+				Name:             "target",
+				CellTenantType:   config.CellTenantType_BROKER,
+				CellTenantName:   "broker",
+				Address:          "bigquery://other-project/analytics/events",
+				ReplyAddress:     broker.Address,
+				RetryQueue:       &config.Queue{Topic: "test-retry-topic"},
+				DeadLetterQueue:  &config.Queue{Topic: "test-dead-letter-topic"},
+				BigqueryDelivery: tc.bigqueryDelivery,
+			}
+			testTargets := memory.NewEmptyTargets()
+			testTargets.MutateCellTenant(broker.Key(), func(bm config.CellTenantMutation) {
+				bm.UpsertTargets(target)
+			})
+			ctx = handlerctx.WithBrokerKey(ctx, broker.Key())
+			ctx = handlerctx.WithTargetKey(ctx, target.Key())
+
+			e := newSampleEvent()
+			e.SetExtension("tenant", "tenant-1")
+			e.SetExtension(eventutil.HopsAttribute, 10)
+			if tc.eventData != nil {
+				contentType := event.ApplicationJSON
+				if _, ok := tc.eventData.(string); ok {
+					contentType = event.TextPlain
+				}
+				if err := e.SetData(contentType, tc.eventData); err != nil {
+					t.Fatal(err)
+				}
+			}
+			row, err := eventRow(target, e)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var data bqtesting.TestClientData
+			if tc.data != nil {
+				data = tc.data(row.InsertID)
+			}
+			bq := bqtesting.NewTestClient(data)
+
+			r, err := metrics.NewDeliveryReporter("pod", "container")
+			if err != nil {
+				t.Fatal(err)
+			}
+			p := &Processor{
+				// Replies are not sent for the events inserted into tables.
+				DeliverClient:      &http.Client{Transport: fakeRoundTripper{}},
+				Targets:            testTargets,
+				RetryOnFailure:     true,
+				DeliverRetryClient: deliverRetryClient,
+				StatsReporter:      r,
+				Tables:             NewTables(bq, 10, time.Millisecond),
+			}
+			if err := p.Process(ctx, e); err != nil {
+				t.Fatalf("unexpected error processing event: %v", err)
+			}
+
+			msgs := srv.Messages()
+			if tc.wantRetry || tc.wantDeadLetter {
+				if len(msgs) != 1 {
+					t.Fatalf("Unexpected number of retried or dead lettered events. Want 1, Got %d", len(msgs))
+				}
+				_, retried := msgs[0].Attributes["ce-"+eventutil.RetryTargetAttribute]
+				if retried != tc.wantRetry {
+					t.Errorf("Event retried got %v, want %v", retried, tc.wantRetry)
+				}
+				if rows := bq.Rows(table); len(rows) != 0 {
+					t.Errorf("Unexpected rows inserted: %v", rows)
+				}
+				return
+			}
+			if len(msgs) != 0 {
+				t.Fatalf("Unexpected number of published messages. Want 0, Got %d", len(msgs))
+			}
+			rows := bq.Rows(table)
+			if len(rows) != 1 {
+				t.Fatalf("Unexpected number of inserted rows. Want 1, Got %d", len(rows))
+			}
+			if rows[0].InsertID != row.InsertID {
+				t.Errorf("Inserted row ID got %q, want %q", rows[0].InsertID, row.InsertID)
+			}
+			// The event time is set when the event is created.
+			delete(rows[0].Values, "time")
+			if diff := cmp.Diff(tc.wantValues, rows[0].Values); diff != "" {
+				t.Error("Inserted row values (-want, +got) =", diff)
+			}
+		})
+	}
+}
+
+func TestTablesBatching(t *testing.T) {
+	ctx := logtest.TestContextWithLogger(t)
+	table := bigquery.Table{Project: "other-project", Dataset: "analytics", Table: "events"}
+	rowErr := errors.New("row error")
+	bq := bqtesting.NewTestClient(bqtesting.TestClientData{RowErrs: map[string]error{"row-1": rowErr}})
+	// The batch delay is long enough for the batch to only be inserted once full.
+	tables := NewTables(bq, 3, time.Hour)
+
+	var wg sync.WaitGroup
+	errs := make([]error, 3)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = tables.Insert(ctx, table, bigquery.InsertOptions{}, bigquery.Row{InsertID: []string{"row-0", "row-1", "row-2"}[i]})
+		}(i)
+	}
+	wg.Wait()
+
+	if got := bq.Requests(); got != 1 {
+		t.Errorf("Insert requests got %d, want 1", got)
+	}
+	if errs[0] != nil || errs[1] != rowErr || errs[2] != nil {
+		t.Errorf("Row errors got %v, want [<nil> %v <nil>]", errs, rowErr)
+	}
+	if got := len(bq.Rows(table)); got != 2 {
+		t.Errorf("Inserted rows got %d, want 2", got)
+	}
+
+	// A batch that is not full is inserted after the batch delay.
+	tables = NewTables(bq, 3, time.Millisecond)
+	if err := tables.Insert(ctx, table, bigquery.InsertOptions{}, bigquery.Row{InsertID: "row-3"}); err != nil {
+		t.Errorf("Unexpected error inserting row: %v", err)
+	}
+	if got := bq.Requests(); got != 2 {
+		t.Errorf("Insert requests got %d, want 2", got)
+	}
+
+	// The rows inserted with other options are batched separately.
+	tables = NewTables(bq, 2, time.Millisecond)
+	wg.Add(2)
+	for i, opts := range []bigquery.InsertOptions{{}, {IgnoreUnknownValues: true}} {
+		go func(i int, opts bigquery.InsertOptions) {
+			defer wg.Done()
+			errs[i] = tables.Insert(ctx, table, opts, bigquery.Row{InsertID: fmt.Sprintf("row-%d", 4+i)})
+		}(i, opts)
+	}
+	wg.Wait()
+	if got := bq.Requests(); got != 4 {
+		t.Errorf("Insert requests got %d, want 4", got)
+	}
+}
+
+func TestTablesPrune(t *testing.T) {
+	tables := NewTables(bqtesting.NewTestClient(bqtesting.TestClientData{}), 10, time.Millisecond)
+	usedTable := bigquery.Table{Project: "other-project", Dataset: "analytics", Table: "used"}
+	used := tables.get(tableKey{table: usedTable})
+	tables.get(tableKey{table: bigquery.Table{Project: "other-project", Dataset: "analytics", Table: "unused"}})
+	// The table is no longer used with these options.
+	tables.get(tableKey{table: usedTable, opts: bigquery.InsertOptions{IgnoreUnknownValues: true}})
+
+	broker := &config.CellTenant{Type: config.CellTenantType_BROKER, Namespace: "ns", Name: "broker"}
+	testTargets := memory.NewEmptyTargets()
+	testTargets.MutateCellTenant(broker.Key(), func(bm config.CellTenantMutation) {
+		bm.UpsertTargets(&config.Target{
+			Namespace:      "ns",
+			Name:           "target",
+			CellTenantType: config.CellTenantType_BROKER,
+			CellTenantName: "broker",
+			Address:        "bigquery://other-project/analytics/used",
+		})
+	})
+	tables.Prune(testTargets)
+
+	var got []tableKey
+	tables.tables.Range(func(k, _ interface{}) bool {
+		got = append(got, k.(tableKey))
+		return true
+	})
+	if diff := cmp.Diff([]tableKey{{table: usedTable}}, got, cmp.AllowUnexported(tableKey{})); diff != "" {
+		t.Error("Tables after pruning (-want, +got) =", diff)
+	}
+	if tables.get(tableKey{table: usedTable}) != used {
+		t.Error("Table still in use was recreated")
+	}
+}
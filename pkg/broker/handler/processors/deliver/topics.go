@@ -0,0 +1,190 @@
+/*
+Copyright 2021 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deliver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	cepubsub "github.com/cloudevents/sdk-go/protocol/pubsub/v2"
+	"github.com/cloudevents/sdk-go/v2/binding"
+	"github.com/cloudevents/sdk-go/v2/binding/spec"
+	"github.com/cloudevents/sdk-go/v2/binding/transformer"
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/cloudevents/sdk-go/v2/types"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/google/knative-gcp/pkg/broker/config"
+	"github.com/google/knative-gcp/pkg/broker/eventutil"
+)
+
+// pubsubScheme is the scheme of the addresses of the targets that are Pub/Sub topics,
+// "pubsub://<project>/<topic>".
+const pubsubScheme = "pubsub://"
+
+// topicKey identifies a Pub/Sub topic and how it is published to.
+type topicKey struct {
+	project string
+	topic   string
+	ordered bool
+}
+
+// Topics holds the Pub/Sub topics that the events are published to for the targets that are
+// Pub/Sub topics, so that the publishing of the events to the same topic is batched. A nil Topics
+// fails the deliveries to such targets.
+type Topics struct {
+	client *pubsub.Client
+	topics sync.Map
+}
+
+// NewTopics creates the Topics publishing with the given client. The topics can be in any
+// project that the client is allowed to publish to.
+func NewTopics(client *pubsub.Client) *Topics {
+	return &Topics{client: client}
+}
+
+// Get returns the topic of the given project, creating its publisher if needed. Ordered topics
+// publish the messages with the same ordering key in order.
+func (ts *Topics) Get(project, topic string, ordered bool) *pubsub.Topic {
+	key := topicKey{project: project, topic: topic, ordered: ordered}
+	if t, ok := ts.topics.Load(key); ok {
+		return t.(*pubsub.Topic)
+	}
+	t := ts.client.TopicInProject(topic, project)
+	t.EnableMessageOrdering = ordered
+	if actual, loaded := ts.topics.LoadOrStore(key, t); loaded {
+		return actual.(*pubsub.Topic)
+	}
+	return t
+}
+
+// Prune stops the publishers of the topics that the targets no longer publish to.
+func (ts *Topics) Prune(targets config.ReadonlyTargets) {
+	used := make(map[topicKey]bool)
+	targets.RangeAllTargets(func(t *config.Target) bool {
+		if project, topic, ok := pubsubTopic(t.Address); ok {
+			used[topicKey{project: project, topic: topic, ordered: orderedDelivery(t)}] = true
+		}
+		return true
+	})
+	ts.topics.Range(func(k, v interface{}) bool {
+		if !used[k.(topicKey)] {
+			ts.topics.Delete(k)
+			// Stop flushes the messages being published, which are owned by deliveries in flight.
+			go v.(*pubsub.Topic).Stop()
+		}
+		return true
+	})
+}
+
+// pubsubTopic returns the project and the ID of the Pub/Sub topic of the target address, if it is
+// one.
+func pubsubTopic(address string) (project, topic string, ok bool) {
+	if !strings.HasPrefix(address, pubsubScheme) {
+		return "", "", false
+	}
+	parts := strings.Split(strings.TrimPrefix(address, pubsubScheme), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+// orderedDelivery returns whether the events are published to the target with an ordering key.
+func orderedDelivery(target *config.Target) bool {
+	return target.PubsubDelivery != nil && target.PubsubDelivery.OrderingKeyAttribute != ""
+}
+
+// publishToTopic publishes msg to the Pub/Sub topic of the target. The topic doesn't reply. Errors
+// are classified like the HTTP responses: the failures that retrying cannot fix, such as a missing
+// topic or permission, wrap ErrNonRetryable.
+func (p *Processor) publishToTopic(ctx context.Context, target *config.Target, msg binding.Message, project, topic string) error {
+	if p.Topics == nil {
+		return errors.New("publishing to Pub/Sub topics is not enabled")
+	}
+	e, err := binding.ToEvent(ctx, msg,
+		// Remove hops from forwarded event.
+		transformer.DeleteExtension(eventutil.HopsAttribute),
+		// Remove the retry target and time from retried events.
+		transformer.DeleteExtension(eventutil.RetryTargetAttribute),
+		transformer.DeleteExtension(eventutil.RetryAtAttribute),
+		// Remove the lineage of replies.
+		transformer.DeleteExtension(eventutil.LineageAttribute),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to read the event: %w", err)
+	}
+	var m pubsub.Message
+	if err := cepubsub.WritePubSubMessage(withDeliveryFormat(ctx, target), binding.ToMessage(e), &m); err != nil {
+		return fmt.Errorf("failed to write the Pub/Sub message: %w", err)
+	}
+	if d := target.PubsubDelivery; d != nil {
+		for ceAttr, attr := range d.AttributeMapping {
+			if v, ok := eventAttribute(e, ceAttr); ok {
+				if m.Attributes == nil {
+					m.Attributes = make(map[string]string, len(d.AttributeMapping))
+				}
+				m.Attributes[attr] = v
+			}
+		}
+		if d.OrderingKeyAttribute != "" {
+			m.OrderingKey, _ = eventAttribute(e, d.OrderingKeyAttribute)
+		}
+	}
+
+	t := p.Topics.Get(project, topic, orderedDelivery(target))
+	startTime := time.Now()
+	_, err = t.Publish(ctx, &m).Get(ctx)
+	p.StatsReporter.ReportEventDispatchTime(ctx, time.Since(startTime))
+	if err == nil {
+		return nil
+	}
+	if m.OrderingKey != "" {
+		// The publishing of the ordering key is paused after a failure. The event is retried
+		// through the retry topic, so the following events of the ordering key are let through.
+		t.ResumePublish(m.OrderingKey)
+	}
+	switch status.Code(err) {
+	case codes.NotFound, codes.PermissionDenied, codes.InvalidArgument, codes.FailedPrecondition:
+		return fmt.Errorf("event publishing failed: %v: %w", err, ErrNonRetryable)
+	}
+	return fmt.Errorf("event publishing failed: %w", err)
+}
+
+// eventAttribute returns the value of the attribute or the extension of the event as a string.
+func eventAttribute(e *event.Event, name string) (string, bool) {
+	v := e.Extensions()[name]
+	if version := spec.VS.Version(e.SpecVersion()); version != nil {
+		if a := version.Attribute(name); a != nil {
+			v = a.Get(e.Context)
+		}
+	}
+	if v == nil {
+		return "", false
+	}
+	s, err := types.Format(v)
+	if err != nil || s == "" {
+		return "", false
+	}
+	return s, true
+}
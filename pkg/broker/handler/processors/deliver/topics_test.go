@@ -0,0 +1,201 @@
+/*
+Copyright 2021 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deliver
+
+import (
+	"net/http"
+	"testing"
+
+	cepubsub "github.com/cloudevents/sdk-go/protocol/pubsub/v2"
+	ceclient "github.com/cloudevents/sdk-go/v2/client"
+	"github.com/google/go-cmp/cmp"
+	pubsubpb "google.golang.org/genproto/googleapis/pubsub/v1"
+	logtest "knative.dev/pkg/logging/testing"
+
+	"github.com/google/knative-gcp/pkg/broker/config"
+	"github.com/google/knative-gcp/pkg/broker/config/memory"
+	"github.com/google/knative-gcp/pkg/broker/eventutil"
+	handlerctx "github.com/google/knative-gcp/pkg/broker/handler/context"
+	"github.com/google/knative-gcp/pkg/metrics"
+	reportertest "github.com/google/knative-gcp/pkg/metrics/testing"
+)
+
+func TestDeliverToPubsubTopic(t *testing.T) {
+	cases := []struct {
+		name     string
+		address  string
+		delivery *config.PubsubDelivery
+		// wantAttributes are the attributes of the message published to the topic.
+		wantAttributes  map[string]string
+		wantOrderingKey string
+		wantDeadLetter  bool
+	}{{
+		name:    "published",
+		address: "pubsub://other-project/events",
+		wantAttributes: map[string]string{
+			"ce-id":          "id",
+			"ce-source":      "source",
+			"ce-subject":     "subject",
+			"ce-specversion": "1.0",
+			"ce-type":        "type",
+			"ce-tenant":      "tenant-1",
+		},
+	}, {
+		name:    "published with ordering key and attributes",
+		address: "pubsub://other-project/events",
+		delivery: &config.PubsubDelivery{
+			OrderingKeyAttribute: "subject",
+			AttributeMapping:     map[string]string{"type": "eventType", "tenant": "tenant", "missing": "missing"},
+		},
+		wantAttributes: map[string]string{
+			"ce-id":          "id",
+			"ce-source":      "source",
+			"ce-subject":     "subject",
+			"ce-specversion": "1.0",
+			"ce-type":        "type",
+			"ce-tenant":      "tenant-1",
+			"eventType":      "type",
+			"tenant":         "tenant-1",
+		},
+		wantOrderingKey: "subject",
+	}, {
+		name:           "missing topic is not retried",
+		address:        "pubsub://other-project/missing",
+		wantDeadLetter: true,
+	}}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			reportertest.ResetDeliveryMetrics()
+			ctx := logtest.TestContextWithLogger(t)
+			srv, c, close := testPubsubClient(ctx, t, "test-project")
+			defer close()
+			if _, err := srv.GServer.CreateTopic(ctx, &pubsubpb.Topic{Name: "projects/other-project/topics/events"}); err != nil {
+				t.Fatalf("failed to create test pubsub topic: %v", err)
+			}
+			if _, err := c.CreateTopic(ctx, "test-dead-letter-topic"); err != nil {
+				t.Fatalf("failed to create test pubsub topic: %v", err)
+			}
+			ps, err := cepubsub.New(ctx, cepubsub.WithClient(c), cepubsub.WithProjectID("test-project"))
+			if err != nil {
+				t.Fatalf("failed to create pubsub protocol: %v", err)
+			}
+			deliverRetryClient, err := ceclient.New(ps)
+			if err != nil {
+				t.Fatalf("failed to create cloudevents client: %v", err)
+			}
+
+			broker := &config.CellTenant{
+				Type:      config.CellTenantType_BROKER,
+				Namespace: "ns",
+				Name:      "broker",
+				Address:   "http://broker.example.com",
+			}
+			target := &config.Target{
+				Id:              "target-uid",
+				Namespace:       "ns",
+				Name:            "target",
+				CellTenantType:  config.CellTenantType_BROKER,
+				CellTenantName:  "broker",
+				Address:         tc.address,
+				ReplyAddress:    broker.Address,
+				PubsubDelivery:  tc.delivery,
+				DeadLetterQueue: &config.Queue{Topic: "test-dead-letter-topic"},
+			}
+			testTargets := memory.NewEmptyTargets()
+			testTargets.MutateCellTenant(broker.Key(), func(bm config.CellTenantMutation) {
+				bm.UpsertTargets(target)
+			})
+			ctx = handlerctx.WithBrokerKey(ctx, broker.Key())
+			ctx = handlerctx.WithTargetKey(ctx, target.Key())
+
+			r, err := metrics.NewDeliveryReporter("pod", "container")
+			if err != nil {
+				t.Fatal(err)
+			}
+			p := &Processor{
+				// Replies are not sent for the events published to topics.
+				DeliverClient:      &http.Client{Transport: fakeRoundTripper{}},
+				Targets:            testTargets,
+				RetryOnFailure:     true,
+				DeliverRetryClient: deliverRetryClient,
+				StatsReporter:      r,
+				Topics:             NewTopics(c),
+			}
+
+			e := newSampleEvent()
+			e.SetExtension("tenant", "tenant-1")
+			e.SetExtension(eventutil.HopsAttribute, 10)
+			if err := p.Process(ctx, e); err != nil {
+				t.Fatalf("unexpected error processing event: %v", err)
+			}
+
+			msgs := srv.Messages()
+			if len(msgs) != 1 {
+				t.Fatalf("Unexpected number of published messages. Want 1, Got %d", len(msgs))
+			}
+			if tc.wantDeadLetter {
+				if got := msgs[0].Attributes["ce-id"]; got != "id" {
+					t.Errorf("Dead lettered event ID got %q, want %q", got, "id")
+				}
+				return
+			}
+			// The event time is set when the event is created.
+			delete(msgs[0].Attributes, "ce-time")
+			if diff := cmp.Diff(tc.wantAttributes, msgs[0].Attributes); diff != "" {
+				t.Error("Published message attributes (-want, +got) =", diff)
+			}
+			if msgs[0].OrderingKey != tc.wantOrderingKey {
+				t.Errorf("Published message ordering key got %q, want %q", msgs[0].OrderingKey, tc.wantOrderingKey)
+			}
+		})
+	}
+}
+
+func TestTopicsPrune(t *testing.T) {
+	ctx := logtest.TestContextWithLogger(t)
+	_, c, close := testPubsubClient(ctx, t, "test-project")
+	defer close()
+	topics := NewTopics(c)
+	used := topics.Get("other-project", "used", false)
+	topics.Get("other-project", "unused", false)
+
+	broker := &config.CellTenant{Type: config.CellTenantType_BROKER, Namespace: "ns", Name: "broker"}
+	testTargets := memory.NewEmptyTargets()
+	testTargets.MutateCellTenant(broker.Key(), func(bm config.CellTenantMutation) {
+		bm.UpsertTargets(&config.Target{
+			Namespace:      "ns",
+			Name:           "target",
+			CellTenantType: config.CellTenantType_BROKER,
+			CellTenantName: "broker",
+			Address:        "pubsub://other-project/used",
+		})
+	})
+	topics.Prune(testTargets)
+
+	var got []topicKey
+	topics.topics.Range(func(k, _ interface{}) bool {
+		got = append(got, k.(topicKey))
+		return true
+	})
+	if diff := cmp.Diff([]topicKey{{project: "other-project", topic: "used"}}, got, cmp.AllowUnexported(topicKey{})); diff != "" {
+		t.Error("Topics after pruning (-want, +got) =", diff)
+	}
+	if topics.Get("other-project", "used", false) != used {
+		t.Error("Topic still in use was recreated")
+	}
+}
@@ -29,6 +29,7 @@ import (
 	"github.com/google/knative-gcp/pkg/broker/config"
 	handlerctx "github.com/google/knative-gcp/pkg/broker/handler/context"
 	"github.com/google/knative-gcp/pkg/broker/handler/processors"
+	"github.com/google/knative-gcp/pkg/broker/handler/processors/filter"
 	"github.com/google/knative-gcp/pkg/metrics"
 )
 
@@ -51,28 +52,34 @@ type Processor struct {
 
 var _ processors.Interface = (*Processor)(nil)
 
-// Process fanouts the given event.
+// Process fanouts the given event to the targets whose filter it may pass. Targets that the
+// index rules out are skipped, the others are filtered by the next processors.
 func (p *Processor) Process(ctx context.Context, event *event.Event) error {
 	bk, err := handlerctx.GetBrokerKey(ctx)
 	if err != nil {
 		return err
 	}
-	broker, ok := p.Targets.GetCellTenantByKey(bk)
-	if !ok {
+	if _, ok := p.Targets.GetCellTenantByKey(bk); !ok {
 		// If the broker no longer exists, then there is nothing to process.
 		logging.FromContext(ctx).Warn("broker no longer exist in the config", zap.Stringer("broker", bk))
 		return nil
 	}
 
+	var targets []*config.Target
+	p.Targets.RangeCandidateTargets(bk, filter.Attributes(event), func(target *config.Target) bool {
+		targets = append(targets, target)
+		return true
+	})
+
 	tc := make(chan *config.Target)
 	go func() {
 		defer close(tc)
-		for _, target := range broker.Targets {
+		for _, target := range targets {
 			tc <- target
 		}
 	}()
 
-	curr := len(broker.Targets)
+	curr := len(targets)
 	if curr > p.MaxConcurrency {
 		curr = p.MaxConcurrency
 	}
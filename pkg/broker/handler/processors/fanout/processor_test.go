@@ -113,6 +113,56 @@ func TestFanoutSuccess(t *testing.T) {
 	}
 }
 
+func TestFanoutSkipsNonMatchingTargets(t *testing.T) {
+	ch := make(chan *event.Event, 4)
+	bk := config.TestOnlyBrokerKey("ns", "broker")
+	testTargets := memory.NewEmptyTargets()
+	testTargets.MutateCellTenant(bk, func(bm config.CellTenantMutation) {
+		bm.UpsertTargets(
+			&config.Target{Name: "matching-type", FilterAttributes: map[string]string{"type": "type"}},
+			&config.Target{Name: "matching-any", FilterAttributes: map[string]string{"type": ""}},
+			&config.Target{Name: "no-filter"},
+			&config.Target{Name: "other-type", FilterAttributes: map[string]string{"type": "other"}},
+			&config.Target{Name: "other-source", FilterAttributes: map[string]string{"source": "other"}},
+		)
+	})
+	b, _ := testTargets.GetCellTenantByKey(bk)
+	var wantTargets []*config.TargetKey
+	for _, name := range []string{"matching-type", "matching-any", "no-filter"} {
+		wantTargets = append(wantTargets, b.Targets[name].Key())
+	}
+	gotTargets := make(chan *config.TargetKey, 5)
+	next := &processors.FakeProcessor{
+		PrevEventsCh: ch,
+		InterceptFunc: func(ctx context.Context, e *event.Event) *event.Event {
+			t, _ := handlerctx.GetTargetKey(ctx)
+			gotTargets <- t
+			return e
+		},
+	}
+
+	p := &Processor{MaxConcurrency: 2, Targets: testTargets}
+	p.WithNext(next)
+
+	e := event.New()
+	e.SetID("id")
+	e.SetSource("source")
+	e.SetType("type")
+
+	ctx := handlerctx.WithBrokerKey(context.Background(), bk)
+	if err := p.Process(ctx, &e); err != nil {
+		t.Errorf("unexpected error from processing: %v", err)
+	}
+	close(gotTargets)
+	var got []*config.TargetKey
+	for t := range gotTargets {
+		got = append(got, t)
+	}
+	if diff := cmp.Diff(wantTargets, got, diffTargetKeySlice); diff != "" {
+		t.Errorf("got target keys (-want,+got): %v", diff)
+	}
+}
+
 func TestFanoutPartialFailure(t *testing.T) {
 	ch := make(chan *event.Event, 4)
 	ns, broker := "ns", "broker"
@@ -163,7 +213,8 @@ func newTestTargets(key *config.CellTenantKey, num int) config.ReadonlyTargets {
 				Name: fmt.Sprintf("target-%d", i),
 				Id:   fmt.Sprintf("target-%d", i),
 				FilterAttributes: map[string]string{
-					"target": strconv.Itoa(i),
+					"subject": "subject",
+					"target":  strconv.Itoa(i),
 				},
 			})
 		}
@@ -0,0 +1,61 @@
+/*
+Copyright 2021 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package federation
+
+import (
+	"context"
+
+	ceocclient "github.com/cloudevents/sdk-go/observability/opencensus/v2/client"
+	"github.com/cloudevents/sdk-go/v2/event"
+	"go.opencensus.io/trace"
+	"go.uber.org/zap"
+
+	"github.com/google/knative-gcp/pkg/broker/eventutil"
+	"github.com/google/knative-gcp/pkg/broker/handler/processors"
+	"github.com/google/knative-gcp/pkg/logging"
+)
+
+// Processor admits the events pulled from the decouple topic of a remote Broker. Each time an
+// event crosses to another Broker it consumes a hop, so that the replies bouncing between
+// federated Brokers run out of hops like the replies within a Broker. The replies that come back
+// to a Trigger they descend from are caught earlier by their lineage, which crosses the Brokers
+// with the events.
+type Processor struct {
+	processors.BaseProcessor
+}
+
+var _ processors.Interface = (*Processor)(nil)
+
+// Process drops the event if it has exhausted its hops, and otherwise passes it to the next
+// processor with a hop less. The events without hops get the default hops once delivered.
+func (p *Processor) Process(ctx context.Context, e *event.Event) error {
+	hops, ok := eventutil.GetRemainingHops(ctx, e)
+	if !ok {
+		return p.Next().Process(ctx, e)
+	}
+	if hops <= 0 {
+		logging.FromContext(ctx).Debug("federated event has exhausted allowed hops: dropping event",
+			zap.String("event.id", e.ID()))
+		trace.FromContext(ctx).Annotate(
+			ceocclient.EventTraceAttributes(e),
+			"event dropped: federated event has exhausted allowed hops",
+		)
+		return nil
+	}
+	e.SetExtension(eventutil.HopsAttribute, hops-1)
+	return p.Next().Process(ctx, e)
+}
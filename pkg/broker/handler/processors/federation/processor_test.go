@@ -0,0 +1,81 @@
+/*
+Copyright 2021 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package federation
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+
+	"github.com/google/knative-gcp/pkg/broker/eventutil"
+	"github.com/google/knative-gcp/pkg/broker/handler/processors"
+)
+
+func TestProcessor(t *testing.T) {
+	cases := []struct {
+		name     string
+		hops     interface{}
+		wantNext bool
+		wantHops int32
+		hasHops  bool
+	}{{
+		name:     "no hops",
+		wantNext: true,
+	}, {
+		name:     "hops are decremented",
+		hops:     int32(10),
+		wantNext: true,
+		wantHops: 9,
+		hasHops:  true,
+	}, {
+		name:     "last hop",
+		hops:     int32(1),
+		wantNext: true,
+		wantHops: 0,
+		hasHops:  true,
+	}, {
+		name: "exhausted hops are dropped",
+		hops: int32(0),
+	}}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := event.New()
+			e.SetID("id")
+			if tc.hops != nil {
+				e.SetExtension(eventutil.HopsAttribute, tc.hops)
+			}
+			eventCh := make(chan *event.Event, 1)
+			p := &Processor{}
+			p.WithNext(&processors.FakeProcessor{PrevEventsCh: eventCh})
+
+			if err := p.Process(context.Background(), &e); err != nil {
+				t.Fatalf("Process got unexpected error: %v", err)
+			}
+			if gotNext := len(eventCh) == 1; gotNext != tc.wantNext {
+				t.Fatalf("event passed to the next processor got %v, want %v", gotNext, tc.wantNext)
+			}
+			if !tc.wantNext {
+				return
+			}
+			hops, ok := eventutil.GetRemainingHops(context.Background(), <-eventCh)
+			if ok != tc.hasHops || hops != tc.wantHops {
+				t.Errorf("remaining hops got (%d, %v), want (%d, %v)", hops, ok, tc.wantHops, tc.hasHops)
+			}
+		})
+	}
+}
@@ -96,6 +96,11 @@ func startSpan(ctx context.Context, trigger types.NamespacedName, event *event.E
 // PassFilter checks given event against attributes available in the attrs map to determine
 // if the event should pass or not.
 func PassFilter(ctx context.Context, attrs map[string]string, event *event.Event) bool {
+	return passFilter(ctx, attrs, Attributes(event))
+}
+
+// Attributes returns the attributes of the event that filters match against, keyed by name.
+func Attributes(event *event.Event) map[string]interface{} {
 	// Set standard context attributes. The attributes available may not be
 	// exactly the same as the attributes defined in the current version of the
 	// CloudEvents spec.
@@ -116,7 +121,11 @@ func PassFilter(ctx context.Context, attrs map[string]string, event *event.Event
 	for k, v := range ext {
 		ce[k] = v
 	}
+	return ce
+}
 
+// passFilter checks the attributes of an event, as returned by Attributes, against the filter.
+func passFilter(ctx context.Context, attrs map[string]string, ce map[string]interface{}) bool {
 	for k, v := range attrs {
 		var value interface{}
 		value, ok := ce[k]
@@ -0,0 +1,126 @@
+/*
+Copyright 2021 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schedule
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	ceclient "github.com/cloudevents/sdk-go/v2/client"
+	cecontext "github.com/cloudevents/sdk-go/v2/context"
+	"github.com/cloudevents/sdk-go/v2/event"
+	"go.uber.org/zap"
+
+	"github.com/google/knative-gcp/pkg/broker/config"
+	"github.com/google/knative-gcp/pkg/broker/eventutil"
+	handlerctx "github.com/google/knative-gcp/pkg/broker/handler/context"
+	"github.com/google/knative-gcp/pkg/broker/handler/processors"
+	"github.com/google/knative-gcp/pkg/logging"
+)
+
+// maxHold bounds how long an event that is not due yet is held at once. It is the longest ack
+// deadline of a pubsub message, with which the handler delays its redelivery.
+const maxHold = 10 * time.Minute
+
+// NotDueError is returned for an event that is scheduled for a later delivery.
+type NotDueError struct {
+	// Delay is how long the event should be held before it is processed again.
+	Delay time.Duration
+}
+
+func (e *NotDueError) Error() string {
+	return fmt.Sprintf("event is not due for another %v", e.Delay)
+}
+
+// NotDue returns how long the event should be held before it is processed again, if it is
+// scheduled for a later delivery.
+func NotDue(err error) (time.Duration, bool) {
+	var notDueErr *NotDueError
+	if errors.As(err, &notDueErr) {
+		return notDueErr.Delay, true
+	}
+	return 0, false
+}
+
+// Processor holds back the events that are scheduled for a later delivery. The events that are
+// due are passed to the next processor.
+type Processor struct {
+	processors.BaseProcessor
+
+	// Targets is the targets from config.
+	Targets config.ReadonlyTargets
+
+	// DelayClient is the cloudevents client to send the events that are not due yet to the delay
+	// topic of their CellTenant, so that they don't wait in the subscription shared with the
+	// events that are due. If nil, or if the CellTenant has no delay topic, the events are held
+	// where they are pulled from.
+	DelayClient ceclient.Client
+
+	// now returns the current time. It is stubbed in tests.
+	now func() time.Time
+}
+
+var _ processors.Interface = (*Processor)(nil)
+
+// Process passes the event to the next processor if it is due. Otherwise it sends the event to
+// the delay topic of its CellTenant, or returns a NotDueError with the time to wait, bounded by
+// maxHold.
+func (p *Processor) Process(ctx context.Context, e *event.Event) error {
+	deliverAt, ok := eventutil.DeliverAt(e)
+	if !ok {
+		return p.Next().Process(ctx, e)
+	}
+	now := time.Now
+	if p.now != nil {
+		now = p.now
+	}
+	delay := deliverAt.Sub(now())
+	if delay <= 0 {
+		return p.Next().Process(ctx, e)
+	}
+	if q := p.delayQueue(ctx); q != nil {
+		logging.FromContext(ctx).Debug("sending event scheduled for a later delivery to the delay topic",
+			zap.String("event.id", e.ID()), zap.Time("deliverAt", deliverAt))
+		if err := p.DelayClient.Send(cecontext.WithTopic(ctx, q.Topic), *e); err != nil {
+			return fmt.Errorf("failed to send event to delay topic: %w", err)
+		}
+		return nil
+	}
+	if delay > maxHold {
+		delay = maxHold
+	}
+	return &NotDueError{Delay: delay}
+}
+
+// delayQueue returns the delay queue of the CellTenant in the context, nil if the events that are
+// not due must be held instead.
+func (p *Processor) delayQueue(ctx context.Context) *config.Queue {
+	if p.DelayClient == nil || p.Targets == nil {
+		return nil
+	}
+	bk, err := handlerctx.GetBrokerKey(ctx)
+	if err != nil {
+		return nil
+	}
+	b, ok := p.Targets.GetCellTenantByKey(bk)
+	if !ok || b.DelayQueue == nil || b.DelayQueue.Topic == "" || b.DelayQueue.State != config.State_READY {
+		return nil
+	}
+	return b.DelayQueue
+}
@@ -0,0 +1,202 @@
+/*
+Copyright 2021 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schedule
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"cloud.google.com/go/pubsub/pstest"
+	cepubsub "github.com/cloudevents/sdk-go/protocol/pubsub/v2"
+	ceclient "github.com/cloudevents/sdk-go/v2/client"
+	"github.com/cloudevents/sdk-go/v2/event"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+
+	"github.com/google/knative-gcp/pkg/broker/config"
+	"github.com/google/knative-gcp/pkg/broker/config/memory"
+	"github.com/google/knative-gcp/pkg/broker/eventutil"
+	handlerctx "github.com/google/knative-gcp/pkg/broker/handler/context"
+	"github.com/google/knative-gcp/pkg/broker/handler/processors"
+)
+
+func TestProcessor(t *testing.T) {
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name      string
+		deliverAt interface{}
+		wantNext  bool
+		wantDelay time.Duration
+	}{{
+		name:     "not scheduled",
+		wantNext: true,
+	}, {
+		name:      "due",
+		deliverAt: now.Add(-time.Second),
+		wantNext:  true,
+	}, {
+		name:      "invalid schedule is ignored",
+		deliverAt: "tomorrow",
+		wantNext:  true,
+	}, {
+		name:      "not due",
+		deliverAt: now.Add(time.Minute),
+		wantDelay: time.Minute,
+	}, {
+		name:      "hold is bounded",
+		deliverAt: now.Add(time.Hour),
+		wantDelay: maxHold,
+	}}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := event.New()
+			e.SetID("id")
+			if tc.deliverAt != nil {
+				e.SetExtension(eventutil.DeliverAtExtension, tc.deliverAt)
+			}
+			eventCh := make(chan *event.Event, 1)
+			p := &Processor{now: func() time.Time { return now }}
+			p.WithNext(&processors.FakeProcessor{PrevEventsCh: eventCh})
+
+			err := p.Process(context.Background(), &e)
+			if gotNext := len(eventCh) == 1; gotNext != tc.wantNext {
+				t.Errorf("event passed to the next processor got %v, want %v", gotNext, tc.wantNext)
+			}
+			delay, notDue := NotDue(err)
+			if tc.wantNext {
+				if err != nil {
+					t.Errorf("Process got unexpected error: %v", err)
+				}
+				return
+			}
+			if !notDue {
+				t.Fatalf("Process got error %v, want NotDueError", err)
+			}
+			if delay != tc.wantDelay {
+				t.Errorf("Process delay got %v, want %v", delay, tc.wantDelay)
+			}
+		})
+	}
+}
+
+func TestProcessorDelayQueue(t *testing.T) {
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name       string
+		delayQueue *config.Queue
+		deliverAt  time.Time
+		wantNext   bool
+		wantParked bool
+		wantDelay  time.Duration
+	}{{
+		name:       "due",
+		delayQueue: &config.Queue{Topic: "delay-topic", State: config.State_READY},
+		deliverAt:  now.Add(-time.Second),
+		wantNext:   true,
+	}, {
+		name:       "not due is sent to the delay topic",
+		delayQueue: &config.Queue{Topic: "delay-topic", State: config.State_READY},
+		deliverAt:  now.Add(time.Hour),
+		wantParked: true,
+	}, {
+		name:       "not due is held while the delay topic is not ready",
+		delayQueue: &config.Queue{Topic: "delay-topic", State: config.State_UNKNOWN},
+		deliverAt:  now.Add(time.Minute),
+		wantDelay:  time.Minute,
+	}, {
+		name:      "not due is held without a delay topic",
+		deliverAt: now.Add(time.Minute),
+		wantDelay: time.Minute,
+	}}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			srv := pstest.NewServer()
+			defer srv.Close()
+			conn, err := grpc.Dial(srv.Addr, grpc.WithInsecure())
+			if err != nil {
+				t.Fatalf("failed to dial test pubsub connection: %v", err)
+			}
+			defer conn.Close()
+			c, err := pubsub.NewClient(ctx, "test-project", option.WithGRPCConn(conn))
+			if err != nil {
+				t.Fatalf("failed to create test pubsub client: %v", err)
+			}
+			if _, err := c.CreateTopic(ctx, "delay-topic"); err != nil {
+				t.Fatalf("failed to create test delay topic: %v", err)
+			}
+			ps, err := cepubsub.New(ctx, cepubsub.WithClient(c), cepubsub.WithProjectID("test-project"))
+			if err != nil {
+				t.Fatalf("failed to create pubsub protocol: %v", err)
+			}
+			delayClient, err := ceclient.New(ps)
+			if err != nil {
+				t.Fatalf("failed to create cloudevents client: %v", err)
+			}
+
+			broker := &config.CellTenant{
+				Type:      config.CellTenantType_BROKER,
+				Namespace: "ns",
+				Name:      "broker",
+			}
+			targets := memory.NewEmptyTargets()
+			targets.MutateCellTenant(broker.Key(), func(bm config.CellTenantMutation) {
+				bm.SetDelayQueue(tc.delayQueue)
+			})
+			ctx = handlerctx.WithBrokerKey(ctx, broker.Key())
+
+			e := event.New()
+			e.SetID("id")
+			e.SetSource("source")
+			e.SetType("type")
+			e.SetExtension(eventutil.DeliverAtExtension, tc.deliverAt)
+			eventCh := make(chan *event.Event, 1)
+			p := &Processor{Targets: targets, DelayClient: delayClient, now: func() time.Time { return now }}
+			p.WithNext(&processors.FakeProcessor{PrevEventsCh: eventCh})
+
+			err = p.Process(ctx, &e)
+			if gotNext := len(eventCh) == 1; gotNext != tc.wantNext {
+				t.Errorf("event passed to the next processor got %v, want %v", gotNext, tc.wantNext)
+			}
+			if gotParked := len(srv.Messages()) == 1; gotParked != tc.wantParked {
+				t.Errorf("event sent to the delay topic got %v, want %v", gotParked, tc.wantParked)
+			}
+			delay, notDue := NotDue(err)
+			if tc.wantDelay == 0 {
+				if err != nil {
+					t.Errorf("Process got unexpected error: %v", err)
+				}
+				return
+			}
+			if !notDue || delay != tc.wantDelay {
+				t.Errorf("Process got error %v, want NotDueError with delay %v", err, tc.wantDelay)
+			}
+		})
+	}
+}
+
+func TestNotDue(t *testing.T) {
+	if _, ok := NotDue(fmt.Errorf("wrapped: %w", &NotDueError{Delay: time.Second})); !ok {
+		t.Error("NotDue did not unwrap a NotDueError")
+	}
+	if _, ok := NotDue(fmt.Errorf("other error")); ok {
+		t.Error("NotDue got true for another error")
+	}
+}
@@ -0,0 +1,448 @@
+/*
+Copyright 2021 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	vkit "cloud.google.com/go/pubsub/apiv1"
+	"github.com/golang/protobuf/ptypes"
+	"go.uber.org/zap"
+	pb "google.golang.org/genproto/googleapis/pubsub/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/google/knative-gcp/pkg/logging"
+)
+
+const (
+	// pullBatchSize bounds the messages pulled from a subscription at once, so
+	// that a single pull doesn't take the outstanding budget of other
+	// subscriptions.
+	pullBatchSize = 100
+	// pullWait bounds a single pull. Pulls wait for messages, rather than
+	// return immediately, which Pub/Sub deprecated, so the wait also bounds how
+	// long a subscription without messages holds a worker. The messages that
+	// Pub/Sub returns as the wait expires are redelivered once their ack
+	// deadline expires.
+	pullWait = 2 * time.Second
+	// minIdleBackoff and maxIdleBackoff bound how long a subscription that had
+	// no message is skipped before it is pulled again. The backoff doubles with
+	// every empty pull, so idle subscriptions cost few pulls while busy ones
+	// are pulled continuously.
+	minIdleBackoff = 100 * time.Millisecond
+	maxIdleBackoff = 2 * time.Second
+	// leaseDeadline is the ack deadline the messages being processed are kept
+	// extended to, every leaseInterval.
+	leaseDeadline = 60 * time.Second
+	leaseInterval = 20 * time.Second
+	// maxAckDeadline is the longest ack deadline supported by Pub/Sub. Messages
+	// nacked with a longer delay are redelivered after it.
+	maxAckDeadline = 600 * time.Second
+	// maxAckIDsPerRequest bounds the ack IDs sent in a single request, which is
+	// limited in size.
+	maxAckIDsPerRequest = 1000
+)
+
+// handleFunc processes a message. It returns whether the message should be
+// acked, and otherwise how long to wait before it is redelivered.
+type handleFunc func(context.Context, *pubsub.Message) (bool, time.Duration)
+
+// PullScheduler pulls the messages of many subscriptions with a bounded set of
+// workers, instead of a streaming pull per subscription. The messages being
+// processed are bounded across all subscriptions, and the subscriptions are
+// pulled in turn, each holding at most its fair share of the outstanding
+// messages, so that a busy subscription doesn't starve the others.
+type PullScheduler struct {
+	client  *vkit.SubscriberClient
+	workers int
+	// tokens holds a token for each message being processed, bounding them
+	// across all subscriptions.
+	tokens chan struct{}
+
+	mu   sync.Mutex
+	subs []*pullSubscription
+	// next is the index of the subscription to pull next.
+	next int
+	// added is closed when a subscription is added, for the idle workers to
+	// pull it right away.
+	added chan struct{}
+}
+
+// pullSubscription is a subscription served by the PullScheduler.
+type pullSubscription struct {
+	// name is the full name of the subscription.
+	name   string
+	handle handleFunc
+	ctx    context.Context
+	// errc receives the error the subscription can't be pulled with anymore.
+	errc chan error
+
+	// The following fields are guarded by the mutex of the scheduler.
+	// outstanding holds the ack IDs of the messages being processed.
+	outstanding map[string]struct{}
+	pulling     bool
+	// stopped is set once the subscription is not pulled anymore. It is
+	// kept until its outstanding messages are acked or nacked, so that
+	// their lease is extended while they are drained.
+	stopped     bool
+	idleUntil   time.Time
+	idleBackoff time.Duration
+}
+
+// NewPullScheduler creates a PullScheduler pulling with the given number of
+// workers, and processing at most maxOutstanding messages at once.
+func NewPullScheduler(client *vkit.SubscriberClient, workers, maxOutstanding int) *PullScheduler {
+	if workers < 1 {
+		workers = 1
+	}
+	if maxOutstanding < 1 {
+		maxOutstanding = 1
+	}
+	return &PullScheduler{
+		client:  client,
+		workers: workers,
+		tokens:  make(chan struct{}, maxOutstanding),
+		added:   make(chan struct{}),
+	}
+}
+
+// Start starts the workers and the lease of the messages being processed. They
+// stop when the context is done.
+func (s *PullScheduler) Start(ctx context.Context) {
+	for i := 0; i < s.workers; i++ {
+		go s.work(ctx)
+	}
+	go s.lease(ctx)
+}
+
+// serve pulls the messages of the subscription and processes them with handle
+// until the context is done. It returns an error if the subscription can't be
+// pulled, e.g. because it doesn't exist.
+func (s *PullScheduler) serve(ctx context.Context, subscription string, handle handleFunc) error {
+	sub := &pullSubscription{
+		name:        subscription,
+		handle:      handle,
+		ctx:         ctx,
+		errc:        make(chan error, 1),
+		outstanding: make(map[string]struct{}),
+	}
+	s.mu.Lock()
+	s.subs = append(s.subs, sub)
+	close(s.added)
+	s.added = make(chan struct{})
+	s.mu.Unlock()
+	defer s.remove(sub)
+
+	select {
+	case <-ctx.Done():
+		return nil
+	case err := <-sub.errc:
+		return err
+	}
+}
+
+// remove stops pulling the subscription. The messages being processed are
+// still leased, and acked or nacked.
+func (s *PullScheduler) remove(sub *pullSubscription) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sub.stopped = true
+	if len(sub.outstanding) == 0 {
+		s.removeLocked(sub)
+	}
+}
+
+// removeLocked removes the subscription. It requires the mutex to be held.
+func (s *PullScheduler) removeLocked(sub *pullSubscription) {
+	for i, other := range s.subs {
+		if other == sub {
+			s.subs = append(s.subs[:i], s.subs[i+1:]...)
+			if s.next > i {
+				s.next--
+			}
+			return
+		}
+	}
+}
+
+// work pulls the subscriptions in turn while there is outstanding budget left.
+func (s *PullScheduler) work(ctx context.Context) {
+	for {
+		// Wait for the budget of at least one message before picking a subscription.
+		select {
+		case s.tokens <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+		s.mu.Lock()
+		added := s.added
+		s.mu.Unlock()
+		sub, max, wait := s.pick()
+		if sub == nil {
+			<-s.tokens
+			timer := time.NewTimer(wait)
+			select {
+			case <-timer.C:
+			case <-added:
+				timer.Stop()
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			}
+			continue
+		}
+		// Take the budget of the rest of the batch, as far as it is available.
+		n := 1
+		for n < max && s.tryAcquire() {
+			n++
+		}
+		received := s.pull(ctx, sub, n)
+		for i := received; i < n; i++ {
+			<-s.tokens
+		}
+	}
+}
+
+// tryAcquire takes the budget of a message if it is available.
+func (s *PullScheduler) tryAcquire() bool {
+	select {
+	case s.tokens <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// pick returns the next subscription to pull in turn and how many messages to
+// pull from it. If no subscription can be pulled, it returns how long to wait
+// before trying again.
+func (s *PullScheduler) pick() (*pullSubscription, int, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	active, empty := 0, 0
+	for _, sub := range s.subs {
+		if sub.stopped {
+			continue
+		}
+		if len(sub.outstanding) > 0 || !now.Before(sub.idleUntil) {
+			active++
+		}
+		if len(sub.outstanding) == 0 {
+			empty++
+		}
+	}
+	// The fair share of a subscription is an equal part of the budget among the
+	// subscriptions that have messages.
+	fairShare := cap(s.tokens)
+	if active > 1 {
+		fairShare = (cap(s.tokens) + active - 1) / active
+	}
+
+	wait := maxIdleBackoff
+	for i := 0; i < len(s.subs); i++ {
+		idx := (s.next + i) % len(s.subs)
+		sub := s.subs[idx]
+		if sub.stopped {
+			continue
+		}
+		// The budget of a message is left for each other subscription without
+		// outstanding messages, so that a subscription that was idle isn't
+		// starved by the ones that took the budget meanwhile.
+		reserved := empty
+		if len(sub.outstanding) == 0 {
+			reserved--
+		}
+		share := fairShare
+		if limit := cap(s.tokens) - reserved; limit < share {
+			share = limit
+		}
+		if share < 1 {
+			share = 1
+		}
+		if sub.pulling || len(sub.outstanding) >= share {
+			// The subscription may be pulled again soon.
+			wait = minIdleBackoff
+			continue
+		}
+		if now.Before(sub.idleUntil) {
+			if d := sub.idleUntil.Sub(now); d < wait {
+				wait = d
+			}
+			continue
+		}
+		s.next = (idx + 1) % len(s.subs)
+		sub.pulling = true
+		max := share - len(sub.outstanding)
+		if max > pullBatchSize {
+			max = pullBatchSize
+		}
+		return sub, max, 0
+	}
+	if wait < minIdleBackoff {
+		wait = minIdleBackoff
+	}
+	return nil, 0, wait
+}
+
+// pull pulls at most max messages from the subscription and starts processing
+// them. It returns the number of messages pulled.
+func (s *PullScheduler) pull(ctx context.Context, sub *pullSubscription, max int) int {
+	pctx, cancel := context.WithTimeout(sub.ctx, pullWait)
+	defer cancel()
+	resp, err := s.client.Pull(pctx, &pb.PullRequest{
+		Subscription: sub.name,
+		MaxMessages:  int32(max),
+	})
+	if err != nil && pctx.Err() == context.DeadlineExceeded && sub.ctx.Err() == nil {
+		// No message arrived during the wait.
+		err = nil
+	}
+
+	s.mu.Lock()
+	sub.pulling = false
+	if err != nil || len(resp.GetReceivedMessages()) == 0 {
+		sub.idleBackoff *= 2
+		if sub.idleBackoff < minIdleBackoff {
+			sub.idleBackoff = minIdleBackoff
+		} else if sub.idleBackoff > maxIdleBackoff {
+			sub.idleBackoff = maxIdleBackoff
+		}
+		sub.idleUntil = time.Now().Add(sub.idleBackoff)
+		s.mu.Unlock()
+		if err != nil && sub.ctx.Err() == nil {
+			logging.FromContext(ctx).Error("failed to pull subscription", zap.String("subscription", sub.name), zap.Error(err))
+			if code := status.Code(err); code == codes.NotFound || code == codes.PermissionDenied {
+				select {
+				case sub.errc <- err:
+				default:
+				}
+			}
+		}
+		return 0
+	}
+	sub.idleBackoff = 0
+	ackIDs := make([]string, 0, len(resp.ReceivedMessages))
+	for _, rm := range resp.ReceivedMessages {
+		sub.outstanding[rm.AckId] = struct{}{}
+		ackIDs = append(ackIDs, rm.AckId)
+	}
+	s.mu.Unlock()
+
+	// Extend the ack deadline of the subscription right away, the lease keeps
+	// extending it afterwards.
+	s.modifyAckDeadline(ctx, sub.name, ackIDs, leaseDeadline)
+	for _, rm := range resp.ReceivedMessages {
+		go s.process(ctx, sub, rm)
+	}
+	return len(resp.ReceivedMessages)
+}
+
+// process processes a pulled message and acks or nacks it.
+func (s *PullScheduler) process(ctx context.Context, sub *pullSubscription, rm *pb.ReceivedMessage) {
+	defer func() { <-s.tokens }()
+	ack, delay := sub.handle(sub.ctx, toMessage(rm))
+
+	s.mu.Lock()
+	delete(sub.outstanding, rm.AckId)
+	if sub.stopped && len(sub.outstanding) == 0 {
+		s.removeLocked(sub)
+	}
+	s.mu.Unlock()
+
+	if ack {
+		if err := s.client.Acknowledge(ctx, &pb.AcknowledgeRequest{Subscription: sub.name, AckIds: []string{rm.AckId}}); err != nil {
+			logging.FromContext(ctx).Error("failed to ack message", zap.String("subscription", sub.name), zap.Error(err))
+		}
+		return
+	}
+	// The message is redelivered once its ack deadline expires.
+	s.modifyAckDeadline(ctx, sub.name, []string{rm.AckId}, delay)
+}
+
+// lease keeps extending the ack deadline of the messages being processed.
+func (s *PullScheduler) lease(ctx context.Context) {
+	ticker := time.NewTicker(leaseInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		leases := make(map[string][]string)
+		s.mu.Lock()
+		for _, sub := range s.subs {
+			for ackID := range sub.outstanding {
+				leases[sub.name] = append(leases[sub.name], ackID)
+			}
+		}
+		s.mu.Unlock()
+		for name, ackIDs := range leases {
+			s.modifyAckDeadline(ctx, name, ackIDs, leaseDeadline)
+		}
+	}
+}
+
+// modifyAckDeadline sets the ack deadline of the messages, rounded up to whole
+// seconds and bounded by the longest deadline supported by Pub/Sub.
+func (s *PullScheduler) modifyAckDeadline(ctx context.Context, subscription string, ackIDs []string, deadline time.Duration) {
+	if deadline > maxAckDeadline {
+		deadline = maxAckDeadline
+	}
+	// A partial second must not round down to a zero deadline, which would
+	// redeliver the messages right away.
+	deadline = (deadline + time.Second - 1).Truncate(time.Second)
+	for len(ackIDs) > 0 {
+		batch := ackIDs
+		if len(batch) > maxAckIDsPerRequest {
+			batch = batch[:maxAckIDsPerRequest]
+		}
+		ackIDs = ackIDs[len(batch):]
+		err := s.client.ModifyAckDeadline(ctx, &pb.ModifyAckDeadlineRequest{
+			Subscription:       subscription,
+			AckIds:             batch,
+			AckDeadlineSeconds: int32(deadline / time.Second),
+		})
+		if err != nil {
+			logging.FromContext(ctx).Error("failed to modify ack deadline", zap.String("subscription", subscription), zap.Error(err))
+		}
+	}
+}
+
+// toMessage converts a pulled message to the message passed to the handler.
+// The message must not be acked or nacked by the handler.
+func toMessage(rm *pb.ReceivedMessage) *pubsub.Message {
+	msg := &pubsub.Message{}
+	if rm.Message == nil {
+		return msg
+	}
+	msg.ID = rm.Message.MessageId
+	msg.Data = rm.Message.Data
+	msg.Attributes = rm.Message.Attributes
+	msg.OrderingKey = rm.Message.OrderingKey
+	msg.PublishTime, _ = ptypes.Timestamp(rm.Message.PublishTime)
+	if rm.DeliveryAttempt > 0 {
+		attempt := int(rm.DeliveryAttempt)
+		msg.DeliveryAttempt = &attempt
+	}
+	return msg
+}
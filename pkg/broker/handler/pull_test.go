@@ -0,0 +1,258 @@
+/*
+Copyright 2021 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	vkit "cloud.google.com/go/pubsub/apiv1"
+	"cloud.google.com/go/pubsub/pstest"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+)
+
+func newTestPullScheduler(t *testing.T, workers, maxOutstanding int) (*PullScheduler, *pubsub.Client) {
+	t.Helper()
+	ctx := context.Background()
+	srv := pstest.NewServer()
+	conn, err := grpc.Dial(srv.Addr, grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("Failed to dial the test pubsub server: %v", err)
+	}
+	client, err := pubsub.NewClient(ctx, "test-project", option.WithGRPCConn(conn))
+	if err != nil {
+		t.Fatalf("Failed to create the pubsub client: %v", err)
+	}
+	subscriber, err := vkit.NewSubscriberClient(ctx, option.WithGRPCConn(conn))
+	if err != nil {
+		t.Fatalf("Failed to create the subscriber client: %v", err)
+	}
+	t.Cleanup(func() {
+		subscriber.Close()
+		client.Close()
+		conn.Close()
+		srv.Close()
+	})
+	return NewPullScheduler(subscriber, workers, maxOutstanding), client
+}
+
+func newTestSubscription(ctx context.Context, t *testing.T, client *pubsub.Client, id string) (*pubsub.Topic, *pubsub.Subscription) {
+	t.Helper()
+	topic, err := client.CreateTopic(ctx, id)
+	if err != nil {
+		t.Fatalf("Failed to create topic: %v", err)
+	}
+	sub, err := client.CreateSubscription(ctx, id, pubsub.SubscriptionConfig{Topic: topic})
+	if err != nil {
+		t.Fatalf("Failed to create subscription: %v", err)
+	}
+	return topic, sub
+}
+
+func publish(ctx context.Context, t *testing.T, topic *pubsub.Topic, data string) {
+	t.Helper()
+	if _, err := topic.Publish(ctx, &pubsub.Message{Data: []byte(data)}).Get(ctx); err != nil {
+		t.Fatalf("Failed to publish: %v", err)
+	}
+}
+
+func TestPullSchedulerServesManySubscriptions(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s, client := newTestPullScheduler(t, 2, 10)
+	s.Start(ctx)
+
+	const numSubs = 20
+	var mu sync.Mutex
+	received := make(map[string]int)
+	var wg sync.WaitGroup
+	wg.Add(numSubs)
+	for i := 0; i < numSubs; i++ {
+		topic, sub := newTestSubscription(ctx, t, client, fmt.Sprintf("sub-%d", i))
+		go s.serve(ctx, sub.String(), func(_ context.Context, msg *pubsub.Message) (bool, time.Duration) {
+			mu.Lock()
+			defer mu.Unlock()
+			received[string(msg.Data)]++
+			if received[string(msg.Data)] == 1 {
+				wg.Done()
+			}
+			return true, 0
+		})
+		publish(ctx, t, topic, sub.ID())
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("Not all subscriptions were served, got %v", received)
+	}
+
+	// Acked messages are not redelivered.
+	time.Sleep(time.Second)
+	mu.Lock()
+	defer mu.Unlock()
+	for data, n := range received {
+		if n != 1 {
+			t.Errorf("Message %q was received %d times, want once", data, n)
+		}
+	}
+}
+
+func TestPullSchedulerFairShare(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s, client := newTestPullScheduler(t, 2, 4)
+	s.Start(ctx)
+
+	busyTopic, busySub := newTestSubscription(ctx, t, client, "busy")
+	quietTopic, quietSub := newTestSubscription(ctx, t, client, "quiet")
+
+	// The busy subscription holds every message it gets.
+	release := make(chan struct{})
+	var mu sync.Mutex
+	busyOutstanding, maxBusyOutstanding := 0, 0
+	go s.serve(ctx, busySub.String(), func(ctx context.Context, _ *pubsub.Message) (bool, time.Duration) {
+		mu.Lock()
+		busyOutstanding++
+		if busyOutstanding > maxBusyOutstanding {
+			maxBusyOutstanding = busyOutstanding
+		}
+		mu.Unlock()
+		select {
+		case <-release:
+		case <-ctx.Done():
+		}
+		mu.Lock()
+		busyOutstanding--
+		mu.Unlock()
+		return true, 0
+	})
+	quietReceived := make(chan struct{}, 1)
+	go s.serve(ctx, quietSub.String(), func(context.Context, *pubsub.Message) (bool, time.Duration) {
+		quietReceived <- struct{}{}
+		return true, 0
+	})
+
+	for i := 0; i < 10; i++ {
+		publish(ctx, t, busyTopic, fmt.Sprintf("busy-%d", i))
+	}
+	// Let the busy subscription take as much budget as it can.
+	time.Sleep(time.Second)
+	publish(ctx, t, quietTopic, "quiet")
+
+	select {
+	case <-quietReceived:
+	case <-time.After(10 * time.Second):
+		t.Fatal("The quiet subscription was starved by the busy one")
+	}
+	close(release)
+	mu.Lock()
+	defer mu.Unlock()
+	// The budget of a message is reserved for the quiet subscription.
+	if maxBusyOutstanding > 3 {
+		t.Errorf("The busy subscription had %d messages outstanding, want at most 3", maxBusyOutstanding)
+	}
+}
+
+func TestPullSchedulerNackRedelivers(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s, client := newTestPullScheduler(t, 1, 10)
+	s.Start(ctx)
+
+	topic, sub := newTestSubscription(ctx, t, client, "nack")
+	attempts := make(chan struct{}, 10)
+	var count int32
+	go s.serve(ctx, sub.String(), func(context.Context, *pubsub.Message) (bool, time.Duration) {
+		attempts <- struct{}{}
+		// Nack the first attempt.
+		return atomic.AddInt32(&count, 1) > 1, 0
+	})
+	publish(ctx, t, topic, "data")
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-attempts:
+		case <-time.After(10 * time.Second):
+			t.Fatalf("Got %d attempts, want 2", i)
+		}
+	}
+}
+
+func TestPullSchedulerNackDelaysRedelivery(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s, client := newTestPullScheduler(t, 1, 10)
+	s.Start(ctx)
+
+	topic, sub := newTestSubscription(ctx, t, client, "delay")
+	delay := 500 * time.Millisecond
+	attempts := make(chan time.Time, 10)
+	var count int32
+	go s.serve(ctx, sub.String(), func(context.Context, *pubsub.Message) (bool, time.Duration) {
+		attempts <- time.Now()
+		// Nack the first attempt with a delay.
+		return atomic.AddInt32(&count, 1) > 1, delay
+	})
+	publish(ctx, t, topic, "data")
+
+	var got []time.Time
+	for i := 0; i < 2; i++ {
+		select {
+		case attempt := <-attempts:
+			got = append(got, attempt)
+		case <-time.After(10 * time.Second):
+			t.Fatalf("Got %d attempts, want 2", i)
+		}
+	}
+	if d := got[1].Sub(got[0]); d < delay {
+		t.Errorf("Message redelivered after %v, want at least %v", d, delay)
+	}
+}
+
+func TestPullSchedulerMissingSubscription(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s, client := newTestPullScheduler(t, 1, 10)
+	s.Start(ctx)
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- s.serve(ctx, client.Subscription("missing").String(), func(context.Context, *pubsub.Message) (bool, time.Duration) {
+			return true, 0
+		})
+	}()
+	select {
+	case err := <-errc:
+		if err == nil {
+			t.Error("Serving a missing subscription returned no error")
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Serving a missing subscription did not return")
+	}
+}
@@ -47,12 +47,38 @@ type RetryPool struct {
 	// For initial events delivery. We only need a shared client.
 	// And we can set target address dynamically.
 	deliverClient *http.Client
-	statsReporter *metrics.DeliveryReporter
+	// For sending events that targets reject as non-retryable to their dead letter topics.
+	deadLetterClient RetryClient
+	statsReporter    *metrics.DeliveryReporter
+	// Adaptive concurrency limiters of the targets, nil if deliveries are not limited.
+	limiters *deliver.ConcurrencyLimiters
+
+	// Values of the delivery headers read from the mounted Secret, nil if it is not mounted.
+	headerSecrets *deliver.HeaderSecrets
+
+	// Publishers of the targets that are Pub/Sub topics.
+	topics *deliver.Topics
+
+	// Batchers of the targets that are BigQuery tables, nil if no BigQuery client is configured.
+	tables *deliver.Tables
+
+	// running tracks the handlers until they are stopped and drained.
+	running sync.WaitGroup
 }
 
 type retryHandlerCache struct {
 	Handler
 	t *config.Target
+	// draining delivers the remaining retries of the draining retry queue of the target, if any.
+	draining *Handler
+}
+
+// Stop stops the handlers of the target.
+func (hc *retryHandlerCache) Stop() {
+	hc.Handler.Stop()
+	if hc.draining != nil {
+		hc.draining.Stop()
+	}
 }
 
 // If somehow the existing handler's setting has deviated from the current target config,
@@ -70,6 +96,12 @@ func (hc *retryHandlerCache) shouldRenew(t *config.Target) bool {
 		t.RetryQueue.Subscription != hc.t.RetryQueue.Subscription {
 		return true
 	}
+	if t.DrainingRetryQueue.GetSubscription() != hc.t.DrainingRetryQueue.GetSubscription() {
+		return true
+	}
+	if hc.draining != nil && !hc.draining.IsAlive() {
+		return true
+	}
 	return false
 }
 
@@ -78,6 +110,7 @@ func NewRetryPool(
 	targets config.ReadonlyTargets,
 	pubsubClient *pubsub.Client,
 	deliverClient *http.Client,
+	deadLetterClient RetryClient,
 	statsReporter *metrics.DeliveryReporter,
 	opts ...Option) (*RetryPool, error) {
 	options, err := NewOptions(opts...)
@@ -86,12 +119,23 @@ func NewRetryPool(
 	}
 
 	p := &RetryPool{
-		targets:       targets,
-		options:       options,
-		pool:          &syncMapTargetKey{},
-		pubsubClient:  pubsubClient,
-		deliverClient: deliverClient,
-		statsReporter: statsReporter,
+		targets:          targets,
+		options:          options,
+		pool:             &syncMapTargetKey{},
+		pubsubClient:     pubsubClient,
+		deliverClient:    deliverClient,
+		deadLetterClient: deadLetterClient,
+		statsReporter:    statsReporter,
+		topics:           deliver.NewTopics(pubsubClient),
+	}
+	if options.MaxConcurrencyPerTarget > 0 {
+		p.limiters = deliver.NewConcurrencyLimiters(options.MaxConcurrencyPerTarget)
+	}
+	if options.DeliveryHeadersPath != "" {
+		p.headerSecrets = deliver.NewHeaderSecrets(options.DeliveryHeadersPath)
+	}
+	if options.BigQueryClient != nil {
+		p.tables = deliver.NewTables(options.BigQueryClient, options.BigQueryBatchSize, options.BigQueryBatchDelay)
 	}
 	return p, nil
 }
@@ -103,6 +147,14 @@ func (p *RetryPool) SyncOnce(ctx context.Context) error {
 		logging.FromContext(ctx).Error("failed to add tags to context", zap.Error(err))
 	}
 
+	if p.limiters != nil {
+		p.limiters.Prune(p.targets)
+	}
+	p.topics.Prune(p.targets)
+	if p.tables != nil {
+		p.tables.Prune(p.targets)
+	}
+
 	p.pool.Range(func(key config.TargetKey, value *retryHandlerCache) bool {
 		// Each target represents a trigger.
 		if _, ok := p.targets.GetTargetByKey(&key); !ok {
@@ -129,25 +181,13 @@ func (p *RetryPool) SyncOnce(ctx context.Context) error {
 			return true
 		}
 
-		sub := p.pubsubClient.Subscription(t.RetryQueue.Subscription)
-		sub.ReceiveSettings = p.options.PubsubReceiveSettings
-
-		h := NewHandler(
-			sub,
-			processors.ChainProcessors(
-				&filter.Processor{Targets: p.targets},
-				&deliver.Processor{
-					DeliverClient: p.deliverClient,
-					Targets:       p.targets,
-					StatsReporter: p.statsReporter,
-				},
-			),
-			p.options.TimeoutPerEvent,
-		)
 		hc := &retryHandlerCache{
-			Handler: *h,
+			Handler: *p.newHandler(t.RetryQueue.Subscription),
 			t:       t,
 		}
+		if sub := t.DrainingRetryQueue.GetSubscription(); sub != "" {
+			hc.draining = p.newHandler(sub)
+		}
 
 		ctx, err := metrics.AddTargetTags(ctx, t)
 		if err != nil {
@@ -158,7 +198,9 @@ func (p *RetryPool) SyncOnce(ctx context.Context) error {
 		ctx = handlerctx.WithBrokerKey(ctx, t.Key().ParentKey())
 		ctx = handlerctx.WithTargetKey(ctx, t.Key())
 		// Start the handler with target in context.
+		p.running.Add(1)
 		hc.Start(ctx, func(err error) {
+			defer p.running.Done()
 			// We will anyway get an error because of https://github.com/cloudevents/sdk-go/issues/470
 			if err != nil {
 				logging.FromContext(ctx).Error("handler for trigger has stopped with error", zap.Stringer("trigger", t.Key()), zap.Error(err))
@@ -166,6 +208,17 @@ func (p *RetryPool) SyncOnce(ctx context.Context) error {
 				logging.FromContext(ctx).Info("handler for trigger has stopped", zap.Stringer("trigger", t.Key()))
 			}
 		})
+		if hc.draining != nil {
+			p.running.Add(1)
+			hc.draining.Start(ctx, func(err error) {
+				defer p.running.Done()
+				if err != nil {
+					logging.FromContext(ctx).Error("draining handler for trigger has stopped with error", zap.Stringer("trigger", t.Key()), zap.Error(err))
+				} else {
+					logging.FromContext(ctx).Info("draining handler for trigger has stopped", zap.Stringer("trigger", t.Key()))
+				}
+			})
+		}
 
 		p.pool.Store(*t.Key(), hc)
 		return true
@@ -174,6 +227,46 @@ func (p *RetryPool) SyncOnce(ctx context.Context) error {
 	return nil
 }
 
+// newHandler creates the handler delivering the retries pulled from the subscription.
+func (p *RetryPool) newHandler(subscription string) *Handler {
+	sub := p.pubsubClient.Subscription(subscription)
+	sub.ReceiveSettings = p.options.PubsubReceiveSettings
+
+	h := NewHandler(
+		sub,
+		processors.ChainProcessors(
+			&filter.Processor{Targets: p.targets},
+			&deliver.Processor{
+				DeliverClient:      p.deliverClient,
+				Targets:            p.targets,
+				DeliverRetryClient: p.deadLetterClient,
+				StatsReporter:      p.statsReporter,
+				Limiters:           p.limiters,
+				HeaderSecrets:      p.headerSecrets,
+				Recorder:           p.options.EventRecorder,
+				Topics:             p.topics,
+				Tables:             p.tables,
+			},
+		),
+		p.options.TimeoutPerEvent,
+	)
+	h.Scheduler = p.options.PullScheduler
+	h.DrainTimeout = p.options.DrainTimeout
+	h.StatsReporter = p.statsReporter
+	return h
+}
+
+// Drain stops all the handlers and waits for their in-flight events to be
+// drained, including the handlers stopped by previous syncs, or the context
+// to be done.
+func (p *RetryPool) Drain(ctx context.Context) error {
+	p.pool.Range(func(_ config.TargetKey, value *retryHandlerCache) bool {
+		value.Stop()
+		return true
+	})
+	return waitDrained(ctx, &p.running)
+}
+
 // syncMapTargetKey is a typed version of sync.Map.
 type syncMapTargetKey struct {
 	m sync.Map
@@ -21,9 +21,12 @@ import (
 	"testing"
 	"time"
 
+	"github.com/cloudevents/sdk-go/v2/binding"
+	cecontext "github.com/cloudevents/sdk-go/v2/context"
 	"github.com/cloudevents/sdk-go/v2/event"
 	"github.com/google/go-cmp/cmp"
 	"golang.org/x/sync/errgroup"
+	"google.golang.org/protobuf/proto"
 
 	"github.com/google/knative-gcp/pkg/broker/config"
 	"github.com/google/knative-gcp/pkg/broker/eventutil"
@@ -51,7 +54,7 @@ func TestRetryWatchAndSync(t *testing.T) {
 	defer helper.Close()
 
 	signal := make(chan struct{})
-	syncPool, err := InitializeTestRetryPool(helper.Targets, retryPod, retryContainer, helper.PubsubClient)
+	syncPool, err := InitializeTestRetryPool(ctx, helper.Targets, retryPod, retryContainer, helper.PubsubClient)
 	if err != nil {
 		t.Errorf("unexpected error from getting sync pool: %v", err)
 	}
@@ -148,7 +151,7 @@ func TestRetrySyncPoolE2E(t *testing.T) {
 	expectMetrics.AddTrigger(t, trigger(t3), wantRetryTags())
 
 	signal := make(chan struct{})
-	syncPool, err := InitializeTestRetryPool(helper.Targets, retryPod, retryContainer, helper.PubsubClient)
+	syncPool, err := InitializeTestRetryPool(ctx, helper.Targets, retryPod, retryContainer, helper.PubsubClient)
 	if err != nil {
 		t.Errorf("unexpected error from getting sync pool: %v", err)
 	}
@@ -319,6 +322,35 @@ func TestRetrySyncPoolE2E(t *testing.T) {
 		expectMetrics.Expect200(t, trigger(t2))
 		expectMetrics.Verify(t)
 	})
+
+	t.Run("event remaining in the draining retry queue delivered", func(t *testing.T) {
+		previous := t3.RetryQueue
+		// Don't modify the target the pool may still hold.
+		renewed := proto.Clone(helper.RenewTarget(ctx, t, t3.Key())).(*config.Target)
+		renewed.DrainingRetryQueue = &config.Queue{Subscription: previous.Subscription}
+		helper.Targets.MutateCellTenant(renewed.Key().ParentKey(), func(bm config.CellTenantMutation) {
+			bm.UpsertTargets(renewed)
+		})
+		signal <- struct{}{}
+
+		group, ctx := errgroup.WithContext(ctx)
+		group.Go(func() error {
+			helper.VerifyNextTargetEvent(ctx, t, t3.Key(), &e3)
+			return nil
+		})
+
+		// Send the event to the previous retry topic.
+		if err := helper.CePubsub.Send(cecontext.WithTopic(ctx, previous.Topic), binding.ToMessage(&e3)); err != nil {
+			t.Fatalf("failed to seed event to the previous retry queue: %v", err)
+		}
+
+		if err := group.Wait(); err != nil {
+			t.Error(err)
+		}
+
+		expectMetrics.Expect200(t, trigger(t3))
+		expectMetrics.Verify(t)
+	})
 }
 
 func assertRetryHandlers(t *testing.T, p *RetryPool, targets config.Targets) {
@@ -39,6 +39,7 @@ import (
 	"github.com/google/uuid"
 	"google.golang.org/api/option"
 	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
 )
 
 type serverCfg struct {
@@ -247,6 +248,8 @@ func (h *Helper) RenewTarget(ctx context.Context, t *testing.T, targetKey *confi
 	if !ok {
 		t.Fatalf("target with key %q doesn't exist", targetKey)
 	}
+	// The handler pools may still read the stored target, so renew a copy.
+	target = proto.Clone(target).(*config.Target)
 
 	rid := uuid.New().String()
 	topic := "retry-topic-" + rid
@@ -0,0 +1,133 @@
+/*
+Copyright 2021 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/google/knative-gcp/pkg/broker/config"
+)
+
+// defaultRetryAfter is the retry delay suggested to clients when the time at which capacity frees
+// up is unknown, e.g. when the bytes in flight quota or the publish buffer is exhausted.
+const defaultRetryAfter = time.Second
+
+// admission enforces the ingress quotas of CellTenants.
+type admission struct {
+	mu      sync.Mutex
+	tenants map[config.CellTenantKey]*tenantAdmission
+}
+
+// tenantAdmission tracks the usage of a single CellTenant's quota.
+type tenantAdmission struct {
+	quota *config.IngressQuota
+	// limiter is nil when the events per second quota is not enforced.
+	limiter *rate.Limiter
+
+	mu            sync.Mutex
+	bytesInFlight int64
+}
+
+func newAdmission() *admission {
+	return &admission{tenants: make(map[config.CellTenantKey]*tenantAdmission)}
+}
+
+// admit checks whether an event of the given size is within the quota of the CellTenant. If the
+// event is admitted, release must be called once the event is no longer in flight. Otherwise
+// retryAfter is the suggested delay before retrying.
+func (a *admission) admit(key *config.CellTenantKey, quota *config.IngressQuota, size int64) (release func(), retryAfter time.Duration, ok bool) {
+	t := a.tenant(key, quota)
+	if t == nil {
+		return func() {}, 0, true
+	}
+
+	if !t.reserveBytes(size) {
+		return nil, defaultRetryAfter, false
+	}
+	if t.limiter != nil {
+		now := time.Now()
+		r := t.limiter.ReserveN(now, 1)
+		if delay := r.DelayFrom(now); delay > 0 {
+			r.CancelAt(now)
+			t.releaseBytes(size)
+			return nil, delay, false
+		}
+	}
+	return func() { t.releaseBytes(size) }, 0, true
+}
+
+// tenant returns the admission state of the CellTenant, recreating it if its quota changed. It
+// returns nil if the CellTenant has no quota.
+func (a *admission) tenant(key *config.CellTenantKey, quota *config.IngressQuota) *tenantAdmission {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if quota == nil || (quota.EventsPerSecond <= 0 && quota.BytesInFlight <= 0) {
+		delete(a.tenants, *key)
+		return nil
+	}
+	if t, ok := a.tenants[*key]; ok && proto.Equal(t.quota, quota) {
+		return t
+	}
+	t := &tenantAdmission{quota: quota}
+	if quota.EventsPerSecond > 0 {
+		// Allow bursts of up to one second worth of events.
+		t.limiter = rate.NewLimiter(rate.Limit(quota.EventsPerSecond), int(quota.EventsPerSecond))
+	}
+	a.tenants[*key] = t
+	return t
+}
+
+// prune drops the admission state of the CellTenants that are no longer in the targets or no
+// longer have a quota, so that the state of deleted CellTenants doesn't accumulate.
+func (a *admission) prune(targets config.ReadonlyTargets) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for key := range a.tenants {
+		key := key
+		if b, ok := targets.GetCellTenantByKey(&key); !ok || b.IngressQuota == nil {
+			delete(a.tenants, key)
+		}
+	}
+}
+
+// reserveBytes adds size to the bytes in flight if that stays within the quota. An event larger
+// than the quota is admitted when nothing else is in flight, otherwise it could never be admitted.
+func (t *tenantAdmission) reserveBytes(size int64) bool {
+	if t.quota.BytesInFlight <= 0 {
+		return true
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.bytesInFlight > 0 && t.bytesInFlight+size > t.quota.BytesInFlight {
+		return false
+	}
+	t.bytesInFlight += size
+	return true
+}
+
+func (t *tenantAdmission) releaseBytes(size int64) {
+	if t.quota.BytesInFlight <= 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.bytesInFlight -= size
+}
@@ -0,0 +1,261 @@
+/*
+Copyright 2021 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"context"
+	nethttp "net/http"
+	"net/http/httptest"
+	"testing"
+
+	cev2 "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/binding"
+	"github.com/cloudevents/sdk-go/v2/protocol"
+	"github.com/cloudevents/sdk-go/v2/protocol/http"
+	"google.golang.org/protobuf/proto"
+	logtest "knative.dev/pkg/logging/testing"
+
+	"github.com/google/knative-gcp/pkg/broker/config"
+	"github.com/google/knative-gcp/pkg/broker/config/memory"
+	"github.com/google/knative-gcp/pkg/metrics"
+	reportertest "github.com/google/knative-gcp/pkg/metrics/testing"
+)
+
+type fakeAcceptingDecoupleSink struct{}
+
+func (*fakeAcceptingDecoupleSink) Send(_ context.Context, _ *config.CellTenantKey, _ cev2.Event, done func()) protocol.Result {
+	done()
+	return nil
+}
+
+// fakeAsyncDecoupleSink accepts events without completing their publish until complete is called.
+type fakeAsyncDecoupleSink struct {
+	dones []func()
+}
+
+func (m *fakeAsyncDecoupleSink) Send(_ context.Context, _ *config.CellTenantKey, _ cev2.Event, done func()) protocol.Result {
+	m.dones = append(m.dones, done)
+	return nil
+}
+
+func (m *fakeAsyncDecoupleSink) complete() {
+	for _, done := range m.dones {
+		done()
+	}
+	m.dones = nil
+}
+
+func TestAdmissionEventsPerSecond(t *testing.T) {
+	a := newAdmission()
+	key := config.TestOnlyBrokerKey("ns", "broker")
+	quota := &config.IngressQuota{EventsPerSecond: 2}
+
+	for i := 0; i < 2; i++ {
+		release, _, ok := a.admit(key, quota, 1)
+		if !ok {
+			t.Fatalf("Event %d was rejected within the burst", i)
+		}
+		release()
+	}
+	_, retryAfter, ok := a.admit(key, quota, 1)
+	if ok {
+		t.Fatal("Event was admitted over the events per second quota")
+	}
+	if retryAfter <= 0 || retryAfter > defaultRetryAfter {
+		t.Errorf("Unexpected retryAfter %v", retryAfter)
+	}
+
+	// A new quota takes effect immediately.
+	if _, _, ok := a.admit(key, &config.IngressQuota{EventsPerSecond: 10}, 1); !ok {
+		t.Error("Event was rejected after the quota was raised")
+	}
+	// Removing the quota admits everything.
+	for i := 0; i < 100; i++ {
+		if _, _, ok := a.admit(key, nil, 1); !ok {
+			t.Fatal("Event was rejected without quota")
+		}
+	}
+}
+
+func TestAdmissionBytesInFlight(t *testing.T) {
+	a := newAdmission()
+	key := config.TestOnlyBrokerKey("ns", "broker")
+	quota := &config.IngressQuota{BytesInFlight: 100}
+
+	release1, _, ok := a.admit(key, quota, 60)
+	if !ok {
+		t.Fatal("First event was rejected")
+	}
+	if _, retryAfter, ok := a.admit(key, quota, 60); ok {
+		t.Fatal("Event was admitted over the bytes in flight quota")
+	} else if retryAfter != defaultRetryAfter {
+		t.Errorf("retryAfter mismatch. got: %v, want: %v", retryAfter, defaultRetryAfter)
+	}
+	release1()
+
+	// An event larger than the quota is admitted when nothing else is in flight. An equal quota
+	// keeps tracking the bytes in flight.
+	release2, _, ok := a.admit(key, proto.Clone(quota).(*config.IngressQuota), 200)
+	if !ok {
+		t.Fatal("Oversized event was rejected while nothing was in flight")
+	}
+	if _, _, ok := a.admit(key, quota, 1); ok {
+		t.Error("Event was admitted while the oversized event was in flight")
+	}
+	release2()
+	if _, _, ok := a.admit(key, quota, 1); !ok {
+		t.Error("Event was rejected after the oversized event was released")
+	}
+}
+
+func TestAdmissionPrune(t *testing.T) {
+	a := newAdmission()
+	quota := &config.IngressQuota{EventsPerSecond: 10}
+	kept := config.TestOnlyBrokerKey("ns", "kept")
+	deleted := config.TestOnlyBrokerKey("ns", "deleted")
+	unlimited := config.TestOnlyBrokerKey("ns", "unlimited")
+	for _, key := range []*config.CellTenantKey{kept, deleted, unlimited} {
+		if _, _, ok := a.admit(key, quota, 1); !ok {
+			t.Fatalf("Event of %v was rejected", key)
+		}
+	}
+
+	targets := memory.NewEmptyTargets()
+	targets.MutateCellTenant(kept, func(m config.CellTenantMutation) {
+		m.SetIngressQuota(quota)
+	})
+	targets.MutateCellTenant(unlimited, func(m config.CellTenantMutation) {})
+	a.prune(targets)
+
+	if _, ok := a.tenants[*kept]; !ok {
+		t.Error("Admission state of the CellTenant with a quota was pruned")
+	}
+	for _, key := range []*config.CellTenantKey{deleted, unlimited} {
+		if _, ok := a.tenants[*key]; ok {
+			t.Errorf("Admission state of %v was kept", key)
+		}
+	}
+}
+
+func TestHandlerIngressQuota(t *testing.T) {
+	tests := []struct {
+		name           string
+		quota          *config.IngressQuota
+		decouple       DecoupleSink
+		wantCodes      []int
+		wantRetryAfter string
+	}{{
+		name:      "no quota",
+		decouple:  &fakeAcceptingDecoupleSink{},
+		wantCodes: []int{nethttp.StatusAccepted, nethttp.StatusAccepted, nethttp.StatusAccepted},
+	}, {
+		name:           "events per second quota exceeded",
+		quota:          &config.IngressQuota{EventsPerSecond: 2},
+		decouple:       &fakeAcceptingDecoupleSink{},
+		wantCodes:      []int{nethttp.StatusAccepted, nethttp.StatusAccepted, nethttp.StatusTooManyRequests},
+		wantRetryAfter: "1",
+	}, {
+		name:           "pubsub overloaded",
+		decouple:       &fakeOverloadedDecoupleSink{},
+		wantCodes:      []int{nethttp.StatusTooManyRequests},
+		wantRetryAfter: "1",
+	}}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			reportertest.ResetIngressMetrics()
+			ctx := logtest.TestContextWithLogger(t)
+			targets := memory.NewTargets(&config.TargetsConfig{
+				CellTenants: map[string]*config.CellTenant{
+					"ns1/broker1": {
+						Type:          config.CellTenantType_BROKER,
+						Name:          "broker1",
+						Namespace:     "ns1",
+						DecoupleQueue: &config.Queue{Topic: topicID, State: config.State_READY},
+						IngressQuota:  tc.quota,
+					},
+				},
+			})
+			statsReporter, err := metrics.NewIngressReporter(metrics.PodName(pod), metrics.ContainerName(container))
+			if err != nil {
+				t.Fatal(err)
+			}
+			h := NewHandler(ctx, nil, nil, tc.decouple, targets, nil, statsReporter, "")
+
+			var res *nethttp.Response
+			for i, wantCode := range tc.wantCodes {
+				req := httptest.NewRequest("POST", "/ns1/broker1", nil)
+				if err := http.WriteRequest(ctx, binding.ToMessage(createTestEvent("test-event")), req); err != nil {
+					t.Fatal(err)
+				}
+				w := httptest.NewRecorder()
+				h.ServeHTTP(w, req)
+				res = w.Result()
+				if res.StatusCode != wantCode {
+					t.Fatalf("Request %d status code mismatch. got: %v, want: %v", i, res.StatusCode, wantCode)
+				}
+			}
+			if got := res.Header.Get("Retry-After"); got != tc.wantRetryAfter {
+				t.Errorf("Retry-After mismatch. got: %q, want: %q", got, tc.wantRetryAfter)
+			}
+		})
+	}
+}
+
+func TestHandlerIngressQuotaAsyncPublish(t *testing.T) {
+	reportertest.ResetIngressMetrics()
+	ctx := logtest.TestContextWithLogger(t)
+	targets := memory.NewTargets(&config.TargetsConfig{
+		CellTenants: map[string]*config.CellTenant{
+			"ns1/broker1": {
+				Type:          config.CellTenantType_BROKER,
+				Name:          "broker1",
+				Namespace:     "ns1",
+				DecoupleQueue: &config.Queue{Topic: topicID, State: config.State_READY},
+				IngressQuota:  &config.IngressQuota{BytesInFlight: 10},
+			},
+		},
+	})
+	statsReporter, err := metrics.NewIngressReporter(metrics.PodName(pod), metrics.ContainerName(container))
+	if err != nil {
+		t.Fatal(err)
+	}
+	decouple := &fakeAsyncDecoupleSink{}
+	h := NewHandler(ctx, nil, nil, decouple, targets, nil, statsReporter, "")
+
+	send := func() int {
+		req := httptest.NewRequest("POST", "/ns1/broker1", nil)
+		if err := http.WriteRequest(ctx, binding.ToMessage(createTestEventWithPayloadSize("test-event", 10)), req); err != nil {
+			t.Fatal(err)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w.Result().StatusCode
+	}
+
+	if got := send(); got != nethttp.StatusAccepted {
+		t.Fatalf("First request status code got %v, want %v", got, nethttp.StatusAccepted)
+	}
+	// The bytes of the accepted event stay in flight until its publish completes.
+	if got := send(); got != nethttp.StatusTooManyRequests {
+		t.Fatalf("Request before the publish completed status code got %v, want %v", got, nethttp.StatusTooManyRequests)
+	}
+	decouple.complete()
+	if got := send(); got != nethttp.StatusAccepted {
+		t.Fatalf("Request after the publish completed status code got %v, want %v", got, nethttp.StatusAccepted)
+	}
+}
@@ -0,0 +1,168 @@
+/*
+Copyright 2021 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	cev2 "github.com/cloudevents/sdk-go/v2"
+	"go.uber.org/zap"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"knative.dev/pkg/system"
+
+	"github.com/google/knative-gcp/pkg/broker/config"
+	"github.com/google/knative-gcp/pkg/broker/eventtype"
+	"github.com/google/knative-gcp/pkg/logging"
+	"github.com/google/knative-gcp/pkg/metrics"
+)
+
+const (
+	// eventTypeReportPeriod is how often the observed event types are reported.
+	eventTypeReportPeriod = 30 * time.Second
+	// maxEventTypesPerBroker bounds the number of distinct event types tracked for a single Broker.
+	// Event types beyond it are not observed until tracked ones expire.
+	maxEventTypesPerBroker = 100
+)
+
+// EventTypeReporter observes the distinct event types published to each Broker and periodically
+// reports them to the controller in the shared report ConfigMap, under the name of the ingress Pod.
+type EventTypeReporter struct {
+	client    kubernetes.Interface
+	namespace string
+	podName   string
+	period    time.Duration
+
+	mu      sync.Mutex
+	brokers map[string]map[eventtype.Tuple]*eventtype.Observation
+	// dirty is whether there are observations that have not been reported yet.
+	dirty bool
+}
+
+// NewEventTypeReporter creates an EventTypeReporter for the ingress Pod.
+func NewEventTypeReporter(client kubernetes.Interface, podName metrics.PodName) *EventTypeReporter {
+	return &EventTypeReporter{
+		client:    client,
+		namespace: system.Namespace(),
+		podName:   string(podName),
+		period:    eventTypeReportPeriod,
+		brokers:   make(map[string]map[eventtype.Tuple]*eventtype.Observation),
+	}
+}
+
+// Observe records the type of an event published to the broker. It is a no-op on a nil reporter.
+func (r *EventTypeReporter) Observe(broker *config.CellTenantKey, event *cev2.Event) {
+	if r == nil {
+		return
+	}
+	t := eventtype.Tuple{Type: event.Type(), Source: event.Source(), Schema: event.DataSchema()}
+	now := metav1.Now()
+	key := broker.PersistenceString()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	tuples, ok := r.brokers[key]
+	if !ok {
+		tuples = make(map[eventtype.Tuple]*eventtype.Observation)
+		r.brokers[key] = tuples
+	}
+	o, ok := tuples[t]
+	if !ok {
+		if len(tuples) >= maxEventTypesPerBroker {
+			return
+		}
+		o = &eventtype.Observation{Tuple: t}
+		tuples[t] = o
+	}
+	o.Count++
+	o.LastSeen = now
+	r.dirty = true
+}
+
+// Start blocks to report the observed event types every period until the context is done.
+func (r *EventTypeReporter) Start(ctx context.Context) error {
+	ticker := time.NewTicker(r.period)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := r.report(ctx); err != nil {
+				logging.FromContext(ctx).Warn("Failed to report observed event types", zap.Error(err))
+			}
+		}
+	}
+}
+
+// report writes the observed event types to the report ConfigMap, if they changed since the last
+// report. The ConfigMap is created by the controller, and concurrent updates from other Pods fail
+// on conflict and are retried in the next period.
+func (r *EventTypeReporter) report(ctx context.Context) (err error) {
+	report, ok := r.snapshot(time.Now().Add(-eventtype.TTL))
+	if !ok {
+		return nil
+	}
+	defer func() {
+		if err != nil {
+			// Report again in the next period.
+			r.mu.Lock()
+			r.dirty = true
+			r.mu.Unlock()
+		}
+	}()
+	configMaps := r.client.CoreV1().ConfigMaps(r.namespace)
+	cm, err := configMaps.Get(ctx, eventtype.ReportsName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	cm = cm.DeepCopy()
+	report.ReportedAt = metav1.Now()
+	if err := eventtype.SetReport(cm, r.podName, report); err != nil {
+		return err
+	}
+	_, err = configMaps.Update(ctx, cm, metav1.UpdateOptions{})
+	return err
+}
+
+// snapshot expires the observations last seen before notBefore and returns the remaining ones. It
+// returns false if nothing changed since the last snapshot.
+func (r *EventTypeReporter) snapshot(notBefore time.Time) (*eventtype.Report, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	report := &eventtype.Report{Brokers: make(map[string][]eventtype.Observation, len(r.brokers))}
+	for key, tuples := range r.brokers {
+		for t, o := range tuples {
+			if o.LastSeen.Time.Before(notBefore) {
+				delete(tuples, t)
+				r.dirty = true
+				continue
+			}
+			report.Brokers[key] = append(report.Brokers[key], *o)
+		}
+		if len(tuples) == 0 {
+			delete(r.brokers, key)
+		}
+	}
+	if !r.dirty {
+		return nil, false
+	}
+	r.dirty = false
+	return report, true
+}
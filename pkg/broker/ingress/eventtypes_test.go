@@ -0,0 +1,135 @@
+/*
+Copyright 2021 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	cev2 "github.com/cloudevents/sdk-go/v2"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"knative.dev/pkg/system"
+	_ "knative.dev/pkg/system/testing"
+
+	"github.com/google/knative-gcp/pkg/broker/config"
+	"github.com/google/knative-gcp/pkg/broker/eventtype"
+)
+
+func newTypedEvent(eventType, source, schema string) *cev2.Event {
+	e := cev2.NewEvent()
+	e.SetID("id")
+	e.SetType(eventType)
+	e.SetSource(source)
+	e.SetDataSchema(schema)
+	return &e
+}
+
+func TestEventTypeReporter(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset()
+	r := NewEventTypeReporter(client, "ingress-pod")
+	broker := config.TestOnlyBrokerKey("ns", "broker")
+	other := config.TestOnlyBrokerKey("ns", "other")
+
+	r.Observe(broker, newTypedEvent("type1", "source1", ""))
+	r.Observe(broker, newTypedEvent("type1", "source1", ""))
+	r.Observe(broker, newTypedEvent("type2", "source1", "https://schema.example.com"))
+	r.Observe(other, newTypedEvent("type1", "source2", ""))
+
+	// The report ConfigMap is created by the controller, so reporting fails until it exists.
+	if err := r.report(ctx); err == nil {
+		t.Error("Reporting without the report ConfigMap got nil error, want error")
+	}
+	reports := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: system.Namespace(), Name: eventtype.ReportsName}}
+	if _, err := client.CoreV1().ConfigMaps(system.Namespace()).Create(ctx, reports, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("Failed to create the report ConfigMap: %v", err)
+	}
+	if err := r.report(ctx); err != nil {
+		t.Fatalf("Unexpected error reporting: %v", err)
+	}
+
+	cm, err := client.CoreV1().ConfigMaps(system.Namespace()).Get(ctx, eventtype.ReportsName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get the report ConfigMap: %v", err)
+	}
+	if _, ok := cm.Data["ingress-pod"]; !ok {
+		t.Errorf("Report ConfigMap data got %v, want the report of the ingress Pod", cm.Data)
+	}
+	decoded, err := eventtype.ReportsFromConfigMap(cm)
+	if err != nil || len(decoded) != 1 {
+		t.Fatalf("Failed to decode the report: %v", err)
+	}
+	report := decoded[0]
+	want := map[string][]eventtype.Observation{
+		"ns/broker": {
+			{Tuple: eventtype.Tuple{Type: "type1", Source: "source1"}, Count: 2},
+			{Tuple: eventtype.Tuple{Type: "type2", Source: "source1", Schema: "https://schema.example.com"}, Count: 1},
+		},
+		"ns/other": {
+			{Tuple: eventtype.Tuple{Type: "type1", Source: "source2"}, Count: 1},
+		},
+	}
+	opts := []cmp.Option{
+		cmpopts.IgnoreFields(eventtype.Observation{}, "LastSeen"),
+		cmpopts.SortSlices(func(a, b eventtype.Observation) bool { return a.Type < b.Type }),
+	}
+	if diff := cmp.Diff(want, report.Brokers, opts...); diff != "" {
+		t.Errorf("Reported event types (-want,+got): %v", diff)
+	}
+
+	// Nothing changed, so the ConfigMap is not written again.
+	client.ClearActions()
+	if err := r.report(ctx); err != nil {
+		t.Fatalf("Unexpected error reporting: %v", err)
+	}
+	if actions := client.Actions(); len(actions) != 0 {
+		t.Errorf("Unexpected actions reporting unchanged event types: %v", actions)
+	}
+
+	// Expired event types are dropped from the report.
+	report, ok := r.snapshot(time.Now().Add(time.Minute))
+	if !ok {
+		t.Fatal("Expiring event types did not change the report")
+	}
+	if len(report.Brokers) != 0 {
+		t.Errorf("Expired event types are still reported: %v", report.Brokers)
+	}
+}
+
+func TestEventTypeReporterBoundsEventTypesPerBroker(t *testing.T) {
+	r := NewEventTypeReporter(fake.NewSimpleClientset(), "ingress-pod")
+	broker := config.TestOnlyBrokerKey("ns", "broker")
+	for i := 0; i < maxEventTypesPerBroker+10; i++ {
+		r.Observe(broker, newTypedEvent(fmt.Sprintf("type%d", i), "source", ""))
+	}
+	report, _ := r.snapshot(time.Time{})
+	if got := len(report.Brokers["ns/broker"]); got != maxEventTypesPerBroker {
+		t.Errorf("Observed event types got %d, want %d", got, maxEventTypesPerBroker)
+	}
+}
+
+func TestNilEventTypeReporter(t *testing.T) {
+	var r *EventTypeReporter
+	// Just assert it won't panic.
+	r.Observe(config.TestOnlyBrokerKey("ns", "broker"), newTypedEvent("type", "source", ""))
+}
@@ -0,0 +1,271 @@
+/*
+Copyright 2021 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	nethttp "net/http"
+	"sync"
+
+	"go.opencensus.io/plugin/ocgrpc"
+	"go.opencensus.io/trace"
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	grpcstatus "google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"knative.dev/pkg/metrics/metricskey"
+
+	"github.com/google/knative-gcp/pkg/broker/config"
+	"github.com/google/knative-gcp/pkg/logging"
+	"github.com/google/knative-gcp/pkg/tracing"
+	"github.com/google/knative-gcp/pkg/utils/authcheck"
+	"github.com/google/knative-gcp/pkg/utils/clients"
+)
+
+// maxConcurrentPublishesPerCall bounds the number of events of a single PublishBatch or
+// PublishStream call that are published to the decouple sink concurrently.
+const maxConcurrentPublishesPerCall = 100
+
+// maxEventsPerStream bounds the number of events of a single PublishStream call, whose results are
+// held until the stream is closed. It matches the number of messages of a PubSub publish request.
+const maxEventsPerStream = 1000
+
+// GRPCReceiver is a gRPC server serving the Publisher service, and the gRPC health service
+// running the same authentication check as the health check of the HTTP receiver. Like the plain
+// HTTP port, the gRPC port is only exposed by the cluster-internal ingress Service and is served
+// without TLS. Clients outside of the cluster publish through the HTTPS external ingress.
+type GRPCReceiver struct {
+	port      int
+	authCheck authcheck.AuthenticationCheck
+}
+
+// NewGRPCReceiver creates a GRPCReceiver that listens on the given port.
+func NewGRPCReceiver(port clients.GRPCPort, authType authcheck.AuthType) *GRPCReceiver {
+	return &GRPCReceiver{port: int(port), authCheck: authcheck.NewDefault(authType)}
+}
+
+// StartListen serves the Publisher service until the context is done, at which point the server
+// is gracefully stopped.
+func (r *GRPCReceiver) StartListen(ctx context.Context, server PublisherServer) error {
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", r.port))
+	if err != nil {
+		return err
+	}
+	s := grpc.NewServer(
+		grpc.StatsHandler(&ocgrpc.ServerHandler{}),
+		// Same limit as the HTTP ingress, which corresponds to the PubSub publish request limit.
+		grpc.MaxRecvMsgSize(maxRequestBodyBytes),
+	)
+	RegisterPublisherServer(s, server)
+	healthpb.RegisterHealthServer(s, &grpcHealthServer{authCheck: r.authCheck})
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- s.Serve(lis)
+	}()
+	select {
+	case <-ctx.Done():
+		s.GracefulStop()
+		return nil
+	case err := <-errCh:
+		return err
+	}
+}
+
+// grpcHealthServer implements the gRPC health service. Like the health check of the HTTP
+// receiver, it reports the failures of the authentication check, which are also written to the
+// termination log of the Pod.
+type grpcHealthServer struct {
+	healthpb.UnimplementedHealthServer
+	authCheck authcheck.AuthenticationCheck
+}
+
+// Check implements healthpb.HealthServer.Check.
+func (s *grpcHealthServer) Check(ctx context.Context, _ *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	if err := s.authCheck.Check(ctx); err != nil {
+		logging.FromContext(ctx).Warn("Authentication check failed", zap.Error(err))
+		return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_NOT_SERVING}, nil
+	}
+	return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}, nil
+}
+
+// grpcPublisher implements PublisherServer. It shares the decouple sink, metrics and tracing of
+// the Handler with the HTTP ingress.
+type grpcPublisher struct {
+	UnimplementedPublisherServer
+	handler *Handler
+}
+
+// Publish implements PublisherServer.Publish.
+func (p *grpcPublisher) Publish(ctx context.Context, req *PublishRequest) (*PublishResponse, error) {
+	ctx, broker, err := p.brokerContext(ctx, req.GetPath())
+	if err != nil {
+		return nil, err
+	}
+	res := p.publishEvent(ctx, broker, req.GetEvent())
+	if res.Code != nethttp.StatusAccepted {
+		st := grpcstatus.New(grpcCode(int(res.Code)), res.Message)
+		if res.RetryAfter != nil {
+			// Clients and interceptors that understand RetryInfo can back off accordingly.
+			if withDetails, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: res.RetryAfter}); err == nil {
+				st = withDetails
+			}
+		}
+		return nil, st.Err()
+	}
+	return res, nil
+}
+
+// PublishBatch implements PublisherServer.PublishBatch.
+func (p *grpcPublisher) PublishBatch(ctx context.Context, req *PublishBatchRequest) (*PublishBatchResponse, error) {
+	ctx, broker, err := p.brokerContext(ctx, req.GetPath())
+	if err != nil {
+		return nil, err
+	}
+	results := make([]*PublishResponse, len(req.GetEvents()))
+	sem := make(chan struct{}, maxConcurrentPublishesPerCall)
+	var wg sync.WaitGroup
+	for i, pe := range req.GetEvents() {
+		i, pe := i, pe
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			results[i] = p.publishEventWithSpan(ctx, broker, pe)
+		}()
+	}
+	wg.Wait()
+	return &PublishBatchResponse{Results: results}, nil
+}
+
+// PublishStream implements PublisherServer.PublishStream. Events are published as they are
+// received, so that the client doesn't need to wait for one event to be published before sending
+// the next. A stream of more than maxEventsPerStream events fails with ResourceExhausted, and the
+// client should split its events over several streams.
+func (p *grpcPublisher) PublishStream(stream Publisher_PublishStreamServer) error {
+	var (
+		mu      sync.Mutex
+		results []*PublishResponse
+		wg      sync.WaitGroup
+	)
+	setResult := func(i int, res *PublishResponse) {
+		mu.Lock()
+		defer mu.Unlock()
+		results[i] = res
+	}
+	sem := make(chan struct{}, maxConcurrentPublishesPerCall)
+	for i := 0; ; i++ {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			wg.Wait()
+			return err
+		}
+		if i >= maxEventsPerStream {
+			wg.Wait()
+			return grpcstatus.Errorf(codes.ResourceExhausted, "a stream may publish at most %d events", maxEventsPerStream)
+		}
+		mu.Lock()
+		results = append(results, nil)
+		mu.Unlock()
+
+		ctx, broker, err := p.brokerContext(stream.Context(), req.GetPath())
+		if err != nil {
+			setResult(i, &PublishResponse{Code: nethttp.StatusNotFound, Message: grpcstatus.Convert(err).Message()})
+			continue
+		}
+		i := i
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			setResult(i, p.publishEventWithSpan(ctx, broker, req.GetEvent()))
+		}()
+	}
+	wg.Wait()
+	return stream.SendAndClose(&PublishBatchResponse{Results: results})
+}
+
+// brokerContext decorates the context with the logger, tracing and metrics resource of the
+// CellTenant at the given path.
+func (p *grpcPublisher) brokerContext(ctx context.Context, path string) (context.Context, *config.CellTenantKey, error) {
+	ctx = logging.WithLogger(ctx, p.handler.logger)
+	ctx = tracing.WithLogging(ctx, trace.FromContext(ctx))
+	broker, err := config.CellTenantKeyFromPersistenceString(path)
+	if err != nil {
+		logging.FromContext(ctx).Debug("Malformed request path", zap.String("path", path))
+		return nil, nil, grpcstatus.Error(codes.NotFound, err.Error())
+	}
+	ctx = logging.With(ctx, zap.Stringer("broker", broker))
+	ctx = metricskey.WithResource(ctx, broker.MetricsResource())
+	return ctx, broker, nil
+}
+
+// publishEventWithSpan publishes the event in its own span, so that each event of a batch or
+// stream is traced separately.
+func (p *grpcPublisher) publishEventWithSpan(ctx context.Context, broker *config.CellTenantKey, pe *CloudEvent) *PublishResponse {
+	ctx, span := trace.StartSpan(ctx, broker.SpanMessagingDestination(), trace.WithSpanKind(trace.SpanKindServer))
+	defer span.End()
+	return p.publishEvent(ctx, broker, pe)
+}
+
+func (p *grpcPublisher) publishEvent(ctx context.Context, broker *config.CellTenantKey, pe *CloudEvent) *PublishResponse {
+	event, err := EventFromProto(pe)
+	if err != nil {
+		logging.FromContext(ctx).Debug("Failed to convert protobuf to event", zap.Error(err))
+		p.handler.reportMetrics(ctx, invalidEventType, nethttp.StatusBadRequest)
+		return &PublishResponse{Code: nethttp.StatusBadRequest, Message: err.Error()}
+	}
+	statusCode, msg, retryAfter := p.handler.publish(ctx, broker, event)
+	res := &PublishResponse{Code: int32(statusCode), Message: msg}
+	if retryAfter > 0 {
+		res.RetryAfter = durationpb.New(retryAfter)
+	}
+	return res
+}
+
+// grpcCode converts the HTTP status code of a publish result to the equivalent gRPC code.
+func grpcCode(statusCode int) codes.Code {
+	switch statusCode {
+	case nethttp.StatusAccepted:
+		return codes.OK
+	case nethttp.StatusBadRequest:
+		return codes.InvalidArgument
+	case nethttp.StatusNotFound:
+		return codes.NotFound
+	case nethttp.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case nethttp.StatusServiceUnavailable:
+		return codes.Unavailable
+	default:
+		return codes.Internal
+	}
+}
@@ -0,0 +1,358 @@
+/*
+Copyright 2021 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	nethttp "net/http"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"cloud.google.com/go/pubsub/pstest"
+	"github.com/cloudevents/sdk-go/v2/binding"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	grpcstatus "google.golang.org/grpc/status"
+	"knative.dev/pkg/logging"
+	logtest "knative.dev/pkg/logging/testing"
+	"knative.dev/pkg/metrics/metricskey"
+
+	"github.com/google/knative-gcp/pkg/broker/config/memory"
+	"github.com/google/knative-gcp/pkg/metrics"
+	reportertest "github.com/google/knative-gcp/pkg/metrics/testing"
+	kgcptesting "github.com/google/knative-gcp/pkg/testing"
+	"github.com/google/knative-gcp/pkg/utils/authcheck"
+	"github.com/google/knative-gcp/pkg/utils/clients"
+)
+
+func TestGRPCPublish(t *testing.T) {
+	tests := []struct {
+		name           string
+		path           string
+		event          *CloudEvent
+		decouple       DecoupleSink
+		wantCode       codes.Code
+		wantMetricTags map[string]string
+	}{
+		{
+			name:     "happy case",
+			path:     "/ns1/broker1",
+			event:    createTestProtoEvent(t, "test-event"),
+			wantCode: codes.OK,
+			wantMetricTags: map[string]string{
+				metricskey.LabelEventType:         eventType,
+				metricskey.LabelResponseCode:      "202",
+				metricskey.LabelResponseCodeClass: "2xx",
+				metricskey.PodName:                pod,
+				metricskey.ContainerName:          container,
+			},
+		},
+		{
+			name:     "malformed path",
+			path:     "/ns1/broker1/and/something/else",
+			event:    createTestProtoEvent(t, "test-event"),
+			wantCode: codes.NotFound,
+		},
+		{
+			name:     "broker doesn't exist",
+			path:     "/ns1/broker-not-exist",
+			event:    createTestProtoEvent(t, "test-event"),
+			wantCode: codes.NotFound,
+			wantMetricTags: map[string]string{
+				metricskey.LabelEventType:         eventType,
+				metricskey.LabelResponseCode:      "404",
+				metricskey.LabelResponseCodeClass: "4xx",
+				metricskey.PodName:                pod,
+				metricskey.ContainerName:          container,
+			},
+		},
+		{
+			name:     "decouple queue not ready",
+			path:     "/ns4/broker4",
+			event:    createTestProtoEvent(t, "test-event"),
+			wantCode: codes.Unavailable,
+			wantMetricTags: map[string]string{
+				metricskey.LabelEventType:         eventType,
+				metricskey.LabelResponseCode:      "503",
+				metricskey.LabelResponseCodeClass: "5xx",
+				metricskey.PodName:                pod,
+				metricskey.ContainerName:          container,
+			},
+		},
+		{
+			name:     "invalid event",
+			path:     "/ns1/broker1",
+			event:    &CloudEvent{Source: "test-source", Type: eventType},
+			wantCode: codes.InvalidArgument,
+			wantMetricTags: map[string]string{
+				metricskey.LabelEventType:         invalidEventType,
+				metricskey.LabelResponseCode:      "400",
+				metricskey.LabelResponseCodeClass: "4xx",
+				metricskey.PodName:                pod,
+				metricskey.ContainerName:          container,
+			},
+		},
+		{
+			name:     "pubsub overloaded",
+			path:     "/ns1/broker1",
+			event:    createTestProtoEvent(t, "test-event"),
+			decouple: &fakeOverloadedDecoupleSink{},
+			wantCode: codes.ResourceExhausted,
+			wantMetricTags: map[string]string{
+				metricskey.LabelEventType:         eventType,
+				metricskey.LabelResponseCode:      "429",
+				metricskey.LabelResponseCodeClass: "4xx",
+				metricskey.PodName:                pod,
+				metricskey.ContainerName:          container,
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			reportertest.ResetIngressMetrics()
+			ctx, cancel := context.WithTimeout(logging.WithLogger(context.Background(), logtest.TestLogger(t)), 5*time.Second)
+			defer cancel()
+
+			psSrv := pstest.NewServer()
+			t.Cleanup(func() { psSrv.Close() })
+
+			decouple := tc.decouple
+			if decouple == nil {
+				decouple = NewMultiTopicDecoupleSink(ctx, memory.NewTargets(brokerConfig), createPubsubClient(ctx, t, psSrv), pubsub.DefaultPublishSettings, nil)
+			}
+			client := createAndStartGRPCIngress(ctx, t, decouple)
+			rec := setupTestReceiver(ctx, t, psSrv)
+
+			res, err := client.Publish(ctx, &PublishRequest{Path: tc.path, Event: tc.event})
+			if code := grpcstatus.Code(err); code != tc.wantCode {
+				t.Fatalf("Code mismatch. got: %v, want: %v, err: %v", code, tc.wantCode, err)
+			}
+			if tc.wantCode == codes.ResourceExhausted {
+				if details := grpcstatus.Convert(err).Details(); len(details) != 1 {
+					t.Errorf("Expected RetryInfo details, got: %v", details)
+				} else if ri, ok := details[0].(*errdetails.RetryInfo); !ok || ri.GetRetryDelay().AsDuration() != defaultRetryAfter {
+					t.Errorf("RetryInfo mismatch. got: %v, want delay: %v", details[0], defaultRetryAfter)
+				}
+			}
+			var wantEventCount int64
+			if tc.wantMetricTags != nil {
+				wantEventCount = 1
+			}
+			verifyMetrics(t, testCase{wantEventCount: wantEventCount, wantMetricTags: tc.wantMetricTags})
+			if err != nil {
+				return
+			}
+			if res.GetCode() != nethttp.StatusAccepted {
+				t.Errorf("Response code mismatch. got: %v, want: %v", res.GetCode(), nethttp.StatusAccepted)
+			}
+
+			m, err := rec.Receive(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer m.Finish(nil)
+			savedToSink, err := binding.ToEvent(ctx, m)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if savedToSink.ID() != tc.event.GetId() {
+				t.Errorf("Event ID mismatch. got: %v, want: %v", savedToSink.ID(), tc.event.GetId())
+			}
+			assertExtensionsExist(EventArrivalTime)(t, savedToSink)
+		})
+	}
+}
+
+func TestGRPCPublishBatchAndStream(t *testing.T) {
+	events := []*CloudEvent{
+		createTestProtoEvent(t, "event-1"),
+		{Source: "test-source", Type: eventType},
+		createTestProtoEvent(t, "event-2"),
+	}
+	wantCodes := []int32{nethttp.StatusAccepted, nethttp.StatusBadRequest, nethttp.StatusAccepted}
+
+	tests := []struct {
+		name    string
+		publish func(ctx context.Context, client PublisherClient) (*PublishBatchResponse, error)
+	}{
+		{
+			name: "batch",
+			publish: func(ctx context.Context, client PublisherClient) (*PublishBatchResponse, error) {
+				return client.PublishBatch(ctx, &PublishBatchRequest{Path: "/ns1/broker1", Events: events})
+			},
+		},
+		{
+			name: "stream",
+			publish: func(ctx context.Context, client PublisherClient) (*PublishBatchResponse, error) {
+				stream, err := client.PublishStream(ctx)
+				if err != nil {
+					return nil, err
+				}
+				for _, e := range events {
+					if err := stream.Send(&PublishRequest{Path: "/ns1/broker1", Event: e}); err != nil {
+						return nil, err
+					}
+				}
+				return stream.CloseAndRecv()
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			reportertest.ResetIngressMetrics()
+			ctx, cancel := context.WithTimeout(logging.WithLogger(context.Background(), logtest.TestLogger(t)), 5*time.Second)
+			defer cancel()
+
+			psSrv := pstest.NewServer()
+			t.Cleanup(func() { psSrv.Close() })
+
+			decouple := NewMultiTopicDecoupleSink(ctx, memory.NewTargets(brokerConfig), createPubsubClient(ctx, t, psSrv), pubsub.DefaultPublishSettings, nil)
+			client := createAndStartGRPCIngress(ctx, t, decouple)
+			rec := setupTestReceiver(ctx, t, psSrv)
+
+			res, err := tc.publish(ctx, client)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(res.GetResults()) != len(wantCodes) {
+				t.Fatalf("Results length mismatch. got: %v, want: %v", len(res.GetResults()), len(wantCodes))
+			}
+			for i, r := range res.GetResults() {
+				if r.GetCode() != wantCodes[i] {
+					t.Errorf("Result %d code mismatch. got: %v, want: %v", i, r.GetCode(), wantCodes[i])
+				}
+			}
+
+			got := make(map[string]bool)
+			for i := 0; i < 2; i++ {
+				m, err := rec.Receive(ctx)
+				if err != nil {
+					t.Fatal(err)
+				}
+				e, err := binding.ToEvent(ctx, m)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got[e.ID()] = true
+				m.Finish(nil)
+			}
+			if !got["event-1"] || !got["event-2"] {
+				t.Errorf("Events missing from the decouple sink, got: %v", got)
+			}
+		})
+	}
+}
+
+func TestGRPCPublishStreamTooLong(t *testing.T) {
+	reportertest.ResetIngressMetrics()
+	ctx, cancel := context.WithTimeout(logging.WithLogger(context.Background(), logtest.TestLogger(t)), 10*time.Second)
+	defer cancel()
+
+	psSrv := pstest.NewServer()
+	t.Cleanup(func() { psSrv.Close() })
+
+	decouple := NewMultiTopicDecoupleSink(ctx, memory.NewTargets(brokerConfig), createPubsubClient(ctx, t, psSrv), pubsub.DefaultPublishSettings, nil)
+	client := createAndStartGRPCIngress(ctx, t, decouple)
+
+	stream, err := client.PublishStream(ctx)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for i := 0; i <= maxEventsPerStream; i++ {
+		if err := stream.Send(&PublishRequest{Path: "/ns1/broker1", Event: createTestProtoEvent(t, fmt.Sprintf("event-%d", i))}); err != nil {
+			// The server may reject the stream before all events are sent.
+			break
+		}
+	}
+	_, err = stream.CloseAndRecv()
+	if got := grpcstatus.Code(err); got != codes.ResourceExhausted {
+		t.Errorf("PublishStream code got %v, want %v", got, codes.ResourceExhausted)
+	}
+}
+
+func TestGRPCHealthCheck(t *testing.T) {
+	tests := []struct {
+		name       string
+		authErr    error
+		wantStatus healthpb.HealthCheckResponse_ServingStatus
+	}{
+		{
+			name:       "authenticated",
+			wantStatus: healthpb.HealthCheckResponse_SERVING,
+		},
+		{
+			name:       "authentication failure",
+			authErr:    errors.New("token is not valid"),
+			wantStatus: healthpb.HealthCheckResponse_NOT_SERVING,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s := &grpcHealthServer{authCheck: &authcheck.FakeAuthenticationCheck{Err: tc.authErr}}
+			res, err := s.Check(logtest.TestContextWithLogger(t), &healthpb.HealthCheckRequest{})
+			if err != nil {
+				t.Fatalf("Unexpected health check error: %v", err)
+			}
+			if res.GetStatus() != tc.wantStatus {
+				t.Errorf("Health check status got %v, want %v", res.GetStatus(), tc.wantStatus)
+			}
+		})
+	}
+}
+
+// createAndStartGRPCIngress creates an ingress with a gRPC receiver, starts it in a goroutine and
+// returns a client connected to it.
+func createAndStartGRPCIngress(ctx context.Context, t *testing.T, decouple DecoupleSink) PublisherClient {
+	statsReporter, err := metrics.NewIngressReporter(metrics.PodName(pod), metrics.ContainerName(container))
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := kgcptesting.GetFreePort(t)
+	h := NewHandler(ctx, &testHttpMessageReceiver{urlCh: make(chan string, 1)}, NewGRPCReceiver(clients.GRPCPort(port), authcheck.WorkloadIdentity), decouple, memory.NewTargets(brokerConfig), nil, statsReporter, "")
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- h.Start(ctx)
+	}()
+	t.Cleanup(func() {
+		if err := <-errCh; err != nil {
+			t.Errorf("Failed to start ingress: %v", err)
+		}
+	})
+
+	conn, err := grpc.DialContext(ctx, fmt.Sprintf("localhost:%d", port), grpc.WithInsecure(), grpc.WithBlock())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return NewPublisherClient(conn)
+}
+
+func createTestProtoEvent(t *testing.T, id string) *CloudEvent {
+	pe, err := EventToProto(createTestEvent(id))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pe
+}
@@ -19,7 +19,9 @@ package ingress
 import (
 	"context"
 	"errors"
+	"math"
 	nethttp "net/http"
+	"strconv"
 	"time"
 
 	"github.com/google/knative-gcp/pkg/broker/config"
@@ -33,13 +35,14 @@ import (
 	"github.com/google/wire"
 	"go.opencensus.io/trace"
 	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
 	"google.golang.org/api/support/bundler"
 	grpccode "google.golang.org/grpc/codes"
 	grpcstatus "google.golang.org/grpc/status"
-	"knative.dev/eventing/pkg/kncloudevents"
 	kntracing "knative.dev/eventing/pkg/tracing"
 	"knative.dev/pkg/metrics/metricskey"
 
+	"github.com/google/knative-gcp/pkg/broker/eventutil"
 	"github.com/google/knative-gcp/pkg/logging"
 	"github.com/google/knative-gcp/pkg/metrics"
 	"github.com/google/knative-gcp/pkg/tracing"
@@ -58,7 +61,10 @@ const (
 	// CloudEvent to measure the time difference between when an event is
 	// received on a broker and before it is dispatched to the trigger function.
 	// The format is an RFC3339 time in string format. For example: 2019-08-26T23:38:17.834384404Z.
-	EventArrivalTime = "knativearrivaltime"
+	EventArrivalTime = eventutil.ArrivalTimeExtension
+
+	// invalidEventType is the event type reported in metrics for requests that are not valid events.
+	invalidEventType = "_invalid_cloud_event_"
 
 	// for permission denied error msg
 	// TODO(cathyzhyi) point to official doc rather than github doc
@@ -66,21 +72,32 @@ const (
 Please refer to "Configure the Authentication Mechanism for GCP" at https://github.com/google/knative-gcp/blob/main/docs/install/install-gcp-broker.md`
 )
 
-// HandlerSet provides a handler with a real HTTPMessageReceiver and pubsub MultiTopicDecoupleSink.
+// HandlerSet provides a handler with a real HTTPMessageReceiver, GRPCMessageReceiver and pubsub
+// MultiTopicDecoupleSink.
 var HandlerSet wire.ProviderSet = wire.NewSet(
 	NewHandler,
-	clients.NewHTTPMessageReceiverWithChecker,
-	wire.Bind(new(HttpMessageReceiver), new(*kncloudevents.HTTPMessageReceiver)),
+	NewHTTPReceiver,
+	NewGRPCReceiver,
+	wire.Bind(new(GRPCMessageReceiver), new(*GRPCReceiver)),
+	NewEventTypeReporter,
 	NewMultiTopicDecoupleSink,
 	wire.Bind(new(DecoupleSink), new(*multiTopicDecoupleSink)),
 	clients.NewPubsubClient,
 	metrics.NewIngressReporter,
 )
 
+// flusher is implemented by decouple sinks that accept events before they are persisted.
+type flusher interface {
+	// Flush blocks until the accepted events are persisted or failed.
+	Flush()
+}
+
 // DecoupleSink is an interface to send events to a decoupling sink (e.g., pubsub).
 type DecoupleSink interface {
-	// Send sends the event from a broker to the corresponding decoupling sink.
-	Send(ctx context.Context, broker *config.CellTenantKey, event cev2.Event) protocol.Result
+	// Send sends the event from a broker to the corresponding decoupling sink. done is called
+	// once the event is no longer in flight, which for an asynchronous publish is after Send
+	// returns.
+	Send(ctx context.Context, broker *config.CellTenantKey, event cev2.Event, done func()) protocol.Result
 }
 
 // HttpMessageReceiver is an interface to listen on http requests.
@@ -88,31 +105,96 @@ type HttpMessageReceiver interface {
 	StartListen(ctx context.Context, handler nethttp.Handler) error
 }
 
+// GRPCMessageReceiver is an interface to listen on gRPC requests.
+type GRPCMessageReceiver interface {
+	StartListen(ctx context.Context, server PublisherServer) error
+}
+
 // Handler receives events and persists them to storage (pubsub).
 type Handler struct {
 	// httpReceiver is an HTTP server to receive events.
 	httpReceiver HttpMessageReceiver
+	// grpcReceiver is a gRPC server to receive events. It is optional.
+	grpcReceiver GRPCMessageReceiver
 	// decouple is the client to send events to a decouple sink.
 	decouple DecoupleSink
-	logger   *zap.Logger
-	reporter *metrics.IngressReporter
-	authType authcheck.AuthType
+	// targets holds the CellTenants' ingress quotas.
+	targets config.ReadonlyTargets
+	// admission enforces the CellTenants' ingress quotas.
+	admission *admission
+	// targetsUpdated signals that the targets were updated. It is optional.
+	targetsUpdated <-chan struct{}
+	// eventTypes reports the event types published to each CellTenant. It is optional.
+	eventTypes *EventTypeReporter
+	logger     *zap.Logger
+	reporter   *metrics.IngressReporter
+	authType   authcheck.AuthType
 }
 
 // NewHandler creates a new ingress handler.
-func NewHandler(ctx context.Context, httpReceiver HttpMessageReceiver, decouple DecoupleSink, reporter *metrics.IngressReporter, authType authcheck.AuthType) *Handler {
+func NewHandler(ctx context.Context, httpReceiver HttpMessageReceiver, grpcReceiver GRPCMessageReceiver, decouple DecoupleSink, targets config.ReadonlyTargets, eventTypes *EventTypeReporter, reporter *metrics.IngressReporter, authType authcheck.AuthType) *Handler {
 	return &Handler{
 		httpReceiver: httpReceiver,
+		grpcReceiver: grpcReceiver,
 		decouple:     decouple,
+		targets:      targets,
+		admission:    newAdmission(),
+		eventTypes:   eventTypes,
 		reporter:     reporter,
 		logger:       logging.FromContext(ctx),
 		authType:     authType,
 	}
 }
 
-// Start blocks to receive events over HTTP.
+// WatchTargets makes the handler drop the quota state of the CellTenants removed from the targets
+// each time the targets are updated, as signaled on the given channel. It must be called before
+// Start.
+func (h *Handler) WatchTargets(updated <-chan struct{}) {
+	h.targetsUpdated = updated
+}
+
+// Start blocks to receive events over HTTP and, if a gRPC receiver is configured, over gRPC. If
+// an event type reporter is configured, it reports the observed event types in the background.
+// Once the receivers stop, the events buffered by the decouple sink are flushed.
 func (h *Handler) Start(ctx context.Context) error {
-	return h.httpReceiver.StartListen(ctx, h)
+	defer h.flush()
+	if h.grpcReceiver == nil && h.eventTypes == nil && h.targetsUpdated == nil {
+		return h.httpReceiver.StartListen(ctx, h)
+	}
+	g, ctx := errgroup.WithContext(ctx)
+	g.Go(func() error {
+		return h.httpReceiver.StartListen(ctx, h)
+	})
+	if h.grpcReceiver != nil {
+		g.Go(func() error {
+			return h.grpcReceiver.StartListen(ctx, &grpcPublisher{handler: h})
+		})
+	}
+	if h.eventTypes != nil {
+		g.Go(func() error {
+			return h.eventTypes.Start(ctx)
+		})
+	}
+	if h.targetsUpdated != nil {
+		g.Go(func() error {
+			for {
+				select {
+				case <-ctx.Done():
+					return nil
+				case <-h.targetsUpdated:
+					h.admission.prune(h.targets)
+				}
+			}
+		})
+	}
+	return g.Wait()
+}
+
+// flush blocks until the events accepted by the decouple sink are persisted or failed.
+func (h *Handler) flush() {
+	if f, ok := h.decouple.(flusher); ok {
+		f.Flush()
+	}
 }
 
 // ServeHTTP implements net/http Handler interface method.
@@ -152,11 +234,44 @@ func (h *Handler) ServeHTTP(response nethttp.ResponseWriter, request *nethttp.Re
 			httpStatus = nethttp.StatusRequestEntityTooLarge
 		}
 		nethttp.Error(response, err.Error(), httpStatus)
-		h.reportMetrics(ctx, "_invalid_cloud_event_", httpStatus)
+		h.reportMetrics(ctx, invalidEventType, httpStatus)
+		return
+	}
+
+	if statusCode, msg, retryAfter := h.publish(ctx, broker, event); statusCode != nethttp.StatusAccepted {
+		if retryAfter > 0 {
+			response.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds(retryAfter)))
+		}
+		nethttp.Error(response, msg, statusCode)
 		return
 	}
+	response.WriteHeader(nethttp.StatusAccepted)
+}
 
-	event.SetExtension(EventArrivalTime, cev2.Timestamp{Time: time.Now()})
+// publish stamps the event with its arrival time, resolves its scheduled delivery time and sends it to the decouple sink of the broker.
+// It is shared by the HTTP and gRPC ingress. It returns the HTTP status code of the result and,
+// if the event was not accepted, a message describing the failure and, for 429, the suggested
+// delay before retrying.
+func (h *Handler) publish(ctx context.Context, broker *config.CellTenantKey, event *cev2.Event) (int, string, time.Duration) {
+	arrival := time.Now()
+	event.SetExtension(EventArrivalTime, cev2.Timestamp{Time: arrival})
+	// Any producer can set the lineage, which dead letters the events that come back to the
+	// Triggers in it, so it is only kept for the brokers that opted into reply loop detection.
+	if !h.replyLoopDetection(broker) {
+		event.SetExtension(eventutil.LineageAttribute, nil)
+	}
+	// The replay time keeps events out of the archive and restarts their max event age. Only the
+	// archive replay sets it, as it publishes straight to the decouple topic.
+	event.SetExtension(eventutil.ReplayTimeExtension, nil)
+	// The retry target and time are only set by the fanout for the retry topic. A producer could
+	// otherwise hold the retries of an event for as long as the retry subscription keeps them.
+	event.SetExtension(eventutil.RetryTargetAttribute, nil)
+	event.SetExtension(eventutil.RetryAtAttribute, nil)
+	if err := eventutil.ScheduleDelivery(event, arrival); err != nil {
+		logging.FromContext(ctx).Debug("Invalid scheduled delivery", zap.Error(err))
+		h.reportMetrics(ctx, event.Type(), nethttp.StatusBadRequest)
+		return nethttp.StatusBadRequest, err.Error(), 0
+	}
 
 	span := trace.FromContext(ctx)
 	span.SetName(broker.SpanMessagingDestination())
@@ -179,7 +294,15 @@ func (h *Handler) ServeHTTP(response nethttp.ResponseWriter, request *nethttp.Re
 	ctx, cancel := context.WithTimeout(ctx, decoupleSinkTimeout)
 	defer cancel()
 	defer func() { h.reportMetrics(ctx, event.Type(), statusCode) }()
-	if res := h.decouple.Send(ctx, broker, *event); !cev2.IsACK(res) {
+
+	release, retryAfter, ok := h.admit(broker, event)
+	if !ok {
+		logging.FromContext(ctx).Debug("Event rejected by the ingress quota", zap.Duration("retryAfter", retryAfter))
+		statusCode = nethttp.StatusTooManyRequests
+		return statusCode, "Broker ingress quota exceeded", retryAfter
+	}
+	// The bytes of an asynchronously published event stay in flight until its publish result.
+	if res := h.decouple.Send(ctx, broker, *event, release); !cev2.IsACK(res) {
 		logging.FromContext(ctx).Error("Error publishing to PubSub", zap.Error(res))
 		statusCode = nethttp.StatusInternalServerError
 
@@ -190,15 +313,31 @@ func (h *Handler) ServeHTTP(response nethttp.ResponseWriter, request *nethttp.Re
 			statusCode = nethttp.StatusServiceUnavailable
 		case errors.Is(res, bundler.ErrOverflow):
 			statusCode = nethttp.StatusTooManyRequests
+			return statusCode, "Failed to publish to PubSub", defaultRetryAfter
 		case grpcstatus.Code(res) == grpccode.PermissionDenied:
-			nethttp.Error(response, deniedErrMsg, statusCode)
-			return
+			return statusCode, deniedErrMsg, 0
 		}
-		nethttp.Error(response, "Failed to publish to PubSub", statusCode)
-		return
+		return statusCode, "Failed to publish to PubSub", 0
 	}
+	h.eventTypes.Observe(broker, event)
+	return statusCode, "", 0
+}
+
+// admit checks the event against the ingress quota of the broker. Brokers that are not found are
+// admitted, the decouple sink rejects their events.
+func (h *Handler) admit(broker *config.CellTenantKey, event *cev2.Event) (func(), time.Duration, bool) {
+	var quota *config.IngressQuota
+	if h.targets != nil {
+		if b, ok := h.targets.GetCellTenantByKey(broker); ok {
+			quota = b.IngressQuota
+		}
+	}
+	return h.admission.admit(broker, quota, int64(len(event.Data())))
+}
 
-	response.WriteHeader(statusCode)
+// retryAfterSeconds rounds the delay up to the whole seconds of the Retry-After header.
+func retryAfterSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
 }
 
 // toEvent converts an http request to an event.
@@ -231,3 +370,13 @@ func (h *Handler) reportMetrics(ctx context.Context, eventType string, statusCod
 		logging.FromContext(ctx).Warn("Failed to record metrics.", zap.Error(err))
 	}
 }
+
+// replyLoopDetection returns whether the broker dead letters the events that come back to the
+// Triggers whose subscribers replied with them.
+func (h *Handler) replyLoopDetection(broker *config.CellTenantKey) bool {
+	if h.targets == nil {
+		return false
+	}
+	brokerConfig, ok := h.targets.GetCellTenantByKey(broker)
+	return ok && brokerConfig.ReplyLoopDetection
+}
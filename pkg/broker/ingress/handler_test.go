@@ -40,6 +40,7 @@ import (
 	"github.com/cloudevents/sdk-go/v2/protocol/http"
 	"github.com/google/knative-gcp/pkg/broker/config"
 	"github.com/google/knative-gcp/pkg/broker/config/memory"
+	"github.com/google/knative-gcp/pkg/broker/eventutil"
 	"github.com/google/knative-gcp/pkg/metrics"
 	reportertest "github.com/google/knative-gcp/pkg/metrics/testing"
 	kgcptesting "github.com/google/knative-gcp/pkg/testing"
@@ -110,6 +111,15 @@ var brokerConfig = &config.TargetsConfig{
 			DecoupleQueue: &config.Queue{Topic: "topic4", State: config.State_UNKNOWN},
 			Targets:       brokerTargets,
 		},
+		"ns5/broker5": {
+			Id:                 "b-uid-5",
+			Type:               config.CellTenantType_BROKER,
+			Name:               "broker5",
+			Namespace:          "ns5",
+			DecoupleQueue:      &config.Queue{Topic: topicID, State: config.State_READY},
+			Targets:            brokerTargets,
+			ReplyLoopDetection: true,
+		},
 	},
 }
 
@@ -137,7 +147,8 @@ type testCase struct {
 
 type fakeOverloadedDecoupleSink struct{}
 
-func (m *fakeOverloadedDecoupleSink) Send(_ context.Context, _ *config.CellTenantKey, _ cev2.Event) protocol.Result {
+func (m *fakeOverloadedDecoupleSink) Send(_ context.Context, _ *config.CellTenantKey, _ cev2.Event, done func()) protocol.Result {
+	done()
 	return bundler.ErrOverflow
 }
 
@@ -312,6 +323,95 @@ func TestHandler(t *testing.T) {
 			},
 			decouple: &fakeOverloadedDecoupleSink{},
 		},
+		{
+			name:           "delivery delay is resolved to the delivery time",
+			path:           "/ns1/broker1",
+			event:          createScheduledTestEvent("test-event", "PT10M"),
+			wantCode:       nethttp.StatusAccepted,
+			wantEventCount: 1,
+			wantMetricTags: map[string]string{
+				metricskey.LabelEventType:         eventType,
+				metricskey.LabelResponseCode:      "202",
+				metricskey.LabelResponseCodeClass: "2xx",
+				metricskey.PodName:                pod,
+				metricskey.ContainerName:          container,
+			},
+			eventAssertions: []eventAssertion{assertExtensionsExist(EventArrivalTime, eventutil.DeliverAtExtension)},
+		},
+		{
+			name:           "lineage is dropped without reply loop detection",
+			path:           "/ns1/broker1",
+			event:          createTestEventWithLineage("test-event"),
+			wantCode:       nethttp.StatusAccepted,
+			wantEventCount: 1,
+			wantMetricTags: map[string]string{
+				metricskey.LabelEventType:         eventType,
+				metricskey.LabelResponseCode:      "202",
+				metricskey.LabelResponseCodeClass: "2xx",
+				metricskey.PodName:                pod,
+				metricskey.ContainerName:          container,
+			},
+			eventAssertions: []eventAssertion{assertExtensionsDontExist(eventutil.LineageAttribute)},
+		},
+		{
+			name:           "lineage is kept with reply loop detection",
+			path:           "/ns5/broker5",
+			event:          createTestEventWithLineage("test-event"),
+			wantCode:       nethttp.StatusAccepted,
+			wantEventCount: 1,
+			wantMetricTags: map[string]string{
+				metricskey.LabelEventType:         eventType,
+				metricskey.LabelResponseCode:      "202",
+				metricskey.LabelResponseCodeClass: "2xx",
+				metricskey.PodName:                pod,
+				metricskey.ContainerName:          container,
+			},
+			eventAssertions: []eventAssertion{assertExtensionsExist(eventutil.LineageAttribute)},
+		},
+		{
+			name:           "replay time is dropped",
+			path:           "/ns1/broker1",
+			event:          createReplayedTestEvent("test-event"),
+			wantCode:       nethttp.StatusAccepted,
+			wantEventCount: 1,
+			wantMetricTags: map[string]string{
+				metricskey.LabelEventType:         eventType,
+				metricskey.LabelResponseCode:      "202",
+				metricskey.LabelResponseCodeClass: "2xx",
+				metricskey.PodName:                pod,
+				metricskey.ContainerName:          container,
+			},
+			eventAssertions: []eventAssertion{assertExtensionsDontExist(eventutil.ReplayTimeExtension)},
+		},
+		{
+			name:           "retry target and time are dropped",
+			path:           "/ns1/broker1",
+			event:          createRetriedTestEvent("test-event"),
+			wantCode:       nethttp.StatusAccepted,
+			wantEventCount: 1,
+			wantMetricTags: map[string]string{
+				metricskey.LabelEventType:         eventType,
+				metricskey.LabelResponseCode:      "202",
+				metricskey.LabelResponseCodeClass: "2xx",
+				metricskey.PodName:                pod,
+				metricskey.ContainerName:          container,
+			},
+			eventAssertions: []eventAssertion{assertExtensionsDontExist(eventutil.RetryTargetAttribute, eventutil.RetryAtAttribute)},
+		},
+		{
+			name:           "invalid delivery delay",
+			path:           "/ns1/broker1",
+			event:          createScheduledTestEvent("test-event", "10 minutes"),
+			wantCode:       nethttp.StatusBadRequest,
+			wantEventCount: 1,
+			wantMetricTags: map[string]string{
+				metricskey.LabelEventType:         eventType,
+				metricskey.LabelResponseCode:      "400",
+				metricskey.LabelResponseCodeClass: "4xx",
+				metricskey.PodName:                pod,
+				metricskey.ContainerName:          container,
+			},
+		},
 	}
 
 	client := nethttp.Client{}
@@ -332,7 +432,7 @@ func TestHandler(t *testing.T) {
 
 			decouple := tc.decouple
 			if decouple == nil {
-				decouple = NewMultiTopicDecoupleSink(ctx, memory.NewTargets(brokerConfig), createPubsubClient(ctx, t, psSrv), pubsub.DefaultPublishSettings)
+				decouple = NewMultiTopicDecoupleSink(ctx, memory.NewTargets(brokerConfig), createPubsubClient(ctx, t, psSrv), pubsub.DefaultPublishSettings, nil)
 			}
 
 			url := createAndStartIngress(ctx, t, psSrv, decouple)
@@ -410,12 +510,12 @@ func runIngressHandlerBenchmark(b *testing.B, eventSize int, targetCounts int) {
 	setBrokerConfigTargets(targetCounts)
 	defer restoreBrokerConfigTargets()
 
-	decouple := NewMultiTopicDecoupleSink(ctx, memory.NewTargets(brokerConfig), psClient, pubsub.DefaultPublishSettings)
+	decouple := NewMultiTopicDecoupleSink(ctx, memory.NewTargets(brokerConfig), psClient, pubsub.DefaultPublishSettings, nil)
 	statsReporter, err := metrics.NewIngressReporter(metrics.PodName(pod), metrics.ContainerName(container))
 	if err != nil {
 		b.Fatal(err)
 	}
-	h := NewHandler(ctx, nil, decouple, statsReporter, "")
+	h := NewHandler(ctx, nil, nil, decouple, memory.NewTargets(brokerConfig), nil, statsReporter, "")
 
 	if _, err := psClient.CreateTopic(ctx, topicID); err != nil {
 		b.Fatal(err)
@@ -523,7 +623,7 @@ func createAndStartIngress(ctx context.Context, t testing.TB, psSrv *pstest.Serv
 	if err != nil {
 		t.Fatal(err)
 	}
-	h := NewHandler(ctx, receiver, decouple, statsReporter, "")
+	h := NewHandler(ctx, receiver, nil, decouple, memory.NewTargets(brokerConfig), nil, statsReporter, "")
 
 	errCh := make(chan error, 1)
 	go func() {
@@ -546,6 +646,31 @@ func createTestEvent(id string) *cloudevents.Event {
 	return &event
 }
 
+func createScheduledTestEvent(id, deliverAfter string) *cloudevents.Event {
+	event := createTestEvent(id)
+	event.SetExtension(eventutil.DeliverAfterExtension, deliverAfter)
+	return event
+}
+
+func createTestEventWithLineage(id string) *cloudevents.Event {
+	event := createTestEvent(id)
+	event.SetExtension(eventutil.LineageAttribute, "0123abcd")
+	return event
+}
+
+func createReplayedTestEvent(id string) *cloudevents.Event {
+	event := createTestEvent(id)
+	event.SetExtension(eventutil.ReplayTimeExtension, "2021-01-01T00:00:00Z")
+	return event
+}
+
+func createRetriedTestEvent(id string) *cloudevents.Event {
+	event := createTestEvent(id)
+	event.SetExtension(eventutil.RetryTargetAttribute, "target-id")
+	event.SetExtension(eventutil.RetryAtAttribute, "2099-01-01T00:00:00Z")
+	return event
+}
+
 func createTestEventWithPayloadSize(id string, payloadSizeBytes int) *cloudevents.Event {
 	testEvent := createTestEvent(id)
 	payload := make([]byte, payloadSizeBytes)
@@ -612,6 +737,54 @@ func assertExtensionsExist(extensions ...string) eventAssertion {
 	}
 }
 
+func assertExtensionsDontExist(extensions ...string) eventAssertion {
+	return func(t *testing.T, e *cloudevents.Event) {
+		for _, extension := range extensions {
+			if _, ok := e.Extensions()[extension]; ok {
+				t.Errorf("Extension %v exists.", extension)
+			}
+		}
+	}
+}
+
+// fakeFlushingDecoupleSink accepts events and records whether it was flushed.
+type fakeFlushingDecoupleSink struct {
+	fakeAcceptingDecoupleSink
+	flushed bool
+}
+
+func (s *fakeFlushingDecoupleSink) Flush() {
+	s.flushed = true
+}
+
+func TestHandlerFlushesDecoupleSinkOnShutdown(t *testing.T) {
+	reportertest.ResetIngressMetrics()
+	ctx, cancel := context.WithCancel(logtest.TestContextWithLogger(t))
+	receiver := &testHttpMessageReceiver{urlCh: make(chan string)}
+	statsReporter, err := metrics.NewIngressReporter(metrics.PodName(pod), metrics.ContainerName(container))
+	if err != nil {
+		t.Fatal(err)
+	}
+	decouple := &fakeFlushingDecoupleSink{}
+	h := NewHandler(ctx, receiver, nil, decouple, memory.NewTargets(brokerConfig), nil, statsReporter, "")
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- h.Start(ctx)
+	}()
+	<-receiver.urlCh
+	if decouple.flushed {
+		t.Fatal("Decouple sink flushed before shutdown")
+	}
+	cancel()
+	if err := <-errCh; err != nil {
+		t.Fatalf("Unexpected error from Start: %v", err)
+	}
+	if !decouple.flushed {
+		t.Error("Decouple sink not flushed on shutdown")
+	}
+}
+
 // testHttpMessageReceiver implements HttpMessageReceiver. When created, it creates an httptest.Server,
 // which starts a server with any available port.
 type testHttpMessageReceiver struct {
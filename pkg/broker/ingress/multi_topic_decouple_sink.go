@@ -19,12 +19,12 @@ package ingress
 import (
 	"context"
 	"fmt"
-	"os"
 	"sync"
 
 	"cloud.google.com/go/pubsub"
 	"go.opencensus.io/trace"
 	"go.uber.org/zap"
+	"knative.dev/pkg/metrics/metricskey"
 
 	cepubsub "github.com/cloudevents/sdk-go/protocol/pubsub/v2"
 	cev2 "github.com/cloudevents/sdk-go/v2"
@@ -33,6 +33,7 @@ import (
 	"github.com/google/knative-gcp/pkg/broker/config"
 	"github.com/google/knative-gcp/pkg/broker/handler/processors/filter"
 	"github.com/google/knative-gcp/pkg/logging"
+	"github.com/google/knative-gcp/pkg/metrics"
 	"github.com/google/knative-gcp/pkg/tracing"
 )
 
@@ -43,16 +44,16 @@ func NewMultiTopicDecoupleSink(
 	ctx context.Context,
 	brokerConfig config.ReadonlyTargets,
 	client *pubsub.Client,
-	publishSettings pubsub.PublishSettings) *multiTopicDecoupleSink {
+	publishSettings pubsub.PublishSettings,
+	reporter *metrics.IngressReporter) *multiTopicDecoupleSink {
 
 	return &multiTopicDecoupleSink{
 		pubsub:          client,
 		publishSettings: publishSettings,
 		brokerConfig:    brokerConfig,
+		reporter:        reporter,
 		// TODO(#1118): remove Topic when broker config is removed
 		topics: make(map[config.CellTenantKey]*pubsub.Topic),
-		// TODO(#1804): remove this field when enabling the feature by default.
-		enableEventFiltering: enableEventFilterFunc(),
 	}
 }
 
@@ -60,7 +61,9 @@ func NewMultiTopicDecoupleSink(
 // to the broker to which the events are sent.
 type multiTopicDecoupleSink struct {
 	// pubsub talks to pubsub.
-	pubsub          *pubsub.Client
+	pubsub *pubsub.Client
+	// publishSettings are the default settings of the topics. Each topic has its own publish
+	// buffer, so that a broker can't exhaust the buffer of other brokers.
 	publishSettings pubsub.PublishSettings
 	// map from brokers to topics
 	topics    map[config.CellTenantKey]*pubsub.Topic
@@ -68,12 +71,23 @@ type multiTopicDecoupleSink struct {
 	// brokerConfig holds configurations for all brokers. It's a view of a configmap populated by
 	// the broker controller.
 	brokerConfig config.ReadonlyTargets
-	// TODO(#1804): remove this field when enabling the feature by default.
-	enableEventFiltering bool
+	// reporter reports the failures of asynchronous publishes. It is optional.
+	reporter *metrics.IngressReporter
+	// pending tracks the asynchronous publishes whose result is not reported yet.
+	pending sync.WaitGroup
 }
 
 // Send sends incoming event to its corresponding pubsub topic based on which broker it belongs to.
-func (m *multiTopicDecoupleSink) Send(ctx context.Context, broker *config.CellTenantKey, event cev2.Event) protocol.Result {
+// If the broker publishes asynchronously, Send returns once the event is buffered and the publish
+// result is only logged and reported, after which done is called.
+func (m *multiTopicDecoupleSink) Send(ctx context.Context, broker *config.CellTenantKey, event cev2.Event, done func()) protocol.Result {
+	async := false
+	defer func() {
+		if !async {
+			done()
+		}
+	}()
+
 	topic, err := m.getTopicForBroker(ctx, broker)
 	if err != nil {
 		trace.FromContext(ctx).Annotate(
@@ -87,8 +101,7 @@ func (m *multiTopicDecoupleSink) Send(ctx context.Context, broker *config.CellTe
 
 	// Check to see if there are any triggers interested in this event. If not, no need to send this
 	// to the decouple topic.
-	// TODO(#1804): remove first check when enabling the feature by default.
-	if m.enableEventFiltering && !m.hasTrigger(ctx, &event) {
+	if m.ingressFilteringEnabled(broker) && !m.hasTrigger(ctx, broker, &event) {
 		logging.FromContext(ctx).Debug("Filtering target-less event at ingress", zap.String("Eventid", event.ID()))
 		return nil
 	}
@@ -99,29 +112,78 @@ func (m *multiTopicDecoupleSink) Send(ctx context.Context, broker *config.CellTe
 		return err
 	}
 
-	_, err = topic.Publish(ctx, msg).Get(ctx)
+	res := topic.Publish(ctx, msg)
+	if m.ingressAsyncPublish(broker) {
+		select {
+		case <-res.Ready():
+			// The event was not buffered, e.g. because the publish buffer is full.
+			_, err = res.Get(ctx)
+			return err
+		default:
+		}
+		// The request context is done once Send returns, so the result is awaited on a context
+		// that only carries the logger and the metrics resource of the request.
+		asyncCtx := logging.WithLogger(context.Background(), logging.FromContext(ctx).With(zap.String("eventID", event.ID())))
+		asyncCtx = metricskey.WithResource(asyncCtx, broker.MetricsResource())
+		m.pending.Add(1)
+		async = true
+		go m.awaitAsyncPublish(asyncCtx, event.Type(), res, done)
+		return nil
+	}
+	_, err = res.Get(ctx)
 	return err
 }
 
+// awaitAsyncPublish waits for the result of an asynchronous publish, reports its failure and then
+// calls done.
+func (m *multiTopicDecoupleSink) awaitAsyncPublish(ctx context.Context, eventType string, res *pubsub.PublishResult, done func()) {
+	defer m.pending.Done()
+	defer done()
+	if _, err := res.Get(ctx); err != nil {
+		logging.FromContext(ctx).Error("Failed to publish an accepted event to PubSub", zap.String("eventType", eventType), zap.Error(err))
+		if m.reporter != nil {
+			if err := m.reporter.ReportAsyncPublishFailure(ctx, eventType); err != nil {
+				logging.FromContext(ctx).Warn("Failed to record metrics.", zap.Error(err))
+			}
+		}
+	}
+}
+
+// Flush publishes the events buffered by all topics and waits until the results of the
+// asynchronous publishes are reported. The topics are stopped, so Flush must only be called once
+// no more events are sent.
+func (m *multiTopicDecoupleSink) Flush() {
+	m.topicsMut.Lock()
+	for broker, topic := range m.topics {
+		topic.Stop()
+		delete(m.topics, broker)
+	}
+	m.topicsMut.Unlock()
+	m.pending.Wait()
+}
+
 // eventFilterFunc is used to see if a target is interested in an event.
 // It is used as a vaiable to allow stubbing out in unit tests.
 var eventFilterFunc = filter.PassFilter
 
-// enableEventFilterFunc is a temporary function to control enabling and
-// disabling trigger-less event filtering in ingress.
-// TODO(#1804): remove this variable when enabling the feature by default.
-var enableEventFilterFunc = isEventFilteringEnabled
+// ingressFilteringEnabled returns whether the broker drops events that match no trigger.
+func (m *multiTopicDecoupleSink) ingressFilteringEnabled(broker *config.CellTenantKey) bool {
+	brokerConfig, ok := m.brokerConfig.GetCellTenantByKey(broker)
+	return ok && brokerConfig.IngressFilteringEnabled
+}
 
-// TODO(#1804): remove this method when enabling the feature by default.
-func isEventFilteringEnabled() bool {
-	return os.Getenv("ENABLE_INGRESS_EVENT_FILTERING") == "true"
+// ingressAsyncPublish returns whether the broker accepts events once they are buffered.
+func (m *multiTopicDecoupleSink) ingressAsyncPublish(broker *config.CellTenantKey) bool {
+	brokerConfig, ok := m.brokerConfig.GetCellTenantByKey(broker)
+	return ok && brokerConfig.IngressAsyncPublish
 }
 
-// hasTrigger checks given event against all targets to see if it will pass any of their filters.
-// If one is fouund, hasTrigger returns true.
-func (m *multiTopicDecoupleSink) hasTrigger(ctx context.Context, event *cev2.Event) bool {
+// hasTrigger checks given event against the targets of the broker to see if it will pass any of
+// their filters. If one is found, hasTrigger returns true. Only the targets the index finds as
+// candidates for the event are checked.
+func (m *multiTopicDecoupleSink) hasTrigger(ctx context.Context, broker *config.CellTenantKey, event *cev2.Event) bool {
 	hasTrigger := false
-	m.brokerConfig.RangeAllTargets(func(target *config.Target) bool {
+	m.brokerConfig.RangeCandidateTargets(broker, filter.Attributes(event), func(target *config.Target) bool {
 		if eventFilterFunc(ctx, target.FilterAttributes, event) {
 			hasTrigger = true
 			return false
@@ -135,14 +197,14 @@ func (m *multiTopicDecoupleSink) hasTrigger(ctx context.Context, event *cev2.Eve
 
 // getTopicForBroker finds the corresponding decouple topic for the broker from the mounted broker configmap volume.
 func (m *multiTopicDecoupleSink) getTopicForBroker(ctx context.Context, broker *config.CellTenantKey) (*pubsub.Topic, error) {
-	topicID, err := m.getTopicIDForBroker(ctx, broker)
+	topicID, settings, err := m.getTopicIDForBroker(ctx, broker)
 	if err != nil {
 		return nil, err
 	}
 
 	if topic, ok := m.getExistingTopic(broker); ok {
-		// Check that the broker's topic ID hasn't changed.
-		if topic.ID() == topicID {
+		// Check that the broker's topic ID and publish settings haven't changed.
+		if topic.ID() == topicID && topic.PublishSettings == settings {
 			return topic, nil
 		}
 	}
@@ -155,13 +217,13 @@ func (m *multiTopicDecoupleSink) updateTopicForBroker(ctx context.Context, broke
 	m.topicsMut.Lock()
 	defer m.topicsMut.Unlock()
 	// Fetch latest decouple topic ID under lock.
-	topicID, err := m.getTopicIDForBroker(ctx, broker)
+	topicID, settings, err := m.getTopicIDForBroker(ctx, broker)
 	if err != nil {
 		return nil, err
 	}
 
 	if topic, ok := m.topics[*broker]; ok {
-		if topic.ID() == topicID {
+		if topic.ID() == topicID && topic.PublishSettings == settings {
 			// Topic already updated.
 			return topic, nil
 		}
@@ -169,29 +231,41 @@ func (m *multiTopicDecoupleSink) updateTopicForBroker(ctx context.Context, broke
 		m.topics[*broker].Stop()
 	}
 	topic := m.pubsub.Topic(topicID)
-	topic.PublishSettings = m.publishSettings
+	topic.PublishSettings = settings
 	m.topics[*broker] = topic
 	return topic, nil
 }
 
-func (m *multiTopicDecoupleSink) getTopicIDForBroker(ctx context.Context, broker *config.CellTenantKey) (string, error) {
+// getTopicIDForBroker returns the decouple topic ID of the broker and the publish settings of the
+// topic.
+func (m *multiTopicDecoupleSink) getTopicIDForBroker(ctx context.Context, broker *config.CellTenantKey) (string, pubsub.PublishSettings, error) {
 	brokerConfig, ok := m.brokerConfig.GetCellTenantByKey(broker)
 	if !ok {
 		// There is an propagation delay between the controller reconciles the broker config and
 		// the config being pushed to the configmap volume in the ingress pod. So sometimes we return
 		// an error even if the request is valid.
 		logging.FromContext(ctx).Warn("config is not found for")
-		return "", fmt.Errorf("%q: %w", broker, ErrNotFound)
+		return "", pubsub.PublishSettings{}, fmt.Errorf("%q: %w", broker, ErrNotFound)
 	}
 	if brokerConfig.DecoupleQueue == nil || brokerConfig.DecoupleQueue.Topic == "" {
 		logging.FromContext(ctx).Error("DecoupleQueue or topic missing for broker, this should NOT happen.", zap.Any("brokerConfig", brokerConfig))
-		return "", fmt.Errorf("decouple queue of %q: %w", broker, ErrIncomplete)
+		return "", pubsub.PublishSettings{}, fmt.Errorf("decouple queue of %q: %w", broker, ErrIncomplete)
 	}
 	if brokerConfig.DecoupleQueue.State != config.State_READY {
 		logging.FromContext(ctx).Debug("decouple queue is not ready")
-		return "", fmt.Errorf("%q: %w", broker, ErrNotReady)
+		return "", pubsub.PublishSettings{}, fmt.Errorf("%q: %w", broker, ErrNotReady)
+	}
+	return brokerConfig.DecoupleQueue.Topic, m.publishSettingsForBroker(brokerConfig), nil
+}
+
+// publishSettingsForBroker returns the default publish settings, with the publish buffer bounded
+// by the broker's bytes in flight quota, if any.
+func (m *multiTopicDecoupleSink) publishSettingsForBroker(brokerConfig *config.CellTenant) pubsub.PublishSettings {
+	settings := m.publishSettings
+	if q := brokerConfig.IngressQuota; q != nil && q.BytesInFlight > 0 && (settings.BufferedByteLimit <= 0 || q.BytesInFlight < int64(settings.BufferedByteLimit)) {
+		settings.BufferedByteLimit = int(q.BytesInFlight)
 	}
-	return brokerConfig.DecoupleQueue.Topic, nil
+	return settings
 }
 
 func (m *multiTopicDecoupleSink) getExistingTopic(broker *config.CellTenantKey) (*pubsub.Topic, bool) {
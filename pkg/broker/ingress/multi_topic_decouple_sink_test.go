@@ -18,12 +18,15 @@ package ingress
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"testing"
+	"time"
 
 	"cloud.google.com/go/pubsub"
 	"cloud.google.com/go/pubsub/pstest"
 	"github.com/google/uuid"
+	"google.golang.org/api/support/bundler"
 
 	cepubsub "github.com/cloudevents/sdk-go/protocol/pubsub/v2"
 	"github.com/cloudevents/sdk-go/v2/binding"
@@ -31,17 +34,14 @@ import (
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/knative-gcp/pkg/broker/config"
 	"github.com/google/knative-gcp/pkg/broker/config/memory"
+	"github.com/google/knative-gcp/pkg/metrics"
+	reportertest "github.com/google/knative-gcp/pkg/metrics/testing"
 	logtest "knative.dev/pkg/logging/testing"
+	"knative.dev/pkg/metrics/metricskey"
+	"knative.dev/pkg/metrics/metricstest"
 )
 
 func TestMultiTopicDecoupleSink(t *testing.T) {
-	// TODO(#1804): remove this mock when enabling the feature by default.
-	origEnableEventFilterFunc := enableEventFilterFunc
-	defer func() { enableEventFilterFunc = origEnableEventFilterFunc }()
-	enableEventFilterFunc = func() bool {
-		return true
-	}
-
 	// If the broker has no targets, it will drop events at ingress without sending them
 	// to pub/sub. So we add a target with no filter to the broker to ensure events are not
 	// dropped due to ingress filtering.
@@ -190,6 +190,9 @@ func TestMultiTopicDecoupleSink(t *testing.T) {
 			defer psSrv.Close()
 			psClient := createPubsubClient(ctx, t, psSrv)
 
+			for _, ct := range tt.brokerConfig.CellTenants {
+				ct.IngressFilteringEnabled = true
+			}
 			brokerConfig := memory.NewTargets(tt.brokerConfig)
 			for i, testCase := range tt.cases {
 				topic := psClient.Topic(testCase.topic)
@@ -206,10 +209,10 @@ func TestMultiTopicDecoupleSink(t *testing.T) {
 					t.Fatal(err)
 				}
 
-				sink := NewMultiTopicDecoupleSink(ctx, brokerConfig, psClient, pubsub.DefaultPublishSettings)
+				sink := NewMultiTopicDecoupleSink(ctx, brokerConfig, psClient, pubsub.DefaultPublishSettings, nil)
 				// Send events
 				event := createTestEvent(uuid.New().String())
-				err = sink.Send(context.Background(), testCase.broker, *event)
+				err = sink.Send(context.Background(), testCase.broker, *event, func() {})
 
 				// Verify results.
 				if testCase.wantErr && err == nil {
@@ -243,9 +246,8 @@ func TestMultiTopicDecoupleSink(t *testing.T) {
 	}
 }
 
-// Temoporary test to ensure functionality doesn't change when the filtering feature is disabled.
-// This is an exact copy of 'TestMultiTopicDecoupleSink'.
-// TODO(#1804): remove this test when enabling the feature by default.
+// Ensures functionality doesn't change when ingress filtering is disabled for the broker.
+// This is a copy of 'TestMultiTopicDecoupleSink'.
 func TestMultiTopicDecoupleSinkWithoutIngressFiltering(t *testing.T) {
 	// If the broker has no targets, it will drop events at ingress without sending them
 	// to pub/sub. So we add a target with no filter to the broker to ensure events are not
@@ -417,10 +419,10 @@ func TestMultiTopicDecoupleSinkWithoutIngressFiltering(t *testing.T) {
 					t.Fatal(err)
 				}
 
-				sink := NewMultiTopicDecoupleSink(ctx, brokerConfig, psClient, pubsub.DefaultPublishSettings)
+				sink := NewMultiTopicDecoupleSink(ctx, brokerConfig, psClient, pubsub.DefaultPublishSettings, nil)
 				// Send events
 				event := createTestEvent(uuid.New().String())
-				err = sink.Send(context.Background(), testCase.broker, *event)
+				err = sink.Send(context.Background(), testCase.broker, *event, func() {})
 
 				// Verify results.
 				if testCase.wantErr && err == nil {
@@ -571,15 +573,25 @@ func TestHasTrigger(t *testing.T) {
 						DecoupleQueue: DecoupleQueue,
 						Targets:       test.brokerTargets,
 					},
+					// The targets of other brokers must not be considered.
+					"test_ns_2/test_broker_2": {
+						Type:          config.CellTenantType_BROKER,
+						DecoupleQueue: DecoupleQueue,
+						Targets: map[string]*config.Target{
+							"other_broker_target": {
+								CellTenantType: config.CellTenantType_BROKER,
+							},
+						},
+					},
 				},
 			}
 
 			brokerConfig := memory.NewTargets(testBrokerConfig)
-			sink := NewMultiTopicDecoupleSink(ctx, brokerConfig, psClient, pubsub.DefaultPublishSettings)
+			sink := NewMultiTopicDecoupleSink(ctx, brokerConfig, psClient, pubsub.DefaultPublishSettings, nil)
 
 			event := createTestEvent(uuid.New().String())
 
-			hasTrigger := sink.hasTrigger(ctx, event)
+			hasTrigger := sink.hasTrigger(ctx, config.TestOnlyBrokerKey("test_ns_1", "test_broker_1"), event)
 			if hasTrigger != test.hasTrigger {
 				t.Errorf("Sink says event has trigger %t which should be %t", hasTrigger, test.hasTrigger)
 			}
@@ -588,13 +600,6 @@ func TestHasTrigger(t *testing.T) {
 }
 
 func TestMultiTopicDecoupleSinkSendChecksFilter(t *testing.T) {
-	// TODO(#1804): remove this mock when enabling the feature by default.
-	origEnableEventFilterFunc := enableEventFilterFunc
-	defer func() { enableEventFilterFunc = origEnableEventFilterFunc }()
-	enableEventFilterFunc = func() bool {
-		return true
-	}
-
 	filterCalled := false
 	origEventFilterFunc := eventFilterFunc
 	defer func() { eventFilterFunc = origEventFilterFunc }()
@@ -616,6 +621,7 @@ func TestMultiTopicDecoupleSinkSendChecksFilter(t *testing.T) {
 				Targets: map[string]*config.Target{"target_1": {
 					CellTenantType: config.CellTenantType_BROKER,
 				}},
+				IngressFilteringEnabled: true,
 			},
 		},
 	}
@@ -632,12 +638,12 @@ func TestMultiTopicDecoupleSinkSendChecksFilter(t *testing.T) {
 		}
 	}
 
-	sink := NewMultiTopicDecoupleSink(ctx, brokerConfig, psClient, pubsub.DefaultPublishSettings)
+	sink := NewMultiTopicDecoupleSink(ctx, brokerConfig, psClient, pubsub.DefaultPublishSettings, nil)
 	// Send event.
 	event := createTestEvent(uuid.New().String())
 
 	namespace := config.TestOnlyBrokerKey("test_ns_1", "test_broker_1")
-	err := sink.Send(context.Background(), namespace, *event)
+	err := sink.Send(context.Background(), namespace, *event, func() {})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -648,9 +654,8 @@ func TestMultiTopicDecoupleSinkSendChecksFilter(t *testing.T) {
 	}
 }
 
-// Temoporary test to ensure the filtering feature is disabled by default.
-// TODO(#1804): remove this test when enabling the feature by default.
-func TestMultiTopicDecoupleSinkSendDoesNotChecksFilterWhenFeatureDisabled(t *testing.T) {
+// Ensures ingress filtering is disabled unless the broker enables it.
+func TestMultiTopicDecoupleSinkSendDoesNotCheckFilterWhenDisabled(t *testing.T) {
 	filterCalled := false
 	origEventFilterFunc := eventFilterFunc
 	defer func() { eventFilterFunc = origEventFilterFunc }()
@@ -688,19 +693,19 @@ func TestMultiTopicDecoupleSinkSendDoesNotChecksFilterWhenFeatureDisabled(t *tes
 		}
 	}
 
-	sink := NewMultiTopicDecoupleSink(ctx, brokerConfig, psClient, pubsub.DefaultPublishSettings)
+	sink := NewMultiTopicDecoupleSink(ctx, brokerConfig, psClient, pubsub.DefaultPublishSettings, nil)
 	// Send event.
 	event := createTestEvent(uuid.New().String())
 
 	namespace := config.TestOnlyBrokerKey("test_ns_1", "test_broker_1")
-	err := sink.Send(context.Background(), namespace, *event)
+	err := sink.Send(context.Background(), namespace, *event, func() {})
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	// Verify results.
 	if filterCalled {
-		t.Errorf("Send called EventFilterFunc when feature is disabled")
+		t.Errorf("Send called EventFilterFunc when ingress filtering is disabled")
 	}
 }
 
@@ -739,11 +744,11 @@ func TestMultiTopicDecoupleSinkUsesPublishSettings(t *testing.T) {
 	publishSettings := pubsub.DefaultPublishSettings
 	// This is a purposely smaller than the event's data to cause an error.
 	publishSettings.BufferedByteLimit = len(ce.Data()) - 1
-	sink := NewMultiTopicDecoupleSink(ctx, brokerConfig, psClient, publishSettings)
+	sink := NewMultiTopicDecoupleSink(ctx, brokerConfig, psClient, publishSettings, nil)
 	// Send event.
 
 	namespace := config.TestOnlyBrokerKey("test_ns_1", "test_broker_1")
-	err := sink.Send(context.Background(), namespace, *ce)
+	err := sink.Send(context.Background(), namespace, *ce, func() {})
 	if err == nil {
 		t.Fatal("Expected an error due to the BufferedByteLimit being smaller than the event, actually none.")
 	}
@@ -751,3 +756,146 @@ func TestMultiTopicDecoupleSinkUsesPublishSettings(t *testing.T) {
 		t.Fatalf("Unexpected error, expected %q, actually %q", want, got)
 	}
 }
+
+func TestMultiTopicDecoupleSinkBoundsPublishBufferByQuota(t *testing.T) {
+	ctx := logtest.TestContextWithLogger(t)
+	psSrv := pstest.NewServer()
+	defer psSrv.Close()
+	psClient := createPubsubClient(ctx, t, psSrv)
+
+	testTopic := "test_topic_1"
+	if _, err := psClient.CreateTopic(ctx, testTopic); err != nil {
+		t.Fatal(err)
+	}
+
+	ce := createTestEvent(uuid.New().String())
+	ce.SetData(event.ApplicationJSON, `{"hello": "world"}`)
+	brokerConfig := memory.NewTargets(&config.TargetsConfig{
+		CellTenants: map[string]*config.CellTenant{
+			"test_ns_1/test_broker_1": {
+				Type:          config.CellTenantType_BROKER,
+				DecoupleQueue: &config.Queue{Topic: testTopic, State: config.State_READY},
+				Targets: map[string]*config.Target{"target_1": {
+					CellTenantType: config.CellTenantType_BROKER,
+				}},
+				// This is a purposely smaller than the event's data to cause an error.
+				IngressQuota: &config.IngressQuota{BytesInFlight: int64(len(ce.Data()) - 1)},
+			},
+		},
+	})
+	sink := NewMultiTopicDecoupleSink(ctx, brokerConfig, psClient, pubsub.DefaultPublishSettings, nil)
+
+	err := sink.Send(context.Background(), config.TestOnlyBrokerKey("test_ns_1", "test_broker_1"), *ce, func() {})
+	if err == nil {
+		t.Fatal("Expected an error due to the bytes in flight quota being smaller than the event, actually none.")
+	}
+	if want, got := "bundler reached buffered byte limit", err.Error(); want != got {
+		t.Fatalf("Unexpected error, expected %q, actually %q", want, got)
+	}
+}
+
+func TestMultiTopicDecoupleSinkAsyncPublish(t *testing.T) {
+	ctx := logtest.TestContextWithLogger(t)
+	psSrv := pstest.NewServer()
+	defer psSrv.Close()
+	psClient := createPubsubClient(ctx, t, psSrv)
+
+	testTopic := "test_topic_1"
+	if _, err := psClient.CreateTopic(ctx, testTopic); err != nil {
+		t.Fatal(err)
+	}
+	brokerConfig := memory.NewTargets(&config.TargetsConfig{
+		CellTenants: map[string]*config.CellTenant{
+			"test_ns_1/test_broker_1": {
+				Type:                config.CellTenantType_BROKER,
+				DecoupleQueue:       &config.Queue{Topic: testTopic, State: config.State_READY},
+				IngressAsyncPublish: true,
+			},
+		},
+	})
+	publishSettings := pubsub.DefaultPublishSettings
+	// Buffer the event until the sink is flushed.
+	publishSettings.DelayThreshold = time.Hour
+	publishSettings.CountThreshold = 100
+	sink := NewMultiTopicDecoupleSink(ctx, brokerConfig, psClient, publishSettings, nil)
+
+	ce := createTestEvent(uuid.New().String())
+	done := make(chan struct{})
+	if err := sink.Send(context.Background(), config.TestOnlyBrokerKey("test_ns_1", "test_broker_1"), *ce, func() { close(done) }); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := len(psSrv.Messages()); got != 0 {
+		t.Fatalf("Got %d published messages before flushing, want 0", got)
+	}
+	select {
+	case <-done:
+		t.Fatal("The publish was done before flushing")
+	default:
+	}
+
+	sink.Flush()
+	select {
+	case <-done:
+	default:
+		t.Fatal("The publish was not done after flushing")
+	}
+	msgs := psSrv.Messages()
+	if len(msgs) != 1 {
+		t.Fatalf("Got %d published messages after flushing, want 1", len(msgs))
+	}
+	got, err := binding.ToEvent(ctx, cepubsub.NewMessage(&pubsub.Message{Data: msgs[0].Data, Attributes: msgs[0].Attributes}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(ce, got); diff != "" {
+		t.Errorf("Output event doesn't match input, diff: %v", diff)
+	}
+}
+
+func TestMultiTopicDecoupleSinkAsyncPublishFailure(t *testing.T) {
+	reportertest.ResetIngressMetrics()
+	ctx := logtest.TestContextWithLogger(t)
+	psSrv := pstest.NewServer()
+	defer psSrv.Close()
+	psClient := createPubsubClient(ctx, t, psSrv)
+
+	ce := createTestEvent(uuid.New().String())
+	ce.SetData(event.ApplicationJSON, `{"hello": "world"}`)
+	brokerConfig := memory.NewTargets(&config.TargetsConfig{
+		CellTenants: map[string]*config.CellTenant{
+			// The topic of the broker doesn't exist, so publishing fails after the event is accepted.
+			"test_ns_1/test_broker_1": {
+				Type:                config.CellTenantType_BROKER,
+				DecoupleQueue:       &config.Queue{Topic: "missing_topic", State: config.State_READY},
+				IngressAsyncPublish: true,
+			},
+			// The publish buffer can't hold the event, so it is rejected.
+			"test_ns_1/test_broker_2": {
+				Type:                config.CellTenantType_BROKER,
+				DecoupleQueue:       &config.Queue{Topic: "missing_topic", State: config.State_READY},
+				IngressAsyncPublish: true,
+				IngressQuota:        &config.IngressQuota{BytesInFlight: int64(len(ce.Data()) - 1)},
+			},
+		},
+	})
+	reporter, err := metrics.NewIngressReporter(metrics.PodName(pod), metrics.ContainerName(container))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink := NewMultiTopicDecoupleSink(ctx, brokerConfig, psClient, pubsub.DefaultPublishSettings, reporter)
+
+	if err := sink.Send(context.Background(), config.TestOnlyBrokerKey("test_ns_1", "test_broker_1"), *ce, func() {}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	err = sink.Send(context.Background(), config.TestOnlyBrokerKey("test_ns_1", "test_broker_2"), *ce, func() {})
+	if !errors.Is(err, bundler.ErrOverflow) {
+		t.Fatalf("Unexpected error, expected %v, actually %v", bundler.ErrOverflow, err)
+	}
+
+	sink.Flush()
+	metricstest.CheckCountData(t, "async_publish_failure_count", map[string]string{
+		metricskey.LabelEventType: eventType,
+		metricskey.PodName:        pod,
+		metricskey.ContainerName:  container,
+	}, 1)
+}
@@ -0,0 +1,209 @@
+/*
+Copyright 2021 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+
+	cev2 "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/types"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	// Names of the optional CloudEvents attributes, as used in the protobuf format's attribute map.
+	dataContentTypeAttribute = "datacontenttype"
+	dataSchemaAttribute      = "dataschema"
+	subjectAttribute         = "subject"
+	timeAttribute            = "time"
+
+	// protobufContentType is the data content type of events carrying proto_data.
+	protobufContentType = "application/protobuf"
+)
+
+// EventFromProto converts an event in the CloudEvents protobuf format to an event.
+func EventFromProto(pe *CloudEvent) (*cev2.Event, error) {
+	if pe == nil {
+		return nil, errors.New("event is missing")
+	}
+	specVersion := pe.GetSpecVersion()
+	if specVersion == "" {
+		specVersion = cev2.VersionV1
+	}
+	event := cev2.NewEvent(specVersion)
+	event.SetID(pe.GetId())
+	event.SetSource(pe.GetSource())
+	event.SetType(pe.GetType())
+
+	for name, attr := range pe.GetAttributes() {
+		value, err := attributeValueFromProto(attr)
+		if err != nil {
+			return nil, fmt.Errorf("attribute %q: %w", name, err)
+		}
+		if err := setAttribute(&event, name, value); err != nil {
+			return nil, fmt.Errorf("attribute %q: %w", name, err)
+		}
+	}
+
+	switch data := pe.GetData().(type) {
+	case *CloudEvent_BinaryData:
+		event.DataEncoded = data.BinaryData
+	case *CloudEvent_TextData:
+		event.DataEncoded = []byte(data.TextData)
+	case *CloudEvent_ProtoData:
+		b, err := proto.Marshal(data.ProtoData)
+		if err != nil {
+			return nil, fmt.Errorf("proto_data: %w", err)
+		}
+		event.DataEncoded = b
+		if event.DataContentType() == "" {
+			event.SetDataContentType(protobufContentType)
+		}
+	}
+
+	if err := event.Validate(); err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+// EventToProto converts an event to the CloudEvents protobuf format. Event data is always carried
+// as binary_data.
+func EventToProto(event *cev2.Event) (*CloudEvent, error) {
+	pe := &CloudEvent{
+		Id:          event.ID(),
+		Source:      event.Source(),
+		SpecVersion: event.SpecVersion(),
+		Type:        event.Type(),
+		Attributes:  make(map[string]*CloudEventAttributeValue),
+	}
+	if v := event.DataContentType(); v != "" {
+		pe.Attributes[dataContentTypeAttribute] = &CloudEventAttributeValue{Attr: &CloudEventAttributeValue_CeString{CeString: v}}
+	}
+	if v := event.DataSchema(); v != "" {
+		pe.Attributes[dataSchemaAttribute] = &CloudEventAttributeValue{Attr: &CloudEventAttributeValue_CeUri{CeUri: v}}
+	}
+	if v := event.Subject(); v != "" {
+		pe.Attributes[subjectAttribute] = &CloudEventAttributeValue{Attr: &CloudEventAttributeValue_CeString{CeString: v}}
+	}
+	if v := event.Time(); !v.IsZero() {
+		pe.Attributes[timeAttribute] = &CloudEventAttributeValue{Attr: &CloudEventAttributeValue_CeTimestamp{CeTimestamp: timestamppb.New(v)}}
+	}
+	for name, value := range event.Extensions() {
+		attr, err := attributeValueToProto(value)
+		if err != nil {
+			return nil, fmt.Errorf("extension %q: %w", name, err)
+		}
+		pe.Attributes[name] = attr
+	}
+	if data := event.Data(); data != nil {
+		pe.Data = &CloudEvent_BinaryData{BinaryData: data}
+	}
+	return pe, nil
+}
+
+// setAttribute sets an optional or extension attribute on the event.
+func setAttribute(event *cev2.Event, name string, value interface{}) error {
+	switch name {
+	case dataContentTypeAttribute, dataSchemaAttribute, subjectAttribute:
+		s, err := types.Format(value)
+		if err != nil {
+			return err
+		}
+		switch name {
+		case dataContentTypeAttribute:
+			event.SetDataContentType(s)
+		case dataSchemaAttribute:
+			event.SetDataSchema(s)
+		case subjectAttribute:
+			event.SetSubject(s)
+		}
+		return nil
+	case timeAttribute:
+		t, err := types.ToTime(value)
+		if err != nil {
+			return err
+		}
+		event.SetTime(t)
+		return nil
+	default:
+		return event.Context.SetExtension(name, value)
+	}
+}
+
+func attributeValueFromProto(attr *CloudEventAttributeValue) (interface{}, error) {
+	switch v := attr.GetAttr().(type) {
+	case *CloudEventAttributeValue_CeBoolean:
+		return v.CeBoolean, nil
+	case *CloudEventAttributeValue_CeInteger:
+		return v.CeInteger, nil
+	case *CloudEventAttributeValue_CeString:
+		return v.CeString, nil
+	case *CloudEventAttributeValue_CeBytes:
+		return v.CeBytes, nil
+	case *CloudEventAttributeValue_CeUri:
+		u, err := url.Parse(v.CeUri)
+		if err != nil {
+			return nil, err
+		}
+		if !u.IsAbs() {
+			return nil, fmt.Errorf("URI %q is not absolute", v.CeUri)
+		}
+		return types.URI{URL: *u}, nil
+	case *CloudEventAttributeValue_CeUriRef:
+		u, err := url.Parse(v.CeUriRef)
+		if err != nil {
+			return nil, err
+		}
+		return types.URIRef{URL: *u}, nil
+	case *CloudEventAttributeValue_CeTimestamp:
+		if err := v.CeTimestamp.CheckValid(); err != nil {
+			return nil, err
+		}
+		return types.Timestamp{Time: v.CeTimestamp.AsTime()}, nil
+	default:
+		return nil, errors.New("attribute value is missing")
+	}
+}
+
+func attributeValueToProto(value interface{}) (*CloudEventAttributeValue, error) {
+	value, err := types.Validate(value)
+	if err != nil {
+		return nil, err
+	}
+	switch v := value.(type) {
+	case bool:
+		return &CloudEventAttributeValue{Attr: &CloudEventAttributeValue_CeBoolean{CeBoolean: v}}, nil
+	case int32:
+		return &CloudEventAttributeValue{Attr: &CloudEventAttributeValue_CeInteger{CeInteger: v}}, nil
+	case string:
+		return &CloudEventAttributeValue{Attr: &CloudEventAttributeValue_CeString{CeString: v}}, nil
+	case []byte:
+		return &CloudEventAttributeValue{Attr: &CloudEventAttributeValue_CeBytes{CeBytes: v}}, nil
+	case types.URI:
+		return &CloudEventAttributeValue{Attr: &CloudEventAttributeValue_CeUri{CeUri: v.String()}}, nil
+	case types.URIRef:
+		return &CloudEventAttributeValue{Attr: &CloudEventAttributeValue_CeUriRef{CeUriRef: v.String()}}, nil
+	case types.Timestamp:
+		return &CloudEventAttributeValue{Attr: &CloudEventAttributeValue_CeTimestamp{CeTimestamp: timestamppb.New(v.Time)}}, nil
+	default:
+		return nil, fmt.Errorf("unsupported attribute type %T", value)
+	}
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2021 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"testing"
+	"time"
+
+	cev2 "github.com/cloudevents/sdk-go/v2"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestEventProtoRoundTrip(t *testing.T) {
+	event := cev2.NewEvent()
+	event.SetID("id")
+	event.SetSource("source")
+	event.SetType("type")
+	event.SetSubject("subject")
+	event.SetDataSchema("https://example.com/schema")
+	event.SetTime(time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC))
+	event.SetExtension("boolext", true)
+	event.SetExtension("intext", 42)
+	event.SetExtension("stringext", "value")
+	if err := event.SetData(cev2.ApplicationJSON, map[string]string{"hello": "world"}); err != nil {
+		t.Fatal(err)
+	}
+
+	pe, err := EventToProto(&event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := EventFromProto(pe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(event.String(), got.String()); diff != "" {
+		t.Errorf("Unexpected event after round trip (-want, +got) = %v", diff)
+	}
+}
+
+func TestEventFromProto(t *testing.T) {
+	anyData, err := anypb.New(timestamppb.New(time.Unix(0, 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name                string
+		event               *CloudEvent
+		wantErr             bool
+		wantData            string
+		wantDataContentType string
+		wantDataSchema      string
+	}{
+		{
+			name:    "nil event",
+			wantErr: true,
+		},
+		{
+			name:    "missing id",
+			event:   &CloudEvent{Source: "source", Type: "type"},
+			wantErr: true,
+		},
+		{
+			name:     "text data",
+			event:    &CloudEvent{Id: "id", Source: "source", Type: "type", Data: &CloudEvent_TextData{TextData: "hello"}},
+			wantData: "hello",
+		},
+		{
+			name:                "proto data",
+			event:               &CloudEvent{Id: "id", Source: "source", Type: "type", Data: &CloudEvent_ProtoData{ProtoData: anyData}},
+			wantDataContentType: protobufContentType,
+		},
+		{
+			name: "relative URI",
+			event: &CloudEvent{Id: "id", Source: "source", Type: "type", Attributes: map[string]*CloudEventAttributeValue{
+				"dataschema": {Attr: &CloudEventAttributeValue_CeUri{CeUri: "relative"}},
+			}},
+			wantErr: true,
+		},
+		{
+			name: "missing attribute value",
+			event: &CloudEvent{Id: "id", Source: "source", Type: "type", Attributes: map[string]*CloudEventAttributeValue{
+				"ext": {},
+			}},
+			wantErr: true,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := EventFromProto(tc.event)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("EventFromProto error mismatch, got: %v, wantErr: %v", err, tc.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if tc.wantData != "" && string(got.Data()) != tc.wantData {
+				t.Errorf("Data mismatch, got: %q, want: %q", got.Data(), tc.wantData)
+			}
+			if got.DataContentType() != tc.wantDataContentType {
+				t.Errorf("DataContentType mismatch, got: %q, want: %q", got.DataContentType(), tc.wantDataContentType)
+			}
+			if got.DataSchema() != tc.wantDataSchema {
+				t.Errorf("DataSchema mismatch, got: %q, want: %q", got.DataSchema(), tc.wantDataSchema)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2021 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	nethttp "net/http"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+	"knative.dev/eventing/pkg/kncloudevents"
+
+	"github.com/google/knative-gcp/pkg/utils/authcheck"
+	"github.com/google/knative-gcp/pkg/utils/clients"
+)
+
+// TLSConfig configures the HTTPS listener of the ingress, which serves the external ingress of
+// the BrokerCell. The listener is disabled if Port is 0.
+type TLSConfig struct {
+	Port     int
+	CertFile string
+	KeyFile  string
+}
+
+// NewHTTPReceiver creates the HTTP receiver of the ingress. If the TLSConfig has a port, the
+// receiver also serves HTTPS on that port.
+func NewHTTPReceiver(port clients.Port, authType authcheck.AuthType, tlsConfig TLSConfig) HttpMessageReceiver {
+	receiver := clients.NewHTTPMessageReceiverWithChecker(port, authType)
+	if tlsConfig.Port == 0 {
+		return receiver
+	}
+	return &tlsReceiver{
+		HttpMessageReceiver: receiver,
+		config:              tlsConfig,
+		certs:               &certificateLoader{certFile: tlsConfig.CertFile, keyFile: tlsConfig.KeyFile},
+	}
+}
+
+// tlsReceiver serves the same handler over HTTP and HTTPS.
+type tlsReceiver struct {
+	HttpMessageReceiver
+	config TLSConfig
+	certs  *certificateLoader
+}
+
+// StartListen serves the handler over HTTP and HTTPS until the context is done.
+func (r *tlsReceiver) StartListen(ctx context.Context, handler nethttp.Handler) error {
+	g, ctx := errgroup.WithContext(ctx)
+	g.Go(func() error {
+		return r.HttpMessageReceiver.StartListen(ctx, handler)
+	})
+	g.Go(func() error {
+		return r.startListenTLS(ctx, handler)
+	})
+	return g.Wait()
+}
+
+func (r *tlsReceiver) startListenTLS(ctx context.Context, handler nethttp.Handler) error {
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", r.config.Port))
+	if err != nil {
+		return err
+	}
+	server := &nethttp.Server{
+		Handler: kncloudevents.CreateHandler(handler),
+		TLSConfig: &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: r.certs.getCertificate,
+		},
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		// The certificate is provided by the TLS config.
+		errCh <- server.ServeTLS(lis, "", "")
+	}()
+	select {
+	case <-ctx.Done():
+		server.SetKeepAlivesEnabled(false)
+		ctx, cancel := context.WithTimeout(context.Background(), kncloudevents.DefaultShutdownTimeout)
+		defer cancel()
+		err := server.Shutdown(ctx)
+		<-errCh
+		return err
+	case err := <-errCh:
+		return err
+	}
+}
+
+// certificateLoader loads the certificate from its files, and reloads it when the files are
+// updated, e.g. when the Secret holding the certificate is rotated.
+type certificateLoader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+func (l *certificateLoader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	info, err := os.Stat(l.certFile)
+	if err != nil {
+		return nil, err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.cert != nil && info.ModTime().Equal(l.modTime) {
+		return l.cert, nil
+	}
+	cert, err := tls.LoadX509KeyPair(l.certFile, l.keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the TLS certificate: %w", err)
+	}
+	l.cert, l.modTime = &cert, info.ModTime()
+	return l.cert, nil
+}
@@ -0,0 +1,159 @@
+/*
+Copyright 2021 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	nethttp "net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	kgcptesting "github.com/google/knative-gcp/pkg/testing"
+	"github.com/google/knative-gcp/pkg/utils/clients"
+)
+
+func TestHTTPReceiverWithoutTLS(t *testing.T) {
+	if _, ok := NewHTTPReceiver(clients.Port(kgcptesting.GetFreePort(t)), "", TLSConfig{}).(*tlsReceiver); ok {
+		t.Error("Expected a plain HTTP receiver when the TLS port is not set")
+	}
+}
+
+func TestHTTPReceiverWithTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ingress-tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	roots := x509.NewCertPool()
+	roots.AddCert(writeCertificate(t, certFile, keyFile, 1))
+
+	tlsPort := kgcptesting.GetFreePort(t)
+	receiver, ok := NewHTTPReceiver(clients.Port(kgcptesting.GetFreePort(t)), "", TLSConfig{
+		Port:     tlsPort,
+		CertFile: certFile,
+		KeyFile:  keyFile,
+	}).(*tlsReceiver)
+	if !ok {
+		t.Fatal("Expected an HTTPS receiver when the TLS port is set")
+	}
+	// The plain HTTP receiver drains for a while before it stops.
+	receiver.HttpMessageReceiver = &testHttpMessageReceiver{urlCh: make(chan string, 1)}
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- receiver.StartListen(ctx, nethttp.HandlerFunc(func(w nethttp.ResponseWriter, _ *nethttp.Request) {
+			w.WriteHeader(nethttp.StatusAccepted)
+		}))
+	}()
+
+	client := &nethttp.Client{
+		Transport: &nethttp.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}},
+	}
+	url := fmt.Sprintf("https://localhost:%d/ns/broker", tlsPort)
+	var resp *nethttp.Response
+	for i := 0; i < 50; i++ {
+		if resp, err = client.Post(url, "application/json", nil); err == nil {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatal("Failed to send the request over HTTPS:", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != nethttp.StatusAccepted {
+		t.Errorf("Unexpected status code, want %d, got %d", nethttp.StatusAccepted, resp.StatusCode)
+	}
+	if got := resp.TLS.PeerCertificates[0].SerialNumber.Int64(); got != 1 {
+		t.Errorf("Unexpected certificate serial number, want 1, got %d", got)
+	}
+
+	// The rotated certificate is served by the new connections.
+	roots.AddCert(writeCertificate(t, certFile, keyFile, 2))
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(certFile, later, later); err != nil {
+		t.Fatal(err)
+	}
+	client.CloseIdleConnections()
+	resp, err = client.Post(url, "application/json", nil)
+	if err != nil {
+		t.Fatal("Failed to send the request over HTTPS:", err)
+	}
+	resp.Body.Close()
+	if got := resp.TLS.PeerCertificates[0].SerialNumber.Int64(); got != 2 {
+		t.Errorf("Unexpected certificate serial number, want 2, got %d", got)
+	}
+
+	cancel()
+	if err := <-errCh; err != nil {
+		t.Error("Unexpected error from the receiver:", err)
+	}
+}
+
+// writeCertificate writes a self-signed certificate for localhost to the given files.
+func writeCertificate(t *testing.T, certFile, keyFile string, serial int64) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: "localhost"},
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
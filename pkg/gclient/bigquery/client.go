@@ -0,0 +1,102 @@
+/*
+Copyright 2021 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bigquery
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	bigquery "google.golang.org/api/bigquery/v2"
+	"google.golang.org/api/option"
+)
+
+// CreateFn is a factory function to create a BigQuery client.
+type CreateFn func(ctx context.Context, opts ...option.ClientOption) (Client, error)
+
+// NewClient creates a new wrapped BigQuery client.
+func NewClient(ctx context.Context, opts ...option.ClientOption) (Client, error) {
+	service, err := bigquery.NewService(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &bigqueryClient{
+		service: service,
+	}, nil
+}
+
+// bigqueryClient wraps bigquery.Service. Is the client that will be used everywhere except unit tests.
+type bigqueryClient struct {
+	service *bigquery.Service
+}
+
+// Verify that it satisfies the Client interface.
+var _ Client = &bigqueryClient{}
+
+// InsertRows implements bigquery.TabledataService.InsertAll
+func (c *bigqueryClient) InsertRows(ctx context.Context, table Table, rows []Row, opts InsertOptions) ([]error, error) {
+	req := &bigquery.TableDataInsertAllRequest{
+		// Insert the valid rows even if some are not, their errors are returned per row.
+		SkipInvalidRows:     true,
+		IgnoreUnknownValues: opts.IgnoreUnknownValues,
+		Rows:                make([]*bigquery.TableDataInsertAllRequestRows, len(rows)),
+	}
+	for i, row := range rows {
+		values := make(map[string]bigquery.JsonValue, len(row.Values))
+		for k, v := range row.Values {
+			values[k] = v
+		}
+		req.Rows[i] = &bigquery.TableDataInsertAllRequestRows{
+			InsertId: row.InsertID,
+			Json:     values,
+		}
+	}
+	resp, err := c.service.Tabledata.InsertAll(table.Project, table.Dataset, table.Table, req).Context(ctx).Do()
+	if err != nil {
+		return nil, err
+	}
+	rowErrs := make([]error, len(rows))
+	for _, insertErr := range resp.InsertErrors {
+		if insertErr.Index < 0 || int(insertErr.Index) >= len(rows) {
+			continue
+		}
+		rowErrs[insertErr.Index] = rowError(insertErr.Errors)
+	}
+	return rowErrs, nil
+}
+
+// RowError is the error of a row that was not inserted.
+type RowError struct {
+	// Reason is the short error code, e.g. "invalid".
+	Reason  string
+	Message string
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("row not inserted: %s: %s", e.Reason, e.Message)
+}
+
+func rowError(errs []*bigquery.ErrorProto) error {
+	if len(errs) == 0 {
+		return &RowError{Reason: "unknown", Message: "no error details"}
+	}
+	messages := make([]string, 0, len(errs))
+	for _, e := range errs {
+		messages = append(messages, e.Message)
+	}
+	return &RowError{Reason: errs[0].Reason, Message: strings.Join(messages, "; ")}
+}
@@ -0,0 +1,52 @@
+/*
+Copyright 2021 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bigquery
+
+import (
+	"context"
+)
+
+// Table identifies a BigQuery table.
+type Table struct {
+	Project string
+	Dataset string
+	Table   string
+}
+
+// Row is a row streamed into a BigQuery table.
+type Row struct {
+	// InsertID is used by BigQuery to deduplicate the rows inserted more than once.
+	InsertID string
+	// Values are the values of the row by column name.
+	Values map[string]interface{}
+}
+
+// InsertOptions configures the insertion of rows into a BigQuery table.
+type InsertOptions struct {
+	// IgnoreUnknownValues ignores the values that don't match a column of the table. Otherwise
+	// the rows with such values are not inserted.
+	IgnoreUnknownValues bool
+}
+
+// Client streams rows into BigQuery tables.
+// see https://cloud.google.com/bigquery/docs/reference/rest/v2/tabledata/insertAll
+type Client interface {
+	// InsertRows streams the rows into the table. The rows that are invalid are skipped, their
+	// errors are returned indexed like the rows. The returned error is set if the request failed,
+	// in which case no row was inserted.
+	InsertRows(ctx context.Context, table Table, rows []Row, opts InsertOptions) ([]error, error)
+}
@@ -0,0 +1,131 @@
+/*
+Copyright 2021 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package testing
+
+import (
+	"context"
+	"sync"
+
+	"google.golang.org/api/option"
+
+	"github.com/google/knative-gcp/pkg/gclient/bigquery"
+)
+
+// TestClientCreator returns a bigquery.CreateFn used to construct the test BigQuery client.
+func TestClientCreator(value interface{}) bigquery.CreateFn {
+	var data TestClientData
+	var ok bool
+	if data, ok = value.(TestClientData); !ok {
+		data = TestClientData{}
+	}
+	if data.CreateClientErr != nil {
+		return func(_ context.Context, _ ...option.ClientOption) (bigquery.Client, error) {
+			return nil, data.CreateClientErr
+		}
+	}
+
+	return func(_ context.Context, _ ...option.ClientOption) (bigquery.Client, error) {
+		return NewTestClient(data), nil
+	}
+}
+
+// TestClientData is the data used to configure the test BigQuery client.
+type TestClientData struct {
+	CreateClientErr error
+	// InsertErr fails the insert requests as a whole.
+	InsertErr error
+	// RowErrs fails the insertion of the rows with the given insert IDs.
+	RowErrs map[string]error
+	// Columns are the columns of the tables. If set, the rows with values of other columns are
+	// not inserted, unless the unknown values are ignored.
+	Columns []string
+}
+
+// TestClient is the test BigQuery client. It records the rows inserted in each table.
+type TestClient struct {
+	data TestClientData
+
+	mu       sync.Mutex
+	rows     map[bigquery.Table][]bigquery.Row
+	requests int
+}
+
+// Verify that it satisfies the bigquery.Client interface.
+var _ bigquery.Client = &TestClient{}
+
+// NewTestClient creates a test BigQuery client configured with data.
+func NewTestClient(data TestClientData) *TestClient {
+	return &TestClient{
+		data: data,
+		rows: make(map[bigquery.Table][]bigquery.Row),
+	}
+}
+
+// InsertRows implements client.InsertRows
+func (c *TestClient) InsertRows(ctx context.Context, table bigquery.Table, rows []bigquery.Row, opts bigquery.InsertOptions) ([]error, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.requests++
+	if c.data.InsertErr != nil {
+		return nil, c.data.InsertErr
+	}
+	rowErrs := make([]error, len(rows))
+	for i, row := range rows {
+		if err := c.data.RowErrs[row.InsertID]; err != nil {
+			rowErrs[i] = err
+			continue
+		}
+		if c.data.Columns != nil {
+			row = c.knownValues(row, opts)
+			if row.Values == nil {
+				rowErrs[i] = &bigquery.RowError{Reason: "invalid", Message: "no such field"}
+				continue
+			}
+		}
+		c.rows[table] = append(c.rows[table], row)
+	}
+	return rowErrs, nil
+}
+
+// knownValues returns the row with the values of the known columns only, or with nil values if it
+// has values of unknown columns that are not ignored.
+func (c *TestClient) knownValues(row bigquery.Row, opts bigquery.InsertOptions) bigquery.Row {
+	known := make(map[string]interface{}, len(row.Values))
+	for _, column := range c.data.Columns {
+		if v, ok := row.Values[column]; ok {
+			known[column] = v
+		}
+	}
+	if len(known) < len(row.Values) && !opts.IgnoreUnknownValues {
+		return bigquery.Row{InsertID: row.InsertID}
+	}
+	return bigquery.Row{InsertID: row.InsertID, Values: known}
+}
+
+// Rows returns the rows inserted in the table.
+func (c *TestClient) Rows(table bigquery.Table) []bigquery.Row {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]bigquery.Row(nil), c.rows[table]...)
+}
+
+// Requests returns the number of insert requests received.
+func (c *TestClient) Requests() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.requests
+}
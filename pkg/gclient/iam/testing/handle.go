@@ -21,6 +21,8 @@ import (
 
 	"cloud.google.com/go/iam"
 	giam "github.com/google/knative-gcp/pkg/gclient/iam"
+	iampb "google.golang.org/genproto/googleapis/iam/v1"
+	"google.golang.org/protobuf/proto"
 )
 
 type TestHandleData struct {
@@ -38,11 +40,16 @@ func (h *testHandle) Policy(ctx context.Context) (*iam.Policy, error) {
 	if h.Config.PolicyErr != nil {
 		return nil, h.Config.PolicyErr
 	}
-	return &h.policy, nil
+	// Like the real handle, return a copy that is only stored by SetPolicy.
+	policy := &iam.Policy{}
+	if h.policy.InternalProto != nil {
+		policy.InternalProto = proto.Clone(h.policy.InternalProto).(*iampb.Policy)
+	}
+	return policy, nil
 }
 
 func (h *testHandle) SetPolicy(ctx context.Context, policy *iam.Policy) error {
-	if h.Config.SetPolicyErr != nil {
+	if h.Config.SetPolicyErr == nil {
 		h.policy = *policy
 	}
 	return h.Config.SetPolicyErr
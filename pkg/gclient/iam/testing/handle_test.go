@@ -0,0 +1,70 @@
+/*
+Copyright 2021 Google LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package testing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"cloud.google.com/go/iam"
+)
+
+const (
+	testMember = "serviceAccount:test@test-project.iam.gserviceaccount.com"
+	testRole   = iam.RoleName("roles/pubsub.publisher")
+)
+
+func TestSetPolicy(t *testing.T) {
+	testCases := []struct {
+		name         string
+		setPolicyErr error
+		wantRole     bool
+	}{
+		{
+			name:     "set policy succeeds",
+			wantRole: true,
+		},
+		{
+			name:         "set policy fails",
+			setPolicyErr: errors.New("permission denied"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			h := NewTestHandle(TestHandleData{SetPolicyErr: tc.setPolicyErr})
+			policy, err := h.Policy(ctx)
+			if err != nil {
+				t.Fatalf("unexpected policy error: %v", err)
+			}
+			// Changing the policy alone doesn't change the policy of the handle.
+			policy.Add(testMember, testRole)
+			if err := h.SetPolicy(ctx, policy); err != tc.setPolicyErr {
+				t.Errorf("expected set policy error %v, got %v", tc.setPolicyErr, err)
+			}
+
+			got, err := h.Policy(ctx)
+			if err != nil {
+				t.Fatalf("unexpected policy error: %v", err)
+			}
+			if hasRole := got.HasRole(testMember, testRole); hasRole != tc.wantRole {
+				t.Errorf("expected the member to have the role %t, got %t", tc.wantRole, hasRole)
+			}
+		})
+	}
+}
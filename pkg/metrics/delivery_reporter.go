@@ -45,6 +45,11 @@ type DeliveryReporter struct {
 	containerName         ContainerName
 	dispatchTimeInMsecM   *stats.Float64Measure
 	processingTimeInMsecM *stats.Float64Measure
+	expiredCountM         *stats.Int64Measure
+	concurrencyLimitM     *stats.Int64Measure
+	drainTimeInMsecM      *stats.Float64Measure
+	drainedCountM         *stats.Int64Measure
+	abortedCountM         *stats.Int64Measure
 }
 
 func (r *DeliveryReporter) register() error {
@@ -86,6 +91,57 @@ func (r *DeliveryReporter) register() error {
 				ContainerNameKey,
 			},
 		},
+		&view.View{
+			Name:        r.expiredCountM.Name(),
+			Description: r.expiredCountM.Description(),
+			Measure:     r.expiredCountM,
+			Aggregation: view.Count(),
+			TagKeys: []tag.Key{
+				TriggerFilterTypeKey,
+				PodNameKey,
+				ContainerNameKey,
+			},
+		},
+		&view.View{
+			Name:        r.concurrencyLimitM.Name(),
+			Description: r.concurrencyLimitM.Description(),
+			Measure:     r.concurrencyLimitM,
+			Aggregation: view.LastValue(),
+			TagKeys: []tag.Key{
+				PodNameKey,
+				ContainerNameKey,
+			},
+		},
+		&view.View{
+			Name:        r.drainTimeInMsecM.Name(),
+			Description: r.drainTimeInMsecM.Description(),
+			Measure:     r.drainTimeInMsecM,
+			Aggregation: view.Distribution(metrics.Buckets125(1, 100000)...), // 1, 2, 5, 10, 20, 50, 100, 1000, 5000, 10000, 100000
+			TagKeys: []tag.Key{
+				PodNameKey,
+				ContainerNameKey,
+			},
+		},
+		&view.View{
+			Name:        r.drainedCountM.Name(),
+			Description: r.drainedCountM.Description(),
+			Measure:     r.drainedCountM,
+			Aggregation: view.Sum(),
+			TagKeys: []tag.Key{
+				PodNameKey,
+				ContainerNameKey,
+			},
+		},
+		&view.View{
+			Name:        r.abortedCountM.Name(),
+			Description: r.abortedCountM.Description(),
+			Measure:     r.abortedCountM,
+			Aggregation: view.Sum(),
+			TagKeys: []tag.Key{
+				PodNameKey,
+				ContainerNameKey,
+			},
+		},
 	)
 }
 
@@ -108,6 +164,41 @@ func NewDeliveryReporter(podName PodName, containerName ContainerName) (*Deliver
 			"The time spent processing an event before it is dispatched to a Trigger subscriber",
 			stats.UnitMilliseconds,
 		),
+		// expiredCountM counts the events that were not delivered to a Trigger subscriber
+		// because they exceeded the max event age.
+		expiredCountM: stats.Int64(
+			"expired_event_count",
+			"Number of events not delivered to a Trigger subscriber because they expired",
+			stats.UnitDimensionless,
+		),
+		// concurrencyLimitM records the current adaptive limit of concurrent deliveries to a
+		// Trigger subscriber.
+		concurrencyLimitM: stats.Int64(
+			"concurrency_limit",
+			"The current limit of concurrent deliveries to a Trigger subscriber",
+			stats.UnitDimensionless,
+		),
+		// drainTimeInMsecM records the time spent draining a handler, from when it
+		// stops pulling until its in-flight events are done or aborted.
+		drainTimeInMsecM: stats.Float64(
+			"drain_latencies",
+			"The time spent draining the in-flight events of a handler",
+			stats.UnitMilliseconds,
+		),
+		// drainedCountM counts the in-flight events that finished processing while
+		// their handler was draining.
+		drainedCountM: stats.Int64(
+			"drained_event_count",
+			"Number of in-flight events that finished processing while their handler was draining",
+			stats.UnitDimensionless,
+		),
+		// abortedCountM counts the in-flight events that were aborted because their
+		// handler exceeded the drain timeout. They are redelivered.
+		abortedCountM: stats.Int64(
+			"aborted_event_count",
+			"Number of in-flight events aborted because their handler exceeded the drain timeout",
+			stats.UnitDimensionless,
+		),
 	}
 
 	if err := r.register(); err != nil {
@@ -123,6 +214,24 @@ func (r *DeliveryReporter) ReportEventDispatchTime(ctx context.Context, d time.D
 	metrics.Record(ctx, r.dispatchTimeInMsecM.M(float64(d/time.Millisecond)), stats.WithAttachments(attachments))
 }
 
+// ReportEventExpired counts an event that expired before it was delivered.
+func (r *DeliveryReporter) ReportEventExpired(ctx context.Context) {
+	metrics.Record(ctx, r.expiredCountM.M(1))
+}
+
+// ReportConcurrencyLimit records the current concurrency limit of the Trigger subscriber.
+func (r *DeliveryReporter) ReportConcurrencyLimit(ctx context.Context, limit int) {
+	metrics.Record(ctx, r.concurrencyLimitM.M(int64(limit)))
+}
+
+// ReportDrain records the time spent draining a handler, and the number of its in-flight
+// events that finished processing or were aborted.
+func (r *DeliveryReporter) ReportDrain(ctx context.Context, d time.Duration, drained, aborted int) {
+	metrics.Record(ctx, r.drainTimeInMsecM.M(float64(d/time.Millisecond)))
+	metrics.Record(ctx, r.drainedCountM.M(int64(drained)))
+	metrics.Record(ctx, r.abortedCountM.M(int64(aborted)))
+}
+
 // StartEventProcessing records the start of event processing for delivery within the given context.
 func StartEventProcessing(ctx context.Context) context.Context {
 	return context.WithValue(ctx, startDeliveryProcessingTime, time.Now())
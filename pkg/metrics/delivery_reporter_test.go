@@ -130,6 +130,40 @@ func TestReportEventProcessingTime(t *testing.T) {
 	metricstest.CheckDistributionData(t, "event_dispatch_latencies", wantTags, 2, 1100.0, 9100.0)
 }
 
+func TestReportEventExpired(t *testing.T) {
+	reportertest.ResetDeliveryMetrics()
+
+	wantTags := map[string]string{
+		metricskey.LabelFilterType: "testeventtype",
+		metricskey.PodName:         "testpod",
+		metricskey.ContainerName:   "testcontainer",
+	}
+
+	r, err := NewDeliveryReporter("testpod", "testcontainer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, err := r.AddTags(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, err = AddTargetTags(ctx, &config.Target{
+		Namespace:      "testns",
+		CellTenantType: config.CellTenantType_BROKER,
+		CellTenantName: "testbroker",
+		Name:           "testtrigger",
+		FilterAttributes: map[string]string{
+			"type": "testeventtype",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.ReportEventExpired(ctx)
+	r.ReportEventExpired(ctx)
+	metricstest.CheckCountData(t, "expired_event_count", wantTags, 2)
+}
+
 func TestMetricsWithEmptySourceAndTypeFilter(t *testing.T) {
 	reportertest.ResetDeliveryMetrics()
 
@@ -165,3 +199,26 @@ func TestMetricsWithEmptySourceAndTypeFilter(t *testing.T) {
 	})
 	metricstest.CheckCountData(t, "event_count", wantTags, 1)
 }
+
+func TestReportDrain(t *testing.T) {
+	reportertest.ResetDeliveryMetrics()
+
+	wantTags := map[string]string{
+		metricskey.PodName:       "testpod",
+		metricskey.ContainerName: "testcontainer",
+	}
+
+	r, err := NewDeliveryReporter("testpod", "testcontainer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, err := r.AddTags(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.ReportDrain(ctx, 200*time.Millisecond, 3, 1)
+	r.ReportDrain(ctx, 100*time.Millisecond, 2, 0)
+	metricstest.CheckDistributionData(t, "drain_latencies", wantTags, 2, 100.0, 200.0)
+	metricstest.CheckSumData(t, "drained_event_count", wantTags, 5)
+	metricstest.CheckSumData(t, "aborted_event_count", wantTags, 1)
+}
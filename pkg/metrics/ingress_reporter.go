@@ -55,6 +55,13 @@ func (r *IngressReporter) register() error {
 			Aggregation: view.Count(),
 			TagKeys:     tagKeys,
 		},
+		&view.View{
+			Name:        r.asyncPublishFailureCountM.Name(),
+			Description: r.asyncPublishFailureCountM.Description(),
+			Measure:     r.asyncPublishFailureCountM,
+			Aggregation: view.Count(),
+			TagKeys:     []tag.Key{EventTypeKey, PodNameKey, ContainerNameKey},
+		},
 	)
 }
 
@@ -68,6 +75,11 @@ func NewIngressReporter(podName PodName, containerName ContainerName) (*IngressR
 			"Number of events received by a Broker",
 			stats.UnitDimensionless,
 		),
+		asyncPublishFailureCountM: stats.Int64(
+			"async_publish_failure_count",
+			"Number of events accepted by a Broker that failed to be published asynchronously",
+			stats.UnitDimensionless,
+		),
 	}
 	if err := r.register(); err != nil {
 		return nil, fmt.Errorf("failed to register ingress stats: %w", err)
@@ -77,9 +89,10 @@ func NewIngressReporter(podName PodName, containerName ContainerName) (*IngressR
 
 // IngressReporter reports ingress metrics.
 type IngressReporter struct {
-	podName       PodName
-	containerName ContainerName
-	eventCountM   *stats.Int64Measure
+	podName                   PodName
+	containerName             ContainerName
+	eventCountM               *stats.Int64Measure
+	asyncPublishFailureCountM *stats.Int64Measure
 }
 
 func (r *IngressReporter) ReportEventCount(ctx context.Context, args IngressReportArgs) error {
@@ -98,3 +111,17 @@ func (r *IngressReporter) ReportEventCount(ctx context.Context, args IngressRepo
 	)
 	return nil
 }
+
+// ReportAsyncPublishFailure reports an event that was accepted before being published, and then
+// failed to be published.
+func (r *IngressReporter) ReportAsyncPublishFailure(ctx context.Context, eventType string) error {
+	metrics.Record(
+		ctx, r.asyncPublishFailureCountM.M(1),
+		stats.WithTags(
+			tag.Insert(PodNameKey, string(r.podName)),
+			tag.Insert(ContainerNameKey, string(r.containerName)),
+			tag.Insert(EventTypeKey, EventTypeMetricValue(eventType)),
+		),
+	)
+	return nil
+}
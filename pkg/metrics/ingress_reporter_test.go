@@ -86,3 +86,23 @@ func TestStatsReporterWithCustomEventType(t *testing.T) {
 	})
 	metricstest.CheckCountData(t, "event_count", wantTags, 2)
 }
+
+func TestStatsReporterAsyncPublishFailure(t *testing.T) {
+	reportertest.ResetIngressMetrics()
+
+	wantTags := map[string]string{
+		metricskey.LabelEventType: "google.cloud.scheduler.job.v1.executed",
+		metricskey.ContainerName:  "testcontainer",
+		metricskey.PodName:        "testpod",
+	}
+
+	r, err := NewIngressReporter(PodName("testpod"), ContainerName("testcontainer"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reportertest.ExpectMetrics(t, func() error {
+		return r.ReportAsyncPublishFailure(context.Background(), "google.cloud.scheduler.job.v1.executed")
+	})
+	metricstest.CheckCountData(t, "async_publish_failure_count", wantTags, 1)
+}
@@ -136,7 +136,7 @@ func (e ExpectDelivery) verifyDelivery(viewName string) error {
 	got := make(map[deliveryKey]int64)
 	for _, p := range metricproducer.GlobalManager().GetAll() {
 		for _, m := range p.Read() {
-			if m.Resource.Type != metricskey.ResourceTypeKnativeTrigger {
+			if m.Resource == nil || m.Resource.Type != metricskey.ResourceTypeKnativeTrigger {
 				continue
 			}
 			if m.Descriptor.Name != viewName {
@@ -184,7 +184,7 @@ func (e ExpectDelivery) verifyProcessing() error {
 	got := make(map[Trigger]int64)
 	for _, p := range metricproducer.GlobalManager().GetAll() {
 		for _, m := range p.Read() {
-			if m.Resource.Type != metricskey.ResourceTypeKnativeTrigger {
+			if m.Resource == nil || m.Resource.Type != metricskey.ResourceTypeKnativeTrigger {
 				continue
 			}
 			if m.Descriptor.Name != "event_processing_latencies" {
@@ -220,7 +220,7 @@ func (e ExpectDelivery) verifyTimeout() error {
 	got := make(map[Trigger]int64)
 	for _, p := range metricproducer.GlobalManager().GetAll() {
 		for _, m := range p.Read() {
-			if m.Resource.Type != metricskey.ResourceTypeKnativeTrigger {
+			if m.Resource == nil || m.Resource.Type != metricskey.ResourceTypeKnativeTrigger {
 				continue
 			}
 			if m.Descriptor.Name != "event_dispatch_latencies" {
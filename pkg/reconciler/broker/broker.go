@@ -26,20 +26,40 @@ import (
 	"github.com/google/knative-gcp/pkg/reconciler/celltenant"
 	"go.uber.org/zap"
 	corev1 "k8s.io/api/core/v1"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	eventingclientset "knative.dev/eventing/pkg/client/clientset/versioned"
+	eventinglisters "knative.dev/eventing/pkg/client/listers/eventing/v1beta1"
 	pkgreconciler "knative.dev/pkg/reconciler"
 
 	brokerv1 "github.com/google/knative-gcp/pkg/apis/broker/v1"
 	brokerreconciler "github.com/google/knative-gcp/pkg/client/injection/reconciler/broker/v1/broker"
+	giam "github.com/google/knative-gcp/pkg/gclient/iam"
+	"github.com/google/knative-gcp/pkg/reconciler/broker/resources"
 )
 
 const (
 	// Name of the corev1.Events emitted from the Broker reconciliation process.
-	brokerReconciled = "BrokerReconciled"
-	brokerFinalized  = "BrokerFinalized"
+	brokerReconciled        = "BrokerReconciled"
+	brokerFinalized         = "BrokerFinalized"
+	archiveBucketNotAllowed = "ArchiveBucketNotAllowed"
 )
 
 type Reconciler struct {
 	celltenant.Reconciler
+
+	// eventTypeLister, configMapLister and eventingClientSet are used to discover EventTypes from
+	// the event types reported by the ingress.
+	eventTypeLister   eventinglisters.EventTypeLister
+	configMapLister   corev1listers.ConfigMapLister
+	eventingClientSet eventingclientset.Interface
+
+	// namespaceLister is used to check the buckets that the namespaces allow the Brokers to
+	// archive their events to.
+	namespaceLister corev1listers.NamespaceLister
+
+	// topicIAM returns the IAM handle of the decouple topics of the Brokers. If nil, the handle of
+	// the topic of the Pub/Sub client is used. Changed in testing only.
+	topicIAM func(project, topic string) giam.Handle
 }
 
 // Check that Reconciler implements Interface
@@ -60,9 +80,78 @@ func (r *Reconciler) ReconcileKind(ctx context.Context, b *brokerv1.Broker) pkgr
 		// whatever info is available. or put this in a defer?
 	}
 
+	if err := r.reconcileRetryTopic(ctx, b, bcs); err != nil {
+		logging.FromContext(ctx).Error("Problem reconciling retry topic", zap.Error(err))
+		return fmt.Errorf("failed to reconcile retry topic: %w", err)
+	}
+
+	if err := r.Reconciler.ReconcileDelayTopicAndSubscription(ctx, bcs, resources.GenerateDelayTopicName(b), resources.GenerateDelaySubscriptionName(b)); err != nil {
+		logging.FromContext(ctx).Error("Problem reconciling delay topic", zap.Error(err))
+		return fmt.Errorf("failed to reconcile delay topic: %w", err)
+	}
+
+	if err := r.reconcileArchiveSubscription(ctx, b, bcs); err != nil {
+		logging.FromContext(ctx).Error("Problem reconciling archive subscription", zap.Error(err))
+		return fmt.Errorf("failed to reconcile archive subscription: %w", err)
+	}
+
+	if err := r.reconcileFederation(ctx, b, bcs); err != nil {
+		logging.FromContext(ctx).Error("Problem reconciling federation subscriptions", zap.Error(err))
+		return fmt.Errorf("failed to reconcile federation subscriptions: %w", err)
+	}
+
+	if err := r.reconcileFederationMembers(ctx, b); err != nil {
+		logging.FromContext(ctx).Error("Problem reconciling federation members", zap.Error(err))
+		return fmt.Errorf("failed to reconcile federation members: %w", err)
+	}
+
+	if err := r.reconcileEventTypes(ctx, b); err != nil {
+		logging.FromContext(ctx).Error("Problem reconciling event types", zap.Error(err))
+		return fmt.Errorf("failed to reconcile event types: %w", err)
+	}
+
 	return pkgreconciler.NewEvent(corev1.EventTypeNormal, brokerReconciled, "Broker reconciled: \"%s/%s\"", b.Namespace, b.Name)
 }
 
+// reconcileRetryTopic creates the retry topic shared by the Broker's Triggers if the Broker
+// consolidates their retries. The topic is deleted once the Broker is explicitly switched back to a
+// retry topic per Trigger; the Triggers keep draining their subscriptions to it.
+func (r *Reconciler) reconcileRetryTopic(ctx context.Context, b *brokerv1.Broker, bcs celltenant.Statusable) error {
+	topicID := resources.GenerateConsolidatedRetryTopicName(b)
+	mode, _ := b.RetryTopicMode()
+	switch mode {
+	case brokerv1.RetryTopicModeConsolidated:
+		return r.Reconciler.ReconcileRetryTopic(ctx, bcs, topicID)
+	case brokerv1.RetryTopicModePerTrigger:
+		return r.Reconciler.DeleteRetryTopic(ctx, bcs, topicID)
+	}
+	return nil
+}
+
+// reconcileArchiveSubscription creates the subscription from which the events of the Broker are
+// archived if the Broker is archived to a bucket that its namespace allows, and deletes it
+// otherwise. The archive is recorded in the status of the Broker for the data plane.
+func (r *Reconciler) reconcileArchiveSubscription(ctx context.Context, b *brokerv1.Broker, bcs celltenant.Statusable) error {
+	subID := resources.GenerateArchiveSubscriptionName(b)
+	bucket, prefix, _ := b.Archive()
+	if bucket != "" {
+		ns, err := r.namespaceLister.Get(b.Namespace)
+		if err != nil {
+			logging.FromContext(ctx).Error("Failed to get the namespace of the Broker", zap.String("namespace", b.Namespace), zap.Error(err))
+			return err
+		}
+		if !brokerv1.ArchiveBucketAllowed(ns.Annotations, bucket) {
+			r.Recorder.Eventf(b, corev1.EventTypeWarning, archiveBucketNotAllowed, "Bucket %q is not listed in the %s annotation of namespace %q, the events are not archived", bucket, brokerv1.ArchiveBucketsAnnotationKey, b.Namespace)
+			bucket = ""
+		}
+	}
+	b.Status.SetArchive(bucket, prefix)
+	if bucket != "" {
+		return r.Reconciler.ReconcileArchiveSubscription(ctx, bcs, subID)
+	}
+	return r.Reconciler.DeleteSubscription(ctx, bcs, subID)
+}
+
 func (r *Reconciler) FinalizeKind(ctx context.Context, b *brokerv1.Broker) pkgreconciler.Event {
 	logger := logging.FromContext(ctx)
 	logger.Debug("Finalizing Broker", zap.Any("broker", b))
@@ -70,6 +159,18 @@ func (r *Reconciler) FinalizeKind(ctx context.Context, b *brokerv1.Broker) pkgre
 	if err := r.Reconciler.FinalizeGCPCellTenant(ctx, bcs); err != nil {
 		return err
 	}
+	if err := r.Reconciler.DeleteRetryTopic(ctx, bcs, resources.GenerateConsolidatedRetryTopicName(b)); err != nil {
+		return err
+	}
+	if err := r.Reconciler.DeleteDelayTopicAndSubscription(ctx, bcs, resources.GenerateDelayTopicName(b), resources.GenerateDelaySubscriptionName(b)); err != nil {
+		return err
+	}
+	if err := r.Reconciler.DeleteSubscription(ctx, bcs, resources.GenerateArchiveSubscriptionName(b)); err != nil {
+		return err
+	}
+	if err := r.deleteFederationSubscriptions(ctx, b, bcs); err != nil {
+		return err
+	}
 
 	return pkgreconciler.NewEvent(corev1.EventTypeNormal, brokerFinalized, "Broker finalized: \"%s/%s\"", b.Namespace, b.Name)
 }
@@ -18,21 +18,27 @@ package broker
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/google/knative-gcp/pkg/reconciler/celltenant"
 
 	"cloud.google.com/go/pubsub"
 	"cloud.google.com/go/pubsub/pstest"
+	"github.com/google/go-cmp/cmp"
 	"github.com/google/knative-gcp/pkg/broker/ingress"
 
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/scheme"
 	clientgotesting "k8s.io/client-go/testing"
 
 	eventingduckv1 "knative.dev/eventing/pkg/apis/duck/v1"
+	eventingv1beta1 "knative.dev/eventing/pkg/apis/eventing/v1beta1"
 	"knative.dev/pkg/apis"
 	duckv1 "knative.dev/pkg/apis/duck/v1"
 	"knative.dev/pkg/client/injection/ducks/duck/v1/addressable"
@@ -43,12 +49,19 @@ import (
 
 	brokerv1 "github.com/google/knative-gcp/pkg/apis/broker/v1"
 	"github.com/google/knative-gcp/pkg/apis/configs/dataresidency"
+	"github.com/google/knative-gcp/pkg/apis/configs/encryption"
+	"github.com/google/knative-gcp/pkg/apis/duck"
+	"github.com/google/knative-gcp/pkg/broker/eventtype"
 	"github.com/google/knative-gcp/pkg/client/injection/ducks/duck/v1alpha1/resource"
+	fakeeventingclient "github.com/google/knative-gcp/pkg/client/injection/eventing/client/fake"
 	brokerreconciler "github.com/google/knative-gcp/pkg/client/injection/reconciler/broker/v1/broker"
+	giam "github.com/google/knative-gcp/pkg/gclient/iam"
+	iamtesting "github.com/google/knative-gcp/pkg/gclient/iam/testing"
 	"github.com/google/knative-gcp/pkg/reconciler"
 	"github.com/google/knative-gcp/pkg/reconciler/broker/resources"
 	brokercellresources "github.com/google/knative-gcp/pkg/reconciler/brokercell/resources"
 	. "github.com/google/knative-gcp/pkg/reconciler/testing"
+	"github.com/google/knative-gcp/pkg/utils/authcheck"
 )
 
 const (
@@ -59,8 +72,22 @@ const (
 	testUID     = "abc123"
 	systemNS    = "knative-testing"
 
+	// federatedTopic is the decouple topic of another Broker in the namespace, which the tests
+	// federate.
+	federatedTopic = "cre-bkr_testnamespace_other-broker_def456"
+	// remoteControlPlane is the control plane of another cluster, which the tests allow to
+	// federate the events of the Broker, and staleControlPlane one that is no longer allowed.
+	remoteControlPlane = "serviceAccount:controller@remote-project.iam.gserviceaccount.com"
+	staleControlPlane  = "serviceAccount:controller@stale-project.iam.gserviceaccount.com"
+	// controlPlaneGSA is the Google service account of the control plane of the cluster.
+	controlPlaneGSA = "controller@test-project-id.iam.gserviceaccount.com"
+
 	brokerFinalizerName = "brokers.eventing.knative.dev"
 	testClusterRegion   = "us-east1"
+	testClusterName     = "test-cluster"
+
+	testClusterKMSKeyName = "projects/test-project-id/locations/us-east1/keyRings/ring/cryptoKeys/cluster-key"
+	testBrokerKMSKeyName  = "projects/test-project-id/locations/us-east1/keyRings/ring/cryptoKeys/broker-key"
 )
 
 var (
@@ -76,6 +103,16 @@ var (
 	brokerFinalizedEvent        = Eventf(corev1.EventTypeNormal, "BrokerFinalized", `Broker finalized: "testnamespace/test-broker"`)
 	ingressServiceName          = brokercellresources.Name(resources.DefaultBrokerCellName, brokercellresources.IngressName)
 
+	// brokerSubscriptionLabels are the labels of the subscriptions of the Broker.
+	brokerSubscriptionLabels = map[string]string{
+		"resource":     "brokers",
+		"broker_class": brokerv1.BrokerClass,
+		"namespace":    testNS,
+		"name":         brokerName,
+		"brokercell":   resources.DefaultBrokerCellName,
+		"cluster":      testClusterName,
+	}
+
 	brokerAddress = &apis.URL{
 		Scheme: "http",
 		Host:   fmt.Sprintf("%s.%s.svc.%s", ingressServiceName, systemNS, network.GetClusterDomainName()),
@@ -112,49 +149,523 @@ func TestAllCases(t *testing.T) {
 		Objects: []runtime.Object{
 			NewBroker(brokerName, testNS,
 				WithBrokerClass(brokerv1.BrokerClass),
-				WithInitBrokerConditions,
-				WithBrokerDeletionTimestamp,
+				WithInitBrokerConditions,
+				WithBrokerDeletionTimestamp,
+				WithBrokerSetDefaults,
+			),
+		},
+		WantEvents: []string{
+			brokerFinalizedEvent,
+		},
+		OtherTestData: map[string]interface{}{
+			"pre": []PubsubAction{},
+		},
+		PostConditions: []func(*testing.T, *TableRow){
+			NoTopicsExist(),
+			NoSubscriptionsExist(),
+		},
+	}, {
+		Name: "Broker is being deleted, topic and sub exists",
+		Key:  testKey,
+		Objects: []runtime.Object{
+			NewBroker(brokerName, testNS,
+				WithBrokerClass(brokerv1.BrokerClass),
+				WithBrokerUID(testUID),
+				WithInitBrokerConditions,
+				WithBrokerDeletionTimestamp,
+				WithBrokerSetDefaults,
+			),
+		},
+		WantEvents: []string{
+			Eventf(corev1.EventTypeNormal, "TopicDeleted", `Deleted PubSub topic "cre-bkr_testnamespace_test-broker_abc123"`),
+			Eventf(corev1.EventTypeNormal, "SubscriptionDeleted", `Deleted PubSub subscription "cre-bkr_testnamespace_test-broker_abc123"`),
+			Eventf(corev1.EventTypeNormal, "TopicDeleted", `Deleted PubSub topic "cre-bkr-dly_testnamespace_test-broker_abc123"`),
+			Eventf(corev1.EventTypeNormal, "SubscriptionDeleted", `Deleted PubSub subscription "cre-bkr-dly_testnamespace_test-broker_abc123"`),
+			brokerFinalizedEvent,
+		},
+		OtherTestData: map[string]interface{}{
+			"pre": []PubsubAction{
+				decouplingTopicAndSub(),
+				delayTopicAndSub(),
+			},
+		},
+		PostConditions: []func(*testing.T, *TableRow){
+			NoTopicsExist(),
+			NoSubscriptionsExist(),
+		},
+	}, {
+		Name: "Create broker with ready brokercell, broker is created",
+		Key:  testKey,
+		Objects: []runtime.Object{
+			NewBroker(brokerName, testNS,
+				WithBrokerClass(brokerv1.BrokerClass),
+				WithBrokerUID(testUID),
+				WithBrokerDeliverySpec(brokerDeliverySpec),
+				WithBrokerSetDefaults),
+			NewBrokerCell(resources.DefaultBrokerCellName, systemNS,
+				WithBrokerCellReady,
+				WithBrokerCellSetDefaults),
+		},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: NewBroker(brokerName, testNS,
+				WithBrokerClass(brokerv1.BrokerClass),
+				WithBrokerUID(testUID),
+				WithBrokerDeliverySpec(brokerDeliverySpec),
+				WithBrokerReadyURI(brokerAddress),
+				WithBrokerSetDefaults,
+			),
+		}},
+		WantEvents: []string{
+			brokerFinalizerUpdatedEvent,
+			Eventf(corev1.EventTypeNormal, "TopicCreated", `Created PubSub topic "cre-bkr_testnamespace_test-broker_abc123"`),
+			Eventf(corev1.EventTypeNormal, "SubscriptionCreated", `Created PubSub subscription "cre-bkr_testnamespace_test-broker_abc123"`),
+			Eventf(corev1.EventTypeNormal, "TopicCreated", `Created PubSub topic "cre-bkr-dly_testnamespace_test-broker_abc123"`),
+			Eventf(corev1.EventTypeNormal, "SubscriptionCreated", `Created PubSub subscription "cre-bkr-dly_testnamespace_test-broker_abc123"`),
+			brokerReconciledEvent,
+		},
+		WantPatches: []clientgotesting.PatchActionImpl{
+			patchFinalizers(testNS, brokerName, brokerFinalizerName),
+		},
+		OtherTestData: map[string]interface{}{
+			"pre": []PubsubAction{},
+		},
+		PostConditions: []func(*testing.T, *TableRow){
+			TopicExists("cre-bkr_testnamespace_test-broker_abc123"),
+			SubscriptionExists("cre-bkr_testnamespace_test-broker_abc123"),
+			SubscriptionHasLabels("cre-bkr_testnamespace_test-broker_abc123", brokerSubscriptionLabels),
+			SubscriptionHasLabels("cre-bkr-dly_testnamespace_test-broker_abc123", brokerSubscriptionLabels),
+		},
+	}, {
+		Name: "Create archived broker, archive subscription is created",
+		Key:  testKey,
+		Objects: []runtime.Object{
+			NewBroker(brokerName, testNS,
+				WithBrokerClass(brokerv1.BrokerClass),
+				WithBrokerUID(testUID),
+				WithBrokerAnnotation(brokerv1.ArchiveAnnotationKey, "audit-bucket/events"),
+				WithBrokerDeliverySpec(brokerDeliverySpec),
+				WithBrokerSetDefaults),
+			NewBrokerCell(resources.DefaultBrokerCellName, systemNS,
+				WithBrokerCellReady,
+				WithBrokerCellSetDefaults),
+			NewNamespace(testNS, WithNamespaceAnnotations(map[string]string{brokerv1.ArchiveBucketsAnnotationKey: "audit-bucket"})),
+		},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: NewBroker(brokerName, testNS,
+				WithBrokerClass(brokerv1.BrokerClass),
+				WithBrokerUID(testUID),
+				WithBrokerAnnotation(brokerv1.ArchiveAnnotationKey, "audit-bucket/events"),
+				WithBrokerDeliverySpec(brokerDeliverySpec),
+				WithBrokerReadyURI(brokerAddress),
+				WithBrokerArchive("audit-bucket", "events"),
+				WithBrokerSetDefaults,
+			),
+		}},
+		WantEvents: []string{
+			brokerFinalizerUpdatedEvent,
+			Eventf(corev1.EventTypeNormal, "TopicCreated", `Created PubSub topic "cre-bkr_testnamespace_test-broker_abc123"`),
+			Eventf(corev1.EventTypeNormal, "SubscriptionCreated", `Created PubSub subscription "cre-bkr_testnamespace_test-broker_abc123"`),
+			Eventf(corev1.EventTypeNormal, "TopicCreated", `Created PubSub topic "cre-bkr-dly_testnamespace_test-broker_abc123"`),
+			Eventf(corev1.EventTypeNormal, "SubscriptionCreated", `Created PubSub subscription "cre-bkr-dly_testnamespace_test-broker_abc123"`),
+			Eventf(corev1.EventTypeNormal, "SubscriptionCreated", `Created PubSub subscription "cre-bkr-arc_testnamespace_test-broker_abc123"`),
+			brokerReconciledEvent,
+		},
+		WantPatches: []clientgotesting.PatchActionImpl{
+			patchFinalizers(testNS, brokerName, brokerFinalizerName),
+		},
+		OtherTestData: map[string]interface{}{
+			"pre": []PubsubAction{},
+		},
+		PostConditions: []func(*testing.T, *TableRow){
+			TopicExists("cre-bkr_testnamespace_test-broker_abc123"),
+			SubscriptionExists("cre-bkr_testnamespace_test-broker_abc123"),
+			SubscriptionExists("cre-bkr-arc_testnamespace_test-broker_abc123"),
+		},
+	}, {
+		Name: "Archived broker, bucket not allowed by the namespace",
+		Key:  testKey,
+		Objects: []runtime.Object{
+			NewBroker(brokerName, testNS,
+				WithBrokerClass(brokerv1.BrokerClass),
+				WithBrokerUID(testUID),
+				WithBrokerAnnotation(brokerv1.ArchiveAnnotationKey, "audit-bucket/events"),
+				WithBrokerDeliverySpec(brokerDeliverySpec),
+				WithBrokerSetDefaults),
+			NewBrokerCell(resources.DefaultBrokerCellName, systemNS,
+				WithBrokerCellReady,
+				WithBrokerCellSetDefaults),
+			NewNamespace(testNS, WithNamespaceAnnotations(map[string]string{brokerv1.ArchiveBucketsAnnotationKey: "other-bucket"})),
+		},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: NewBroker(brokerName, testNS,
+				WithBrokerClass(brokerv1.BrokerClass),
+				WithBrokerUID(testUID),
+				WithBrokerAnnotation(brokerv1.ArchiveAnnotationKey, "audit-bucket/events"),
+				WithBrokerDeliverySpec(brokerDeliverySpec),
+				WithBrokerReadyURI(brokerAddress),
+				WithBrokerSetDefaults,
+			),
+		}},
+		WantEvents: []string{
+			brokerFinalizerUpdatedEvent,
+			Eventf(corev1.EventTypeWarning, "ArchiveBucketNotAllowed", `Bucket "audit-bucket" is not listed in the events.cloud.google.com/archiveBuckets annotation of namespace "testnamespace", the events are not archived`),
+			brokerReconciledEvent,
+		},
+		WantPatches: []clientgotesting.PatchActionImpl{
+			patchFinalizers(testNS, brokerName, brokerFinalizerName),
+		},
+		OtherTestData: map[string]interface{}{
+			"pre": []PubsubAction{
+				decouplingTopicAndSub(),
+				delayTopicAndSub(),
+			},
+		},
+		PostConditions: []func(*testing.T, *TableRow){
+			OnlySubscriptions("cre-bkr_testnamespace_test-broker_abc123", "cre-bkr-dly_testnamespace_test-broker_abc123"),
+		},
+	}, {
+		Name: "Broker no longer archived, archive subscription is deleted",
+		Key:  testKey,
+		Objects: []runtime.Object{
+			NewBroker(brokerName, testNS,
+				WithBrokerClass(brokerv1.BrokerClass),
+				WithBrokerUID(testUID),
+				WithBrokerDeliverySpec(brokerDeliverySpec),
+				WithBrokerSetDefaults),
+			NewBrokerCell(resources.DefaultBrokerCellName, systemNS,
+				WithBrokerCellReady,
+				WithBrokerCellSetDefaults),
+		},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: NewBroker(brokerName, testNS,
+				WithBrokerClass(brokerv1.BrokerClass),
+				WithBrokerUID(testUID),
+				WithBrokerDeliverySpec(brokerDeliverySpec),
+				WithBrokerReadyURI(brokerAddress),
+				WithBrokerSetDefaults,
+			),
+		}},
+		WantEvents: []string{
+			brokerFinalizerUpdatedEvent,
+			Eventf(corev1.EventTypeNormal, "SubscriptionDeleted", `Deleted PubSub subscription "cre-bkr-arc_testnamespace_test-broker_abc123"`),
+			brokerReconciledEvent,
+		},
+		WantPatches: []clientgotesting.PatchActionImpl{
+			patchFinalizers(testNS, brokerName, brokerFinalizerName),
+		},
+		OtherTestData: map[string]interface{}{
+			"pre": []PubsubAction{
+				decouplingTopicAndSub(),
+				delayTopicAndSub(),
+				SubscriptionWithTopic("cre-bkr-arc_testnamespace_test-broker_abc123", "cre-bkr_testnamespace_test-broker_abc123"),
+			},
+		},
+		PostConditions: []func(*testing.T, *TableRow){
+			SubscriptionExists("cre-bkr_testnamespace_test-broker_abc123"),
+			OnlySubscriptions("cre-bkr_testnamespace_test-broker_abc123", "cre-bkr-dly_testnamespace_test-broker_abc123"),
+		},
+	}, {
+		Name: "Create federated broker, federation subscription is created",
+		Key:  testKey,
+		Objects: []runtime.Object{
+			NewBroker(brokerName, testNS,
+				WithBrokerClass(brokerv1.BrokerClass),
+				WithBrokerUID(testUID),
+				WithBrokerAnnotation(brokerv1.FederateFromAnnotationKey, "projects/test-project-id/topics/"+federatedTopic),
+				WithBrokerDeliverySpec(brokerDeliverySpec),
+				WithBrokerSetDefaults),
+			NewBrokerCell(resources.DefaultBrokerCellName, systemNS,
+				WithBrokerCellReady,
+				WithBrokerCellSetDefaults),
+		},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: NewBroker(brokerName, testNS,
+				WithBrokerClass(brokerv1.BrokerClass),
+				WithBrokerUID(testUID),
+				WithBrokerAnnotation(brokerv1.FederateFromAnnotationKey, "projects/test-project-id/topics/"+federatedTopic),
+				WithBrokerDeliverySpec(brokerDeliverySpec),
+				WithBrokerReadyURI(brokerAddress),
+				WithBrokerFederationSubscriptions("cre-bkr-fed-f8465f3a_testnamespace_test-broker_abc123"),
+				WithBrokerFederatedTopics(brokerv1.FederatedTopic{Project: testProject, Topic: federatedTopic}),
+				WithBrokerSetDefaults,
+			),
+		}},
+		WantEvents: []string{
+			brokerFinalizerUpdatedEvent,
+			Eventf(corev1.EventTypeNormal, "TopicCreated", `Created PubSub topic "cre-bkr_testnamespace_test-broker_abc123"`),
+			Eventf(corev1.EventTypeNormal, "SubscriptionCreated", `Created PubSub subscription "cre-bkr_testnamespace_test-broker_abc123"`),
+			Eventf(corev1.EventTypeNormal, "TopicCreated", `Created PubSub topic "cre-bkr-dly_testnamespace_test-broker_abc123"`),
+			Eventf(corev1.EventTypeNormal, "SubscriptionCreated", `Created PubSub subscription "cre-bkr-dly_testnamespace_test-broker_abc123"`),
+			Eventf(corev1.EventTypeNormal, "SubscriptionCreated", `Created PubSub subscription "cre-bkr-fed-f8465f3a_testnamespace_test-broker_abc123"`),
+			brokerReconciledEvent,
+		},
+		WantPatches: []clientgotesting.PatchActionImpl{
+			patchFinalizers(testNS, brokerName, brokerFinalizerName),
+		},
+		OtherTestData: map[string]interface{}{
+			"pre": []PubsubAction{
+				Topic(federatedTopic),
+			},
+		},
+		PostConditions: []func(*testing.T, *TableRow){
+			SubscriptionExists("cre-bkr_testnamespace_test-broker_abc123"),
+			SubscriptionExists("cre-bkr-fed-f8465f3a_testnamespace_test-broker_abc123"),
+		},
+	}, {
+		Name: "Federated topic of another project, subscribing fails and the control plane member is reported",
+		Key:  testKey,
+		Objects: []runtime.Object{
+			NewBroker(brokerName, testNS,
+				WithBrokerClass(brokerv1.BrokerClass),
+				WithBrokerUID(testUID),
+				WithBrokerAnnotation(brokerv1.FederateFromAnnotationKey, "projects/remote-project/topics/remote-topic"),
+				WithBrokerDeliverySpec(brokerDeliverySpec),
+				WithBrokerSetDefaults),
+			NewBrokerCell(resources.DefaultBrokerCellName, systemNS,
+				WithBrokerCellReady,
+				WithBrokerCellSetDefaults),
+			NewServiceAccount(authcheck.ControllerServiceAccountName, systemNS, WithServiceAccountAnnotation(controlPlaneGSA)),
+		},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: NewBroker(brokerName, testNS,
+				WithBrokerClass(brokerv1.BrokerClass),
+				WithBrokerUID(testUID),
+				WithBrokerAnnotation(brokerv1.FederateFromAnnotationKey, "projects/remote-project/topics/remote-topic"),
+				WithBrokerDeliverySpec(brokerDeliverySpec),
+				WithBrokerReadyURI(brokerAddress),
+				WithBrokerSubscriptionFailed("SubscriptionCreationFailed", `Subscription creation failed: rpc error: code = NotFound desc = topic "projects/remote-project/topics/remote-topic"`),
+				WithBrokerFederationSubscriptions("cre-bkr-fed-7effad34_testnamespace_test-broker_abc123"),
+				WithBrokerSetDefaults,
+			),
+		}},
+		WantEvents: []string{
+			brokerFinalizerUpdatedEvent,
+			Eventf(corev1.EventTypeWarning, "FederationMemberRequired", "Failed to subscribe to topic projects/remote-project/topics/remote-topic, the remote Broker must list serviceAccount:"+controlPlaneGSA+" in its events.cloud.google.com/federateTo annotation"),
+			Eventf(corev1.EventTypeWarning, "InternalError", `failed to reconcile federation subscriptions: rpc error: code = NotFound desc = topic "projects/remote-project/topics/remote-topic"`),
+		},
+		WantPatches: []clientgotesting.PatchActionImpl{
+			patchFinalizers(testNS, brokerName, brokerFinalizerName),
+		},
+		OtherTestData: map[string]interface{}{
+			"pre": []PubsubAction{
+				decouplingTopicAndSub(),
+				delayTopicAndSub(),
+			},
+		},
+		WantErr: true,
+	}, {
+		Name: "Federated topic of the project in another namespace, topic is not federated",
+		Key:  testKey,
+		Objects: []runtime.Object{
+			NewBroker(brokerName, testNS,
+				WithBrokerClass(brokerv1.BrokerClass),
+				WithBrokerUID(testUID),
+				WithBrokerAnnotation(brokerv1.FederateFromAnnotationKey, "projects/test-project-id/topics/cre-bkr_otherns_other-broker_def456"),
+				WithBrokerDeliverySpec(brokerDeliverySpec),
+				WithBrokerSetDefaults),
+			NewBrokerCell(resources.DefaultBrokerCellName, systemNS,
+				WithBrokerCellReady,
+				WithBrokerCellSetDefaults),
+		},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: NewBroker(brokerName, testNS,
+				WithBrokerClass(brokerv1.BrokerClass),
+				WithBrokerUID(testUID),
+				WithBrokerAnnotation(brokerv1.FederateFromAnnotationKey, "projects/test-project-id/topics/cre-bkr_otherns_other-broker_def456"),
+				WithBrokerDeliverySpec(brokerDeliverySpec),
+				WithBrokerReadyURI(brokerAddress),
+				WithBrokerSetDefaults,
+			),
+		}},
+		WantEvents: []string{
+			brokerFinalizerUpdatedEvent,
+			Eventf(corev1.EventTypeWarning, "FederatedTopicNotAllowed", `Topic projects/test-project-id/topics/cre-bkr_otherns_other-broker_def456 is in the project of the cluster but is not the decouple topic of a Broker in namespace "testnamespace", it is not federated`),
+			brokerReconciledEvent,
+		},
+		WantPatches: []clientgotesting.PatchActionImpl{
+			patchFinalizers(testNS, brokerName, brokerFinalizerName),
+		},
+		OtherTestData: map[string]interface{}{
+			"pre": []PubsubAction{
+				decouplingTopicAndSub(),
+				delayTopicAndSub(),
+				Topic("cre-bkr_otherns_other-broker_def456"),
+			},
+		},
+		PostConditions: []func(*testing.T, *TableRow){
+			OnlySubscriptions("cre-bkr_testnamespace_test-broker_abc123", "cre-bkr-dly_testnamespace_test-broker_abc123"),
+		},
+	}, {
+		Name: "Broker federated to another cluster, subscriber role is granted to the allowed members only",
+		Key:  testKey,
+		Objects: []runtime.Object{
+			NewBroker(brokerName, testNS,
+				WithBrokerClass(brokerv1.BrokerClass),
+				WithBrokerUID(testUID),
+				WithBrokerAnnotation(brokerv1.FederateToAnnotationKey, remoteControlPlane),
+				WithBrokerDeliverySpec(brokerDeliverySpec),
+				WithBrokerFederationMembers(staleControlPlane),
+				WithBrokerSetDefaults),
+			NewBrokerCell(resources.DefaultBrokerCellName, systemNS,
+				WithBrokerCellReady,
+				WithBrokerCellSetDefaults),
+		},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: NewBroker(brokerName, testNS,
+				WithBrokerClass(brokerv1.BrokerClass),
+				WithBrokerUID(testUID),
+				WithBrokerAnnotation(brokerv1.FederateToAnnotationKey, remoteControlPlane),
+				WithBrokerDeliverySpec(brokerDeliverySpec),
+				WithBrokerReadyURI(brokerAddress),
+				WithBrokerFederationMembers(remoteControlPlane),
+				WithBrokerSetDefaults,
+			),
+		}},
+		WantEvents: []string{
+			brokerFinalizerUpdatedEvent,
+			brokerReconciledEvent,
+		},
+		WantPatches: []clientgotesting.PatchActionImpl{
+			patchFinalizers(testNS, brokerName, brokerFinalizerName),
+		},
+		OtherTestData: map[string]interface{}{
+			"pre": []PubsubAction{
+				decouplingTopicAndSub(),
+				delayTopicAndSub(),
+			},
+			"topicIAM": topicIAMWithSubscribers(staleControlPlane),
+		},
+		PostConditions: []func(*testing.T, *TableRow){
+			topicSubscribers(remoteControlPlane),
+		},
+	}, {
+		Name: "Reading the IAM policy of the topic fails, members are not recorded",
+		Key:  testKey,
+		Objects: []runtime.Object{
+			NewBroker(brokerName, testNS,
+				WithBrokerClass(brokerv1.BrokerClass),
+				WithBrokerUID(testUID),
+				WithBrokerAnnotation(brokerv1.FederateToAnnotationKey, remoteControlPlane),
+				WithBrokerDeliverySpec(brokerDeliverySpec),
+				WithBrokerSetDefaults),
+			NewBrokerCell(resources.DefaultBrokerCellName, systemNS,
+				WithBrokerCellReady,
+				WithBrokerCellSetDefaults),
+		},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: NewBroker(brokerName, testNS,
+				WithBrokerClass(brokerv1.BrokerClass),
+				WithBrokerUID(testUID),
+				WithBrokerAnnotation(brokerv1.FederateToAnnotationKey, remoteControlPlane),
+				WithBrokerDeliverySpec(brokerDeliverySpec),
+				WithBrokerReadyURI(brokerAddress),
+				WithBrokerSetDefaults,
+			),
+		}},
+		WantEvents: []string{
+			brokerFinalizerUpdatedEvent,
+			Eventf(corev1.EventTypeWarning, "InternalError", "failed to reconcile federation members: permission denied"),
+		},
+		WantPatches: []clientgotesting.PatchActionImpl{
+			patchFinalizers(testNS, brokerName, brokerFinalizerName),
+		},
+		OtherTestData: map[string]interface{}{
+			"pre": []PubsubAction{
+				decouplingTopicAndSub(),
+				delayTopicAndSub(),
+			},
+			"topicIAM": iamtesting.NewTestHandle(iamtesting.TestHandleData{PolicyErr: errors.New("permission denied")}),
+		},
+		WantErr: true,
+	}, {
+		Name: "Broker no longer federated, federation subscription is deleted",
+		Key:  testKey,
+		Objects: []runtime.Object{
+			NewBroker(brokerName, testNS,
+				WithBrokerClass(brokerv1.BrokerClass),
+				WithBrokerUID(testUID),
+				WithBrokerDeliverySpec(brokerDeliverySpec),
+				WithBrokerFederationSubscriptions("cre-bkr-fed-b0531e8c_testnamespace_test-broker_abc123"),
+				WithBrokerSetDefaults),
+			NewBrokerCell(resources.DefaultBrokerCellName, systemNS,
+				WithBrokerCellReady,
+				WithBrokerCellSetDefaults),
+		},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: NewBroker(brokerName, testNS,
+				WithBrokerClass(brokerv1.BrokerClass),
+				WithBrokerUID(testUID),
+				WithBrokerDeliverySpec(brokerDeliverySpec),
+				WithBrokerReadyURI(brokerAddress),
 				WithBrokerSetDefaults,
 			),
-		},
+		}},
 		WantEvents: []string{
-			brokerFinalizedEvent,
+			brokerFinalizerUpdatedEvent,
+			Eventf(corev1.EventTypeNormal, "SubscriptionDeleted", `Deleted PubSub subscription "cre-bkr-fed-b0531e8c_testnamespace_test-broker_abc123"`),
+			brokerReconciledEvent,
+		},
+		WantPatches: []clientgotesting.PatchActionImpl{
+			patchFinalizers(testNS, brokerName, brokerFinalizerName),
 		},
 		OtherTestData: map[string]interface{}{
-			"pre": []PubsubAction{},
+			"pre": []PubsubAction{
+				decouplingTopicAndSub(),
+				delayTopicAndSub(),
+				TopicAndSub("remote-topic", "cre-bkr-fed-b0531e8c_testnamespace_test-broker_abc123"),
+			},
 		},
 		PostConditions: []func(*testing.T, *TableRow){
-			NoTopicsExist(),
-			NoSubscriptionsExist(),
+			OnlySubscriptions("cre-bkr_testnamespace_test-broker_abc123", "cre-bkr-dly_testnamespace_test-broker_abc123"),
 		},
 	}, {
-		Name: "Broker is being deleted, topic and sub exists",
+		Name: "Create broker with ready brokercell with external ingress, broker publishes its external address",
 		Key:  testKey,
 		Objects: []runtime.Object{
 			NewBroker(brokerName, testNS,
 				WithBrokerClass(brokerv1.BrokerClass),
 				WithBrokerUID(testUID),
-				WithInitBrokerConditions,
-				WithBrokerDeletionTimestamp,
+				WithBrokerDeliverySpec(brokerDeliverySpec),
+				WithBrokerSetDefaults),
+			NewBrokerCell(resources.DefaultBrokerCellName, systemNS,
+				WithBrokerCellReady,
+				WithExternalIngressTemplate("https://{name}.{namespace}.events.example.com/{namespace}/{name}"),
+				WithBrokerCellSetDefaults),
+		},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: NewBroker(brokerName, testNS,
+				WithBrokerClass(brokerv1.BrokerClass),
+				WithBrokerUID(testUID),
+				WithBrokerDeliverySpec(brokerDeliverySpec),
+				WithBrokerReadyURI(brokerAddress),
+				WithBrokerExternalAddress(&apis.URL{
+					Scheme: "https",
+					Host:   "test-broker.testnamespace.events.example.com",
+					Path:   ingress.BrokerPath(testNS, brokerName),
+				}),
 				WithBrokerSetDefaults,
 			),
-		},
+		}},
 		WantEvents: []string{
-			Eventf(corev1.EventTypeNormal, "TopicDeleted", `Deleted PubSub topic "cre-bkr_testnamespace_test-broker_abc123"`),
-			Eventf(corev1.EventTypeNormal, "SubscriptionDeleted", `Deleted PubSub subscription "cre-bkr_testnamespace_test-broker_abc123"`),
-			brokerFinalizedEvent,
+			brokerFinalizerUpdatedEvent,
+			Eventf(corev1.EventTypeNormal, "TopicCreated", `Created PubSub topic "cre-bkr_testnamespace_test-broker_abc123"`),
+			Eventf(corev1.EventTypeNormal, "SubscriptionCreated", `Created PubSub subscription "cre-bkr_testnamespace_test-broker_abc123"`),
+			Eventf(corev1.EventTypeNormal, "TopicCreated", `Created PubSub topic "cre-bkr-dly_testnamespace_test-broker_abc123"`),
+			Eventf(corev1.EventTypeNormal, "SubscriptionCreated", `Created PubSub subscription "cre-bkr-dly_testnamespace_test-broker_abc123"`),
+			brokerReconciledEvent,
+		},
+		WantPatches: []clientgotesting.PatchActionImpl{
+			patchFinalizers(testNS, brokerName, brokerFinalizerName),
 		},
 		OtherTestData: map[string]interface{}{
-			"pre": []PubsubAction{
-				TopicAndSub("cre-bkr_testnamespace_test-broker_abc123", "cre-bkr_testnamespace_test-broker_abc123"),
-			},
+			"pre": []PubsubAction{},
 		},
 		PostConditions: []func(*testing.T, *TableRow){
-			NoTopicsExist(),
-			NoSubscriptionsExist(),
+			TopicExists("cre-bkr_testnamespace_test-broker_abc123"),
+			SubscriptionExists("cre-bkr_testnamespace_test-broker_abc123"),
 		},
 	}, {
-		Name: "Create broker with ready brokercell, broker is created",
+		Name: "Broker discovers the event types observed by the ingress",
 		Key:  testKey,
 		Objects: []runtime.Object{
 			NewBroker(brokerName, testNS,
@@ -165,13 +676,41 @@ func TestAllCases(t *testing.T) {
 			NewBrokerCell(resources.DefaultBrokerCellName, systemNS,
 				WithBrokerCellReady,
 				WithBrokerCellSetDefaults),
+			eventTypeReports(map[string]map[string][]eventtype.Observation{"ingress-1": {
+				testKey: {
+					{Tuple: eventtype.Tuple{Type: "type-a", Source: "//source"}, Count: 5, LastSeen: metav1.Now()},
+					{Tuple: eventtype.Tuple{Type: "type-expired"}, Count: 100, LastSeen: metav1.NewTime(time.Now().Add(-2 * eventtype.TTL))},
+				},
+				"testnamespace/other-broker": {
+					{Tuple: eventtype.Tuple{Type: "type-other"}, Count: 1, LastSeen: metav1.Now()},
+				},
+			}, "ingress-2": {
+				testKey: {
+					{Tuple: eventtype.Tuple{Type: "type-b"}, Count: 2, LastSeen: metav1.Now()},
+				},
+			}}),
+			outdatedEventType(eventtype.Tuple{Type: "type-b"}),
+			discoveredEventType(eventtype.Tuple{Type: "type-stale"}),
 		},
+		WantCreates: []runtime.Object{
+			discoveredEventType(eventtype.Tuple{Type: "type-a", Source: "//source"}),
+		},
+		WantUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: discoveredEventType(eventtype.Tuple{Type: "type-b"}),
+		}},
+		WantDeletes: []clientgotesting.DeleteActionImpl{{
+			ActionImpl: clientgotesting.ActionImpl{
+				Namespace: testNS, Verb: "delete", Resource: eventingv1beta1.SchemeGroupVersion.WithResource("eventtypes"),
+			},
+			Name: resources.EventTypeName(discoveredEventTypeBroker(), eventtype.Tuple{Type: "type-stale"}),
+		}},
 		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
 			Object: NewBroker(brokerName, testNS,
 				WithBrokerClass(brokerv1.BrokerClass),
 				WithBrokerUID(testUID),
 				WithBrokerDeliverySpec(brokerDeliverySpec),
 				WithBrokerReadyURI(brokerAddress),
+				WithBrokerTopEventTypes("type-a", "type-b"),
 				WithBrokerSetDefaults,
 			),
 		}},
@@ -179,6 +718,8 @@ func TestAllCases(t *testing.T) {
 			brokerFinalizerUpdatedEvent,
 			Eventf(corev1.EventTypeNormal, "TopicCreated", `Created PubSub topic "cre-bkr_testnamespace_test-broker_abc123"`),
 			Eventf(corev1.EventTypeNormal, "SubscriptionCreated", `Created PubSub subscription "cre-bkr_testnamespace_test-broker_abc123"`),
+			Eventf(corev1.EventTypeNormal, "TopicCreated", `Created PubSub topic "cre-bkr-dly_testnamespace_test-broker_abc123"`),
+			Eventf(corev1.EventTypeNormal, "SubscriptionCreated", `Created PubSub subscription "cre-bkr-dly_testnamespace_test-broker_abc123"`),
 			brokerReconciledEvent,
 		},
 		WantPatches: []clientgotesting.PatchActionImpl{
@@ -220,6 +761,8 @@ func TestAllCases(t *testing.T) {
 			brokerFinalizerUpdatedEvent,
 			Eventf(corev1.EventTypeNormal, "TopicCreated", `Created PubSub topic "cre-bkr_testnamespace_test-broker_abc123"`),
 			Eventf(corev1.EventTypeNormal, "SubscriptionCreated", `Created PubSub subscription "cre-bkr_testnamespace_test-broker_abc123"`),
+			Eventf(corev1.EventTypeNormal, "TopicCreated", `Created PubSub topic "cre-bkr-dly_testnamespace_test-broker_abc123"`),
+			Eventf(corev1.EventTypeNormal, "SubscriptionCreated", `Created PubSub subscription "cre-bkr-dly_testnamespace_test-broker_abc123"`),
 			brokerReconciledEvent,
 		},
 		WantPatches: []clientgotesting.PatchActionImpl{
@@ -298,6 +841,8 @@ func TestAllCases(t *testing.T) {
 			Eventf(corev1.EventTypeNormal, "BrokerCellCreated", `Created BrokerCell knative-testing/default`),
 			Eventf(corev1.EventTypeNormal, "TopicCreated", `Created PubSub topic "cre-bkr_testnamespace_test-broker_abc123"`),
 			Eventf(corev1.EventTypeNormal, "SubscriptionCreated", `Created PubSub subscription "cre-bkr_testnamespace_test-broker_abc123"`),
+			Eventf(corev1.EventTypeNormal, "TopicCreated", `Created PubSub topic "cre-bkr-dly_testnamespace_test-broker_abc123"`),
+			Eventf(corev1.EventTypeNormal, "SubscriptionCreated", `Created PubSub subscription "cre-bkr-dly_testnamespace_test-broker_abc123"`),
 			brokerReconciledEvent,
 		},
 		WantPatches: []clientgotesting.PatchActionImpl{
@@ -340,6 +885,8 @@ func TestAllCases(t *testing.T) {
 			brokerFinalizerUpdatedEvent,
 			Eventf(corev1.EventTypeNormal, "TopicCreated", `Created PubSub topic "cre-bkr_testnamespace_test-broker_abc123"`),
 			Eventf(corev1.EventTypeNormal, "SubscriptionCreated", `Created PubSub subscription "cre-bkr_testnamespace_test-broker_abc123"`),
+			Eventf(corev1.EventTypeNormal, "TopicCreated", `Created PubSub topic "cre-bkr-dly_testnamespace_test-broker_abc123"`),
+			Eventf(corev1.EventTypeNormal, "SubscriptionCreated", `Created PubSub subscription "cre-bkr-dly_testnamespace_test-broker_abc123"`),
 			brokerReconciledEvent,
 		},
 		WantPatches: []clientgotesting.PatchActionImpl{
@@ -359,6 +906,184 @@ func TestAllCases(t *testing.T) {
 				},
 			}),
 		},
+	}, {
+		Name: "Broker topic created in the regions of its allowed persistence regions annotation",
+		Key:  testKey,
+		Objects: []runtime.Object{
+			NewBroker(brokerName, testNS,
+				WithBrokerClass(brokerv1.BrokerClass),
+				WithBrokerUID(testUID),
+				WithBrokerAnnotation(duck.AllowedPersistenceRegionsAnnotation, "europe-west1"),
+				WithBrokerDeliverySpec(brokerDeliverySpec),
+				WithBrokerSetDefaults),
+			NewBrokerCell(resources.DefaultBrokerCellName, systemNS,
+				WithBrokerCellReady,
+				WithBrokerCellSetDefaults),
+		},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: NewBroker(brokerName, testNS,
+				WithBrokerClass(brokerv1.BrokerClass),
+				WithBrokerUID(testUID),
+				WithBrokerAnnotation(duck.AllowedPersistenceRegionsAnnotation, "europe-west1"),
+				WithBrokerDeliverySpec(brokerDeliverySpec),
+				WithBrokerReadyURI(brokerAddress),
+				WithBrokerSetDefaults,
+			),
+		}},
+		WantEvents: []string{
+			brokerFinalizerUpdatedEvent,
+			Eventf(corev1.EventTypeNormal, "TopicCreated", `Created PubSub topic "cre-bkr_testnamespace_test-broker_abc123"`),
+			Eventf(corev1.EventTypeNormal, "SubscriptionCreated", `Created PubSub subscription "cre-bkr_testnamespace_test-broker_abc123"`),
+			Eventf(corev1.EventTypeNormal, "TopicCreated", `Created PubSub topic "cre-bkr-dly_testnamespace_test-broker_abc123"`),
+			Eventf(corev1.EventTypeNormal, "SubscriptionCreated", `Created PubSub subscription "cre-bkr-dly_testnamespace_test-broker_abc123"`),
+			brokerReconciledEvent,
+		},
+		WantPatches: []clientgotesting.PatchActionImpl{
+			patchFinalizers(testNS, brokerName, brokerFinalizerName),
+		},
+		OtherTestData: map[string]interface{}{
+			"pre":                    []PubsubAction{},
+			"dataResidencyConfigMap": NewDataresidencyConfigMapFromRegions([]string{"us-east1"}),
+		},
+		PostConditions: []func(*testing.T, *TableRow){
+			TopicExistsWithConfig("cre-bkr_testnamespace_test-broker_abc123", &pubsub.TopicConfig{
+				MessageStoragePolicy: pubsub.MessageStoragePolicy{
+					AllowedPersistenceRegions: []string{"europe-west1"},
+				},
+				Labels: map[string]string{
+					"broker_class": "googlecloud", "name": "test-broker", "namespace": "testnamespace", "resource": "brokers",
+				},
+			}),
+		},
+	}, {
+		Name: "Existing broker topic conflicts with data residency",
+		Key:  testKey,
+		Objects: []runtime.Object{
+			NewBroker(brokerName, testNS,
+				WithBrokerClass(brokerv1.BrokerClass),
+				WithBrokerUID(testUID),
+				WithBrokerDeliverySpec(brokerDeliverySpec),
+				WithBrokerSetDefaults),
+			NewBrokerCell(resources.DefaultBrokerCellName, systemNS,
+				WithBrokerCellReady,
+				WithBrokerCellSetDefaults),
+		},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: NewBroker(brokerName, testNS,
+				WithBrokerClass(brokerv1.BrokerClass),
+				WithBrokerUID(testUID),
+				WithBrokerDeliverySpec(brokerDeliverySpec),
+				WithInitBrokerConditions,
+				WithBrokerBrokerCellReady,
+				WithBrokerAddressURI(brokerAddress),
+				WithBrokerTopicFailed("TopicDataResidencyConflict", `topic "cre-bkr_testnamespace_test-broker_abc123" persists messages in regions [us-central1], the data residency only allows [us-east1]`),
+				WithBrokerSetDefaults,
+			),
+		}},
+		WantEvents: []string{
+			brokerFinalizerUpdatedEvent,
+			Eventf(corev1.EventTypeWarning, "InternalError", `failed to reconcile broker: decoupling topic reconcile failed: topic "cre-bkr_testnamespace_test-broker_abc123" persists messages in regions [us-central1], the data residency only allows [us-east1]`),
+		},
+		WantPatches: []clientgotesting.PatchActionImpl{
+			patchFinalizers(testNS, brokerName, brokerFinalizerName),
+		},
+		OtherTestData: map[string]interface{}{
+			"pre": []PubsubAction{
+				TopicWithConfig("cre-bkr_testnamespace_test-broker_abc123", &pubsub.TopicConfig{
+					MessageStoragePolicy: pubsub.MessageStoragePolicy{
+						AllowedPersistenceRegions: []string{"us-central1"},
+					},
+				}),
+			},
+			"dataResidencyConfigMap": NewDataresidencyConfigMapFromRegions([]string{"us-east1"}),
+		},
+		WantErr: true,
+	}, {
+		Name: "Broker topic created with the key of its kms key name annotation",
+		Key:  testKey,
+		Objects: []runtime.Object{
+			NewBroker(brokerName, testNS,
+				WithBrokerClass(brokerv1.BrokerClass),
+				WithBrokerUID(testUID),
+				WithBrokerAnnotation(duck.KMSKeyNameAnnotation, testBrokerKMSKeyName),
+				WithBrokerDeliverySpec(brokerDeliverySpec),
+				WithBrokerSetDefaults),
+			NewBrokerCell(resources.DefaultBrokerCellName, systemNS,
+				WithBrokerCellReady,
+				WithBrokerCellSetDefaults),
+		},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: NewBroker(brokerName, testNS,
+				WithBrokerClass(brokerv1.BrokerClass),
+				WithBrokerUID(testUID),
+				WithBrokerAnnotation(duck.KMSKeyNameAnnotation, testBrokerKMSKeyName),
+				WithBrokerDeliverySpec(brokerDeliverySpec),
+				WithBrokerReadyURI(brokerAddress),
+				WithBrokerSetDefaults,
+			),
+		}},
+		WantEvents: []string{
+			brokerFinalizerUpdatedEvent,
+			Eventf(corev1.EventTypeNormal, "TopicCreated", `Created PubSub topic "cre-bkr_testnamespace_test-broker_abc123"`),
+			Eventf(corev1.EventTypeNormal, "SubscriptionCreated", `Created PubSub subscription "cre-bkr_testnamespace_test-broker_abc123"`),
+			Eventf(corev1.EventTypeNormal, "TopicCreated", `Created PubSub topic "cre-bkr-dly_testnamespace_test-broker_abc123"`),
+			Eventf(corev1.EventTypeNormal, "SubscriptionCreated", `Created PubSub subscription "cre-bkr-dly_testnamespace_test-broker_abc123"`),
+			brokerReconciledEvent,
+		},
+		WantPatches: []clientgotesting.PatchActionImpl{
+			patchFinalizers(testNS, brokerName, brokerFinalizerName),
+		},
+		OtherTestData: map[string]interface{}{
+			"pre":                 []PubsubAction{},
+			"encryptionConfigMap": NewEncryptionConfigMapFromKMSKeyName(testClusterKMSKeyName),
+		},
+		PostConditions: []func(*testing.T, *TableRow){
+			TopicExistsWithConfig("cre-bkr_testnamespace_test-broker_abc123", &pubsub.TopicConfig{
+				KMSKeyName: testBrokerKMSKeyName,
+				Labels: map[string]string{
+					"broker_class": "googlecloud", "name": "test-broker", "namespace": "testnamespace", "resource": "brokers",
+				},
+			}),
+		},
+	}, {
+		Name: "Existing broker topic conflicts with encryption",
+		Key:  testKey,
+		Objects: []runtime.Object{
+			NewBroker(brokerName, testNS,
+				WithBrokerClass(brokerv1.BrokerClass),
+				WithBrokerUID(testUID),
+				WithBrokerDeliverySpec(brokerDeliverySpec),
+				WithBrokerSetDefaults),
+			NewBrokerCell(resources.DefaultBrokerCellName, systemNS,
+				WithBrokerCellReady,
+				WithBrokerCellSetDefaults),
+		},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: NewBroker(brokerName, testNS,
+				WithBrokerClass(brokerv1.BrokerClass),
+				WithBrokerUID(testUID),
+				WithBrokerDeliverySpec(brokerDeliverySpec),
+				WithInitBrokerConditions,
+				WithBrokerBrokerCellReady,
+				WithBrokerAddressURI(brokerAddress),
+				WithBrokerTopicFailed("TopicEncryptionConflict", `topic "cre-bkr_testnamespace_test-broker_abc123" is encrypted with a Google-managed key, the encryption requires key "`+testClusterKMSKeyName+`"`),
+				WithBrokerSetDefaults,
+			),
+		}},
+		WantEvents: []string{
+			brokerFinalizerUpdatedEvent,
+			Eventf(corev1.EventTypeWarning, "InternalError", `failed to reconcile broker: decoupling topic reconcile failed: topic "cre-bkr_testnamespace_test-broker_abc123" is encrypted with a Google-managed key, the encryption requires key "`+testClusterKMSKeyName+`"`),
+		},
+		WantPatches: []clientgotesting.PatchActionImpl{
+			patchFinalizers(testNS, brokerName, brokerFinalizerName),
+		},
+		OtherTestData: map[string]interface{}{
+			"pre": []PubsubAction{
+				Topic("cre-bkr_testnamespace_test-broker_abc123"),
+			},
+			"encryptionConfigMap": NewEncryptionConfigMapFromKMSKeyName(testClusterKMSKeyName),
+		},
+		WantErr: true,
 	}, {
 		Name: "Create broker with ready brokercell with nil Pubsub client",
 		Key:  testKey,
@@ -385,6 +1110,8 @@ func TestAllCases(t *testing.T) {
 			brokerFinalizerUpdatedEvent,
 			Eventf(corev1.EventTypeNormal, "TopicCreated", `Created PubSub topic "cre-bkr_testnamespace_test-broker_abc123"`),
 			Eventf(corev1.EventTypeNormal, "SubscriptionCreated", `Created PubSub subscription "cre-bkr_testnamespace_test-broker_abc123"`),
+			Eventf(corev1.EventTypeNormal, "TopicCreated", `Created PubSub topic "cre-bkr-dly_testnamespace_test-broker_abc123"`),
+			Eventf(corev1.EventTypeNormal, "SubscriptionCreated", `Created PubSub subscription "cre-bkr-dly_testnamespace_test-broker_abc123"`),
 			brokerReconciledEvent,
 		},
 		WantPatches: []clientgotesting.PatchActionImpl{
@@ -463,6 +1190,12 @@ func TestAllCases(t *testing.T) {
 			drStore = NewDataresidencyTestStore(t, cm.(*corev1.ConfigMap))
 		}
 
+		// If we found "encryptionConfigMap" in OtherData, we create a store with the configmap
+		var eStore *encryption.Store
+		if cm, ok := testData["encryptionConfigMap"]; ok {
+			eStore = NewEncryptionTestStore(t, cm.(*corev1.ConfigMap))
+		}
+
 		// If maxPSClientCreateTime is in testData, no pubsub client is passed to reconciler, the reconciler
 		// will create one in demand
 		testPSClient := psclient
@@ -482,13 +1215,144 @@ func TestAllCases(t *testing.T) {
 				ProjectID:          testProject,
 				PubsubClient:       testPSClient,
 				DataresidencyStore: drStore,
+				EncryptionStore:    eStore,
 				ClusterRegion:      testClusterRegion,
+				ClusterName:        testClusterName,
 			},
+			eventTypeLister:   listers.GetEventTypeLister(),
+			configMapLister:   listers.GetConfigMapLister(),
+			eventingClientSet: fakeeventingclient.Get(ctx),
+			namespaceLister:   listers.GetNamespaceLister(),
+		}
+		if h, ok := testData["topicIAM"]; ok {
+			r.topicIAM = func(string, string) giam.Handle {
+				return h.(giam.Handle)
+			}
 		}
 		return brokerreconciler.NewReconciler(ctx, r.Logger, r.RunClientSet, listers.GetBrokerLister(), r.Recorder, r, brokerv1.BrokerClass)
 	}))
 }
 
+// decouplingTopicAndSub creates the decoupling topic and subscription of the Broker as the
+// reconciler does.
+func decouplingTopicAndSub() PubsubAction {
+	return func(ctx context.Context, t *testing.T, c *pubsub.Client) {
+		Topic("cre-bkr_testnamespace_test-broker_abc123")(ctx, t, c)
+		if _, err := c.CreateSubscription(ctx, "cre-bkr_testnamespace_test-broker_abc123", pubsub.SubscriptionConfig{
+			Topic:  c.Topic("cre-bkr_testnamespace_test-broker_abc123"),
+			Labels: brokerSubscriptionLabels,
+		}); err != nil {
+			t.Fatalf("Error creating decoupling subscription: %v", err)
+		}
+	}
+}
+
+// delayTopicAndSub creates the delay topic and subscription of the Broker as the reconciler does.
+func delayTopicAndSub() PubsubAction {
+	return func(ctx context.Context, t *testing.T, c *pubsub.Client) {
+		Topic("cre-bkr-dly_testnamespace_test-broker_abc123")(ctx, t, c)
+		if _, err := c.CreateSubscription(ctx, "cre-bkr-dly_testnamespace_test-broker_abc123", pubsub.SubscriptionConfig{
+			Topic:             c.Topic("cre-bkr-dly_testnamespace_test-broker_abc123"),
+			Labels:            brokerSubscriptionLabels,
+			RetentionDuration: 7 * 24 * time.Hour,
+			RetryPolicy: &pubsub.RetryPolicy{
+				MinimumBackoff: 10 * time.Second,
+				MaximumBackoff: 10 * time.Minute,
+			},
+		}); err != nil {
+			t.Fatalf("Error creating delay subscription: %v", err)
+		}
+	}
+}
+
+// topicIAMWithSubscribers returns a topic IAM handle whose policy grants the subscriber role to the
+// members.
+func topicIAMWithSubscribers(members ...string) giam.Handle {
+	h := iamtesting.NewTestHandle(iamtesting.TestHandleData{})
+	policy, _ := h.Policy(context.Background())
+	for _, m := range members {
+		policy.Add(m, subscriberRole)
+	}
+	h.SetPolicy(context.Background(), policy)
+	return h
+}
+
+func topicSubscribers(members ...string) func(*testing.T, *TableRow) {
+	return func(t *testing.T, r *TableRow) {
+		policy, err := r.OtherTestData["topicIAM"].(giam.Handle).Policy(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(members, policy.Members(subscriberRole)); diff != "" {
+			t.Errorf("unexpected members granted %s on the topic (-want, +got) = %v", subscriberRole, diff)
+		}
+	}
+}
+
+func TestChangedReportBrokers(t *testing.T) {
+	seen := func(typ string) []eventtype.Observation {
+		return []eventtype.Observation{{Tuple: eventtype.Tuple{Type: typ}, Count: 1, LastSeen: metav1.Now()}}
+	}
+	old := eventTypeReports(map[string]map[string][]eventtype.Observation{
+		"ingress-1": {"ns/unchanged": seen("type-a")},
+		"ingress-2": {"ns/changed": seen("type-a"), "ns/dropped": seen("type-a")},
+		"ingress-3": {"ns/deleted": seen("type-a")},
+	})
+	new := old.DeepCopy()
+	delete(new.Data, "ingress-2")
+	delete(new.Data, "ingress-3")
+	if err := eventtype.SetReport(new, "ingress-2", &eventtype.Report{
+		Brokers:    map[string][]eventtype.Observation{"ns/changed": seen("type-b"), "channel/ns/channel": seen("type-b")},
+		ReportedAt: metav1.Now(),
+	}); err != nil {
+		t.Fatalf("SetReport got error: %v", err)
+	}
+
+	want := []types.NamespacedName{
+		{Namespace: "ns", Name: "changed"},
+		{Namespace: "ns", Name: "deleted"},
+		{Namespace: "ns", Name: "dropped"},
+	}
+	if diff := cmp.Diff(want, changedReportBrokers(old, new)); diff != "" {
+		t.Errorf("changedReportBrokers (-want,+got): %v", diff)
+	}
+	want = []types.NamespacedName{{Namespace: "ns", Name: "unchanged"}}
+	if diff := cmp.Diff(want, changedReportBrokers(nil, eventTypeReports(map[string]map[string][]eventtype.Observation{
+		"ingress-1": {"ns/unchanged": seen("type-a")},
+	}))); diff != "" {
+		t.Errorf("changedReportBrokers of a new ConfigMap (-want,+got): %v", diff)
+	}
+}
+
+func eventTypeReports(reports map[string]map[string][]eventtype.Observation) *corev1.ConfigMap {
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: systemNS,
+			Name:      eventtype.ReportsName,
+		},
+	}
+	for podName, brokers := range reports {
+		if err := eventtype.SetReport(cm, podName, &eventtype.Report{Brokers: brokers, ReportedAt: metav1.Now()}); err != nil {
+			panic(err)
+		}
+	}
+	return cm
+}
+
+func discoveredEventTypeBroker() *brokerv1.Broker {
+	return NewBroker(brokerName, testNS, WithBrokerClass(brokerv1.BrokerClass), WithBrokerUID(testUID))
+}
+
+func discoveredEventType(t eventtype.Tuple) *eventingv1beta1.EventType {
+	return resources.MakeEventType(discoveredEventTypeBroker(), t)
+}
+
+func outdatedEventType(t eventtype.Tuple) *eventingv1beta1.EventType {
+	et := discoveredEventType(t)
+	et.Spec.Description = "outdated"
+	return et
+}
+
 func patchFinalizers(namespace, name, finalizer string) clientgotesting.PatchActionImpl {
 	action := clientgotesting.PatchActionImpl{}
 	action.Name = name
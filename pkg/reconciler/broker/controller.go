@@ -23,19 +23,27 @@ import (
 
 	"github.com/google/knative-gcp/pkg/apis/configs/brokerdelivery"
 	"github.com/google/knative-gcp/pkg/apis/configs/dataresidency"
+	"github.com/google/knative-gcp/pkg/apis/configs/encryption"
 
 	"cloud.google.com/go/pubsub"
 	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/client-go/tools/cache"
 
+	"github.com/google/knative-gcp/pkg/broker/eventtype"
 	"github.com/google/knative-gcp/pkg/logging"
+	configmapinformer "knative.dev/pkg/client/injection/kube/informers/core/v1/configmap"
+	namespaceinformer "knative.dev/pkg/client/injection/kube/informers/core/v1/namespace"
 	"knative.dev/pkg/configmap"
 	"knative.dev/pkg/controller"
 	"knative.dev/pkg/injection"
+	"knative.dev/pkg/system"
 
 	brokerv1 "github.com/google/knative-gcp/pkg/apis/broker/v1"
 	inteventsv1alpha1 "github.com/google/knative-gcp/pkg/apis/intevents/v1alpha1"
+	eventingclient "github.com/google/knative-gcp/pkg/client/injection/eventing/client"
+	eventtypeinformer "github.com/google/knative-gcp/pkg/client/injection/eventing/informers/eventing/v1beta1/eventtype"
 	brokerinformer "github.com/google/knative-gcp/pkg/client/injection/informers/broker/v1/broker"
 	brokercellinformer "github.com/google/knative-gcp/pkg/client/injection/informers/intevents/v1alpha1/brokercell"
 	brokerreconciler "github.com/google/knative-gcp/pkg/client/injection/reconciler/broker/v1/broker"
@@ -53,15 +61,16 @@ const (
 type Constructor injection.ControllerConstructor
 
 // NewConstructor creates a constructor to make a Broker controller.
-func NewConstructor(brokerdeliveryss *brokerdelivery.StoreSingleton, dataresidencyss *dataresidency.StoreSingleton) Constructor {
+func NewConstructor(brokerdeliveryss *brokerdelivery.StoreSingleton, dataresidencyss *dataresidency.StoreSingleton, encryptionss *encryption.StoreSingleton) Constructor {
 	return func(ctx context.Context, cmw configmap.Watcher) *controller.Impl {
-		return newController(ctx, cmw, brokerdeliveryss.Store(ctx, cmw), dataresidencyss.Store(ctx, cmw))
+		return newController(ctx, cmw, brokerdeliveryss.Store(ctx, cmw), dataresidencyss.Store(ctx, cmw), encryptionss.Store(ctx, cmw))
 	}
 }
 
-func newController(ctx context.Context, cmw configmap.Watcher, brds *brokerdelivery.Store, drs *dataresidency.Store) *controller.Impl {
+func newController(ctx context.Context, cmw configmap.Watcher, brds *brokerdelivery.Store, drs *dataresidency.Store, es *encryption.Store) *controller.Impl {
 	brokerInformer := brokerinformer.Get(ctx)
 	bcInformer := brokercellinformer.Get(ctx)
+	eventTypeInformer := eventtypeinformer.Get(ctx)
 
 	var client *pubsub.Client
 	// If there is an error, the projectID will be empty. The reconciler will retry
@@ -92,7 +101,13 @@ func newController(ctx context.Context, cmw configmap.Watcher, brds *brokerdeliv
 			BrokerCellLister:   bcInformer.Lister(),
 			PubsubClient:       client,
 			DataresidencyStore: drs,
+			EncryptionStore:    es,
 		},
+		// Discovered EventTypes are reconciled with the Broker when the event type reports change.
+		eventTypeLister:   eventTypeInformer.Lister(),
+		configMapLister:   configmapinformer.Get(ctx).Lister(),
+		eventingClientSet: eventingclient.Get(ctx),
+		namespaceLister:   namespaceinformer.Get(ctx).Lister(),
 	}
 
 	impl := brokerreconciler.NewImpl(ctx, r, brokerv1.BrokerClass,
@@ -131,5 +146,56 @@ func newController(ctx context.Context, cmw configmap.Watcher, brds *brokerdeliv
 		},
 	))
 
+	// Watch namespaces, which allow the buckets that the Brokers archive their events to.
+	namespaceinformer.Get(ctx).Informer().AddEventHandler(controller.HandleAll(
+		func(obj interface{}) {
+			if ns, ok := obj.(*corev1.Namespace); ok {
+				brokers, err := brokerInformer.Lister().Brokers(ns.Name).List(labels.Everything())
+				if err != nil {
+					r.Logger.Error("Failed to list brokers", zap.Error(err))
+					return
+				}
+				for _, broker := range brokers {
+					impl.Enqueue(broker)
+				}
+			}
+		},
+	))
+
+	// Recreate or restore the discovered EventTypes if they are changed.
+	eventTypeInformer.Informer().AddEventHandler(cache.FilteringResourceEventHandler{
+		FilterFunc: controller.FilterControllerGK(brokerv1.Kind("Broker")),
+		Handler:    controller.HandleAll(impl.EnqueueControllerOf),
+	})
+
+	// Reconcile the Brokers named in the changed event type reports, so that newly observed event
+	// types are discovered without waiting for the resync.
+	enqueueReported := func(old, new *corev1.ConfigMap) {
+		for _, key := range changedReportBrokers(old, new) {
+			impl.EnqueueKey(key)
+		}
+	}
+	configmapinformer.Get(ctx).Informer().AddEventHandler(cache.FilteringResourceEventHandler{
+		FilterFunc: controller.FilterWithNameAndNamespace(system.Namespace(), eventtype.ReportsName),
+		Handler: cache.ResourceEventHandlerFuncs{
+			AddFunc: func(obj interface{}) {
+				if cm, ok := obj.(*corev1.ConfigMap); ok {
+					enqueueReported(nil, cm)
+				}
+			},
+			UpdateFunc: func(oldObj, newObj interface{}) {
+				old, _ := oldObj.(*corev1.ConfigMap)
+				if cm, ok := newObj.(*corev1.ConfigMap); ok {
+					enqueueReported(old, cm)
+				}
+			},
+			DeleteFunc: func(obj interface{}) {
+				if cm, ok := obj.(*corev1.ConfigMap); ok {
+					enqueueReported(cm, nil)
+				}
+			},
+		},
+	})
+
 	return impl
 }
@@ -21,6 +21,7 @@ import (
 
 	"github.com/google/knative-gcp/pkg/apis/configs/brokerdelivery"
 	"github.com/google/knative-gcp/pkg/apis/configs/dataresidency"
+	"github.com/google/knative-gcp/pkg/apis/configs/encryption"
 	. "github.com/google/knative-gcp/pkg/reconciler/testing"
 
 	corev1 "k8s.io/api/core/v1"
@@ -33,14 +34,17 @@ import (
 	tracingconfig "knative.dev/pkg/tracing/config"
 
 	// Fake injection informers
+	_ "github.com/google/knative-gcp/pkg/client/injection/eventing/informers/eventing/v1beta1/eventtype/fake"
 	_ "github.com/google/knative-gcp/pkg/client/injection/informers/broker/v1/broker/fake"
 	_ "github.com/google/knative-gcp/pkg/client/injection/informers/intevents/v1alpha1/brokercell/fake"
+	_ "knative.dev/pkg/client/injection/kube/informers/core/v1/configmap/fake"
+	_ "knative.dev/pkg/client/injection/kube/informers/core/v1/namespace/fake"
 )
 
 func TestNew(t *testing.T) {
 	ctx, _ := SetupFakeContext(t)
 
-	c := NewConstructor(&brokerdelivery.StoreSingleton{}, &dataresidency.StoreSingleton{})(ctx, configmap.NewStaticWatcher(
+	c := NewConstructor(&brokerdelivery.StoreSingleton{}, &dataresidency.StoreSingleton{}, &encryption.StoreSingleton{})(ctx, configmap.NewStaticWatcher(
 		&corev1.ConfigMap{
 			ObjectMeta: metav1.ObjectMeta{
 				Name:      logging.ConfigMapName(),
@@ -64,6 +68,7 @@ func TestNew(t *testing.T) {
 		},
 		NewBrokerDeliveryConfigMapFromDeliverySpec(nil),
 		NewDataresidencyConfigMapFromRegions([]string{}),
+		NewEncryptionConfigMapFromKMSKeyName(""),
 	))
 
 	if c == nil {
@@ -0,0 +1,213 @@
+/*
+Copyright 2021 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package broker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/cache"
+	eventingv1beta1 "knative.dev/eventing/pkg/apis/eventing/v1beta1"
+	"knative.dev/pkg/system"
+
+	brokerv1 "github.com/google/knative-gcp/pkg/apis/broker/v1"
+	"github.com/google/knative-gcp/pkg/broker/config"
+	"github.com/google/knative-gcp/pkg/broker/eventtype"
+	"github.com/google/knative-gcp/pkg/logging"
+	"github.com/google/knative-gcp/pkg/reconciler/broker/resources"
+)
+
+const (
+	// maxDiscoveredEventTypes bounds the number of EventTypes discovered for a single Broker. The
+	// most frequently observed event types are kept.
+	maxDiscoveredEventTypes = 100
+	// maxTopEventTypes is the number of event types listed in the Broker status.
+	maxTopEventTypes = 10
+)
+
+// reconcileEventTypes discovers EventTypes from the event types the ingress observed on the
+// Broker. It creates or updates an EventType for each event type observed within the TTL, deletes
+// the discovered EventTypes that have not been observed since, and lists the most frequently
+// observed types in the Broker status.
+func (r *Reconciler) reconcileEventTypes(ctx context.Context, b *brokerv1.Broker) error {
+	observed, err := r.observedEventTypes(ctx, b)
+	if err != nil {
+		return err
+	}
+
+	existing, err := r.eventTypeLister.EventTypes(b.Namespace).List(labels.SelectorFromSet(resources.DiscoveredEventTypeLabels(b)))
+	if err != nil {
+		return fmt.Errorf("failed to list EventTypes: %w", err)
+	}
+	stale := make(map[string]*eventingv1beta1.EventType, len(existing))
+	for _, et := range existing {
+		if metav1.IsControlledBy(et, b) {
+			stale[et.Name] = et
+		}
+	}
+
+	var errs error
+	for _, o := range observed {
+		desired := resources.MakeEventType(b, o.Tuple)
+		delete(stale, desired.Name)
+		errs = multierr.Append(errs, r.reconcileEventType(ctx, b, desired))
+	}
+
+	names := make([]string, 0, len(stale))
+	for name := range stale {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		err := r.eventingClientSet.EventingV1beta1().EventTypes(b.Namespace).Delete(ctx, name, metav1.DeleteOptions{})
+		if err != nil && !apierrs.IsNotFound(err) {
+			errs = multierr.Append(errs, fmt.Errorf("failed to delete EventType %s: %w", name, err))
+		}
+	}
+
+	b.Status.SetTopEventTypes(topEventTypes(observed))
+	return errs
+}
+
+// observedEventTypes merges the event types observed on the Broker from the reports of all ingress
+// Pods.
+func (r *Reconciler) observedEventTypes(ctx context.Context, b *brokerv1.Broker) ([]eventtype.Observation, error) {
+	cm, err := r.configMapLister.ConfigMaps(system.Namespace()).Get(eventtype.ReportsName)
+	if apierrs.IsNotFound(err) {
+		// The BrokerCell controller has not created the report ConfigMap yet, so nothing was observed.
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get event type reports: %w", err)
+	}
+	reports, err := eventtype.ReportsFromConfigMap(cm)
+	if err != nil {
+		logging.FromContext(ctx).Warn("Ignoring malformed event type reports", zap.Error(err))
+	}
+	observed := eventtype.Merge(reports, config.KeyFromBroker(b).PersistenceString(), time.Now().Add(-eventtype.TTL))
+	if len(observed) > maxDiscoveredEventTypes {
+		observed = observed[:maxDiscoveredEventTypes]
+	}
+	return observed, nil
+}
+
+// reconcileEventType creates the desired EventType, or updates it if its spec changed. EventTypes
+// with the same name that are not controlled by the Broker are left alone.
+func (r *Reconciler) reconcileEventType(ctx context.Context, b *brokerv1.Broker, desired *eventingv1beta1.EventType) error {
+	client := r.eventingClientSet.EventingV1beta1().EventTypes(desired.Namespace)
+	current, err := r.eventTypeLister.EventTypes(desired.Namespace).Get(desired.Name)
+	if apierrs.IsNotFound(err) {
+		if _, err := client.Create(ctx, desired, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("failed to create EventType %s: %w", desired.Name, err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get EventType %s: %w", desired.Name, err)
+	}
+	if !metav1.IsControlledBy(current, b) {
+		logging.FromContext(ctx).Debug("Skipping EventType not controlled by the Broker", zap.String("eventType", desired.Name))
+		return nil
+	}
+	if equality.Semantic.DeepEqual(current.Spec, desired.Spec) {
+		return nil
+	}
+	updated := current.DeepCopy()
+	updated.Spec = desired.Spec
+	if _, err := client.Update(ctx, updated, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to update EventType %s: %w", desired.Name, err)
+	}
+	return nil
+}
+
+// topEventTypes returns the most frequently observed event types, summing the counts of the
+// observations of the same type from different sources.
+func topEventTypes(observed []eventtype.Observation) []string {
+	counts := make(map[string]int64)
+	for _, o := range observed {
+		counts[o.Type] += o.Count
+	}
+	types := make([]string, 0, len(counts))
+	for t := range counts {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool {
+		if counts[types[i]] != counts[types[j]] {
+			return counts[types[i]] > counts[types[j]]
+		}
+		return types[i] < types[j]
+	})
+	if len(types) > maxTopEventTypes {
+		types = types[:maxTopEventTypes]
+	}
+	return types
+}
+
+// changedReportBrokers returns the Brokers named in the reports that differ between the old and the
+// new report ConfigMaps, either of which may be nil. Reports of other cell tenants are skipped.
+func changedReportBrokers(old, new *corev1.ConfigMap) []types.NamespacedName {
+	var oldData, newData map[string]string
+	if old != nil {
+		oldData = old.Data
+	}
+	if new != nil {
+		newData = new.Data
+	}
+	keys := sets.NewString()
+	addKeys := func(data string) {
+		r := &eventtype.Report{}
+		if err := json.Unmarshal([]byte(data), r); err != nil {
+			return
+		}
+		for key := range r.Brokers {
+			keys.Insert(key)
+		}
+	}
+	for name, data := range newData {
+		if oldData[name] != data {
+			addKeys(data)
+			addKeys(oldData[name])
+		}
+	}
+	for name, data := range oldData {
+		if _, ok := newData[name]; !ok {
+			addKeys(data)
+		}
+	}
+	brokers := make([]types.NamespacedName, 0, keys.Len())
+	for _, key := range keys.List() {
+		// Only the persistence strings of Brokers have the namespace/name form.
+		ns, name, err := cache.SplitMetaNamespaceKey(key)
+		if err != nil || ns == "" {
+			continue
+		}
+		brokers = append(brokers, types.NamespacedName{Namespace: ns, Name: name})
+	}
+	return brokers
+}
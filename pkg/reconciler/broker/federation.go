@@ -0,0 +1,219 @@
+/*
+Copyright 2021 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package broker
+
+import (
+	"context"
+	"errors"
+
+	"cloud.google.com/go/iam"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"knative.dev/pkg/system"
+
+	brokerv1 "github.com/google/knative-gcp/pkg/apis/broker/v1"
+	giam "github.com/google/knative-gcp/pkg/gclient/iam"
+	"github.com/google/knative-gcp/pkg/logging"
+	"github.com/google/knative-gcp/pkg/reconciler/broker/resources"
+	"github.com/google/knative-gcp/pkg/reconciler/celltenant"
+	"github.com/google/knative-gcp/pkg/reconciler/identity"
+	"github.com/google/knative-gcp/pkg/utils"
+	"github.com/google/knative-gcp/pkg/utils/authcheck"
+)
+
+// subscriberRole is the role granted on the decouple topic of a Broker to the members allowed to
+// federate its events, which allows attaching subscriptions to it.
+const subscriberRole iam.RoleName = "roles/pubsub.subscriber"
+
+// federationLink is a federated topic and the subscription of the Broker to it.
+type federationLink struct {
+	topic        brokerv1.FederatedTopic
+	subscription string
+}
+
+// federationLinks returns the links of the Broker to the decouple topics of the remote Brokers it
+// federates, and the topics it is not allowed to federate. In the project of the cluster, the
+// control plane can subscribe to any topic, so only the decouple topics of the Brokers in the
+// namespace of the Broker are allowed. Invalid annotations are rejected by the webhook and are
+// ignored here.
+func federationLinks(b *brokerv1.Broker, projectID string) ([]federationLink, []brokerv1.FederatedTopic) {
+	topics, _ := b.FederatedTopics()
+	ownTopic := brokerv1.FederatedTopic{Project: projectID, Topic: resources.GenerateDecouplingTopicName(b)}
+	links := make([]federationLink, 0, len(topics))
+	var rejected []brokerv1.FederatedTopic
+	for _, t := range topics {
+		// Federating the Broker's own topic would deliver every event twice.
+		if t == ownTopic {
+			continue
+		}
+		if t.Project == projectID && !resources.IsDecouplingTopicInNamespace(t.Topic, b.Namespace) {
+			rejected = append(rejected, t)
+			continue
+		}
+		links = append(links, federationLink{topic: t, subscription: resources.GenerateFederationSubscriptionName(b, t)})
+	}
+	return links, rejected
+}
+
+// reconcileFederation creates the subscriptions to the decouple topics of the remote Brokers that
+// the Broker federates, and deletes the subscriptions to the topics it no longer federates. The
+// subscriptions are listed in the status annotations, so that they are known once the annotation
+// of the Broker changes, along with the federated topics the BrokerCell delivers the events of.
+// The subscriptions can only be attached once the remote Brokers allow the control plane.
+func (r *Reconciler) reconcileFederation(ctx context.Context, b *brokerv1.Broker, bcs celltenant.Statusable) error {
+	projectID, err := utils.ProjectIDOrDefault(r.Reconciler.ProjectID)
+	if err != nil {
+		return err
+	}
+	links, rejected := federationLinks(b, projectID)
+	for _, t := range rejected {
+		r.Recorder.Eventf(b, corev1.EventTypeWarning, "FederatedTopicNotAllowed", "Topic %s is in the project of the cluster but is not the decouple topic of a Broker in namespace %q, it is not federated", t, b.Namespace)
+	}
+	existing := b.Status.FederationSubscriptions()
+	wanted := make(map[string]bool, len(links))
+	subscriptions := make([]string, 0, len(links))
+	topics := make([]brokerv1.FederatedTopic, 0, len(links))
+	for _, l := range links {
+		wanted[l.subscription] = true
+		subscriptions = append(subscriptions, l.subscription)
+		topics = append(topics, l.topic)
+	}
+	// List the subscriptions before creating them, so that they are deleted even if the
+	// reconciliation fails half way.
+	listed := append([]string(nil), subscriptions...)
+	for _, s := range existing {
+		if !wanted[s] {
+			listed = append(listed, s)
+		}
+	}
+	b.Status.SetFederationSubscriptions(listed)
+
+	for _, l := range links {
+		if err := r.Reconciler.ReconcileFederationSubscription(ctx, bcs, l.subscription, l.topic.Project, l.topic.Topic); err != nil {
+			if l.topic.Project != projectID {
+				r.reportFederationMember(ctx, b, l.topic)
+			}
+			return err
+		}
+	}
+	for _, s := range existing {
+		if wanted[s] {
+			continue
+		}
+		if err := r.Reconciler.DeleteSubscription(ctx, bcs, s); err != nil {
+			return err
+		}
+	}
+	b.Status.SetFederationSubscriptions(subscriptions)
+	b.Status.SetFederatedTopics(topics)
+	return nil
+}
+
+// reportFederationMember reports the IAM member of the control plane that the remote Broker of the
+// topic must allow, once subscribing to the topic of another project failed. The subscriptions
+// are created by the control plane, whose Google service account is only granted the permission
+// to attach them by the remote Broker. The control plane doesn't grant itself the permission.
+func (r *Reconciler) reportFederationMember(ctx context.Context, b *brokerv1.Broker, t brokerv1.FederatedTopic) {
+	member, err := identity.WorkloadIdentityMember(ctx, r.KubeClientSet, system.Namespace(), authcheck.ControllerServiceAccountName)
+	if err != nil {
+		logging.FromContext(ctx).Warn("Failed to get the identity of the control plane", zap.Error(err))
+	}
+	if member == "" {
+		// The control plane doesn't use Workload Identity, its credentials are only known to the user.
+		member = "the Google service account of the control plane"
+	}
+	r.Recorder.Eventf(b, corev1.EventTypeWarning, "FederationMemberRequired", "Failed to subscribe to topic %s, the remote Broker must list %s in its %s annotation", t, member, brokerv1.FederateToAnnotationKey)
+}
+
+// deleteFederationSubscriptions deletes all the subscriptions of the Broker to the decouple topics
+// of remote Brokers.
+func (r *Reconciler) deleteFederationSubscriptions(ctx context.Context, b *brokerv1.Broker, bcs celltenant.Statusable) error {
+	subscriptions := b.Status.FederationSubscriptions()
+	for _, t := range b.Status.FederatedTopics() {
+		subscriptions = append(subscriptions, resources.GenerateFederationSubscriptionName(b, t))
+	}
+	deleted := make(map[string]bool, len(subscriptions))
+	for _, s := range subscriptions {
+		if deleted[s] {
+			continue
+		}
+		if err := r.Reconciler.DeleteSubscription(ctx, bcs, s); err != nil {
+			return err
+		}
+		deleted[s] = true
+	}
+	return nil
+}
+
+// reconcileFederationMembers grants the subscriber role on the decouple topic of the Broker to the
+// members allowed to federate its events, and revokes it from the members no longer allowed. The
+// granted members are listed in the status annotations, so that the IAM policy of the topic is
+// only read for the Brokers whose events are federated.
+func (r *Reconciler) reconcileFederationMembers(ctx context.Context, b *brokerv1.Broker) error {
+	// Invalid annotations are rejected by the webhook, so an error here means no member is allowed.
+	members, _ := b.FederationMembers()
+	granted := b.Status.FederationMembers()
+	if len(members) == 0 && len(granted) == 0 {
+		return nil
+	}
+	projectID, err := utils.ProjectIDOrDefault(r.Reconciler.ProjectID)
+	if err != nil {
+		return err
+	}
+	h, err := r.topicIAMHandle(projectID, resources.GenerateDecouplingTopicName(b))
+	if err != nil {
+		return err
+	}
+	policy, err := h.Policy(ctx)
+	if err != nil {
+		return err
+	}
+	wanted := sets.NewString(members...)
+	changed := false
+	for _, m := range granted {
+		if !wanted.Has(m) && policy.HasRole(m, subscriberRole) {
+			policy.Remove(m, subscriberRole)
+			changed = true
+		}
+	}
+	for _, m := range members {
+		if !policy.HasRole(m, subscriberRole) {
+			policy.Add(m, subscriberRole)
+			changed = true
+		}
+	}
+	if changed {
+		if err := h.SetPolicy(ctx, policy); err != nil {
+			return err
+		}
+	}
+	b.Status.SetFederationMembers(members)
+	return nil
+}
+
+// topicIAMHandle returns the IAM handle of the Pub/Sub topic of the given project.
+func (r *Reconciler) topicIAMHandle(project, topic string) (giam.Handle, error) {
+	if r.topicIAM != nil {
+		return r.topicIAM(project, topic), nil
+	}
+	client := r.Reconciler.PubsubClient
+	if client == nil {
+		return nil, errors.New("Pub/Sub client is not available")
+	}
+	return giam.NewIamHandle(client.TopicInProject(topic, project).IAM()), nil
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2021 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	"crypto/sha256"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/eventing/pkg/apis/eventing"
+	eventingv1beta1 "knative.dev/eventing/pkg/apis/eventing/v1beta1"
+	"knative.dev/pkg/apis"
+	"knative.dev/pkg/kmeta"
+
+	brokerv1 "github.com/google/knative-gcp/pkg/apis/broker/v1"
+	"github.com/google/knative-gcp/pkg/broker/eventtype"
+)
+
+// DiscoveredEventTypeLabelKey labels the EventTypes that were discovered from the events sent to a
+// Broker. Only those are managed by the Broker controller.
+const DiscoveredEventTypeLabelKey = "events.cloud.google.com/discovered"
+
+// DiscoveredEventTypeLabels are the labels of the EventTypes discovered on the Broker.
+func DiscoveredEventTypeLabels(b *brokerv1.Broker) map[string]string {
+	return map[string]string{
+		eventing.BrokerLabelKey:     b.Name,
+		DiscoveredEventTypeLabelKey: "true",
+	}
+}
+
+// MakeEventType creates the EventType of an event type observed on the Broker. Its name is derived
+// from the event type, so that each event type maps to a single EventType.
+func MakeEventType(b *brokerv1.Broker, t eventtype.Tuple) *eventingv1beta1.EventType {
+	return &eventingv1beta1.EventType{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       b.Namespace,
+			Name:            EventTypeName(b, t),
+			OwnerReferences: []metav1.OwnerReference{*kmeta.NewControllerRef(b)},
+			Labels:          DiscoveredEventTypeLabels(b),
+		},
+		Spec: eventingv1beta1.EventTypeSpec{
+			Type:        t.Type,
+			Source:      parseURL(t.Source),
+			Schema:      parseURL(t.Schema),
+			Broker:      b.Name,
+			Description: fmt.Sprintf("Discovered from the events sent to Broker %s", b.Name),
+		},
+	}
+}
+
+// EventTypeName is the name of the EventType of an event type observed on the Broker.
+func EventTypeName(b *brokerv1.Broker, t eventtype.Tuple) string {
+	h := sha256.Sum256([]byte(t.Type + "\x00" + t.Source + "\x00" + t.Schema))
+	return kmeta.ChildName(b.Name, fmt.Sprintf("-%x", h[:8]))
+}
+
+// parseURL parses an event attribute as URL. Attributes that are not valid URLs are left out.
+func parseURL(s string) *apis.URL {
+	if s == "" {
+		return nil
+	}
+	u, err := apis.ParseURL(s)
+	if err != nil {
+		return nil
+	}
+	return u
+}
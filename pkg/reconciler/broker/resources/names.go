@@ -17,6 +17,10 @@ limitations under the License.
 package resources
 
 import (
+	"fmt"
+	"hash/fnv"
+	"strings"
+
 	brokerv1 "github.com/google/knative-gcp/pkg/apis/broker/v1"
 	"github.com/google/knative-gcp/pkg/utils/naming"
 )
@@ -40,6 +44,13 @@ func GenerateDecouplingTopicName(b *brokerv1.Broker) string {
 	return naming.TruncatedPubsubResourceName("cre-bkr", b.Namespace, b.Name, b.UID)
 }
 
+// IsDecouplingTopicInNamespace returns whether the topic is the decoupling
+// topic of a Broker in the namespace. The namespace is never truncated and
+// can't contain the separator, so it is unambiguous.
+func IsDecouplingTopicInNamespace(topic, namespace string) bool {
+	return strings.HasPrefix(topic, "cre-bkr_"+namespace+"_")
+}
+
 // GenerateDecouplingSubscriptionName generates a deterministic subscription
 // name for a Broker. If the subscription name would be longer than allowed by
 // PubSub, the Broker name is truncated to fit.
@@ -60,3 +71,52 @@ func GenerateRetryTopicName(t *brokerv1.Trigger) string {
 func GenerateRetrySubscriptionName(t *brokerv1.Trigger) string {
 	return naming.TruncatedPubsubResourceName("cre-tgr", t.Namespace, t.Name, t.UID)
 }
+
+// GenerateConsolidatedRetryTopicName generates a deterministic name for the
+// retry topic shared by the Triggers of a Broker. If the topic name would be
+// longer than allowed by PubSub, the Broker name is truncated to fit.
+func GenerateConsolidatedRetryTopicName(b *brokerv1.Broker) string {
+	return naming.TruncatedPubsubResourceName("cre-bkr-rty", b.Namespace, b.Name, b.UID)
+}
+
+// GenerateConsolidatedRetrySubscriptionName generates a deterministic name
+// for the subscription of a Trigger to the retry topic of its Broker. If the
+// subscription name would be longer than allowed by PubSub, the Trigger name
+// is truncated to fit.
+func GenerateConsolidatedRetrySubscriptionName(t *brokerv1.Trigger) string {
+	return naming.TruncatedPubsubResourceName("cre-tgr-rty", t.Namespace, t.Name, t.UID)
+}
+
+// GenerateArchiveSubscriptionName generates a deterministic name for the
+// subscription to the decoupling topic of a Broker from which its events are
+// archived. If the subscription name would be longer than allowed by PubSub,
+// the Broker name is truncated to fit.
+func GenerateArchiveSubscriptionName(b *brokerv1.Broker) string {
+	return naming.TruncatedPubsubResourceName("cre-bkr-arc", b.Namespace, b.Name, b.UID)
+}
+
+// GenerateDelayTopicName generates a deterministic name for the topic the
+// events of a Broker scheduled for a later delivery wait in. If the topic name
+// would be longer than allowed by PubSub, the Broker name is truncated to fit.
+func GenerateDelayTopicName(b *brokerv1.Broker) string {
+	return naming.TruncatedPubsubResourceName("cre-bkr-dly", b.Namespace, b.Name, b.UID)
+}
+
+// GenerateDelaySubscriptionName generates a deterministic name for the
+// subscription to the delay topic of a Broker. If the subscription name would
+// be longer than allowed by PubSub, the Broker name is truncated to fit.
+func GenerateDelaySubscriptionName(b *brokerv1.Broker) string {
+	return naming.TruncatedPubsubResourceName("cre-bkr-dly", b.Namespace, b.Name, b.UID)
+}
+
+// GenerateFederationSubscriptionName generates a deterministic name for the
+// subscription to the decouple topic of a remote Broker whose events are
+// delivered to the Triggers of a Broker. The prefix includes a hash of the
+// topic, so that the Broker has a subscription per topic. If the subscription
+// name would be longer than allowed by PubSub, the Broker name is truncated to
+// fit.
+func GenerateFederationSubscriptionName(b *brokerv1.Broker, t brokerv1.FederatedTopic) string {
+	h := fnv.New32a()
+	h.Write([]byte(t.String()))
+	return naming.TruncatedPubsubResourceName(fmt.Sprintf("cre-bkr-fed-%08x", h.Sum32()), b.Namespace, b.Name, b.UID)
+}
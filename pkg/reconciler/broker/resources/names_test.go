@@ -196,6 +196,128 @@ func TestGenerateRetrySubscriptionName(t *testing.T) {
 	}
 }
 
+func TestGenerateConsolidatedRetryTopicName(t *testing.T) {
+	testCases := []struct {
+		ns   string
+		n    string
+		uid  string
+		want string
+	}{{
+		ns:   "default",
+		n:    "default",
+		uid:  testUID,
+		want: fmt.Sprintf("cre-bkr-rty_default_default_%s", testUID),
+	}, {
+		ns:   maxNamespace,
+		n:    maxName,
+		uid:  testUID,
+		want: fmt.Sprintf("cre-bkr-rty_%s_%s_%s", maxNamespace, strings.Repeat("n", truncatedNameMaxForBkrTgr-4), testUID),
+	}}
+
+	for _, tc := range testCases {
+		got := GenerateConsolidatedRetryTopicName(broker(tc.ns, tc.n, tc.uid))
+		if len(got) > naming.PubsubMax {
+			t.Errorf("name length %d is greater than %d", len(got), naming.PubsubMax)
+		}
+		if diff := cmp.Diff(tc.want, got); diff != "" {
+			t.Errorf("unexpected (want, +got) = %v", diff)
+		}
+	}
+}
+
+func TestGenerateConsolidatedRetrySubscriptionName(t *testing.T) {
+	testCases := []struct {
+		ns   string
+		n    string
+		uid  string
+		want string
+	}{{
+		ns:   "default",
+		n:    "default",
+		uid:  testUID,
+		want: fmt.Sprintf("cre-tgr-rty_default_default_%s", testUID),
+	}, {
+		ns:   maxNamespace,
+		n:    maxName,
+		uid:  testUID,
+		want: fmt.Sprintf("cre-tgr-rty_%s_%s_%s", maxNamespace, strings.Repeat("n", truncatedNameMaxForBkrTgr-4), testUID),
+	}}
+
+	for _, tc := range testCases {
+		got := GenerateConsolidatedRetrySubscriptionName(trigger(tc.ns, tc.n, tc.uid))
+		if len(got) > naming.PubsubMax {
+			t.Errorf("name length %d is greater than %d", len(got), naming.PubsubMax)
+		}
+		if diff := cmp.Diff(tc.want, got); diff != "" {
+			t.Errorf("unexpected (want, +got) = %v", diff)
+		}
+	}
+}
+
+func TestGenerateArchiveSubscriptionName(t *testing.T) {
+	testCases := []struct {
+		ns   string
+		n    string
+		uid  string
+		want string
+	}{{
+		ns:   "default",
+		n:    "default",
+		uid:  testUID,
+		want: fmt.Sprintf("cre-bkr-arc_default_default_%s", testUID),
+	}, {
+		ns:   maxNamespace,
+		n:    maxName,
+		uid:  testUID,
+		want: fmt.Sprintf("cre-bkr-arc_%s_%s_%s", maxNamespace, strings.Repeat("n", truncatedNameMaxForBkrTgr-4), testUID),
+	}}
+
+	for _, tc := range testCases {
+		got := GenerateArchiveSubscriptionName(broker(tc.ns, tc.n, tc.uid))
+		if len(got) > naming.PubsubMax {
+			t.Errorf("name length %d is greater than %d", len(got), naming.PubsubMax)
+		}
+		if diff := cmp.Diff(tc.want, got); diff != "" {
+			t.Errorf("unexpected (want, +got) = %v", diff)
+		}
+	}
+}
+
+func TestGenerateFederationSubscriptionName(t *testing.T) {
+	topic := brokerv1.FederatedTopic{Project: "other-project", Topic: "events"}
+	testCases := []struct {
+		ns   string
+		n    string
+		uid  string
+		want string
+	}{{
+		ns:   "default",
+		n:    "default",
+		uid:  testUID,
+		want: fmt.Sprintf("cre-bkr-fed-ac7148f9_default_default_%s", testUID),
+	}, {
+		ns:   maxNamespace,
+		n:    maxName,
+		uid:  testUID,
+		want: fmt.Sprintf("cre-bkr-fed-ac7148f9_%s_%s_%s", maxNamespace, strings.Repeat("n", truncatedNameMaxForBkrTgr-13), testUID),
+	}}
+
+	for _, tc := range testCases {
+		got := GenerateFederationSubscriptionName(broker(tc.ns, tc.n, tc.uid), topic)
+		if len(got) > naming.PubsubMax {
+			t.Errorf("name length %d is greater than %d", len(got), naming.PubsubMax)
+		}
+		if diff := cmp.Diff(tc.want, got); diff != "" {
+			t.Errorf("unexpected (want, +got) = %v", diff)
+		}
+	}
+
+	other := GenerateFederationSubscriptionName(broker("default", "default", testUID), brokerv1.FederatedTopic{Project: "other-project", Topic: "other"})
+	if other == GenerateFederationSubscriptionName(broker("default", "default", testUID), topic) {
+		t.Errorf("subscriptions to different topics have the same name %q", other)
+	}
+}
+
 func broker(ns, n, uid string) *brokerv1.Broker {
 	return &brokerv1.Broker{
 		ObjectMeta: metav1.ObjectMeta{
@@ -215,3 +337,22 @@ func trigger(ns, n, uid string) *brokerv1.Trigger {
 		},
 	}
 }
+
+func TestIsDecouplingTopicInNamespace(t *testing.T) {
+	topic := GenerateDecouplingTopicName(broker(maxNamespace, maxName, testUID))
+	if !IsDecouplingTopicInNamespace(topic, maxNamespace) {
+		t.Errorf("%q is not the decoupling topic of a Broker in namespace %q", topic, maxNamespace)
+	}
+	for _, tc := range []struct {
+		topic string
+		ns    string
+	}{
+		{topic: GenerateDecouplingTopicName(broker("default", "default", testUID)), ns: "def"},
+		{topic: GenerateArchiveSubscriptionName(broker("default", "default", testUID)), ns: "default"},
+		{topic: "events", ns: "default"},
+	} {
+		if IsDecouplingTopicInNamespace(tc.topic, tc.ns) {
+			t.Errorf("%q is the decoupling topic of a Broker in namespace %q", tc.topic, tc.ns)
+		}
+	}
+}
@@ -24,8 +24,11 @@ import (
 
 	"github.com/google/knative-gcp/pkg/logging"
 	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/known/durationpb"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/client-go/tools/cache"
+	eventingduckv1 "knative.dev/eventing/pkg/apis/duck/v1"
 	"knative.dev/eventing/pkg/apis/eventing"
 
 	brokerv1 "github.com/google/knative-gcp/pkg/apis/broker/v1"
@@ -41,6 +44,12 @@ import (
 
 const (
 	configFailed = "BrokerTargetsConfigFailed"
+
+	// maxTargetsConfigSize is the size limit of a ConfigMap.
+	maxTargetsConfigSize = 1 << 20
+	// targetsConfigSizeWarning is the targets config size over which a warning is emitted, so
+	// that Brokers can be moved to another BrokerCell before reaching the limit.
+	targetsConfigSizeWarning = maxTargetsConfigSize * 8 / 10
 )
 
 func (r *Reconciler) reconcileConfig(ctx context.Context, bc *intv1alpha1.BrokerCell) error {
@@ -60,6 +69,15 @@ func (r *Reconciler) reconcileConfig(ctx context.Context, bc *intv1alpha1.Broker
 		return fmt.Errorf("unable to add Channels to targets: %w", err)
 	}
 
+	bc.Status.SetTenants(countTenants(targets))
+
+	// Reconcile the delivery headers Secret before the config referencing it.
+	if err := r.reconcileDeliveryHeaders(ctx, bc, targets); err != nil {
+		logging.FromContext(ctx).Error("Failed to reconcile delivery headers secret", zap.Error(err))
+		bc.Status.MarkTargetsConfigFailed(configFailed, "failed to reconcile delivery headers secret: %v", err)
+		return err
+	}
+
 	if err := r.updateTargetsConfig(ctx, bc, targets); err != nil {
 		logging.FromContext(ctx).Error("Failed to update broker targets configmap", zap.Error(err))
 		bc.Status.MarkTargetsConfigFailed(configFailed, "failed to update configmap: %v", err)
@@ -94,13 +112,23 @@ func (r *Reconciler) addBrokersAndTriggersToTargets(ctx context.Context, bc *int
 			bc.Status.MarkTargetsConfigFailed(configFailed, "failed to list triggers for broker %v: %v", broker.Name, err)
 			return err
 		}
-		addBrokerAndTriggersToConfig(ctx, broker, triggers, targets)
+		addBrokerAndTriggersToConfig(ctx, broker, brokerAddress(bc, broker), triggers, targets)
 	}
 	return nil
 }
 
+// brokerAddress returns the address the BrokerCell components reach the broker at. When the
+// BrokerCell has an external ingress, the broker status holds the external address while the
+// components keep using the in-cluster ingress.
+func brokerAddress(bc *intv1alpha1.BrokerCell, b *brokerv1.Broker) string {
+	if bc.Status.ExternalIngressTemplate == "" || bc.Status.IngressTemplate == "" {
+		return b.Status.Address.URL.String()
+	}
+	return resources.ExpandIngressTemplate(bc.Status.IngressTemplate, b.Namespace, b.Name)
+}
+
 // addBrokerAndTriggersToConfig reconstructs the data entry for the given broker and adds it to targets-config.
-func addBrokerAndTriggersToConfig(_ context.Context, b *brokerv1.Broker, triggers []*brokerv1.Trigger, brokerTargets config.Targets) {
+func addBrokerAndTriggersToConfig(_ context.Context, b *brokerv1.Broker, address string, triggers []*brokerv1.Trigger, brokerTargets config.Targets) {
 	// TODO Maybe get rid of GCPCellAddressableMutation and add Delete() and Upsert(broker) methods to TargetsConfig. Now we always
 	//  delete or update the entire broker entry and we don't need partial updates per trigger.
 	// The code can be simplified to r.targetsConfig.Upsert(brokerConfigEntry)
@@ -116,17 +144,56 @@ func addBrokerAndTriggersToConfig(_ context.Context, b *brokerv1.Broker, trigger
 		}
 		// Then reconstruct the broker entry and insert it
 		m.SetID(string(b.UID))
-		m.SetAddress(b.Status.Address.URL.String())
+		m.SetAddress(address)
 		m.SetDecoupleQueue(&config.Queue{
 			Topic:        brokerresources.GenerateDecouplingTopicName(b),
 			Subscription: brokerresources.GenerateDecouplingSubscriptionName(b),
 			State:        brokerQueueState,
 		})
+		m.SetDelayQueue(&config.Queue{
+			Topic:        brokerresources.GenerateDelayTopicName(b),
+			Subscription: brokerresources.GenerateDelaySubscriptionName(b),
+			State:        brokerQueueState,
+		})
 		if b.Status.IsReady() {
 			m.SetState(config.State_READY)
 		} else {
 			m.SetState(config.State_UNKNOWN)
 		}
+		// Invalid quota annotations are rejected by the webhook, so an error here means the Broker
+		// predates the quota annotations and no quota is enforced.
+		if eventsPerSecond, bytesInFlight, err := b.IngressQuota(); err == nil && (eventsPerSecond > 0 || bytesInFlight > 0) {
+			m.SetIngressQuota(&config.IngressQuota{
+				EventsPerSecond: eventsPerSecond,
+				BytesInFlight:   bytesInFlight,
+			})
+		}
+		if enabled, err := b.IngressFilteringEnabled(); err == nil {
+			m.SetIngressFilteringEnabled(enabled)
+		}
+		if async, err := b.IngressAsyncPublish(); err == nil {
+			m.SetIngressAsyncPublish(async)
+		}
+		if enabled, err := b.ReplyLoopDetection(); err == nil {
+			m.SetReplyLoopDetection(enabled)
+		}
+		if bucket, prefix := b.Status.Archive(); bucket != "" {
+			m.SetArchive(&config.Archive{
+				Bucket:       bucket,
+				Prefix:       prefix,
+				Subscription: brokerresources.GenerateArchiveSubscriptionName(b),
+			})
+		}
+		// The broker reconciler lists the federated topics in the status once it checked them.
+		var queues []*config.Queue
+		for _, t := range b.Status.FederatedTopics() {
+			queues = append(queues, &config.Queue{
+				Topic:        t.String(),
+				Subscription: brokerresources.GenerateFederationSubscriptionName(b, t),
+				State:        brokerQueueState,
+			})
+		}
+		m.SetFederationQueues(queues...)
 
 		// Insert each Trigger to the config.
 		for _, t := range triggers {
@@ -137,7 +204,7 @@ func addBrokerAndTriggersToConfig(_ context.Context, b *brokerv1.Broker, trigger
 					Namespace:      t.Namespace,
 					CellTenantType: config.CellTenantType_BROKER,
 					CellTenantName: b.Name,
-					ReplyAddress:   b.Status.Address.URL.String(),
+					ReplyAddress:   address,
 					Address:        t.Status.SubscriberURI.String(),
 					RetryQueue: &config.Queue{
 						Topic:        brokerresources.GenerateRetryTopicName(t),
@@ -147,6 +214,18 @@ func addBrokerAndTriggersToConfig(_ context.Context, b *brokerv1.Broker, trigger
 				if t.Spec.Filter != nil && t.Spec.Filter.Attributes != nil {
 					target.FilterAttributes = t.Spec.Filter.Attributes
 				}
+				target.DeadLetterQueue = deadLetterQueue(b.Spec.Delivery)
+				// Invalid classification annotations are rejected by the webhook, so an error
+				// here means the Trigger predates them and the default classification is used.
+				if success, nonRetryable, err := t.ResponseClassification(); err == nil && (len(success) > 0 || len(nonRetryable) > 0) {
+					target.ResponseClassification = &config.ResponseClassification{
+						SuccessCodes:      success,
+						NonRetryableCodes: nonRetryable,
+					}
+				}
+				target.MaxEventAge = maxEventAge(b, t)
+				setDelivery(target, t)
+				setRetryQueues(target, b, t)
 				// TODO(#939) May need to use "data plane readiness" for trigger in stead of the
 				//  overall status, see https://github.com/google/knative-gcp/issues/939#issuecomment-644337937
 				if t.Status.IsReady() {
@@ -231,6 +310,7 @@ func addChannelToConfig(_ context.Context, c *v1beta1.Channel, targets config.Ta
 					Topic:        channelresources.GenerateSubscriberRetryTopicName(c, s.UID),
 					Subscription: channelresources.GenerateSubscriberRetrySubscriptionName(c, s.UID),
 				},
+				DeadLetterQueue: deadLetterQueue(s.Delivery),
 				// TODO(#939) May need to use "data plane readiness" for trigger in stead of the
 				//  overall status, see https://github.com/google/knative-gcp/issues/939#issuecomment-644337937
 				State: config.State_READY,
@@ -240,6 +320,75 @@ func addChannelToConfig(_ context.Context, c *v1beta1.Channel, targets config.Ta
 	})
 }
 
+// deadLetterQueue returns the Pub/Sub topic of the dead letter sink of the delivery spec, if any.
+func deadLetterQueue(spec *eventingduckv1.DeliverySpec) *config.Queue {
+	if spec == nil || spec.DeadLetterSink == nil || spec.DeadLetterSink.URI == nil || spec.DeadLetterSink.URI.Scheme != "pubsub" {
+		return nil
+	}
+	return &config.Queue{Topic: spec.DeadLetterSink.URI.Host}
+}
+
+// maxEventAge returns the max event age of the Trigger's target. The Trigger's overrides the
+// Broker's. Invalid annotations are rejected by the webhook and are ignored here.
+func maxEventAge(b *brokerv1.Broker, t *brokerv1.Trigger) *durationpb.Duration {
+	age, err := t.MaxEventAge()
+	if err != nil || age == 0 {
+		if age, err = b.MaxEventAge(); err != nil || age == 0 {
+			return nil
+		}
+	}
+	return durationpb.New(age)
+}
+
+// setRetryQueues points the Trigger's target to the retry topic of the Broker if the Broker
+// consolidates the retries of its Triggers, and sets the retry subscription still being drained
+// after the retry topic mode changed.
+func setRetryQueues(target *config.Target, b *brokerv1.Broker, t *brokerv1.Trigger) {
+	if b.ConsolidatedRetryTopic() {
+		target.RetryQueue = &config.Queue{
+			Topic:        brokerresources.GenerateConsolidatedRetryTopicName(b),
+			Subscription: brokerresources.GenerateConsolidatedRetrySubscriptionName(t),
+		}
+	}
+	if sub, _, ok := t.Status.DrainingRetrySubscription(); ok && sub != target.RetryQueue.Subscription {
+		target.DrainingRetryQueue = &config.Queue{Subscription: sub}
+	}
+}
+
+// setDelivery sets the delivery format, headers, Pub/Sub and BigQuery options of the Trigger's
+// target.
+// Invalid annotations are rejected by the webhook and are ignored here.
+func setDelivery(target *config.Target, t *brokerv1.Trigger) {
+	switch format, _ := t.DeliveryFormat(); format {
+	case brokerv1.DeliveryFormatBinary:
+		target.DeliveryFormat = config.DeliveryFormat_BINARY
+	case brokerv1.DeliveryFormatStructured:
+		target.DeliveryFormat = config.DeliveryFormat_STRUCTURED
+	}
+	if orderingKey, attributes, err := t.PubsubDelivery(); err == nil && (orderingKey != "" || len(attributes) > 0) {
+		target.PubsubDelivery = &config.PubsubDelivery{
+			OrderingKeyAttribute: orderingKey,
+			AttributeMapping:     attributes,
+		}
+	}
+	if ignore, err := t.BigQueryIgnoreUnknownValues(); err == nil && ignore {
+		target.BigqueryDelivery = &config.BigQueryDelivery{IgnoreUnknownValues: true}
+	}
+	static, secrets, err := t.DeliveryHeaders()
+	if err != nil {
+		return
+	}
+	if len(static) > 0 {
+		target.DeliveryHeaders = static
+	}
+	for header, ref := range secrets {
+		if target.DeliveryHeaderSecrets == nil {
+			target.DeliveryHeaderSecrets = make(map[string]string, len(secrets))
+		}
+		target.DeliveryHeaderSecrets[header] = resources.DeliveryHeaderSecretKey(t.Namespace, ref.Name, ref.Key)
+	}
+}
+
 //TODO all this stuff should be in a configmap variant of the config object
 func (r *Reconciler) updateTargetsConfig(ctx context.Context, bc *intv1alpha1.BrokerCell, brokerTargets config.Targets) error {
 	desired, err := resources.MakeTargetsConfig(bc, brokerTargets)
@@ -254,10 +403,36 @@ func (r *Reconciler) updateTargetsConfig(ctx context.Context, bc *intv1alpha1.Br
 		UpdateFunc: func(oldObj, newObj interface{}) { r.refreshPodVolume(ctx, bc) },
 		DeleteFunc: nil,
 	}
+	// The size is recorded before the update, so that it is reported when the ConfigMap grows too
+	// large to be updated. The warning is only emitted when the size crosses the threshold.
+	previousSize := bc.Status.TargetsConfigSize
+	size := resources.TargetsConfigSize(desired)
+	bc.Status.SetTargetsConfigSize(size)
+	if size > targetsConfigSizeWarning && previousSize <= targetsConfigSizeWarning {
+		r.Recorder.Eventf(bc, corev1.EventTypeWarning, "TargetsConfigSizeHigh", "The targets config is %d bytes, approaching the limit of %d bytes", size, maxTargetsConfigSize)
+	}
+
 	_, err = r.cmRec.ReconcileConfigMap(ctx, bc, desired, resources.TargetsConfigMapEqual, handlerFuncs)
 	return err
 }
 
+// countTenants counts the Brokers and Channels in the targets config, along with their targets.
+func countTenants(targets config.Targets) intv1alpha1.TenantsStatus {
+	var tenants intv1alpha1.TenantsStatus
+	targets.RangeCellTenants(func(t *config.CellTenant) bool {
+		switch t.Type {
+		case config.CellTenantType_BROKER:
+			tenants.Brokers++
+			tenants.Triggers += int32(len(t.Targets))
+		case config.CellTenantType_CHANNEL:
+			tenants.Channels++
+			tenants.Subscribers += int32(len(t.Targets))
+		}
+		return true
+	})
+	return tenants
+}
+
 func (r *Reconciler) refreshPodVolume(ctx context.Context, bc *intv1alpha1.BrokerCell) {
 	if err := volume.UpdateVolumeGeneration(ctx, r.KubeClientSet, r.podLister, bc.Namespace, resources.CommonLabels(bc.Name)); err != nil {
 		// Failing to update the annotation on the data plane pods means there
@@ -24,8 +24,10 @@ import (
 	"go.uber.org/zap"
 
 	channellisters "github.com/google/knative-gcp/pkg/client/listers/messaging/v1beta1"
+	appsv1 "k8s.io/api/apps/v1"
 	hpav2beta2 "k8s.io/api/autoscaling/v2beta2"
 	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
 	"k8s.io/apimachinery/pkg/api/equality"
 	apierrs "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -33,6 +35,7 @@ import (
 	appsv1listers "k8s.io/client-go/listers/apps/v1"
 	hpav2beta2listers "k8s.io/client-go/listers/autoscaling/v2beta2"
 	corev1listers "k8s.io/client-go/listers/core/v1"
+	policyv1beta1listers "k8s.io/client-go/listers/policy/v1beta1"
 	"knative.dev/pkg/network"
 
 	pkgreconciler "knative.dev/pkg/reconciler"
@@ -40,10 +43,12 @@ import (
 	intv1alpha1 "github.com/google/knative-gcp/pkg/apis/intevents/v1alpha1"
 	bcreconciler "github.com/google/knative-gcp/pkg/client/injection/reconciler/intevents/v1alpha1/brokercell"
 	brokerlisters "github.com/google/knative-gcp/pkg/client/listers/broker/v1"
+	metadataClient "github.com/google/knative-gcp/pkg/gclient/metadata"
 	"github.com/google/knative-gcp/pkg/logging"
 	"github.com/google/knative-gcp/pkg/reconciler"
 	"github.com/google/knative-gcp/pkg/reconciler/brokercell/resources"
 	reconcilerutils "github.com/google/knative-gcp/pkg/reconciler/utils"
+	"github.com/google/knative-gcp/pkg/utils"
 	"github.com/google/knative-gcp/pkg/utils/authcheck"
 )
 
@@ -53,22 +58,26 @@ type envConfig struct {
 	RetryImage             string `envconfig:"RETRY_IMAGE" required:"true"`
 	ServiceAccountName     string `envconfig:"SERVICE_ACCOUNT" default:"broker"`
 	IngressPort            int    `envconfig:"INGRESS_PORT" default:"8080"`
+	IngressGRPCPort        int    `envconfig:"INGRESS_GRPC_PORT" default:"8081"`
+	IngressTLSPort         int    `envconfig:"INGRESS_TLS_PORT" default:"8443"`
 	MetricsPort            int    `envconfig:"METRICS_PORT" default:"9090"`
 	InternalMetricsEnabled bool   `envconfig:"INTERNAL_METRICS_ENABLED" default:"false"`
 }
 
 type listers struct {
-	brokerLister         brokerlisters.BrokerLister
-	channelLister        channellisters.ChannelLister
-	hpaLister            hpav2beta2listers.HorizontalPodAutoscalerLister
-	triggerLister        brokerlisters.TriggerLister
-	configMapLister      corev1listers.ConfigMapLister
-	secretLister         corev1listers.SecretLister
-	serviceAccountLister corev1listers.ServiceAccountLister
-	serviceLister        corev1listers.ServiceLister
-	endpointsLister      corev1listers.EndpointsLister
-	deploymentLister     appsv1listers.DeploymentLister
-	podLister            corev1listers.PodLister
+	brokerLister               brokerlisters.BrokerLister
+	channelLister              channellisters.ChannelLister
+	hpaLister                  hpav2beta2listers.HorizontalPodAutoscalerLister
+	pdbLister                  policyv1beta1listers.PodDisruptionBudgetLister
+	triggerLister              brokerlisters.TriggerLister
+	configMapLister            corev1listers.ConfigMapLister
+	secretLister               corev1listers.SecretLister
+	deliveryHeaderSecretLister corev1listers.SecretLister
+	serviceAccountLister       corev1listers.ServiceAccountLister
+	serviceLister              corev1listers.ServiceLister
+	endpointsLister            corev1listers.EndpointsLister
+	deploymentLister           appsv1listers.DeploymentLister
+	podLister                  corev1listers.PodLister
 }
 
 // NewReconciler creates a new BrokerCell reconciler.
@@ -115,6 +124,10 @@ type Reconciler struct {
 	cmRec         *reconcilerutils.ConfigMapReconciler
 
 	env envConfig
+
+	// clusterName is the name of the cluster, which the subscriptions of the backlog autoscaling
+	// are selected by. It is resolved from the metadata server when first needed.
+	clusterName string
 }
 
 // Check that our Reconciler implements Interface
@@ -142,6 +155,13 @@ func (r *Reconciler) ReconcileKind(ctx context.Context, bc *intv1alpha1.BrokerCe
 		return err
 	}
 
+	// The ingress Pods report the event types they observe in a ConfigMap they may only update.
+	if _, err := r.cmRec.ReconcileConfigMap(ctx, bc, resources.MakeEventTypeReports(), resources.EventTypeReportsEqual); err != nil {
+		logging.FromContext(ctx).Error("Failed to reconcile event type reports configmap", zap.Any("namespace", bc.Namespace), zap.Any("name", bc.Name), zap.Error(err))
+		bc.Status.MarkIngressFailed("EventTypeReportsFailed", "Failed to reconcile event type reports configmap: %v", err)
+		return err
+	}
+
 	authType, err := authcheck.GetAuthTypeForBrokerCell(ctx, r.serviceAccountLister, r.secretLister, authcheck.AuthTypeArgs{
 		Namespace:          bc.Namespace,
 		ServiceAccountName: authcheck.BrokerServiceAccountName,
@@ -164,13 +184,19 @@ func (r *Reconciler) ReconcileKind(ctx context.Context, bc *intv1alpha1.BrokerCe
 		return err
 	}
 
-	ingressHPA := resources.MakeHorizontalPodAutoscaler(ind, r.makeIngressHPAArgs(bc))
-	if err := r.reconcileAutoscaling(ctx, bc, ingressHPA); err != nil {
+	ingressHPA, err := r.reconcileAutoscaling(ctx, bc, resources.MakeHorizontalPodAutoscaler(ind, r.makeIngressHPAArgs(bc)))
+	if err != nil {
 		logging.FromContext(ctx).Error("Failed to reconcile ingress HPA", zap.Any("namespace", bc.Namespace), zap.Any("name", bc.Name), zap.Error(err))
 		bc.Status.MarkIngressFailed("HorizontalPodAutoscalerFailed", "Failed to reconcile ingress HorizontalPodAutoscaler: %v", err)
 		return err
 	}
 
+	if err := r.reconcileDisruptionBudget(ctx, bc, r.makeDisruptionBudget(bc, ind, resources.IngressName, bc.Spec.Components.Ingress)); err != nil {
+		logging.FromContext(ctx).Error("Failed to reconcile ingress PodDisruptionBudget", zap.Any("namespace", bc.Namespace), zap.Any("name", bc.Name), zap.Error(err))
+		bc.Status.MarkIngressFailed("PodDisruptionBudgetFailed", "Failed to reconcile ingress PodDisruptionBudget: %v", err)
+		return err
+	}
+
 	endpoints, err := r.svcRec.ReconcileService(ctx, bc, resources.MakeIngressService(ingressArgs))
 	if err != nil {
 		logging.FromContext(ctx).Error("Failed to reconcile ingress service", zap.Any("namespace", bc.Namespace), zap.Any("name", bc.Name), zap.Error(err))
@@ -188,9 +214,18 @@ func (r *Reconciler) ReconcileKind(ctx context.Context, bc *intv1alpha1.BrokerCe
 			bc.Status.MarkIngressUnknown(authcheck.AuthenticationCheckUnknownReason, authenticationCheckMessage)
 		}
 	}
+	ingressAtMaxReplicas := bc.Status.Components.Ingress.AtMaxReplicas
+	bc.Status.PropagateIngressReplicas(ind, ingressHPA)
+	r.reportReplicaLimit(bc, resources.IngressName, ingressAtMaxReplicas, ingressHPA)
 	hostName := network.GetServiceHostname(endpoints.GetName(), endpoints.GetNamespace())
 	bc.Status.IngressTemplate = fmt.Sprintf("http://%s/{namespace}/{name}", hostName)
 
+	if err := r.reconcileExternalIngress(ctx, bc, ingressArgs); err != nil {
+		logging.FromContext(ctx).Error("Failed to reconcile external ingress", zap.Any("namespace", bc.Namespace), zap.Any("name", bc.Name), zap.Error(err))
+		bc.Status.MarkIngressFailed("ExternalIngressFailed", "Failed to reconcile external ingress: %v", err)
+		return err
+	}
+
 	// Reconcile fanout deployment and HPA.
 	fd, err := r.deploymentRec.ReconcileDeployment(ctx, bc, resources.MakeFanoutDeployment(r.makeFanoutArgs(bc, authType)))
 	if err != nil {
@@ -199,12 +234,24 @@ func (r *Reconciler) ReconcileKind(ctx context.Context, bc *intv1alpha1.BrokerCe
 		return err
 	}
 
-	fanoutHPA := resources.MakeHorizontalPodAutoscaler(fd, r.makeFanoutHPAArgs(bc))
-	if err := r.reconcileAutoscaling(ctx, bc, fanoutHPA); err != nil {
+	fanoutHPAArgs, err := r.makeFanoutHPAArgs(bc)
+	if err != nil {
+		logging.FromContext(ctx).Error("Failed to make fanout HPA", zap.Any("namespace", bc.Namespace), zap.Any("name", bc.Name), zap.Error(err))
+		bc.Status.MarkFanoutFailed("HorizontalPodAutoscalerFailed", "Failed to make fanout HorizontalPodAutoscaler: %v", err)
+		return err
+	}
+	fanoutHPA, err := r.reconcileAutoscaling(ctx, bc, resources.MakeHorizontalPodAutoscaler(fd, fanoutHPAArgs))
+	if err != nil {
 		logging.FromContext(ctx).Error("Failed to reconcile fanout HPA", zap.Any("namespace", bc.Namespace), zap.Any("name", bc.Name), zap.Error(err))
 		bc.Status.MarkFanoutFailed("HorizontalPodAutoscalerFailed", "Failed to reconcile fanout HorizontalPodAutoscaler: %v", err)
 		return err
 	}
+
+	if err := r.reconcileDisruptionBudget(ctx, bc, r.makeDisruptionBudget(bc, fd, resources.FanoutName, bc.Spec.Components.Fanout)); err != nil {
+		logging.FromContext(ctx).Error("Failed to reconcile fanout PodDisruptionBudget", zap.Any("namespace", bc.Namespace), zap.Any("name", bc.Name), zap.Error(err))
+		bc.Status.MarkFanoutFailed("PodDisruptionBudgetFailed", "Failed to reconcile fanout PodDisruptionBudget: %v", err)
+		return err
+	}
 	// If deployment has replicaUnavailable error, it potentially has authentication configuration issues.
 	if replicaAvailable := bc.Status.PropagateFanoutAvailability(fd); !replicaAvailable {
 		podList, err := authcheck.GetPodList(ctx, resources.GetLabelSelector(bc.Name, resources.FanoutName), r.KubeClientSet, bc.Namespace)
@@ -216,6 +263,9 @@ func (r *Reconciler) ReconcileKind(ctx context.Context, bc *intv1alpha1.BrokerCe
 			bc.Status.MarkFanoutUnknown(authcheck.AuthenticationCheckUnknownReason, authenticationCheckMessage)
 		}
 	}
+	fanoutAtMaxReplicas := bc.Status.Components.Fanout.AtMaxReplicas
+	bc.Status.PropagateFanoutReplicas(fd, fanoutHPA)
+	r.reportReplicaLimit(bc, resources.FanoutName, fanoutAtMaxReplicas, fanoutHPA)
 	// Reconcile retry deployment and HPA.
 	rd, err := r.deploymentRec.ReconcileDeployment(ctx, bc, resources.MakeRetryDeployment(r.makeRetryArgs(bc, authType)))
 	if err != nil {
@@ -224,12 +274,24 @@ func (r *Reconciler) ReconcileKind(ctx context.Context, bc *intv1alpha1.BrokerCe
 		return err
 	}
 
-	retryHPA := resources.MakeHorizontalPodAutoscaler(rd, r.makeRetryHPAArgs(bc))
-	if err := r.reconcileAutoscaling(ctx, bc, retryHPA); err != nil {
+	retryHPAArgs, err := r.makeRetryHPAArgs(bc)
+	if err != nil {
+		logging.FromContext(ctx).Error("Failed to make retry HPA", zap.Any("namespace", bc.Namespace), zap.Any("name", bc.Name), zap.Error(err))
+		bc.Status.MarkRetryFailed("HorizontalPodAutoscalerFailed", "Failed to make retry HorizontalPodAutoscaler: %v", err)
+		return err
+	}
+	retryHPA, err := r.reconcileAutoscaling(ctx, bc, resources.MakeHorizontalPodAutoscaler(rd, retryHPAArgs))
+	if err != nil {
 		logging.FromContext(ctx).Error("Failed to reconcile retry HPA", zap.Any("namespace", bc.Namespace), zap.Any("name", bc.Name), zap.Error(err))
 		bc.Status.MarkRetryFailed("HorizontalPodAutoscalerFailed", "Failed to reconcile retry HorizontalPodAutoscaler: %v", err)
 		return err
 	}
+
+	if err := r.reconcileDisruptionBudget(ctx, bc, r.makeDisruptionBudget(bc, rd, resources.RetryName, bc.Spec.Components.Retry)); err != nil {
+		logging.FromContext(ctx).Error("Failed to reconcile retry PodDisruptionBudget", zap.Any("namespace", bc.Namespace), zap.Any("name", bc.Name), zap.Error(err))
+		bc.Status.MarkRetryFailed("PodDisruptionBudgetFailed", "Failed to reconcile retry PodDisruptionBudget: %v", err)
+		return err
+	}
 	// If deployment has replicaUnavailable error, it potentially has authentication configuration issues.
 	if replicaAvailable := bc.Status.PropagateRetryAvailability(rd); !replicaAvailable {
 		podList, err := authcheck.GetPodList(ctx, resources.GetLabelSelector(bc.Name, resources.RetryName), r.KubeClientSet, bc.Namespace)
@@ -241,6 +303,9 @@ func (r *Reconciler) ReconcileKind(ctx context.Context, bc *intv1alpha1.BrokerCe
 			bc.Status.MarkRetryUnknown(authcheck.AuthenticationCheckUnknownReason, authenticationCheckMessage)
 		}
 	}
+	retryAtMaxReplicas := bc.Status.Components.Retry.AtMaxReplicas
+	bc.Status.PropagateRetryReplicas(rd, retryHPA)
+	r.reportReplicaLimit(bc, resources.RetryName, retryAtMaxReplicas, retryHPA)
 
 	bc.Status.ObservedGeneration = bc.Generation
 	return pkgreconciler.NewEvent(corev1.EventTypeNormal, "BrokerCellReconciled", "BrokerCell reconciled: \"%s/%s\"", bc.Namespace, bc.Name)
@@ -309,20 +374,18 @@ func (r *Reconciler) makeIngressArgs(bc *intv1alpha1.BrokerCell, authType authch
 			MemoryLimit:        bc.Spec.Components.Ingress.MemoryLimit,
 			RolloutRestartTime: bc.GetAnnotations()[resources.IngressRestartTimeAnnotationKey],
 			AuthType:           authType,
-		},
-		Port: r.env.IngressPort,
-		// TODO(#1804): remove this arg when enabling the feature by default.
-		EnableIngressFilter: getIngressFilteringEnabled(bc),
-	}
-}
 
-// TODO(#1804): remove this function when enabling the feature by default.
-func getIngressFilteringEnabled(bc *intv1alpha1.BrokerCell) bool {
-	if val, ok := bc.GetAnnotations()[resources.IngressFilteringEnabledAnnotationKey]; ok {
-		return val == "true"
+			NodeSelector:              bc.Spec.Components.Ingress.NodeSelector,
+			Tolerations:               bc.Spec.Components.Ingress.Tolerations,
+			Affinity:                  bc.Spec.Components.Ingress.Affinity,
+			TopologySpreadConstraints: bc.Spec.Components.Ingress.TopologySpreadConstraints,
+			PriorityClassName:         bc.Spec.Components.Ingress.PriorityClassName,
+		},
+		Port:          r.env.IngressPort,
+		GRPCPort:      r.env.IngressGRPCPort,
+		TLSPort:       r.env.IngressTLSPort,
+		TLSSecretName: resources.ExternalIngressTLSSecretName(bc),
 	}
-
-	return false
 }
 
 func (r *Reconciler) makeIngressHPAArgs(bc *intv1alpha1.BrokerCell) resources.AutoscalingArgs {
@@ -351,12 +414,20 @@ func (r *Reconciler) makeFanoutArgs(bc *intv1alpha1.BrokerCell, authType authche
 			MemoryLimit:        bc.Spec.Components.Fanout.MemoryLimit,
 			RolloutRestartTime: bc.GetAnnotations()[resources.FanoutRestartTimeAnnotationKey],
 			AuthType:           authType,
+
+			NodeSelector:              bc.Spec.Components.Fanout.NodeSelector,
+			Tolerations:               bc.Spec.Components.Fanout.Tolerations,
+			Affinity:                  bc.Spec.Components.Fanout.Affinity,
+			TopologySpreadConstraints: bc.Spec.Components.Fanout.TopologySpreadConstraints,
+			PriorityClassName:         bc.Spec.Components.Fanout.PriorityClassName,
 		},
+		MaxConcurrencyPerTarget: bc.Spec.Components.Fanout.MaxConcurrencyPerTarget,
+		PullWorkers:             bc.Spec.Components.Fanout.PullWorkers,
 	}
 }
 
-func (r *Reconciler) makeFanoutHPAArgs(bc *intv1alpha1.BrokerCell) resources.AutoscalingArgs {
-	return resources.AutoscalingArgs{
+func (r *Reconciler) makeFanoutHPAArgs(bc *intv1alpha1.BrokerCell) (resources.AutoscalingArgs, error) {
+	args := resources.AutoscalingArgs{
 		ComponentName:     resources.FanoutName,
 		BrokerCell:        bc,
 		AvgCPUUtilization: bc.Spec.Components.Fanout.AvgCPUUtilization,
@@ -364,6 +435,16 @@ func (r *Reconciler) makeFanoutHPAArgs(bc *intv1alpha1.BrokerCell) resources.Aut
 		MaxReplicas:       *bc.Spec.Components.Fanout.MaxReplicas,
 		MinReplicas:       *bc.Spec.Components.Fanout.MinReplicas,
 	}
+	if bc.Spec.Components.Fanout.AutoscalingMode == intv1alpha1.BacklogAutoscaling {
+		clusterName, err := r.resolveClusterName()
+		if err != nil {
+			return args, err
+		}
+		args.BacklogResources = resources.FanoutBacklogResources
+		args.TargetBacklog = bc.Spec.Components.Fanout.TargetBacklog
+		args.ClusterName = clusterName
+	}
+	return args, nil
 }
 
 func (r *Reconciler) makeRetryArgs(bc *intv1alpha1.BrokerCell, authType authcheck.AuthType) resources.RetryArgs {
@@ -381,12 +462,20 @@ func (r *Reconciler) makeRetryArgs(bc *intv1alpha1.BrokerCell, authType authchec
 			MemoryLimit:        bc.Spec.Components.Retry.MemoryLimit,
 			RolloutRestartTime: bc.GetAnnotations()[resources.RetryRestartTimeAnnotationKey],
 			AuthType:           authType,
+
+			NodeSelector:              bc.Spec.Components.Retry.NodeSelector,
+			Tolerations:               bc.Spec.Components.Retry.Tolerations,
+			Affinity:                  bc.Spec.Components.Retry.Affinity,
+			TopologySpreadConstraints: bc.Spec.Components.Retry.TopologySpreadConstraints,
+			PriorityClassName:         bc.Spec.Components.Retry.PriorityClassName,
 		},
+		MaxConcurrencyPerTarget: bc.Spec.Components.Retry.MaxConcurrencyPerTarget,
+		PullWorkers:             bc.Spec.Components.Retry.PullWorkers,
 	}
 }
 
-func (r *Reconciler) makeRetryHPAArgs(bc *intv1alpha1.BrokerCell) resources.AutoscalingArgs {
-	return resources.AutoscalingArgs{
+func (r *Reconciler) makeRetryHPAArgs(bc *intv1alpha1.BrokerCell) (resources.AutoscalingArgs, error) {
+	args := resources.AutoscalingArgs{
 		ComponentName:     resources.RetryName,
 		BrokerCell:        bc,
 		AvgCPUUtilization: bc.Spec.Components.Retry.AvgCPUUtilization,
@@ -394,32 +483,120 @@ func (r *Reconciler) makeRetryHPAArgs(bc *intv1alpha1.BrokerCell) resources.Auto
 		MaxReplicas:       *bc.Spec.Components.Retry.MaxReplicas,
 		MinReplicas:       *bc.Spec.Components.Retry.MinReplicas,
 	}
+	if bc.Spec.Components.Retry.AutoscalingMode == intv1alpha1.BacklogAutoscaling {
+		clusterName, err := r.resolveClusterName()
+		if err != nil {
+			return args, err
+		}
+		args.BacklogResources = resources.RetryBacklogResources
+		args.TargetBacklog = bc.Spec.Components.Retry.TargetBacklog
+		args.ClusterName = clusterName
+	}
+	return args, nil
 }
 
-func (r *Reconciler) reconcileAutoscaling(ctx context.Context, bc *intv1alpha1.BrokerCell, desired *hpav2beta2.HorizontalPodAutoscaler) error {
+// resolveClusterName returns the name of the cluster, asking the metadata server the first time.
+func (r *Reconciler) resolveClusterName() (string, error) {
+	clusterName, err := utils.ClusterName(r.clusterName, metadataClient.NewDefaultMetadataClient())
+	if err != nil {
+		return "", fmt.Errorf("failed to get cluster name: %w", err)
+	}
+	r.clusterName = clusterName
+	return clusterName, nil
+}
+
+func (r *Reconciler) reconcileAutoscaling(ctx context.Context, bc *intv1alpha1.BrokerCell, desired *hpav2beta2.HorizontalPodAutoscaler) (*hpav2beta2.HorizontalPodAutoscaler, error) {
 	existing, err := r.hpaLister.HorizontalPodAutoscalers(desired.Namespace).Get(desired.Name)
 	if apierrs.IsNotFound(err) {
 		existing, err = r.KubeClientSet.AutoscalingV2beta2().HorizontalPodAutoscalers(desired.Namespace).Create(ctx, desired, metav1.CreateOptions{})
 		if apierrs.IsAlreadyExists(err) {
-			return nil
+			return desired, nil
 		}
 		if err == nil {
 			r.Recorder.Eventf(bc, corev1.EventTypeNormal, "HorizontalPodAutoscalerCreated", "Created HPA %s/%s", desired.Namespace, desired.Name)
 		}
-		return err
+		return existing, err
 	}
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if !equality.Semantic.DeepDerivative(desired.Spec, existing.Spec) {
 		// Don't modify the informers copy.
 		copy := existing.DeepCopy()
 		copy.Spec = desired.Spec
-		_, err := r.KubeClientSet.AutoscalingV2beta2().HorizontalPodAutoscalers(copy.Namespace).Update(ctx, copy, metav1.UpdateOptions{})
+		updated, err := r.KubeClientSet.AutoscalingV2beta2().HorizontalPodAutoscalers(copy.Namespace).Update(ctx, copy, metav1.UpdateOptions{})
 		if err == nil {
 			r.Recorder.Eventf(bc, corev1.EventTypeNormal, "HorizontalPodAutoscalerUpdated", "Updated HPA %s/%s", desired.Namespace, desired.Name)
 		}
+		return updated, err
+	}
+	return existing, nil
+}
+
+// reportReplicaLimit warns when the HPA of the component reaches the maximum number of replicas,
+// unless the component was already at the maximum in the previous reconciliation.
+func (r *Reconciler) reportReplicaLimit(bc *intv1alpha1.BrokerCell, componentName string, wasAtMaxReplicas bool, hpa *hpav2beta2.HorizontalPodAutoscaler) {
+	if wasAtMaxReplicas {
+		return
+	}
+	if hpa != nil && hpa.Spec.MaxReplicas > 0 && hpa.Status.DesiredReplicas >= hpa.Spec.MaxReplicas {
+		r.Recorder.Eventf(bc, corev1.EventTypeWarning, "MaxReplicasReached", "The %s wants %d replicas, reaching the maximum of %d replicas", componentName, hpa.Status.DesiredReplicas, hpa.Spec.MaxReplicas)
+	}
+}
+
+func (r *Reconciler) makeDisruptionBudget(bc *intv1alpha1.BrokerCell, d *appsv1.Deployment, componentName string, params *intv1alpha1.ComponentParameters) *policyv1beta1.PodDisruptionBudget {
+	return resources.MakePodDisruptionBudget(d, resources.DisruptionBudgetArgs{
+		ComponentName:  componentName,
+		BrokerCell:     bc,
+		MinAvailable:   params.MinAvailable,
+		MaxUnavailable: params.MaxUnavailable,
+	})
+}
+
+// reconcileDisruptionBudget creates or updates the desired PodDisruptionBudget, or deletes the
+// existing one when the component doesn't specify a budget.
+func (r *Reconciler) reconcileDisruptionBudget(ctx context.Context, bc *intv1alpha1.BrokerCell, desired *policyv1beta1.PodDisruptionBudget) error {
+	wanted := desired.Spec.MinAvailable != nil || desired.Spec.MaxUnavailable != nil
+	existing, err := r.pdbLister.PodDisruptionBudgets(desired.Namespace).Get(desired.Name)
+	if apierrs.IsNotFound(err) {
+		if !wanted {
+			return nil
+		}
+		_, err = r.KubeClientSet.PolicyV1beta1().PodDisruptionBudgets(desired.Namespace).Create(ctx, desired, metav1.CreateOptions{})
+		if apierrs.IsAlreadyExists(err) {
+			return nil
+		}
+		if err == nil {
+			r.Recorder.Eventf(bc, corev1.EventTypeNormal, "PodDisruptionBudgetCreated", "Created PodDisruptionBudget %s/%s", desired.Namespace, desired.Name)
+		}
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	if !wanted {
+		err := r.KubeClientSet.PolicyV1beta1().PodDisruptionBudgets(existing.Namespace).Delete(ctx, existing.Name, metav1.DeleteOptions{})
+		if apierrs.IsNotFound(err) {
+			return nil
+		}
+		if err == nil {
+			r.Recorder.Eventf(bc, corev1.EventTypeNormal, "PodDisruptionBudgetDeleted", "Deleted PodDisruptionBudget %s/%s", desired.Namespace, desired.Name)
+		}
+		return err
+	}
+	// DeepDerivative would miss switching from minAvailable to maxUnavailable.
+	if !equality.Semantic.DeepEqual(desired.Spec.MinAvailable, existing.Spec.MinAvailable) ||
+		!equality.Semantic.DeepEqual(desired.Spec.MaxUnavailable, existing.Spec.MaxUnavailable) ||
+		!equality.Semantic.DeepDerivative(desired.Spec.Selector, existing.Spec.Selector) {
+		// Don't modify the informers copy.
+		copy := existing.DeepCopy()
+		copy.Spec = desired.Spec
+		_, err := r.KubeClientSet.PolicyV1beta1().PodDisruptionBudgets(copy.Namespace).Update(ctx, copy, metav1.UpdateOptions{})
+		if err == nil {
+			r.Recorder.Eventf(bc, corev1.EventTypeNormal, "PodDisruptionBudgetUpdated", "Updated PodDisruptionBudget %s/%s", desired.Namespace, desired.Name)
+		}
 		return err
 	}
 	return nil
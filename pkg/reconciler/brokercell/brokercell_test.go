@@ -20,19 +20,25 @@ import (
 	"context"
 	"fmt"
 	"testing"
+	"time"
 
 	eventingduckv1 "knative.dev/eventing/pkg/apis/duck/v1"
 	"knative.dev/pkg/apis"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
 
 	"github.com/google/knative-gcp/pkg/apis/messaging/v1beta1"
 
 	appsv1 "k8s.io/api/apps/v1"
 	hpav2beta2 "k8s.io/api/autoscaling/v2beta2"
 	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/kubernetes/scheme"
 	clientgotesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
 	fakekubeclient "knative.dev/pkg/client/injection/kube/client/fake"
 	"knative.dev/pkg/configmap"
 	"knative.dev/pkg/controller"
@@ -44,6 +50,7 @@ import (
 	brokerv1 "github.com/google/knative-gcp/pkg/apis/broker/v1"
 	intv1alpha1 "github.com/google/knative-gcp/pkg/apis/intevents/v1alpha1"
 	"github.com/google/knative-gcp/pkg/broker/config"
+	"github.com/google/knative-gcp/pkg/broker/config/memory"
 	bcreconciler "github.com/google/knative-gcp/pkg/client/injection/reconciler/intevents/v1alpha1/brokercell"
 	"github.com/google/knative-gcp/pkg/reconciler"
 	"github.com/google/knative-gcp/pkg/reconciler/brokercell/resources"
@@ -57,6 +64,7 @@ const (
 	brokerCellName = "test-brokercell"
 	targetsCMName  = "broker-targets"
 	targetsCMKey   = "targets"
+	clusterName    = "test-cluster"
 )
 
 var (
@@ -69,40 +77,39 @@ var (
 		"events.cloud.google.com/fanoutRestartRequestedAt":  "2020-09-25T16:28:36-04:00",
 		"events.cloud.google.com/retryRestartRequestedAt":   "2020-09-25T16:28:36-04:00",
 	}
-	// TODO(1804): remove this variable when feature is enabled by default.
-	enableIngressFilteringAnnotation = map[string]string{
-		"events.cloud.google.com/ingressFilteringEnabled": "true",
-	}
 
-	brokerCellReconciledEvent     = Eventf(corev1.EventTypeNormal, "BrokerCellReconciled", `BrokerCell reconciled: "testnamespace/test-brokercell"`)
-	brokerCellGCEvent             = Eventf(corev1.EventTypeNormal, "BrokerCellGarbageCollected", `BrokerCell garbage collected: "testnamespace/test-brokercell"`)
-	brokerCellGCFailedEvent       = Eventf(corev1.EventTypeWarning, "InternalError", `failed to garbage collect brokercell: inducing failure for delete brokercells`)
-	brokerCellUpdateFailedEvent   = Eventf(corev1.EventTypeWarning, "UpdateFailed", `Failed to update status for "test-brokercell": inducing failure for update brokercells`)
-	ingressDeploymentCreatedEvent = Eventf(corev1.EventTypeNormal, "DeploymentCreated", "Created deployment testnamespace/test-brokercell-brokercell-ingress")
-	ingressDeploymentUpdatedEvent = Eventf(corev1.EventTypeNormal, "DeploymentUpdated", "Updated deployment testnamespace/test-brokercell-brokercell-ingress")
-	ingressHPACreatedEvent        = Eventf(corev1.EventTypeNormal, "HorizontalPodAutoscalerCreated", "Created HPA testnamespace/test-brokercell-brokercell-ingress-hpa")
-	ingressHPAUpdatedEvent        = Eventf(corev1.EventTypeNormal, "HorizontalPodAutoscalerUpdated", "Updated HPA testnamespace/test-brokercell-brokercell-ingress-hpa")
-	fanoutDeploymentCreatedEvent  = Eventf(corev1.EventTypeNormal, "DeploymentCreated", "Created deployment testnamespace/test-brokercell-brokercell-fanout")
-	fanoutDeploymentUpdatedEvent  = Eventf(corev1.EventTypeNormal, "DeploymentUpdated", "Updated deployment testnamespace/test-brokercell-brokercell-fanout")
-	fanoutHPACreatedEvent         = Eventf(corev1.EventTypeNormal, "HorizontalPodAutoscalerCreated", "Created HPA testnamespace/test-brokercell-brokercell-fanout-hpa")
-	fanoutHPAUpdatedEvent         = Eventf(corev1.EventTypeNormal, "HorizontalPodAutoscalerUpdated", "Updated HPA testnamespace/test-brokercell-brokercell-fanout-hpa")
-	retryDeploymentCreatedEvent   = Eventf(corev1.EventTypeNormal, "DeploymentCreated", "Created deployment testnamespace/test-brokercell-brokercell-retry")
-	retryDeploymentUpdatedEvent   = Eventf(corev1.EventTypeNormal, "DeploymentUpdated", "Updated deployment testnamespace/test-brokercell-brokercell-retry")
-	retryHPACreatedEvent          = Eventf(corev1.EventTypeNormal, "HorizontalPodAutoscalerCreated", "Created HPA testnamespace/test-brokercell-brokercell-retry-hpa")
-	retryHPAUpdatedEvent          = Eventf(corev1.EventTypeNormal, "HorizontalPodAutoscalerUpdated", "Updated HPA testnamespace/test-brokercell-brokercell-retry-hpa")
-	ingressServiceCreatedEvent    = Eventf(corev1.EventTypeNormal, "ServiceCreated", "Created service testnamespace/test-brokercell-brokercell-ingress")
-	ingressServiceUpdatedEvent    = Eventf(corev1.EventTypeNormal, "ServiceUpdated", "Updated service testnamespace/test-brokercell-brokercell-ingress")
-	deploymentCreationFailedEvent = Eventf(corev1.EventTypeWarning, "InternalError", "inducing failure for create deployments")
-	deploymentUpdateFailedEvent   = Eventf(corev1.EventTypeWarning, "InternalError", "inducing failure for update deployments")
-	serviceCreationFailedEvent    = Eventf(corev1.EventTypeWarning, "InternalError", "inducing failure for create services")
-	serviceUpdateFailedEvent      = Eventf(corev1.EventTypeWarning, "InternalError", "inducing failure for update services")
-	hpaCreationFailedEvent        = Eventf(corev1.EventTypeWarning, "InternalError", "inducing failure for create horizontalpodautoscalers")
-	hpaUpdateFailedEvent          = Eventf(corev1.EventTypeWarning, "InternalError", "inducing failure for update horizontalpodautoscalers")
-	configmapCreationFailedEvent  = Eventf(corev1.EventTypeWarning, "InternalError", "inducing failure for create configmaps")
-	configmapUpdateFailedEvent    = Eventf(corev1.EventTypeWarning, "InternalError", "inducing failure for update configmaps")
-	configmapCreatedEvent         = Eventf(corev1.EventTypeNormal, "ConfigMapCreated", "Created configmap testnamespace/test-brokercell-brokercell-broker-targets")
-	configmapUpdatedEvent         = Eventf(corev1.EventTypeNormal, "ConfigMapUpdated", "Updated configmap testnamespace/test-brokercell-brokercell-broker-targets")
-	authTypeEvent                 = Eventf(corev1.EventTypeWarning, "InternalError", "authentication is not configured, when checking Kubernetes Service Account broker, got error: can't find Kubernetes Service Account broker, when checking Kubernetes Secret google-broker-key, got error: can't find Kubernetes Secret google-broker-key")
+	brokerCellReconciledEvent          = Eventf(corev1.EventTypeNormal, "BrokerCellReconciled", `BrokerCell reconciled: "testnamespace/test-brokercell"`)
+	brokerCellGCEvent                  = Eventf(corev1.EventTypeNormal, "BrokerCellGarbageCollected", `BrokerCell garbage collected: "testnamespace/test-brokercell"`)
+	brokerCellGCFailedEvent            = Eventf(corev1.EventTypeWarning, "InternalError", `failed to garbage collect brokercell: inducing failure for delete brokercells`)
+	brokerCellUpdateFailedEvent        = Eventf(corev1.EventTypeWarning, "UpdateFailed", `Failed to update status for "test-brokercell": inducing failure for update brokercells`)
+	ingressDeploymentCreatedEvent      = Eventf(corev1.EventTypeNormal, "DeploymentCreated", "Created deployment testnamespace/test-brokercell-brokercell-ingress")
+	ingressDeploymentUpdatedEvent      = Eventf(corev1.EventTypeNormal, "DeploymentUpdated", "Updated deployment testnamespace/test-brokercell-brokercell-ingress")
+	ingressHPACreatedEvent             = Eventf(corev1.EventTypeNormal, "HorizontalPodAutoscalerCreated", "Created HPA testnamespace/test-brokercell-brokercell-ingress-hpa")
+	ingressHPAUpdatedEvent             = Eventf(corev1.EventTypeNormal, "HorizontalPodAutoscalerUpdated", "Updated HPA testnamespace/test-brokercell-brokercell-ingress-hpa")
+	fanoutDeploymentCreatedEvent       = Eventf(corev1.EventTypeNormal, "DeploymentCreated", "Created deployment testnamespace/test-brokercell-brokercell-fanout")
+	fanoutDeploymentUpdatedEvent       = Eventf(corev1.EventTypeNormal, "DeploymentUpdated", "Updated deployment testnamespace/test-brokercell-brokercell-fanout")
+	fanoutHPACreatedEvent              = Eventf(corev1.EventTypeNormal, "HorizontalPodAutoscalerCreated", "Created HPA testnamespace/test-brokercell-brokercell-fanout-hpa")
+	fanoutHPAUpdatedEvent              = Eventf(corev1.EventTypeNormal, "HorizontalPodAutoscalerUpdated", "Updated HPA testnamespace/test-brokercell-brokercell-fanout-hpa")
+	retryDeploymentCreatedEvent        = Eventf(corev1.EventTypeNormal, "DeploymentCreated", "Created deployment testnamespace/test-brokercell-brokercell-retry")
+	retryDeploymentUpdatedEvent        = Eventf(corev1.EventTypeNormal, "DeploymentUpdated", "Updated deployment testnamespace/test-brokercell-brokercell-retry")
+	retryHPACreatedEvent               = Eventf(corev1.EventTypeNormal, "HorizontalPodAutoscalerCreated", "Created HPA testnamespace/test-brokercell-brokercell-retry-hpa")
+	retryHPAUpdatedEvent               = Eventf(corev1.EventTypeNormal, "HorizontalPodAutoscalerUpdated", "Updated HPA testnamespace/test-brokercell-brokercell-retry-hpa")
+	ingressServiceCreatedEvent         = Eventf(corev1.EventTypeNormal, "ServiceCreated", "Created service testnamespace/test-brokercell-brokercell-ingress")
+	ingressServiceUpdatedEvent         = Eventf(corev1.EventTypeNormal, "ServiceUpdated", "Updated service testnamespace/test-brokercell-brokercell-ingress")
+	ingressExternalServiceCreatedEvent = Eventf(corev1.EventTypeNormal, "ServiceCreated", "Created service testnamespace/test-brokercell-brokercell-ingress-external")
+	deploymentCreationFailedEvent      = Eventf(corev1.EventTypeWarning, "InternalError", "inducing failure for create deployments")
+	deploymentUpdateFailedEvent        = Eventf(corev1.EventTypeWarning, "InternalError", "inducing failure for update deployments")
+	serviceCreationFailedEvent         = Eventf(corev1.EventTypeWarning, "InternalError", "inducing failure for create services")
+	serviceUpdateFailedEvent           = Eventf(corev1.EventTypeWarning, "InternalError", "inducing failure for update services")
+	hpaCreationFailedEvent             = Eventf(corev1.EventTypeWarning, "InternalError", "inducing failure for create horizontalpodautoscalers")
+	hpaUpdateFailedEvent               = Eventf(corev1.EventTypeWarning, "InternalError", "inducing failure for update horizontalpodautoscalers")
+	pdbCreationFailedEvent             = Eventf(corev1.EventTypeWarning, "InternalError", "inducing failure for create poddisruptionbudgets")
+	pdbDeletionFailedEvent             = Eventf(corev1.EventTypeWarning, "InternalError", "inducing failure for delete poddisruptionbudgets")
+	configmapCreationFailedEvent       = Eventf(corev1.EventTypeWarning, "InternalError", "inducing failure for create configmaps")
+	configmapUpdateFailedEvent         = Eventf(corev1.EventTypeWarning, "InternalError", "inducing failure for update configmaps")
+	configmapCreatedEvent              = Eventf(corev1.EventTypeNormal, "ConfigMapCreated", "Created configmap testnamespace/test-brokercell-brokercell-broker-targets")
+	configmapUpdatedEvent              = Eventf(corev1.EventTypeNormal, "ConfigMapUpdated", "Updated configmap testnamespace/test-brokercell-brokercell-broker-targets")
+	authTypeEvent                      = Eventf(corev1.EventTypeWarning, "InternalError", "authentication is not configured, when checking Kubernetes Service Account broker, got error: can't find Kubernetes Service Account broker, when checking Kubernetes Secret google-broker-key, got error: can't find Kubernetes Secret google-broker-key")
 )
 
 func init() {
@@ -162,6 +169,12 @@ func TestAllCases(t *testing.T) {
 				Object: NewBrokerCell(brokerCellName, testNS,
 					WithInitBrokerCellConditions,
 					WithTargetsCofigFailed(configFailed, "failed to update configmap: inducing failure for update configmaps"),
+					WithBrokerCellTenants(intv1alpha1.TenantsStatus{Brokers: 1}),
+					withTargetsConfigSizeOf(testingdata.BrokerCellObjects{
+						BrokersToTriggers: map[*brokerv1.Broker][]*brokerv1.Trigger{
+							NewBroker("broker", testNS, WithBrokerSetDefaults): {},
+						},
+					}),
 					WithBrokerCellSetDefaults,
 				),
 			}},
@@ -176,11 +189,187 @@ func TestAllCases(t *testing.T) {
 			)}},
 			WantErr: true,
 		},
+		{
+			Name: "Broker ingress quota is added to the targets config",
+			Key:  testKey,
+			Objects: []runtime.Object{
+				NewBrokerCell(brokerCellName, testNS, WithBrokerCellSetDefaults),
+				testingdata.EmptyConfig(t, NewBrokerCell(brokerCellName, testNS, WithBrokerCellSetDefaults)),
+				NewBroker("broker", testNS, WithBrokerSetDefaults,
+					WithBrokerAnnotation(brokerv1.IngressEventsPerSecondAnnotationKey, "100"),
+					WithBrokerAnnotation(brokerv1.IngressBytesInFlightAnnotationKey, "1Mi")),
+			},
+			WithReactors: []clientgotesting.ReactionFunc{InduceFailure("update", "configmaps")},
+			WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+				Object: NewBrokerCell(brokerCellName, testNS,
+					WithInitBrokerCellConditions,
+					WithTargetsCofigFailed(configFailed, "failed to update configmap: inducing failure for update configmaps"),
+					WithBrokerCellTenants(intv1alpha1.TenantsStatus{Brokers: 1}),
+					withTargetsConfigSizeOf(testingdata.BrokerCellObjects{
+						BrokersToTriggers: map[*brokerv1.Broker][]*brokerv1.Trigger{
+							NewBroker("broker", testNS, WithBrokerSetDefaults,
+								WithBrokerAnnotation(brokerv1.IngressEventsPerSecondAnnotationKey, "100"),
+								WithBrokerAnnotation(brokerv1.IngressBytesInFlightAnnotationKey, "1Mi")): {},
+						},
+					}),
+					WithBrokerCellSetDefaults,
+				),
+			}},
+			WantEvents: []string{configmapUpdateFailedEvent},
+			WantUpdates: []clientgotesting.UpdateActionImpl{{Object: testingdata.Config(
+				NewBrokerCell(brokerCellName, testNS, WithBrokerCellSetDefaults),
+				testingdata.BrokerCellObjects{
+					BrokersToTriggers: map[*brokerv1.Broker][]*brokerv1.Trigger{
+						NewBroker("broker", testNS, WithBrokerSetDefaults,
+							WithBrokerAnnotation(brokerv1.IngressEventsPerSecondAnnotationKey, "100"),
+							WithBrokerAnnotation(brokerv1.IngressBytesInFlightAnnotationKey, "1Mi")): {},
+					},
+				},
+			)}},
+			WantErr: true,
+		},
+		{
+			Name: "Broker ingress async publish is added to the targets config",
+			Key:  testKey,
+			Objects: []runtime.Object{
+				NewBrokerCell(brokerCellName, testNS, WithBrokerCellSetDefaults),
+				testingdata.EmptyConfig(t, NewBrokerCell(brokerCellName, testNS, WithBrokerCellSetDefaults)),
+				NewBroker("broker", testNS, WithBrokerSetDefaults,
+					WithBrokerAnnotation(brokerv1.IngressAsyncPublishAnnotationKey, "true")),
+			},
+			WithReactors: []clientgotesting.ReactionFunc{InduceFailure("update", "configmaps")},
+			WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+				Object: NewBrokerCell(brokerCellName, testNS,
+					WithInitBrokerCellConditions,
+					WithTargetsCofigFailed(configFailed, "failed to update configmap: inducing failure for update configmaps"),
+					WithBrokerCellTenants(intv1alpha1.TenantsStatus{Brokers: 1}),
+					withTargetsConfigSizeOf(testingdata.BrokerCellObjects{
+						BrokersToTriggers: map[*brokerv1.Broker][]*brokerv1.Trigger{
+							NewBroker("broker", testNS, WithBrokerSetDefaults,
+								WithBrokerAnnotation(brokerv1.IngressAsyncPublishAnnotationKey, "true")): {},
+						},
+					}),
+					WithBrokerCellSetDefaults,
+				),
+			}},
+			WantEvents: []string{configmapUpdateFailedEvent},
+			WantUpdates: []clientgotesting.UpdateActionImpl{{Object: testingdata.Config(
+				NewBrokerCell(brokerCellName, testNS, WithBrokerCellSetDefaults),
+				testingdata.BrokerCellObjects{
+					BrokersToTriggers: map[*brokerv1.Broker][]*brokerv1.Trigger{
+						NewBroker("broker", testNS, WithBrokerSetDefaults,
+							WithBrokerAnnotation(brokerv1.IngressAsyncPublishAnnotationKey, "true")): {},
+					},
+				},
+			)}},
+			WantErr: true,
+		},
+		{
+			Name: "Broker archive is added to the targets config",
+			Key:  testKey,
+			Objects: []runtime.Object{
+				NewBrokerCell(brokerCellName, testNS, WithBrokerCellSetDefaults),
+				testingdata.EmptyConfig(t, NewBrokerCell(brokerCellName, testNS, WithBrokerCellSetDefaults)),
+				NewBroker("broker", testNS, WithBrokerSetDefaults,
+					WithBrokerAnnotation(brokerv1.ArchiveAnnotationKey, "archive-bucket/events"),
+					WithBrokerArchive("archive-bucket", "events")),
+			},
+			WithReactors: []clientgotesting.ReactionFunc{InduceFailure("update", "configmaps")},
+			WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+				Object: NewBrokerCell(brokerCellName, testNS,
+					WithInitBrokerCellConditions,
+					WithTargetsCofigFailed(configFailed, "failed to update configmap: inducing failure for update configmaps"),
+					WithBrokerCellTenants(intv1alpha1.TenantsStatus{Brokers: 1}),
+					withTargetsConfigSizeOf(testingdata.BrokerCellObjects{
+						BrokersToTriggers: map[*brokerv1.Broker][]*brokerv1.Trigger{
+							NewBroker("broker", testNS, WithBrokerSetDefaults,
+								WithBrokerAnnotation(brokerv1.ArchiveAnnotationKey, "archive-bucket/events"),
+								WithBrokerArchive("archive-bucket", "events")): {},
+						},
+					}),
+					WithBrokerCellSetDefaults,
+				),
+			}},
+			WantEvents: []string{configmapUpdateFailedEvent},
+			WantUpdates: []clientgotesting.UpdateActionImpl{{Object: testingdata.Config(
+				NewBrokerCell(brokerCellName, testNS, WithBrokerCellSetDefaults),
+				testingdata.BrokerCellObjects{
+					BrokersToTriggers: map[*brokerv1.Broker][]*brokerv1.Trigger{
+						NewBroker("broker", testNS, WithBrokerSetDefaults,
+							WithBrokerAnnotation(brokerv1.ArchiveAnnotationKey, "archive-bucket/events"),
+							WithBrokerArchive("archive-bucket", "events")): {},
+					},
+				},
+			)}},
+			WantErr: true,
+		},
+		{
+			Name: "Broker federation queues are added to the targets config",
+			Key:  testKey,
+			Objects: []runtime.Object{
+				NewBrokerCell(brokerCellName, testNS, WithBrokerCellSetDefaults),
+				testingdata.EmptyConfig(t, NewBrokerCell(brokerCellName, testNS, WithBrokerCellSetDefaults)),
+				NewBroker("broker", testNS, WithBrokerSetDefaults,
+					WithBrokerAnnotation(brokerv1.FederateFromAnnotationKey, "projects/other-project/topics/remote-topic"),
+					WithBrokerFederatedTopics(brokerv1.FederatedTopic{Project: "other-project", Topic: "remote-topic"})),
+			},
+			WithReactors: []clientgotesting.ReactionFunc{InduceFailure("update", "configmaps")},
+			WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+				Object: NewBrokerCell(brokerCellName, testNS,
+					WithInitBrokerCellConditions,
+					WithTargetsCofigFailed(configFailed, "failed to update configmap: inducing failure for update configmaps"),
+					WithBrokerCellTenants(intv1alpha1.TenantsStatus{Brokers: 1}),
+					withTargetsConfigSizeOf(testingdata.BrokerCellObjects{
+						BrokersToTriggers: map[*brokerv1.Broker][]*brokerv1.Trigger{
+							NewBroker("broker", testNS, WithBrokerSetDefaults,
+								WithBrokerAnnotation(brokerv1.FederateFromAnnotationKey, "projects/other-project/topics/remote-topic"),
+								WithBrokerFederatedTopics(brokerv1.FederatedTopic{Project: "other-project", Topic: "remote-topic"})): {},
+						},
+					}),
+					WithBrokerCellSetDefaults,
+				),
+			}},
+			WantEvents: []string{configmapUpdateFailedEvent},
+			WantUpdates: []clientgotesting.UpdateActionImpl{{Object: testingdata.Config(
+				NewBrokerCell(brokerCellName, testNS, WithBrokerCellSetDefaults),
+				testingdata.BrokerCellObjects{
+					BrokersToTriggers: map[*brokerv1.Broker][]*brokerv1.Trigger{
+						NewBroker("broker", testNS, WithBrokerSetDefaults,
+							WithBrokerAnnotation(brokerv1.FederateFromAnnotationKey, "projects/other-project/topics/remote-topic"),
+							WithBrokerFederatedTopics(brokerv1.FederatedTopic{Project: "other-project", Topic: "remote-topic"})): {},
+					},
+				},
+			)}},
+			WantErr: true,
+		},
+		{
+			Name: "Event type reports ConfigMap.Create error",
+			Key:  testKey,
+			Objects: []runtime.Object{
+				NewBrokerCell(brokerCellName, testNS, WithBrokerCellSetDefaults),
+				testingdata.EmptyConfig(t, NewBrokerCell(brokerCellName, testNS, WithBrokerCellSetDefaults)),
+			},
+			WithReactors: []clientgotesting.ReactionFunc{InduceFailure("create", "configmaps")},
+			WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+				Object: NewBrokerCell(brokerCellName, testNS,
+					WithInitBrokerCellConditions,
+					WithTargetsCofigReady(),
+					WithBrokerCellIngressFailed("EventTypeReportsFailed", "Failed to reconcile event type reports configmap: inducing failure for create configmaps"),
+					WithBrokerCellSetDefaults,
+				),
+			}},
+			WantEvents:  []string{configmapCreationFailedEvent},
+			WantCreates: []runtime.Object{testingdata.EventTypeReports()},
+			WantErr:     true,
+			// The event type reports ConfigMap is in the system namespace.
+			SkipNamespaceValidation: true,
+		},
 		{
 			Name: "authType error",
 			Key:  testKeyAuth,
 			Objects: []runtime.Object{
 				NewBrokerCell(brokerCellName, authcheck.ControlPlaneNamespace, WithBrokerCellSetDefaults),
+				testingdata.EventTypeReports(),
 				testingdata.EmptyConfig(t, NewBrokerCell(brokerCellName, authcheck.ControlPlaneNamespace, WithBrokerCellSetDefaults)),
 			},
 			WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
@@ -201,6 +390,7 @@ func TestAllCases(t *testing.T) {
 			Key:  testKey,
 			Objects: []runtime.Object{
 				NewBrokerCell(brokerCellName, testNS, WithBrokerCellSetDefaults),
+				testingdata.EventTypeReports(),
 				testingdata.EmptyConfig(t, NewBrokerCell(brokerCellName, testNS, WithBrokerCellSetDefaults)),
 			},
 			WithReactors: []clientgotesting.ReactionFunc{
@@ -227,6 +417,7 @@ func TestAllCases(t *testing.T) {
 			Key:  testKey,
 			Objects: []runtime.Object{
 				NewBrokerCell(brokerCellName, testNS, WithBrokerCellSetDefaults),
+				testingdata.EventTypeReports(),
 				// Create an deployment such that only the spec is different from expected deployment to trigger an update.
 				NewDeployment(brokerCellName+"-brokercell-ingress", testNS,
 					func(d *appsv1.Deployment) {
@@ -260,6 +451,7 @@ func TestAllCases(t *testing.T) {
 			Key:  testKey,
 			Objects: []runtime.Object{
 				NewBrokerCell(brokerCellName, testNS, WithBrokerCellSetDefaults),
+				testingdata.EventTypeReports(),
 				testingdata.EmptyConfig(t, NewBrokerCell(brokerCellName, testNS, WithBrokerCellSetDefaults)),
 				testingdata.IngressDeploymentWithStatus(t),
 			},
@@ -287,6 +479,7 @@ func TestAllCases(t *testing.T) {
 			Key:  testKey,
 			Objects: []runtime.Object{
 				NewBrokerCell(brokerCellName, testNS, WithBrokerCellSetDefaults),
+				testingdata.EventTypeReports(),
 				testingdata.EmptyConfig(t, NewBrokerCell(brokerCellName, testNS, WithBrokerCellSetDefaults)),
 				testingdata.IngressDeploymentWithStatus(t),
 				emptyHPASpec(testingdata.IngressHPA(t)),
@@ -315,6 +508,7 @@ func TestAllCases(t *testing.T) {
 			Key:  testKey,
 			Objects: []runtime.Object{
 				NewBrokerCell(brokerCellName, testNS, WithBrokerCellSetDefaults),
+				testingdata.EventTypeReports(),
 				testingdata.EmptyConfig(t, NewBrokerCell(brokerCellName, testNS, WithBrokerCellSetDefaults)),
 				testingdata.IngressDeploymentWithStatus(t),
 				testingdata.IngressHPA(t),
@@ -345,6 +539,7 @@ func TestAllCases(t *testing.T) {
 			Key:  testKey,
 			Objects: []runtime.Object{
 				NewBrokerCell(brokerCellName, testNS, WithBrokerCellSetDefaults),
+				testingdata.EventTypeReports(),
 				testingdata.EmptyConfig(t, NewBrokerCell(brokerCellName, testNS, WithBrokerCellSetDefaults)),
 				testingdata.IngressDeploymentWithStatus(t),
 				testingdata.IngressHPA(t),
@@ -380,6 +575,7 @@ func TestAllCases(t *testing.T) {
 			Key:  testKey,
 			Objects: []runtime.Object{
 				NewBrokerCell(brokerCellName, testNS, WithBrokerCellSetDefaults),
+				testingdata.EventTypeReports(),
 				testingdata.EmptyConfig(t, NewBrokerCell(brokerCellName, testNS, WithBrokerCellSetDefaults)),
 				testingdata.IngressHPA(t),
 				NewEndpoints(brokerCellName+"-brokercell-ingress", testNS,
@@ -413,6 +609,7 @@ func TestAllCases(t *testing.T) {
 			Key:  testKey,
 			Objects: []runtime.Object{
 				NewBrokerCell(brokerCellName, testNS, WithBrokerCellSetDefaults),
+				testingdata.EventTypeReports(),
 				testingdata.EmptyConfig(t, NewBrokerCell(brokerCellName, testNS, WithBrokerCellSetDefaults)),
 				testingdata.IngressHPA(t),
 				NewEndpoints(brokerCellName+"-brokercell-ingress", testNS,
@@ -453,6 +650,7 @@ func TestAllCases(t *testing.T) {
 			Key:  testKey,
 			Objects: []runtime.Object{
 				NewBrokerCell(brokerCellName, testNS, WithBrokerCellSetDefaults),
+				testingdata.EventTypeReports(),
 				testingdata.EmptyConfig(t, NewBrokerCell(brokerCellName, testNS, WithBrokerCellSetDefaults)),
 				testingdata.IngressHPA(t),
 				NewEndpoints(brokerCellName+"-brokercell-ingress", testNS,
@@ -487,6 +685,7 @@ func TestAllCases(t *testing.T) {
 			Key:  testKey,
 			Objects: []runtime.Object{
 				NewBrokerCell(brokerCellName, testNS, WithBrokerCellSetDefaults),
+				testingdata.EventTypeReports(),
 				testingdata.EmptyConfig(t, NewBrokerCell(brokerCellName, testNS, WithBrokerCellSetDefaults)),
 				testingdata.IngressHPA(t),
 				NewEndpoints(brokerCellName+"-brokercell-ingress", testNS,
@@ -517,11 +716,128 @@ func TestAllCases(t *testing.T) {
 			},
 			WantErr: true,
 		},
+		{
+			Name: "Fanout PodDisruptionBudget.Create error",
+			Key:  testKey,
+			Objects: []runtime.Object{
+				NewBrokerCell(brokerCellName, testNS, WithBrokerCellSetDefaults, withFanoutMaxUnavailable),
+				testingdata.EventTypeReports(),
+				testingdata.EmptyConfig(t, NewBrokerCell(brokerCellName, testNS, WithBrokerCellSetDefaults, withFanoutMaxUnavailable)),
+				testingdata.IngressHPA(t),
+				NewEndpoints(brokerCellName+"-brokercell-ingress", testNS,
+					WithEndpointsAddresses(corev1.EndpointAddress{IP: "127.0.0.1"})),
+				testingdata.IngressDeploymentWithStatus(t),
+				testingdata.IngressServiceWithStatus(t),
+				testingdata.FanoutDeployment(t),
+				testingdata.FanoutHPA(t),
+			},
+			WithReactors: []clientgotesting.ReactionFunc{
+				InduceFailure("create", "poddisruptionbudgets"),
+			},
+			WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+				Object: NewBrokerCell(brokerCellName, testNS,
+					WithInitBrokerCellConditions,
+					WithTargetsCofigReady(),
+					WithBrokerCellIngressAvailable(),
+					WithIngressTemplate("http://test-brokercell-brokercell-ingress.testnamespace.svc.cluster.local/{namespace}/{name}"),
+					WithBrokerCellFanoutFailed("PodDisruptionBudgetFailed", `Failed to reconcile fanout PodDisruptionBudget: inducing failure for create poddisruptionbudgets`),
+					WithBrokerCellSetDefaults,
+					withFanoutMaxUnavailable,
+				),
+			}},
+			WantEvents: []string{
+				pdbCreationFailedEvent,
+			},
+			WantCreates: []runtime.Object{
+				testingdata.FanoutPDB(t),
+			},
+			WantErr: true,
+		},
+		{
+			Name: "Fanout PodDisruptionBudget is deleted when no budget is specified",
+			Key:  testKey,
+			Objects: []runtime.Object{
+				NewBrokerCell(brokerCellName, testNS, WithBrokerCellSetDefaults),
+				testingdata.EventTypeReports(),
+				testingdata.EmptyConfig(t, NewBrokerCell(brokerCellName, testNS, WithBrokerCellSetDefaults)),
+				testingdata.IngressHPA(t),
+				NewEndpoints(brokerCellName+"-brokercell-ingress", testNS,
+					WithEndpointsAddresses(corev1.EndpointAddress{IP: "127.0.0.1"})),
+				testingdata.IngressDeploymentWithStatus(t),
+				testingdata.IngressServiceWithStatus(t),
+				testingdata.FanoutDeployment(t),
+				testingdata.FanoutHPA(t),
+				testingdata.FanoutPDB(t),
+			},
+			WithReactors: []clientgotesting.ReactionFunc{
+				InduceFailure("delete", "poddisruptionbudgets"),
+			},
+			WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+				Object: NewBrokerCell(brokerCellName, testNS,
+					WithInitBrokerCellConditions,
+					WithTargetsCofigReady(),
+					WithBrokerCellIngressAvailable(),
+					WithIngressTemplate("http://test-brokercell-brokercell-ingress.testnamespace.svc.cluster.local/{namespace}/{name}"),
+					WithBrokerCellFanoutFailed("PodDisruptionBudgetFailed", `Failed to reconcile fanout PodDisruptionBudget: inducing failure for delete poddisruptionbudgets`),
+					WithBrokerCellSetDefaults,
+				),
+			}},
+			WantEvents: []string{
+				pdbDeletionFailedEvent,
+			},
+			WantDeletes: []clientgotesting.DeleteActionImpl{{
+				ActionImpl: clientgotesting.ActionImpl{
+					Namespace: testNS,
+					Verb:      "delete",
+					Resource:  policyv1beta1.SchemeGroupVersion.WithResource("poddisruptionbudgets"),
+				},
+				Name: brokerCellName + "-brokercell-fanout-pdb",
+			}},
+			WantErr: true,
+		},
+		{
+			Name: "Fanout HorizontalPodAutoscaler scales on the subscription backlog",
+			Key:  testKey,
+			Objects: []runtime.Object{
+				NewBrokerCell(brokerCellName, testNS, withFanoutBacklogAutoscaling, WithBrokerCellSetDefaults),
+				testingdata.EventTypeReports(),
+				testingdata.EmptyConfig(t, NewBrokerCell(brokerCellName, testNS, withFanoutBacklogAutoscaling, WithBrokerCellSetDefaults)),
+				testingdata.IngressHPA(t),
+				NewEndpoints(brokerCellName+"-brokercell-ingress", testNS,
+					WithEndpointsAddresses(corev1.EndpointAddress{IP: "127.0.0.1"})),
+				testingdata.IngressDeploymentWithStatus(t),
+				testingdata.IngressServiceWithStatus(t),
+				testingdata.FanoutDeployment(t),
+				testingdata.FanoutHPA(t),
+			},
+			WithReactors: []clientgotesting.ReactionFunc{
+				InduceFailure("update", "horizontalpodautoscalers"),
+			},
+			WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+				Object: NewBrokerCell(brokerCellName, testNS,
+					withFanoutBacklogAutoscaling,
+					WithInitBrokerCellConditions,
+					WithTargetsCofigReady(),
+					WithBrokerCellIngressAvailable(),
+					WithIngressTemplate("http://test-brokercell-brokercell-ingress.testnamespace.svc.cluster.local/{namespace}/{name}"),
+					WithBrokerCellFanoutFailed("HorizontalPodAutoscalerFailed", `Failed to reconcile fanout HorizontalPodAutoscaler: inducing failure for update horizontalpodautoscalers`),
+					WithBrokerCellSetDefaults,
+				),
+			}},
+			WantEvents: []string{
+				hpaUpdateFailedEvent,
+			},
+			WantUpdates: []clientgotesting.UpdateActionImpl{
+				{Object: testingdata.FanoutHPAWithBacklog(t)},
+			},
+			WantErr: true,
+		},
 		{
 			Name: "Retry Deployment.Create error",
 			Key:  testKey,
 			Objects: []runtime.Object{
 				NewBrokerCell(brokerCellName, testNS, WithBrokerCellSetDefaults),
+				testingdata.EventTypeReports(),
 				testingdata.EmptyConfig(t, NewBrokerCell(brokerCellName, testNS, WithBrokerCellSetDefaults)),
 				NewEndpoints(brokerCellName+"-brokercell-ingress", testNS,
 					WithEndpointsAddresses(corev1.EndpointAddress{IP: "127.0.0.1"})),
@@ -559,6 +875,7 @@ func TestAllCases(t *testing.T) {
 			Key:  testKey,
 			Objects: []runtime.Object{
 				NewBrokerCell(brokerCellName, testNS, WithBrokerCellSetDefaults),
+				testingdata.EventTypeReports(),
 				testingdata.EmptyConfig(t, NewBrokerCell(brokerCellName, testNS, WithBrokerCellSetDefaults)),
 				NewEndpoints(brokerCellName+"-brokercell-ingress", testNS,
 					WithEndpointsAddresses(corev1.EndpointAddress{IP: "127.0.0.1"})),
@@ -602,6 +919,7 @@ func TestAllCases(t *testing.T) {
 			Key:  testKey,
 			Objects: []runtime.Object{
 				NewBrokerCell(brokerCellName, testNS, WithBrokerCellSetDefaults),
+				testingdata.EventTypeReports(),
 				testingdata.EmptyConfig(t, NewBrokerCell(brokerCellName, testNS, WithBrokerCellSetDefaults)),
 				NewEndpoints(brokerCellName+"-brokercell-ingress", testNS,
 					WithEndpointsAddresses(corev1.EndpointAddress{IP: "127.0.0.1"})),
@@ -639,6 +957,7 @@ func TestAllCases(t *testing.T) {
 			Key:  testKey,
 			Objects: []runtime.Object{
 				NewBrokerCell(brokerCellName, testNS, WithBrokerCellSetDefaults),
+				testingdata.EventTypeReports(),
 				testingdata.EmptyConfig(t, NewBrokerCell(brokerCellName, testNS, WithBrokerCellSetDefaults)),
 				NewEndpoints(brokerCellName+"-brokercell-ingress", testNS,
 					WithEndpointsAddresses(corev1.EndpointAddress{IP: "127.0.0.1"})),
@@ -677,6 +996,7 @@ func TestAllCases(t *testing.T) {
 			Key:  testKey,
 			Objects: []runtime.Object{
 				NewBrokerCell(brokerCellName, testNS, WithBrokerCellSetDefaults),
+				testingdata.EventTypeReports(),
 				NewEndpoints(brokerCellName+"-brokercell-ingress", testNS),
 			},
 			WantCreates: []runtime.Object{
@@ -720,6 +1040,7 @@ func TestAllCases(t *testing.T) {
 			Key:  testKey,
 			Objects: []runtime.Object{
 				NewBrokerCell(brokerCellName, testNS, WithBrokerCellSetDefaults),
+				testingdata.EventTypeReports(),
 				NewBrokerCell(brokerCellName, testNS, WithBrokerCellSetDefaults),
 				NewBroker("broker", testNS, WithBrokerSetDefaults),
 				testingdata.EmptyConfig(t, NewBrokerCell(brokerCellName, testNS, WithBrokerCellSetDefaults)),
@@ -778,6 +1099,12 @@ func TestAllCases(t *testing.T) {
 					WithBrokerCellFanoutUnknown("DeploymentUnavailable", `Deployment "test-brokercell-brokercell-fanout" is unavailable.`),
 					WithBrokerCellRetryUnknown("DeploymentUnavailable", `Deployment "test-brokercell-brokercell-retry" is unavailable.`),
 					WithIngressTemplate("http://test-brokercell-brokercell-ingress.testnamespace.svc.cluster.local/{namespace}/{name}"),
+					WithBrokerCellTenants(intv1alpha1.TenantsStatus{Brokers: 1}),
+					withTargetsConfigSizeOf(testingdata.BrokerCellObjects{
+						BrokersToTriggers: map[*brokerv1.Broker][]*brokerv1.Trigger{
+							NewBroker("broker", testNS, WithBrokerSetDefaults): {},
+						},
+					}),
 					WithBrokerCellSetDefaults,
 				)},
 			},
@@ -798,6 +1125,7 @@ func TestAllCases(t *testing.T) {
 			Key:  testKey,
 			Objects: []runtime.Object{
 				NewBrokerCell(brokerCellName, testNS, WithBrokerCellSetDefaults),
+				testingdata.EventTypeReports(),
 				testingdata.EmptyConfig(t, NewBrokerCell(brokerCellName, testNS, WithBrokerCellSetDefaults)),
 				NewEndpoints(brokerCellName+"-brokercell-ingress", testNS,
 					WithEndpointsAddresses(corev1.EndpointAddress{IP: "127.0.0.1"})),
@@ -829,6 +1157,7 @@ func TestAllCases(t *testing.T) {
 			Key:  testKey,
 			Objects: []runtime.Object{
 				NewBrokerCell(brokerCellName, testNS, WithBrokerCellSetDefaults),
+				testingdata.EventTypeReports(),
 				testingdata.EmptyConfig(t, NewBrokerCell(brokerCellName, testNS, WithBrokerCellSetDefaults)),
 				NewEndpoints(brokerCellName+"-brokercell-ingress", testNS,
 					WithEndpointsAddresses(corev1.EndpointAddress{IP: "127.0.0.1"})),
@@ -852,32 +1181,138 @@ func TestAllCases(t *testing.T) {
 			},
 		},
 		{
-			// TODO(1804): remove this test case when the feature is enabled by default.
-			Name: "BrokerCell with ingress filtering created successfully",
+			Name: "External ingress LoadBalancer Service is created",
 			Key:  testKey,
 			Objects: []runtime.Object{
-				NewBrokerCell(brokerCellName, testNS, WithBrokerCellSetDefaults,
-					WithBrokerCellAnnotations(enableIngressFilteringAnnotation)),
+				NewBrokerCell(brokerCellName, testNS, withLoadBalancerExternalIngress),
+				testingdata.EventTypeReports(),
+				testingdata.EmptyConfig(t, NewBrokerCell(brokerCellName, testNS, WithBrokerCellSetDefaults)),
+				NewEndpoints(brokerCellName+"-brokercell-ingress", testNS,
+					WithEndpointsAddresses(corev1.EndpointAddress{IP: "127.0.0.1"})),
+				testingdata.IngressDeploymentWithStatus(t),
+				testingdata.IngressServiceWithStatus(t),
+				testingdata.FanoutDeploymentWithStatus(t),
+				testingdata.RetryDeploymentWithStatus(t),
+				testingdata.IngressHPA(t),
+				testingdata.FanoutHPA(t),
+				testingdata.RetryHPA(t),
+			},
+			WantCreates: []runtime.Object{
+				testingdata.IngressExternalService(t),
+			},
+			WantStatusUpdates: []clientgotesting.UpdateActionImpl{
+				{Object: NewBrokerCell(brokerCellName, testNS,
+					withLoadBalancerExternalIngress,
+					WithBrokerCellReady,
+					WithIngressTemplate("http://test-brokercell-brokercell-ingress.testnamespace.svc.cluster.local/{namespace}/{name}"),
+				)},
+			},
+			WantEvents: []string{
+				ingressExternalServiceCreatedEvent,
+				brokerCellReconciledEvent,
+			},
+		},
+		{
+			Name: "External ingress is addressable at the LoadBalancer",
+			Key:  testKey,
+			Objects: []runtime.Object{
+				NewBrokerCell(brokerCellName, testNS, withLoadBalancerExternalIngress),
+				testingdata.EventTypeReports(),
 				testingdata.EmptyConfig(t, NewBrokerCell(brokerCellName, testNS, WithBrokerCellSetDefaults)),
 				NewEndpoints(brokerCellName+"-brokercell-ingress", testNS,
 					WithEndpointsAddresses(corev1.EndpointAddress{IP: "127.0.0.1"})),
-				testingdata.IngressDeploymentWithFilteringAnnotation(t),
+				testingdata.IngressDeploymentWithStatus(t),
 				testingdata.IngressServiceWithStatus(t),
 				testingdata.FanoutDeploymentWithStatus(t),
 				testingdata.RetryDeploymentWithStatus(t),
 				testingdata.IngressHPA(t),
 				testingdata.FanoutHPA(t),
 				testingdata.RetryHPA(t),
+				withLoadBalancerIngress(testingdata.IngressExternalService(t), "203.0.113.1"),
 			},
 			WantStatusUpdates: []clientgotesting.UpdateActionImpl{
 				{Object: NewBrokerCell(brokerCellName, testNS,
+					withLoadBalancerExternalIngress,
 					WithBrokerCellReady,
-					WithBrokerCellAnnotations(enableIngressFilteringAnnotation),
 					WithIngressTemplate("http://test-brokercell-brokercell-ingress.testnamespace.svc.cluster.local/{namespace}/{name}"),
+					WithExternalIngressTemplate("http://203.0.113.1/{namespace}/{name}"),
+				)},
+			},
+			WantEvents: []string{
+				brokerCellReconciledEvent,
+			},
+		},
+		{
+			Name: "External ingress LoadBalancer Service drops the removed source ranges",
+			Key:  testKey,
+			Objects: []runtime.Object{
+				NewBrokerCell(brokerCellName, testNS, withLoadBalancerExternalIngress),
+				testingdata.EventTypeReports(),
+				testingdata.EmptyConfig(t, NewBrokerCell(brokerCellName, testNS, WithBrokerCellSetDefaults)),
+				NewEndpoints(brokerCellName+"-brokercell-ingress", testNS,
+					WithEndpointsAddresses(corev1.EndpointAddress{IP: "127.0.0.1"})),
+				testingdata.IngressDeploymentWithStatus(t),
+				testingdata.IngressServiceWithStatus(t),
+				testingdata.FanoutDeploymentWithStatus(t),
+				testingdata.RetryDeploymentWithStatus(t),
+				testingdata.IngressHPA(t),
+				testingdata.FanoutHPA(t),
+				testingdata.RetryHPA(t),
+				withLoadBalancerSourceRanges(testingdata.IngressExternalService(t), "203.0.113.0/24", "0.0.0.0/0"),
+			},
+			WantUpdates: []clientgotesting.UpdateActionImpl{
+				{Object: testingdata.IngressExternalService(t)},
+			},
+			WantStatusUpdates: []clientgotesting.UpdateActionImpl{
+				{Object: NewBrokerCell(brokerCellName, testNS,
+					withLoadBalancerExternalIngress,
+					WithBrokerCellReady,
+					WithIngressTemplate("http://test-brokercell-brokercell-ingress.testnamespace.svc.cluster.local/{namespace}/{name}"),
+				)},
+			},
+			WantEvents: []string{
+				Eventf(corev1.EventTypeNormal, "ServiceUpdated", "Updated service testnamespace/test-brokercell-brokercell-ingress-external"),
+				brokerCellReconciledEvent,
+			},
+		},
+		{
+			Name: "External ingress LoadBalancer Service is deleted once the external ingress is removed",
+			Key:  testKey,
+			Objects: []runtime.Object{
+				NewBrokerCell(brokerCellName, testNS, WithBrokerCellSetDefaults,
+					WithExternalIngressTemplate("http://203.0.113.1/{namespace}/{name}")),
+				testingdata.EventTypeReports(),
+				testingdata.EmptyConfig(t, NewBrokerCell(brokerCellName, testNS, WithBrokerCellSetDefaults)),
+				NewEndpoints(brokerCellName+"-brokercell-ingress", testNS,
+					WithEndpointsAddresses(corev1.EndpointAddress{IP: "127.0.0.1"})),
+				testingdata.IngressDeploymentWithStatus(t),
+				testingdata.IngressServiceWithStatus(t),
+				testingdata.FanoutDeploymentWithStatus(t),
+				testingdata.RetryDeploymentWithStatus(t),
+				testingdata.IngressHPA(t),
+				testingdata.FanoutHPA(t),
+				testingdata.RetryHPA(t),
+				testingdata.IngressExternalService(t),
+			},
+			WantDeletes: []clientgotesting.DeleteActionImpl{
+				{
+					ActionImpl: clientgotesting.ActionImpl{
+						Namespace: testNS,
+						Verb:      "delete",
+						Resource:  corev1.SchemeGroupVersion.WithResource("services"),
+					},
+					Name: brokerCellName + "-brokercell-ingress-external",
+				},
+			},
+			WantStatusUpdates: []clientgotesting.UpdateActionImpl{
+				{Object: NewBrokerCell(brokerCellName, testNS,
 					WithBrokerCellSetDefaults,
+					WithBrokerCellReady,
+					WithIngressTemplate("http://test-brokercell-brokercell-ingress.testnamespace.svc.cluster.local/{namespace}/{name}"),
 				)},
 			},
 			WantEvents: []string{
+				Eventf(corev1.EventTypeNormal, "ServiceDeleted", "Deleted service testnamespace/test-brokercell-brokercell-ingress-external"),
 				brokerCellReconciledEvent,
 			},
 		},
@@ -888,6 +1323,7 @@ func TestAllCases(t *testing.T) {
 				NewBrokerCell(brokerCellName, testNS,
 					WithBrokerCellAnnotations(creatorAnnotation),
 					WithBrokerCellSetDefaults),
+				testingdata.EventTypeReports(),
 				testingdata.Config(NewBrokerCell(brokerCellName, testNS, WithBrokerCellSetDefaults),
 					testingdata.BrokerCellObjects{
 						BrokersToTriggers: map[*brokerv1.Broker][]*brokerv1.Trigger{
@@ -910,6 +1346,12 @@ func TestAllCases(t *testing.T) {
 					WithBrokerCellAnnotations(creatorAnnotation),
 					WithBrokerCellReady,
 					WithIngressTemplate("http://test-brokercell-brokercell-ingress.testnamespace.svc.cluster.local/{namespace}/{name}"),
+					WithBrokerCellTenants(intv1alpha1.TenantsStatus{Brokers: 1}),
+					withTargetsConfigSizeOf(testingdata.BrokerCellObjects{
+						BrokersToTriggers: map[*brokerv1.Broker][]*brokerv1.Trigger{
+							NewBroker("broker", testNS, WithBrokerSetDefaults): {},
+						},
+					}),
 					WithBrokerCellSetDefaults,
 				)},
 			},
@@ -924,6 +1366,7 @@ func TestAllCases(t *testing.T) {
 				NewBrokerCell(brokerCellName, testNS,
 					WithBrokerCellAnnotations(creatorAnnotation),
 					WithBrokerCellSetDefaults),
+				testingdata.EventTypeReports(),
 				testingdata.Config(NewBrokerCell(brokerCellName, testNS, WithBrokerCellSetDefaults),
 					testingdata.BrokerCellObjects{
 						Channels: []*v1beta1.Channel{
@@ -946,6 +1389,12 @@ func TestAllCases(t *testing.T) {
 					WithBrokerCellAnnotations(creatorAnnotation),
 					WithBrokerCellReady,
 					WithIngressTemplate("http://test-brokercell-brokercell-ingress.testnamespace.svc.cluster.local/{namespace}/{name}"),
+					WithBrokerCellTenants(intv1alpha1.TenantsStatus{Channels: 1}),
+					withTargetsConfigSizeOf(testingdata.BrokerCellObjects{
+						Channels: []*v1beta1.Channel{
+							NewChannel("channel", testNS, WithChannelSetDefaults, WithChannelAddress("http://example.com")),
+						},
+					}),
 					WithBrokerCellSetDefaults,
 				)},
 			},
@@ -1001,6 +1450,7 @@ func TestAllCases(t *testing.T) {
 			Objects: []runtime.Object{
 				NewBrokerCell(brokerCellName, testNS, WithBrokerCellSetDefaults,
 					WithBrokerCellAnnotations(restartedTimeAnnotation)),
+				testingdata.EventTypeReports(),
 				testingdata.EmptyConfig(t, NewBrokerCell(brokerCellName, testNS, WithBrokerCellSetDefaults)),
 				NewEndpoints(brokerCellName+"-brokercell-ingress", testNS,
 					WithEndpointsAddresses(corev1.EndpointAddress{IP: "127.0.0.1"})),
@@ -1038,27 +1488,64 @@ func TestAllCases(t *testing.T) {
 		setReconcilerEnv()
 		base := reconciler.NewBase(ctx, controllerAgentName, cmw)
 		ls := listers{
-			brokerLister:         testingListers.GetBrokerLister(),
-			channelLister:        testingListers.GetChannelLister(),
-			hpaLister:            testingListers.GetHPALister(),
-			triggerLister:        testingListers.GetTriggerLister(),
-			configMapLister:      testingListers.GetConfigMapLister(),
-			secretLister:         testingListers.GetSecretLister(),
-			serviceAccountLister: testingListers.GetServiceAccountLister(),
-			serviceLister:        testingListers.GetK8sServiceLister(),
-			endpointsLister:      testingListers.GetEndpointsLister(),
-			deploymentLister:     testingListers.GetDeploymentLister(),
-			podLister:            testingListers.GetPodLister(),
+			brokerLister:               testingListers.GetBrokerLister(),
+			channelLister:              testingListers.GetChannelLister(),
+			hpaLister:                  testingListers.GetHPALister(),
+			pdbLister:                  testingListers.GetPodDisruptionBudgetLister(),
+			triggerLister:              testingListers.GetTriggerLister(),
+			configMapLister:            testingListers.GetConfigMapLister(),
+			secretLister:               testingListers.GetSecretLister(),
+			deliveryHeaderSecretLister: testingListers.GetSecretLister(),
+			serviceAccountLister:       testingListers.GetServiceAccountLister(),
+			serviceLister:              testingListers.GetK8sServiceLister(),
+			endpointsLister:            testingListers.GetEndpointsLister(),
+			deploymentLister:           testingListers.GetDeploymentLister(),
+			podLister:                  testingListers.GetPodLister(),
 		}
 
 		r, err := NewReconciler(base, ls)
 		if err != nil {
 			t.Fatalf("Failed to created BrokerCell reconciler: %v", err)
 		}
+		r.clusterName = clusterName
 		return bcreconciler.NewReconciler(ctx, r.Logger, r.RunClientSet, testingListers.GetBrokerCellLister(), r.Recorder, r)
 	}))
 }
 
+func withFanoutBacklogAutoscaling(bc *intv1alpha1.BrokerCell) {
+	bc.SetDefaults(context.Background())
+	bc.Spec.Components.Fanout.AutoscalingMode = intv1alpha1.BacklogAutoscaling
+}
+
+// withTargetsConfigSizeOf sets the targets config size of the BrokerCell status
+// to the size of the targets config generated for the given objects.
+func withTargetsConfigSizeOf(objs testingdata.BrokerCellObjects) BrokerCellOption {
+	return WithTargetsConfigSize(resources.TargetsConfigSize(
+		testingdata.Config(NewBrokerCell(brokerCellName, testNS, WithBrokerCellSetDefaults), objs)))
+}
+
+func withFanoutMaxUnavailable(bc *intv1alpha1.BrokerCell) {
+	maxUnavailable := intstr.FromInt(1)
+	bc.Spec.Components.Fanout.MaxUnavailable = &maxUnavailable
+}
+
+func withLoadBalancerExternalIngress(bc *intv1alpha1.BrokerCell) {
+	bc.Spec.ExternalIngress = &intv1alpha1.ExternalIngressSpec{
+		LoadBalancerSourceRanges: []string{"203.0.113.0/24"},
+	}
+	bc.SetDefaults(context.Background())
+}
+
+func withLoadBalancerSourceRanges(svc *corev1.Service, ranges ...string) *corev1.Service {
+	svc.Spec.LoadBalancerSourceRanges = ranges
+	return svc
+}
+
+func withLoadBalancerIngress(svc *corev1.Service, ip string) *corev1.Service {
+	svc.Status.LoadBalancer.Ingress = []corev1.LoadBalancerIngress{{IP: ip}}
+	return svc
+}
+
 func emptyHPASpec(template *hpav2beta2.HorizontalPodAutoscaler) *hpav2beta2.HorizontalPodAutoscaler {
 	template.Spec = hpav2beta2.HorizontalPodAutoscalerSpec{}
 	return template
@@ -1087,6 +1574,76 @@ func TestBrokerTargetsReconcileConfig(t *testing.T) {
 			expectEmptyMap: false,
 		},
 
+		{
+			name: "reconcile config of a broker with a dead letter sink and triggers classifying responses",
+			broker: NewBroker("broker", testNS, WithBrokerClass(brokerv1.BrokerClass),
+				WithBrokerDeliverySpec(&eventingduckv1.DeliverySpec{
+					DeadLetterSink: &duckv1.Destination{URI: uri("pubsub://dead-letter-topic")},
+				})),
+			triggers: []*brokerv1.Trigger{
+				NewTrigger("trigger1", testNS, "broker", WithTriggerSetDefaults,
+					WithTriggerAnnotation(brokerv1.SuccessResponseCodesAnnotationKey, "409"),
+					WithTriggerAnnotation(brokerv1.NonRetryableResponseCodesAnnotationKey, "400,415")),
+				NewTrigger("trigger2", testNS, "broker", WithTriggerSetDefaults),
+			},
+			bc: NewBrokerCell(brokerCellName, testNS, WithBrokerCellSetDefaults),
+		},
+		{
+			name: "reconcile config of a broker and triggers with a max event age",
+			broker: NewBroker("broker", testNS, WithBrokerClass(brokerv1.BrokerClass),
+				WithBrokerAnnotation(brokerv1.MaxEventAgeAnnotationKey, "PT1H")),
+			triggers: []*brokerv1.Trigger{
+				NewTrigger("trigger1", testNS, "broker", WithTriggerSetDefaults,
+					WithTriggerAnnotation(brokerv1.MaxEventAgeAnnotationKey, "PT5M")),
+				NewTrigger("trigger2", testNS, "broker", WithTriggerSetDefaults),
+			},
+			bc: NewBrokerCell(brokerCellName, testNS, WithBrokerCellSetDefaults),
+		},
+		{
+			name:   "reconcile config of a broker and triggers with delivery format and headers",
+			broker: NewBroker("broker", testNS, WithBrokerClass(brokerv1.BrokerClass)),
+			triggers: []*brokerv1.Trigger{
+				NewTrigger("trigger1", testNS, "broker", WithTriggerSetDefaults,
+					WithTriggerAnnotation(brokerv1.DeliveryFormatAnnotationKey, brokerv1.DeliveryFormatStructured),
+					WithTriggerAnnotation(brokerv1.DeliveryHeadersAnnotationKey, `{"X-Tenant-Id": "tenant-1"}`),
+					WithTriggerAnnotation(brokerv1.DeliveryHeaderSecretsAnnotationKey, `{"X-Api-Key": "credentials/api-key"}`)),
+				NewTrigger("trigger2", testNS, "broker", WithTriggerSetDefaults),
+			},
+			bc: NewBrokerCell(brokerCellName, testNS, WithBrokerCellSetDefaults),
+		},
+		{
+			name:   "reconcile config of a broker and triggers publishing to pubsub topics",
+			broker: NewBroker("broker", testNS, WithBrokerClass(brokerv1.BrokerClass)),
+			triggers: []*brokerv1.Trigger{
+				NewTrigger("trigger1", testNS, "broker", WithTriggerSetDefaults,
+					WithTriggerStatusSubscriberURI("pubsub://other-project/events"),
+					WithTriggerAnnotation(brokerv1.PubsubOrderingKeyAnnotationKey, "subject"),
+					WithTriggerAnnotation(brokerv1.PubsubAttributesAnnotationKey, `{"type": "eventType"}`)),
+				NewTrigger("trigger2", testNS, "broker", WithTriggerSetDefaults),
+			},
+			bc: NewBrokerCell(brokerCellName, testNS, WithBrokerCellSetDefaults),
+		},
+		{
+			name:   "reconcile config of a broker and a trigger streaming into a bigquery table",
+			broker: NewBroker("broker", testNS, WithBrokerClass(brokerv1.BrokerClass)),
+			triggers: []*brokerv1.Trigger{
+				NewTrigger("trigger1", testNS, "broker", WithTriggerSetDefaults,
+					WithTriggerStatusSubscriberURI("bigquery://other-project/analytics/events"),
+					WithTriggerAnnotation(brokerv1.BigQueryIgnoreUnknownValuesAnnotationKey, "true")),
+			},
+			bc: NewBrokerCell(brokerCellName, testNS, WithBrokerCellSetDefaults),
+		},
+		{
+			name: "reconcile config of a broker consolidating the retries of its triggers",
+			broker: NewBroker("broker", testNS, WithBrokerClass(brokerv1.BrokerClass),
+				WithBrokerAnnotation(brokerv1.RetryTopicModeAnnotationKey, brokerv1.RetryTopicModeConsolidated)),
+			triggers: []*brokerv1.Trigger{
+				NewTrigger("trigger1", testNS, "broker", WithTriggerSetDefaults,
+					WithTriggerRetrySubscriptionDraining("cre-tgr_testnamespace_trigger1_", time.Now().Add(time.Hour))),
+				NewTrigger("trigger2", testNS, "broker", WithTriggerSetDefaults),
+			},
+			bc: NewBrokerCell(brokerCellName, testNS, WithBrokerCellSetDefaults),
+		},
 		{
 			name:   "reconcile config when the broker is not gcp broker",
 			broker: NewBroker("broker", testNS, WithBrokerClass("some-other-broker-class")),
@@ -1176,7 +1733,7 @@ func TestBrokerTargetsReconcileConfig(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			setReconcilerEnv()
 			bc := NewBrokerCell(brokerCellName, testNS, WithBrokerCellSetDefaults)
-			ctx, _ := SetupFakeContext(t)
+			ctx, _ := SetupFakeContext(t, withDeliveryHeaderSecretsSelector)
 			cmw := configmap.NewStaticWatcher()
 			ctx, client := fakekubeclient.With(ctx)
 			base := reconciler.NewBase(ctx, controllerAgentName, cmw)
@@ -1192,15 +1749,17 @@ func TestBrokerTargetsReconcileConfig(t *testing.T) {
 			}
 			testingListers := NewListers(objects)
 			ls := listers{
-				brokerLister:     testingListers.GetBrokerLister(),
-				channelLister:    testingListers.GetChannelLister(),
-				hpaLister:        testingListers.GetHPALister(),
-				triggerLister:    testingListers.GetTriggerLister(),
-				configMapLister:  testingListers.GetConfigMapLister(),
-				serviceLister:    testingListers.GetK8sServiceLister(),
-				endpointsLister:  testingListers.GetEndpointsLister(),
-				deploymentLister: testingListers.GetDeploymentLister(),
-				podLister:        testingListers.GetPodLister(),
+				brokerLister:               testingListers.GetBrokerLister(),
+				channelLister:              testingListers.GetChannelLister(),
+				hpaLister:                  testingListers.GetHPALister(),
+				pdbLister:                  testingListers.GetPodDisruptionBudgetLister(),
+				triggerLister:              testingListers.GetTriggerLister(),
+				configMapLister:            testingListers.GetConfigMapLister(),
+				deliveryHeaderSecretLister: testingListers.GetSecretLister(),
+				serviceLister:              testingListers.GetK8sServiceLister(),
+				endpointsLister:            testingListers.GetEndpointsLister(),
+				deploymentLister:           testingListers.GetDeploymentLister(),
+				podLister:                  testingListers.GetPodLister(),
 			}
 			r, err := NewReconciler(base, ls)
 			if err != nil {
@@ -1249,3 +1808,94 @@ func uri(uri string) *apis.URL {
 	url, _ := apis.ParseURL(uri)
 	return url
 }
+
+func TestReconcileDeliveryHeaders(t *testing.T) {
+	setReconcilerEnv()
+	bc := NewBrokerCell(brokerCellName, testNS, WithBrokerCellSetDefaults)
+	broker := NewBroker("broker", testNS, WithBrokerClass(brokerv1.BrokerClass))
+	trigger := NewTrigger("trigger", testNS, "broker", WithTriggerSetDefaults,
+		WithTriggerAnnotation(brokerv1.DeliveryHeaderSecretsAnnotationKey,
+			`{"X-Api-Key": "credentials/api-key", "X-Missing": "credentials/missing", "X-Token": "unlabeled/token"}`))
+	labeled := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "credentials",
+			Namespace: testNS,
+			Labels:    map[string]string{brokerv1.DeliveryHeaderSecretLabelKey: "true"},
+		},
+		Data: map[string][]byte{"api-key": []byte("secret-value")},
+	}
+	unlabeled := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "unlabeled", Namespace: testNS},
+		Data:       map[string][]byte{"token": []byte("token-value")},
+	}
+	ctx, _ := SetupFakeContext(t, withDeliveryHeaderSecretsSelector)
+	ctx, client := fakekubeclient.With(ctx)
+	base := reconciler.NewBase(ctx, controllerAgentName, configmap.NewStaticWatcher())
+	testingListers := NewListers([]runtime.Object{bc, broker, trigger, labeled, unlabeled})
+	r, err := NewReconciler(base, listers{
+		brokerLister:               testingListers.GetBrokerLister(),
+		channelLister:              testingListers.GetChannelLister(),
+		triggerLister:              testingListers.GetTriggerLister(),
+		configMapLister:            testingListers.GetConfigMapLister(),
+		deliveryHeaderSecretLister: testingListers.GetSecretLister(),
+		podLister:                  testingListers.GetPodLister(),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create BrokerCell reconciler: %v", err)
+	}
+
+	if err := r.reconcileConfig(ctx, bc); err != nil {
+		t.Fatalf("Failed to reconcile config: %v", err)
+	}
+	secret, err := client.CoreV1().Secrets(testNS).Get(ctx, resources.DeliveryHeadersSecretName(bc.Name), metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get the delivery headers Secret: %v", err)
+	}
+	// The missing key and the Secret without the opt-in label are skipped.
+	want := map[string][]byte{
+		resources.DeliveryHeaderSecretKey(testNS, "credentials", "api-key"): []byte("secret-value"),
+	}
+	if diff := cmp.Diff(want, secret.Data); diff != "" {
+		t.Errorf("Unexpected delivery headers Secret data (-want, +got): %s", diff)
+	}
+
+	// The Secret is deleted once no Trigger references a Secret.
+	if err := r.reconcileDeliveryHeaders(ctx, bc, memory.NewEmptyTargets()); err != nil {
+		t.Fatalf("Failed to reconcile delivery headers: %v", err)
+	}
+	if _, err := client.CoreV1().Secrets(testNS).Get(ctx, resources.DeliveryHeadersSecretName(bc.Name), metav1.GetOptions{}); !apierrs.IsNotFound(err) {
+		t.Errorf("Delivery headers Secret got error %v, want not found", err)
+	}
+}
+
+func TestReportReplicaLimit(t *testing.T) {
+	atMax := &hpav2beta2.HorizontalPodAutoscaler{
+		Spec:   hpav2beta2.HorizontalPodAutoscalerSpec{MaxReplicas: 10},
+		Status: hpav2beta2.HorizontalPodAutoscalerStatus{DesiredReplicas: 12},
+	}
+	belowMax := &hpav2beta2.HorizontalPodAutoscaler{
+		Spec:   hpav2beta2.HorizontalPodAutoscalerSpec{MaxReplicas: 10},
+		Status: hpav2beta2.HorizontalPodAutoscalerStatus{DesiredReplicas: 3},
+	}
+	tests := []struct {
+		name             string
+		wasAtMaxReplicas bool
+		hpa              *hpav2beta2.HorizontalPodAutoscaler
+		wantEvents       int
+	}{
+		{name: "reaches the maximum", hpa: atMax, wantEvents: 1},
+		{name: "stays at the maximum", wasAtMaxReplicas: true, hpa: atMax},
+		{name: "below the maximum", hpa: belowMax},
+		{name: "no HPA"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(1)
+			r := &Reconciler{Base: &reconciler.Base{Recorder: recorder}}
+			r.reportReplicaLimit(NewBrokerCell(brokerCellName, testNS), resources.FanoutName, tc.wasAtMaxReplicas, tc.hpa)
+			if got := len(recorder.Events); got != tc.wantEvents {
+				t.Errorf("Got %d events, want %d", got, tc.wantEvents)
+			}
+		})
+	}
+}
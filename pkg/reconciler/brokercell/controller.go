@@ -32,6 +32,7 @@ import (
 	triggerinformer "github.com/google/knative-gcp/pkg/client/injection/informers/broker/v1/trigger"
 	brokercellinformer "github.com/google/knative-gcp/pkg/client/injection/informers/intevents/v1alpha1/brokercell"
 	hpainformer "github.com/google/knative-gcp/pkg/client/injection/kube/informers/autoscaling/v2beta2/horizontalpodautoscaler"
+	pdbinformer "github.com/google/knative-gcp/pkg/client/injection/kube/informers/policy/v1beta1/poddisruptionbudget"
 	v1alpha1brokercell "github.com/google/knative-gcp/pkg/client/injection/reconciler/intevents/v1alpha1/brokercell"
 	"github.com/google/knative-gcp/pkg/logging"
 	"github.com/google/knative-gcp/pkg/metrics"
@@ -49,6 +50,7 @@ import (
 	configmapinformer "knative.dev/pkg/client/injection/kube/informers/core/v1/configmap"
 	endpointsinformer "knative.dev/pkg/client/injection/kube/informers/core/v1/endpoints"
 	podinformer "knative.dev/pkg/client/injection/kube/informers/core/v1/pod"
+	filteredsecretinformer "knative.dev/pkg/client/injection/kube/informers/core/v1/secret/filtered"
 	serviceinformer "knative.dev/pkg/client/injection/kube/informers/core/v1/service"
 	serviceaccountinformer "knative.dev/pkg/client/injection/kube/informers/core/v1/serviceaccount"
 	"knative.dev/pkg/configmap"
@@ -84,17 +86,19 @@ func NewController(
 	logger := logging.FromContext(ctx)
 
 	ls := listers{
-		brokerLister:         brokerinformer.Get(ctx).Lister(),
-		channelLister:        channelinformer.Get(ctx).Lister(),
-		hpaLister:            hpainformer.Get(ctx).Lister(),
-		triggerLister:        triggerinformer.Get(ctx).Lister(),
-		configMapLister:      configmapinformer.Get(ctx).Lister(),
-		secretLister:         systemnamespacesecretinformer.Get(ctx).Lister(),
-		serviceAccountLister: serviceaccountinformer.Get(ctx).Lister(),
-		serviceLister:        serviceinformer.Get(ctx).Lister(),
-		endpointsLister:      endpointsinformer.Get(ctx).Lister(),
-		deploymentLister:     deploymentinformer.Get(ctx).Lister(),
-		podLister:            podinformer.Get(ctx).Lister(),
+		brokerLister:               brokerinformer.Get(ctx).Lister(),
+		channelLister:              channelinformer.Get(ctx).Lister(),
+		hpaLister:                  hpainformer.Get(ctx).Lister(),
+		pdbLister:                  pdbinformer.Get(ctx).Lister(),
+		triggerLister:              triggerinformer.Get(ctx).Lister(),
+		configMapLister:            configmapinformer.Get(ctx).Lister(),
+		secretLister:               systemnamespacesecretinformer.Get(ctx).Lister(),
+		deliveryHeaderSecretLister: filteredsecretinformer.Get(ctx, DeliveryHeaderSecretsSelector).Lister(),
+		serviceAccountLister:       serviceaccountinformer.Get(ctx).Lister(),
+		serviceLister:              serviceinformer.Get(ctx).Lister(),
+		endpointsLister:            endpointsinformer.Get(ctx).Lister(),
+		deploymentLister:           deploymentinformer.Get(ctx).Lister(),
+		podLister:                  podinformer.Get(ctx).Lister(),
 	}
 
 	base := reconciler.NewBase(ctx, controllerAgentName, cmw)
@@ -157,6 +161,10 @@ func NewController(
 	hpainformer.Get(ctx).Informer().AddEventHandler(handleResourceUpdate(impl))
 	// 4. Watch the broker targets configmap.
 	configmapinformer.Get(ctx).Informer().AddEventHandler(handleResourceUpdate(impl))
+	// 5. Watch pdb for ingress, fanout and retry deployments
+	pdbinformer.Get(ctx).Informer().AddEventHandler(handleResourceUpdate(impl))
+	// 6. Watch services for the external ingress load balancer
+	serviceinformer.Get(ctx).Informer().AddEventHandler(handleResourceUpdate(impl))
 
 	// Watch componets which are not created by brokercell, but affect broker data plane.
 	// 1. Watch broker data plane's secret,
@@ -171,6 +179,14 @@ func NewController(
 		FilterFunc: filterWithNamespace(authcheck.ControlPlaneNamespace),
 		Handler:    authcheck.EnqueueBrokerCell(impl, brokerCellLister),
 	})
+	// 3. Watch the Secrets opted into delivery headers, so that rotated values are copied into
+	// the delivery headers Secret of the brokercell.
+	filteredsecretinformer.Get(ctx, DeliveryHeaderSecretsSelector).Informer().AddEventHandler(controller.HandleAll(
+		func(interface{}) {
+			// TODO(#866) Select the brokercell that's associated with the given secret.
+			impl.EnqueueKey(types.NamespacedName{Namespace: system.Namespace(), Name: brokerresources.DefaultBrokerCellName})
+		},
+	))
 	return impl
 }
 
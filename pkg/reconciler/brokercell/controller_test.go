@@ -17,12 +17,14 @@ limitations under the License.
 package brokercell
 
 import (
+	"context"
 	"os"
 	"testing"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
+	filteredinformerfactory "knative.dev/pkg/client/injection/kube/informers/factory/filtered"
 	"knative.dev/pkg/configmap"
 	"knative.dev/pkg/logging"
 	"knative.dev/pkg/metrics"
@@ -35,8 +37,10 @@ import (
 	_ "knative.dev/pkg/client/injection/kube/informers/core/v1/configmap/fake"
 	_ "knative.dev/pkg/client/injection/kube/informers/core/v1/endpoints/fake"
 	_ "knative.dev/pkg/client/injection/kube/informers/core/v1/pod/fake"
+	_ "knative.dev/pkg/client/injection/kube/informers/core/v1/secret/filtered/fake"
 	_ "knative.dev/pkg/client/injection/kube/informers/core/v1/service/fake"
 	_ "knative.dev/pkg/client/injection/kube/informers/core/v1/serviceaccount/fake"
+	_ "knative.dev/pkg/client/injection/kube/informers/factory/filtered/fake"
 	_ "knative.dev/pkg/injection/clients/namespacedkube/informers/core/v1/secret/fake"
 
 	// Fake injection informers
@@ -45,10 +49,11 @@ import (
 	_ "github.com/google/knative-gcp/pkg/client/injection/informers/intevents/v1alpha1/brokercell/fake"
 	_ "github.com/google/knative-gcp/pkg/client/injection/informers/messaging/v1beta1/channel/fake"
 	_ "github.com/google/knative-gcp/pkg/client/injection/kube/informers/autoscaling/v2beta2/horizontalpodautoscaler/fake"
+	_ "github.com/google/knative-gcp/pkg/client/injection/kube/informers/policy/v1beta1/poddisruptionbudget/fake"
 )
 
 func TestNew(t *testing.T) {
-	ctx, _ := SetupFakeContext(t)
+	ctx, _ := SetupFakeContext(t, withDeliveryHeaderSecretsSelector)
 
 	setReconcilerEnv()
 
@@ -87,3 +92,9 @@ func setReconcilerEnv() {
 	_ = os.Setenv("BROKER_CELL_RETRY_IMAGE", "retry")
 	_ = os.Setenv("INTERNAL_METRICS_ENABLED", "false")
 }
+
+// withDeliveryHeaderSecretsSelector sets up the selector of the filtered Secret informer, whose
+// fake is set up by SetupFakeContext.
+func withDeliveryHeaderSecretsSelector(ctx context.Context) context.Context {
+	return filteredinformerfactory.WithSelectors(ctx, DeliveryHeaderSecretsSelector)
+}
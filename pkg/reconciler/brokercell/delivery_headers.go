@@ -0,0 +1,135 @@
+/*
+Copyright 2021 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package brokercell
+
+import (
+	"context"
+	"fmt"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	brokerv1 "github.com/google/knative-gcp/pkg/apis/broker/v1"
+	intv1alpha1 "github.com/google/knative-gcp/pkg/apis/intevents/v1alpha1"
+	"github.com/google/knative-gcp/pkg/broker/config"
+	"github.com/google/knative-gcp/pkg/logging"
+	"github.com/google/knative-gcp/pkg/reconciler/brokercell/resources"
+)
+
+const (
+	deliveryHeadersSecretCreated = "DeliveryHeadersSecretCreated"
+	deliveryHeadersSecretUpdated = "DeliveryHeadersSecretUpdated"
+	deliveryHeadersSecretDeleted = "DeliveryHeadersSecretDeleted"
+)
+
+// DeliveryHeaderSecretsSelector selects the Secrets opted into being read for delivery headers.
+// The controller only watches these Secrets.
+const DeliveryHeaderSecretsSelector = brokerv1.DeliveryHeaderSecretLabelKey + "=true"
+
+// reconcileDeliveryHeaders copies the values of the Secrets referenced by the delivery headers of
+// the targets into the delivery headers Secret of the BrokerCell, which is mounted into the fanout
+// and retry Pods. The Secret is deleted when no target references a Secret. Changes to the
+// referenced Secrets enqueue the BrokerCell, see NewConstructor.
+func (r *Reconciler) reconcileDeliveryHeaders(ctx context.Context, bc *intv1alpha1.BrokerCell, targets config.ReadonlyTargets) error {
+	keys := make(map[string]struct{})
+	targets.RangeAllTargets(func(t *config.Target) bool {
+		for _, k := range t.DeliveryHeaderSecrets {
+			keys[k] = struct{}{}
+		}
+		return true
+	})
+
+	client := r.KubeClientSet.CoreV1().Secrets(bc.Namespace)
+	name := resources.DeliveryHeadersSecretName(bc.Name)
+	current, err := client.Get(ctx, name, metav1.GetOptions{})
+	if err != nil && !apierrs.IsNotFound(err) {
+		return fmt.Errorf("failed to get the delivery headers Secret: %w", err)
+	}
+	exists := err == nil
+
+	if len(keys) == 0 {
+		if !exists {
+			return nil
+		}
+		if err := client.Delete(ctx, name, metav1.DeleteOptions{}); err != nil && !apierrs.IsNotFound(err) {
+			return fmt.Errorf("failed to delete the delivery headers Secret: %w", err)
+		}
+		r.Recorder.Eventf(bc, corev1.EventTypeNormal, deliveryHeadersSecretDeleted, "Deleted secret %s/%s", bc.Namespace, name)
+		return nil
+	}
+
+	data, err := r.deliveryHeaderValues(ctx, keys)
+	if err != nil {
+		return err
+	}
+	desired := resources.MakeDeliveryHeadersSecret(bc, data)
+	if !exists {
+		if _, err := client.Create(ctx, desired, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("failed to create the delivery headers Secret: %w", err)
+		}
+		r.Recorder.Eventf(bc, corev1.EventTypeNormal, deliveryHeadersSecretCreated, "Created secret %s/%s", bc.Namespace, name)
+		return nil
+	}
+	if equality.Semantic.DeepEqual(current.Data, desired.Data) {
+		return nil
+	}
+	updated := current.DeepCopy()
+	updated.Data = desired.Data
+	if _, err := client.Update(ctx, updated, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to update the delivery headers Secret: %w", err)
+	}
+	r.Recorder.Eventf(bc, corev1.EventTypeNormal, deliveryHeadersSecretUpdated, "Updated secret %s/%s", bc.Namespace, name)
+	return nil
+}
+
+// deliveryHeaderValues reads the values of the referenced Secret keys. Only Secrets labeled with
+// brokerv1.DeliveryHeaderSecretLabelKey are read, so that a Trigger cannot copy arbitrary Secrets
+// of its namespace into the BrokerCell. Missing or unlabeled Secrets and missing keys are skipped,
+// so that a Trigger referencing them only fails its own deliveries.
+func (r *Reconciler) deliveryHeaderValues(ctx context.Context, keys map[string]struct{}) (map[string][]byte, error) {
+	data := make(map[string][]byte, len(keys))
+	for k := range keys {
+		ns, secretName, key, err := resources.ParseDeliveryHeaderSecretKey(k)
+		if err != nil {
+			return nil, err
+		}
+		nn := types.NamespacedName{Namespace: ns, Name: secretName}
+		s, err := r.deliveryHeaderSecretLister.Secrets(ns).Get(secretName)
+		if apierrs.IsNotFound(err) {
+			logging.FromContext(ctx).Warn("Secret referenced by delivery headers not found", zap.String("secret", nn.String()))
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to get Secret %s referenced by delivery headers: %w", nn, err)
+		}
+		if s.Labels[brokerv1.DeliveryHeaderSecretLabelKey] != "true" {
+			logging.FromContext(ctx).Warn("Secret referenced by delivery headers is not labeled for it",
+				zap.String("secret", nn.String()), zap.String("label", brokerv1.DeliveryHeaderSecretLabelKey))
+			continue
+		}
+		v, ok := s.Data[key]
+		if !ok {
+			logging.FromContext(ctx).Warn("Secret key referenced by delivery headers not found", zap.String("secret", nn.String()), zap.String("key", key))
+			continue
+		}
+		data[k] = v
+	}
+	return data, nil
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2021 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package brokercell
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	intv1alpha1 "github.com/google/knative-gcp/pkg/apis/intevents/v1alpha1"
+	"github.com/google/knative-gcp/pkg/reconciler/brokercell/resources"
+)
+
+// reconcileExternalIngress exposes the ingress outside of the cluster as specified by the
+// BrokerCell, and sets the IngressTemplate of the external ingress. The template is left empty
+// until the load balancer is provisioned, so that the Brokers keep the in-cluster address.
+func (r *Reconciler) reconcileExternalIngress(ctx context.Context, bc *intv1alpha1.BrokerCell, args resources.IngressArgs) error {
+	ei := bc.Spec.ExternalIngress
+	if ei == nil {
+		bc.Status.SetExternalIngressTemplate("")
+		return r.deleteExternalIngressService(ctx, bc)
+	}
+
+	svc, err := r.reconcileExternalIngressService(ctx, bc, resources.MakeExternalIngressService(args))
+	if err != nil {
+		return err
+	}
+	host := ei.HostTemplate
+	if host == "" {
+		host = resources.LoadBalancerHost(svc)
+	}
+	if host == "" {
+		bc.Status.SetExternalIngressTemplate("")
+		return nil
+	}
+	bc.Status.SetExternalIngressTemplate(resources.ExternalIngressTemplate(ei, host))
+	return nil
+}
+
+func (r *Reconciler) reconcileExternalIngressService(ctx context.Context, bc *intv1alpha1.BrokerCell, desired *corev1.Service) (*corev1.Service, error) {
+	existing, err := r.serviceLister.Services(desired.Namespace).Get(desired.Name)
+	if apierrs.IsNotFound(err) {
+		svc, err := r.KubeClientSet.CoreV1().Services(desired.Namespace).Create(ctx, desired, metav1.CreateOptions{})
+		if err == nil {
+			r.Recorder.Eventf(bc, corev1.EventTypeNormal, "ServiceCreated", "Created service %s/%s", desired.Namespace, desired.Name)
+		}
+		if apierrs.IsAlreadyExists(err) {
+			// The informer is not updated yet, the Service is reconciled again once it is.
+			return desired, nil
+		}
+		return svc, err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The node ports are allocated by the API server, keep them.
+	for i := range desired.Spec.Ports {
+		for _, p := range existing.Spec.Ports {
+			if p.Name == desired.Spec.Ports[i].Name {
+				desired.Spec.Ports[i].NodePort = p.NodePort
+			}
+		}
+	}
+	// A source range removed from the spec must be removed from the Service, which DeepDerivative
+	// would ignore.
+	if equality.Semantic.DeepDerivative(desired.Spec, existing.Spec) &&
+		equality.Semantic.DeepEqual(desired.Spec.LoadBalancerSourceRanges, existing.Spec.LoadBalancerSourceRanges) &&
+		equality.Semantic.DeepDerivative(desired.Annotations, existing.Annotations) {
+		return existing, nil
+	}
+	// Don't modify the informers copy.
+	copy := existing.DeepCopy()
+	copy.Spec.Type = desired.Spec.Type
+	copy.Spec.Selector = desired.Spec.Selector
+	copy.Spec.Ports = desired.Spec.Ports
+	copy.Spec.LoadBalancerSourceRanges = desired.Spec.LoadBalancerSourceRanges
+	if copy.Annotations == nil {
+		copy.Annotations = make(map[string]string, len(desired.Annotations))
+	}
+	for k, v := range desired.Annotations {
+		copy.Annotations[k] = v
+	}
+	svc, err := r.KubeClientSet.CoreV1().Services(copy.Namespace).Update(ctx, copy, metav1.UpdateOptions{})
+	if err == nil {
+		r.Recorder.Eventf(bc, corev1.EventTypeNormal, "ServiceUpdated", "Updated service %s/%s", desired.Namespace, desired.Name)
+	}
+	return svc, err
+}
+
+func (r *Reconciler) deleteExternalIngressService(ctx context.Context, bc *intv1alpha1.BrokerCell) error {
+	name := resources.Name(bc.Name, resources.ExternalIngressName)
+	if _, err := r.serviceLister.Services(bc.Namespace).Get(name); apierrs.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	err := r.KubeClientSet.CoreV1().Services(bc.Namespace).Delete(ctx, name, metav1.DeleteOptions{})
+	if apierrs.IsNotFound(err) {
+		return nil
+	}
+	if err == nil {
+		r.Recorder.Eventf(bc, corev1.EventTypeNormal, "ServiceDeleted", "Deleted service %s/%s", bc.Namespace, name)
+	}
+	return err
+}
@@ -19,7 +19,9 @@ package resources
 import (
 	"fmt"
 
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"knative.dev/pkg/kmeta"
 
 	intv1alpha1 "github.com/google/knative-gcp/pkg/apis/intevents/v1alpha1"
@@ -40,9 +42,6 @@ const (
 	FanoutRestartTimeAnnotationKey  = "events.cloud.google.com/fanoutRestartRequestedAt"
 	RetryRestartTimeAnnotationKey   = "events.cloud.google.com/retryRestartRequestedAt"
 	RolloutRestartTimeAnnotationKey = "events.cloud.google.com/RestartRequestedAt"
-	// IngressFilteringEnabledAnnotationKey is the annotation key for enabling ingress filtering.
-	// TODO(#1804): remove this constant when enabling the feature by default.
-	IngressFilteringEnabledAnnotationKey = "events.cloud.google.com/ingressFilteringEnabled"
 )
 
 var (
@@ -63,24 +62,43 @@ type Args struct {
 	MemoryLimit        string
 	RolloutRestartTime string
 	AuthType           authcheck.AuthType
+
+	NodeSelector              map[string]string
+	Tolerations               []corev1.Toleration
+	Affinity                  *corev1.Affinity
+	TopologySpreadConstraints []corev1.TopologySpreadConstraint
+	PriorityClassName         string
 }
 
 // IngressArgs are the arguments to create a Broker's ingress Deployment.
 type IngressArgs struct {
 	Args
-	Port int
-	// TODO(#1804): remove this field when enabling the feature by default.
-	EnableIngressFilter bool
+	Port     int
+	GRPCPort int
+	// TLSPort is the port serving HTTPS with the certificate of the TLSSecretName Secret, if
+	// the ingress terminates the TLS of the external ingress.
+	TLSPort       int
+	TLSSecretName string
 }
 
 // FanoutArgs are the arguments to create a Broker's fanout Deployment.
 type FanoutArgs struct {
 	Args
+	// MaxConcurrencyPerTarget bounds the adaptive limit of concurrent
+	// deliveries to each target, if set.
+	MaxConcurrencyPerTarget *int32
+	// PullWorkers is the number of workers pulling the subscriptions, if set.
+	PullWorkers *int32
 }
 
 // RetryArgs are the arguments to create a Broker's retry Deployment.
 type RetryArgs struct {
 	Args
+	// MaxConcurrencyPerTarget bounds the adaptive limit of concurrent
+	// deliveries to each target, if set.
+	MaxConcurrencyPerTarget *int32
+	// PullWorkers is the number of workers pulling the subscriptions, if set.
+	PullWorkers *int32
 }
 
 // AutoscalingArgs are the arguments to create HPA for deployments.
@@ -89,8 +107,23 @@ type AutoscalingArgs struct {
 	BrokerCell        *intv1alpha1.BrokerCell
 	AvgCPUUtilization *int32
 	AvgMemoryUsage    *string
-	MaxReplicas       int32
-	MinReplicas       int32
+	// BacklogResources lists the values of the "resource" label of the
+	// Pub/Sub subscriptions whose backlog the HPA scales on.
+	BacklogResources []string
+	TargetBacklog    *int64
+	// ClusterName is the name of the cluster of the BrokerCell, which the
+	// subscriptions it pulls are labeled with.
+	ClusterName string
+	MaxReplicas int32
+	MinReplicas int32
+}
+
+// DisruptionBudgetArgs are the arguments to create PodDisruptionBudgets for deployments.
+type DisruptionBudgetArgs struct {
+	ComponentName  string
+	BrokerCell     *intv1alpha1.BrokerCell
+	MinAvailable   *intstr.IntOrString
+	MaxUnavailable *intstr.IntOrString
 }
 
 // Labels generates the labels present on all resources representing the
@@ -21,9 +21,11 @@ import (
 
 	"google.golang.org/protobuf/proto"
 	"knative.dev/pkg/kmeta"
+	"knative.dev/pkg/system"
 
 	intv1alpha1 "github.com/google/knative-gcp/pkg/apis/intevents/v1alpha1"
 	"github.com/google/knative-gcp/pkg/broker/config"
+	"github.com/google/knative-gcp/pkg/broker/eventtype"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
@@ -76,3 +78,33 @@ func MakeTargetsConfig(bc *intv1alpha1.BrokerCell, brokerTargets config.Targets)
 		Data: map[string]string{"debugOnlyTargets.txt": brokerTargets.DebugString()},
 	}, nil
 }
+
+// TargetsConfigSize returns the size in bytes of the values of the targets ConfigMap.
+func TargetsConfigSize(cm *corev1.ConfigMap) int64 {
+	var size int64
+	for _, v := range cm.BinaryData {
+		size += int64(len(v))
+	}
+	for _, v := range cm.Data {
+		size += int64(len(v))
+	}
+	return size
+}
+
+// MakeEventTypeReports creates the ConfigMap in which the ingress Pods of all BrokerCells report
+// the event types they observe. It is not owned by any BrokerCell, and the data plane may only
+// update it.
+func MakeEventTypeReports() *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      eventtype.ReportsName,
+			Namespace: system.Namespace(),
+		},
+	}
+}
+
+// EventTypeReportsEqual always returns true, so that the reports written by the ingress Pods are
+// never overwritten.
+func EventTypeReportsEqual(_, _ *corev1.ConfigMap) bool {
+	return true
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2021 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/kmeta"
+
+	intv1alpha1 "github.com/google/knative-gcp/pkg/apis/intevents/v1alpha1"
+)
+
+const (
+	deliveryHeadersSecretName = "delivery-headers"
+	deliveryHeadersVolumeName = "delivery-headers"
+	// DeliveryHeadersMountPath is where the delivery headers Secret is mounted in the fanout and
+	// retry Pods.
+	DeliveryHeadersMountPath = "/var/run/cloud-run-events/delivery-headers"
+)
+
+// DeliveryHeadersSecretName returns the name of the Secret holding the values of the delivery
+// headers of the Triggers of the BrokerCell.
+func DeliveryHeadersSecretName(bcName string) string {
+	return Name(bcName, deliveryHeadersSecretName)
+}
+
+// DeliveryHeaderSecretKey returns the key in the delivery headers Secret of the BrokerCell holding
+// the value of the given key of a Secret in the Trigger's namespace. Namespaces and Secret names
+// cannot contain underscores, so the key is unique.
+func DeliveryHeaderSecretKey(namespace, secretName, key string) string {
+	return namespace + "_" + secretName + "_" + key
+}
+
+// ParseDeliveryHeaderSecretKey returns the namespace, name and key of the Secret whose value is
+// held by the key of the delivery headers Secret.
+func ParseDeliveryHeaderSecretKey(k string) (namespace, secretName, key string, err error) {
+	parts := strings.SplitN(k, "_", 3)
+	if len(parts) != 3 {
+		return "", "", "", fmt.Errorf("malformed delivery header secret key %q", k)
+	}
+	return parts[0], parts[1], parts[2], nil
+}
+
+// MakeDeliveryHeadersSecret creates the Secret holding the values of the delivery headers of the
+// Triggers of the BrokerCell.
+func MakeDeliveryHeadersSecret(bc *intv1alpha1.BrokerCell, data map[string][]byte) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            DeliveryHeadersSecretName(bc.Name),
+			Namespace:       bc.Namespace,
+			OwnerReferences: []metav1.OwnerReference{*kmeta.NewControllerRef(bc)},
+			Labels:          Labels(bc.Name, deliveryHeadersSecretName),
+		},
+		Data: data,
+	}
+}
+
+// mountDeliveryHeaders mounts the delivery headers Secret of the BrokerCell into the container of
+// the Pod. The Secret is optional as it only exists when a Trigger references Secrets.
+func mountDeliveryHeaders(bcName string, podSpec *corev1.PodSpec, container *corev1.Container) {
+	podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+		Name: deliveryHeadersVolumeName,
+		VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{
+			SecretName: DeliveryHeadersSecretName(bcName),
+			Optional:   &optionalSecretVolume,
+		}},
+	})
+	container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+		Name:      deliveryHeadersVolumeName,
+		MountPath: DeliveryHeadersMountPath,
+		ReadOnly:  true,
+	})
+	container.Env = append(container.Env, corev1.EnvVar{
+		Name:  "DELIVERY_HEADERS_PATH",
+		Value: DeliveryHeadersMountPath,
+	})
+}
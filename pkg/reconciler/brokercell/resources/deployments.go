@@ -34,16 +34,15 @@ import (
 func MakeIngressDeployment(args IngressArgs) *appsv1.Deployment {
 	container := containerTemplate(args.Args)
 	// Decorate the container template with ingress port.
-	container.Env = append(container.Env, corev1.EnvVar{Name: "PORT", Value: strconv.Itoa(args.Port)})
-
-	// TODO(#1804): remove this env variable when enabling the feature by default.
-	// Enable ingress filtering if necessary.
-	container.Env = append(container.Env, corev1.EnvVar{
-		Name:  "ENABLE_INGRESS_EVENT_FILTERING",
-		Value: strconv.FormatBool(args.EnableIngressFilter),
-	})
+	container.Env = append(container.Env,
+		corev1.EnvVar{Name: "PORT", Value: strconv.Itoa(args.Port)},
+		corev1.EnvVar{Name: "GRPC_PORT", Value: strconv.Itoa(args.GRPCPort)},
+	)
 
-	container.Ports = append(container.Ports, corev1.ContainerPort{Name: "http", ContainerPort: int32(args.Port)})
+	container.Ports = append(container.Ports,
+		corev1.ContainerPort{Name: "http", ContainerPort: int32(args.Port)},
+		corev1.ContainerPort{Name: "grpc", ContainerPort: int32(args.GRPCPort)},
+	)
 	container.ReadinessProbe = &corev1.Probe{
 		Handler: corev1.Handler{
 			HTTPGet: &corev1.HTTPGetAction{
@@ -72,7 +71,11 @@ func MakeIngressDeployment(args IngressArgs) *appsv1.Deployment {
 		TimeoutSeconds:      5,
 	}
 	container.Resources = resourceutil.BuildResourceRequirements(args.CPURequest, args.CPULimit, args.MemoryRequest, args.MemoryLimit)
-	return deploymentTemplate(args.Args, []corev1.Container{container})
+	d := deploymentTemplate(args.Args, []corev1.Container{container})
+	if args.TLSSecretName != "" {
+		mountIngressTLS(args, &d.Spec.Template.Spec, &d.Spec.Template.Spec.Containers[0])
+	}
+	return d
 }
 
 // MakeFanoutDeployment creates the fanout Deployment object.
@@ -89,6 +92,8 @@ func MakeFanoutDeployment(args FanoutArgs) *appsv1.Deployment {
 		Name:  "MAX_CONCURRENCY_PER_EVENT",
 		Value: "100",
 	})
+	container.Env = appendMaxConcurrencyPerTarget(container.Env, args.MaxConcurrencyPerTarget)
+	container.Env = appendPullWorkers(container.Env, args.PullWorkers)
 	container.LivenessProbe = &corev1.Probe{
 		Handler: corev1.Handler{
 			HTTPGet: &corev1.HTTPGetAction{
@@ -103,7 +108,9 @@ func MakeFanoutDeployment(args FanoutArgs) *appsv1.Deployment {
 		SuccessThreshold:    1,
 		TimeoutSeconds:      5,
 	}
-	return deploymentTemplate(args.Args, []corev1.Container{container})
+	d := deploymentTemplate(args.Args, []corev1.Container{container})
+	mountDeliveryHeaders(args.BrokerCell.Name, &d.Spec.Template.Spec, &d.Spec.Template.Spec.Containers[0])
+	return d
 }
 
 // MakeRetryDeployment creates the retry Deployment object.
@@ -116,6 +123,8 @@ func MakeRetryDeployment(args RetryArgs) *appsv1.Deployment {
 			ContainerPort: handler.DefaultProbeCheckPort,
 		},
 	)
+	container.Env = appendMaxConcurrencyPerTarget(container.Env, args.MaxConcurrencyPerTarget)
+	container.Env = appendPullWorkers(container.Env, args.PullWorkers)
 	container.LivenessProbe = &corev1.Probe{
 		Handler: corev1.Handler{
 			HTTPGet: &corev1.HTTPGetAction{
@@ -130,7 +139,33 @@ func MakeRetryDeployment(args RetryArgs) *appsv1.Deployment {
 		SuccessThreshold:    1,
 		TimeoutSeconds:      5,
 	}
-	return deploymentTemplate(args.Args, []corev1.Container{container})
+	d := deploymentTemplate(args.Args, []corev1.Container{container})
+	mountDeliveryHeaders(args.BrokerCell.Name, &d.Spec.Template.Spec, &d.Spec.Template.Spec.Containers[0])
+	return d
+}
+
+// appendMaxConcurrencyPerTarget sets the bound of the adaptive concurrency
+// limit of the targets, if any.
+func appendMaxConcurrencyPerTarget(env []corev1.EnvVar, maxConcurrencyPerTarget *int32) []corev1.EnvVar {
+	if maxConcurrencyPerTarget == nil {
+		return env
+	}
+	return append(env, corev1.EnvVar{
+		Name:  "MAX_CONCURRENCY_PER_TARGET",
+		Value: strconv.Itoa(int(*maxConcurrencyPerTarget)),
+	})
+}
+
+// appendPullWorkers sets the number of workers pulling the subscriptions, if
+// any.
+func appendPullWorkers(env []corev1.EnvVar, pullWorkers *int32) []corev1.EnvVar {
+	if pullWorkers == nil {
+		return env
+	}
+	return append(env, corev1.EnvVar{
+		Name:  "PULL_WORKERS",
+		Value: strconv.Itoa(int(*pullWorkers)),
+	})
 }
 
 // deploymentTemplate creates a template for data plane deployments.
@@ -176,12 +211,34 @@ func deploymentTemplate(args Args, containers []corev1.Container) *appsv1.Deploy
 					},
 					Containers:                    containers,
 					TerminationGracePeriodSeconds: ptr.Int64(60),
+					NodeSelector:                  args.NodeSelector,
+					Tolerations:                   args.Tolerations,
+					Affinity:                      args.Affinity,
+					TopologySpreadConstraints:     topologySpreadConstraints(args),
+					PriorityClassName:             args.PriorityClassName,
 				},
 			},
 		},
 	}
 }
 
+// topologySpreadConstraints returns the topology spread constraints of the
+// component, selecting the pods of the component when no selector is given.
+func topologySpreadConstraints(args Args) []corev1.TopologySpreadConstraint {
+	if len(args.TopologySpreadConstraints) == 0 {
+		return nil
+	}
+	constraints := make([]corev1.TopologySpreadConstraint, 0, len(args.TopologySpreadConstraints))
+	for _, c := range args.TopologySpreadConstraints {
+		c := *c.DeepCopy()
+		if c.LabelSelector == nil {
+			c.LabelSelector = &metav1.LabelSelector{MatchLabels: Labels(args.BrokerCell.Name, args.ComponentName)}
+		}
+		constraints = append(constraints, c)
+	}
+	return constraints
+}
+
 // containerTemplate returns a common template for broker data plane containers.
 func containerTemplate(args Args) corev1.Container {
 	return corev1.Container{
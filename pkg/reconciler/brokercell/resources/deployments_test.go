@@ -0,0 +1,228 @@
+/*
+Copyright 2021 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/ptr"
+	_ "knative.dev/pkg/system/testing"
+
+	intv1alpha1 "github.com/google/knative-gcp/pkg/apis/intevents/v1alpha1"
+)
+
+func TestMakeFanoutDeploymentScheduling(t *testing.T) {
+	bc := &intv1alpha1.BrokerCell{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "default"},
+	}
+	hostSelector := &metav1.LabelSelector{MatchLabels: map[string]string{"app": "other"}}
+	args := FanoutArgs{
+		Args: Args{
+			ComponentName: FanoutName,
+			BrokerCell:    bc,
+			NodeSelector:  map[string]string{"pool": "events"},
+			Tolerations: []corev1.Toleration{{
+				Key:      "dedicated",
+				Operator: corev1.TolerationOpEqual,
+				Value:    "events",
+				Effect:   corev1.TaintEffectNoSchedule,
+			}},
+			Affinity: &corev1.Affinity{
+				PodAntiAffinity: &corev1.PodAntiAffinity{},
+			},
+			TopologySpreadConstraints: []corev1.TopologySpreadConstraint{{
+				MaxSkew:           1,
+				TopologyKey:       "topology.kubernetes.io/zone",
+				WhenUnsatisfiable: corev1.ScheduleAnyway,
+			}, {
+				MaxSkew:           2,
+				TopologyKey:       "kubernetes.io/hostname",
+				WhenUnsatisfiable: corev1.DoNotSchedule,
+				LabelSelector:     hostSelector,
+			}},
+			PriorityClassName: "events-critical",
+		},
+	}
+
+	spec := MakeFanoutDeployment(args).Spec.Template.Spec
+	if diff := cmp.Diff(args.NodeSelector, spec.NodeSelector); diff != "" {
+		t.Error("Unexpected node selector (-want, +got):", diff)
+	}
+	if diff := cmp.Diff(args.Tolerations, spec.Tolerations); diff != "" {
+		t.Error("Unexpected tolerations (-want, +got):", diff)
+	}
+	if diff := cmp.Diff(args.Affinity, spec.Affinity); diff != "" {
+		t.Error("Unexpected affinity (-want, +got):", diff)
+	}
+	if spec.PriorityClassName != "events-critical" {
+		t.Errorf("Unexpected priority class %q", spec.PriorityClassName)
+	}
+	wantConstraints := []corev1.TopologySpreadConstraint{{
+		MaxSkew:           1,
+		TopologyKey:       "topology.kubernetes.io/zone",
+		WhenUnsatisfiable: corev1.ScheduleAnyway,
+		LabelSelector:     &metav1.LabelSelector{MatchLabels: Labels("default", FanoutName)},
+	}, {
+		MaxSkew:           2,
+		TopologyKey:       "kubernetes.io/hostname",
+		WhenUnsatisfiable: corev1.DoNotSchedule,
+		LabelSelector:     hostSelector,
+	}}
+	if diff := cmp.Diff(wantConstraints, spec.TopologySpreadConstraints); diff != "" {
+		t.Error("Unexpected topology spread constraints (-want, +got):", diff)
+	}
+	if args.TopologySpreadConstraints[0].LabelSelector != nil {
+		t.Error("MakeFanoutDeployment modified the topology spread constraints of the arguments")
+	}
+}
+
+func TestMakeDeploymentsMaxConcurrencyPerTarget(t *testing.T) {
+	bc := &intv1alpha1.BrokerCell{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "default"},
+	}
+	fanout := FanoutArgs{
+		Args:                    Args{ComponentName: FanoutName, BrokerCell: bc},
+		MaxConcurrencyPerTarget: ptr.Int32(50),
+	}
+	retry := RetryArgs{
+		Args:                    Args{ComponentName: RetryName, BrokerCell: bc},
+		MaxConcurrencyPerTarget: ptr.Int32(10),
+	}
+	for _, tc := range []struct {
+		name    string
+		env     []corev1.EnvVar
+		wantEnv *corev1.EnvVar
+	}{{
+		name:    "fanout",
+		env:     MakeFanoutDeployment(fanout).Spec.Template.Spec.Containers[0].Env,
+		wantEnv: &corev1.EnvVar{Name: "MAX_CONCURRENCY_PER_TARGET", Value: "50"},
+	}, {
+		name:    "retry",
+		env:     MakeRetryDeployment(retry).Spec.Template.Spec.Containers[0].Env,
+		wantEnv: &corev1.EnvVar{Name: "MAX_CONCURRENCY_PER_TARGET", Value: "10"},
+	}, {
+		name: "fanout without limit",
+		env:  MakeFanoutDeployment(FanoutArgs{Args: fanout.Args}).Spec.Template.Spec.Containers[0].Env,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			var got *corev1.EnvVar
+			for i, e := range tc.env {
+				if e.Name == "MAX_CONCURRENCY_PER_TARGET" {
+					got = &tc.env[i]
+				}
+			}
+			if diff := cmp.Diff(tc.wantEnv, got); diff != "" {
+				t.Error("Unexpected MAX_CONCURRENCY_PER_TARGET env (-want, +got):", diff)
+			}
+		})
+	}
+}
+
+func TestMakeDeploymentsPullWorkers(t *testing.T) {
+	bc := &intv1alpha1.BrokerCell{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "default"},
+	}
+	fanout := FanoutArgs{
+		Args:        Args{ComponentName: FanoutName, BrokerCell: bc},
+		PullWorkers: ptr.Int32(16),
+	}
+	retry := RetryArgs{
+		Args:        Args{ComponentName: RetryName, BrokerCell: bc},
+		PullWorkers: ptr.Int32(4),
+	}
+	for _, tc := range []struct {
+		name    string
+		env     []corev1.EnvVar
+		wantEnv *corev1.EnvVar
+	}{{
+		name:    "fanout",
+		env:     MakeFanoutDeployment(fanout).Spec.Template.Spec.Containers[0].Env,
+		wantEnv: &corev1.EnvVar{Name: "PULL_WORKERS", Value: "16"},
+	}, {
+		name:    "retry",
+		env:     MakeRetryDeployment(retry).Spec.Template.Spec.Containers[0].Env,
+		wantEnv: &corev1.EnvVar{Name: "PULL_WORKERS", Value: "4"},
+	}, {
+		name: "fanout with streaming pulls",
+		env:  MakeFanoutDeployment(FanoutArgs{Args: fanout.Args}).Spec.Template.Spec.Containers[0].Env,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			var got *corev1.EnvVar
+			for i, e := range tc.env {
+				if e.Name == "PULL_WORKERS" {
+					got = &tc.env[i]
+				}
+			}
+			if diff := cmp.Diff(tc.wantEnv, got); diff != "" {
+				t.Error("Unexpected PULL_WORKERS env (-want, +got):", diff)
+			}
+		})
+	}
+}
+
+func TestMakeIngressDeploymentTLS(t *testing.T) {
+	bc := &intv1alpha1.BrokerCell{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "default"},
+	}
+	args := IngressArgs{
+		Args: Args{
+			ComponentName: IngressName,
+			BrokerCell:    bc,
+		},
+		Port:          8080,
+		GRPCPort:      8081,
+		TLSPort:       8443,
+		TLSSecretName: "events-tls",
+	}
+
+	spec := MakeIngressDeployment(args).Spec.Template.Spec
+	wantVolume := corev1.Volume{
+		Name:         "ingress-tls",
+		VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: "events-tls"}},
+	}
+	if diff := cmp.Diff(wantVolume, spec.Volumes[len(spec.Volumes)-1]); diff != "" {
+		t.Error("Unexpected TLS volume (-want, +got):", diff)
+	}
+	container := spec.Containers[0]
+	wantMount := corev1.VolumeMount{Name: "ingress-tls", MountPath: IngressTLSMountPath, ReadOnly: true}
+	if diff := cmp.Diff(wantMount, container.VolumeMounts[len(container.VolumeMounts)-1]); diff != "" {
+		t.Error("Unexpected TLS volume mount (-want, +got):", diff)
+	}
+	wantEnv := []corev1.EnvVar{
+		{Name: "TLS_PORT", Value: "8443"},
+		{Name: "TLS_CERT_FILE", Value: IngressTLSMountPath + "/tls.crt"},
+		{Name: "TLS_KEY_FILE", Value: IngressTLSMountPath + "/tls.key"},
+	}
+	if diff := cmp.Diff(wantEnv, container.Env[len(container.Env)-len(wantEnv):]); diff != "" {
+		t.Error("Unexpected TLS env (-want, +got):", diff)
+	}
+	wantPort := corev1.ContainerPort{Name: "https", ContainerPort: 8443}
+	if diff := cmp.Diff(wantPort, container.Ports[len(container.Ports)-1]); diff != "" {
+		t.Error("Unexpected TLS port (-want, +got):", diff)
+	}
+
+	// Without a TLS Secret, the ingress only serves HTTP.
+	args.TLSSecretName = ""
+	for _, v := range MakeIngressDeployment(args).Spec.Template.Spec.Volumes {
+		if v.Name == "ingress-tls" {
+			t.Error("Unexpected TLS volume without a TLS Secret")
+		}
+	}
+}
@@ -0,0 +1,132 @@
+/*
+Copyright 2021 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"knative.dev/pkg/kmeta"
+
+	intv1alpha1 "github.com/google/knative-gcp/pkg/apis/intevents/v1alpha1"
+)
+
+const (
+	// ExternalIngressName is the name used for the LoadBalancer Service of the external ingress.
+	ExternalIngressName = "ingress-external"
+
+	ingressTLSVolumeName = "ingress-tls"
+	// IngressTLSMountPath is where the TLS Secret of the external ingress is mounted in the ingress
+	// Pods.
+	IngressTLSMountPath = "/var/run/cloud-run-events/ingress-tls"
+)
+
+// ExternalIngressTLSSecretName returns the name of the TLS Secret the ingress Pods serve the
+// external ingress with, if any.
+func ExternalIngressTLSSecretName(bc *intv1alpha1.BrokerCell) string {
+	ei := bc.Spec.ExternalIngress
+	if ei == nil || ei.TLS == nil {
+		return ""
+	}
+	return ei.TLS.SecretName
+}
+
+// MakeExternalIngressService creates the LoadBalancer Service exposing the ingress outside of the
+// cluster to the clients in its source ranges.
+func MakeExternalIngressService(args IngressArgs) *corev1.Service {
+	bc := args.BrokerCell
+	port := corev1.ServicePort{
+		Name:       "http",
+		Port:       80,
+		TargetPort: intstr.FromInt(args.Port),
+	}
+	if args.TLSSecretName != "" {
+		port = corev1.ServicePort{
+			Name:       "https",
+			Port:       443,
+			TargetPort: intstr.FromInt(args.TLSPort),
+		}
+	}
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       bc.Namespace,
+			Name:            Name(bc.Name, ExternalIngressName),
+			Labels:          Labels(bc.Name, ExternalIngressName),
+			Annotations:     bc.Spec.ExternalIngress.Annotations,
+			OwnerReferences: []metav1.OwnerReference{*kmeta.NewControllerRef(bc)},
+		},
+		Spec: corev1.ServiceSpec{
+			Type:                     corev1.ServiceTypeLoadBalancer,
+			Selector:                 Labels(bc.Name, IngressName),
+			Ports:                    []corev1.ServicePort{port},
+			LoadBalancerSourceRanges: bc.Spec.ExternalIngress.LoadBalancerSourceRanges,
+		},
+	}
+}
+
+// LoadBalancerHost returns the IP or hostname of the load balancer of the Service, or an empty
+// string if the load balancer is not provisioned yet.
+func LoadBalancerHost(svc *corev1.Service) string {
+	for _, ing := range svc.Status.LoadBalancer.Ingress {
+		if ing.Hostname != "" {
+			return ing.Hostname
+		}
+		if ing.IP != "" {
+			return ing.IP
+		}
+	}
+	return ""
+}
+
+// ExternalIngressTemplate returns the IngressTemplate of the external ingress served on the given
+// host.
+func ExternalIngressTemplate(ei *intv1alpha1.ExternalIngressSpec, host string) string {
+	scheme := "http"
+	if ei.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + host + "/{namespace}/{name}"
+}
+
+// ExpandIngressTemplate returns the ingress URI of the Broker from the IngressTemplate.
+func ExpandIngressTemplate(template, namespace, name string) string {
+	return strings.NewReplacer("{namespace}", namespace, "{name}", name).Replace(template)
+}
+
+// mountIngressTLS mounts the TLS Secret of the external ingress into the ingress container, which
+// then serves HTTPS on the TLS port.
+func mountIngressTLS(args IngressArgs, podSpec *corev1.PodSpec, container *corev1.Container) {
+	podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+		Name:         ingressTLSVolumeName,
+		VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: args.TLSSecretName}},
+	})
+	container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+		Name:      ingressTLSVolumeName,
+		MountPath: IngressTLSMountPath,
+		ReadOnly:  true,
+	})
+	container.Env = append(container.Env,
+		corev1.EnvVar{Name: "TLS_PORT", Value: strconv.Itoa(args.TLSPort)},
+		corev1.EnvVar{Name: "TLS_CERT_FILE", Value: filepath.Join(IngressTLSMountPath, corev1.TLSCertKey)},
+		corev1.EnvVar{Name: "TLS_KEY_FILE", Value: filepath.Join(IngressTLSMountPath, corev1.TLSPrivateKeyKey)},
+	)
+	container.Ports = append(container.Ports, corev1.ContainerPort{Name: "https", ContainerPort: int32(args.TLSPort)})
+}
@@ -25,6 +25,37 @@ import (
 	"knative.dev/pkg/kmeta"
 )
 
+const (
+	// undeliveredMessagesMetric is the Cloud Monitoring metric of a
+	// subscription backlog, as exposed by the Custom Metrics Stackdriver
+	// Adapter.
+	undeliveredMessagesMetric = "pubsub.googleapis.com|subscription|num_undelivered_messages"
+	// subscriptionResourceLabel selects subscriptions by the "resource" label
+	// set by the celltenant reconciler.
+	subscriptionResourceLabel = "metadata.user_labels.resource"
+	// subscriptionBrokerCellLabel and subscriptionClusterLabel select the
+	// subscriptions pulled by the BrokerCell, so that the HPA doesn't scale on
+	// the backlog of other BrokerCells and clusters in the same project.
+	subscriptionBrokerCellLabel = "metadata.user_labels." + SubscriptionBrokerCellLabelKey
+	subscriptionClusterLabel    = "metadata.user_labels." + SubscriptionClusterLabelKey
+
+	// SubscriptionBrokerCellLabelKey is the label of a subscription naming the
+	// BrokerCell that pulls it.
+	SubscriptionBrokerCellLabelKey = "brokercell"
+	// SubscriptionClusterLabelKey is the label of a subscription naming the
+	// cluster of the BrokerCell that pulls it.
+	SubscriptionClusterLabelKey = "cluster"
+)
+
+var (
+	// FanoutBacklogResources are the subscriptions pulled by the fanout:
+	// the decoupling subscriptions of Brokers and Channels.
+	FanoutBacklogResources = []string{"brokers", "channels"}
+	// RetryBacklogResources are the subscriptions pulled by the retry: the
+	// retry subscriptions of Triggers and Channel subscribers.
+	RetryBacklogResources = []string{"triggers", "subscriptions"}
+)
+
 // MakeHorizontalPodAutoscaler makes an HPA for the given arguments.
 func MakeHorizontalPodAutoscaler(deployment *appsv1.Deployment, args AutoscalingArgs) *hpav2beta2.HorizontalPodAutoscaler {
 	autoscalingMetrics := []hpav2beta2.MetricSpec{}
@@ -56,6 +87,34 @@ func MakeHorizontalPodAutoscaler(deployment *appsv1.Deployment, args Autoscaling
 			autoscalingMetrics = append(autoscalingMetrics, memoryMetric)
 		}
 	}
+	if args.TargetBacklog != nil {
+		targetBacklog := resource.NewQuantity(*args.TargetBacklog, resource.DecimalSI)
+		// The external metric selector doesn't support set based requirements, so
+		// each kind of subscription is a separate metric. The HPA scales to the
+		// replica count of the largest backlog.
+		for _, r := range args.BacklogResources {
+			backlogMetric := hpav2beta2.MetricSpec{
+				Type: hpav2beta2.ExternalMetricSourceType,
+				External: &hpav2beta2.ExternalMetricSource{
+					Metric: hpav2beta2.MetricIdentifier{
+						Name: undeliveredMessagesMetric,
+						Selector: &metav1.LabelSelector{
+							MatchLabels: map[string]string{
+								subscriptionResourceLabel:   r,
+								subscriptionBrokerCellLabel: args.BrokerCell.Name,
+								subscriptionClusterLabel:    args.ClusterName,
+							},
+						},
+					},
+					Target: hpav2beta2.MetricTarget{
+						Type:         hpav2beta2.AverageValueMetricType,
+						AverageValue: targetBacklog,
+					},
+				},
+			}
+			autoscalingMetrics = append(autoscalingMetrics, backlogMetric)
+		}
+	}
 
 	return &hpav2beta2.HorizontalPodAutoscaler{
 		ObjectMeta: metav1.ObjectMeta{
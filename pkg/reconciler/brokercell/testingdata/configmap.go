@@ -29,7 +29,9 @@ import (
 	"github.com/google/knative-gcp/pkg/broker/config/memory"
 	brokerresources "github.com/google/knative-gcp/pkg/reconciler/broker/resources"
 	"github.com/google/knative-gcp/pkg/reconciler/brokercell/resources"
+	"google.golang.org/protobuf/types/known/durationpb"
 	corev1 "k8s.io/api/core/v1"
+	eventingduckv1 "knative.dev/eventing/pkg/apis/duck/v1"
 )
 
 func EmptyConfig(t *testing.T, bc *intv1alpha1.BrokerCell) *corev1.ConfigMap {
@@ -37,6 +39,10 @@ func EmptyConfig(t *testing.T, bc *intv1alpha1.BrokerCell) *corev1.ConfigMap {
 	return cm
 }
 
+func EventTypeReports() *corev1.ConfigMap {
+	return resources.MakeEventTypeReports()
+}
+
 type BrokerCellObjects struct {
 	BrokersToTriggers map[*brokerv1.Broker][]*brokerv1.Trigger
 	Channels          []*v1beta1.Channel
@@ -82,9 +88,43 @@ func addBroker(targets *config.TargetsConfig, broker *brokerv1.Broker, triggers
 			Subscription: brokerresources.GenerateDecouplingSubscriptionName(broker),
 			State:        brokerQueueState,
 		},
+		DelayQueue: &config.Queue{
+			Topic:        brokerresources.GenerateDelayTopicName(broker),
+			Subscription: brokerresources.GenerateDelaySubscriptionName(broker),
+			State:        brokerQueueState,
+		},
 		Targets: make(map[string]*config.Target),
 		State:   state,
 	}
+	if eventsPerSecond, bytesInFlight, err := broker.IngressQuota(); err == nil && (eventsPerSecond > 0 || bytesInFlight > 0) {
+		brokerConfig.IngressQuota = &config.IngressQuota{
+			EventsPerSecond: eventsPerSecond,
+			BytesInFlight:   bytesInFlight,
+		}
+	}
+	if enabled, err := broker.IngressFilteringEnabled(); err == nil {
+		brokerConfig.IngressFilteringEnabled = enabled
+	}
+	if async, err := broker.IngressAsyncPublish(); err == nil {
+		brokerConfig.IngressAsyncPublish = async
+	}
+	if enabled, err := broker.ReplyLoopDetection(); err == nil {
+		brokerConfig.ReplyLoopDetection = enabled
+	}
+	if bucket, prefix := broker.Status.Archive(); bucket != "" {
+		brokerConfig.Archive = &config.Archive{
+			Bucket:       bucket,
+			Prefix:       prefix,
+			Subscription: brokerresources.GenerateArchiveSubscriptionName(broker),
+		}
+	}
+	for _, t := range broker.Status.FederatedTopics() {
+		brokerConfig.FederationQueues = append(brokerConfig.FederationQueues, &config.Queue{
+			Topic:        t.String(),
+			Subscription: brokerresources.GenerateFederationSubscriptionName(broker, t),
+			State:        brokerQueueState,
+		})
+	}
 	for _, trigger := range triggers {
 		var filterAttributes map[string]string
 		if trigger.Spec.Filter != nil && trigger.Spec.Filter.Attributes != nil {
@@ -104,6 +144,52 @@ func addBroker(targets *config.TargetsConfig, broker *brokerv1.Broker, triggers
 			},
 			State:            state,
 			FilterAttributes: filterAttributes,
+			DeadLetterQueue:  deadLetterQueue(broker.Spec.Delivery),
+		}
+		if success, nonRetryable, err := trigger.ResponseClassification(); err == nil && (len(success) > 0 || len(nonRetryable) > 0) {
+			brokerConfig.Targets[trigger.Name].ResponseClassification = &config.ResponseClassification{
+				SuccessCodes:      success,
+				NonRetryableCodes: nonRetryable,
+			}
+		}
+		if age, err := trigger.MaxEventAge(); err == nil && age > 0 {
+			brokerConfig.Targets[trigger.Name].MaxEventAge = durationpb.New(age)
+		} else if age, err := broker.MaxEventAge(); err == nil && age > 0 {
+			brokerConfig.Targets[trigger.Name].MaxEventAge = durationpb.New(age)
+		}
+		if format, _ := trigger.DeliveryFormat(); format == brokerv1.DeliveryFormatStructured {
+			brokerConfig.Targets[trigger.Name].DeliveryFormat = config.DeliveryFormat_STRUCTURED
+		} else if format == brokerv1.DeliveryFormatBinary {
+			brokerConfig.Targets[trigger.Name].DeliveryFormat = config.DeliveryFormat_BINARY
+		}
+		if orderingKey, attributes, err := trigger.PubsubDelivery(); err == nil && (orderingKey != "" || len(attributes) > 0) {
+			brokerConfig.Targets[trigger.Name].PubsubDelivery = &config.PubsubDelivery{
+				OrderingKeyAttribute: orderingKey,
+				AttributeMapping:     attributes,
+			}
+		}
+		if ignore, _ := trigger.BigQueryIgnoreUnknownValues(); ignore {
+			brokerConfig.Targets[trigger.Name].BigqueryDelivery = &config.BigQueryDelivery{IgnoreUnknownValues: true}
+		}
+		if broker.ConsolidatedRetryTopic() {
+			brokerConfig.Targets[trigger.Name].RetryQueue = &config.Queue{
+				Topic:        brokerresources.GenerateConsolidatedRetryTopicName(broker),
+				Subscription: brokerresources.GenerateConsolidatedRetrySubscriptionName(trigger),
+			}
+		}
+		if sub, _, ok := trigger.Status.DrainingRetrySubscription(); ok && sub != brokerConfig.Targets[trigger.Name].RetryQueue.Subscription {
+			brokerConfig.Targets[trigger.Name].DrainingRetryQueue = &config.Queue{Subscription: sub}
+		}
+		if static, secrets, err := trigger.DeliveryHeaders(); err == nil {
+			if len(static) > 0 {
+				brokerConfig.Targets[trigger.Name].DeliveryHeaders = static
+			}
+			for header, ref := range secrets {
+				if brokerConfig.Targets[trigger.Name].DeliveryHeaderSecrets == nil {
+					brokerConfig.Targets[trigger.Name].DeliveryHeaderSecrets = map[string]string{}
+				}
+				brokerConfig.Targets[trigger.Name].DeliveryHeaderSecrets[header] = resources.DeliveryHeaderSecretKey(trigger.Namespace, ref.Name, ref.Key)
+			}
 		}
 	}
 	targets.CellTenants[brokerConfig.Key().PersistenceString()] = brokerConfig
@@ -148,10 +234,18 @@ func addChannel(targets *config.TargetsConfig, channel *v1beta1.Channel) {
 					Topic:        channelresources.GenerateSubscriberRetryTopicName(channel, s.UID),
 					Subscription: channelresources.GenerateSubscriberRetrySubscriptionName(channel, s.UID),
 				},
-				State:        config.State_READY,
-				ReplyAddress: s.ReplyURI.String(),
+				State:           config.State_READY,
+				ReplyAddress:    s.ReplyURI.String(),
+				DeadLetterQueue: deadLetterQueue(s.Delivery),
 			}
 		}
 	}
 	targets.CellTenants[cellTenant.Key().PersistenceString()] = cellTenant
 }
+
+func deadLetterQueue(spec *eventingduckv1.DeliverySpec) *config.Queue {
+	if spec == nil || spec.DeadLetterSink == nil || spec.DeadLetterSink.URI == nil || spec.DeadLetterSink.URI.Scheme != "pubsub" {
+		return nil
+	}
+	return &config.Queue{Topic: spec.DeadLetterSink.URI.Host}
+}
@@ -26,6 +26,7 @@ import (
 	appsv1 "k8s.io/api/apps/v1"
 	hpav2beta2 "k8s.io/api/autoscaling/v2beta2"
 	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
 	"sigs.k8s.io/yaml"
 )
 
@@ -33,11 +34,6 @@ func IngressDeployment(t *testing.T) *appsv1.Deployment {
 	return getDeployment(t, "testingdata/ingress_deployment.yaml")
 }
 
-// TODO(1804): remove this function when ingress filtering is enabled by default.
-func IngressDeploymentWithFilteringAnnotation(t *testing.T) *appsv1.Deployment {
-	return getDeployment(t, "testingdata/ingress_deployment_with_filtering_annotation.yaml")
-}
-
 func FanoutDeployment(t *testing.T) *appsv1.Deployment {
 	return getDeployment(t, "testingdata/fanout_deployment.yaml")
 }
@@ -50,6 +46,10 @@ func IngressService(t *testing.T) *corev1.Service {
 	return getService(t, "testingdata/ingress_service.yaml")
 }
 
+func IngressExternalService(t *testing.T) *corev1.Service {
+	return getService(t, "testingdata/ingress_external_service.yaml")
+}
+
 func IngressDeploymentWithStatus(t *testing.T) *appsv1.Deployment {
 	return getDeployment(t, "testingdata/ingress_deployment_with_status.yaml")
 }
@@ -86,10 +86,22 @@ func FanoutHPA(t *testing.T) *hpav2beta2.HorizontalPodAutoscaler {
 	return getHPA(t, "testingdata/fanout_hpa.yaml")
 }
 
+func FanoutHPAWithBacklog(t *testing.T) *hpav2beta2.HorizontalPodAutoscaler {
+	return getHPA(t, "testingdata/fanout_hpa_with_backlog.yaml")
+}
+
 func RetryHPA(t *testing.T) *hpav2beta2.HorizontalPodAutoscaler {
 	return getHPA(t, "testingdata/retry_hpa.yaml")
 }
 
+func FanoutPDB(t *testing.T) *policyv1beta1.PodDisruptionBudget {
+	pdb := &policyv1beta1.PodDisruptionBudget{}
+	if err := getSpecFromFile("testingdata/fanout_pdb.yaml", pdb); err != nil {
+		t.Fatalf("Failed to parse YAML: %v", err)
+	}
+	return pdb
+}
+
 func getHPA(t *testing.T, path string) *hpav2beta2.HorizontalPodAutoscaler {
 	hpa := &hpav2beta2.HorizontalPodAutoscaler{}
 	if err := getSpecFromFile(path, hpa); err != nil {
@@ -21,15 +21,18 @@ package celltenant
 import (
 	"context"
 	"fmt"
+	"time"
 
 	metadataClient "github.com/google/knative-gcp/pkg/gclient/metadata"
 
+	brokerv1 "github.com/google/knative-gcp/pkg/apis/broker/v1"
 	inteventsv1alpha1 "github.com/google/knative-gcp/pkg/apis/intevents/v1alpha1"
 	brokercellresources "github.com/google/knative-gcp/pkg/reconciler/brokercell/resources"
 	apierrs "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/google/knative-gcp/pkg/apis/configs/dataresidency"
+	"github.com/google/knative-gcp/pkg/apis/configs/encryption"
 
 	"cloud.google.com/go/pubsub"
 	"github.com/google/knative-gcp/pkg/logging"
@@ -50,6 +53,18 @@ import (
 const (
 	// Name of the corev1.Events emitted from the Broker reconciliation process.
 	brokerCellCreated = "BrokerCellCreated"
+
+	// archiveRetentionDuration is the max retention duration of Pub/Sub subscriptions.
+	archiveRetentionDuration = 7 * 24 * time.Hour
+
+	// delayRetentionDuration is the retention duration of the delay subscriptions. It covers the
+	// max delay of the scheduled deliveries.
+	delayRetentionDuration = 7 * 24 * time.Hour
+	// delayMinBackoff and delayMaxBackoff bound how long the events that are not due yet wait in the
+	// delay subscription before they are redelivered, when the handler can't set their ack
+	// deadline.
+	delayMinBackoff = 10 * time.Second
+	delayMaxBackoff = 10 * time.Minute
 )
 
 // Reconciler implements controller.Reconciler for CellTenants.
@@ -66,8 +81,13 @@ type Reconciler struct {
 
 	DataresidencyStore *dataresidency.Store
 
+	EncryptionStore *encryption.Store
+
 	// clusterRegion is the region where GKE is running.
 	ClusterRegion string
+
+	// ClusterName is the name of the GKE cluster, which the subscriptions are labeled with.
+	ClusterName string
 }
 
 func (r *Reconciler) ReconcileGCPCellTenant(ctx context.Context, b Statusable) error {
@@ -111,6 +131,11 @@ func (r *Reconciler) reconcileDecouplingTopicAndSubscription(ctx context.Context
 		logger.Error("Failed to get cluster region: ", zap.Error(err))
 		return err
 	}
+	r.ClusterName, err = utils.ClusterName(r.ClusterName, metadataClient.NewDefaultMetadataClient())
+	if err != nil {
+		logger.Error("Failed to get cluster name: ", zap.Error(err))
+		return err
+	}
 
 	client, err := r.getClientOrCreateNew(ctx, projectID, b.StatusUpdater())
 	if err != nil {
@@ -122,16 +147,15 @@ func (r *Reconciler) reconcileDecouplingTopicAndSubscription(ctx context.Context
 	// Check if topic exists, and if not, create it.
 	topicID := b.GetTopicID()
 	topicConfig := &pubsub.TopicConfig{Labels: b.GetLabels()}
-	if r.DataresidencyStore != nil {
-		if r.DataresidencyStore.Load().DataResidencyDefaults.ComputeAllowedPersistenceRegions(topicConfig, r.ClusterRegion) {
-			logger.Debug("Updated Topic Config AllowedPersistenceRegions for Broker", zap.Any("topicConfig", *topicConfig))
-		}
-	}
+	requiredRegions, requiredKey := reconcilerutilspubsub.ComputeTopicConfig(ctx, r.DataresidencyStore, r.EncryptionStore, topicConfig, r.ClusterRegion, b.TopicConfigObject())
 
 	topic, err := pubsubReconciler.ReconcileTopic(ctx, topicID, topicConfig, b.Object(), b.StatusUpdater())
 	if err != nil {
 		return err
 	}
+	if err := pubsubReconciler.CheckTopicConfig(ctx, topic, requiredRegions, requiredKey, b.StatusUpdater()); err != nil {
+		return err
+	}
 	// TODO(grantr): this isn't actually persisted due to webhook issues.
 	//TODO uncomment when eventing webhook allows this
 	//b.Status.TopicID = topic.ID()
@@ -140,7 +164,7 @@ func (r *Reconciler) reconcileDecouplingTopicAndSubscription(ctx context.Context
 	subID := b.GetSubscriptionName()
 	subConfig := pubsub.SubscriptionConfig{
 		Topic:  topic,
-		Labels: b.GetLabels(),
+		Labels: subscriptionLabels(b.GetLabels(), r.ClusterName),
 		//TODO(grantr): configure these settings?
 		// AckDeadline
 		// RetentionDuration
@@ -186,6 +210,226 @@ func (r *Reconciler) deleteDecouplingTopicAndSubscription(ctx context.Context, s
 	return err
 }
 
+// ReconcileRetryTopic creates the retry topic with the given ID shared by the Targets of the
+// CellTenant, if it doesn't exist.
+func (r *Reconciler) ReconcileRetryTopic(ctx context.Context, s Statusable, topicID string) error {
+	logger := logging.FromContext(ctx)
+	logger.Debug("Reconciling retry topic", zap.String("topic", topicID))
+	projectID, err := utils.ProjectIDOrDefault(r.ProjectID)
+	if err != nil {
+		logger.Error("Failed to find project id", zap.Error(err))
+		s.StatusUpdater().MarkTopicUnknown("ProjectIdNotFound", "Failed to find project id: %v", err)
+		return err
+	}
+
+	r.ClusterRegion, err = utils.ClusterRegion(r.ClusterRegion, metadataClient.NewDefaultMetadataClient)
+	if err != nil {
+		logger.Error("Failed to get cluster region: ", zap.Error(err))
+		return err
+	}
+
+	client, err := r.getClientOrCreateNew(ctx, projectID, s.StatusUpdater())
+	if err != nil {
+		logger.Error("Failed to create Pub/Sub client", zap.Error(err))
+		return err
+	}
+	pubsubReconciler := reconcilerutilspubsub.NewReconciler(client, r.Recorder)
+
+	topicConfig := &pubsub.TopicConfig{Labels: s.GetLabels()}
+	requiredRegions, requiredKey := reconcilerutilspubsub.ComputeTopicConfig(ctx, r.DataresidencyStore, r.EncryptionStore, topicConfig, r.ClusterRegion, s.TopicConfigObject())
+	topic, err := pubsubReconciler.ReconcileTopic(ctx, topicID, topicConfig, s.Object(), s.StatusUpdater())
+	if err != nil {
+		return err
+	}
+	return pubsubReconciler.CheckTopicConfig(ctx, topic, requiredRegions, requiredKey, s.StatusUpdater())
+}
+
+// ReconcileDelayTopicAndSubscription creates the topic with the given ID the events of the
+// CellTenant scheduled for a later delivery wait in, and the subscription with the given ID they
+// are redelivered from until they are due, if they don't exist. The subscription has no dead
+// letter policy, the events are redelivered as long as they are retained.
+func (r *Reconciler) ReconcileDelayTopicAndSubscription(ctx context.Context, s Statusable, topicID, subID string) error {
+	logger := logging.FromContext(ctx)
+	logger.Debug("Reconciling delay topic", zap.String("topic", topicID), zap.String("subscription", subID))
+	projectID, err := utils.ProjectIDOrDefault(r.ProjectID)
+	if err != nil {
+		logger.Error("Failed to find project id", zap.Error(err))
+		s.StatusUpdater().MarkTopicUnknown("ProjectIdNotFound", "Failed to find project id: %v", err)
+		s.StatusUpdater().MarkSubscriptionUnknown("ProjectIdNotFound", "Failed to find project id: %v", err)
+		return err
+	}
+
+	r.ClusterRegion, err = utils.ClusterRegion(r.ClusterRegion, metadataClient.NewDefaultMetadataClient)
+	if err != nil {
+		logger.Error("Failed to get cluster region: ", zap.Error(err))
+		return err
+	}
+	r.ClusterName, err = utils.ClusterName(r.ClusterName, metadataClient.NewDefaultMetadataClient())
+	if err != nil {
+		logger.Error("Failed to get cluster name: ", zap.Error(err))
+		return err
+	}
+
+	client, err := r.getClientOrCreateNew(ctx, projectID, s.StatusUpdater())
+	if err != nil {
+		logger.Error("Failed to create Pub/Sub client", zap.Error(err))
+		return err
+	}
+	pubsubReconciler := reconcilerutilspubsub.NewReconciler(client, r.Recorder)
+
+	topicConfig := &pubsub.TopicConfig{Labels: s.GetLabels()}
+	requiredRegions, requiredKey := reconcilerutilspubsub.ComputeTopicConfig(ctx, r.DataresidencyStore, r.EncryptionStore, topicConfig, r.ClusterRegion, s.TopicConfigObject())
+	topic, err := pubsubReconciler.ReconcileTopic(ctx, topicID, topicConfig, s.Object(), s.StatusUpdater())
+	if err != nil {
+		return err
+	}
+	if err := pubsubReconciler.CheckTopicConfig(ctx, topic, requiredRegions, requiredKey, s.StatusUpdater()); err != nil {
+		return err
+	}
+
+	subConfig := pubsub.SubscriptionConfig{
+		Topic:             topic,
+		Labels:            subscriptionLabels(s.GetLabels(), r.ClusterName),
+		RetentionDuration: delayRetentionDuration,
+		RetryPolicy: &pubsub.RetryPolicy{
+			MinimumBackoff: delayMinBackoff,
+			MaximumBackoff: delayMaxBackoff,
+		},
+	}
+	_, err = pubsubReconciler.ReconcileSubscription(ctx, subID, subConfig, s.Object(), s.StatusUpdater())
+	return err
+}
+
+// DeleteDelayTopicAndSubscription deletes the delay topic and subscription with the given IDs of
+// the CellTenant, if they exist. The events that are not due yet are dropped.
+func (r *Reconciler) DeleteDelayTopicAndSubscription(ctx context.Context, s Statusable, topicID, subID string) error {
+	logger := logging.FromContext(ctx)
+	logger.Debug("Deleting delay topic", zap.String("topic", topicID), zap.String("subscription", subID))
+	projectID, err := utils.ProjectIDOrDefault(r.ProjectID)
+	if err != nil {
+		logger.Error("Failed to find project id", zap.Error(err))
+		s.StatusUpdater().MarkTopicUnknown("FinalizeTopicProjectIdNotFound", "Failed to find project id: %v", err)
+		s.StatusUpdater().MarkSubscriptionUnknown("FinalizeSubscriptionProjectIdNotFound", "Failed to find project id: %v", err)
+		return err
+	}
+	client, err := r.getClientOrCreateNew(ctx, projectID, s.StatusUpdater())
+	if err != nil {
+		logger.Error("Failed to create Pub/Sub client", zap.Error(err))
+		return err
+	}
+	pubsubReconciler := reconcilerutilspubsub.NewReconciler(client, r.Recorder)
+	err = multierr.Append(nil, pubsubReconciler.DeleteTopic(ctx, topicID, s.Object(), s.StatusUpdater()))
+	return multierr.Append(err, pubsubReconciler.DeleteSubscription(ctx, subID, s.Object(), s.StatusUpdater()))
+}
+
+// DeleteRetryTopic deletes the retry topic with the given ID shared by the Targets of the
+// CellTenant, if it exists. The subscriptions of the Targets keep delivering the retries they
+// hold until they are deleted themselves.
+func (r *Reconciler) DeleteRetryTopic(ctx context.Context, s Statusable, topicID string) error {
+	logger := logging.FromContext(ctx)
+	logger.Debug("Deleting retry topic", zap.String("topic", topicID))
+	projectID, err := utils.ProjectIDOrDefault(r.ProjectID)
+	if err != nil {
+		logger.Error("Failed to find project id", zap.Error(err))
+		s.StatusUpdater().MarkTopicUnknown("FinalizeTopicProjectIdNotFound", "Failed to find project id: %v", err)
+		return err
+	}
+	client, err := r.getClientOrCreateNew(ctx, projectID, s.StatusUpdater())
+	if err != nil {
+		logger.Error("Failed to create Pub/Sub client", zap.Error(err))
+		return err
+	}
+	pubsubReconciler := reconcilerutilspubsub.NewReconciler(client, r.Recorder)
+	return pubsubReconciler.DeleteTopic(ctx, topicID, s.Object(), s.StatusUpdater())
+}
+
+// ReconcileArchiveSubscription creates the subscription with the given ID to the decouple topic of
+// the CellTenant, from which its events are archived, if it doesn't exist. The events are retained
+// as long as Pub/Sub allows, so that they are archived after an outage of the archive.
+func (r *Reconciler) ReconcileArchiveSubscription(ctx context.Context, s Statusable, subID string) error {
+	logger := logging.FromContext(ctx)
+	logger.Debug("Reconciling archive subscription", zap.String("subscription", subID))
+	projectID, err := utils.ProjectIDOrDefault(r.ProjectID)
+	if err != nil {
+		logger.Error("Failed to find project id", zap.Error(err))
+		s.StatusUpdater().MarkSubscriptionUnknown("ProjectIdNotFound", "Failed to find project id: %v", err)
+		return err
+	}
+	r.ClusterName, err = utils.ClusterName(r.ClusterName, metadataClient.NewDefaultMetadataClient())
+	if err != nil {
+		logger.Error("Failed to get cluster name: ", zap.Error(err))
+		return err
+	}
+
+	client, err := r.getClientOrCreateNew(ctx, projectID, s.StatusUpdater())
+	if err != nil {
+		logger.Error("Failed to create Pub/Sub client", zap.Error(err))
+		return err
+	}
+	pubsubReconciler := reconcilerutilspubsub.NewReconciler(client, r.Recorder)
+	subConfig := pubsub.SubscriptionConfig{
+		Topic:             client.Topic(s.GetTopicID()),
+		Labels:            subscriptionLabels(s.GetLabels(), r.ClusterName),
+		RetentionDuration: archiveRetentionDuration,
+	}
+	_, err = pubsubReconciler.ReconcileSubscription(ctx, subID, subConfig, s.Object(), s.StatusUpdater())
+	return err
+}
+
+// ReconcileFederationSubscription creates the subscription with the given ID to the decouple topic
+// of a remote Broker, from which its events are delivered to the targets of the CellTenant, if it
+// doesn't exist. The subscription is in the project of the CellTenant, the topic can be in any
+// project that allows attaching subscriptions to it.
+func (r *Reconciler) ReconcileFederationSubscription(ctx context.Context, s Statusable, subID, topicProject, topicID string) error {
+	logger := logging.FromContext(ctx)
+	logger.Debug("Reconciling federation subscription", zap.String("subscription", subID),
+		zap.String("project", topicProject), zap.String("topic", topicID))
+	projectID, err := utils.ProjectIDOrDefault(r.ProjectID)
+	if err != nil {
+		logger.Error("Failed to find project id", zap.Error(err))
+		s.StatusUpdater().MarkSubscriptionUnknown("ProjectIdNotFound", "Failed to find project id: %v", err)
+		return err
+	}
+	r.ClusterName, err = utils.ClusterName(r.ClusterName, metadataClient.NewDefaultMetadataClient())
+	if err != nil {
+		logger.Error("Failed to get cluster name: ", zap.Error(err))
+		return err
+	}
+
+	client, err := r.getClientOrCreateNew(ctx, projectID, s.StatusUpdater())
+	if err != nil {
+		logger.Error("Failed to create Pub/Sub client", zap.Error(err))
+		return err
+	}
+	pubsubReconciler := reconcilerutilspubsub.NewReconciler(client, r.Recorder)
+	subConfig := pubsub.SubscriptionConfig{
+		Topic:  client.TopicInProject(topicID, topicProject),
+		Labels: subscriptionLabels(s.GetLabels(), r.ClusterName),
+	}
+	_, err = pubsubReconciler.ReconcileSubscription(ctx, subID, subConfig, s.Object(), s.StatusUpdater())
+	return err
+}
+
+// DeleteSubscription deletes the subscription with the given ID of the CellTenant, such as its
+// archive subscription, if it exists. The events it holds are dropped.
+func (r *Reconciler) DeleteSubscription(ctx context.Context, s Statusable, subID string) error {
+	logger := logging.FromContext(ctx)
+	logger.Debug("Deleting subscription", zap.String("subscription", subID))
+	projectID, err := utils.ProjectIDOrDefault(r.ProjectID)
+	if err != nil {
+		logger.Error("Failed to find project id", zap.Error(err))
+		s.StatusUpdater().MarkSubscriptionUnknown("FinalizeSubscriptionProjectIdNotFound", "Failed to find project id: %v", err)
+		return err
+	}
+	client, err := r.getClientOrCreateNew(ctx, projectID, s.StatusUpdater())
+	if err != nil {
+		logger.Error("Failed to create Pub/Sub client", zap.Error(err))
+		return err
+	}
+	pubsubReconciler := reconcilerutilspubsub.NewReconciler(client, r.Recorder)
+	return pubsubReconciler.DeleteSubscription(ctx, subID, s.Object(), s.StatusUpdater())
+}
+
 // CreatePubsubClientFn is a function for pubsub client creation. Changed in testing only.
 // TODO Stop exporting this once unit tests are migrated from the Broker and Trigger reconcilers to
 // the CellTenant and Target reconcilers.
@@ -252,6 +496,22 @@ func (r *Reconciler) ensureBrokerCellExists(ctx context.Context, s Statusable) e
 		s.MarkBrokerCellUnknown("BrokerCellNotReady", "BrokerCell %s/%s is not ready", bc.Namespace, bc.Name)
 	}
 
+	// Brokers are also reachable at the external ingress of the BrokerCell, if any. Their address
+	// stays in-cluster so that the external URL is only handed out deliberately.
+	if b, ok := s.Object().(*brokerv1.Broker); ok {
+		var externalAddress *apis.URL
+		if bc.Status.ExternalIngressTemplate != "" {
+			var err error
+			externalAddress, err = apis.ParseURL(brokercellresources.ExpandIngressTemplate(bc.Status.ExternalIngressTemplate, b.Namespace, b.Name))
+			if err != nil {
+				logging.FromContext(ctx).Error("Failed to parse the external ingress address", zap.String("template", bc.Status.ExternalIngressTemplate), zap.Error(err))
+				s.MarkBrokerCellFailed("ExternalIngressInvalid", "Failed to parse the external ingress address of BrokerCell %s/%s: %v", bc.Namespace, bc.Name, err)
+				return err
+			}
+		}
+		b.Status.SetExternalAddress(externalAddress)
+	}
+
 	//TODO(#1019) Use the IngressTemplate of brokercell.
 	ingressServiceName := brokercellresources.Name(bc.Name, brokercellresources.IngressName)
 	s.SetAddress(&apis.URL{
@@ -262,3 +522,12 @@ func (r *Reconciler) ensureBrokerCellExists(ctx context.Context, s Statusable) e
 
 	return nil
 }
+
+// subscriptionLabels adds to the given labels of a CellTenant or Target the labels of its
+// subscriptions. They are pulled by the default BrokerCell of the cluster, whose backlog
+// autoscaling selects them by these labels.
+func subscriptionLabels(labels map[string]string, clusterName string) map[string]string {
+	labels[brokercellresources.SubscriptionBrokerCellLabelKey] = resources.DefaultBrokerCellName
+	labels[brokercellresources.SubscriptionClusterLabelKey] = clusterName
+	return labels
+}
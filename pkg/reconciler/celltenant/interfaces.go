@@ -27,6 +27,7 @@ import (
 	channelresources "github.com/google/knative-gcp/pkg/reconciler/messaging/channel/resources"
 	reconcilerutilspubsub "github.com/google/knative-gcp/pkg/reconciler/utils/pubsub"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	eventingduckv1 "knative.dev/eventing/pkg/apis/duck/v1"
@@ -42,20 +43,25 @@ type Target interface {
 	GetLabels() map[string]string
 	DeliverySpec() *eventingduckv1.DeliverySpec
 	SetStatusProjectID(projectID string)
+	// TopicConfigObject returns the resource whose data residency and encryption the topics of
+	// the Target follow.
+	TopicConfigObject() metav1.Object
 }
 
 var _ Target = (*targetForTrigger)(nil)
 
 type targetForTrigger struct {
 	trigger      *brokerv1.Trigger
+	broker       *brokerv1.Broker
 	deliverySpec *eventingduckv1.DeliverySpec
 }
 
 // TargetFromTrigger creates a Target for the given Trigger and associated
-// Broker's deliverySpec.
-func TargetFromTrigger(t *brokerv1.Trigger, deliverySpec *eventingduckv1.DeliverySpec) Target {
+// Broker's deliverySpec. The Broker may be nil if the Target is only deleted.
+func TargetFromTrigger(t *brokerv1.Trigger, b *brokerv1.Broker, deliverySpec *eventingduckv1.DeliverySpec) Target {
 	return &targetForTrigger{
 		trigger:      t,
+		broker:       b,
 		deliverySpec: deliverySpec,
 	}
 }
@@ -94,6 +100,43 @@ func (t *targetForTrigger) SetStatusProjectID(_ string) {
 	// t.trigger.Status.ProjectID = projectID
 }
 
+// TopicConfigObject returns the Broker of the Trigger, as the retries of the Trigger are events
+// of the Broker.
+func (t *targetForTrigger) TopicConfigObject() metav1.Object {
+	if t.broker == nil {
+		return t.trigger
+	}
+	return t.broker
+}
+
+var _ Target = (*consolidatedTargetForTrigger)(nil)
+
+// consolidatedTargetForTrigger is the Target of a Trigger whose retries are queued in the retry
+// topic of its Broker.
+type consolidatedTargetForTrigger struct {
+	targetForTrigger
+}
+
+// ConsolidatedTargetFromTrigger creates a Target for the given Trigger subscribing to the retry
+// topic of its Broker.
+func ConsolidatedTargetFromTrigger(t *brokerv1.Trigger, b *brokerv1.Broker, deliverySpec *eventingduckv1.DeliverySpec) Target {
+	return &consolidatedTargetForTrigger{
+		targetForTrigger: targetForTrigger{
+			trigger:      t,
+			broker:       b,
+			deliverySpec: deliverySpec,
+		},
+	}
+}
+
+func (t *consolidatedTargetForTrigger) GetTopicID() string {
+	return brokerresources.GenerateConsolidatedRetryTopicName(t.broker)
+}
+
+func (t *consolidatedTargetForTrigger) GetSubscriptionName() string {
+	return brokerresources.GenerateConsolidatedRetrySubscriptionName(t.trigger)
+}
+
 var _ Target = (*targetForSubscriberSpec)(nil)
 
 type targetForSubscriberSpec struct {
@@ -153,6 +196,10 @@ func (s *targetForSubscriberSpec) SetStatusProjectID(_ string) {
 	// ProjectID is stored on the Channel's status, not each subscriber's, so this is a noop.
 }
 
+func (s *targetForSubscriberSpec) TopicConfigObject() metav1.Object {
+	return s.channel
+}
+
 var _ Target = (*targetForSubscriberStatus)(nil)
 
 type targetForSubscriberStatus struct {
@@ -175,6 +222,10 @@ func (s *targetForSubscriberStatus) SetStatusProjectID(_ string) {
 	// ProjectID is stored on the Channel's status, not each subscriber's, so this is a noop.
 }
 
+func (s *targetForSubscriberStatus) TopicConfigObject() metav1.Object {
+	return s.channel
+}
+
 func TargetFromSubscriberStatus(channel *v1beta1.Channel, subscriberStatus eventingduckv1.SubscriberStatus) (Target, *SubscriberStatus) {
 	status := &SubscriberStatus{}
 	return &targetForSubscriberStatus{
@@ -271,6 +322,9 @@ type Statusable interface {
 	GetLabels() map[string]string
 	GetTopicID() string
 	GetSubscriptionName() string
+	// TopicConfigObject returns the resource whose data residency and encryption the topics of
+	// the CellTenant follow.
+	TopicConfigObject() metav1.Object
 }
 
 var _ Statusable = (*statusableForBroker)(nil)
@@ -309,6 +363,10 @@ func (b *statusableForBroker) Object() runtime.Object {
 	return b.broker
 }
 
+func (b *statusableForBroker) TopicConfigObject() metav1.Object {
+	return b.broker
+}
+
 func (b *statusableForBroker) StatusUpdater() reconcilerutilspubsub.StatusUpdater {
 	return &b.broker.Status
 }
@@ -367,6 +425,10 @@ func (c *statusableForChannel) Object() runtime.Object {
 	return c.ch
 }
 
+func (c *statusableForChannel) TopicConfigObject() metav1.Object {
+	return c.ch
+}
+
 func (c *statusableForChannel) StatusUpdater() reconcilerutilspubsub.StatusUpdater {
 	return &c.ch.Status
 }
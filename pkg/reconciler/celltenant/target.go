@@ -34,6 +34,7 @@ import (
 
 	"cloud.google.com/go/pubsub"
 	"github.com/google/knative-gcp/pkg/apis/configs/dataresidency"
+	"github.com/google/knative-gcp/pkg/apis/configs/encryption"
 	reconcilerutilspubsub "github.com/google/knative-gcp/pkg/reconciler/utils/pubsub"
 	"github.com/google/knative-gcp/pkg/utils"
 )
@@ -54,8 +55,13 @@ type TargetReconciler struct {
 
 	DataresidencyStore *dataresidency.Store
 
+	EncryptionStore *encryption.Store
+
 	// ClusterRegion is the region where GKE is running.
 	ClusterRegion string
+
+	// ClusterName is the name of the GKE cluster, which the subscriptions are labeled with.
+	ClusterName string
 }
 
 func (r *TargetReconciler) ReconcileRetryTopicAndSubscription(ctx context.Context, recorder record.EventRecorder, t Target) error {
@@ -77,6 +83,11 @@ func (r *TargetReconciler) ReconcileRetryTopicAndSubscription(ctx context.Contex
 		logger.Error("Failed to get cluster region: ", zap.Error(err))
 		return err
 	}
+	r.ClusterName, err = utils.ClusterName(r.ClusterName, metadataClient.NewDefaultMetadataClient())
+	if err != nil {
+		logger.Error("Failed to get cluster name: ", zap.Error(err))
+		return err
+	}
 
 	client, err := r.getClientOrCreateNew(ctx, projectID, t.StatusUpdater())
 	if err != nil {
@@ -89,15 +100,14 @@ func (r *TargetReconciler) ReconcileRetryTopicAndSubscription(ctx context.Contex
 	// Check if topic exists, and if not, create it.
 	topicID := t.GetTopicID()
 	topicConfig := &pubsub.TopicConfig{Labels: t.GetLabels()}
-	if r.DataresidencyStore != nil {
-		if r.DataresidencyStore.Load().DataResidencyDefaults.ComputeAllowedPersistenceRegions(topicConfig, r.ClusterRegion) {
-			logging.FromContext(ctx).Debug("Updated Topic Config AllowedPersistenceRegions for Trigger", zap.Any("topicConfig", *topicConfig))
-		}
-	}
+	requiredRegions, requiredKey := reconcilerutilspubsub.ComputeTopicConfig(ctx, r.DataresidencyStore, r.EncryptionStore, topicConfig, r.ClusterRegion, t.TopicConfigObject())
 	topic, err := pubsubReconciler.ReconcileTopic(ctx, topicID, topicConfig, t.Object(), t.StatusUpdater())
 	if err != nil {
 		return err
 	}
+	if err := pubsubReconciler.CheckTopicConfig(ctx, topic, requiredRegions, requiredKey, t.StatusUpdater()); err != nil {
+		return err
+	}
 	// TODO(grantr): this isn't actually persisted due to webhook issues.
 	//TODO uncomment when eventing webhook allows this
 	//trig.Status.TopicID = topic.ID()
@@ -109,7 +119,7 @@ func (r *TargetReconciler) ReconcileRetryTopicAndSubscription(ctx context.Contex
 	subID := t.GetSubscriptionName()
 	subConfig := pubsub.SubscriptionConfig{
 		Topic:            topic,
-		Labels:           t.GetLabels(),
+		Labels:           subscriptionLabels(t.GetLabels(), r.ClusterName),
 		RetryPolicy:      retryPolicy,
 		DeadLetterPolicy: deadLetterPolicy,
 		//TODO(grantr): configure these settings?
@@ -237,3 +247,87 @@ func (r *TargetReconciler) getClientOrCreateNew(ctx context.Context, projectID s
 	r.PubsubClient = client
 	return client, nil
 }
+
+// ReconcileFilteredRetrySubscription creates the retry subscription of the Target to the retry
+// topic shared by the Targets of its CellTenant, which only receives the retries matching the
+// filter. The topic is owned by the CellTenant, so it is not created here.
+func (r *TargetReconciler) ReconcileFilteredRetrySubscription(ctx context.Context, recorder record.EventRecorder, t Target, filter string) error {
+	logger := logging.FromContext(ctx)
+	logger.Debug("Reconciling filtered retry subscription")
+	projectID, err := utils.ProjectIDOrDefault(r.ProjectID)
+	if err != nil {
+		logger.Error("Failed to find project id", zap.Error(err))
+		t.StatusUpdater().MarkTopicUnknown("ProjectIdNotFound", "Failed to find project id: %v", err)
+		t.StatusUpdater().MarkSubscriptionUnknown("ProjectIdNotFound", "Failed to find project id: %v", err)
+		return err
+	}
+	t.SetStatusProjectID(projectID)
+
+	r.ClusterName, err = utils.ClusterName(r.ClusterName, metadataClient.NewDefaultMetadataClient())
+	if err != nil {
+		logger.Error("Failed to get cluster name: ", zap.Error(err))
+		return err
+	}
+
+	client, err := r.getClientOrCreateNew(ctx, projectID, t.StatusUpdater())
+	if err != nil {
+		logger.Error("Failed to create Pub/Sub client", zap.Error(err))
+		return err
+	}
+
+	topicID := t.GetTopicID()
+	topic := client.Topic(topicID)
+	exists, err := topic.Exists(ctx)
+	if err != nil {
+		logger.Error("Failed to verify Pub/Sub topic exists", zap.Error(err))
+		t.StatusUpdater().MarkTopicUnknown("TopicVerificationFailed", "Failed to verify Pub/Sub topic exists: %v", err)
+		return err
+	}
+	if !exists {
+		t.StatusUpdater().MarkTopicUnknown("RetryTopicNotFound", "Retry topic %q does not exist yet", topicID)
+		return fmt.Errorf("retry topic %q does not exist yet", topicID)
+	}
+	t.StatusUpdater().MarkTopicReady()
+
+	subConfig := pubsub.SubscriptionConfig{
+		Topic:            topic,
+		Labels:           subscriptionLabels(t.GetLabels(), r.ClusterName),
+		RetryPolicy:      getPubsubRetryPolicy(ctx, t.DeliverySpec()),
+		DeadLetterPolicy: getPubsubDeadLetterPolicy(projectID, t.DeliverySpec()),
+		Filter:           filter,
+	}
+	pubsubReconciler := reconcilerutilspubsub.NewReconciler(client, recorder)
+	_, err = pubsubReconciler.ReconcileSubscription(ctx, t.GetSubscriptionName(), subConfig, t.Object(), t.StatusUpdater())
+	return err
+}
+
+// RetrySubscriptionExists returns whether the retry subscription of the Target exists.
+func (r *TargetReconciler) RetrySubscriptionExists(ctx context.Context, t Target) (bool, error) {
+	projectID, err := utils.ProjectIDOrDefault(r.ProjectID)
+	if err != nil {
+		return false, err
+	}
+	client, err := r.getClientOrCreateNew(ctx, projectID, t.StatusUpdater())
+	if err != nil {
+		return false, err
+	}
+	return client.Subscription(t.GetSubscriptionName()).Exists(ctx)
+}
+
+// DeleteRetrySubscription deletes the retry subscription with the given ID of the Target, if it
+// exists. If deleteTopic is set, the retry topic of the Target is deleted as well.
+func (r *TargetReconciler) DeleteRetrySubscription(ctx context.Context, recorder record.EventRecorder, t Target, subID string, deleteTopic bool) error {
+	projectID, err := utils.ProjectIDOrDefault(r.ProjectID)
+	if err != nil {
+		return err
+	}
+	client, err := r.getClientOrCreateNew(ctx, projectID, t.StatusUpdater())
+	if err != nil {
+		return err
+	}
+	pubsubReconciler := reconcilerutilspubsub.NewReconciler(client, recorder)
+	if deleteTopic {
+		err = pubsubReconciler.DeleteTopic(ctx, t.GetTopicID(), t.Object(), t.StatusUpdater())
+	}
+	return multierr.Append(err, pubsubReconciler.DeleteSubscription(ctx, subID, t.Object(), t.StatusUpdater()))
+}
@@ -204,6 +204,24 @@ func (i *Identity) removeIamPolicyBinding(ctx context.Context, projectID string,
 	return i.policyManager.RemoveIAMPolicyBinding(ctx, iam.GServiceAccount(identityNames.GoogleServiceAccountName), currentMember, Role)
 }
 
+// WorkloadIdentityMember returns the IAM member of the Google service account that the Kubernetes
+// service account is bound to through Workload Identity. It returns an empty string if the
+// Kubernetes service account doesn't exist or isn't bound, in which case the permissions have to be
+// granted to its credentials by the user.
+func WorkloadIdentityMember(ctx context.Context, kubeClient kubernetes.Interface, namespace, name string) (string, error) {
+	ksa, err := kubeClient.CoreV1().ServiceAccounts(namespace).Get(ctx, name, metav1.GetOptions{})
+	if apierrs.IsNotFound(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if gsa := ksa.Annotations[resources.WorkloadIdentityKey]; gsa != "" {
+		return "serviceAccount:" + gsa, nil
+	}
+	return "", nil
+}
+
 // ownerReferenceExists checks if a K8s ServiceAccount contains specific ownerReference
 func ownerReferenceExists(kServiceAccount *corev1.ServiceAccount, expect metav1.OwnerReference) bool {
 	references := kServiceAccount.OwnerReferences
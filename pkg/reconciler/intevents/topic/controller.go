@@ -34,6 +34,7 @@ import (
 	serviceinformer "knative.dev/serving/pkg/client/injection/informers/serving/v1/service"
 
 	"github.com/google/knative-gcp/pkg/apis/configs/dataresidency"
+	"github.com/google/knative-gcp/pkg/apis/configs/encryption"
 	"github.com/google/knative-gcp/pkg/apis/configs/gcpauth"
 	v1 "github.com/google/knative-gcp/pkg/apis/intevents/v1"
 	topicinformer "github.com/google/knative-gcp/pkg/client/injection/informers/intevents/v1/topic"
@@ -61,9 +62,9 @@ type envConfig struct {
 type Constructor injection.ControllerConstructor
 
 // NewConstructor creates a constructor to make a Topic controller.
-func NewConstructor(ipm iam.IAMPolicyManager, gcpas *gcpauth.StoreSingleton, dataresidencyss *dataresidency.StoreSingleton) Constructor {
+func NewConstructor(ipm iam.IAMPolicyManager, gcpas *gcpauth.StoreSingleton, dataresidencyss *dataresidency.StoreSingleton, encryptionss *encryption.StoreSingleton) Constructor {
 	return func(ctx context.Context, cmw configmap.Watcher) *controller.Impl {
-		return newController(ctx, cmw, ipm, gcpas.Store(ctx, cmw), dataresidencyss.Store(ctx, cmw))
+		return newController(ctx, cmw, ipm, gcpas.Store(ctx, cmw), dataresidencyss.Store(ctx, cmw), encryptionss.Store(ctx, cmw))
 	}
 }
 
@@ -73,6 +74,7 @@ func newController(
 	ipm iam.IAMPolicyManager,
 	gcpas *gcpauth.Store,
 	dataresidencyStore *dataresidency.Store,
+	encryptionStore *encryption.Store,
 ) *controller.Impl {
 	topicInformer := topicinformer.Get(ctx)
 	serviceInformer := serviceinformer.Get(ctx)
@@ -91,6 +93,7 @@ func newController(
 		Base:                 reconciler.NewBase(ctx, controllerAgentName, cmw),
 		Identity:             identity.NewIdentity(ctx, ipm, gcpas),
 		dataresidencyStore:   dataresidencyStore,
+		encryptionStore:      encryptionStore,
 		topicLister:          topicLister,
 		serviceLister:        serviceInformer.Lister(),
 		serviceAccountLister: serviceAccountInformer.Lister(),
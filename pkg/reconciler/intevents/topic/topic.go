@@ -44,6 +44,7 @@ import (
 	gstatus "google.golang.org/grpc/status"
 
 	"github.com/google/knative-gcp/pkg/apis/configs/dataresidency"
+	"github.com/google/knative-gcp/pkg/apis/configs/encryption"
 	v1 "github.com/google/knative-gcp/pkg/apis/intevents/v1"
 	topicreconciler "github.com/google/knative-gcp/pkg/client/injection/reconciler/intevents/v1/topic"
 	listers "github.com/google/knative-gcp/pkg/client/listers/intevents/v1"
@@ -73,6 +74,8 @@ type Reconciler struct {
 	*identity.Identity
 	// data residency store
 	dataresidencyStore *dataresidency.Store
+	// encryption store
+	encryptionStore *encryption.Store
 	// topicLister index properties about topics.
 	topicLister listers.TopicLister
 	// serviceLister index properties about services.
@@ -162,6 +165,9 @@ func (r *Reconciler) reconcileTopic(ctx context.Context, topic *v1.Topic) error
 		return err
 	}
 
+	topicConfig := &pubsub.TopicConfig{}
+	requiredRegions, requiredKey := reconcilerutilspubsub.ComputeTopicConfig(ctx, r.dataresidencyStore, r.encryptionStore, topicConfig, r.clusterRegion, topic)
+
 	t := client.Topic(topic.Spec.Topic)
 	exists, err := t.Exists(ctx)
 	if err != nil {
@@ -174,12 +180,6 @@ func (r *Reconciler) reconcileTopic(ctx context.Context, topic *v1.Topic) error
 			logging.FromContext(ctx).Desugar().Error("Topic does not exist and the topic policy doesn't allow creation")
 			return fmt.Errorf("Topic %q does not exist and the topic policy doesn't allow creation", topic.Spec.Topic)
 		} else {
-			topicConfig := &pubsub.TopicConfig{}
-			if r.dataresidencyStore != nil {
-				if r.dataresidencyStore.Load().DataResidencyDefaults.ComputeAllowedPersistenceRegions(topicConfig, r.clusterRegion) {
-					logging.FromContext(ctx).Desugar().Debug("Updated Topic Config AllowedPersistenceRegions for topic reconciler", zap.Any("topicConfig", *topicConfig))
-				}
-			}
 			// Create a new topic with the given name.
 			t, err = client.CreateTopicWithConfig(ctx, topic.Spec.Topic, topicConfig)
 			if err != nil {
@@ -196,8 +196,10 @@ func (r *Reconciler) reconcileTopic(ctx context.Context, topic *v1.Topic) error
 				return nil
 			}
 		}
+		return nil
 	}
-	return nil
+	// The settings of an existing topic are not changed, they are only checked.
+	return reconcilerutilspubsub.NewReconciler(client, r.Recorder).CheckTopicConfig(ctx, t, requiredRegions, requiredKey, &topicStatusUpdater{status: &topic.Status})
 }
 
 // deleteTopic looks at the status.TopicID and if non-empty,
@@ -324,3 +326,29 @@ func (r *Reconciler) FinalizeKind(ctx context.Context, topic *v1.Topic) pkgrecon
 	}
 	return nil
 }
+
+// topicStatusUpdater reports the state of the Pub/Sub topic of a Topic on its status. A Topic has
+// no subscription, so the subscription state is ignored.
+type topicStatusUpdater struct {
+	status *v1.TopicStatus
+}
+
+var _ reconcilerutilspubsub.StatusUpdater = (*topicStatusUpdater)(nil)
+
+func (u *topicStatusUpdater) MarkTopicFailed(reason, format string, args ...interface{}) {
+	u.status.MarkNoTopic(reason, format, args...)
+}
+
+func (u *topicStatusUpdater) MarkTopicUnknown(reason, format string, args ...interface{}) {
+	u.status.MarkNoTopic(reason, format, args...)
+}
+
+func (u *topicStatusUpdater) MarkTopicReady() {
+	u.status.MarkTopicReady()
+}
+
+func (u *topicStatusUpdater) MarkSubscriptionFailed(string, string, ...interface{}) {}
+
+func (u *topicStatusUpdater) MarkSubscriptionUnknown(string, string, ...interface{}) {}
+
+func (u *topicStatusUpdater) MarkSubscriptionReady(string) {}
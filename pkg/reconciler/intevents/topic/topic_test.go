@@ -49,6 +49,8 @@ import (
 	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
 
 	"github.com/google/knative-gcp/pkg/apis/configs/dataresidency"
+	"github.com/google/knative-gcp/pkg/apis/configs/encryption"
+	"github.com/google/knative-gcp/pkg/apis/duck"
 	pubsubv1 "github.com/google/knative-gcp/pkg/apis/intevents/v1"
 	"github.com/google/knative-gcp/pkg/client/injection/reconciler/intevents/v1/topic"
 	"github.com/google/knative-gcp/pkg/reconciler"
@@ -68,6 +70,9 @@ const (
 	testTopicURI      = "http://" + topicName + "-topic." + testNS + ".svc.cluster.local"
 	testClusterRegion = "us-east1"
 
+	testClusterKMSKeyName = "projects/test-project-id/locations/us-east1/keyRings/ring/cryptoKeys/cluster-key"
+	testTopicKMSKeyName   = "projects/test-project-id/locations/us-east1/keyRings/ring/cryptoKeys/topic-key"
+
 	secretName = "testing-secret"
 
 	failedToReconcileTopicMsg = `Failed to reconcile Pub/Sub topic`
@@ -767,6 +772,232 @@ func TestAllCases(t *testing.T) {
 				},
 			}),
 		},
+	}, {
+		Name: "topic created in the regions of its allowed persistence regions annotation",
+		Objects: []runtime.Object{
+			reconcilertestingv1.NewTopic(topicName, testNS,
+				reconcilertestingv1.WithTopicUID(topicUID),
+				reconcilertestingv1.WithTopicAnnotations(map[string]string{
+					duck.AllowedPersistenceRegionsAnnotation: "europe-west1",
+				}),
+				reconcilertestingv1.WithTopicSpec(pubsubv1.TopicSpec{
+					Project: testProject,
+					Topic:   testTopicID,
+					Secret:  &secret,
+				}),
+				reconcilertestingv1.WithTopicPropagationPolicy("CreateNoDelete"),
+				reconcilertestingv1.WithTopicSetDefaults,
+			),
+			newSink(),
+			newSecret(),
+		},
+		Key: testNS + "/" + topicName,
+		WantPatches: []clientgotesting.PatchActionImpl{
+			patchFinalizers(testNS, topicName, resourceGroup),
+		},
+		WantEvents: []string{
+			Eventf(corev1.EventTypeNormal, "FinalizerUpdate", "Updated %q finalizers", topicName),
+			Eventf(corev1.EventTypeNormal, reconciledSuccessReason, `Topic reconciled: "%s/%s"`, testNS, topicName),
+		},
+		WithReactors: []clientgotesting.ReactionFunc{
+			ProvideResource("create", "services", makeReadyPublisher()),
+		},
+		WantCreates: []runtime.Object{
+			newPublisher(),
+		},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: reconcilertestingv1.NewTopic(topicName, testNS,
+				reconcilertestingv1.WithTopicUID(topicUID),
+				reconcilertestingv1.WithTopicAnnotations(map[string]string{
+					duck.AllowedPersistenceRegionsAnnotation: "europe-west1",
+				}),
+				reconcilertestingv1.WithTopicProjectID(testProject),
+				reconcilertestingv1.WithTopicSpec(pubsubv1.TopicSpec{
+					Project: testProject,
+					Topic:   testTopicID,
+					Secret:  &secret,
+				}),
+				reconcilertestingv1.WithTopicPropagationPolicy("CreateNoDelete"),
+				// Updates
+				reconcilertestingv1.WithInitTopicConditions,
+				reconcilertestingv1.WithTopicReadyAndPublisherDeployed(testTopicID),
+				reconcilertestingv1.WithTopicPublisherDeployed,
+				reconcilertestingv1.WithTopicAddress(testTopicURI),
+				reconcilertestingv1.WithTopicSetDefaults,
+			),
+		}},
+		OtherTestData: map[string]interface{}{
+			"pre":                    []PubsubAction{},
+			"dataResidencyConfigMap": NewDataresidencyConfigMapFromRegions([]string{"us-east1"}),
+		},
+		PostConditions: []func(*testing.T, *TableRow){
+			TopicExistsWithConfig(testTopicID, &pubsub.TopicConfig{
+				MessageStoragePolicy: pubsub.MessageStoragePolicy{
+					AllowedPersistenceRegions: []string{"europe-west1"},
+				},
+			}),
+		},
+	}, {
+		Name: "existing topic conflicts with data residency",
+		Objects: []runtime.Object{
+			reconcilertestingv1.NewTopic(topicName, testNS,
+				reconcilertestingv1.WithTopicUID(topicUID),
+				reconcilertestingv1.WithTopicSpec(pubsubv1.TopicSpec{
+					Project: testProject,
+					Topic:   testTopicID,
+					Secret:  &secret,
+				}),
+				reconcilertestingv1.WithTopicPropagationPolicy("NoCreateNoDelete"),
+				reconcilertestingv1.WithTopicSetDefaults,
+			),
+			newSink(),
+			newSecret(),
+		},
+		Key: testNS + "/" + topicName,
+		WantEvents: []string{
+			Eventf(corev1.EventTypeNormal, "FinalizerUpdate", "Updated %q finalizers", topicName),
+			Eventf(corev1.EventTypeWarning, reconciledTopicFailedReason, "Failed to reconcile Pub/Sub topic: topic %q persists messages in regions [us-central1], the data residency only allows [us-east1]", testTopicID),
+		},
+		WantPatches: []clientgotesting.PatchActionImpl{
+			patchFinalizers(testNS, topicName, resourceGroup),
+		},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: reconcilertestingv1.NewTopic(topicName, testNS,
+				reconcilertestingv1.WithTopicUID(topicUID),
+				reconcilertestingv1.WithTopicProjectID(testProject),
+				reconcilertestingv1.WithTopicSpec(pubsubv1.TopicSpec{
+					Project: testProject,
+					Topic:   testTopicID,
+					Secret:  &secret,
+				}),
+				reconcilertestingv1.WithTopicPropagationPolicy("NoCreateNoDelete"),
+				// Updates
+				reconcilertestingv1.WithInitTopicConditions,
+				reconcilertestingv1.WithTopicNoTopic("TopicReconcileFailed", fmt.Sprintf("%s: topic %q persists messages in regions [us-central1], the data residency only allows [us-east1]", failedToReconcileTopicMsg, testTopicID)),
+				reconcilertestingv1.WithTopicSetDefaults,
+			),
+		}},
+		OtherTestData: map[string]interface{}{
+			"pre": []PubsubAction{
+				TopicWithConfig(testTopicID, &pubsub.TopicConfig{
+					MessageStoragePolicy: pubsub.MessageStoragePolicy{
+						AllowedPersistenceRegions: []string{"us-central1"},
+					},
+				}),
+			},
+			"dataResidencyConfigMap": NewDataresidencyConfigMapFromRegions([]string{"us-east1"}),
+		},
+	}, {
+		Name: "topic created with the key of its kms key name annotation",
+		Objects: []runtime.Object{
+			reconcilertestingv1.NewTopic(topicName, testNS,
+				reconcilertestingv1.WithTopicUID(topicUID),
+				reconcilertestingv1.WithTopicAnnotations(map[string]string{
+					duck.KMSKeyNameAnnotation: testTopicKMSKeyName,
+				}),
+				reconcilertestingv1.WithTopicSpec(pubsubv1.TopicSpec{
+					Project: testProject,
+					Topic:   testTopicID,
+					Secret:  &secret,
+				}),
+				reconcilertestingv1.WithTopicPropagationPolicy("CreateNoDelete"),
+				reconcilertestingv1.WithTopicSetDefaults,
+			),
+			newSink(),
+			newSecret(),
+		},
+		Key: testNS + "/" + topicName,
+		WantPatches: []clientgotesting.PatchActionImpl{
+			patchFinalizers(testNS, topicName, resourceGroup),
+		},
+		WantEvents: []string{
+			Eventf(corev1.EventTypeNormal, "FinalizerUpdate", "Updated %q finalizers", topicName),
+			Eventf(corev1.EventTypeNormal, reconciledSuccessReason, `Topic reconciled: "%s/%s"`, testNS, topicName),
+		},
+		WithReactors: []clientgotesting.ReactionFunc{
+			ProvideResource("create", "services", makeReadyPublisher()),
+		},
+		WantCreates: []runtime.Object{
+			newPublisher(),
+		},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: reconcilertestingv1.NewTopic(topicName, testNS,
+				reconcilertestingv1.WithTopicUID(topicUID),
+				reconcilertestingv1.WithTopicAnnotations(map[string]string{
+					duck.KMSKeyNameAnnotation: testTopicKMSKeyName,
+				}),
+				reconcilertestingv1.WithTopicProjectID(testProject),
+				reconcilertestingv1.WithTopicSpec(pubsubv1.TopicSpec{
+					Project: testProject,
+					Topic:   testTopicID,
+					Secret:  &secret,
+				}),
+				reconcilertestingv1.WithTopicPropagationPolicy("CreateNoDelete"),
+				// Updates
+				reconcilertestingv1.WithInitTopicConditions,
+				reconcilertestingv1.WithTopicReadyAndPublisherDeployed(testTopicID),
+				reconcilertestingv1.WithTopicPublisherDeployed,
+				reconcilertestingv1.WithTopicAddress(testTopicURI),
+				reconcilertestingv1.WithTopicSetDefaults,
+			),
+		}},
+		OtherTestData: map[string]interface{}{
+			"pre":                 []PubsubAction{},
+			"encryptionConfigMap": NewEncryptionConfigMapFromKMSKeyName(testClusterKMSKeyName),
+		},
+		PostConditions: []func(*testing.T, *TableRow){
+			TopicExistsWithConfig(testTopicID, &pubsub.TopicConfig{
+				KMSKeyName: testTopicKMSKeyName,
+			}),
+		},
+	}, {
+		Name: "existing topic conflicts with encryption",
+		Objects: []runtime.Object{
+			reconcilertestingv1.NewTopic(topicName, testNS,
+				reconcilertestingv1.WithTopicUID(topicUID),
+				reconcilertestingv1.WithTopicSpec(pubsubv1.TopicSpec{
+					Project: testProject,
+					Topic:   testTopicID,
+					Secret:  &secret,
+				}),
+				reconcilertestingv1.WithTopicPropagationPolicy("NoCreateNoDelete"),
+				reconcilertestingv1.WithTopicSetDefaults,
+			),
+			newSink(),
+			newSecret(),
+		},
+		Key: testNS + "/" + topicName,
+		WantEvents: []string{
+			Eventf(corev1.EventTypeNormal, "FinalizerUpdate", "Updated %q finalizers", topicName),
+			Eventf(corev1.EventTypeWarning, reconciledTopicFailedReason, "Failed to reconcile Pub/Sub topic: topic %q is encrypted with key %q, the encryption requires key %q", testTopicID, testTopicKMSKeyName, testClusterKMSKeyName),
+		},
+		WantPatches: []clientgotesting.PatchActionImpl{
+			patchFinalizers(testNS, topicName, resourceGroup),
+		},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: reconcilertestingv1.NewTopic(topicName, testNS,
+				reconcilertestingv1.WithTopicUID(topicUID),
+				reconcilertestingv1.WithTopicProjectID(testProject),
+				reconcilertestingv1.WithTopicSpec(pubsubv1.TopicSpec{
+					Project: testProject,
+					Topic:   testTopicID,
+					Secret:  &secret,
+				}),
+				reconcilertestingv1.WithTopicPropagationPolicy("NoCreateNoDelete"),
+				// Updates
+				reconcilertestingv1.WithInitTopicConditions,
+				reconcilertestingv1.WithTopicNoTopic("TopicReconcileFailed", fmt.Sprintf("%s: topic %q is encrypted with key %q, the encryption requires key %q", failedToReconcileTopicMsg, testTopicID, testTopicKMSKeyName, testClusterKMSKeyName)),
+				reconcilertestingv1.WithTopicSetDefaults,
+			),
+		}},
+		OtherTestData: map[string]interface{}{
+			"pre": []PubsubAction{
+				TopicWithConfig(testTopicID, &pubsub.TopicConfig{
+					KMSKeyName: testTopicKMSKeyName,
+				}),
+			},
+			"encryptionConfigMap": NewEncryptionConfigMapFromKMSKeyName(testClusterKMSKeyName),
+		},
 	}}
 
 	table.Test(t, MakeFactory(func(ctx context.Context, listers *Listers, cmw configmap.Watcher, testData map[string]interface{}) controller.Reconciler {
@@ -805,6 +1036,11 @@ func TestAllCases(t *testing.T) {
 		if cm, ok := testData["dataResidencyConfigMap"]; ok {
 			drStore = NewDataresidencyTestStore(t, cm.(*corev1.ConfigMap))
 		}
+		// If we found "encryptionConfigMap" in OtherData, we create a store with the configmap
+		var eStore *encryption.Store
+		if cm, ok := testData["encryptionConfigMap"]; ok {
+			eStore = NewEncryptionTestStore(t, cm.(*corev1.ConfigMap))
+		}
 		// use normal create function or always error one
 		var createClientFn reconcilerutilspubsub.CreateFn
 		if testData != nil && testData["client-error"] != nil {
@@ -822,6 +1058,7 @@ func TestAllCases(t *testing.T) {
 			publisherImage:     testImage,
 			createClientFn:     createClientFn,
 			dataresidencyStore: drStore,
+			encryptionStore:    eStore,
 			clusterRegion:      testClusterRegion,
 		}
 		return topic.NewReconciler(ctx, r.Logger, r.RunClientSet, listers.GetTopicLister(), r.Recorder, r)
@@ -33,6 +33,7 @@ import (
 
 	"cloud.google.com/go/pubsub/pstest"
 	"github.com/google/knative-gcp/pkg/apis/configs/dataresidency"
+	"github.com/google/knative-gcp/pkg/apis/configs/encryption"
 	"github.com/google/knative-gcp/pkg/client/injection/ducks/duck/v1alpha1/resource"
 	"github.com/google/knative-gcp/pkg/reconciler/celltenant"
 	"knative.dev/pkg/client/injection/ducks/duck/v1/addressable"
@@ -66,6 +67,8 @@ const (
 
 	testProject       = "test-project-id"
 	testClusterRegion = "us-east1"
+	testClusterName   = "test-cluster"
+	testKMSKeyName    = "projects/test-project-id/locations/us-east1/keyRings/ring/cryptoKeys/key"
 
 	subscriptionUID        = subscriptionName + "-def-123"
 	subscriptionName       = "testsubscription"
@@ -178,7 +181,7 @@ func TestAllCasesChannel(t *testing.T) {
 		},
 		OtherTestData: map[string]interface{}{
 			"pre": []PubsubAction{
-				TopicAndSub("cre-ch_testnamespace_test-channel_test-channel-abc-123", "cre-ch_testnamespace_test-channel_test-channel-abc-123"),
+				decouplingTopicAndSub(),
 			},
 		},
 		PostConditions: []func(*testing.T, *TableRow){
@@ -235,7 +238,7 @@ func TestAllCasesChannel(t *testing.T) {
 		},
 		OtherTestData: map[string]interface{}{
 			"pre": []PubsubAction{
-				TopicAndSub("cre-ch_testnamespace_test-channel_test-channel-abc-123", "cre-ch_testnamespace_test-channel_test-channel-abc-123"),
+				decouplingTopicAndSub(),
 				TopicAndSub("cre-sub_testnamespace_test-channel_testsubscription-def-123", "cre-sub_testnamespace_test-channel_testsubscription-def-123"),
 			},
 		},
@@ -429,6 +432,45 @@ func TestAllCasesChannel(t *testing.T) {
 				},
 			}),
 		},
+	}, {
+		Name: "Check topic config with correct encryption key",
+		Key:  testKey,
+		Objects: []runtime.Object{
+			NewChannel(channelName, testNS,
+				WithChannelUID(channelUID),
+				WithChannelSetDefaults),
+			NewBrokerCell(resources.DefaultBrokerCellName, systemNS,
+				WithBrokerCellReady,
+				WithBrokerCellSetDefaults),
+		},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: NewChannel(channelName, testNS,
+				WithChannelUID(channelUID),
+				WithChannelReadyURI(channelURI),
+				WithChannelSetDefaults,
+			),
+		}},
+		WantEvents: []string{
+			channelFinalizerUpdatedEvent,
+			Eventf(corev1.EventTypeNormal, "TopicCreated", `Created PubSub topic "cre-ch_testnamespace_test-channel_test-channel-abc-123"`),
+			Eventf(corev1.EventTypeNormal, "SubscriptionCreated", `Created PubSub subscription "cre-ch_testnamespace_test-channel_test-channel-abc-123"`),
+			channelReconciledEvent,
+		},
+		WantPatches: []clientgotesting.PatchActionImpl{
+			patchFinalizers(testNS, channelName, channelFinalizerName),
+		},
+		OtherTestData: map[string]interface{}{
+			"pre":                 []PubsubAction{},
+			"encryptionConfigMap": NewEncryptionConfigMapFromKMSKeyName(testKMSKeyName),
+		},
+		PostConditions: []func(*testing.T, *TableRow){
+			TopicExistsWithConfig("cre-ch_testnamespace_test-channel_test-channel-abc-123", &pubsub.TopicConfig{
+				KMSKeyName: testKMSKeyName,
+				Labels: map[string]string{
+					"name": "test-channel", "namespace": "testnamespace", "resource": "channels",
+				},
+			}),
+		},
 	}, {
 		Name: "Create channel with ready brokerCell with nil Pubsub client",
 		Key:  testKey,
@@ -614,7 +656,7 @@ func TestAllCasesChannel(t *testing.T) {
 		},
 		OtherTestData: map[string]interface{}{
 			"pre": []PubsubAction{
-				TopicAndSub("cre-ch_testnamespace_test-channel_test-channel-abc-123", "cre-ch_testnamespace_test-channel_test-channel-abc-123"),
+				decouplingTopicAndSub(),
 				TopicAndSub("cre-sub_testnamespace_test-channel_testsubscription-def-123", "cre-sub_testnamespace_test-channel_testsubscription-def-123"),
 			},
 		},
@@ -660,7 +702,7 @@ func TestAllCasesChannel(t *testing.T) {
 		},
 		OtherTestData: map[string]interface{}{
 			"pre": []PubsubAction{
-				TopicAndSub("cre-ch_testnamespace_test-channel_test-channel-abc-123", "cre-ch_testnamespace_test-channel_test-channel-abc-123"),
+				decouplingTopicAndSub(),
 				TopicAndSub("cre-sub_testnamespace_test-channel_testsubscription-def-123", "cre-sub_testnamespace_test-channel_testsubscription-def-123"),
 			},
 		},
@@ -916,7 +958,7 @@ func TestAllCasesChannel(t *testing.T) {
 		},
 		OtherTestData: map[string]interface{}{
 			"pre": []PubsubAction{
-				TopicAndSub("cre-ch_testnamespace_test-channel_test-channel-abc-123", "cre-ch_testnamespace_test-channel_test-channel-abc-123"),
+				decouplingTopicAndSub(),
 				TopicAndSub("cre-sub_testnamespace_test-channel_unchanged-1-uid", "cre-sub_testnamespace_test-channel_unchanged-1-uid"),
 				TopicAndSub("cre-sub_testnamespace_test-channel_updated-1-uid", "cre-sub_testnamespace_test-channel_updated-1-uid"),
 				TopicAndSub("cre-sub_testnamespace_test-channel_deleted-1-uid", "cre-sub_testnamespace_test-channel_deleted-1-uid"),
@@ -990,6 +1032,12 @@ func TestAllCasesChannel(t *testing.T) {
 			drStore = NewDataresidencyTestStore(t, cm.(*corev1.ConfigMap))
 		}
 
+		// If we found "encryptionConfigMap" in OtherData, we create a store with the configmap
+		var eStore *encryption.Store
+		if cm, ok := testData["encryptionConfigMap"]; ok {
+			eStore = NewEncryptionTestStore(t, cm.(*corev1.ConfigMap))
+		}
+
 		// If maxPSClientCreateTime is in testData, no pubsub client is passed to reconciler, the reconciler
 		// will create one in demand
 		testPSClient := psclient
@@ -1009,15 +1057,39 @@ func TestAllCasesChannel(t *testing.T) {
 				ProjectID:          testProject,
 				PubsubClient:       testPSClient,
 				DataresidencyStore: drStore,
+				EncryptionStore:    eStore,
 				ClusterRegion:      testClusterRegion,
+				ClusterName:        testClusterName,
 			},
 			targetReconciler: &celltenant.TargetReconciler{
 				ProjectID:          testProject,
 				PubsubClient:       testPSClient,
 				DataresidencyStore: drStore,
+				EncryptionStore:    eStore,
 				ClusterRegion:      testClusterRegion,
+				ClusterName:        testClusterName,
 			},
 		}
 		return channelreconciler.NewReconciler(ctx, r.Logger, r.RunClientSet, listers.GetChannelLister(), r.Recorder, r)
 	}))
 }
+
+// decouplingTopicAndSub creates the decoupling topic and subscription of the Channel as the
+// reconciler does.
+func decouplingTopicAndSub() PubsubAction {
+	return func(ctx context.Context, t *testing.T, c *pubsub.Client) {
+		Topic(testTopicID)(ctx, t, c)
+		if _, err := c.CreateSubscription(ctx, testTopicID, pubsub.SubscriptionConfig{
+			Topic: c.Topic(testTopicID),
+			Labels: map[string]string{
+				"resource":   "channels",
+				"namespace":  testNS,
+				"name":       channelName,
+				"brokercell": resources.DefaultBrokerCellName,
+				"cluster":    testClusterName,
+			},
+		}); err != nil {
+			t.Fatalf("Error creating decoupling subscription: %v", err)
+		}
+	}
+}
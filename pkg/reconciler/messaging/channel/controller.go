@@ -22,6 +22,7 @@ import (
 	channellister "github.com/google/knative-gcp/pkg/client/listers/messaging/v1beta1"
 
 	"github.com/google/knative-gcp/pkg/apis/configs/dataresidency"
+	"github.com/google/knative-gcp/pkg/apis/configs/encryption"
 
 	"github.com/google/knative-gcp/pkg/logging"
 
@@ -53,9 +54,9 @@ const (
 type Constructor injection.ControllerConstructor
 
 // NewConstructor creates a constructor to make a Channel controller.
-func NewConstructor(drs *dataresidency.StoreSingleton) Constructor {
+func NewConstructor(drs *dataresidency.StoreSingleton, es *encryption.StoreSingleton) Constructor {
 	return func(ctx context.Context, cmw configmap.Watcher) *controller.Impl {
-		return newController(ctx, cmw, drs.Store(ctx, cmw))
+		return newController(ctx, cmw, drs.Store(ctx, cmw), es.Store(ctx, cmw))
 	}
 }
 
@@ -63,6 +64,7 @@ func newController(
 	ctx context.Context,
 	cmw configmap.Watcher,
 	drs *dataresidency.Store,
+	es *encryption.Store,
 ) *controller.Impl {
 	channelInformer := channelinformer.Get(ctx)
 	bcInformer := brokercellinformer.Get(ctx)
@@ -97,11 +99,13 @@ func newController(
 			ProjectID:          projectID,
 			PubsubClient:       client,
 			DataresidencyStore: drs,
+			EncryptionStore:    es,
 		},
 		targetReconciler: &celltenant.TargetReconciler{
 			ProjectID:          projectID,
 			PubsubClient:       client,
 			DataresidencyStore: drs,
+			EncryptionStore:    es,
 		},
 	}
 	impl := channelreconciler.NewImpl(ctx, r)
@@ -117,7 +121,8 @@ func newController(
 }
 
 // filterChannelsForBrokerCell creates a filter that is intended to be used on the BrokerCell
-//  informer. It will enqueue all Channels associated with the changed BrokerCell.
+//
+//	informer. It will enqueue all Channels associated with the changed BrokerCell.
 func filterChannelsForBrokerCell(
 	logger *zap.Logger, channelInformer channellister.ChannelLister, enqueue func(interface{})) func(obj interface{}) {
 	return func(obj interface{}) {
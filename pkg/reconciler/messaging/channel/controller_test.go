@@ -42,7 +42,7 @@ import (
 func TestNew(t *testing.T) {
 	ctx, _ := SetupFakeContext(t)
 	cmw := configmap.NewStaticWatcher()
-	c := newController(ctx, cmw, reconcilertesting.NewDataresidencyTestStore(t, nil))
+	c := newController(ctx, cmw, reconcilertesting.NewDataresidencyTestStore(t, nil), reconcilertesting.NewEncryptionTestStore(t, nil))
 
 	if c == nil {
 		t.Fatal("Expected newControllerWithIAMPolicyManager to return a non-nil value")
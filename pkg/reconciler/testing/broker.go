@@ -152,12 +152,66 @@ func WithBrokerTopicUnknown(reason, msg string) BrokerOption {
 	}
 }
 
+func WithBrokerTopicFailed(reason, msg string) BrokerOption {
+	return func(b *brokerv1.Broker) {
+		b.Status.MarkTopicFailed(reason, msg)
+	}
+}
+
 func WithBrokerSubscriptionUnknown(reason, msg string) BrokerOption {
 	return func(b *brokerv1.Broker) {
 		b.Status.MarkSubscriptionUnknown(reason, msg)
 	}
 }
 
+func WithBrokerSubscriptionFailed(reason, msg string) BrokerOption {
+	return func(b *brokerv1.Broker) {
+		b.Status.MarkSubscriptionFailed(reason, msg)
+	}
+}
+
+// WithBrokerTopEventTypes lists the top event types in the Broker's status.
+func WithBrokerTopEventTypes(types ...string) BrokerOption {
+	return func(b *brokerv1.Broker) {
+		b.Status.SetTopEventTypes(types)
+	}
+}
+
+// WithBrokerArchive sets the archive in the Broker's status.
+func WithBrokerArchive(bucket, prefix string) BrokerOption {
+	return func(b *brokerv1.Broker) {
+		b.Status.SetArchive(bucket, prefix)
+	}
+}
+
+// WithBrokerExternalAddress sets the external address in the Broker's status.
+func WithBrokerExternalAddress(url *apis.URL) BrokerOption {
+	return func(b *brokerv1.Broker) {
+		b.Status.SetExternalAddress(url)
+	}
+}
+
+// WithBrokerFederationSubscriptions lists the federation subscriptions in the Broker's status.
+func WithBrokerFederationSubscriptions(subscriptions ...string) BrokerOption {
+	return func(b *brokerv1.Broker) {
+		b.Status.SetFederationSubscriptions(subscriptions)
+	}
+}
+
+// WithBrokerFederatedTopics lists the federated topics in the Broker's status.
+func WithBrokerFederatedTopics(topics ...brokerv1.FederatedTopic) BrokerOption {
+	return func(b *brokerv1.Broker) {
+		b.Status.SetFederatedTopics(topics)
+	}
+}
+
+// WithBrokerFederationMembers lists the federation members in the Broker's status.
+func WithBrokerFederationMembers(members ...string) BrokerOption {
+	return func(b *brokerv1.Broker) {
+		b.Status.SetFederationMembers(members)
+	}
+}
+
 func WithBrokerClass(bc string) BrokerOption {
 	return func(b *brokerv1.Broker) {
 		annotations := b.GetAnnotations()
@@ -169,6 +223,18 @@ func WithBrokerClass(bc string) BrokerOption {
 	}
 }
 
+// WithBrokerAnnotation sets an annotation on the Broker.
+func WithBrokerAnnotation(key, value string) BrokerOption {
+	return func(b *brokerv1.Broker) {
+		annotations := b.GetAnnotations()
+		if annotations == nil {
+			annotations = make(map[string]string, 1)
+		}
+		annotations[key] = value
+		b.SetAnnotations(annotations)
+	}
+}
+
 func WithBrokerSetDefaults(b *brokerv1.Broker) {
 	b.SetDefaults(context.Background())
 }
@@ -82,6 +82,12 @@ func WithIngressTemplate(address string) BrokerCellOption {
 	}
 }
 
+func WithExternalIngressTemplate(address string) BrokerCellOption {
+	return func(bc *intv1alpha1.BrokerCell) {
+		bc.Status.SetExternalIngressTemplate(address)
+	}
+}
+
 func WithBrokerCellReady(bc *intv1alpha1.BrokerCell) {
 	bc.Status = *intv1alpha1.TestHelper.ReadyBrokerCellStatus()
 }
@@ -152,6 +158,18 @@ func WithTargetsCofigFailed(reason, msg string) BrokerCellOption {
 	}
 }
 
+func WithBrokerCellTenants(tenants intv1alpha1.TenantsStatus) BrokerCellOption {
+	return func(bc *intv1alpha1.BrokerCell) {
+		bc.Status.SetTenants(tenants)
+	}
+}
+
+func WithTargetsConfigSize(size int64) BrokerCellOption {
+	return func(bc *intv1alpha1.BrokerCell) {
+		bc.Status.SetTargetsConfigSize(size)
+	}
+}
+
 func WithBrokerCellSetDefaults(bc *intv1alpha1.BrokerCell) {
 	bc.SetDefaults(context.Background())
 }
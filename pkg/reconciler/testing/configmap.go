@@ -22,6 +22,7 @@ import (
 
 	"github.com/google/knative-gcp/pkg/apis/configs/brokerdelivery"
 	"github.com/google/knative-gcp/pkg/apis/configs/dataresidency"
+	"github.com/google/knative-gcp/pkg/apis/configs/encryption"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	eventingduckv1 "knative.dev/eventing/pkg/apis/duck/v1"
@@ -101,6 +102,20 @@ func NewDataresidencyConfigMapFromRegions(regions []string) *corev1.ConfigMap {
 	}
 }
 
+// NewEncryptionConfigMapFromKMSKeyName creates a new encryption configuration map
+// from the cluster default Cloud KMS key.
+func NewEncryptionConfigMapFromKMSKeyName(kmsKeyName string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      encryption.ConfigMapName(),
+			Namespace: system.Namespace(),
+		},
+		Data: map[string]string{
+			"default-encryption-config": fmt.Sprintf("\n  clusterDefaults:\n    kmsKeyName: %q", kmsKeyName),
+		},
+	}
+}
+
 // NewBrokerDeliveryConfigMapFromDeliverySpec creates a new cluster defaulted
 // broker delivery configuration map from a given delivery spec.
 func NewBrokerDeliveryConfigMapFromDeliverySpec(spec *eventingduckv1.DeliverySpec) *corev1.ConfigMap {
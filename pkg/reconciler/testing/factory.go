@@ -32,6 +32,7 @@ import (
 	logtesting "knative.dev/pkg/logging/testing"
 
 	fakerunclient "github.com/google/knative-gcp/pkg/client/injection/client/fake"
+	fakeeventingclient "github.com/google/knative-gcp/pkg/client/injection/eventing/client/fake"
 	fakekubeclient "knative.dev/pkg/client/injection/kube/client/fake"
 	fakedynamicclient "knative.dev/pkg/injection/clients/dynamicclient/fake"
 	fakeservingclient "knative.dev/serving/pkg/client/injection/client/fake"
@@ -60,6 +61,7 @@ func MakeFactory(ctor Ctor) Factory {
 		ctx, kubeClient := fakekubeclient.With(ctx, ls.GetKubeObjects()...)
 		ctx, client := fakerunclient.With(ctx, ls.GetEventsObjects()...)
 		ctx, servingclient := fakeservingclient.With(ctx, ls.GetServingObjects()...)
+		ctx, eventingclient := fakeeventingclient.With(ctx, ls.GetEventingObjects()...)
 
 		dynamicScheme := runtime.NewScheme()
 		for _, addTo := range clientSetSchemes {
@@ -94,6 +96,7 @@ func MakeFactory(ctor Ctor) Factory {
 			client.PrependReactor("*", "*", reactor)
 			dynamicClient.PrependReactor("*", "*", reactor)
 			servingclient.PrependReactor("*", "*", reactor)
+			eventingclient.PrependReactor("*", "*", reactor)
 		}
 
 		// Validate all Create operations through the serving client.
@@ -104,7 +107,7 @@ func MakeFactory(ctor Ctor) Factory {
 			return ValidateUpdates(ctx, action)
 		})
 
-		actionRecorderList := ActionRecorderList{dynamicClient, client, kubeClient, servingclient}
+		actionRecorderList := ActionRecorderList{dynamicClient, client, kubeClient, servingclient, eventingclient}
 		eventList := EventList{Recorder: eventRecorder}
 
 		return c, actionRecorderList, eventList
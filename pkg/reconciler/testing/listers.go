@@ -21,6 +21,7 @@ import (
 	hpav2beta2 "k8s.io/api/autoscaling/v2beta2"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -29,9 +30,12 @@ import (
 	hpav2beta2listers "k8s.io/client-go/listers/autoscaling/v2beta2"
 	batchv1listers "k8s.io/client-go/listers/batch/v1"
 	corev1listers "k8s.io/client-go/listers/core/v1"
+	policyv1beta1listers "k8s.io/client-go/listers/policy/v1beta1"
 	rbacv1listers "k8s.io/client-go/listers/rbac/v1"
 	"k8s.io/client-go/tools/cache"
 
+	eventingv1beta1 "knative.dev/eventing/pkg/apis/eventing/v1beta1"
+	eventingv1beta1listers "knative.dev/eventing/pkg/client/listers/eventing/v1beta1"
 	"knative.dev/pkg/reconciler/testing"
 	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
 	servingv1listers "knative.dev/serving/pkg/client/listers/serving/v1"
@@ -63,6 +67,7 @@ var clientSetSchemes = []func(*runtime.Scheme) error{
 	fakekubeclientset.AddToScheme,
 	fakeeventsclientset.AddToScheme,
 	fakeservingclientset.AddToScheme,
+	eventingv1beta1.AddToScheme,
 	sinkAddToScheme,
 }
 
@@ -107,6 +112,7 @@ func (l *Listers) GetAllObjects() []runtime.Object {
 	all := l.GetSinkObjects()
 	all = append(all, l.GetEventsObjects()...)
 	all = append(all, l.GetKubeObjects()...)
+	all = append(all, l.GetEventingObjects()...)
 	return all
 }
 
@@ -114,6 +120,10 @@ func (l *Listers) GetServingObjects() []runtime.Object {
 	return l.sorter.ObjectsForSchemeFunc(fakeservingclientset.AddToScheme)
 }
 
+func (l *Listers) GetEventingObjects() []runtime.Object {
+	return l.sorter.ObjectsForSchemeFunc(eventingv1beta1.AddToScheme)
+}
+
 func (l *Listers) GetPullSubscriptionLister() inteventslisters.PullSubscriptionLister {
 	return inteventslisters.NewPullSubscriptionLister(l.indexerFor(&inteventsv1.PullSubscription{}))
 }
@@ -190,6 +200,10 @@ func (l *Listers) GetEndpointsLister() corev1listers.EndpointsLister {
 	return corev1listers.NewEndpointsLister(l.indexerFor(&corev1.Endpoints{}))
 }
 
+func (l *Listers) GetEventTypeLister() eventingv1beta1listers.EventTypeLister {
+	return eventingv1beta1listers.NewEventTypeLister(l.indexerFor(&eventingv1beta1.EventType{}))
+}
+
 func (l *Listers) GetConfigMapLister() corev1listers.ConfigMapLister {
 	return corev1listers.NewConfigMapLister(l.indexerFor(&corev1.ConfigMap{}))
 }
@@ -209,3 +223,7 @@ func (l *Listers) GetBrokerCellLister() intlisters.BrokerCellLister {
 func (l *Listers) GetHPALister() hpav2beta2listers.HorizontalPodAutoscalerLister {
 	return hpav2beta2listers.NewHorizontalPodAutoscalerLister(l.indexerFor(&hpav2beta2.HorizontalPodAutoscaler{}))
 }
+
+func (l *Listers) GetPodDisruptionBudgetLister() policyv1beta1listers.PodDisruptionBudgetLister {
+	return policyv1beta1listers.NewPodDisruptionBudgetLister(l.indexerFor(&policyv1beta1.PodDisruptionBudget{}))
+}
@@ -49,3 +49,9 @@ func WithNamespaceLabeled(labels map[string]string) NamespaceOption {
 		n.Labels = labels
 	}
 }
+
+func WithNamespaceAnnotations(annotations map[string]string) NamespaceOption {
+	return func(n *corev1.Namespace) {
+		n.Annotations = annotations
+	}
+}
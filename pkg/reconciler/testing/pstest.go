@@ -43,6 +43,16 @@ func Topic(id string) PubsubAction {
 	}
 }
 
+func TopicWithConfig(id string, cfg *pubsub.TopicConfig) PubsubAction {
+	return func(ctx context.Context, t *testing.T, c *pubsub.Client) {
+		_, err := c.CreateTopicWithConfig(ctx, id, cfg)
+		if err != nil {
+			t.Fatalf("Error creating topic %q: %v", id, err)
+		}
+		t.Logf("Created topic %q", id)
+	}
+}
+
 func SubscriptionWithTopic(id string, tid string) PubsubAction {
 	return func(ctx context.Context, t *testing.T, c *pubsub.Client) {
 		_, err := c.CreateSubscription(ctx, id, pubsub.SubscriptionConfig{Topic: c.Topic(tid)})
@@ -183,6 +193,34 @@ func SubscriptionHasDeadLetterPolicy(id string, wantPolicy *pubsub.DeadLetterPol
 	}
 }
 
+func SubscriptionHasFilter(id string, wantFilter string) func(*testing.T, *rtesting.TableRow) {
+	return func(t *testing.T, r *rtesting.TableRow) {
+		c := getPubsubClient(r)
+		sub := c.Subscription(id)
+		cfg, err := sub.Config(context.Background())
+		if err != nil {
+			t.Errorf("Error getting pubsub config: %v", err)
+		}
+		if cfg.Filter != wantFilter {
+			t.Errorf("Pubsub config filter got %q, want %q", cfg.Filter, wantFilter)
+		}
+	}
+}
+
+func SubscriptionHasLabels(id string, wantLabels map[string]string) func(*testing.T, *rtesting.TableRow) {
+	return func(t *testing.T, r *rtesting.TableRow) {
+		c := getPubsubClient(r)
+		sub := c.Subscription(id)
+		cfg, err := sub.Config(context.Background())
+		if err != nil {
+			t.Errorf("Error getting pubsub config: %v", err)
+		}
+		if diff := cmp.Diff(wantLabels, cfg.Labels); diff != "" {
+			t.Errorf("Pubsub config labels (-want,+got): %v", diff)
+		}
+	}
+}
+
 func OnlySubscriptions(ids ...string) func(*testing.T, *rtesting.TableRow) {
 	return func(t *testing.T, r *rtesting.TableRow) {
 		c := getPubsubClient(r)
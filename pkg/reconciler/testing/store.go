@@ -23,6 +23,7 @@ import (
 	logtesting "knative.dev/pkg/logging/testing"
 
 	"github.com/google/knative-gcp/pkg/apis/configs/dataresidency"
+	"github.com/google/knative-gcp/pkg/apis/configs/encryption"
 	"github.com/google/knative-gcp/pkg/apis/configs/gcpauth"
 )
 
@@ -41,3 +42,11 @@ func NewDataresidencyTestStore(t *testing.T, config *corev1.ConfigMap) *dataresi
 	}
 	return dataresidencyTestStore
 }
+
+func NewEncryptionTestStore(t *testing.T, config *corev1.ConfigMap) *encryption.Store {
+	encryptionTestStore := encryption.NewStore(logtesting.TestLogger(t))
+	if config != nil {
+		encryptionTestStore.OnConfigChanged(config)
+	}
+	return encryptionTestStore
+}
@@ -141,6 +141,15 @@ func WithInjectionAnnotation(injectionAnnotation string) TriggerOption {
 	}
 }
 
+func WithTriggerAnnotation(key, value string) TriggerOption {
+	return func(t *brokerv1.Trigger) {
+		if t.Annotations == nil {
+			t.Annotations = make(map[string]string)
+		}
+		t.Annotations[key] = value
+	}
+}
+
 func WithDependencyAnnotation(dependencyAnnotation string) TriggerOption {
 	return func(t *brokerv1.Trigger) {
 		if t.Annotations == nil {
@@ -202,6 +211,12 @@ func WithTriggerSubscriptionUnknown(reason, msg string) TriggerOption {
 	}
 }
 
+func WithTriggerRetrySubscriptionDraining(subscription string, deadline time.Time) TriggerOption {
+	return func(t *brokerv1.Trigger) {
+		t.Status.MarkRetrySubscriptionDraining(subscription, deadline)
+	}
+}
+
 func WithTriggerDeletionTimestamp(t *brokerv1.Trigger) {
 	deleteTime := metav1.NewTime(time.Unix(1e9, 0))
 	t.ObjectMeta.SetDeletionTimestamp(&deleteTime)
@@ -23,6 +23,7 @@ import (
 
 	"cloud.google.com/go/pubsub"
 	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/client-go/tools/cache"
 
@@ -31,6 +32,7 @@ import (
 	"knative.dev/eventing/pkg/duck"
 	"knative.dev/pkg/client/injection/ducks/duck/v1/addressable"
 	"knative.dev/pkg/client/injection/ducks/duck/v1/source"
+	namespaceinformer "knative.dev/pkg/client/injection/kube/informers/core/v1/namespace"
 	"knative.dev/pkg/configmap"
 	"knative.dev/pkg/controller"
 	pkgcontroller "knative.dev/pkg/controller"
@@ -39,6 +41,7 @@ import (
 
 	brokerv1 "github.com/google/knative-gcp/pkg/apis/broker/v1"
 	"github.com/google/knative-gcp/pkg/apis/configs/dataresidency"
+	"github.com/google/knative-gcp/pkg/apis/configs/encryption"
 	brokerinformer "github.com/google/knative-gcp/pkg/client/injection/informers/broker/v1/broker"
 	triggerinformer "github.com/google/knative-gcp/pkg/client/injection/informers/broker/v1/trigger"
 	triggerreconciler "github.com/google/knative-gcp/pkg/client/injection/reconciler/broker/v1/trigger"
@@ -58,13 +61,13 @@ const (
 type Constructor injection.ControllerConstructor
 
 // NewConstructor creates a constructor to make a Trigger controller.
-func NewConstructor(dataresidencyss *dataresidency.StoreSingleton) Constructor {
+func NewConstructor(dataresidencyss *dataresidency.StoreSingleton, encryptionss *encryption.StoreSingleton) Constructor {
 	return func(ctx context.Context, cmw configmap.Watcher) *controller.Impl {
-		return newController(ctx, cmw, dataresidencyss.Store(ctx, cmw))
+		return newController(ctx, cmw, dataresidencyss.Store(ctx, cmw), encryptionss.Store(ctx, cmw))
 	}
 }
 
-func newController(ctx context.Context, cmw configmap.Watcher, drs *dataresidency.Store) *controller.Impl {
+func newController(ctx context.Context, cmw configmap.Watcher, drs *dataresidency.Store, es *encryption.Store) *controller.Impl {
 	triggerInformer := triggerinformer.Get(ctx)
 
 	var client *pubsub.Client
@@ -90,12 +93,14 @@ func newController(ctx context.Context, cmw configmap.Watcher, drs *dataresidenc
 		}()
 	}
 	r := &Reconciler{
-		Base:         reconciler.NewBase(ctx, controllerAgentName, cmw),
-		brokerLister: brokerinformer.Get(ctx).Lister(),
+		Base:            reconciler.NewBase(ctx, controllerAgentName, cmw),
+		brokerLister:    brokerinformer.Get(ctx).Lister(),
+		namespaceLister: namespaceinformer.Get(ctx).Lister(),
 		targetReconciler: &celltenant.TargetReconciler{
 			ProjectID:          projectID,
 			PubsubClient:       client,
 			DataresidencyStore: drs,
+			EncryptionStore:    es,
 		},
 	}
 
@@ -128,6 +133,20 @@ func newController(ctx context.Context, cmw configmap.Watcher, drs *dataresidenc
 		},
 	)
 
+	// Watch namespaces, which opt into Pub/Sub topic and BigQuery table subscribers.
+	namespaceinformer.Get(ctx).Informer().AddEventHandler(controller.HandleAll(func(obj interface{}) {
+		if ns, ok := obj.(*corev1.Namespace); ok {
+			triggers, err := triggerinformer.Get(ctx).Lister().Triggers(ns.Name).List(labels.Everything())
+			if err != nil {
+				r.Logger.Warn("Failed to list triggers", zap.String("Namespace", ns.Name))
+				return
+			}
+			for _, trigger := range triggers {
+				impl.Enqueue(trigger)
+			}
+		}
+	}))
+
 	return impl
 }
 
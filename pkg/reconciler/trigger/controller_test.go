@@ -29,6 +29,7 @@ import (
 	tracingconfig "knative.dev/pkg/tracing/config"
 
 	"github.com/google/knative-gcp/pkg/apis/configs/dataresidency"
+	"github.com/google/knative-gcp/pkg/apis/configs/encryption"
 	. "github.com/google/knative-gcp/pkg/reconciler/testing"
 
 	// Fake injection informers
@@ -39,6 +40,7 @@ import (
 	_ "knative.dev/pkg/client/injection/kube/informers/apps/v1/deployment/fake"
 	_ "knative.dev/pkg/client/injection/kube/informers/core/v1/configmap/fake"
 	_ "knative.dev/pkg/client/injection/kube/informers/core/v1/endpoints/fake"
+	_ "knative.dev/pkg/client/injection/kube/informers/core/v1/namespace/fake"
 	_ "knative.dev/pkg/client/injection/kube/informers/core/v1/pod/fake"
 	_ "knative.dev/pkg/injection/clients/dynamicclient/fake"
 )
@@ -46,7 +48,7 @@ import (
 func TestNew(t *testing.T) {
 	ctx, _ := SetupFakeContext(t)
 
-	c := NewConstructor(&dataresidency.StoreSingleton{})(ctx, configmap.NewStaticWatcher(
+	c := NewConstructor(&dataresidency.StoreSingleton{}, &encryption.StoreSingleton{})(ctx, configmap.NewStaticWatcher(
 		&corev1.ConfigMap{
 			ObjectMeta: metav1.ObjectMeta{
 				Name:      logging.ConfigMapName(),
@@ -69,6 +71,7 @@ func TestNew(t *testing.T) {
 			Data: map[string]string{},
 		},
 		NewDataresidencyConfigMapFromRegions([]string{}),
+		NewEncryptionConfigMapFromKMSKeyName(""),
 	))
 
 	if c == nil {
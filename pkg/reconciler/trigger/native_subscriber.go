@@ -0,0 +1,58 @@
+/*
+Copyright 2021 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package trigger
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+
+	brokerv1 "github.com/google/knative-gcp/pkg/apis/broker/v1"
+	"github.com/google/knative-gcp/pkg/logging"
+)
+
+// reconcileNativeSubscriber checks that the namespace of the Trigger opted into its subscriber, if
+// it is a Pub/Sub topic or a BigQuery table. The subscriber is not resolved otherwise, so that the
+// data plane doesn't publish to the topic or insert into the table. Once opted in, the data plane
+// is granted the permission to publish to the topic. It is not granted the permission to insert
+// into the table, the owner of the table grants it to the Google service account of the data
+// plane.
+func (r *Reconciler) reconcileNativeSubscriber(ctx context.Context, t *brokerv1.Trigger) error {
+	var labelKey, kind string
+	if _, _, ok := brokerv1.PubsubSubscriberTopic(t.Status.SubscriberURI); ok {
+		labelKey, kind = brokerv1.PubsubSubscribersLabelKey, "Pub/Sub topic"
+	} else if _, _, _, ok := brokerv1.BigQuerySubscriberTable(t.Status.SubscriberURI); ok {
+		labelKey, kind = brokerv1.BigQuerySubscribersLabelKey, "BigQuery table"
+	} else {
+		return nil
+	}
+	ns, err := r.namespaceLister.Get(t.Namespace)
+	if err != nil {
+		logging.FromContext(ctx).Error("Failed to get the namespace of the Trigger", zap.String("namespace", t.Namespace), zap.Error(err))
+		t.Status.MarkSubscriberResolvedFailed("NamespaceGetFailed", "Failed to get namespace %q: %v", t.Namespace, err)
+		t.Status.SubscriberURI = nil
+		return err
+	}
+	if ns.Labels[labelKey] != "true" {
+		logging.FromContext(ctx).Debug("Namespace did not opt into the subscriber of the Trigger",
+			zap.String("namespace", t.Namespace), zap.String("subscriber", t.Status.SubscriberURI.String()))
+		t.Status.MarkSubscriberResolvedFailed("SubscriberNotAllowed", "Namespace %q is not labeled with %s=true, %s subscribers are not allowed", t.Namespace, labelKey, kind)
+		t.Status.SubscriberURI = nil
+		return nil
+	}
+	return r.reconcilePubsubSubscriber(ctx, t)
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2021 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package trigger
+
+import (
+	"context"
+	"errors"
+
+	"cloud.google.com/go/iam"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	"knative.dev/pkg/system"
+
+	brokerv1 "github.com/google/knative-gcp/pkg/apis/broker/v1"
+	giam "github.com/google/knative-gcp/pkg/gclient/iam"
+	"github.com/google/knative-gcp/pkg/logging"
+	"github.com/google/knative-gcp/pkg/reconciler/identity"
+	"github.com/google/knative-gcp/pkg/utils/authcheck"
+)
+
+// publisherRole is the role granted to the data plane on the Pub/Sub topics that are Trigger
+// subscribers.
+const publisherRole iam.RoleName = "roles/pubsub.publisher"
+
+// reconcilePubsubSubscriber grants the data plane the permission to publish to the subscriber of
+// the Trigger, if it is a Pub/Sub topic of a namespace that opted into them. The permission is not
+// revoked when the Trigger is deleted, as other Triggers may publish to the same topic.
+func (r *Reconciler) reconcilePubsubSubscriber(ctx context.Context, t *brokerv1.Trigger) error {
+	project, topic, ok := brokerv1.PubsubSubscriberTopic(t.Status.SubscriberURI)
+	if !ok {
+		return nil
+	}
+	member, err := r.dataPlaneMember(ctx)
+	if err != nil {
+		t.Status.MarkSubscriberResolvedFailed("PublisherPermissionFailed", "Failed to get the identity of the data plane: %v", err)
+		return err
+	}
+	if member == "" {
+		// The data plane doesn't use Workload Identity, the permission has to be granted to its
+		// credentials by the user.
+		logging.FromContext(ctx).Debug("Data plane identity unknown, not granting the permission to publish to the subscriber topic",
+			zap.String("project", project), zap.String("topic", topic))
+		return nil
+	}
+
+	h, err := r.topicIAMHandle(project, topic)
+	if err != nil {
+		t.Status.MarkSubscriberResolvedFailed("PublisherPermissionFailed", "Failed to get the IAM policy of topic %q of project %q: %v", topic, project, err)
+		return err
+	}
+	policy, err := h.Policy(ctx)
+	if err != nil {
+		t.Status.MarkSubscriberResolvedFailed("PublisherPermissionFailed", "Failed to get the IAM policy of topic %q of project %q: %v", topic, project, err)
+		return err
+	}
+	if policy.HasRole(member, publisherRole) {
+		return nil
+	}
+	policy.Add(member, publisherRole)
+	if err := h.SetPolicy(ctx, policy); err != nil {
+		t.Status.MarkSubscriberResolvedFailed("PublisherPermissionFailed", "Failed to grant %s on topic %q of project %q: %v", publisherRole, topic, project, err)
+		return err
+	}
+	r.Recorder.Eventf(t, corev1.EventTypeNormal, "PublisherPermissionGranted", "Granted %s on topic %q of project %q to %s", publisherRole, topic, project, member)
+	return nil
+}
+
+// dataPlaneMember returns the IAM member of the Google service account of the data plane, or an
+// empty string if the data plane doesn't use Workload Identity.
+func (r *Reconciler) dataPlaneMember(ctx context.Context) (string, error) {
+	return identity.WorkloadIdentityMember(ctx, r.KubeClientSet, system.Namespace(), authcheck.BrokerServiceAccountName)
+}
+
+// topicIAMHandle returns the IAM handle of the Pub/Sub topic of the given project.
+func (r *Reconciler) topicIAMHandle(project, topic string) (giam.Handle, error) {
+	if r.topicIAM != nil {
+		return r.topicIAM(project, topic), nil
+	}
+	client := r.targetReconciler.PubsubClient
+	if client == nil {
+		return nil, errors.New("Pub/Sub client is not available")
+	}
+	return giam.NewIamHandle(client.TopicInProject(topic, project).IAM()), nil
+}
@@ -19,12 +19,14 @@ package trigger
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/google/knative-gcp/pkg/reconciler/celltenant"
 
 	"go.uber.org/zap"
 	corev1 "k8s.io/api/core/v1"
 	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	corev1listers "k8s.io/client-go/listers/core/v1"
 
 	"github.com/google/knative-gcp/pkg/logging"
 	eventingv1 "knative.dev/eventing/pkg/apis/eventing/v1"
@@ -34,9 +36,12 @@ import (
 	"knative.dev/pkg/resolver"
 
 	brokerv1 "github.com/google/knative-gcp/pkg/apis/broker/v1"
+	"github.com/google/knative-gcp/pkg/broker/eventutil"
 	triggerreconciler "github.com/google/knative-gcp/pkg/client/injection/reconciler/broker/v1/trigger"
 	brokerlisters "github.com/google/knative-gcp/pkg/client/listers/broker/v1"
+	giam "github.com/google/knative-gcp/pkg/gclient/iam"
 	"github.com/google/knative-gcp/pkg/reconciler"
+	brokerresources "github.com/google/knative-gcp/pkg/reconciler/broker/resources"
 	reconcilerutils "github.com/google/knative-gcp/pkg/reconciler/utils"
 )
 
@@ -44,6 +49,11 @@ const (
 	// Name of the corev1.Events emitted from the Trigger reconciliation process.
 	triggerReconciled = "TriggerReconciled"
 	triggerFinalized  = "TriggerFinalized"
+
+	// retryDrainDuration is how long the retry subscription of the previous retry topic mode is
+	// drained after the mode changes. It is the default message retention duration of Pub/Sub
+	// subscriptions, after which no retry is left.
+	retryDrainDuration = 7 * 24 * time.Hour
 )
 
 // Reconciler implements controller.Reconciler for Trigger resources.
@@ -51,7 +61,8 @@ type Reconciler struct {
 	*reconciler.Base
 	targetReconciler *celltenant.TargetReconciler
 
-	brokerLister brokerlisters.BrokerLister
+	brokerLister    brokerlisters.BrokerLister
+	namespaceLister corev1listers.NamespaceLister
 
 	// Dynamic tracker to track sources. It tracks the dependency between Triggers and Sources.
 	sourceTracker duck.ListableTracker
@@ -59,6 +70,10 @@ type Reconciler struct {
 	// Dynamic tracker to track AddressableTypes. It tracks Trigger subscribers.
 	addressableTracker duck.ListableTracker
 	uriResolver        *resolver.URIResolver
+
+	// topicIAM returns the IAM handle of the Pub/Sub topic subscribers. If nil, the handle of the
+	// Pub/Sub client of the targetReconciler is used. Changed in testing only.
+	topicIAM func(project, topic string) giam.Handle
 }
 
 // Check that TriggerReconciler implements Interface
@@ -132,8 +147,11 @@ func (r *Reconciler) reconcile(ctx context.Context, t *brokerv1.Trigger, b *brok
 		b.SetDefaults(ctx)
 	}
 
-	ct := celltenant.TargetFromTrigger(t, b.Spec.Delivery)
-	if err := r.targetReconciler.ReconcileRetryTopicAndSubscription(ctx, r.Recorder, ct); err != nil {
+	if err := r.reconcileRetryQueue(ctx, t, b); err != nil {
+		return err
+	}
+
+	if err := r.reconcileNativeSubscriber(ctx, t); err != nil {
 		return err
 	}
 
@@ -156,13 +174,71 @@ func (r *Reconciler) FinalizeKind(ctx context.Context, t *brokerv1.Trigger) pkgr
 	if !hasGCPBrokerFinalizer(t) {
 		return nil
 	}
-	ct := celltenant.TargetFromTrigger(t, nil)
+	ct := celltenant.TargetFromTrigger(t, nil, nil)
 	if err := r.targetReconciler.DeleteRetryTopicAndSubscription(ctx, r.Recorder, ct); err != nil {
 		return err
 	}
+	// The Broker may be gone, so the subscription to its retry topic is deleted by name.
+	sub := brokerresources.GenerateConsolidatedRetrySubscriptionName(t)
+	if err := r.targetReconciler.DeleteRetrySubscription(ctx, r.Recorder, ct, sub, false); err != nil {
+		return err
+	}
 	return pkgreconciler.NewEvent(corev1.EventTypeNormal, triggerFinalized, "Trigger finalized: \"%s/%s\"", t.Namespace, t.Name)
 }
 
+// reconcileRetryQueue reconciles the retry subscription of the Trigger according to the retry
+// topic mode of the Broker. When the mode changes, the retry subscription of the previous mode is
+// drained until the retries it holds expire, then deleted.
+func (r *Reconciler) reconcileRetryQueue(ctx context.Context, t *brokerv1.Trigger, b *brokerv1.Broker) error {
+	var current, previous celltenant.Target
+	// The retry topic of the previous mode is deleted along with the drained subscription, unless
+	// it is the retry topic of the Broker, which the Broker deletes.
+	deletePreviousTopic := false
+	if b.ConsolidatedRetryTopic() {
+		current = celltenant.ConsolidatedTargetFromTrigger(t, b, b.Spec.Delivery)
+		if err := r.targetReconciler.ReconcileFilteredRetrySubscription(ctx, r.Recorder, current, eventutil.RetryTargetFilter(string(t.UID))); err != nil {
+			return err
+		}
+		previous = celltenant.TargetFromTrigger(t, nil, nil)
+		deletePreviousTopic = true
+	} else {
+		current = celltenant.TargetFromTrigger(t, b, b.Spec.Delivery)
+		if err := r.targetReconciler.ReconcileRetryTopicAndSubscription(ctx, r.Recorder, current); err != nil {
+			return err
+		}
+		// Without the annotation, the Broker has never consolidated the retries, so there is no
+		// subscription to drain.
+		if mode, _ := b.RetryTopicMode(); mode != brokerv1.RetryTopicModePerTrigger {
+			return nil
+		}
+		previous = celltenant.ConsolidatedTargetFromTrigger(t, b, nil)
+	}
+
+	if sub, deadline, ok := t.Status.DrainingRetrySubscription(); ok {
+		switch {
+		case sub == current.GetSubscriptionName():
+			// The mode was switched back before the subscription was drained.
+			t.Status.MarkRetrySubscriptionDrained()
+		case time.Now().After(deadline):
+			if err := r.targetReconciler.DeleteRetrySubscription(ctx, r.Recorder, previous, sub, deletePreviousTopic); err != nil {
+				return err
+			}
+			t.Status.MarkRetrySubscriptionDrained()
+		}
+		return nil
+	}
+
+	exists, err := r.targetReconciler.RetrySubscriptionExists(ctx, previous)
+	if err != nil {
+		return err
+	}
+	if exists {
+		logging.FromContext(ctx).Info("Draining previous retry subscription", zap.String("subscription", previous.GetSubscriptionName()))
+		t.Status.MarkRetrySubscriptionDraining(previous.GetSubscriptionName(), time.Now().Add(retryDrainDuration))
+	}
+	return nil
+}
+
 func (r *Reconciler) resolveSubscriber(ctx context.Context, t *brokerv1.Trigger, b *brokerv1.Broker) error {
 	if t.Spec.Subscriber.Ref != nil && t.Spec.Subscriber.Ref.Namespace == "" {
 		// To call URIFromDestination(dest apisv1alpha1.Destination, parent interface{}), dest.Ref must have a Namespace
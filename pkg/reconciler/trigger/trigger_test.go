@@ -18,6 +18,7 @@ package trigger
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"testing"
 	"time"
@@ -43,13 +44,17 @@ import (
 	"knative.dev/pkg/controller"
 	. "knative.dev/pkg/reconciler/testing"
 	"knative.dev/pkg/resolver"
+	"knative.dev/pkg/system"
 
 	brokerv1 "github.com/google/knative-gcp/pkg/apis/broker/v1"
 	"github.com/google/knative-gcp/pkg/apis/configs/dataresidency"
 	"github.com/google/knative-gcp/pkg/client/injection/ducks/duck/v1alpha1/resource"
 	triggerreconciler "github.com/google/knative-gcp/pkg/client/injection/reconciler/broker/v1/trigger"
+	giam "github.com/google/knative-gcp/pkg/gclient/iam"
+	iamtesting "github.com/google/knative-gcp/pkg/gclient/iam/testing"
 	"github.com/google/knative-gcp/pkg/reconciler"
 	. "github.com/google/knative-gcp/pkg/reconciler/testing"
+	"github.com/google/knative-gcp/pkg/utils/authcheck"
 )
 
 const (
@@ -59,12 +64,17 @@ const (
 	testUID           = "abc123"
 	testProject       = "test-project-id"
 	testClusterRegion = "us-east1"
+	testClusterName   = "test-cluster"
 
-	subscriberURI     = "http://example.com/subscriber/"
-	subscriberKind    = "Service"
-	subscriberName    = "subscriber-name"
-	subscriberGroup   = "serving.knative.dev"
-	subscriberVersion = "v1"
+	subscriberURI = "http://example.com/subscriber/"
+
+	pubsubSubscriberURI   = "pubsub://other-project/events"
+	dataPlaneGSA          = "broker@test-project-id.iam.gserviceaccount.com"
+	bigQuerySubscriberURI = "bigquery://other-project/analytics/events"
+	subscriberKind        = "Service"
+	subscriberName        = "subscriber-name"
+	subscriberGroup       = "serving.knative.dev"
+	subscriberVersion     = "v1"
 )
 
 var (
@@ -75,17 +85,18 @@ var (
 
 	testKey = fmt.Sprintf("%s/%s", testNS, triggerName)
 
-	triggerFinalizerUpdatedEvent   = Eventf(corev1.EventTypeNormal, "FinalizerUpdate", `Updated "test-trigger" finalizers`)
-	triggerReconciledEvent         = Eventf(corev1.EventTypeNormal, "TriggerReconciled", `Trigger reconciled: "testnamespace/test-trigger"`)
-	triggerFinalizedEvent          = Eventf(corev1.EventTypeNormal, "TriggerFinalized", `Trigger finalized: "testnamespace/test-trigger"`)
-	topicCreatedEvent              = Eventf(corev1.EventTypeNormal, "TopicCreated", `Created PubSub topic "cre-tgr_testnamespace_test-trigger_abc123"`)
-	topicDeletedEvent              = Eventf(corev1.EventTypeNormal, "TopicDeleted", `Deleted PubSub topic "cre-tgr_testnamespace_test-trigger_abc123"`)
-	deadLetterTopicCreatedEvent    = Eventf(corev1.EventTypeNormal, "TopicCreated", `Created PubSub topic "test-dead-letter-topic-id"`)
-	subscriptionCreatedEvent       = Eventf(corev1.EventTypeNormal, "SubscriptionCreated", `Created PubSub subscription "cre-tgr_testnamespace_test-trigger_abc123"`)
-	subscriptionDeletedEvent       = Eventf(corev1.EventTypeNormal, "SubscriptionDeleted", `Deleted PubSub subscription "cre-tgr_testnamespace_test-trigger_abc123"`)
-	subscriptionConfigUpdatedEvent = Eventf(corev1.EventTypeNormal, "SubscriptionConfigUpdated", `Updated config for PubSub subscription "cre-tgr_testnamespace_test-trigger_abc123"`)
-	subscriberAPIVersion           = fmt.Sprintf("%s/%s", subscriberGroup, subscriberVersion)
-	subscriberGVK                  = metav1.GroupVersionKind{
+	triggerFinalizerUpdatedEvent    = Eventf(corev1.EventTypeNormal, "FinalizerUpdate", `Updated "test-trigger" finalizers`)
+	triggerReconciledEvent          = Eventf(corev1.EventTypeNormal, "TriggerReconciled", `Trigger reconciled: "testnamespace/test-trigger"`)
+	triggerFinalizedEvent           = Eventf(corev1.EventTypeNormal, "TriggerFinalized", `Trigger finalized: "testnamespace/test-trigger"`)
+	topicCreatedEvent               = Eventf(corev1.EventTypeNormal, "TopicCreated", `Created PubSub topic "cre-tgr_testnamespace_test-trigger_abc123"`)
+	topicDeletedEvent               = Eventf(corev1.EventTypeNormal, "TopicDeleted", `Deleted PubSub topic "cre-tgr_testnamespace_test-trigger_abc123"`)
+	deadLetterTopicCreatedEvent     = Eventf(corev1.EventTypeNormal, "TopicCreated", `Created PubSub topic "test-dead-letter-topic-id"`)
+	subscriptionCreatedEvent        = Eventf(corev1.EventTypeNormal, "SubscriptionCreated", `Created PubSub subscription "cre-tgr_testnamespace_test-trigger_abc123"`)
+	subscriptionDeletedEvent        = Eventf(corev1.EventTypeNormal, "SubscriptionDeleted", `Deleted PubSub subscription "cre-tgr_testnamespace_test-trigger_abc123"`)
+	publisherPermissionGrantedEvent = Eventf(corev1.EventTypeNormal, "PublisherPermissionGranted", `Granted roles/pubsub.publisher on topic "events" of project "other-project" to serviceAccount:broker@test-project-id.iam.gserviceaccount.com`)
+	subscriptionConfigUpdatedEvent  = Eventf(corev1.EventTypeNormal, "SubscriptionConfigUpdated", `Updated config for PubSub subscription "cre-tgr_testnamespace_test-trigger_abc123"`)
+	subscriberAPIVersion            = fmt.Sprintf("%s/%s", subscriberGroup, subscriberVersion)
+	subscriberGVK                   = metav1.GroupVersionKind{
 		Group:   subscriberGroup,
 		Version: subscriberVersion,
 		Kind:    subscriberKind,
@@ -334,6 +345,215 @@ func TestAllCasesTrigger(t *testing.T) {
 					}),
 			},
 		},
+		{
+			Name: "Pub/Sub topic subscriber, namespace opted in, data plane identity unknown",
+			Key:  testKey,
+			Objects: []runtime.Object{
+				NewBroker(brokerName, testNS,
+					WithBrokerClass(brokerv1.BrokerClass),
+					WithInitBrokerConditions,
+					WithBrokerReady("url"),
+					WithBrokerSetDefaults,
+				),
+				NewNamespace(testNS, WithNamespaceLabeled(map[string]string{brokerv1.PubsubSubscribersLabelKey: "true"})),
+				NewTrigger(triggerName, testNS, brokerName,
+					WithTriggerUID(testUID),
+					WithTriggerSubscriberURI(pubsubSubscriberURI),
+					WithTriggerSetDefaults),
+			},
+			WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+				Object: NewTrigger(triggerName, testNS, brokerName,
+					WithTriggerUID(testUID),
+					WithTriggerSubscriberURI(pubsubSubscriberURI),
+					WithTriggerBrokerReady,
+					WithTriggerSubscriptionReady,
+					WithTriggerTopicReady,
+					WithTriggerDependencyReady,
+					WithTriggerSubscriberResolvedSucceeded,
+					WithTriggerStatusSubscriberURI(pubsubSubscriberURI),
+					WithTriggerSetDefaults,
+				),
+			}},
+			WantEvents: []string{
+				triggerFinalizerUpdatedEvent,
+				topicCreatedEvent,
+				subscriptionCreatedEvent,
+				triggerReconciledEvent,
+			},
+			WantPatches: []clientgotesting.PatchActionImpl{
+				patchFinalizers(testNS, triggerName, finalizerName),
+			},
+		},
+		{
+			Name: "Pub/Sub topic subscriber, namespace opted in, publisher permission granted",
+			Key:  testKey,
+			Objects: []runtime.Object{
+				NewBroker(brokerName, testNS,
+					WithBrokerClass(brokerv1.BrokerClass),
+					WithInitBrokerConditions,
+					WithBrokerReady("url"),
+					WithBrokerSetDefaults,
+				),
+				NewNamespace(testNS, WithNamespaceLabeled(map[string]string{brokerv1.PubsubSubscribersLabelKey: "true"})),
+				NewServiceAccount(authcheck.BrokerServiceAccountName, system.Namespace(), WithServiceAccountAnnotation(dataPlaneGSA)),
+				NewTrigger(triggerName, testNS, brokerName,
+					WithTriggerUID(testUID),
+					WithTriggerSubscriberURI(pubsubSubscriberURI),
+					WithTriggerSetDefaults),
+			},
+			WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+				Object: NewTrigger(triggerName, testNS, brokerName,
+					WithTriggerUID(testUID),
+					WithTriggerSubscriberURI(pubsubSubscriberURI),
+					WithTriggerBrokerReady,
+					WithTriggerSubscriptionReady,
+					WithTriggerTopicReady,
+					WithTriggerDependencyReady,
+					WithTriggerSubscriberResolvedSucceeded,
+					WithTriggerStatusSubscriberURI(pubsubSubscriberURI),
+					WithTriggerSetDefaults,
+				),
+			}},
+			WantEvents: []string{
+				triggerFinalizerUpdatedEvent,
+				topicCreatedEvent,
+				subscriptionCreatedEvent,
+				publisherPermissionGrantedEvent,
+				triggerReconciledEvent,
+			},
+			WantPatches: []clientgotesting.PatchActionImpl{
+				patchFinalizers(testNS, triggerName, finalizerName),
+			},
+			OtherTestData: map[string]interface{}{
+				"topicIAM": iamtesting.NewTestHandle(iamtesting.TestHandleData{}),
+			},
+			PostConditions: []func(*testing.T, *TableRow){
+				topicHasPublisher("serviceAccount:"+dataPlaneGSA, true),
+			},
+		},
+		{
+			Name: "Pub/Sub topic subscriber, namespace opted in, granting publisher permission fails",
+			Key:  testKey,
+			Objects: []runtime.Object{
+				NewBroker(brokerName, testNS,
+					WithBrokerClass(brokerv1.BrokerClass),
+					WithInitBrokerConditions,
+					WithBrokerReady("url"),
+					WithBrokerSetDefaults,
+				),
+				NewNamespace(testNS, WithNamespaceLabeled(map[string]string{brokerv1.PubsubSubscribersLabelKey: "true"})),
+				NewServiceAccount(authcheck.BrokerServiceAccountName, system.Namespace(), WithServiceAccountAnnotation(dataPlaneGSA)),
+				NewTrigger(triggerName, testNS, brokerName,
+					WithTriggerUID(testUID),
+					WithTriggerSubscriberURI(pubsubSubscriberURI),
+					WithTriggerSetDefaults),
+			},
+			WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+				Object: NewTrigger(triggerName, testNS, brokerName,
+					WithTriggerUID(testUID),
+					WithTriggerSubscriberURI(pubsubSubscriberURI),
+					WithTriggerBrokerReady,
+					WithTriggerSubscriptionReady,
+					WithTriggerTopicReady,
+					WithTriggerDependencyUnknown("", ""),
+					WithTriggerSubscriberResolvedFailed("PublisherPermissionFailed", `Failed to grant roles/pubsub.publisher on topic "events" of project "other-project": permission denied`),
+					WithTriggerStatusSubscriberURI(pubsubSubscriberURI),
+					WithTriggerSetDefaults,
+				),
+			}},
+			WantEvents: []string{
+				triggerFinalizerUpdatedEvent,
+				topicCreatedEvent,
+				subscriptionCreatedEvent,
+				Eventf(corev1.EventTypeWarning, "InternalError", "permission denied"),
+			},
+			WantPatches: []clientgotesting.PatchActionImpl{
+				patchFinalizers(testNS, triggerName, finalizerName),
+			},
+			OtherTestData: map[string]interface{}{
+				"topicIAM": iamtesting.NewTestHandle(iamtesting.TestHandleData{SetPolicyErr: errors.New("permission denied")}),
+			},
+			PostConditions: []func(*testing.T, *TableRow){
+				topicHasPublisher("serviceAccount:"+dataPlaneGSA, false),
+			},
+			WantErr: true,
+		},
+		{
+			Name: "Pub/Sub topic subscriber, namespace not opted in",
+			Key:  testKey,
+			Objects: []runtime.Object{
+				NewBroker(brokerName, testNS,
+					WithBrokerClass(brokerv1.BrokerClass),
+					WithInitBrokerConditions,
+					WithBrokerReady("url"),
+					WithBrokerSetDefaults,
+				),
+				NewNamespace(testNS),
+				NewTrigger(triggerName, testNS, brokerName,
+					WithTriggerUID(testUID),
+					WithTriggerSubscriberURI(pubsubSubscriberURI),
+					WithTriggerSetDefaults),
+			},
+			WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+				Object: NewTrigger(triggerName, testNS, brokerName,
+					WithTriggerUID(testUID),
+					WithTriggerSubscriberURI(pubsubSubscriberURI),
+					WithTriggerBrokerReady,
+					WithTriggerSubscriptionReady,
+					WithTriggerTopicReady,
+					WithTriggerDependencyReady,
+					WithTriggerSubscriberResolvedFailed("SubscriberNotAllowed", `Namespace "testnamespace" is not labeled with events.cloud.google.com/pubsub-subscribers=true, Pub/Sub topic subscribers are not allowed`),
+					WithTriggerSetDefaults,
+				),
+			}},
+			WantEvents: []string{
+				triggerFinalizerUpdatedEvent,
+				topicCreatedEvent,
+				subscriptionCreatedEvent,
+				triggerReconciledEvent,
+			},
+			WantPatches: []clientgotesting.PatchActionImpl{
+				patchFinalizers(testNS, triggerName, finalizerName),
+			},
+		},
+		{
+			Name: "BigQuery table subscriber, namespace only opted into Pub/Sub topic subscribers",
+			Key:  testKey,
+			Objects: []runtime.Object{
+				NewBroker(brokerName, testNS,
+					WithBrokerClass(brokerv1.BrokerClass),
+					WithInitBrokerConditions,
+					WithBrokerReady("url"),
+					WithBrokerSetDefaults,
+				),
+				NewNamespace(testNS, WithNamespaceLabeled(map[string]string{brokerv1.PubsubSubscribersLabelKey: "true"})),
+				NewTrigger(triggerName, testNS, brokerName,
+					WithTriggerUID(testUID),
+					WithTriggerSubscriberURI(bigQuerySubscriberURI),
+					WithTriggerSetDefaults),
+			},
+			WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+				Object: NewTrigger(triggerName, testNS, brokerName,
+					WithTriggerUID(testUID),
+					WithTriggerSubscriberURI(bigQuerySubscriberURI),
+					WithTriggerBrokerReady,
+					WithTriggerSubscriptionReady,
+					WithTriggerTopicReady,
+					WithTriggerDependencyReady,
+					WithTriggerSubscriberResolvedFailed("SubscriberNotAllowed", `Namespace "testnamespace" is not labeled with events.cloud.google.com/bigquery-subscribers=true, BigQuery table subscribers are not allowed`),
+					WithTriggerSetDefaults,
+				),
+			}},
+			WantEvents: []string{
+				triggerFinalizerUpdatedEvent,
+				topicCreatedEvent,
+				subscriptionCreatedEvent,
+				triggerReconciledEvent,
+			},
+			WantPatches: []clientgotesting.PatchActionImpl{
+				patchFinalizers(testNS, triggerName, finalizerName),
+			},
+		},
 		{
 			Name: "Subsciber doesn't exist",
 			Key:  testKey,
@@ -490,6 +710,115 @@ func TestAllCasesTrigger(t *testing.T) {
 					}),
 			},
 		},
+		{
+			Name: "Consolidated retry topic, previous retry subscription drained",
+			Key:  testKey,
+			Objects: []runtime.Object{
+				NewBroker(brokerName, testNS,
+					WithBrokerClass(brokerv1.BrokerClass),
+					WithBrokerUID(testUID),
+					WithBrokerAnnotation(brokerv1.RetryTopicModeAnnotationKey, brokerv1.RetryTopicModeConsolidated),
+					WithInitBrokerConditions,
+					WithBrokerReady("url"),
+					WithBrokerDeliverySpec(brokerDeliverySpec),
+					WithBrokerSetDefaults,
+				),
+				makeSubscriberAddressableAsUnstructured(),
+				NewTrigger(triggerName, testNS, brokerName,
+					WithTriggerUID(testUID),
+					WithTriggerSubscriberRef(subscriberGVK, subscriberName, testNS),
+					WithTriggerRetrySubscriptionDraining("cre-tgr_testnamespace_test-trigger_abc123", time.Unix(1e9, 0)),
+					WithTriggerSetDefaults),
+			},
+			WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+				Object: NewTrigger(triggerName, testNS, brokerName,
+					WithTriggerUID(testUID),
+					WithTriggerSubscriberRef(subscriberGVK, subscriberName, testNS),
+					WithTriggerBrokerReady,
+					WithTriggerSubscriptionReady,
+					WithTriggerTopicReady,
+					WithTriggerDependencyReady,
+					WithTriggerSubscriberResolvedSucceeded,
+					WithTriggerStatusSubscriberURI(subscriberURI),
+					WithTriggerSetDefaults,
+				),
+			}},
+			WantEvents: []string{
+				triggerFinalizerUpdatedEvent,
+				Eventf(corev1.EventTypeNormal, "SubscriptionCreated", `Created PubSub subscription "cre-tgr-rty_testnamespace_test-trigger_abc123"`),
+				topicDeletedEvent,
+				subscriptionDeletedEvent,
+				triggerReconciledEvent,
+			},
+			WantPatches: []clientgotesting.PatchActionImpl{
+				patchFinalizers(testNS, triggerName, finalizerName),
+			},
+			OtherTestData: map[string]interface{}{
+				"pre": []PubsubAction{
+					Topic("cre-bkr-rty_testnamespace_test-broker_abc123"),
+					TopicAndSub("cre-tgr_testnamespace_test-trigger_abc123", "cre-tgr_testnamespace_test-trigger_abc123"),
+					Topic("test-dead-letter-topic-id"),
+				},
+			},
+			PostConditions: []func(*testing.T, *TableRow){
+				OnlyTopics("cre-bkr-rty_testnamespace_test-broker_abc123", "test-dead-letter-topic-id"),
+				OnlySubscriptions("cre-tgr-rty_testnamespace_test-trigger_abc123"),
+				SubscriptionHasFilter("cre-tgr-rty_testnamespace_test-trigger_abc123", `attributes."ce-kgcptarget" = "abc123"`),
+				SubscriptionHasDeadLetterPolicy("cre-tgr-rty_testnamespace_test-trigger_abc123",
+					&pubsub.DeadLetterPolicy{
+						MaxDeliveryAttempts: 3,
+						DeadLetterTopic:     "projects/test-project-id/topics/test-dead-letter-topic-id",
+					}),
+			},
+		},
+		{
+			Name: "Consolidated retry topic not created yet",
+			Key:  testKey,
+			Objects: []runtime.Object{
+				NewBroker(brokerName, testNS,
+					WithBrokerClass(brokerv1.BrokerClass),
+					WithBrokerUID(testUID),
+					WithBrokerAnnotation(brokerv1.RetryTopicModeAnnotationKey, brokerv1.RetryTopicModeConsolidated),
+					WithInitBrokerConditions,
+					WithBrokerReady("url"),
+					WithBrokerDeliverySpec(brokerDeliverySpec),
+					WithBrokerSetDefaults,
+				),
+				makeSubscriberAddressableAsUnstructured(),
+				NewTrigger(triggerName, testNS, brokerName,
+					WithTriggerUID(testUID),
+					WithTriggerSubscriberRef(subscriberGVK, subscriberName, testNS),
+					WithTriggerSetDefaults),
+			},
+			WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+				Object: NewTrigger(triggerName, testNS, brokerName,
+					WithTriggerUID(testUID),
+					WithTriggerSubscriberRef(subscriberGVK, subscriberName, testNS),
+					WithInitTriggerConditions,
+					WithTriggerBrokerReady,
+					WithTriggerTopicUnknown("RetryTopicNotFound", `Retry topic "cre-bkr-rty_testnamespace_test-broker_abc123" does not exist yet`),
+					WithTriggerSubscriberResolvedSucceeded,
+					WithTriggerStatusSubscriberURI(subscriberURI),
+					WithTriggerSetDefaults,
+				),
+			}},
+			WantEvents: []string{
+				triggerFinalizerUpdatedEvent,
+				Eventf(corev1.EventTypeWarning, "InternalError", `retry topic "cre-bkr-rty_testnamespace_test-broker_abc123" does not exist yet`),
+			},
+			WantPatches: []clientgotesting.PatchActionImpl{
+				patchFinalizers(testNS, triggerName, finalizerName),
+			},
+			WantErr: true,
+			OtherTestData: map[string]interface{}{
+				"pre": []PubsubAction{
+					Topic("test-dead-letter-topic-id"),
+				},
+			},
+			PostConditions: []func(*testing.T, *TableRow){
+				NoSubscriptionsExist(),
+			},
+		},
 		{
 			Name: "Check topic config and labels - broker without spec.delivery.retry",
 			Key:  testKey,
@@ -783,6 +1112,7 @@ func TestAllCasesTrigger(t *testing.T) {
 		r := &Reconciler{
 			Base:               reconciler.NewBase(ctx, controllerAgentName, cmw),
 			brokerLister:       listers.GetBrokerLister(),
+			namespaceLister:    listers.GetNamespaceLister(),
 			sourceTracker:      duck.NewListableTracker(ctx, source.Get, func(types.NamespacedName) {}, 0),
 			addressableTracker: duck.NewListableTracker(ctx, addressable.Get, func(types.NamespacedName) {}, 0),
 			uriResolver:        resolver.NewURIResolver(ctx, func(types.NamespacedName) {}),
@@ -791,9 +1121,16 @@ func TestAllCasesTrigger(t *testing.T) {
 				PubsubClient:       testPSClient,
 				DataresidencyStore: drStore,
 				ClusterRegion:      testClusterRegion,
+				ClusterName:        testClusterName,
 			},
 		}
 
+		if h, ok := testData["topicIAM"]; ok {
+			r.topicIAM = func(string, string) giam.Handle {
+				return h.(giam.Handle)
+			}
+		}
+
 		return triggerreconciler.NewReconciler(ctx, r.Logger, r.RunClientSet, listers.GetTriggerLister(), r.Recorder, r, withAgentAndFinalizer(nil))
 	}))
 }
@@ -816,6 +1153,20 @@ func makeSubscriberAddressableAsUnstructured() *unstructured.Unstructured {
 	}
 }
 
+// topicHasPublisher checks whether the member is granted the publisher role in the policy of the
+// topicIAM handle.
+func topicHasPublisher(member string, want bool) func(*testing.T, *TableRow) {
+	return func(t *testing.T, r *TableRow) {
+		policy, err := r.OtherTestData["topicIAM"].(giam.Handle).Policy(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := policy.HasRole(member, publisherRole); got != want {
+			t.Errorf("%s granted %s on the topic got %t, want %t", member, publisherRole, got, want)
+		}
+	}
+}
+
 // TODO Move to a util package so all reconciler tests can use.
 func patchFinalizers(namespace, name, finalizer string) clientgotesting.PatchActionImpl {
 	action := clientgotesting.PatchActionImpl{}
@@ -67,12 +67,18 @@ func (r *Reconciler) ReconcileSubscription(ctx context.Context, id string, subCo
 			return r.createSubscription(ctx, id, subConfig, obj, updater)
 		}
 		// Update the subscription config in case the retry or dead letter policy changed. A nil policy indicates no change.
+		// Labels are only added, so that subscriptions created before a label was introduced get it.
+		labels, labelsChanged := mergeLabels(config.Labels, subConfig.Labels)
 		if (subConfig.RetryPolicy != nil && !equality.Semantic.DeepEqual(config.RetryPolicy, subConfig.RetryPolicy)) ||
-			(subConfig.DeadLetterPolicy != nil && !equality.Semantic.DeepEqual(config.DeadLetterPolicy, subConfig.DeadLetterPolicy)) {
+			(subConfig.DeadLetterPolicy != nil && !equality.Semantic.DeepEqual(config.DeadLetterPolicy, subConfig.DeadLetterPolicy)) ||
+			labelsChanged {
 			updateSubConfig := pubsub.SubscriptionConfigToUpdate{
 				RetryPolicy:      subConfig.RetryPolicy,
 				DeadLetterPolicy: subConfig.DeadLetterPolicy,
 			}
+			if labelsChanged {
+				updateSubConfig.Labels = labels
+			}
 			if _, err := sub.Update(ctx, updateSubConfig); err != nil {
 				updater.MarkSubscriptionFailed("SubscriptionConfigUpdateFailed", "Failed to update Pub/Sub subscription config: %v", err)
 				return nil, err
@@ -87,6 +93,23 @@ func (r *Reconciler) ReconcileSubscription(ctx context.Context, id string, subCo
 	return r.createSubscription(ctx, id, subConfig, obj, updater)
 }
 
+// mergeLabels returns the existing labels with the desired labels set, and whether any of them
+// changed.
+func mergeLabels(existing, desired map[string]string) (map[string]string, bool) {
+	changed := false
+	merged := make(map[string]string, len(existing)+len(desired))
+	for k, v := range existing {
+		merged[k] = v
+	}
+	for k, v := range desired {
+		if existing[k] != v {
+			merged[k] = v
+			changed = true
+		}
+	}
+	return merged, changed
+}
+
 func (r *Reconciler) DeleteSubscription(ctx context.Context, id string, obj runtime.Object, updater StatusUpdater) error {
 	logger := logging.FromContext(ctx)
 	logger.Debug("Deleting decoupling sub")
@@ -18,17 +18,24 @@ package pubsub
 
 import (
 	"context"
+	"fmt"
 
 	"cloud.google.com/go/pubsub"
+	"github.com/google/knative-gcp/pkg/apis/configs/dataresidency"
+	"github.com/google/knative-gcp/pkg/apis/configs/encryption"
 	"github.com/google/knative-gcp/pkg/logging"
 	"go.uber.org/zap"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
 const (
 	topicCreated = "TopicCreated"
 	topicDeleted = "TopicDeleted"
+
+	topicDataResidencyConflict = "TopicDataResidencyConflict"
+	topicEncryptionConflict    = "TopicEncryptionConflict"
 )
 
 func (r *Reconciler) ReconcileTopic(ctx context.Context, id string, topicConfig *pubsub.TopicConfig, obj runtime.Object, updater StatusUpdater) (*pubsub.Topic, error) {
@@ -61,6 +68,85 @@ func (r *Reconciler) ReconcileTopic(ctx context.Context, id string, topicConfig
 	return topic, nil
 }
 
+// ComputeTopicConfig sets the allowed persistence regions and the Cloud KMS key of the config of a
+// topic following the data residency and the encryption of obj, and returns the regions the topic
+// is required to persist its messages in and the key it is required to be encrypted with, if any.
+func ComputeTopicConfig(ctx context.Context, drStore *dataresidency.Store, eStore *encryption.Store, topicConfig *pubsub.TopicConfig, clusterRegion string, obj metav1.Object) ([]string, string) {
+	logger := logging.FromContext(ctx)
+	var regions []string
+	if drStore != nil {
+		defaults := drStore.Load().DataResidencyDefaults
+		if defaults.ComputeAllowedPersistenceRegions(topicConfig, clusterRegion, obj) {
+			logger.Debug("Updated Topic Config AllowedPersistenceRegions", zap.Any("topicConfig", *topicConfig))
+		}
+		regions = defaults.RequiredPersistenceRegions(obj)
+	}
+	var kmsKeyName string
+	if eStore != nil {
+		defaults := eStore.Load().EncryptionDefaults
+		if defaults.ComputeKMSKeyName(topicConfig, obj) {
+			logger.Debug("Updated Topic Config KMSKeyName", zap.Any("topicConfig", *topicConfig))
+		}
+		kmsKeyName = defaults.KMSKeyName(obj)
+	}
+	return regions, kmsKeyName
+}
+
+// CheckTopicConfig verifies that the topic only persists its messages in the allowed regions of
+// the data residency of the resource, and that it is encrypted with the Cloud KMS key required by
+// its encryption. An existing topic keeps its message storage policy and its key, so conflicts are
+// reported on the status rather than fixed. The config of the topic is only fetched once.
+func (r *Reconciler) CheckTopicConfig(ctx context.Context, topic *pubsub.Topic, allowedRegions []string, kmsKeyName string, updater StatusUpdater) error {
+	if len(allowedRegions) == 0 && kmsKeyName == "" {
+		return nil
+	}
+	logger := logging.FromContext(ctx)
+	cfg, err := topic.Config(ctx)
+	if err != nil {
+		logger.Error("Failed to get Pub/Sub topic config", zap.Error(err))
+		updater.MarkTopicUnknown("TopicVerificationFailed", "Failed to get Pub/Sub topic config: %v", err)
+		return err
+	}
+	if err := checkPersistenceRegions(topic.ID(), cfg, allowedRegions); err != nil {
+		logger.Error("Pub/Sub topic conflicts with the data residency", zap.Error(err))
+		updater.MarkTopicFailed(topicDataResidencyConflict, "%v", err)
+		return err
+	}
+	if err := checkKMSKeyName(topic.ID(), cfg, kmsKeyName); err != nil {
+		logger.Error("Pub/Sub topic conflicts with the encryption", zap.Error(err))
+		updater.MarkTopicFailed(topicEncryptionConflict, "%v", err)
+		return err
+	}
+	return nil
+}
+
+// checkPersistenceRegions returns an error if the topic persists its messages outside of the
+// allowed regions.
+func checkPersistenceRegions(id string, cfg pubsub.TopicConfig, allowed []string) error {
+	if len(allowed) == 0 {
+		return nil
+	}
+	conflicts, conflict := dataresidency.ConflictingPersistenceRegions(allowed, cfg.MessageStoragePolicy.AllowedPersistenceRegions)
+	if !conflict {
+		return nil
+	}
+	if len(conflicts) == 0 {
+		return fmt.Errorf("topic %q persists messages in any region, the data residency only allows %v", id, allowed)
+	}
+	return fmt.Errorf("topic %q persists messages in regions %v, the data residency only allows %v", id, conflicts, allowed)
+}
+
+// checkKMSKeyName returns an error if the topic is not encrypted with the Cloud KMS key.
+func checkKMSKeyName(id string, cfg pubsub.TopicConfig, kmsKeyName string) error {
+	if kmsKeyName == "" || cfg.KMSKeyName == kmsKeyName {
+		return nil
+	}
+	if cfg.KMSKeyName == "" {
+		return fmt.Errorf("topic %q is encrypted with a Google-managed key, the encryption requires key %q", id, kmsKeyName)
+	}
+	return fmt.Errorf("topic %q is encrypted with key %q, the encryption requires key %q", id, cfg.KMSKeyName, kmsKeyName)
+}
+
 func (r *Reconciler) DeleteTopic(ctx context.Context, id string, obj runtime.Object, updater StatusUpdater) error {
 	logger := logging.FromContext(ctx)
 	logger.Debug("Deleting decoupling topic")
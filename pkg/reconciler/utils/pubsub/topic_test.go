@@ -71,6 +71,119 @@ func TestReconcileTopic(t *testing.T) {
 
 }
 
+func TestCheckTopicConfig(t *testing.T) {
+	const (
+		key      = "projects/test-project/locations/us-east1/keyRings/ring/cryptoKeys/key"
+		otherKey = "projects/test-project/locations/us-east1/keyRings/ring/cryptoKeys/other-key"
+	)
+	tests := []struct {
+		testCase
+		allowed    []string
+		kmsKeyName string
+		wantErr    bool
+	}{
+		{
+			testCase: testCase{
+				name: "no data residency nor encryption",
+			},
+		},
+		{
+			testCase: testCase{
+				name: "topic in allowed regions",
+				pre: []reconcilertesting.PubsubAction{reconcilertesting.TopicWithConfig(topic, &pubsub.TopicConfig{
+					MessageStoragePolicy: pubsub.MessageStoragePolicy{AllowedPersistenceRegions: []string{"us-east1"}},
+				})},
+			},
+			allowed: []string{"us-east1", "us-west1"},
+		},
+		{
+			testCase: testCase{
+				name: "topic in other regions",
+				pre: []reconcilertesting.PubsubAction{reconcilertesting.TopicWithConfig(topic, &pubsub.TopicConfig{
+					MessageStoragePolicy: pubsub.MessageStoragePolicy{AllowedPersistenceRegions: []string{"us-east1", "europe-west1"}},
+				})},
+				wantTopicCondition: apis.Condition{
+					Status:  corev1.ConditionFalse,
+					Reason:  "TopicDataResidencyConflict",
+					Message: `topic "test-topic" persists messages in regions [us-east1], the data residency only allows [europe-west1]`,
+				},
+			},
+			allowed: []string{"europe-west1"},
+			wantErr: true,
+		},
+		{
+			testCase: testCase{
+				name: "topic in any region",
+				pre:  []reconcilertesting.PubsubAction{reconcilertesting.Topic(topic)},
+				wantTopicCondition: apis.Condition{
+					Status:  corev1.ConditionFalse,
+					Reason:  "TopicDataResidencyConflict",
+					Message: `topic "test-topic" persists messages in any region, the data residency only allows [europe-west1]`,
+				},
+			},
+			allowed: []string{"europe-west1"},
+			wantErr: true,
+		},
+		{
+			testCase: testCase{
+				name: "topic encrypted with the key",
+				pre:  []reconcilertesting.PubsubAction{reconcilertesting.TopicWithConfig(topic, &pubsub.TopicConfig{KMSKeyName: key})},
+			},
+			kmsKeyName: key,
+		},
+		{
+			testCase: testCase{
+				name: "topic encrypted with another key",
+				pre:  []reconcilertesting.PubsubAction{reconcilertesting.TopicWithConfig(topic, &pubsub.TopicConfig{KMSKeyName: otherKey})},
+				wantTopicCondition: apis.Condition{
+					Status:  corev1.ConditionFalse,
+					Reason:  "TopicEncryptionConflict",
+					Message: `topic "test-topic" is encrypted with key "` + otherKey + `", the encryption requires key "` + key + `"`,
+				},
+			},
+			kmsKeyName: key,
+			wantErr:    true,
+		},
+		{
+			testCase: testCase{
+				name: "topic encrypted with a Google-managed key",
+				pre:  []reconcilertesting.PubsubAction{reconcilertesting.Topic(topic)},
+				wantTopicCondition: apis.Condition{
+					Status:  corev1.ConditionFalse,
+					Reason:  "TopicEncryptionConflict",
+					Message: `topic "test-topic" is encrypted with a Google-managed key, the encryption requires key "` + key + `"`,
+				},
+			},
+			kmsKeyName: key,
+			wantErr:    true,
+		},
+		{
+			testCase: testCase{
+				name: "topic in allowed regions and encrypted with the key",
+				pre: []reconcilertesting.PubsubAction{reconcilertesting.TopicWithConfig(topic, &pubsub.TopicConfig{
+					MessageStoragePolicy: pubsub.MessageStoragePolicy{AllowedPersistenceRegions: []string{"us-east1"}},
+					KMSKeyName:           key,
+				})},
+			},
+			allowed:    []string{"us-east1"},
+			kmsKeyName: key,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tr, cleanup := newTestRunner(t, tc.testCase)
+			defer cleanup()
+			r := NewReconciler(tr.client, tr.recorder)
+			su := &utilspubsubtesting.StatusUpdater{}
+			err := r.CheckTopicConfig(context.Background(), tr.client.Topic(topic), tc.allowed, tc.kmsKeyName, su)
+			if (err != nil) != tc.wantErr {
+				t.Errorf("Unexpected error, got: %v, want error: %v", err, tc.wantErr)
+			}
+			tr.verify(t, tc.testCase, su, err)
+		})
+	}
+}
+
 func TestDeleteTopic(t *testing.T) {
 	tests := []testCase{
 		{
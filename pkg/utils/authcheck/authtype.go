@@ -53,6 +53,7 @@ const (
 	AuthenticationCheckUnknownReason = "AuthenticationCheckPending"
 	ControlPlaneNamespace            = "cloud-run-events"
 	BrokerServiceAccountName         = "broker"
+	ControllerServiceAccountName     = "controller"
 )
 
 var (
@@ -32,6 +32,7 @@ import (
 )
 
 type Port int
+type GRPCPort int
 type ProjectID string
 type MaxConnsPerHost int
 